@@ -289,6 +289,15 @@ var ControllersDisabledByDefault = sets.NewString()
 func NewControllerInitializers() map[string]InitFunc {
 	controllers := map[string]InitFunc{}
 	controllers["nodelifecycle"] = startNodeLifecycleController
+	controllers["yurthubconfig"] = startYurtHubConfigController
+	controllers["evictionpolicy"] = startEvictionPolicyController
+	controllers["registrymirror"] = startRegistryMirrorController
+	controllers["hostconfig"] = startHostConfigController
+	controllers["nodecommand"] = startNodeCommandController
+	controllers["strictnodebinding"] = startStrictNodeBindingController
+	controllers["poolsummary"] = startPoolSummaryController
+	controllers["edgepriority"] = startEdgePriorityController
+	controllers["clusterinfo"] = startClusterInfoController
 
 	return controllers
 }
@@ -22,9 +22,24 @@ limitations under the License.
 package app
 
 import (
+	"io/ioutil"
 	"net/http"
 	"time"
 
+	"github.com/alibaba/openyurt/pkg/clusterinfo"
+	clusterinfocontroller "github.com/alibaba/openyurt/pkg/controller/clusterinfo"
+	"github.com/alibaba/openyurt/pkg/controller/edgepriority"
+	"github.com/alibaba/openyurt/pkg/controller/evictionpolicy"
+	"github.com/alibaba/openyurt/pkg/controller/hostconfig"
+	"github.com/alibaba/openyurt/pkg/controller/nodecommand"
+	"github.com/alibaba/openyurt/pkg/controller/poolsummary"
+	"github.com/alibaba/openyurt/pkg/controller/registrymirror"
+	"github.com/alibaba/openyurt/pkg/controller/strictbinding"
+	"github.com/alibaba/openyurt/pkg/controller/yurthubconfig"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/klog"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	lifecyclecontroller "k8s.io/kubernetes/pkg/controller/nodelifecycle"
 	"k8s.io/kubernetes/pkg/features"
@@ -57,3 +72,169 @@ func startNodeLifecycleController(ctx ControllerContext) (http.Handler, bool, er
 	go lifecycleController.Run(ctx.Stop)
 	return nil, true, nil
 }
+
+func startYurtHubConfigController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[yurthubconfig.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, yurthub config controller is not started", yurthubconfig.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("yurthub-config-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	hubConfigController := yurthubconfig.NewController(
+		informerFactory.ForResource(yurthubconfig.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.ClientBuilder.ClientOrDie("yurthub-config-controller"),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go hubConfigController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startEvictionPolicyController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[evictionpolicy.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, eviction policy controller is not started", evictionpolicy.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("eviction-policy-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	evictionPolicyController := evictionpolicy.NewController(
+		informerFactory.ForResource(evictionpolicy.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.ClientBuilder.ClientOrDie("eviction-policy-controller"),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go evictionPolicyController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startRegistryMirrorController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[registrymirror.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, registry mirror controller is not started", registrymirror.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("registry-mirror-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	registryMirrorController := registrymirror.NewController(
+		informerFactory.ForResource(registrymirror.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.InformerFactory.Batch().V1().Jobs(),
+		ctx.ClientBuilder.ClientOrDie("registry-mirror-controller"),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go registryMirrorController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startHostConfigController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[hostconfig.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, host config controller is not started", hostconfig.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("host-config-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	hostConfigController := hostconfig.NewController(
+		informerFactory.ForResource(hostconfig.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.InformerFactory.Batch().V1().Jobs(),
+		ctx.ClientBuilder.ClientOrDie("host-config-controller"),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go hostConfigController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startNodeCommandController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[nodecommand.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, node command controller is not started", nodecommand.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("node-command-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	nodeCommandController := nodecommand.NewController(
+		informerFactory.ForResource(nodecommand.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.InformerFactory.Batch().V1().Jobs(),
+		ctx.ClientBuilder.ClientOrDie("node-command-controller"),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go nodeCommandController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startPoolSummaryController(ctx ControllerContext) (http.Handler, bool, error) {
+	if !ctx.AvailableResources[poolsummary.SchemeGroupVersionResource] {
+		klog.Warningf("%s is not available, node pool summary controller is not started", poolsummary.SchemeGroupVersionResource)
+		return nil, false, nil
+	}
+
+	dynamicClient := dynamic.NewForConfigOrDie(ctx.ClientBuilder.ConfigOrDie("pool-summary-controller"))
+	informerFactory := dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, ctx.ResyncPeriod())
+	poolSummaryController := poolsummary.NewController(
+		informerFactory.ForResource(poolsummary.SchemeGroupVersionResource),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.InformerFactory.Core().V1().Pods(),
+		dynamicClient,
+	)
+	informerFactory.Start(ctx.Stop)
+	go poolSummaryController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startStrictNodeBindingController(ctx ControllerContext) (http.Handler, bool, error) {
+	strictBindingController := strictbinding.NewController(
+		ctx.InformerFactory.Core().V1().Pods(),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.ClientBuilder.ClientOrDie("strict-node-binding-controller"),
+	)
+	go strictBindingController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startEdgePriorityController(ctx ControllerContext) (http.Handler, bool, error) {
+	edgePriorityController := edgepriority.NewController(
+		ctx.InformerFactory.Core().V1().Pods(),
+		ctx.InformerFactory.Core().V1().Nodes(),
+		ctx.InformerFactory.Scheduling().V1().PriorityClasses(),
+		ctx.ClientBuilder.ClientOrDie("edge-priority-controller"),
+	)
+	go edgePriorityController.Run(1, ctx.Stop)
+	return nil, true, nil
+}
+
+func startClusterInfoController(ctx ControllerContext) (http.Handler, bool, error) {
+	restConfig := ctx.ClientBuilder.ConfigOrDie("cluster-info-controller")
+	caData := restConfig.CAData
+	if len(caData) == 0 && len(restConfig.CAFile) != 0 {
+		var err error
+		if caData, err = ioutil.ReadFile(restConfig.CAFile); err != nil {
+			return nil, false, err
+		}
+	}
+
+	kubeClient := ctx.ClientBuilder.ClientOrDie("cluster-info-controller")
+	sourceInformerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, ctx.ResyncPeriod(),
+		informers.WithNamespace(clusterinfo.SourceNamespace))
+	publishedInformerFactory := informers.NewSharedInformerFactoryWithOptions(kubeClient, ctx.ResyncPeriod(),
+		informers.WithNamespace(clusterinfo.Namespace))
+	clusterInfoController := clusterinfocontroller.NewController(
+		sourceInformerFactory.Core().V1().ConfigMaps(),
+		sourceInformerFactory.Core().V1().Secrets(),
+		publishedInformerFactory.Core().V1().ConfigMaps(),
+		kubeClient,
+		caData,
+	)
+	sourceInformerFactory.Start(ctx.Stop)
+	publishedInformerFactory.Start(ctx.Stop)
+	go clusterInfoController.Run(ctx.Stop)
+	return nil, true, nil
+}
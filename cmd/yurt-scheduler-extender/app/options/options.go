@@ -0,0 +1,76 @@
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+type SchedulerExtenderOptions struct {
+	Kubeconfig            string
+	BindAddress           string
+	Port                  int
+	DisconnectedThreshold float64
+	WebhookPort           int
+	TLSCertFile           string
+	TLSPrivateKeyFile     string
+	ClientCAFile          string
+	ClientCRLFile         string
+	ClientCertMaxLifetime time.Duration
+}
+
+func NewSchedulerExtenderOptions() *SchedulerExtenderOptions {
+	return &SchedulerExtenderOptions{
+		BindAddress:           "0.0.0.0",
+		Port:                  10266,
+		DisconnectedThreshold: 0.55,
+		WebhookPort:           10267,
+	}
+}
+
+func ValidateOptions(options *SchedulerExtenderOptions) error {
+	if options.Port <= 0 || options.Port > 65535 {
+		return fmt.Errorf("port %d is invalid", options.Port)
+	}
+
+	if options.DisconnectedThreshold <= 0 || options.DisconnectedThreshold > 1 {
+		return fmt.Errorf("disconnected-pool-threshold %v is not in (0, 1]", options.DisconnectedThreshold)
+	}
+
+	if options.WebhookPort <= 0 || options.WebhookPort > 65535 {
+		return fmt.Errorf("webhook port %d is invalid", options.WebhookPort)
+	}
+
+	if (len(options.TLSCertFile) == 0) != (len(options.TLSPrivateKeyFile) == 0) {
+		return fmt.Errorf("tls-cert-file and tls-private-key-file should be set together")
+	}
+
+	if options.ClientCertMaxLifetime < 0 {
+		return fmt.Errorf("client-cert-max-lifetime %v can not be negative", options.ClientCertMaxLifetime)
+	}
+
+	if len(options.ClientCAFile) == 0 && (len(options.ClientCRLFile) != 0 || options.ClientCertMaxLifetime != 0) {
+		return fmt.Errorf("client-crl-file and client-cert-max-lifetime need client-ca-file")
+	}
+
+	return nil
+}
+
+// WebhookEnabled returns true if the admission webhook of node pools should be served
+func (o *SchedulerExtenderOptions) WebhookEnabled() bool {
+	return len(o.TLSCertFile) != 0 && len(o.TLSPrivateKeyFile) != 0
+}
+
+func (o *SchedulerExtenderOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Kubeconfig, "kubeconfig", o.Kubeconfig, "path to the kubeconfig file, in-cluster config is used if it's empty.")
+	fs.StringVar(&o.BindAddress, "bind-address", o.BindAddress, "the IP address on which to serve the extender requests of scheduler.")
+	fs.IntVar(&o.Port, "port", o.Port, "the port on which to serve the extender requests of scheduler.")
+	fs.Float64Var(&o.DisconnectedThreshold, "disconnected-pool-threshold", o.DisconnectedThreshold, "the fraction of unready nodes in a node pool that the node pool is regarded as disconnected from cloud, new pods are not placed on the nodes of disconnected node pools.")
+	fs.IntVar(&o.WebhookPort, "webhook-port", o.WebhookPort, "the port on which to serve the admission webhook that validates the node pool membership changes of nodes, in https.")
+	fs.StringVar(&o.TLSCertFile, "tls-cert-file", o.TLSCertFile, "the certificate for serving the admission webhook, the webhook is served only if it's set with tls-private-key-file.")
+	fs.StringVar(&o.TLSPrivateKeyFile, "tls-private-key-file", o.TLSPrivateKeyFile, "the private key of tls-cert-file.")
+	fs.StringVar(&o.ClientCAFile, "client-ca-file", o.ClientCAFile, "the ca certificates that verify the client certificates of admission webhook requests(like the one of kube-apiserver in its admission config), client certificates are required if it's set.")
+	fs.StringVar(&o.ClientCRLFile, "client-crl-file", o.ClientCRLFile, "the CRL(in PEM or DER) of the client certificates of admission webhook requests, the revoked certificates are rejected. the file is reloaded when it's changed, so a certificate is revoked without restarting the extender.")
+	fs.DurationVar(&o.ClientCertMaxLifetime, "client-cert-max-lifetime", o.ClientCertMaxLifetime, "the max lifetime(NotAfter - NotBefore) of the client certificates of admission webhook requests, the longer lived ones are rejected, so the rotation of client certificates is enforced. 0 means no limit.")
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurt-scheduler-extender/app/options"
+	"github.com/alibaba/openyurt/pkg/schedulerextender"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+const (
+	// yurt scheduler extender component name
+	componentSchedulerExtender = "yurt-scheduler-extender"
+	// shutdownTimeout bounds the time that in flight requests are waited for on shutdown
+	shutdownTimeout = 10 * time.Second
+)
+
+func NewCmdStartSchedulerExtender(stopCh <-chan struct{}) *cobra.Command {
+	extenderOptions := options.NewSchedulerExtenderOptions()
+
+	cmd := &cobra.Command{
+		Use:   componentSchedulerExtender,
+		Short: "Launch yurt-scheduler-extender",
+		Long:  "Launch yurt-scheduler-extender, a scheduler extender that understands node pools and node autonomy",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+				klog.V(1).Infof("FLAG: --%s=%q", flag.Name, flag.Value)
+			})
+			if err := options.ValidateOptions(extenderOptions); err != nil {
+				klog.Fatalf("validate options: %v", err)
+			}
+
+			if err := Run(extenderOptions, stopCh); err != nil {
+				klog.Fatalf("run yurt-scheduler-extender failed, %v", err)
+			}
+		},
+	}
+
+	extenderOptions.AddFlags(cmd.Flags())
+	return cmd
+}
+
+func Run(o *options.SchedulerExtenderOptions, stopCh <-chan struct{}) error {
+	cfg, err := clientcmd.BuildConfigFromFlags("", o.Kubeconfig)
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig, %v", err)
+	}
+	client, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create kube client, %v", err)
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	nodeInformer := informerFactory.Core().V1().Nodes()
+	extender := schedulerextender.NewExtender(podInformer.Lister(), nodeInformer.Lister(), o.DisconnectedThreshold)
+	informerFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, podInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced) {
+		return fmt.Errorf("failed to sync cache of pods and nodes")
+	}
+
+	servers := []*http.Server{{
+		Addr:    net.JoinHostPort(o.BindAddress, strconv.Itoa(o.Port)),
+		Handler: schedulerextender.NewHandler(extender),
+	}}
+	if o.WebhookEnabled() {
+		tlsConfig, err := schedulerextender.NewWebhookTLSConfig(o.ClientCAFile, o.ClientCRLFile, o.ClientCertMaxLifetime)
+		if err != nil {
+			return err
+		}
+		validator := schedulerextender.NewNodePoolValidator(podInformer.Lister())
+		servers = append(servers, &http.Server{
+			Addr:      net.JoinHostPort(o.BindAddress, strconv.Itoa(o.WebhookPort)),
+			Handler:   schedulerextender.NewWebhookHandler(validator),
+			TLSConfig: tlsConfig,
+		})
+	}
+
+	errCh := make(chan error, len(servers))
+	go func() {
+		klog.Infof("serve extender requests of scheduler at %s", servers[0].Addr)
+		errCh <- servers[0].ListenAndServe()
+	}()
+	if len(servers) > 1 {
+		go func() {
+			klog.Infof("serve admission webhook of node pools at %s", servers[1].Addr)
+			errCh <- servers[1].ListenAndServeTLS(o.TLSCertFile, o.TLSPrivateKeyFile)
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-stopCh:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	var shutdownErr error
+	for _, s := range servers {
+		if err := s.Shutdown(ctx); err != nil {
+			shutdownErr = err
+		}
+	}
+	return shutdownErr
+}
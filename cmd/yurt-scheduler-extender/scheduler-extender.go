@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/alibaba/openyurt/cmd/yurt-scheduler-extender/app"
+)
+
+func main() {
+	cmd := app.NewCmdStartSchedulerExtender(setupSignalHandler())
+	cmd.Flags().AddGoFlagSet(flag.CommandLine)
+	if err := cmd.Execute(); err != nil {
+		panic(err)
+	}
+}
+
+// setupSignalHandler returns a channel that is closed on SIGTERM or SIGINT,
+// the process exits immediately on the second signal.
+func setupSignalHandler() <-chan struct{} {
+	stopCh := make(chan struct{})
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-c
+		close(stopCh)
+		<-c
+		os.Exit(1)
+	}()
+
+	return stopCh
+}
@@ -11,17 +11,80 @@ import (
 )
 
 type YurtHubConfiguration struct {
-	LBMode                    string
-	RemoteServers             []*url.URL
-	YurtHubHost               string
-	YurtHubPort               int
-	GCFrequency               int
-	CertMgrMode               string
-	NodeName                  string
-	HeartbeatFailedRetry      int
-	HeartbeatHealthyThreshold int
-	HeartbeatTimeoutSeconds   int
-	MaxRequestInFlight        int
+	LBMode                     string
+	RemoteServers              []*url.URL
+	YurtHubHost                string
+	YurtHubPort                int
+	GCFrequency                int
+	CertMgrMode                string
+	NodeName                   string
+	HeartbeatFailedRetry       int
+	HeartbeatHealthyThreshold  int
+	HeartbeatTimeoutSeconds    int
+	MaxRequestInFlight         int
+	AggregatedAPICacheGroups   []string
+	EnableMetricsShim          bool
+	NodePool                   string
+	EnableHubConfig            bool
+	StaticHosts                []string
+	HostsFile                  string
+	EnableDNSCache             bool
+	BindAddresses              []string
+	EnableProxyProtocol        bool
+	EnableReusePort            bool
+	ShutdownDelaySeconds       int
+	CacheEncodings             []string
+	PullSecretRefreshFrequency int
+	ExecKubeconfig             string
+	BootstrapKubeconfig        string
+	EnableRemoteWipe           bool
+	DashboardAddress           string
+	MetricsBufferInterval      int
+	MetricsBufferMaxSnapshots  int
+	LongRunningVerbs           []string
+	LongRunningSubresources    []string
+	RequestTimeoutSeconds      int
+	RequestTimeouts            []string
+	ServeCacheWhenThrottled    bool
+	CachedEndpoints            []string
+	CredentialProviderHosts    []string
+	PluginDir                  string
+	PluginFailurePolicy        string
+	ReviewCacheSize            int
+	ReviewCacheTTLSeconds      int
+	ReviewCacheMaxStaleSeconds int
+	EnableLeaseBatching        bool
+	LeaseMinRenewSeconds       int
+	WriteDedupSize             int
+	WriteDedupTTLSeconds       int
+	MirrorKubeconfig           string
+	MirrorSamplePercent        int
+	StorageFsync               bool
+	CacheStorage               string
+	CacheStorageOptions        map[string]string
+	MemoryStorageSizeMB        int
+	DiskCachePath              string
+	SeedCacheFrom              string
+	CacheReadOnly              bool
+	CacheHighWaterMarkMB       int
+	CacheDailyWriteWarningMB   int
+	MemoryCacheSizeMB          int
+	AutoSizeMemory             bool
+	ListChunkSize              int
+	StorageCompression         string
+	EncryptionKeyFile          string
+	EncryptionKMSEndpoint      string
+	EncryptedResources         []string
+	DiskCacheSizeMB            int
+	DiskCacheMaxObjects        int
+	DiskCacheProtectedPrefixes []string
+	DiskCacheSharding          bool
+	DiscoverRemoteServers      bool
+	CacheTTLMinutes            int
+	CacheTTLOverrides          []string
+	// Flags is the flags that are set explicitly on command line, they are
+	// reported to cloud for detecting the drift of yurthub configuration.
+	Flags map[string]string
 }
 
 func Complete(options *options.YurtHubOptions) (*YurtHubConfiguration, error) {
@@ -31,17 +94,77 @@ func Complete(options *options.YurtHubOptions) (*YurtHubConfiguration, error) {
 	}
 
 	cfg := &YurtHubConfiguration{
-		LBMode:                    options.LBMode,
-		RemoteServers:             us,
-		YurtHubHost:               options.YurtHubHost,
-		YurtHubPort:               options.YurtHubPort,
-		GCFrequency:               options.GCFrequency,
-		CertMgrMode:               options.CertMgrMode,
-		NodeName:                  options.NodeName,
-		HeartbeatFailedRetry:      options.HeartbeatFailedRetry,
-		HeartbeatHealthyThreshold: options.HeartbeatHealthyThreshold,
-		HeartbeatTimeoutSeconds:   options.HeartbeatTimeoutSeconds,
-		MaxRequestInFlight:        options.MaxRequestInFlight,
+		LBMode:                     options.LBMode,
+		RemoteServers:              us,
+		YurtHubHost:                options.YurtHubHost,
+		YurtHubPort:                options.YurtHubPort,
+		GCFrequency:                options.GCFrequency,
+		CertMgrMode:                options.CertMgrMode,
+		NodeName:                   options.NodeName,
+		HeartbeatFailedRetry:       options.HeartbeatFailedRetry,
+		HeartbeatHealthyThreshold:  options.HeartbeatHealthyThreshold,
+		HeartbeatTimeoutSeconds:    options.HeartbeatTimeoutSeconds,
+		MaxRequestInFlight:         options.MaxRequestInFlight,
+		AggregatedAPICacheGroups:   options.AggregatedAPICacheGroups,
+		EnableMetricsShim:          options.EnableMetricsShim,
+		NodePool:                   options.NodePool,
+		EnableHubConfig:            options.EnableHubConfig,
+		StaticHosts:                options.StaticHosts,
+		HostsFile:                  options.HostsFile,
+		EnableDNSCache:             options.EnableDNSCache,
+		BindAddresses:              options.BindAddresses,
+		EnableProxyProtocol:        options.EnableProxyProtocol,
+		EnableReusePort:            options.EnableReusePort,
+		ShutdownDelaySeconds:       options.ShutdownDelaySeconds,
+		CacheEncodings:             options.CacheEncodings,
+		PullSecretRefreshFrequency: options.PullSecretRefreshFrequency,
+		ExecKubeconfig:             options.ExecKubeconfig,
+		BootstrapKubeconfig:        options.BootstrapKubeconfig,
+		EnableRemoteWipe:           options.EnableRemoteWipe,
+		DashboardAddress:           options.DashboardAddress,
+		MetricsBufferInterval:      options.MetricsBufferInterval,
+		MetricsBufferMaxSnapshots:  options.MetricsBufferMaxSnapshots,
+		LongRunningVerbs:           options.LongRunningVerbs,
+		LongRunningSubresources:    options.LongRunningSubresources,
+		RequestTimeoutSeconds:      options.RequestTimeoutSeconds,
+		RequestTimeouts:            options.RequestTimeouts,
+		ServeCacheWhenThrottled:    options.ServeCacheWhenThrottled,
+		CachedEndpoints:            options.CachedEndpoints,
+		CredentialProviderHosts:    options.CredentialProviderHosts,
+		PluginDir:                  options.PluginDir,
+		PluginFailurePolicy:        options.PluginFailurePolicy,
+		ReviewCacheSize:            options.ReviewCacheSize,
+		ReviewCacheTTLSeconds:      options.ReviewCacheTTLSeconds,
+		ReviewCacheMaxStaleSeconds: options.ReviewCacheMaxStaleSeconds,
+		EnableLeaseBatching:        options.EnableLeaseBatching,
+		LeaseMinRenewSeconds:       options.LeaseMinRenewSeconds,
+		WriteDedupSize:             options.WriteDedupSize,
+		WriteDedupTTLSeconds:       options.WriteDedupTTLSeconds,
+		MirrorKubeconfig:           options.MirrorKubeconfig,
+		MirrorSamplePercent:        options.MirrorSamplePercent,
+		StorageFsync:               options.StorageFsync,
+		CacheStorage:               options.CacheStorage,
+		CacheStorageOptions:        options.CacheStorageOptions,
+		MemoryStorageSizeMB:        options.MemoryStorageSizeMB,
+		DiskCachePath:              options.DiskCachePath,
+		SeedCacheFrom:              options.SeedCacheFrom,
+		CacheReadOnly:              options.CacheReadOnly,
+		CacheHighWaterMarkMB:       options.CacheHighWaterMarkMB,
+		CacheDailyWriteWarningMB:   options.CacheDailyWriteWarningMB,
+		MemoryCacheSizeMB:          options.MemoryCacheSizeMB,
+		AutoSizeMemory:             options.AutoSizeMemory,
+		ListChunkSize:              options.ListChunkSize,
+		StorageCompression:         options.StorageCompression,
+		EncryptionKeyFile:          options.EncryptionKeyFile,
+		EncryptionKMSEndpoint:      options.EncryptionKMSEndpoint,
+		EncryptedResources:         options.EncryptedResources,
+		DiskCacheSizeMB:            options.DiskCacheSizeMB,
+		DiskCacheMaxObjects:        options.DiskCacheMaxObjects,
+		DiskCacheProtectedPrefixes: options.DiskCacheProtectedPrefixes,
+		DiskCacheSharding:          options.DiskCacheSharding,
+		DiscoverRemoteServers:      options.DiscoverRemoteServers,
+		CacheTTLMinutes:            options.CacheTTLMinutes,
+		CacheTTLOverrides:          options.CacheTTLOverrides,
 	}
 
 	return cfg, nil
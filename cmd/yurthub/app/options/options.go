@@ -2,36 +2,134 @@ package options
 
 import (
 	"fmt"
+	"net"
+	"strings"
 
+	"github.com/alibaba/openyurt/pkg/fips"
+	"github.com/alibaba/openyurt/pkg/yurthub/credentialprovider"
+	"github.com/alibaba/openyurt/pkg/yurthub/endpointcache"
+	"github.com/alibaba/openyurt/pkg/yurthub/features"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin"
+	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/resolver"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/expiry"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/factory"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/sqlite"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
 	"github.com/spf13/pflag"
 )
 
 type YurtHubOptions struct {
-	ServerAddr                string
-	YurtHubHost               string
-	YurtHubPort               int
-	GCFrequency               int
-	CertMgrMode               string
-	NodeName                  string
-	LBMode                    string
-	HeartbeatFailedRetry      int
-	HeartbeatHealthyThreshold int
-	HeartbeatTimeoutSeconds   int
-	MaxRequestInFlight        int
+	ServerAddr                 string
+	YurtHubHost                string
+	YurtHubPort                int
+	GCFrequency                int
+	CertMgrMode                string
+	NodeName                   string
+	LBMode                     string
+	HeartbeatFailedRetry       int
+	HeartbeatHealthyThreshold  int
+	HeartbeatTimeoutSeconds    int
+	MaxRequestInFlight         int
+	AggregatedAPICacheGroups   []string
+	EnableMetricsShim          bool
+	NodePool                   string
+	EnableHubConfig            bool
+	StaticHosts                []string
+	HostsFile                  string
+	EnableDNSCache             bool
+	BindAddresses              []string
+	EnableProxyProtocol        bool
+	EnableReusePort            bool
+	ShutdownDelaySeconds       int
+	CacheEncodings             []string
+	PullSecretRefreshFrequency int
+	ExecKubeconfig             string
+	BootstrapKubeconfig        string
+	EnableRemoteWipe           bool
+	DashboardAddress           string
+	MetricsBufferInterval      int
+	MetricsBufferMaxSnapshots  int
+	LongRunningVerbs           []string
+	LongRunningSubresources    []string
+	RequestTimeoutSeconds      int
+	RequestTimeouts            []string
+	ServeCacheWhenThrottled    bool
+	CachedEndpoints            []string
+	CredentialProviderHosts    []string
+	PluginDir                  string
+	PluginFailurePolicy        string
+	ReviewCacheSize            int
+	ReviewCacheTTLSeconds      int
+	ReviewCacheMaxStaleSeconds int
+	EnableLeaseBatching        bool
+	LeaseMinRenewSeconds       int
+	WriteDedupSize             int
+	WriteDedupTTLSeconds       int
+	MirrorKubeconfig           string
+	MirrorSamplePercent        int
+	StorageFsync               bool
+	CacheStorage               string
+	CacheStorageOptions        map[string]string
+	MemoryStorageSizeMB        int
+	DiskCachePath              string
+	SeedCacheFrom              string
+	CacheReadOnly              bool
+	CacheHighWaterMarkMB       int
+	CacheDailyWriteWarningMB   int
+	RequireFIPS                bool
+	MemoryCacheSizeMB          int
+	AutoSizeMemory             bool
+	ListChunkSize              int
+	StorageCompression         string
+	EncryptionKeyFile          string
+	EncryptionKMSEndpoint      string
+	EncryptedResources         []string
+	DiskCacheSizeMB            int
+	DiskCacheMaxObjects        int
+	DiskCacheProtectedPrefixes []string
+	DiskCacheSharding          bool
+	DiscoverRemoteServers      bool
+	CacheTTLMinutes            int
+	CacheTTLOverrides          []string
 }
 
 func NewYurtHubOptions() *YurtHubOptions {
 	o := &YurtHubOptions{
-		YurtHubHost:               "127.0.0.1",
-		YurtHubPort:               10261,
-		GCFrequency:               120,
-		CertMgrMode:               "kubelet",
-		LBMode:                    "rr",
-		HeartbeatFailedRetry:      3,
-		HeartbeatHealthyThreshold: 2,
-		HeartbeatTimeoutSeconds:   2,
-		MaxRequestInFlight:        250,
+		YurtHubHost:                "127.0.0.1",
+		YurtHubPort:                10261,
+		GCFrequency:                120,
+		CertMgrMode:                "kubelet",
+		LBMode:                     "rr",
+		HeartbeatFailedRetry:       3,
+		HeartbeatHealthyThreshold:  2,
+		HeartbeatTimeoutSeconds:    2,
+		MaxRequestInFlight:         250,
+		EnableDNSCache:             true,
+		PullSecretRefreshFrequency: 10,
+		MetricsBufferInterval:      60,
+		MetricsBufferMaxSnapshots:  720,
+		LongRunningVerbs:           []string{"watch", "proxy"},
+		LongRunningSubresources:    []string{"attach", "exec", "proxy", "log", "portforward"},
+		RequestTimeouts:            []string{"patch/nodes/status=15", "patch/pods/status=15", "update/leases=15", "list=300"},
+		PluginFailurePolicy:        plugin.FailurePolicyIgnore,
+		ReviewCacheSize:            1024,
+		ReviewCacheTTLSeconds:      120,
+		ReviewCacheMaxStaleSeconds: 1800,
+		LeaseMinRenewSeconds:       20,
+		WriteDedupSize:             256,
+		WriteDedupTTLSeconds:       60,
+		MirrorSamplePercent:        10,
+		CacheStorage:               factory.StorageDisk,
+		DiskCachePath:              disk.DefaultBaseDir,
+		MemoryCacheSizeMB:          16,
+		AutoSizeMemory:             true,
+		ListChunkSize:              500,
+		StorageCompression:         disk.CompressionNone,
+		EncryptedResources:         []string{"secrets"},
+		DiskCacheProtectedPrefixes: []string{"kubelet/pods", "kubelet/nodes"},
 	}
 
 	return o
@@ -54,19 +152,226 @@ func ValidateOptions(options *YurtHubOptions) error {
 		return fmt.Errorf("cert manage mode %s is not supported", options.CertMgrMode)
 	}
 
+	if options.CertMgrMode == "exec" && len(options.ExecKubeconfig) == 0 {
+		return fmt.Errorf("exec-kubeconfig is required in exec cert manage mode")
+	}
+
+	if options.MetricsBufferInterval < 0 {
+		return fmt.Errorf("metrics buffer interval(%d) can not be negative", options.MetricsBufferInterval)
+	}
+
+	if options.MetricsBufferInterval > 0 && options.MetricsBufferMaxSnapshots <= 0 {
+		return fmt.Errorf("metrics buffer max snapshots(%d) should be positive", options.MetricsBufferMaxSnapshots)
+	}
+
+	if len(options.DashboardAddress) != 0 {
+		if _, _, err := net.SplitHostPort(options.DashboardAddress); err != nil {
+			return fmt.Errorf("dashboard address(%s) is invalid, %v", options.DashboardAddress, err)
+		}
+	}
+
+	if options.ShutdownDelaySeconds < 0 {
+		return fmt.Errorf("shutdown delay seconds(%d) can not be negative", options.ShutdownDelaySeconds)
+	}
+
+	if options.PullSecretRefreshFrequency < 0 {
+		return fmt.Errorf("pull secret refresh frequency(%d) can not be negative", options.PullSecretRefreshFrequency)
+	}
+
+	if options.RequestTimeoutSeconds < 0 {
+		return fmt.Errorf("request timeout seconds(%d) can not be negative", options.RequestTimeoutSeconds)
+	}
+
+	if _, err := proxyutil.ParseRequestTimeouts(options.RequestTimeouts, 0); err != nil {
+		return err
+	}
+
+	if _, err := endpointcache.ParseEndpoints(options.CachedEndpoints); err != nil {
+		return err
+	}
+
+	if _, err := credentialprovider.ParseHosts(options.CredentialProviderHosts); err != nil {
+		return err
+	}
+
+	if !plugin.IsSupportedFailurePolicy(options.PluginFailurePolicy) {
+		return fmt.Errorf("plugin failure policy(%s) is not supported", options.PluginFailurePolicy)
+	}
+
+	if err := fips.Check(options.RequireFIPS); err != nil {
+		return err
+	}
+
+	if !factory.IsSupportedStorage(options.CacheStorage) {
+		return fmt.Errorf("cache storage %s is not supported, only %s are supported", options.CacheStorage, strings.Join(factory.SupportedStorages(), ", "))
+	}
+
+	if options.CacheStorage == factory.StorageSQLite {
+		if err := sqlite.CheckCgo(); err != nil {
+			return err
+		}
+	}
+
+	if options.MemoryStorageSizeMB < 0 {
+		return fmt.Errorf("memory storage size(%d) can not be negative", options.MemoryStorageSizeMB)
+	}
+
+	if len(options.DiskCachePath) == 0 {
+		return fmt.Errorf("disk cache path is empty")
+	}
+
+	if options.CacheReadOnly && len(options.SeedCacheFrom) != 0 {
+		return fmt.Errorf("seed cache from %s can not be imported into read-only cache", options.SeedCacheFrom)
+	}
+
+	if options.CacheHighWaterMarkMB < 0 {
+		return fmt.Errorf("cache high water mark(%d) can not be negative", options.CacheHighWaterMarkMB)
+	}
+
+	if options.CacheDailyWriteWarningMB < 0 {
+		return fmt.Errorf("cache daily write warning(%d) can not be negative", options.CacheDailyWriteWarningMB)
+	}
+
+	if options.MemoryCacheSizeMB < 0 {
+		return fmt.Errorf("memory cache size(%d) can not be negative", options.MemoryCacheSizeMB)
+	}
+
+	if options.CacheTTLMinutes < 0 {
+		return fmt.Errorf("cache ttl minutes(%d) can not be negative", options.CacheTTLMinutes)
+	}
+
+	if _, err := expiry.ParseOverrides(options.CacheTTLOverrides); err != nil {
+		return err
+	}
+
+	if options.DiskCacheSizeMB < 0 || options.DiskCacheMaxObjects < 0 {
+		return fmt.Errorf("disk cache size(%d) and max objects(%d) can not be negative", options.DiskCacheSizeMB, options.DiskCacheMaxObjects)
+	}
+
+	if !disk.IsSupportedCompression(options.StorageCompression) {
+		return fmt.Errorf("storage compression %s is not supported, only %s, %s and %s are supported", options.StorageCompression, disk.CompressionNone, disk.CompressionGzip, disk.CompressionSnappy)
+	}
+
+	if len(options.EncryptionKeyFile) != 0 && len(options.EncryptionKMSEndpoint) != 0 {
+		return fmt.Errorf("only one of encryption key file and kms endpoint can be set")
+	}
+
+	if (len(options.EncryptionKeyFile) != 0 || len(options.EncryptionKMSEndpoint) != 0) && !features.Enabled(features.CacheEncryption) {
+		return fmt.Errorf("encryption key file or kms endpoint is set, but feature gate %s is disabled", features.CacheEncryption)
+	}
+
+	if options.EnableLeaseBatching && !features.Enabled(features.LeaseBatching) {
+		return fmt.Errorf("lease batching is enabled, but feature gate %s is disabled", features.LeaseBatching)
+	}
+
+	if len(options.MirrorKubeconfig) != 0 && !features.Enabled(features.RequestMirroring) {
+		return fmt.Errorf("mirror kubeconfig is set, but feature gate %s is disabled", features.RequestMirroring)
+	}
+
+	if options.ListChunkSize < 0 {
+		return fmt.Errorf("list chunk size(%d) can not be negative", options.ListChunkSize)
+	}
+
+	if options.MirrorSamplePercent < 0 || options.MirrorSamplePercent > 100 {
+		return fmt.Errorf("mirror sample percent(%d) must be in [0, 100]", options.MirrorSamplePercent)
+	}
+
+	if options.LeaseMinRenewSeconds < 0 {
+		return fmt.Errorf("lease min renew seconds(%d) can not be negative", options.LeaseMinRenewSeconds)
+	}
+
+	if options.WriteDedupSize < 0 || options.WriteDedupTTLSeconds < 0 {
+		return fmt.Errorf("write dedup size(%d) and ttl seconds(%d) can not be negative", options.WriteDedupSize, options.WriteDedupTTLSeconds)
+	}
+
+	if options.ReviewCacheSize < 0 || options.ReviewCacheTTLSeconds < 0 || options.ReviewCacheMaxStaleSeconds < 0 {
+		return fmt.Errorf("review cache size(%d), ttl seconds(%d) and max stale seconds(%d) can not be negative",
+			options.ReviewCacheSize, options.ReviewCacheTTLSeconds, options.ReviewCacheMaxStaleSeconds)
+	}
+
+	if _, err := resolver.ParseStaticHosts(options.StaticHosts); err != nil {
+		return err
+	}
+
+	if err := serializer.NewStorageCodec().SetFormats(options.CacheEncodings); err != nil {
+		return err
+	}
+
 	return nil
 }
 
 func (o *YurtHubOptions) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.YurtHubHost, "yurt-hub-host", o.YurtHubHost, "the host that used to connect yurthub.")
+	fs.StringSliceVar(&o.BindAddresses, "bind-addresses", o.BindAddresses, "the ips or network interfaces(like \"lo,cni0\") that yurthub listens on, all ips of an interface are listened. yurt-hub-host is listened if not set.")
+	fs.BoolVar(&o.EnableProxyProtocol, "enable-proxy-protocol", o.EnableProxyProtocol, "accept proxy protocol(v1 and v2) header from a load balancer in front of yurthub, connections without header are accepted as well.")
+	fs.BoolVar(&o.EnableReusePort, "enable-reuse-port", o.EnableReusePort, "listen with SO_REUSEPORT(linux only), so the old and new yurthub can serve at the same time during restarts, and kubelet never gets connection refused.")
+	fs.IntVar(&o.ShutdownDelaySeconds, "shutdown-delay-seconds", o.ShutdownDelaySeconds, "number of seconds that yurthub keeps serving after it's stopped, so the new yurthub can be ready before the listeners are closed.")
 	fs.IntVar(&o.YurtHubPort, "yurt-hub-port", o.YurtHubPort, "the port that used to connect yurthub.")
 	fs.StringVar(&o.ServerAddr, "server-addr", o.ServerAddr, "the address of Kubernetes kube-apiserver,the format is: \"server1,server2,...\"")
-	fs.StringVar(&o.CertMgrMode, "cert-mgr-mode", o.CertMgrMode, "the cert manager mode, kubelet: use certificates that belongs to kubelet")
+	fs.StringVar(&o.CertMgrMode, "cert-mgr-mode", o.CertMgrMode, "the cert manager mode, kubelet: use certificates that belongs to kubelet, exec: use credentials issued by the exec credential plugin in exec-kubeconfig")
+	fs.StringVar(&o.BootstrapKubeconfig, "bootstrap-kubeconfig", o.BootstrapKubeconfig, "the bootstrap kubeconfig of kubelet(like /etc/kubernetes/bootstrap-kubelet.conf) in kubelet cert manage mode, its token is used while the client certificate of kubelet is not issued or is expired, so kubelet can bootstrap through yurthub. the certificate is used once it's issued, without restarting yurthub.")
+	fs.StringVar(&o.ExecKubeconfig, "exec-kubeconfig", o.ExecKubeconfig, "the kubeconfig whose current user sets the exec credential plugin(like cloud IAM authenticators) in exec cert manage mode, the certificate authority of its cluster is used to verify remote servers.")
 	fs.IntVar(&o.GCFrequency, "gc-frequency", o.GCFrequency, "the frequency to gc cache in storage(unit: minute).")
 	fs.StringVar(&o.NodeName, "node-name", o.NodeName, "the name of node that runs yurthub")
 	fs.StringVar(&o.LBMode, "lb-mode", o.LBMode, "the mode of load balancer to connect remote servers(rr, priority)")
+	fs.BoolVar(&o.DiscoverRemoteServers, "discover-remote-servers", o.DiscoverRemoteServers, "discover the addresses of kube-apiservers from the cached endpoints default/kubernetes, and add them to the load balancer in addition to server-addr at runtime, so yurthub follows the control plane when it's scaled or moved.")
 	fs.IntVar(&o.HeartbeatFailedRetry, "heartbeat-failed-retry", o.HeartbeatFailedRetry, "number of heartbeat request retry after having failed.")
 	fs.IntVar(&o.HeartbeatHealthyThreshold, "heartbeat-healthy-threshold", o.HeartbeatHealthyThreshold, "minimum consecutive successes for the heartbeat to be considered healthy after having failed.")
 	fs.IntVar(&o.HeartbeatTimeoutSeconds, "heartbeat-timeout-seconds", o.HeartbeatTimeoutSeconds, " number of seconds after which the heartbeat times out.")
-	fs.IntVar(&o.MaxRequestInFlight, "max-requests-in-flight", o.MaxRequestInFlight, "the maximum number of parallel requests.")
+	fs.IntVar(&o.MaxRequestInFlight, "max-requests-in-flight", o.MaxRequestInFlight, "the maximum number of parallel requests, long-running requests are not counted.")
+	fs.StringSliceVar(&o.LongRunningVerbs, "long-running-verbs", o.LongRunningVerbs, "the verbs of long-running requests, which are exempted from max-requests-in-flight and request-timeout-seconds.")
+	fs.StringSliceVar(&o.LongRunningSubresources, "long-running-subresources", o.LongRunningSubresources, "the subresources of long-running requests, which are exempted from max-requests-in-flight and request-timeout-seconds.")
+	fs.IntVar(&o.RequestTimeoutSeconds, "request-timeout-seconds", o.RequestTimeoutSeconds, "number of seconds after which the requests that are not long-running are timed out, unless request-timeouts sets the timeout of them. 0 means no timeout.")
+	fs.BoolVar(&o.ServeCacheWhenThrottled, "serve-cache-when-throttled", o.ServeCacheWhenThrottled, "serve gets and lists of cache agents from cache while their requests are throttled(429) by kube-apiserver, instead of rejecting them until the backoff ends.")
+	fs.StringSliceVar(&o.RequestTimeouts, "request-timeouts", o.RequestTimeouts, "the timeouts of requests by verb and resource, the format is: \"verb[/resource[/subresource]]=seconds,...\", verb can be * for all verbs, and the most specific one is used. 0 means no timeout, long-running requests are not timed out.")
+	fs.StringSliceVar(&o.AggregatedAPICacheGroups, "aggregated-api-cache-groups", o.AggregatedAPICacheGroups, "the aggregated api groups(like metrics.k8s.io) that responses can be cached, requests for other aggregated api groups are passed through only.")
+	fs.IntVar(&o.ListChunkSize, "list-chunk-size", o.ListChunkSize, "the number of items in a chunk when a cached json list response of aggregated api has more items than it, the chunks are stored in separate files and only the changed chunks are written when the list is updated. 0 stores every list in one file.")
+	fs.StringVar(&o.NodePool, "node-pool", o.NodePool, "the node pool that the node of yurthub belongs to, it is used to select the yurthub settings from cloud.")
+	fs.BoolVar(&o.EnableHubConfig, "enable-hub-config", o.EnableHubConfig, "watch yurthub settings(cache agents, aggregated api cache groups, max requests in flight) of the node pool in configmap from cloud and apply them at runtime.")
+	fs.BoolVar(&o.EnableMetricsShim, "enable-metrics-shim", o.EnableMetricsShim, "cache the last metrics.k8s.io responses and serve them as stale metrics to local consumers when cluster is unhealthy.")
+	fs.StringSliceVar(&o.StaticHosts, "static-hosts", o.StaticHosts, "the static addresses of remote server hosts, the format is: \"host1=ip1,host1=ip2,host2=ip3,...\", static hosts take precedence over dns and hosts file.")
+	fs.StringVar(&o.HostsFile, "hosts-file", o.HostsFile, "the file of static addresses of remote server hosts in the format of /etc/hosts.")
+	fs.StringSliceVar(&o.CacheEncodings, "cache-encodings", o.CacheEncodings, "the encodings(json, protobuf) of objects in cache, the format is: \"resource1=encoding1,resource2=encoding2,default-encoding\", objects are encoded in json by default. cached objects in any encoding can be read, so the encodings can be changed without dropping the cache.")
+	fs.IntVar(&o.PullSecretRefreshFrequency, "pull-secret-refresh-frequency", o.PullSecretRefreshFrequency, "the frequency to refresh the image pull secrets of pods on the node in cache(unit: minute), the secrets are refreshed after yurthub is reconnected to remote servers as well, so pods restarted during disconnection can pull images with the latest registry tokens. 0 disables the refreshing.")
+	fs.StringSliceVar(&o.CachedEndpoints, "cached-endpoints", o.CachedEndpoints, "the https endpoints outside of kubernetes(like config services) that node agents read through yurthub on /v1/endpoints/{name}, and their responses are cached, the format is: \"name1=url1[;ttl=seconds][;ca=file],...\". a cached response is fresh within ttl(300 seconds by default), and it's served when the endpoint is unavailable.")
+	fs.StringSliceVar(&o.CredentialProviderHosts, "credential-provider-hosts", o.CredentialProviderHosts, "the hosts(like cloud metadata services and registry auth endpoints) that the image credential providers of kubelet connect through yurthub as a forward proxy(HTTPS_PROXY/HTTP_PROXY of providers), the format is: \"host1:port1,*.domain2:port2,...\". https is tunneled, and the successful responses of plain http requests without credentials are cached in memory and served when the host is unavailable until they expire. the proxy is disabled if not set.")
+	fs.StringVar(&o.PluginDir, "plugin-dir", o.PluginDir, "the dir of unix sockets of plugins(like /var/run/yurthub/plugins), plugins are grpc servers that filter the requests to yurthub, they are registered and unregistered at runtime as their sockets are created and removed. plugins are disabled if not set.")
+	fs.StringVar(&o.PluginFailurePolicy, "plugin-failure-policy", o.PluginFailurePolicy, "how requests are handled when a plugin fails, Ignore: the plugin is skipped, Fail: the request is rejected.")
+	fs.IntVar(&o.ReviewCacheSize, "review-cache-size", o.ReviewCacheSize, "the maximum number of TokenReview and SubjectAccessReview results that node agents(like kubelet webhook auth) create through yurthub to cache in memory, the least recently used ones are evicted. 0 disables the cache.")
+	fs.IntVar(&o.ReviewCacheTTLSeconds, "review-cache-ttl-seconds", o.ReviewCacheTTLSeconds, "number of seconds that a cached review result is served without requesting kube-apiserver, denied results are fresh for 30 seconds at most. 0 disables the cache.")
+	fs.IntVar(&o.ReviewCacheMaxStaleSeconds, "review-cache-max-stale-seconds", o.ReviewCacheMaxStaleSeconds, "number of seconds that a cached review result is kept and served when kube-apiserver is unavailable, like the node is offline.")
+	fs.BoolVar(&o.EnableLeaseBatching, "enable-lease-batching", o.EnableLeaseBatching, "queue and de-duplicate the updates of leases(like node heartbeats of kubelet) that are sent to kube-apiserver through yurthub, updates of a lease are sent one at a time and a queued update is coalesced into a newer one.")
+	fs.IntVar(&o.LeaseMinRenewSeconds, "lease-min-renew-seconds", o.LeaseMinRenewSeconds, "number of seconds after a lease is renewed in kube-apiserver that the renewals which only move its renewTime forward are responded by yurthub without requesting kube-apiserver, half of the lease duration at most, when enable-lease-batching is set. 0 sends all renewals.")
+	fs.IntVar(&o.WriteDedupSize, "write-dedup-size", o.WriteDedupSize, "the maximum number of responses of successful writes(node status patches, events and lease updates) to record in memory, a retry of a recorded write(like after flapping reconnects) is responded with its response instead of being applied again. the least recently used ones are evicted. 0 disables the de-duplication.")
+	fs.IntVar(&o.WriteDedupTTLSeconds, "write-dedup-ttl-seconds", o.WriteDedupTTLSeconds, "number of seconds that the response of a successful write is recorded for its retries. 0 disables the de-duplication.")
+	fs.StringVar(&o.MirrorKubeconfig, "mirror-kubeconfig", o.MirrorKubeconfig, "the kubeconfig of a secondary cluster(like the staging control plane that nodes are moving to), the sampled read requests(get and list of resources, and discovery) are mirrored to it to rehearse the migration, and their status codes are compared with the primary in metrics. writes, watches and exec are never mirrored.")
+	fs.IntVar(&o.MirrorSamplePercent, "mirror-sample-percent", o.MirrorSamplePercent, "the percent of read requests that are mirrored to the cluster of mirror-kubeconfig, in [0, 100].")
+	fs.BoolVar(&o.StorageFsync, "storage-fsync", o.StorageFsync, "flush the cache files and their dirs to disk before the writes are completed, so the cache survives power loss of the node, at the cost of slower writes. cache files are always replaced atomically, an interrupted write never leaves a half-written file.")
+	fs.StringVar(&o.StorageCompression, "storage-compression", o.StorageCompression, "the compression of cached objects on disk, none, gzip or snappy. gzip saves the most disk space, snappy costs less cpu. objects cached with any compression can be read after it's changed.")
+	fs.StringVar(&o.EncryptionKeyFile, "encryption-key-file", o.EncryptionKeyFile, "the file of base64 encoded aes key(16, 24 or 32 bytes), the cached objects of encrypted resources are encrypted by aes-gcm with it on disk.")
+	fs.StringVar(&o.EncryptionKMSEndpoint, "encryption-kms-endpoint", o.EncryptionKMSEndpoint, "the unix socket of kms plugin(like unix:///var/run/kms-plugin.sock), the cached objects of encrypted resources are encrypted by aes-gcm with the data keys that are encrypted by the kms plugin. only one of encryption-key-file and encryption-kms-endpoint can be set.")
+	fs.StringSliceVar(&o.EncryptedResources, "encrypted-resources", o.EncryptedResources, "the resources that cached objects are encrypted on disk when encryption-key-file or encryption-kms-endpoint is set.")
+	fs.StringVar(&o.CacheStorage, "cache-storage", o.CacheStorage, "the storage of the cache of yurthub, disk, memory, bolt, sqlite or plugin. memory is for diskless edge devices, the cache is lost when yurthub restarts, so pods can not be restarted from cache if the node is offline after that. bolt stores the cache in one bbolt db file next to disk-cache-path(like /etc/kubernetes/cache.db) instead of a file per object, for the filesystems that are short of inodes, the cache on disk is moved into the db when it's created. sqlite stores the cache in one sqlite db file next to disk-cache-path(like /etc/kubernetes/cache.sqlite) with the metadata of objects(like kind and resourceVersion) in columns that can be queried, it requires yurthub built with cgo, which is disabled when yurthub is cross-compiled. plugin stores the cache in the storage plugin on the unix socket in option socket of cache-storage-options.")
+	fs.StringToStringVar(&o.CacheStorageOptions, "cache-storage-options", o.CacheStorageOptions, "the backend-specific options of cache-storage, like key1=value1,key2=value2. they are passed to the factory of the storage backend, and override the flags of built-in storages, like max-bytes of disk or memory storage.")
+	fs.IntVar(&o.MemoryStorageSizeMB, "memory-storage-size-mb", o.MemoryStorageSizeMB, "the maximum size in megabytes of the cache when cache-storage is memory, writes beyond it fail instead of evicting objects. 0 means no limit.")
+	fs.StringVar(&o.DiskCachePath, "disk-cache-path", o.DiskCachePath, "the dir on disk where the cache of yurthub is stored, like a dedicated partition for cache. yurthubs on the same host must use different dirs.")
+	fs.StringVar(&o.SeedCacheFrom, "seed-cache-from", o.SeedCacheFrom, "the path of a gzipped tarball of cache(like the one generated by yurtctl seed-cache) that is imported into the cache when yurthub starts, the objects already in the cache are kept, so pods can be started offline on a node that has never been connected to cloud. it's skipped if the file doesn't exist.")
+	fs.BoolVar(&o.CacheReadOnly, "cache-read-only", o.CacheReadOnly, "serve the cache as it is and never write it, for debugging the cache and for the nodes with failing disks. responses are not cached, writes of the cache(like gc, expiry and remote wipe) fail, and the temp files and corrupted keys left on disk are not cleaned when yurthub starts.")
+	fs.IntVar(&o.CacheHighWaterMarkMB, "cache-high-water-mark-mb", o.CacheHighWaterMarkMB, "the size in megabytes of cache that is taken as a runaway cache, a warning is logged and an event of the node is recorded when the sampled usage of cache crosses it, so operators notice it before the disk is full. 0 disables the warning.")
+	fs.IntVar(&o.CacheDailyWriteWarningMB, "cache-daily-write-warning-mb", o.CacheDailyWriteWarningMB, "the size in megabytes of the daily write volume of cache that wears out the flash(like eMMC) of the node too fast, a warning is logged and an event of the node is recorded when the volume estimated by the writes of the last hour crosses it. 0 disables the warning.")
+	fs.IntVar(&o.DiskCacheSizeMB, "disk-cache-size-mb", o.DiskCacheSizeMB, "the maximum size in megabytes of the cache on disk, the least recently updated objects are evicted when it's exceeded. 0 means no limit.")
+	fs.IntVar(&o.DiskCacheMaxObjects, "disk-cache-max-objects", o.DiskCacheMaxObjects, "the maximum number of objects in the cache on disk, the least recently updated objects are evicted when it's exceeded. 0 means no limit.")
+	fs.BoolVar(&o.DiskCacheSharding, "disk-cache-sharding", o.DiskCacheSharding, "put the cached objects of each collection into 256 hashed dirs of two levels instead of one flat dir, so the reads of dirs stay fast on flash media with thousands of objects(like configmaps). the cache is migrated once when yurthub starts after it's changed.")
+	fs.StringSliceVar(&o.DiskCacheProtectedPrefixes, "disk-cache-protected-prefixes", o.DiskCacheProtectedPrefixes, "the prefixes of cache keys(component/resource) that are never evicted from the cache on disk, like the objects that kubelet needs to restart pods when the node is offline.")
+	fs.IntVar(&o.CacheTTLMinutes, "cache-ttl-minutes", o.CacheTTLMinutes, "the minutes that a cached object is kept without being refreshed from cloud while yurthub is connected, so the objects whose deletes are missed don't stay in cache forever. it should be longer than the relist period of clients, because unchanged objects are refreshed only when they are listed again. 0 disables the expiry.")
+	fs.StringSliceVar(&o.CacheTTLOverrides, "cache-ttl-overrides", o.CacheTTLOverrides, "the cache ttl of resources that is different from cache-ttl-minutes, the format is: \"resource1=minutes1,resource2=minutes2\", 0 means the objects of the resource never expire.")
+	fs.IntVar(&o.MemoryCacheSizeMB, "memory-cache-size-mb", o.MemoryCacheSizeMB, "the maximum size in megabytes of cached objects that are kept in memory in front of the disk cache, so the repeated reads of hot objects(like node, leases and frequently listed resources) don't read the disk. 0 disables the memory cache.")
+	fs.BoolVar(&o.AutoSizeMemory, "auto-size-memory", o.AutoSizeMemory, "detect the memory limit of the cgroup that yurthub runs in(like the limit of its static pod), and lower memory-cache-size-mb, memory-storage-size-mb, max-requests-in-flight and review-cache-size to fit in it, unless they are set explicitly.")
+	fs.BoolVar(&o.RequireFIPS, "require-fips", o.RequireFIPS, "require FIPS 140-2 validated crypto(BoringCrypto) for tls, yurthub refuses to start if it's not built with BoringCrypto.")
+	fs.BoolVar(&o.EnableDNSCache, "enable-dns-cache", o.EnableDNSCache, "cache the addresses of remote server hosts resolved by dns in storage, and use them when dns is unavailable.")
+	fs.BoolVar(&o.EnableRemoteWipe, "enable-remote-wipe", o.EnableRemoteWipe, "serve /v1/admin/wipe that wipes the local cache(and credentials if requested) of yurthub, requests are authenticated and authorized by kube-apiserver, and audited by events of the node.")
+	fs.StringVar(&o.DashboardAddress, "dashboard-address", o.DashboardAddress, "the address(like 0.0.0.0:10268) that a read-only web page of connectivity, cache, certificate and recent errors of yurthub is served on for technicians on site, the page is not authenticated. disabled if not set.")
+	fs.IntVar(&o.MetricsBufferInterval, "metrics-buffer-interval-seconds", o.MetricsBufferInterval, "the interval in seconds that the metrics of yurthub are sampled into a buffer in memory while all remote servers are disconnected, and the buffered samples are served on /v1/metrics/buffered with their timestamps, so the monitoring in cloud can catch up on them after the node is reconnected. 0 disables the buffer.")
+	fs.IntVar(&o.MetricsBufferMaxSnapshots, "metrics-buffer-max-snapshots", o.MetricsBufferMaxSnapshots, "the maximum number of samples of yurthub metrics that are buffered, the oldest samples are dropped when it's exceeded.")
+	features.DefaultMutableFeatureGate.AddFlag(fs)
 }
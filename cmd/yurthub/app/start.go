@@ -1,20 +1,47 @@
 package app
 
 import (
+	"context"
+	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"time"
 
 	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
 	"github.com/alibaba/openyurt/cmd/yurthub/app/options"
+	"github.com/alibaba/openyurt/pkg/fips"
+	"github.com/alibaba/openyurt/pkg/yurthub/admin"
 	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate"
+	"github.com/alibaba/openyurt/pkg/yurthub/certificate/exec"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/initializer"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/kubelet"
+	"github.com/alibaba/openyurt/pkg/yurthub/credentialprovider"
+	"github.com/alibaba/openyurt/pkg/yurthub/dashboard"
+	"github.com/alibaba/openyurt/pkg/yurthub/discovery"
+	"github.com/alibaba/openyurt/pkg/yurthub/endpointcache"
 	"github.com/alibaba/openyurt/pkg/yurthub/gc"
 	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
 	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/memlimit"
+	"github.com/alibaba/openyurt/pkg/yurthub/metricsbuffer"
 	"github.com/alibaba/openyurt/pkg/yurthub/proxy"
+	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/pullsecret"
+	"github.com/alibaba/openyurt/pkg/yurthub/readiness"
+	"github.com/alibaba/openyurt/pkg/yurthub/resolver"
+	"github.com/alibaba/openyurt/pkg/yurthub/resync"
+	"github.com/alibaba/openyurt/pkg/yurthub/selfreport"
 	"github.com/alibaba/openyurt/pkg/yurthub/server"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/bolt"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/encryption"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/expiry"
 	"github.com/alibaba/openyurt/pkg/yurthub/storage/factory"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/sqlite"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/usage"
 	"github.com/alibaba/openyurt/pkg/yurthub/transport"
 
 	"github.com/spf13/cobra"
@@ -46,7 +73,12 @@ func NewCmdStartYurtHub(stopCh <-chan struct{}) *cobra.Command {
 			if err != nil {
 				klog.Fatalf("complete yurthub configuration error, %v", err)
 			}
+			yurtHubCfg.Flags = make(map[string]string)
+			cmd.Flags().Visit(func(flag *pflag.Flag) {
+				yurtHubCfg.Flags[flag.Name] = flag.Value.String()
+			})
 			klog.Infof("yurthub cfg: %#+v", yurtHubCfg)
+			klog.Infof("yurthub fips mode(BoringCrypto) enabled: %v", fips.Enabled())
 
 			if err := Run(yurtHubCfg, stopCh); err != nil {
 				klog.Fatalf("run yurthub failed, %v", err)
@@ -60,8 +92,77 @@ func NewCmdStartYurtHub(stopCh <-chan struct{}) *cobra.Command {
 
 func Run(cfg *config.YurtHubConfiguration, stopCh <-chan struct{}) error {
 	trace := 1
+	if cfg.AutoSizeMemory {
+		klog.Infof("%d. size memory of yurthub by its memory limit", trace)
+		autoSizeMemory(cfg)
+		trace++
+	}
+
+	if cfg.CacheStorage == factory.StorageMemory {
+		klog.Infof("%d. create storage manager in memory with %dMB limit", trace, cfg.MemoryStorageSizeMB)
+	} else if cfg.CacheStorage == factory.StorageBolt {
+		klog.Infof("%d. create storage manager in %s with %dMB memory cache", trace, bolt.DBPath(cfg.DiskCachePath), cfg.MemoryCacheSizeMB)
+	} else if cfg.CacheStorage == factory.StorageSQLite {
+		klog.Infof("%d. create storage manager in %s with %dMB memory cache", trace, sqlite.DBPath(cfg.DiskCachePath), cfg.MemoryCacheSizeMB)
+	} else if cfg.CacheStorage != factory.StorageDisk {
+		klog.Infof("%d. create storage manager of %s backend with %dMB memory cache", trace, cfg.CacheStorage, cfg.MemoryCacheSizeMB)
+	} else {
+		klog.Infof("%d. create storage manager in %s with %s compression and %dMB memory cache", trace, cfg.DiskCachePath, cfg.StorageCompression, cfg.MemoryCacheSizeMB)
+	}
+	storageOpts := factory.Options{
+		Type:    cfg.CacheStorage,
+		BaseDir: cfg.DiskCachePath,
+		Disk: disk.Options{
+			Fsync:             cfg.StorageFsync,
+			Compression:       cfg.StorageCompression,
+			MaxBytes:          int64(cfg.DiskCacheSizeMB) * 1024 * 1024,
+			MaxObjects:        cfg.DiskCacheMaxObjects,
+			ProtectedPrefixes: cfg.DiskCacheProtectedPrefixes,
+			Sharding:          cfg.DiskCacheSharding,
+		},
+		Encryption: encryption.Options{
+			KeyFile:     cfg.EncryptionKeyFile,
+			KMSEndpoint: cfg.EncryptionKMSEndpoint,
+			Resources:   cfg.EncryptedResources,
+		},
+		MemoryCacheBytes:   int64(cfg.MemoryCacheSizeMB) * 1024 * 1024,
+		MemoryStorageBytes: int64(cfg.MemoryStorageSizeMB) * 1024 * 1024,
+		ReadOnly:           cfg.CacheReadOnly,
+		BackendOptions:     cfg.CacheStorageOptions,
+	}
+	if storageOpts.Encryption.Enabled() && cfg.CacheStorage != factory.StorageMemory {
+		klog.Infof("%d. encrypt cache of resources %v on disk", trace, cfg.EncryptedResources)
+	}
+	if cfg.CacheReadOnly {
+		klog.Infof("%d. cache is read-only, it's served as it is and never written", trace)
+	}
+	storageManager, err := factory.CreateStorage(storageOpts)
+	if err != nil {
+		klog.Errorf("could not create storage manager, %v", err)
+		return err
+	}
+	trace++
+
+	if cfg.SeedCacheFrom != "" {
+		klog.Infof("%d. seed cache from %s", trace, cfg.SeedCacheFrom)
+		seedCache(storageManager, cfg.SeedCacheFrom)
+		trace++
+	}
+
+	klog.Infof("%d. new resolver for remote servers with %d static hosts", trace, len(cfg.StaticHosts))
+	var dnsCache storage.Store
+	if cfg.EnableDNSCache {
+		dnsCache = storageManager
+	}
+	hostResolver, err := resolver.NewResolver(cfg.StaticHosts, cfg.HostsFile, dnsCache)
+	if err != nil {
+		klog.Errorf("could not new resolver, %v", err)
+		return err
+	}
+	trace++
+
 	klog.Infof("%d. new transport manager for healthz client", trace)
-	transportManager, err := transport.NewTransportManager(cfg.HeartbeatTimeoutSeconds, stopCh)
+	transportManager, err := transport.NewTransportManager(cfg.HeartbeatTimeoutSeconds, hostResolver.DialContext, stopCh)
 	if err != nil {
 		klog.Errorf("could not new transport manager, %v", err)
 		return err
@@ -77,12 +178,13 @@ func Run(cfg *config.YurtHubConfiguration, stopCh <-chan struct{}) error {
 	trace++
 
 	klog.Infof("%d. init cert initializer", trace)
-	cmInitializer := initializer.NewCMInitializer(healthChecker)
+	cmInitializer := initializer.NewCMInitializer(healthChecker, storageManager)
 	trace++
 
 	klog.Infof("%d. register cert managers", trace)
 	cmr := certificate.NewCertificateManagerRegistry()
 	kubelet.Register(cmr)
+	exec.Register(cmr)
 	trace++
 
 	klog.Infof("%d. create cert manager with %s mode", trace, cfg.CertMgrMode)
@@ -101,13 +203,13 @@ func Run(cfg *config.YurtHubConfiguration, stopCh <-chan struct{}) error {
 	}
 	trace++
 
-	klog.Infof("%d. create storage manager", trace)
-	storageManager, err := factory.CreateStorage()
-	if err != nil {
-		klog.Errorf("could not create storage manager, %v", err)
+	klog.Infof("%d. new storage codec with cache encodings %v", trace, cfg.CacheEncodings)
+	storageCodec := serializer.NewStorageCodec()
+	if err := storageCodec.SetFormats(cfg.CacheEncodings); err != nil {
+		klog.Errorf("could not set cache encodings, %v", err)
 		return err
 	}
-	storageWrapper := cachemanager.NewStorageWrapper(storageManager)
+	storageWrapper := cachemanager.NewStorageWrapper(storageManager, storageCodec)
 	trace++
 
 	klog.Infof("%d. new serializer manager", trace)
@@ -115,7 +217,7 @@ func Run(cfg *config.YurtHubConfiguration, stopCh <-chan struct{}) error {
 	trace++
 
 	klog.Infof("%d. new yurt cache manager with storage wrapper and serializer manager", trace)
-	cacheMgr, err := cachemanager.NewCacheManager(storageWrapper, serializerManager)
+	cacheMgr, err := cachemanager.NewCacheManager(storageWrapper, serializerManager, cfg.AggregatedAPICacheGroups, cfg.ListChunkSize)
 	if err != nil {
 		klog.Errorf("could not new cache manager, %v", err)
 		return err
@@ -128,20 +230,209 @@ func Run(cfg *config.YurtHubConfiguration, stopCh <-chan struct{}) error {
 		klog.Errorf("could not new gc manager, %v", err)
 		return err
 	}
-	gcMgr.Run()
+	if !cfg.CacheReadOnly {
+		gcMgr.Run()
+	}
+	trace++
+
+	ttlOverrides, err := expiry.ParseOverrides(cfg.CacheTTLOverrides)
+	if err != nil {
+		klog.Errorf("could not parse cache ttl overrides, %v", err)
+		return err
+	}
+	expiryOpts := expiry.Options{TTL: time.Duration(cfg.CacheTTLMinutes) * time.Minute, Overrides: ttlOverrides}
+	if expiryOpts.Enabled() && !cfg.CacheReadOnly {
+		klog.Infof("%d. new expiry collector of cache, and default ttl is %d min", trace, cfg.CacheTTLMinutes)
+		collector := expiry.NewCollector(storageManager, storageWrapper.Delete, expiryOpts, cfg.RemoteServers, healthChecker, stopCh)
+		collector.Run()
+		trace++
+	}
+
+	klog.Infof("%d. new usage sampler of cache, high water mark is %d MB and daily write warning is %d MB", trace, cfg.CacheHighWaterMarkMB, cfg.CacheDailyWriteWarningMB)
+	usageSampler := usage.NewSampler(cfg, storageManager, transportManager, stopCh)
+	usageSampler.Run()
 	trace++
 
+	limiter := proxyutil.NewRequestLimiter(cfg.MaxRequestInFlight)
+	var hubCfgMgr *hubconfig.Manager
+	if cfg.EnableHubConfig {
+		klog.Infof("%d. new hub config manager for node pool(%s)", trace, cfg.NodePool)
+		hubCfgMgr = hubconfig.NewManager(cfg, cacheMgr, limiter, transportManager, stopCh)
+		hubCfgMgr.Run()
+		trace++
+	}
+
+	var discoverer *discovery.Discoverer
+	if cfg.DiscoverRemoteServers {
+		klog.Infof("%d. new discoverer of remote servers from cached endpoints", trace)
+		discoverer = discovery.NewDiscoverer(cfg, storageWrapper, cacheMgr.ListCacheAgents, stopCh)
+		trace++
+	}
+
 	klog.Infof("%d. new yurt reverse proxy handler for remote servers", trace)
-	yurtProxyHandler, err := proxy.NewYurtReverseProxyHandler(cfg, cacheMgr, transportManager, healthChecker, certManager, stopCh)
+	yurtProxyHandler, err := proxy.NewYurtReverseProxyHandler(cfg, cacheMgr, storageManager, transportManager, healthChecker, certManager, limiter, discoverer, stopCh)
 	if err != nil {
 		klog.Errorf("could not create yurt reverse proxy handler, %v", err)
 		return err
 	}
+	if hubCfgMgr != nil {
+		yurtProxyHandler = hubCfgMgr.WithRequestStats(yurtProxyHandler)
+	}
+	trace++
+
+	if !cfg.CacheReadOnly {
+		klog.Infof("%d. new cache resyncer for upgrades of kube-apiserver", trace)
+		resyncer := resync.NewResyncer(cfg, storageManager, storageWrapper, transportManager, healthChecker, stopCh)
+		resyncer.Run()
+		trace++
+	}
+
+	if cfg.PullSecretRefreshFrequency > 0 && !cfg.CacheReadOnly {
+		klog.Infof("%d. new image pull secret prefetcher for node %s, and refresh frequency is %d min", trace, cfg.NodeName, cfg.PullSecretRefreshFrequency)
+		prefetcher := pullsecret.NewPrefetcher(cfg, storageWrapper, transportManager, healthChecker, stopCh)
+		if hubCfgMgr != nil {
+			hubCfgMgr.OnMaintenance(prefetcher.SetMaintenance)
+		}
+		prefetcher.Run()
+		trace++
+	}
+
+	klog.Infof("%d. new watermark syncer for the resource version of cloud", trace)
+	watermarkSyncer := cachemanager.NewWatermarkSyncer(cfg, cacheMgr.Watermarks(), transportManager, healthChecker, stopCh)
+	watermarkSyncer.Run()
+	trace++
+
+	klog.Infof("%d. new self reporter of yurthub version(%s) for node %s", trace, selfreport.Version, cfg.NodeName)
+	reporter := selfreport.NewReporter(cfg, transportManager, stopCh)
+	reporter.Run()
+	trace++
+
+	klog.Infof("%d. new autonomy readiness evaluator for node %s", trace, cfg.NodeName)
+	evaluator := readiness.NewEvaluator(cfg, storageManager, certManager, transportManager, healthChecker, cfg.DiskCachePath, stopCh)
+	evaluator.Run()
 	trace++
 
+	if len(cfg.DashboardAddress) != 0 {
+		klog.Infof("%d. new dashboard of yurthub on %s", trace, cfg.DashboardAddress)
+		if err := dashboard.NewDashboard(cfg, healthChecker, storageManager, certManager, evaluator, stopCh).Run(cfg.DashboardAddress); err != nil {
+			klog.Errorf("could not run dashboard, %v", err)
+			return err
+		}
+		trace++
+	}
+
+	var metricsBuffer http.Handler
+	if cfg.MetricsBufferInterval > 0 {
+		klog.Infof("%d. new buffer of yurthub metrics while disconnected, and interval is %d seconds", trace, cfg.MetricsBufferInterval)
+		buffer := metricsbuffer.NewBuffer(cfg, healthChecker, stopCh)
+		buffer.Run()
+		metricsBuffer = buffer
+		trace++
+	}
+
+	var wiper http.Handler
+	if cfg.EnableRemoteWipe {
+		klog.Infof("%d. new wiper for remote wipe of cache", trace)
+		wiper = admin.NewWiper(cfg, storageManager, transportManager)
+		trace++
+	}
+
+	var endpointCache http.Handler
+	if len(cfg.CachedEndpoints) != 0 {
+		klog.Infof("%d. new cache for endpoints %v", trace, cfg.CachedEndpoints)
+		endpoints, err := endpointcache.ParseEndpoints(cfg.CachedEndpoints)
+		if err != nil {
+			return err
+		}
+		cache, err := endpointcache.NewCache(endpoints, storageManager, hostResolver.DialContext)
+		if err != nil {
+			klog.Errorf("could not new endpoint cache, %v", err)
+			return err
+		}
+		endpointCache = cache
+		trace++
+	}
+
+	var credentialProxy http.Handler
+	if len(cfg.CredentialProviderHosts) != 0 {
+		klog.Infof("%d. new proxy for credential providers to %v", trace, cfg.CredentialProviderHosts)
+		hosts, err := credentialprovider.ParseHosts(cfg.CredentialProviderHosts)
+		if err != nil {
+			return err
+		}
+		credentialProxy = credentialprovider.NewProxy(hosts, hostResolver.DialContext)
+		trace++
+	}
+
 	klog.Infof("%d. new yurthub server and begin to serve", trace)
-	s := server.NewYurtHubServer(cfg, certManager, yurtProxyHandler)
+	s := server.NewYurtHubServer(cfg, server.Options{
+		CertificateMgr:  certManager,
+		ProxyHandler:    yurtProxyHandler,
+		Storage:         storageManager,
+		StorageWrapper:  storageWrapper,
+		Watermarks:      cacheMgr.Watermarks(),
+		Evaluator:       evaluator,
+		Wiper:           wiper,
+		EndpointCache:   endpointCache,
+		CredentialProxy: credentialProxy,
+		MetricsBuffer:   metricsBuffer,
+	}, stopCh)
 	s.Run()
-	<-stopCh
 	return nil
 }
+
+// seedCache imports the cache in the tarball of path into store, the objects already in store
+// are kept. it's best effort, yurthub keeps starting if the tarball can't be imported, as the
+// cache can be filled from cloud later.
+func seedCache(store storage.Store, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			klog.Infof("seed of cache %s doesn't exist, skip it", path)
+		} else {
+			klog.Errorf("failed to open seed of cache %s, %v", path, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	imported, err := storage.Import(context.Background(), store, f, false)
+	if err != nil {
+		klog.Errorf("failed to seed cache from %s, %d objects are imported, %v", path, imported, err)
+		return
+	}
+	klog.Infof("%d objects are imported from seed of cache %s", imported, path)
+}
+
+// autoSizeMemory lowers the sizes of memory caches and max requests in flight that are not
+// set on command line to fit in the memory limit of yurthub, so yurthub in a static pod
+// with a small limit(like 128Mi) is not OOM-killed with the defaults. sizes are never raised
+// above the defaults.
+func autoSizeMemory(cfg *config.YurtHubConfiguration) {
+	limit, err := memlimit.Detect()
+	if err != nil {
+		klog.Errorf("failed to detect memory limit, %v, keep the default sizes", err)
+		return
+	} else if limit == 0 {
+		klog.Infof("memory of yurthub is not limited, keep the default sizes")
+		return
+	}
+
+	klog.Infof("memory of yurthub is limited to %d bytes", limit)
+	sizes := memlimit.SizesFor(limit)
+	// unlimited is true if 0 means no limit rather than disabled
+	lower := func(flag string, value *int, size int, unlimited bool) {
+		if _, ok := cfg.Flags[flag]; ok || *value <= size && !(unlimited && *value == 0) {
+			return
+		}
+		klog.Infof("%s is lowered from %d to %d", flag, *value, size)
+		*value = size
+	}
+	lower("memory-cache-size-mb", &cfg.MemoryCacheSizeMB, sizes.MemoryCacheSizeMB, false)
+	lower("max-requests-in-flight", &cfg.MaxRequestInFlight, sizes.MaxRequestInFlight, false)
+	lower("review-cache-size", &cfg.ReviewCacheSize, sizes.ReviewCacheSize, false)
+	lower("write-dedup-size", &cfg.WriteDedupSize, sizes.WriteDedupSize, false)
+	if cfg.CacheStorage == factory.StorageMemory {
+		lower("memory-storage-size-mb", &cfg.MemoryStorageSizeMB, sizes.MemoryStorageSizeMB, true)
+	}
+}
@@ -3,17 +3,35 @@ package main
 import (
 	"flag"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/alibaba/openyurt/cmd/yurthub/app"
-	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
-	cmd := app.NewCmdStartYurtHub(wait.NeverStop)
+	cmd := app.NewCmdStartYurtHub(setupSignalHandler())
 	cmd.Flags().AddGoFlagSet(flag.CommandLine)
 	if err := cmd.Execute(); err != nil {
 		panic(err)
 	}
 }
+
+// setupSignalHandler returns a channel that is closed on SIGTERM or SIGINT,
+// the process exits immediately on the second signal.
+func setupSignalHandler() <-chan struct{} {
+	stopCh := make(chan struct{})
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-c
+		close(stopCh)
+		<-c
+		os.Exit(1)
+	}()
+
+	return stopCh
+}
@@ -4,11 +4,14 @@ readonly YURT_ALL_TARGETS=(
     cmd/yurtctl
     cmd/yurthub
     cmd/yurt-controller-manager
+    cmd/yurt-scheduler-extender
 )
 
 build_binaries() {
     local goflags goldflags gcflags
     goldflags="${GOLDFLAGS=-s -w}"
+    # version of yurthub is reported to cloud for detecting the drift of yurthub configuration
+    goldflags+=" -X github.com/alibaba/openyurt/pkg/yurthub/selfreport.Version=$(git -C ${YURT_ROOT} describe --tags --always --dirty 2>/dev/null || echo unknown)"
     gcflags="${GOGCFLAGS:-}"
     goflags=${GOFLAGS:-}
 
@@ -28,10 +31,53 @@ build_binaries() {
       targets=("${YURT_ALL_TARGETS[@]}")
     fi
 
-    mkdir -p ${YURT_BIN_DIR}
-    cd ${YURT_BIN_DIR}
-    for binary in "${targets[@]}"; do
-      echo "Building ${binary}"
-      go build -ldflags "${goldflags:-}" -gcflags "${gcflags:-}" ${goflags} $YURT_ROOT/${binary}
+    # YURT_PLATFORMS is the platforms to build for, like "linux/amd64 linux/arm64 linux/arm",
+    # binaries are put in _output/bin/{os}_{arch}/, the host platform is built in _output/bin/ by default.
+    # go disables cgo when cross-compiling, so yurthub built for other platforms rejects
+    # --cache-storage=sqlite, set CGO_ENABLED=1 and CC to a cross compiler of the platform to keep it.
+    local -a platforms=(${YURT_PLATFORMS:-})
+    if [[ ${#platforms[@]} -eq 0 ]]; then
+      build_platform "" "${targets[@]}"
+      return
+    fi
+
+    local platform
+    for platform in "${platforms[@]}"; do
+      build_platform "${platform}" "${targets[@]}"
+    done
+}
+
+# build_platform builds the targets for platform(like linux/arm64), empty platform means the host platform.
+# when YURT_FIPS is true, binaries are built with BoringCrypto(FIPS 140-2 validated crypto) in
+# _output/bin/{os}_{arch}-fips/, it requires cgo, and only linux/amd64 and linux/arm64 are supported.
+build_platform() {
+    local platform=$1
+    shift
+
+    local goos goarch bin_dir
+    goos=$(go env GOOS)
+    goarch=$(go env GOARCH)
+    bin_dir=${YURT_BIN_DIR}
+    if [[ -n "${platform}" ]]; then
+      goos=${platform%/*}
+      goarch=${platform##*/}
+      bin_dir=${YURT_BIN_DIR}/${goos}_${goarch}
+    fi
+
+    local -a envs=("GOOS=${goos}" "GOARCH=${goarch}")
+    if [[ "${YURT_FIPS:-false}" == "true" ]]; then
+      if [[ "${goos}/${goarch}" != "linux/amd64" && "${goos}/${goarch}" != "linux/arm64" ]]; then
+        echo "fips build is not supported on ${goos}/${goarch}, only linux/amd64 and linux/arm64 are supported" >&2
+        return 1
+      fi
+      envs+=("GOEXPERIMENT=boringcrypto" "CGO_ENABLED=1")
+      bin_dir=${YURT_BIN_DIR}/${goos}_${goarch}-fips
+    fi
+
+    mkdir -p ${bin_dir}
+    local binary
+    for binary in "$@"; do
+      echo "Building ${binary} for ${goos}/${goarch}"
+      (cd ${bin_dir} && env "${envs[@]}" go build -ldflags "${goldflags:-}" -gcflags "${gcflags:-}" ${goflags} $YURT_ROOT/${binary})
     done
 }
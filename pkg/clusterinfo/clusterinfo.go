@@ -0,0 +1,250 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package clusterinfo defines the signed cluster-info bundle that is published
+// for the bootstrap of edge nodes. the bundle tells where kube-apiserver and
+// yurt-tunnel are and which CAs they are signed by, it's signed by ed25519 keys,
+// so edge nodes that pin the hash of a signing key can fetch it over untrusted
+// networks without copying kubeconfig to the devices.
+package clusterinfo
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// Namespace is the namespace of the published bundle, it's readable by anonymous users
+	Namespace = "kube-public"
+	// Name is the name of ConfigMap of the published bundle
+	Name = "yurt-cluster-info"
+	// BundleKey is the key of ConfigMap for the bundle in json
+	BundleKey = "bundle"
+	// SignaturesKey is the key of ConfigMap for the signatures of bundle in json
+	SignaturesKey = "signatures"
+
+	// SourceNamespace is the namespace of the source of bundle and the signing keys
+	SourceNamespace = "kube-system"
+	// SourceName is the name of ConfigMap that the bundle is built from
+	SourceName = "yurt-cluster-info-source"
+	// SigningKeysName is the name of Secret of the signing keys, the keys are stored
+	// by the hex of their ids, and all of them sign the bundle.
+	SigningKeysName = "yurt-cluster-info-signing-keys"
+
+	// SourceAPIServerEndpoints is the key of source for the comma separated endpoints of kube-apiserver
+	SourceAPIServerEndpoints = "apiServerEndpoints"
+	// SourceTunnelEndpoints is the key of source for the comma separated endpoints of yurt-tunnel-server
+	SourceTunnelEndpoints = "tunnelEndpoints"
+	// SourceCACertificates is the key of source for the CA certificates in pem, like both the
+	// old and new CAs during the rotation of CA. the CA of controller is used if it's not set.
+	SourceCACertificates = "caCertificates"
+
+	// hashPrefix is the prefix of the hashes of CA public keys and signing keys
+	hashPrefix = "sha256:"
+)
+
+// Bundle is the cluster info that edge nodes need for bootstrap
+type Bundle struct {
+	// APIServerEndpoints are the endpoints of kube-apiserver, like https://1.2.3.4:6443
+	APIServerEndpoints []string `json:"apiServerEndpoints"`
+	// CACertificates are the CA certificates of kube-apiserver in pem
+	CACertificates string `json:"caCertificates"`
+	// CAHashes are the sha256 hashes of the public keys of CA certificates, the same as
+	// --discovery-token-ca-cert-hash of kubeadm join
+	CAHashes []string `json:"caHashes"`
+	// TunnelEndpoints are the endpoints of yurt-tunnel-server
+	TunnelEndpoints []string `json:"tunnelEndpoints,omitempty"`
+	// SigningKeys are the ids of keys that sign the bundle, edge nodes learn the
+	// new key from it while the signing key is rotated.
+	SigningKeys []string `json:"signingKeys"`
+	// IssuedAt is the time that the bundle is signed
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
+// Signature is a signature of bundle
+type Signature struct {
+	// KeyID is the sha256 hash of public key, like sha256:<hex>
+	KeyID string `json:"keyID"`
+	// PublicKey is the ed25519 public key, it's verified against KeyID
+	PublicKey []byte `json:"publicKey"`
+	// Signature is the ed25519 signature of the bundle in json
+	Signature []byte `json:"signature"`
+}
+
+// KeyID returns the id of signing key with public key
+func KeyID(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(publicKey)
+	return hashPrefix + hex.EncodeToString(hash[:])
+}
+
+// GenerateSigningKey generates an ed25519 signing key, and returns the name that the
+// key is stored by in the Secret of signing keys.
+func GenerateSigningKey() (string, ed25519.PrivateKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimPrefix(KeyID(publicKey), hashPrefix), privateKey, nil
+}
+
+// SigningKeys returns the signing keys in secret by their ids, the keys that don't match
+// their names in secret are refused.
+func SigningKeys(secret *v1.Secret) (map[string]ed25519.PrivateKey, error) {
+	keys := make(map[string]ed25519.PrivateKey)
+	for name, b := range secret.Data {
+		if len(b) != ed25519.PrivateKeySize {
+			return nil, fmt.Errorf("signing key %s is %d bytes, it must be %d bytes", name, len(b), ed25519.PrivateKeySize)
+		}
+		key := ed25519.PrivateKey(b)
+		id := KeyID(key.Public().(ed25519.PublicKey))
+		if id != hashPrefix+name {
+			return nil, fmt.Errorf("signing key %s doesn't match its id %s", name, id)
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+// CAHashes returns the sha256 hashes of the public keys of certificates in caData
+func CAHashes(caData []byte) ([]string, error) {
+	var hashes []string
+	for rest := caData; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ca certificate, %v", err)
+		}
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		hashes = append(hashes, hashPrefix+hex.EncodeToString(hash[:]))
+	}
+
+	if len(hashes) == 0 {
+		return nil, errors.New("no certificate is found in ca data")
+	}
+	return hashes, nil
+}
+
+// Sign signs bundle by all the keys, and returns the data of the ConfigMap that the
+// bundle is published by. the ids of keys are set in the bundle before it's signed.
+func Sign(bundle *Bundle, keys map[string]ed25519.PrivateKey) (map[string]string, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing key")
+	}
+
+	bundle.SigningKeys = make([]string, 0, len(keys))
+	for id := range keys {
+		bundle.SigningKeys = append(bundle.SigningKeys, id)
+	}
+	sort.Strings(bundle.SigningKeys)
+
+	b, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	signatures := make([]Signature, 0, len(keys))
+	for _, id := range bundle.SigningKeys {
+		key := keys[id]
+		signatures = append(signatures, Signature{
+			KeyID:     id,
+			PublicKey: key.Public().(ed25519.PublicKey),
+			Signature: ed25519.Sign(key, b),
+		})
+	}
+	sigs, err := json.Marshal(signatures)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		BundleKey:     string(b),
+		SignaturesKey: string(sigs),
+	}, nil
+}
+
+// Verify verifies the bundle in the data of ConfigMap is signed by one of the pinned keys,
+// and returns the bundle. the bundle that is not signed by pinned keys can't be trusted,
+// because it can be fetched over untrusted networks.
+func Verify(data map[string]string, pins []string) (*Bundle, error) {
+	b, ok := data[BundleKey]
+	if !ok {
+		return nil, errors.New("no bundle is found")
+	}
+
+	var signatures []Signature
+	if err := json.Unmarshal([]byte(data[SignaturesKey]), &signatures); err != nil {
+		return nil, fmt.Errorf("failed to parse signatures, %v", err)
+	}
+
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pinned[strings.ToLower(pin)] = true
+	}
+
+	for _, sig := range signatures {
+		if !pinned[sig.KeyID] || len(sig.PublicKey) != ed25519.PublicKeySize ||
+			KeyID(sig.PublicKey) != sig.KeyID || !ed25519.Verify(sig.PublicKey, []byte(b), sig.Signature) {
+			continue
+		}
+
+		var bundle Bundle
+		if err := json.Unmarshal([]byte(b), &bundle); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle, %v", err)
+		}
+		return &bundle, nil
+	}
+	return nil, fmt.Errorf("bundle is not signed by the pinned keys %v", pins)
+}
+
+// Equal checks the bundles are the same except the time they are signed
+func Equal(a, b *Bundle) bool {
+	x, y := *a, *b
+	x.IssuedAt, y.IssuedAt = time.Time{}, time.Time{}
+	bx, _ := json.Marshal(&x)
+	by, _ := json.Marshal(&y)
+	return string(bx) == string(by)
+}
+
+// SplitEndpoints splits the comma separated endpoints
+func SplitEndpoints(value string) []string {
+	var endpoints []string
+	for _, endpoint := range strings.Split(value, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
@@ -0,0 +1,222 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinfo
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func newTestCA(t *testing.T) []byte {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate, %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newTestKeys(t *testing.T, n int) map[string]ed25519.PrivateKey {
+	keys := make(map[string]ed25519.PrivateKey)
+	for i := 0; i < n; i++ {
+		_, key, err := GenerateSigningKey()
+		if err != nil {
+			t.Fatalf("failed to generate signing key, %v", err)
+		}
+		keys[KeyID(key.Public().(ed25519.PublicKey))] = key
+	}
+	return keys
+}
+
+func keyIDs(keys map[string]ed25519.PrivateKey) []string {
+	var ids []string
+	for id := range keys {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestSignAndVerify(t *testing.T) {
+	keys := newTestKeys(t, 2)
+	ids := keyIDs(keys)
+	other := keyIDs(newTestKeys(t, 1))
+
+	testcases := map[string]struct {
+		pins      []string
+		tamper    func(data map[string]string)
+		expectErr bool
+	}{
+		"pinned by old key": {
+			pins: ids[:1],
+		},
+		"pinned by new key": {
+			pins: ids[1:],
+		},
+		"pinned in upper case": {
+			pins: []string{strings.ToUpper(ids[0])},
+		},
+		"not pinned": {
+			pins:      other,
+			expectErr: true,
+		},
+		"bundle is tampered": {
+			pins: ids,
+			tamper: func(data map[string]string) {
+				data[BundleKey] = strings.Replace(data[BundleKey], "1.2.3.4", "6.6.6.6", 1)
+			},
+			expectErr: true,
+		},
+		"signature is replaced by unpinned key": {
+			pins: ids,
+			tamper: func(data map[string]string) {
+				forged, _ := Sign(&Bundle{APIServerEndpoints: []string{"https://6.6.6.6:6443"}}, newTestKeys(t, 1))
+				var sigs []Signature
+				json.Unmarshal([]byte(forged[SignaturesKey]), &sigs)
+				// claims to be a pinned key with another public key
+				sigs[0].KeyID = ids[0]
+				b, _ := json.Marshal(sigs)
+				data[BundleKey], data[SignaturesKey] = forged[BundleKey], string(b)
+			},
+			expectErr: true,
+		},
+		"no signatures": {
+			pins: ids,
+			tamper: func(data map[string]string) {
+				delete(data, SignaturesKey)
+			},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			bundle := &Bundle{APIServerEndpoints: []string{"https://1.2.3.4:6443"}, CAHashes: []string{"sha256:00"}}
+			data, err := Sign(bundle, keys)
+			if err != nil {
+				t.Fatalf("failed to sign bundle, %v", err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(data)
+			}
+
+			verified, err := Verify(data, tt.pins)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got bundle %v", verified)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("failed to verify bundle, %v", err)
+			}
+
+			if !reflect.DeepEqual(verified, bundle) {
+				t.Errorf("expect bundle %v, but got %v", bundle, verified)
+			}
+			if len(verified.SigningKeys) != 2 {
+				t.Errorf("expect 2 signing keys in bundle, but got %v", verified.SigningKeys)
+			}
+		})
+	}
+}
+
+func TestSigningKeys(t *testing.T) {
+	name, key, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("failed to generate signing key, %v", err)
+	}
+	_, otherKey, _ := GenerateSigningKey()
+
+	testcases := map[string]struct {
+		data      map[string][]byte
+		expectErr bool
+	}{
+		"valid key": {
+			data: map[string][]byte{name: key},
+		},
+		"key doesn't match its name": {
+			data:      map[string][]byte{name: otherKey},
+			expectErr: true,
+		},
+		"invalid key": {
+			data:      map[string][]byte{name: []byte("key")},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			keys, err := SigningKeys(&v1.Secret{Data: tt.data})
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("expect error %v, but got %v", tt.expectErr, err)
+			}
+			if !tt.expectErr && !reflect.DeepEqual(keys[KeyID(key.Public().(ed25519.PublicKey))], key) {
+				t.Errorf("expect key is found by its id, but got %v", keys)
+			}
+		})
+	}
+}
+
+func TestCAHashes(t *testing.T) {
+	ca1, ca2 := newTestCA(t), newTestCA(t)
+	hashes, err := CAHashes(append(ca1, ca2...))
+	if err != nil {
+		t.Fatalf("failed to get ca hashes, %v", err)
+	}
+	if len(hashes) != 2 || hashes[0] == hashes[1] || !strings.HasPrefix(hashes[0], "sha256:") {
+		t.Errorf("expect 2 different hashes, but got %v", hashes)
+	}
+
+	if _, err := CAHashes([]byte("not a certificate")); err == nil {
+		t.Errorf("expect error for invalid ca data, but got nil")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a := &Bundle{APIServerEndpoints: []string{"https://1.2.3.4:6443"}, IssuedAt: time.Now()}
+	b := &Bundle{APIServerEndpoints: []string{"https://1.2.3.4:6443"}, IssuedAt: time.Now().Add(time.Hour)}
+	if !Equal(a, b) {
+		t.Errorf("expect bundles issued at different time are equal")
+	}
+
+	b.TunnelEndpoints = []string{"1.2.3.4:10262"}
+	if Equal(a, b) {
+		t.Errorf("expect bundles with different tunnel endpoints are not equal")
+	}
+}
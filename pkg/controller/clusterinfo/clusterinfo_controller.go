@@ -0,0 +1,265 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinfo
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/clusterinfo"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// syncKey is the only key of queue, because there is only one bundle in the cluster
+const syncKey = "cluster-info"
+
+// Controller publishes the cluster-info bundle for the bootstrap of edge nodes. the
+// bundle is built from the source configmap and the CA of kube-apiserver, it's signed
+// by all the keys in the secret of signing keys and published to the configmap in
+// kube-public, which can be read by anonymous users.
+//
+// the bundle is signed again only when its contents or the signing keys are changed,
+// so a new signing key can be added before the old one is retired, and edge nodes that
+// pin either of them can verify the bundle during the rotation.
+type Controller struct {
+	kubeClient      clientset.Interface
+	configMapLister corelisters.ConfigMapLister
+	configMapSynced cache.InformerSynced
+	secretLister    corelisters.SecretLister
+	secretSynced    cache.InformerSynced
+	publishedLister corelisters.ConfigMapLister
+	publishedSynced cache.InformerSynced
+	// caData is the CA of kube-apiserver that the controller connects to, it's
+	// published if no CA certificates are set in the source configmap.
+	caData []byte
+	queue  workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for the cluster-info bundle, configMapInformer and
+// secretInformer are the informers of kube-system, publishedInformer is the informer of
+// configmaps in kube-public.
+func NewController(configMapInformer coreinformers.ConfigMapInformer,
+	secretInformer coreinformers.SecretInformer,
+	publishedInformer coreinformers.ConfigMapInformer,
+	kubeClient clientset.Interface,
+	caData []byte) *Controller {
+	c := &Controller{
+		kubeClient:      kubeClient,
+		configMapLister: configMapInformer.Lister(),
+		configMapSynced: configMapInformer.Informer().HasSynced,
+		secretLister:    secretInformer.Lister(),
+		secretSynced:    secretInformer.Informer().HasSynced,
+		publishedLister: publishedInformer.Lister(),
+		publishedSynced: publishedInformer.Informer().HasSynced,
+		caData:          caData,
+		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "clusterinfo"),
+	}
+
+	for _, informer := range []cache.SharedIndexInformer{configMapInformer.Informer(), secretInformer.Informer(), publishedInformer.Informer()} {
+		informer.AddEventHandler(cache.FilteringResourceEventHandler{
+			FilterFunc: isClusterInfoObject,
+			Handler: cache.ResourceEventHandlerFuncs{
+				AddFunc: c.enqueue,
+				UpdateFunc: func(_, newObj interface{}) {
+					c.enqueue(newObj)
+				},
+				DeleteFunc: c.enqueue,
+			},
+		})
+	}
+
+	return c
+}
+
+// Run starts a worker to publish the cluster-info bundle
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting cluster info controller")
+	defer klog.Infof("Shutting down cluster info controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.configMapSynced, c.secretSynced, c.publishedSynced) {
+		return
+	}
+
+	go wait.Until(c.worker, time.Second, stopCh)
+
+	<-stopCh
+}
+
+// isClusterInfoObject checks obj is the source, the signing keys or the published bundle
+func isClusterInfoObject(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+
+	switch accessor.GetNamespace() + "/" + accessor.GetName() {
+	case clusterinfo.SourceNamespace + "/" + clusterinfo.SourceName,
+		clusterinfo.SourceNamespace + "/" + clusterinfo.SigningKeysName,
+		clusterinfo.Namespace + "/" + clusterinfo.Name:
+		return true
+	}
+	return false
+}
+
+func (c *Controller) enqueue(_ interface{}) {
+	c.queue.Add(syncKey)
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync cluster info, %v", err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// sync signs and publishes the bundle if it's changed, nothing is published until both
+// the source configmap and the signing keys are created(by yurtctl cluster-info init).
+func (c *Controller) sync() error {
+	source, err := c.configMapLister.ConfigMaps(clusterinfo.SourceNamespace).Get(clusterinfo.SourceName)
+	if apierrors.IsNotFound(err) {
+		klog.V(4).Infof("configmap %s/%s is not found, cluster info is not published", clusterinfo.SourceNamespace, clusterinfo.SourceName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	secret, err := c.secretLister.Secrets(clusterinfo.SourceNamespace).Get(clusterinfo.SigningKeysName)
+	if apierrors.IsNotFound(err) {
+		klog.Warningf("secret %s/%s is not found, cluster info is not published", clusterinfo.SourceNamespace, clusterinfo.SigningKeysName)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	keys, err := clusterinfo.SigningKeys(secret)
+	if err != nil {
+		return err
+	}
+
+	bundle, err := c.newBundle(source, keys)
+	if err != nil {
+		return err
+	}
+
+	published, err := c.publishedLister.ConfigMaps(clusterinfo.Namespace).Get(clusterinfo.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if published != nil && samePublished(published, bundle) {
+		return nil
+	}
+
+	bundle.IssuedAt = time.Now().UTC().Truncate(time.Second)
+	data, err := clusterinfo.Sign(bundle, keys)
+	if err != nil {
+		return err
+	}
+
+	if published == nil {
+		klog.Infof("publish cluster info %s/%s signed by %v", clusterinfo.Namespace, clusterinfo.Name, bundle.SigningKeys)
+		_, err = c.kubeClient.CoreV1().ConfigMaps(clusterinfo.Namespace).Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterinfo.Name, Namespace: clusterinfo.Namespace},
+			Data:       data,
+		})
+		return err
+	}
+
+	klog.Infof("update cluster info %s/%s signed by %v", clusterinfo.Namespace, clusterinfo.Name, bundle.SigningKeys)
+	published = published.DeepCopy()
+	published.Data = data
+	_, err = c.kubeClient.CoreV1().ConfigMaps(clusterinfo.Namespace).Update(published)
+	return err
+}
+
+// newBundle returns the bundle from the source configmap, the ids of signing keys are
+// set in the bundle as they are set by clusterinfo.Sign, so it can be compared with
+// the published one.
+func (c *Controller) newBundle(source *v1.ConfigMap, keys map[string]ed25519.PrivateKey) (*clusterinfo.Bundle, error) {
+	bundle := &clusterinfo.Bundle{
+		APIServerEndpoints: clusterinfo.SplitEndpoints(source.Data[clusterinfo.SourceAPIServerEndpoints]),
+		CACertificates:     source.Data[clusterinfo.SourceCACertificates],
+		TunnelEndpoints:    clusterinfo.SplitEndpoints(source.Data[clusterinfo.SourceTunnelEndpoints]),
+	}
+	if len(bundle.APIServerEndpoints) == 0 {
+		return nil, fmt.Errorf("no apiserver endpoints are set in configmap %s/%s", source.Namespace, source.Name)
+	}
+	if len(bundle.CACertificates) == 0 {
+		bundle.CACertificates = string(c.caData)
+	}
+
+	hashes, err := clusterinfo.CAHashes([]byte(bundle.CACertificates))
+	if err != nil {
+		return nil, err
+	}
+	bundle.CAHashes = hashes
+
+	for id := range keys {
+		bundle.SigningKeys = append(bundle.SigningKeys, id)
+	}
+	sort.Strings(bundle.SigningKeys)
+	return bundle, nil
+}
+
+// samePublished checks the published bundle is the same as bundle, the bundle is
+// published again if the published one can't be parsed or verified.
+func samePublished(published *v1.ConfigMap, bundle *clusterinfo.Bundle) bool {
+	if _, err := clusterinfo.Verify(published.Data, bundle.SigningKeys); err != nil {
+		return false
+	}
+
+	var old clusterinfo.Bundle
+	if err := json.Unmarshal([]byte(published.Data[clusterinfo.BundleKey]), &old); err != nil {
+		return false
+	}
+	return clusterinfo.Equal(&old, bundle)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package clusterinfo
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/clusterinfo"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestCA(t *testing.T) []byte {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate, %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func newTestSecret(t *testing.T, n int) (*v1.Secret, []string) {
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterinfo.SigningKeysName, Namespace: clusterinfo.SourceNamespace},
+		Data:       map[string][]byte{},
+	}
+	var ids []string
+	for i := 0; i < n; i++ {
+		name, key, err := clusterinfo.GenerateSigningKey()
+		if err != nil {
+			t.Fatalf("failed to generate signing key, %v", err)
+		}
+		secret.Data[name] = key
+		ids = append(ids, "sha256:"+name)
+	}
+	return secret, ids
+}
+
+func newTestSource(caData []byte) *v1.ConfigMap {
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterinfo.SourceName, Namespace: clusterinfo.SourceNamespace},
+		Data: map[string]string{
+			clusterinfo.SourceAPIServerEndpoints: "https://1.2.3.4:6443, https://5.6.7.8:6443",
+			clusterinfo.SourceTunnelEndpoints:    "1.2.3.4:10262",
+		},
+	}
+	if caData != nil {
+		cm.Data[clusterinfo.SourceCACertificates] = string(caData)
+	}
+	return cm
+}
+
+func newTestController(caData []byte, objs ...interface{}) (*Controller, *fake.Clientset) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, obj := range objs {
+		indexer.Add(obj)
+	}
+	var published []runtime.Object
+	for _, obj := range objs {
+		if cm, ok := obj.(*v1.ConfigMap); ok && cm.Namespace == clusterinfo.Namespace {
+			published = append(published, cm)
+		}
+	}
+	kubeClient := fake.NewSimpleClientset(published...)
+	return &Controller{
+		kubeClient:      kubeClient,
+		configMapLister: corelisters.NewConfigMapLister(indexer),
+		secretLister:    corelisters.NewSecretLister(indexer),
+		publishedLister: corelisters.NewConfigMapLister(indexer),
+		caData:          caData,
+	}, kubeClient
+}
+
+func getPublished(t *testing.T, kubeClient *fake.Clientset) *v1.ConfigMap {
+	cm, err := kubeClient.CoreV1().ConfigMaps(clusterinfo.Namespace).Get(clusterinfo.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get published cluster info, %v", err)
+	}
+	return cm
+}
+
+func TestSync(t *testing.T) {
+	controllerCA, sourceCA := newTestCA(t), newTestCA(t)
+	secret, ids := newTestSecret(t, 1)
+
+	testcases := map[string]struct {
+		sourceCA []byte
+		expectCA []byte
+	}{
+		"ca of controller is published": {
+			expectCA: controllerCA,
+		},
+		"ca in source is published": {
+			sourceCA: sourceCA,
+			expectCA: sourceCA,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c, kubeClient := newTestController(controllerCA, newTestSource(tt.sourceCA), secret)
+			if err := c.sync(); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			bundle, err := clusterinfo.Verify(getPublished(t, kubeClient).Data, ids)
+			if err != nil {
+				t.Fatalf("failed to verify published cluster info, %v", err)
+			}
+			if bundle.CACertificates != string(tt.expectCA) {
+				t.Errorf("expect ca %s, but got %s", string(tt.expectCA), bundle.CACertificates)
+			}
+			if expect := "https://1.2.3.4:6443,https://5.6.7.8:6443"; strings.Join(bundle.APIServerEndpoints, ",") != expect {
+				t.Errorf("expect apiserver endpoints %s, but got %v", expect, bundle.APIServerEndpoints)
+			}
+			if len(bundle.CAHashes) != 1 || len(bundle.TunnelEndpoints) != 1 {
+				t.Errorf("expect 1 ca hash and 1 tunnel endpoint, but got %v", bundle)
+			}
+		})
+	}
+}
+
+func TestSyncNotConfigured(t *testing.T) {
+	secret, _ := newTestSecret(t, 1)
+	testcases := map[string][]interface{}{
+		"no source":       {secret},
+		"no signing keys": {newTestSource(nil)},
+	}
+
+	for k, objs := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c, kubeClient := newTestController(newTestCA(t), objs...)
+			if err := c.sync(); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+			if cms, _ := kubeClient.CoreV1().ConfigMaps(clusterinfo.Namespace).List(metav1.ListOptions{}); len(cms.Items) != 0 {
+				t.Errorf("expect nothing is published, but got %v", cms.Items)
+			}
+		})
+	}
+}
+
+func TestSyncRotation(t *testing.T) {
+	caData := newTestCA(t)
+	secret, ids := newTestSecret(t, 1)
+	c, kubeClient := newTestController(caData, newTestSource(nil), secret)
+	if err := c.sync(); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+	published := getPublished(t, kubeClient)
+
+	// nothing is changed, the published bundle is kept
+	c, kubeClient = newTestController(caData, newTestSource(nil), secret, published)
+	if err := c.sync(); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+	if got := getPublished(t, kubeClient); got.Data[clusterinfo.SignaturesKey] != published.Data[clusterinfo.SignaturesKey] {
+		t.Errorf("expect bundle is not signed again")
+	}
+
+	// a new key is added, the bundle is signed by both keys
+	rotated, newIDs := newTestSecret(t, 1)
+	for name, key := range secret.Data {
+		rotated.Data[name] = key
+	}
+	c, kubeClient = newTestController(caData, newTestSource(nil), rotated, published)
+	if err := c.sync(); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+	published = getPublished(t, kubeClient)
+	for _, pins := range [][]string{ids, newIDs} {
+		bundle, err := clusterinfo.Verify(published.Data, pins)
+		if err != nil {
+			t.Fatalf("expect bundle is verified by %v, but got %v", pins, err)
+		}
+		if len(bundle.SigningKeys) != 2 {
+			t.Errorf("expect 2 signing keys in bundle, but got %v", bundle.SigningKeys)
+		}
+	}
+
+	// the old key is retired
+	for name := range secret.Data {
+		delete(rotated.Data, name)
+	}
+	c, kubeClient = newTestController(caData, newTestSource(nil), rotated, published)
+	if err := c.sync(); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+	published = getPublished(t, kubeClient)
+	if _, err := clusterinfo.Verify(published.Data, ids); err == nil {
+		t.Errorf("expect bundle is not signed by retired key")
+	}
+	if _, err := clusterinfo.Verify(published.Data, newIDs); err != nil {
+		t.Errorf("expect bundle is verified by new key, but got %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgepriority
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	schedulinginformers "k8s.io/client-go/informers/scheduling/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	schedulinglisters "k8s.io/client-go/listers/scheduling/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+)
+
+const (
+	// AnnotationEdgePriority is the annotation of pod for the priority that kubelet ranks the pod by
+	// when it evicts pods under resource pressure while the node is offline, yurthub sets it as the
+	// priority of pod when it serves the pod from cache. it's also the annotation of PriorityClass
+	// for the edge priority of its pods, so the priority of workloads can be changed on the edge
+	// without recreating the pods, whose priority is immutable.
+	AnnotationEdgePriority = "openyurt.io/edge-priority"
+	// AnnotationEdgeCritical is the annotation of pod that the pod is critical on the edge(like the
+	// agents needed for reconnection), it's never evicted by kubelet while the node is offline.
+	AnnotationEdgeCritical = "openyurt.io/edge-critical"
+)
+
+// Controller annotates the pods on edge nodes with their edge priority, which is resolved from
+// the PriorityClasses in the cache of informer. the edge priority of a pod is the system critical
+// priority if the pod is edge critical, or the edge priority of its PriorityClass(or the global
+// default PriorityClass if the pod has no class). pods without edge priority are not annotated,
+// and kubelet ranks them by their own priority.
+type Controller struct {
+	kubeClient          clientset.Interface
+	podLister           corelisters.PodLister
+	podSynced           cache.InformerSynced
+	nodeLister          corelisters.NodeLister
+	nodeSynced          cache.InformerSynced
+	priorityClassLister schedulinglisters.PriorityClassLister
+	priorityClassSynced cache.InformerSynced
+	queue               workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for the edge priority of pods
+func NewController(podInformer coreinformers.PodInformer,
+	nodeInformer coreinformers.NodeInformer,
+	priorityClassInformer schedulinginformers.PriorityClassInformer,
+	kubeClient clientset.Interface) *Controller {
+	c := &Controller{
+		kubeClient:          kubeClient,
+		podLister:           podInformer.Lister(),
+		podSynced:           podInformer.Informer().HasSynced,
+		nodeLister:          nodeInformer.Lister(),
+		nodeSynced:          nodeInformer.Informer().HasSynced,
+		priorityClassLister: priorityClassInformer.Lister(),
+		priorityClassSynced: priorityClassInformer.Informer().HasSynced,
+		queue:               workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "edgepriority"),
+	}
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueue(newObj)
+		},
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, newNode := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if isEdgeNode(oldNode) != isEdgeNode(newNode) {
+				c.enqueuePods(func(pod *v1.Pod) bool { return pod.Spec.NodeName == newNode.Name })
+			}
+		},
+	})
+
+	priorityClassInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.updatePriorityClass,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.updatePriorityClass(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			c.updatePriorityClass(obj)
+		},
+	})
+
+	return c
+}
+
+// Run starts workers to annotate the edge priority of pods
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting edge priority controller")
+	defer klog.Infof("Shutting down edge priority controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.podSynced, c.nodeSynced, c.priorityClassSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueue enqueues the scheduled pod
+func (c *Controller) enqueue(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || len(pod.Spec.NodeName) == 0 {
+		return
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for pod %s/%s: %v", pod.Namespace, pod.Name, err))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// updatePriorityClass enqueues the pods of class, and all the pods without class if class is
+// the global default.
+func (c *Controller) updatePriorityClass(obj interface{}) {
+	class, ok := obj.(*schedulingv1.PriorityClass)
+	if !ok {
+		return
+	}
+	c.enqueuePods(func(pod *v1.Pod) bool {
+		return pod.Spec.PriorityClassName == class.Name || (len(pod.Spec.PriorityClassName) == 0 && class.GlobalDefault)
+	})
+}
+
+func (c *Controller) enqueuePods(filter func(pod *v1.Pod) bool) {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list pods, %v", err))
+		return
+	}
+	for _, pod := range pods {
+		if filter(pod) {
+			c.enqueue(pod)
+		}
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to annotate edge priority of pod %s, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	pod, err := c.podLister.Pods(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if len(pod.Spec.NodeName) == 0 {
+		return nil
+	}
+
+	node, err := c.nodeLister.Get(pod.Spec.NodeName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	desired := ""
+	if isEdgeNode(node) {
+		if priority, ok := c.edgePriorityOf(pod); ok {
+			desired = strconv.Itoa(int(priority))
+		}
+	}
+	current, annotated := pod.Annotations[AnnotationEdgePriority]
+	if (annotated && current == desired) || (!annotated && len(desired) == 0) {
+		return nil
+	}
+
+	// the annotation is removed by null in merge patch
+	var value interface{}
+	if len(desired) != 0 {
+		value = desired
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{AnnotationEdgePriority: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := c.kubeClient.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.MergePatchType, patch); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	klog.V(2).Infof("edge priority of pod %s is changed from %q to %q", key, current, desired)
+	return nil
+}
+
+// edgePriorityOf returns the edge priority of pod, false if the pod has no edge priority
+func (c *Controller) edgePriorityOf(pod *v1.Pod) (int32, bool) {
+	if pod.Annotations[AnnotationEdgeCritical] == "true" {
+		return scheduling.SystemCriticalPriority, true
+	}
+
+	var class *schedulingv1.PriorityClass
+	if len(pod.Spec.PriorityClassName) != 0 {
+		pc, err := c.priorityClassLister.Get(pod.Spec.PriorityClassName)
+		if err != nil {
+			return 0, false
+		}
+		class = pc
+	} else {
+		classes, err := c.priorityClassLister.List(labels.Everything())
+		if err != nil {
+			return 0, false
+		}
+		for _, pc := range classes {
+			if pc.GlobalDefault {
+				class = pc
+				break
+			}
+		}
+	}
+	if class == nil {
+		return 0, false
+	}
+
+	value, ok := class.Annotations[AnnotationEdgePriority]
+	if !ok {
+		return 0, false
+	}
+	priority, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		klog.Warningf("edge priority(%s) of priority class %s is invalid, %v", value, class.Name, err)
+		return 0, false
+	}
+	// only edge critical pods can be ranked as system critical pods, which are never evicted
+	if priority > int64(scheduling.HighestUserDefinablePriority) {
+		priority = int64(scheduling.HighestUserDefinablePriority)
+	}
+	return int32(priority), true
+}
+
+func isEdgeNode(node *v1.Node) bool {
+	return node.Labels[constants.LabelEdgeWorker] == "true"
+}
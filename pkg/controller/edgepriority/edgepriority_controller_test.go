@@ -0,0 +1,183 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package edgepriority
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	schedulinglisters "k8s.io/client-go/listers/scheduling/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestNode(name string, edge bool) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{constants.LabelEdgeWorker: "false"}}}
+	if edge {
+		node.Labels[constants.LabelEdgeWorker] = "true"
+	}
+	return node
+}
+
+func newTestPod(name, nodeName, className string, annotations map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: annotations},
+		Spec:       v1.PodSpec{NodeName: nodeName, PriorityClassName: className},
+	}
+}
+
+func newTestPriorityClass(name string, globalDefault bool, edgePriority string) *schedulingv1.PriorityClass {
+	class := &schedulingv1.PriorityClass{
+		ObjectMeta:    metav1.ObjectMeta{Name: name},
+		GlobalDefault: globalDefault,
+	}
+	if len(edgePriority) != 0 {
+		class.Annotations = map[string]string{AnnotationEdgePriority: edgePriority}
+	}
+	return class
+}
+
+func newTestController(nodes []*v1.Node, pods []*v1.Pod, classes []*schedulingv1.PriorityClass) (*Controller, *fake.Clientset) {
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+	}
+	classIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, class := range classes {
+		classIndexer.Add(class)
+	}
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var objs []runtime.Object
+	for _, pod := range pods {
+		podIndexer.Add(pod)
+		objs = append(objs, pod.DeepCopy())
+	}
+
+	kubeClient := fake.NewSimpleClientset(objs...)
+	return &Controller{
+		kubeClient:          kubeClient,
+		podLister:           corelisters.NewPodLister(podIndexer),
+		nodeLister:          corelisters.NewNodeLister(nodeIndexer),
+		priorityClassLister: schedulinglisters.NewPriorityClassLister(classIndexer),
+	}, kubeClient
+}
+
+func TestSync(t *testing.T) {
+	classes := []*schedulingv1.PriorityClass{
+		newTestPriorityClass("low", false, "-100"),
+		newTestPriorityClass("high", false, "2000000000"),
+		newTestPriorityClass("plain", false, ""),
+		newTestPriorityClass("default", true, "10"),
+	}
+	nodes := []*v1.Node{newTestNode("edge", true), newTestNode("cloud", false)}
+
+	testcases := map[string]struct {
+		pod     *v1.Pod
+		classes []*schedulingv1.PriorityClass
+		patched bool
+		// expected is the annotation in patch, nil means the annotation is removed
+		expected interface{}
+	}{
+		"edge critical pod": {
+			pod:      newTestPod("agent", "edge", "low", map[string]string{AnnotationEdgeCritical: "true"}),
+			classes:  classes,
+			patched:  true,
+			expected: "2000000000",
+		},
+		"pod of class with edge priority": {
+			pod:      newTestPod("app", "edge", "low", nil),
+			classes:  classes,
+			patched:  true,
+			expected: "-100",
+		},
+		"edge priority is capped by highest user definable priority": {
+			pod:      newTestPod("app", "edge", "high", nil),
+			classes:  classes,
+			patched:  true,
+			expected: "1000000000",
+		},
+		"pod without class uses global default": {
+			pod:      newTestPod("app", "edge", "", nil),
+			classes:  classes,
+			patched:  true,
+			expected: "10",
+		},
+		"pod without class and global default": {
+			pod:     newTestPod("app", "edge", "", nil),
+			classes: classes[:3],
+		},
+		"pod of class without edge priority": {
+			pod:     newTestPod("app", "edge", "plain", nil),
+			classes: classes,
+		},
+		"pod is annotated already": {
+			pod:     newTestPod("app", "edge", "low", map[string]string{AnnotationEdgePriority: "-100"}),
+			classes: classes,
+		},
+		"annotation is removed from pod on cloud node": {
+			pod:     newTestPod("app", "cloud", "low", map[string]string{AnnotationEdgePriority: "-100"}),
+			classes: classes,
+			patched: true,
+		},
+		"pod on cloud node": {
+			pod:     newTestPod("app", "cloud", "low", nil),
+			classes: classes,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c, kubeClient := newTestController(nodes, []*v1.Pod{tt.pod}, tt.classes)
+			if err := c.sync("default/" + tt.pod.Name); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			var patches []clienttesting.PatchAction
+			for _, action := range kubeClient.Actions() {
+				if patch, ok := action.(clienttesting.PatchAction); ok {
+					patches = append(patches, patch)
+				}
+			}
+			if (len(patches) != 0) != tt.patched {
+				t.Fatalf("expect patched %v, but got %d patches", tt.patched, len(patches))
+			}
+			if !tt.patched {
+				return
+			}
+
+			patch := struct {
+				Metadata struct {
+					Annotations map[string]interface{} `json:"annotations"`
+				} `json:"metadata"`
+			}{}
+			if err := json.Unmarshal(patches[0].GetPatch(), &patch); err != nil {
+				t.Fatalf("failed to decode patch, %v", err)
+			}
+			if got := patch.Metadata.Annotations[AnnotationEdgePriority]; got != tt.expected {
+				t.Errorf("expect edge priority %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evictionpolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// Controller projects NodePoolEvictionPolicy to annotations of the nodes in the node pool,
+// node lifecycle controller evicts pods from the unhealthy nodes by the annotations. the
+// work queue is keyed by node pool, so annotations are removed from the nodes when the
+// policy is deleted or the nodes are moved to another node pool.
+type Controller struct {
+	kubeClient    clientset.Interface
+	dynamicClient dynamic.Interface
+	lister        cache.GenericLister
+	synced        cache.InformerSynced
+	nodeLister    corelisters.NodeLister
+	nodeSynced    cache.InformerSynced
+	queue         workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for NodePoolEvictionPolicy
+func NewController(informer informers.GenericInformer,
+	nodeInformer coreinformers.NodeInformer,
+	kubeClient clientset.Interface,
+	dynamicClient dynamic.Interface) *Controller {
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		lister:        informer.Lister(),
+		synced:        informer.Informer().HasSynced,
+		nodeLister:    nodeInformer.Lister(),
+		nodeSynced:    nodeInformer.Informer().HasSynced,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "evictionpolicy"),
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePolicy,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueuePolicy(oldObj)
+			c.enqueuePolicy(newObj)
+		},
+		DeleteFunc: c.enqueuePolicy,
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.queue.Add(obj.(*v1.Node).Labels[constants.LabelNodePool])
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, newNode := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if oldNode.Labels[constants.LabelNodePool] != newNode.Labels[constants.LabelNodePool] ||
+				oldNode.Annotations[scheduler.AnnotationKeyEvictionPolicy] != newNode.Annotations[scheduler.AnnotationKeyEvictionPolicy] ||
+				oldNode.Annotations[scheduler.AnnotationKeyEvictAfterHours] != newNode.Annotations[scheduler.AnnotationKeyEvictAfterHours] {
+				c.queue.Add(newNode.Labels[constants.LabelNodePool])
+			}
+		},
+	})
+
+	return c
+}
+
+// Run starts workers to reconcile NodePoolEvictionPolicy
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting eviction policy controller")
+	defer klog.Infof("Shutting down eviction policy controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.nodeSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueuePolicy enqueues the node pool of NodePoolEvictionPolicy
+func (c *Controller) enqueuePolicy(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+
+	pool, _, _ := unstructured.NestedString(u.Object, "spec", "nodePool")
+	c.queue.Add(pool)
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync eviction policy of node pool %q, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(pool string) error {
+	obj, policy, err := c.policyOf(pool)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := c.nodeLister.List(nodePoolSelector(pool))
+	if err != nil {
+		return err
+	}
+
+	desired := annotationsOf(policy)
+	for _, node := range nodes {
+		if err := c.syncNode(node, desired); err != nil {
+			return err
+		}
+	}
+
+	if policy == nil || policy.Status.Nodes == len(nodes) {
+		return nil
+	}
+	return c.updateStatus(obj, &NodePoolEvictionPolicyStatus{Nodes: len(nodes)})
+}
+
+// policyOf returns the NodePoolEvictionPolicy of node pool, the oldest one is used
+// if more than one policies are set for the same node pool.
+func (c *Controller) policyOf(pool string) (runtime.Object, *NodePoolEvictionPolicy, error) {
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []*NodePoolEvictionPolicy
+	policyObjs := make(map[string]runtime.Object)
+	for _, obj := range objs {
+		policy, err := toNodePoolEvictionPolicy(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		if policy.Spec.NodePool != pool {
+			continue
+		}
+		if err := validate(policy); err != nil {
+			klog.Warningf("eviction policy %s is ignored, %v", policy.Name, err)
+			continue
+		}
+		candidates = append(candidates, policy)
+		policyObjs[policy.Name] = obj
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].CreationTimestamp.Equal(&candidates[j].CreationTimestamp) {
+			return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if len(candidates) > 1 {
+		klog.Warningf("more than one eviction policies are set for node pool %q, %s is used", pool, candidates[0].Name)
+	}
+
+	return policyObjs[candidates[0].Name], candidates[0], nil
+}
+
+// syncNode patches the annotations of eviction policy of node, the annotations that
+// are not desired are removed from the node.
+func (c *Controller) syncNode(node *v1.Node, desired map[string]string) error {
+	annotations := make(map[string]interface{})
+	for _, key := range []string{scheduler.AnnotationKeyEvictionPolicy, scheduler.AnnotationKeyEvictAfterHours} {
+		value, ok := node.Annotations[key]
+		if desiredValue, desiredOk := desired[key]; desiredOk && (!ok || value != desiredValue) {
+			annotations[key] = desiredValue
+		} else if !desiredOk && ok {
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("update eviction policy of node %s to %v", node.Name, desired)
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+func (c *Controller) updateStatus(obj runtime.Object, status *NodePoolEvictionPolicyStatus) error {
+	u := obj.(*unstructured.Unstructured).DeepCopy()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(SchemeGroupVersionResource).UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// annotationsOf returns the annotations of node that policy is projected to
+func annotationsOf(policy *NodePoolEvictionPolicy) map[string]string {
+	annotations := make(map[string]string)
+	if policy == nil {
+		return annotations
+	}
+
+	annotations[scheduler.AnnotationKeyEvictionPolicy] = string(policy.Spec.Policy)
+	if policy.Spec.Policy == scheduler.EvictionPolicyAfterDuration {
+		annotations[scheduler.AnnotationKeyEvictAfterHours] = strconv.Itoa(policy.Spec.EvictAfterHours)
+	}
+	return annotations
+}
+
+// nodePoolSelector selects the nodes of node pool, the nodes that don't
+// belong to any node pool are selected for empty node pool.
+func nodePoolSelector(pool string) labels.Selector {
+	if len(pool) != 0 {
+		return labels.SelectorFromSet(labels.Set{constants.LabelNodePool: pool})
+	}
+
+	req, _ := labels.NewRequirement(constants.LabelNodePool, selection.DoesNotExist, nil)
+	return labels.NewSelector().Add(*req)
+}
+
+func validate(policy *NodePoolEvictionPolicy) error {
+	switch policy.Spec.Policy {
+	case scheduler.EvictionPolicyNever, scheduler.EvictionPolicyNonCriticalOnly:
+		return nil
+	case scheduler.EvictionPolicyAfterDuration:
+		if policy.Spec.EvictAfterHours < 0 {
+			return fmt.Errorf("evictAfterHours %d is negative", policy.Spec.EvictAfterHours)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown policy %q", policy.Spec.Policy)
+	}
+}
+
+func toNodePoolEvictionPolicy(obj runtime.Object) (*NodePoolEvictionPolicy, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	policy := &NodePoolEvictionPolicy{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), policy); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to eviction policy, %v", u.GetName(), err)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evictionpolicy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestPolicy(name, pool string, policy scheduler.EvictionPolicyType, hours int, created time.Time) *unstructured.Unstructured {
+	p := &NodePoolEvictionPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolEvictionPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: NodePoolEvictionPolicySpec{
+			NodePool:        pool,
+			Policy:          policy,
+			EvictAfterHours: hours,
+		},
+	}
+
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(p)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func newTestNode(name, pool string, annotations map[string]string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+	if len(pool) != 0 {
+		node.Labels = map[string]string{constants.LabelNodePool: pool}
+	}
+	return node
+}
+
+func TestSync(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		pool     string
+		policies []*unstructured.Unstructured
+		nodes    []*v1.Node
+		patches  map[string]string
+		status   int64
+	}{
+		"apply policy to nodes in node pool": {
+			pool:     "hangzhou",
+			policies: []*unstructured.Unstructured{newTestPolicy("foo", "hangzhou", scheduler.EvictionPolicyAfterDuration, 6, created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", nil),
+				newTestNode("node2", "beijing", nil),
+			},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/evict-after-hours":"6","openyurt.io/eviction-policy":"AfterDuration"}}}`,
+			},
+			status: 1,
+		},
+		"remove policy from nodes": {
+			pool: "hangzhou",
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", map[string]string{
+					scheduler.AnnotationKeyEvictionPolicy:  "AfterDuration",
+					scheduler.AnnotationKeyEvictAfterHours: "6",
+					"foo":                                  "bar",
+				}),
+			},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/evict-after-hours":null,"openyurt.io/eviction-policy":null}}}`,
+			},
+		},
+		"change policy of nodes": {
+			pool:     "hangzhou",
+			policies: []*unstructured.Unstructured{newTestPolicy("foo", "hangzhou", scheduler.EvictionPolicyNever, 0, created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", map[string]string{
+					scheduler.AnnotationKeyEvictionPolicy:  "AfterDuration",
+					scheduler.AnnotationKeyEvictAfterHours: "6",
+				}),
+			},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/evict-after-hours":null,"openyurt.io/eviction-policy":"Never"}}}`,
+			},
+			status: 1,
+		},
+		"apply policy to nodes without node pool": {
+			policies: []*unstructured.Unstructured{newTestPolicy("foo", "", scheduler.EvictionPolicyNonCriticalOnly, 0, created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "", nil),
+				newTestNode("node2", "beijing", nil),
+			},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/eviction-policy":"NonCriticalOnly"}}}`,
+			},
+			status: 1,
+		},
+		"oldest policy is used": {
+			pool: "hangzhou",
+			policies: []*unstructured.Unstructured{
+				newTestPolicy("foo", "hangzhou", scheduler.EvictionPolicyNonCriticalOnly, 0, created.Add(time.Hour)),
+				newTestPolicy("bar", "hangzhou", scheduler.EvictionPolicyNever, 0, created),
+				newTestPolicy("baz", "hangzhou", "Sometimes", 0, created.Add(-time.Hour)),
+			},
+			nodes: []*v1.Node{newTestNode("node1", "hangzhou", nil)},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/eviction-policy":"Never"}}}`,
+			},
+		},
+		"policy is applied already": {
+			pool:     "hangzhou",
+			policies: []*unstructured.Unstructured{newTestPolicy("foo", "hangzhou", scheduler.EvictionPolicyNever, 0, created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", map[string]string{scheduler.AnnotationKeyEvictionPolicy: "Never"}),
+			},
+			patches: map[string]string{},
+			status:  1,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var dynamicObjs []runtime.Object
+			for _, p := range tt.policies {
+				indexer.Add(p)
+				dynamicObjs = append(dynamicObjs, p.DeepCopy())
+			}
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var nodeObjs []runtime.Object
+			for _, node := range tt.nodes {
+				nodeIndexer.Add(node)
+				nodeObjs = append(nodeObjs, node.DeepCopy())
+			}
+			kubeClient := fake.NewSimpleClientset(nodeObjs...)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjs...)
+			c := &Controller{
+				kubeClient:    kubeClient,
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+			}
+
+			if err := c.sync(tt.pool); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			// annotations are not removed by patch of fake client, so patches are checked
+			patches := make(map[string]string)
+			for _, action := range kubeClient.Actions() {
+				if patch, ok := action.(clienttesting.PatchAction); ok {
+					patches[patch.GetName()] = string(patch.GetPatch())
+				}
+			}
+			if !reflect.DeepEqual(patches, tt.patches) {
+				t.Errorf("expect patches %v, but got %v", tt.patches, patches)
+			}
+
+			if tt.status == 0 {
+				return
+			}
+			u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get(tt.policies[0].GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get eviction policy, %v", err)
+			}
+			if nodes, _, _ := unstructured.NestedInt64(u.Object, "status", "nodes"); nodes != tt.status {
+				t.Errorf("expect status nodes %d, but got %d", tt.status, nodes)
+			}
+		})
+	}
+}
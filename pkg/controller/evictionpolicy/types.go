@@ -0,0 +1,59 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package evictionpolicy
+
+import (
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of NodePoolEvictionPolicy
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "nodepoolevictionpolicies",
+}
+
+// NodePoolEvictionPolicy is how pods are evicted from the unhealthy nodes of a node pool,
+// the policy is projected to annotations of the nodes in the node pool.
+type NodePoolEvictionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodePoolEvictionPolicySpec   `json:"spec"`
+	Status NodePoolEvictionPolicyStatus `json:"status,omitempty"`
+}
+
+// NodePoolEvictionPolicySpec is the spec of NodePoolEvictionPolicy
+type NodePoolEvictionPolicySpec struct {
+	// NodePool is the node pool that the policy is applied to,
+	// empty means the nodes that don't belong to any node pool.
+	NodePool string `json:"nodePool,omitempty"`
+	// Policy is one of Never, AfterDuration and NonCriticalOnly
+	Policy scheduler.EvictionPolicyType `json:"policy"`
+	// EvictAfterHours is the hours that pods are evicted after the node is unhealthy,
+	// it's required by policy AfterDuration.
+	EvictAfterHours int `json:"evictAfterHours,omitempty"`
+}
+
+// NodePoolEvictionPolicyStatus is the status of NodePoolEvictionPolicy
+type NodePoolEvictionPolicyStatus struct {
+	// Nodes is the number of nodes that the policy is applied to
+	Nodes int `json:"nodes"`
+}
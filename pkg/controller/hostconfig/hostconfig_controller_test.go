@@ -0,0 +1,245 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostconfig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestSpec(pool string) NodePoolHostConfigSpec {
+	return NodePoolHostConfigSpec{
+		NodePool: pool,
+		Sysctls: []Sysctl{
+			{Name: "net.ipv4.tcp_rmem", Value: "4096 87380 6291456"},
+			{Name: "net.ipv4.ip_forward", Value: "1"},
+		},
+		KernelModules: []string{"br_netfilter"},
+		NTPServers:    []string{"ntp.example.com"},
+		Routes:        []StaticRoute{{Destination: "10.0.0.0/8", Gateway: "192.168.1.1", Device: "eth1"}},
+	}
+}
+
+func newTestConfig(name string, spec NodePoolHostConfigSpec, created time.Time) *unstructured.Unstructured {
+	c := &NodePoolHostConfig{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolHostConfig",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: spec,
+	}
+
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(c)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func newTestNode(name, pool string, hash string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+		constants.LabelNodePool:   pool,
+		constants.LabelEdgeWorker: "true",
+	}}}
+	if len(hash) != 0 {
+		node.Annotations = map[string]string{AnnotationHostConfigHash: hash}
+	}
+	return node
+}
+
+func newTestJob(node, pool, hash string, condition batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "yurtctl-servant-hostconfig-" + node,
+			Namespace:   "kube-system",
+			Labels:      map[string]string{LabelHostConfigNodePool: pool},
+			Annotations: map[string]string{AnnotationHostConfigHash: hash},
+		},
+	}
+	job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue}}
+	return job
+}
+
+func TestSync(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	params := kubeutil.ServantJobParams{Action: kubeutil.ServantJobActionHostConfig}
+	spec := newTestSpec("hangzhou")
+	hostConfigOf(&NodePoolHostConfig{Spec: spec}).SetParams(&params)
+	hash := configHash(&params)
+	emptyHash := configHash(&kubeutil.ServantJobParams{})
+
+	invalid := newTestSpec("hangzhou")
+	invalid.KernelModules = []string{"br_netfilter; reboot"}
+
+	testcases := map[string]struct {
+		configs     []*unstructured.Unstructured
+		nodes       []*v1.Node
+		jobs        []*batchv1.Job
+		created     []string
+		deleted     []string
+		patches     map[string]string
+		status      int64
+		synced      int64
+		createdEnvs map[string]string
+	}{
+		"apply configuration on edge nodes in node pool": {
+			configs: []*unstructured.Unstructured{newTestConfig("foo", spec, created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", ""),
+				newTestNode("node2", "hangzhou", hash),
+				newTestNode("node3", "beijing", ""),
+			},
+			created: []string{"yurtctl-servant-hostconfig-node1"},
+			patches: map[string]string{},
+			status:  2,
+			synced:  1,
+			createdEnvs: map[string]string{
+				"HOST_SYSCTLS":        "net.ipv4.ip_forward=1 net.ipv4.tcp_rmem=4096,87380,6291456",
+				"HOST_KERNEL_MODULES": "br_netfilter",
+				"HOST_NTP_SERVERS":    "ntp.example.com",
+				"HOST_ROUTES":         "10.0.0.0/8,192.168.1.1,eth1",
+			},
+		},
+		"job is completed": {
+			configs: []*unstructured.Unstructured{newTestConfig("foo", spec, created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", "")},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", hash, batchv1.JobComplete)},
+			deleted: []string{"yurtctl-servant-hostconfig-node1"},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/host-config-hash":"` + hash + `"}}}`,
+			},
+			status: 1,
+			synced: 1,
+		},
+		"invalid configuration is ignored": {
+			configs: []*unstructured.Unstructured{newTestConfig("foo", invalid, created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", ""), newTestNode("node2", "hangzhou", hash)},
+			created: []string{"yurtctl-servant-hostconfig-node2"},
+			patches: map[string]string{},
+		},
+		"configuration is removed from nodes": {
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", hash)},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", emptyHash, batchv1.JobComplete)},
+			deleted: []string{"yurtctl-servant-hostconfig-node1"},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/host-config-hash":null}}}`,
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var dynamicObjs []runtime.Object
+			for _, c := range tt.configs {
+				indexer.Add(c)
+				dynamicObjs = append(dynamicObjs, c.DeepCopy())
+			}
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var objs []runtime.Object
+			for _, node := range tt.nodes {
+				nodeIndexer.Add(node)
+				objs = append(objs, node.DeepCopy())
+			}
+			for _, job := range tt.jobs {
+				jobIndexer.Add(job)
+				objs = append(objs, job.DeepCopy())
+			}
+			kubeClient := fake.NewSimpleClientset(objs...)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjs...)
+			c := &Controller{
+				kubeClient:    kubeClient,
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+				jobLister:     batchlisters.NewJobLister(jobIndexer),
+			}
+
+			if err := c.sync("hangzhou"); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			var created, deleted []string
+			patches := make(map[string]string)
+			for _, action := range kubeClient.Actions() {
+				switch action.GetVerb() {
+				case "create":
+					job := action.(clienttesting.CreateAction).GetObject().(*batchv1.Job)
+					created = append(created, job.Name)
+					if tt.createdEnvs == nil {
+						continue
+					}
+					envs := make(map[string]string)
+					for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+						if env.Name != "NODE_NAME" {
+							envs[env.Name] = env.Value
+						}
+					}
+					if !reflect.DeepEqual(envs, tt.createdEnvs) {
+						t.Errorf("expect envs %v of job, but got %v", tt.createdEnvs, envs)
+					}
+				case "delete":
+					deleted = append(deleted, action.(clienttesting.DeleteAction).GetName())
+				case "patch":
+					patch := action.(clienttesting.PatchAction)
+					patches[patch.GetName()] = string(patch.GetPatch())
+				}
+			}
+			if !reflect.DeepEqual(created, tt.created) {
+				t.Errorf("expect created jobs %v, but got %v", tt.created, created)
+			}
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("expect deleted jobs %v, but got %v", tt.deleted, deleted)
+			}
+			if !reflect.DeepEqual(patches, tt.patches) {
+				t.Errorf("expect patches %v, but got %v", tt.patches, patches)
+			}
+
+			if tt.status == 0 {
+				return
+			}
+			u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get(tt.configs[0].GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get host config, %v", err)
+			}
+			if nodes, _, _ := unstructured.NestedInt64(u.Object, "status", "nodes"); nodes != tt.status {
+				t.Errorf("expect status nodes %d, but got %d", tt.status, nodes)
+			}
+			if synced, _, _ := unstructured.NestedInt64(u.Object, "status", "syncedNodes"); synced != tt.synced {
+				t.Errorf("expect status synced nodes %d, but got %d", tt.synced, synced)
+			}
+		})
+	}
+}
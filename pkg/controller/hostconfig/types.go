@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hostconfig
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of NodePoolHostConfig
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "nodepoolhostconfigs",
+}
+
+const (
+	// AnnotationHostConfigHash is the annotation of node for the hash of the host configuration
+	// that is applied on the node, and the annotation of the servant job for the hash of the
+	// host configuration that the job applies.
+	AnnotationHostConfigHash = "openyurt.io/host-config-hash"
+	// LabelHostConfigNodePool is the label of servant job for the node pool of the node that
+	// the job applies host configuration on.
+	LabelHostConfigNodePool = "openyurt.io/host-config-node-pool"
+)
+
+// NodePoolHostConfig is the configuration of the host OS on the edge nodes of a node pool,
+// the configuration is applied on the nodes by servant jobs.
+type NodePoolHostConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodePoolHostConfigSpec   `json:"spec"`
+	Status NodePoolHostConfigStatus `json:"status,omitempty"`
+}
+
+// NodePoolHostConfigSpec is the spec of NodePoolHostConfig
+type NodePoolHostConfigSpec struct {
+	// NodePool is the node pool that the configuration is applied on,
+	// empty means the edge nodes that don't belong to any node pool.
+	NodePool string `json:"nodePool,omitempty"`
+	// Sysctls are the kernel parameters that are set on boot
+	Sysctls []Sysctl `json:"sysctls,omitempty"`
+	// KernelModules are the kernel modules that are loaded on boot
+	KernelModules []string `json:"kernelModules,omitempty"`
+	// NTPServers are the ntp servers of chrony or systemd-timesyncd
+	NTPServers []string `json:"ntpServers,omitempty"`
+	// Routes are the static routes that are added on boot
+	Routes []StaticRoute `json:"routes,omitempty"`
+}
+
+// Sysctl is a kernel parameter, like net.ipv4.ip_forward=1
+type Sysctl struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// StaticRoute is a static route of the host
+type StaticRoute struct {
+	// Destination is the cidr of destination, like 10.0.0.0/8
+	Destination string `json:"destination"`
+	// Gateway is the ip of next hop, it may be empty if Device is set
+	Gateway string `json:"gateway,omitempty"`
+	// Device is the network interface that the route goes through
+	Device string `json:"device,omitempty"`
+}
+
+// NodePoolHostConfigStatus is the status of NodePoolHostConfig
+type NodePoolHostConfigStatus struct {
+	// Nodes is the number of edge nodes in the node pool
+	Nodes int `json:"nodes"`
+	// SyncedNodes is the number of edge nodes that the configuration is applied on
+	SyncedNodes int `json:"syncedNodes"`
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodecommand
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	controllerutil "github.com/alibaba/openyurt/pkg/controller/util"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	batchinformers "k8s.io/client-go/informers/batch/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// Controller runs NodePoolCommand on the edge nodes of node pools by the servant jobs of
+// yurtctl. a command runs once: the ready edge nodes of the node pool are selected when it's
+// started, then the command is run on at most MaxConcurrency nodes at the same time, and the
+// rest nodes are skipped after it fails on a node unless ContinueOnFailure is set. the results
+// are kept in the status of NodePoolCommand, and every step is recorded as an event of it for
+// audit. the work queue is keyed by the name of NodePoolCommand.
+type Controller struct {
+	kubeClient    clientset.Interface
+	dynamicClient dynamic.Interface
+	lister        cache.GenericLister
+	synced        cache.InformerSynced
+	nodeLister    corelisters.NodeLister
+	nodeSynced    cache.InformerSynced
+	jobLister     batchlisters.JobLister
+	jobSynced     cache.InformerSynced
+	recorder      record.EventRecorder
+	queue         workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for NodePoolCommand
+func NewController(informer informers.GenericInformer,
+	nodeInformer coreinformers.NodeInformer,
+	jobInformer batchinformers.JobInformer,
+	kubeClient clientset.Interface,
+	dynamicClient dynamic.Interface) *Controller {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		lister:        informer.Lister(),
+		synced:        informer.Informer().HasSynced,
+		nodeLister:    nodeInformer.Lister(),
+		nodeSynced:    nodeInformer.Informer().HasSynced,
+		jobLister:     jobInformer.Lister(),
+		jobSynced:     jobInformer.Informer().HasSynced,
+		recorder:      eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "node-command-controller"}),
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "nodecommand"),
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueCommand,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueueCommand(newObj) },
+		DeleteFunc: c.enqueueCommand,
+	})
+
+	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueJob,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueueJob(newObj) },
+		DeleteFunc: c.enqueueJob,
+	})
+
+	return c
+}
+
+// Run starts workers to run NodePoolCommand
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting node command controller")
+	defer klog.Infof("Shutting down node command controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.nodeSynced, c.jobSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueueCommand enqueues the name of NodePoolCommand
+func (c *Controller) enqueueCommand(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(err)
+		return
+	}
+	c.queue.Add(key)
+}
+
+// enqueueJob enqueues the NodePoolCommand of the servant job that runs the command
+func (c *Controller) enqueueJob(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+
+	if name, ok := job.Labels[LabelNodePoolCommand]; ok {
+		c.queue.Add(name)
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync node pool command %q, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(name string) error {
+	obj, err := c.lister.Get(name)
+	if apierrors.IsNotFound(err) {
+		// the jobs of a deleted command are stopped
+		return c.deleteJobs(name)
+	} else if err != nil {
+		return err
+	}
+
+	cmd, err := toNodePoolCommand(obj)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.Status.Phase {
+	case CommandSucceeded, CommandFailed:
+		// the jobs are deleted after the status is updated, they are left if the deletion fails
+		return c.deleteJobs(name)
+	case "":
+		return c.start(obj, cmd)
+	default:
+		return c.run(obj, cmd)
+	}
+}
+
+// start selects the ready edge nodes of the node pool that the command runs on, all nodes
+// are skipped for dry run.
+func (c *Controller) start(obj runtime.Object, cmd *NodePoolCommand) error {
+	now := metav1.Now()
+	status := &NodePoolCommandStatus{Phase: CommandRunning, StartTime: &now}
+	if err := kubeutil.ValidateNodeCommand(cmd.Spec.Command); err != nil {
+		status.Phase, status.Message, status.CompletionTime = CommandFailed, err.Error(), &now
+		c.recorder.Event(obj, v1.EventTypeWarning, "InvalidCommand", err.Error())
+		return c.updateStatus(obj, status)
+	}
+
+	nodes, err := c.nodeLister.List(controllerutil.EdgeNodeSelector(cmd.Spec.NodePool))
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	var selected []string
+	for _, node := range nodes {
+		result := NodeCommandResult{NodeName: node.Name, Phase: NodePending}
+		if !nodeReady(node) {
+			result.Phase, result.Message = NodeSkipped, "node is not ready"
+		} else if cmd.Spec.DryRun {
+			result.Phase, result.Message = NodeSkipped, "dry run"
+			selected = append(selected, node.Name)
+		} else {
+			selected = append(selected, node.Name)
+		}
+		status.Nodes = append(status.Nodes, result)
+	}
+
+	if cmd.Spec.DryRun {
+		c.recorder.Eventf(obj, v1.EventTypeNormal, "DryRun", "command %s would run on %d nodes of node pool %q: %s",
+			cmd.Spec.Command, len(selected), cmd.Spec.NodePool, strings.Join(selected, ", "))
+	} else {
+		c.recorder.Eventf(obj, v1.EventTypeNormal, "Started", "command %s is started on %d nodes of node pool %q: %s",
+			cmd.Spec.Command, len(selected), cmd.Spec.NodePool, strings.Join(selected, ", "))
+	}
+	c.complete(obj, status)
+	return c.updateStatus(obj, status)
+}
+
+// run collects the results of the running jobs, and creates jobs for the pending nodes
+// until MaxConcurrency jobs are running.
+func (c *Controller) run(obj runtime.Object, cmd *NodePoolCommand) error {
+	status := cmd.Status
+	status.Nodes = append([]NodeCommandResult(nil), cmd.Status.Nodes...)
+
+	var errs []error
+	var finished []*batchv1.Job
+	running, failed := 0, 0
+	for i := range status.Nodes {
+		result := &status.Nodes[i]
+		if result.Phase == NodeRunning {
+			job, err := c.jobLister.Jobs(metav1.NamespaceSystem).Get(jobName(result.NodeName))
+			if err != nil && !apierrors.IsNotFound(err) {
+				errs = append(errs, err)
+				running++
+				continue
+			}
+			switch {
+			case job == nil || job.Labels[LabelNodePoolCommand] != cmd.Name:
+				result.Phase, result.Message = NodeFailed, "servant job is deleted"
+			case jobFinished(job, batchv1.JobComplete):
+				result.Phase = NodeSucceeded
+				finished = append(finished, job)
+			case jobFinished(job, batchv1.JobFailed):
+				result.Phase, result.Message = NodeFailed, "servant job is failed"
+				if r := kubeutil.ServantPodResult(c.kubeClient, job); r != nil && !r.Succeeded {
+					result.Message = strings.TrimSpace(r.Reason() + ": " + r.Message)
+				}
+				finished = append(finished, job)
+			default:
+				running++
+			}
+			switch result.Phase {
+			case NodeSucceeded:
+				c.recorder.Eventf(obj, v1.EventTypeNormal, "NodeSucceeded", "command %s succeeded on node %s",
+					cmd.Spec.Command, result.NodeName)
+			case NodeFailed:
+				c.recorder.Eventf(obj, v1.EventTypeWarning, "NodeFailed", "command %s failed on node %s, %s",
+					cmd.Spec.Command, result.NodeName, result.Message)
+			}
+		}
+		if result.Phase == NodeFailed {
+			failed++
+		}
+	}
+
+	for i := range status.Nodes {
+		result := &status.Nodes[i]
+		if result.Phase != NodePending {
+			continue
+		}
+		if failed != 0 && !cmd.Spec.ContinueOnFailure {
+			result.Phase, result.Message = NodeSkipped, "aborted after the command failed on other nodes"
+			continue
+		}
+		if running >= maxConcurrency(cmd) {
+			continue
+		}
+		started, err := c.createJob(cmd, result.NodeName)
+		if err != nil {
+			errs = append(errs, err)
+		} else if started {
+			result.Phase = NodeRunning
+			running++
+		}
+	}
+
+	c.complete(obj, &status)
+	if !reflect.DeepEqual(&status, &cmd.Status) {
+		if err := c.updateStatus(obj, &status); err != nil {
+			return err
+		}
+	}
+	for _, job := range finished {
+		if err := c.deleteJob(job); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// complete counts the results, and completes the command if it's finished on all nodes
+func (c *Controller) complete(obj runtime.Object, status *NodePoolCommandStatus) {
+	status.Succeeded, status.Failed = 0, 0
+	for _, result := range status.Nodes {
+		switch result.Phase {
+		case NodePending, NodeRunning:
+			return
+		case NodeSucceeded:
+			status.Succeeded++
+		case NodeFailed:
+			status.Failed++
+		}
+	}
+
+	now := metav1.Now()
+	status.CompletionTime = &now
+	if status.Failed != 0 {
+		status.Phase = CommandFailed
+		c.recorder.Eventf(obj, v1.EventTypeWarning, "Failed", "command failed on %d nodes, and succeeded on %d nodes",
+			status.Failed, status.Succeeded)
+		return
+	}
+	status.Phase = CommandSucceeded
+	c.recorder.Eventf(obj, v1.EventTypeNormal, "Completed", "command succeeded on %d nodes", status.Succeeded)
+}
+
+// createJob creates the servant job to run the command on node, it returns false if the job
+// of another command is still on the node.
+func (c *Controller) createJob(cmd *NodePoolCommand, nodeName string) (bool, error) {
+	params := kubeutil.ServantJobParams{
+		Action:      kubeutil.ServantJobActionCommand,
+		NodeCommand: cmd.Spec.Command,
+	}
+	desired, err := kubeutil.NewServantJob(params, nodeName)
+	if err != nil {
+		return false, err
+	}
+
+	job, err := c.jobLister.Jobs(desired.Namespace).Get(desired.Name)
+	if err == nil {
+		// the job is created by the last sync if it belongs to the command
+		return job.Labels[LabelNodePoolCommand] == cmd.Name, nil
+	} else if !apierrors.IsNotFound(err) {
+		return false, err
+	}
+
+	desired.Labels = map[string]string{LabelNodePoolCommand: cmd.Name}
+	klog.Infof("run command %s of %s on node %s by job %s", cmd.Spec.Command, cmd.Name, nodeName, desired.Name)
+	if _, err := c.kubeClient.BatchV1().Jobs(desired.Namespace).Create(desired); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteJobs deletes the servant jobs of the command
+func (c *Controller) deleteJobs(name string) error {
+	selector := labels.SelectorFromSet(labels.Set{LabelNodePoolCommand: name})
+	jobs, err := c.jobLister.Jobs(metav1.NamespaceSystem).List(selector)
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if err := c.deleteJob(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) deleteJob(job *batchv1.Job) error {
+	err := c.kubeClient.BatchV1().Jobs(job.Namespace).Delete(job.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &kubeutil.PropagationPolicy,
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Controller) updateStatus(obj runtime.Object, status *NodePoolCommandStatus) error {
+	u := obj.(*unstructured.Unstructured).DeepCopy()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(SchemeGroupVersionResource).UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// maxConcurrency returns the max number of nodes that the command runs on at the same time
+func maxConcurrency(cmd *NodePoolCommand) int {
+	if cmd.Spec.MaxConcurrency < 1 {
+		return 1
+	}
+	return cmd.Spec.MaxConcurrency
+}
+
+// jobName returns the name of the servant job that runs commands on node
+func jobName(nodeName string) string {
+	return kubeutil.CommandJobNameBase + "-" + nodeName
+}
+
+func jobFinished(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == conditionType && cond.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func toNodePoolCommand(obj runtime.Object) (*NodePoolCommand, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	cmd := &NodePoolCommand{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), cmd); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to node pool command, %v", u.GetName(), err)
+	}
+
+	return cmd, nil
+}
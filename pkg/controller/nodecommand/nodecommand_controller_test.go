@@ -0,0 +1,306 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodecommand
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestCommand(spec NodePoolCommandSpec, nodes ...NodeCommandResult) *unstructured.Unstructured {
+	cmd := &NodePoolCommand{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolCommand",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: "restart"},
+		Spec:       spec,
+	}
+	if len(nodes) != 0 {
+		cmd.Status = NodePoolCommandStatus{Phase: CommandRunning, Nodes: nodes}
+	}
+
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(cmd)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func newTestNode(name, pool string, ready bool) *v1.Node {
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionUnknown
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{
+			constants.LabelNodePool:   pool,
+			constants.LabelEdgeWorker: "true",
+		}},
+		Status: v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}},
+	}
+}
+
+func newTestJob(node, command string, condition batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "yurtctl-servant-command-" + node,
+			Namespace: "kube-system",
+			Labels:    map[string]string{LabelNodePoolCommand: command},
+		},
+	}
+	if len(condition) != 0 {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue}}
+	}
+	return job
+}
+
+func result(node string, phase NodePhase, message string) NodeCommandResult {
+	return NodeCommandResult{NodeName: node, Phase: phase, Message: message}
+}
+
+func TestSync(t *testing.T) {
+	spec := NodePoolCommandSpec{NodePool: "hangzhou", Command: kubeutil.NodeCommandRestartKubelet, MaxConcurrency: 2}
+	nodes := []*v1.Node{
+		newTestNode("node1", "hangzhou", true),
+		newTestNode("node2", "hangzhou", true),
+		newTestNode("node3", "hangzhou", true),
+		newTestNode("node4", "hangzhou", false),
+		newTestNode("node5", "beijing", true),
+	}
+	dryRun := spec
+	dryRun.DryRun = true
+	continueOnFailure := spec
+	continueOnFailure.ContinueOnFailure = true
+
+	testcases := map[string]struct {
+		command *unstructured.Unstructured
+		jobs    []*batchv1.Job
+		created []string
+		deleted []string
+		phase   CommandPhase
+		nodes   []NodeCommandResult
+		events  []string
+	}{
+		"select ready nodes of node pool": {
+			command: newTestCommand(spec),
+			phase:   CommandRunning,
+			nodes: []NodeCommandResult{
+				result("node1", NodePending, ""),
+				result("node2", NodePending, ""),
+				result("node3", NodePending, ""),
+				result("node4", NodeSkipped, "node is not ready"),
+			},
+			events: []string{"Normal Started command restart-kubelet is started on 3 nodes of node pool \"hangzhou\": node1, node2, node3"},
+		},
+		"dry run": {
+			command: newTestCommand(dryRun),
+			phase:   CommandSucceeded,
+			nodes: []NodeCommandResult{
+				result("node1", NodeSkipped, "dry run"),
+				result("node2", NodeSkipped, "dry run"),
+				result("node3", NodeSkipped, "dry run"),
+				result("node4", NodeSkipped, "node is not ready"),
+			},
+			events: []string{
+				"Normal DryRun command restart-kubelet would run on 3 nodes of node pool \"hangzhou\": node1, node2, node3",
+				"Normal Completed command succeeded on 0 nodes",
+			},
+		},
+		"invalid command": {
+			command: newTestCommand(NodePoolCommandSpec{NodePool: "hangzhou", Command: "reboot; rm -rf /"}),
+			phase:   CommandFailed,
+			events:  []string{"Warning InvalidCommand unknown command: reboot; rm -rf /, valid commands are: restart-kubelet, restart-container-runtime, restart-yurthub"},
+		},
+		"run on nodes with max concurrency": {
+			command: newTestCommand(spec, result("node1", NodePending, ""), result("node2", NodePending, ""),
+				result("node3", NodePending, "")),
+			created: []string{"yurtctl-servant-command-node1", "yurtctl-servant-command-node2"},
+			phase:   CommandRunning,
+			nodes: []NodeCommandResult{
+				result("node1", NodeRunning, ""),
+				result("node2", NodeRunning, ""),
+				result("node3", NodePending, ""),
+			},
+		},
+		"wait for the job of another command": {
+			command: newTestCommand(spec, result("node1", NodePending, "")),
+			jobs:    []*batchv1.Job{newTestJob("node1", "other", "")},
+			phase:   CommandRunning,
+			nodes:   []NodeCommandResult{result("node1", NodePending, "")},
+		},
+		"next node after a job is completed": {
+			command: newTestCommand(spec, result("node1", NodeRunning, ""), result("node2", NodeRunning, ""),
+				result("node3", NodePending, "")),
+			jobs: []*batchv1.Job{
+				newTestJob("node1", "restart", batchv1.JobComplete),
+				newTestJob("node2", "restart", ""),
+			},
+			created: []string{"yurtctl-servant-command-node3"},
+			deleted: []string{"yurtctl-servant-command-node1"},
+			phase:   CommandRunning,
+			nodes: []NodeCommandResult{
+				result("node1", NodeSucceeded, ""),
+				result("node2", NodeRunning, ""),
+				result("node3", NodeRunning, ""),
+			},
+			events: []string{"Normal NodeSucceeded command restart-kubelet succeeded on node node1"},
+		},
+		"abort after a failure": {
+			command: newTestCommand(spec, result("node1", NodeRunning, ""), result("node2", NodeSucceeded, ""),
+				result("node3", NodePending, "")),
+			jobs:    []*batchv1.Job{newTestJob("node1", "restart", batchv1.JobFailed)},
+			deleted: []string{"yurtctl-servant-command-node1"},
+			phase:   CommandFailed,
+			nodes: []NodeCommandResult{
+				result("node1", NodeFailed, "servant job is failed"),
+				result("node2", NodeSucceeded, ""),
+				result("node3", NodeSkipped, "aborted after the command failed on other nodes"),
+			},
+			events: []string{
+				"Warning NodeFailed command restart-kubelet failed on node node1, servant job is failed",
+				"Warning Failed command failed on 1 nodes, and succeeded on 1 nodes",
+			},
+		},
+		"continue on failure": {
+			command: newTestCommand(continueOnFailure, result("node1", NodeRunning, ""), result("node2", NodePending, "")),
+			jobs:    []*batchv1.Job{newTestJob("node1", "restart", batchv1.JobFailed)},
+			created: []string{"yurtctl-servant-command-node2"},
+			deleted: []string{"yurtctl-servant-command-node1"},
+			phase:   CommandRunning,
+			nodes: []NodeCommandResult{
+				result("node1", NodeFailed, "servant job is failed"),
+				result("node2", NodeRunning, ""),
+			},
+			events: []string{"Warning NodeFailed command restart-kubelet failed on node node1, servant job is failed"},
+		},
+		"deleted job fails the node": {
+			command: newTestCommand(spec, result("node1", NodeRunning, "")),
+			phase:   CommandFailed,
+			nodes:   []NodeCommandResult{result("node1", NodeFailed, "servant job is deleted")},
+			events: []string{
+				"Warning NodeFailed command restart-kubelet failed on node node1, servant job is deleted",
+				"Warning Failed command failed on 1 nodes, and succeeded on 0 nodes",
+			},
+		},
+		"jobs of deleted command are deleted": {
+			jobs:    []*batchv1.Job{newTestJob("node1", "restart", ""), newTestJob("node2", "other", "")},
+			deleted: []string{"yurtctl-servant-command-node1"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var dynamicObjs []runtime.Object
+			if tt.command != nil {
+				indexer.Add(tt.command)
+				dynamicObjs = append(dynamicObjs, tt.command.DeepCopy())
+			}
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var objs []runtime.Object
+			for _, node := range nodes {
+				nodeIndexer.Add(node)
+				objs = append(objs, node.DeepCopy())
+			}
+			for _, job := range tt.jobs {
+				jobIndexer.Add(job)
+				objs = append(objs, job.DeepCopy())
+			}
+			kubeClient := fake.NewSimpleClientset(objs...)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjs...)
+			recorder := record.NewFakeRecorder(10)
+			c := &Controller{
+				kubeClient:    kubeClient,
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+				jobLister:     batchlisters.NewJobLister(jobIndexer),
+				recorder:      recorder,
+			}
+
+			if err := c.sync("restart"); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			var created, deleted []string
+			for _, action := range kubeClient.Actions() {
+				switch action.GetVerb() {
+				case "create":
+					job := action.(clienttesting.CreateAction).GetObject().(*batchv1.Job)
+					created = append(created, job.Name)
+					if job.Labels[LabelNodePoolCommand] != "restart" {
+						t.Errorf("expect job %s is labeled with the command, but got %v", job.Name, job.Labels)
+					}
+				case "delete":
+					deleted = append(deleted, action.(clienttesting.DeleteAction).GetName())
+				}
+			}
+			if !reflect.DeepEqual(created, tt.created) {
+				t.Errorf("expect created jobs %v, but got %v", tt.created, created)
+			}
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("expect deleted jobs %v, but got %v", tt.deleted, deleted)
+			}
+
+			var events []string
+			close(recorder.Events)
+			for event := range recorder.Events {
+				events = append(events, event)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("expect events\n%s\nbut got\n%s", strings.Join(tt.events, "\n"), strings.Join(events, "\n"))
+			}
+
+			if tt.command == nil {
+				return
+			}
+			u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get("restart", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get node pool command, %v", err)
+			}
+			cmd, err := toNodePoolCommand(u)
+			if err != nil {
+				t.Fatalf("failed to convert node pool command, %v", err)
+			}
+			if cmd.Status.Phase != tt.phase {
+				t.Errorf("expect phase %s, but got %s", tt.phase, cmd.Status.Phase)
+			}
+			if !reflect.DeepEqual(cmd.Status.Nodes, tt.nodes) {
+				t.Errorf("expect nodes %+v, but got %+v", tt.nodes, cmd.Status.Nodes)
+			}
+			if (tt.phase == CommandSucceeded || tt.phase == CommandFailed) && cmd.Status.CompletionTime == nil {
+				t.Errorf("expect completion time is set")
+			}
+		})
+	}
+}
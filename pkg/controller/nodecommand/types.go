@@ -0,0 +1,105 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nodecommand
+
+import (
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of NodePoolCommand
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "nodepoolcommands",
+}
+
+const (
+	// LabelNodePoolCommand is the label of servant job for the NodePoolCommand that the job runs
+	LabelNodePoolCommand = "openyurt.io/node-pool-command"
+)
+
+// CommandPhase is the phase of NodePoolCommand
+type CommandPhase string
+
+const (
+	CommandRunning   CommandPhase = "Running"
+	CommandSucceeded CommandPhase = "Succeeded"
+	CommandFailed    CommandPhase = "Failed"
+)
+
+// NodePhase is the phase of the command on a node
+type NodePhase string
+
+const (
+	NodePending   NodePhase = "Pending"
+	NodeRunning   NodePhase = "Running"
+	NodeSucceeded NodePhase = "Succeeded"
+	NodeFailed    NodePhase = "Failed"
+	// NodeSkipped means the command is not run on the node, like the node is not ready,
+	// the command is a dry run, or it's aborted after a failure.
+	NodeSkipped NodePhase = "Skipped"
+)
+
+// NodePoolCommand is an emergency operation that is run once on the edge nodes of a node
+// pool by servant jobs, the nodes are selected when the command is started.
+type NodePoolCommand struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodePoolCommandSpec   `json:"spec"`
+	Status NodePoolCommandStatus `json:"status,omitempty"`
+}
+
+// NodePoolCommandSpec is the spec of NodePoolCommand
+type NodePoolCommandSpec struct {
+	// NodePool is the node pool that the command is run on,
+	// empty means the edge nodes that don't belong to any node pool.
+	NodePool string `json:"nodePool,omitempty"`
+	// Command is one of the commands of kubeutil.NodeCommands, like restart-kubelet
+	Command kubeutil.NodeCommand `json:"command"`
+	// MaxConcurrency is the max number of nodes that the command is run on at the same time,
+	// defaults to 1.
+	MaxConcurrency int `json:"maxConcurrency,omitempty"`
+	// ContinueOnFailure keeps running the command on the rest nodes after it fails on a node,
+	// by default the rest nodes are skipped.
+	ContinueOnFailure bool `json:"continueOnFailure,omitempty"`
+	// DryRun only lists the nodes that the command would be run on
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// NodePoolCommandStatus is the status of NodePoolCommand
+type NodePoolCommandStatus struct {
+	Phase          CommandPhase `json:"phase,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Succeeded and Failed are the number of nodes that the command succeeds or fails on
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	// Nodes are the results of the command on the selected nodes, in the order of names
+	Nodes []NodeCommandResult `json:"nodes,omitempty"`
+}
+
+// NodeCommandResult is the result of the command on a node
+type NodeCommandResult struct {
+	NodeName string    `json:"nodeName"`
+	Phase    NodePhase `json:"phase"`
+	Message  string    `json:"message,omitempty"`
+}
@@ -19,6 +19,7 @@ package nodelifecycle
 import (
 	"sync"
 
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -74,5 +75,6 @@ func Register() {
 		prometheus.MustRegister(zoneSize)
 		prometheus.MustRegister(unhealthyNodes)
 		prometheus.MustRegister(evictionsNumber)
+		prometheus.MustRegister(scheduler.AutonomyDecisions)
 	})
 }
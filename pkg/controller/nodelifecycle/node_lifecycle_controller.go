@@ -187,6 +187,9 @@ type Controller struct {
 	knownNodeSet map[string]*v1.Node
 	// per Node map storing last observed health together with a local time when it was observed.
 	nodeHealthMap map[string]*nodeHealthData
+	// autonomyEvictionSuppressed records the nodes that pod eviction is suppressed for, because
+	// of autonomy or eviction policy, so the decision is recorded once until the node is ready again.
+	autonomyEvictionSuppressed map[string]bool
 
 	// Lock to access evictor workers
 	evictorLock sync.Mutex
@@ -306,6 +309,7 @@ func NewNodeLifecycleController(
 		now:                         metav1.Now,
 		knownNodeSet:                make(map[string]*v1.Node),
 		nodeHealthMap:               make(map[string]*nodeHealthData),
+		autonomyEvictionSuppressed:  make(map[string]bool),
 		recorder:                    recorder,
 		nodeMonitorPeriod:           nodeMonitorPeriod,
 		nodeStartupGracePeriod:      nodeStartupGracePeriod,
@@ -635,7 +639,14 @@ func (nc *Controller) doEvictionPass() {
 				klog.Warningf("Failed to get Node %v from the nodeLister: %v", value.Value, err)
 			}
 			nodeUID, _ := value.UID.(string)
-			remaining, err := nodeutil.DeletePods(nc.kubeClient, nc.recorder, value.Value, nodeUID, nc.daemonSetStore)
+			var filter func(pod *v1.Pod) bool
+			if node != nil {
+				// critical pods are kept on the node with eviction policy NonCriticalOnly
+				filter = func(pod *v1.Pod) bool {
+					return scheduler.IsPodEvictable(node, pod)
+				}
+			}
+			remaining, err := nodeutil.DeletePodsWithFilter(nc.kubeClient, nc.recorder, value.Value, nodeUID, nc.daemonSetStore, filter)
 			if err != nil {
 				utilruntime.HandleError(fmt.Errorf("unable to evict node %q: %v", value.Value, err))
 				return false, 0
@@ -687,6 +698,7 @@ func (nc *Controller) monitorNodeHealth() error {
 		klog.V(1).Infof("Controller observed a Node deletion: %v", deleted[i].Name)
 		nodeutil.RecordNodeEvent(nc.recorder, deleted[i].Name, string(deleted[i].UID), v1.EventTypeNormal, "RemovingNode", fmt.Sprintf("Removing Node %v from Controller", deleted[i].Name))
 		delete(nc.knownNodeSet, deleted[i].Name)
+		delete(nc.autonomyEvictionSuppressed, deleted[i].Name)
 	}
 
 	zoneToNodeConditions := map[string][]*v1.NodeCondition{}
@@ -736,17 +748,15 @@ func (nc *Controller) monitorNodeHealth() error {
 						)
 					}
 				} else {
-					// if node is in autonomy status, skip evict pods from the node
-					if !(node != nil && node.Annotations != nil && node.Annotations[scheduler.AnnotationKeyNodeAutonomy] == "true") {
-						if decisionTimestamp.After(nc.nodeHealthMap[node.Name].readyTransitionTimestamp.Add(nc.podEvictionTimeout)) {
-							if nc.evictPods(node) {
-								klog.V(2).Infof("Node is NotReady. Adding Pods on Node %s to eviction queue: %v is later than %v + %v",
-									node.Name,
-									decisionTimestamp,
-									nc.nodeHealthMap[node.Name].readyTransitionTimestamp,
-									nc.podEvictionTimeout,
-								)
-							}
+					if decisionTimestamp.After(nc.nodeHealthMap[node.Name].readyTransitionTimestamp.Add(nc.podEvictionTimeout)) {
+						// if node is in autonomy status or eviction is held by eviction policy, skip evict pods from the node
+						if !nc.suppressPodEviction(node, "NotReady", nc.nodeHealthMap[node.Name].readyTransitionTimestamp, decisionTimestamp) && nc.evictPods(node) {
+							klog.V(2).Infof("Node is NotReady. Adding Pods on Node %s to eviction queue: %v is later than %v + %v",
+								node.Name,
+								decisionTimestamp,
+								nc.nodeHealthMap[node.Name].readyTransitionTimestamp,
+								nc.podEvictionTimeout,
+							)
 						}
 					}
 				}
@@ -766,22 +776,21 @@ func (nc *Controller) monitorNodeHealth() error {
 						)
 					}
 				} else {
-					// if node is in autonomy status, skip evict pods from the node
-					if !(node != nil && node.Annotations != nil && node.Annotations[scheduler.AnnotationKeyNodeAutonomy] == "true") {
-						if decisionTimestamp.After(nc.nodeHealthMap[node.Name].probeTimestamp.Add(nc.podEvictionTimeout)) {
-							if nc.evictPods(node) {
-								klog.V(2).Infof("Node is unresponsive. Adding Pods on Node %s to eviction queues: %v is later than %v + %v",
-									node.Name,
-									decisionTimestamp,
-									nc.nodeHealthMap[node.Name].readyTransitionTimestamp,
-									nc.podEvictionTimeout-gracePeriod,
-								)
-							}
+					if decisionTimestamp.After(nc.nodeHealthMap[node.Name].probeTimestamp.Add(nc.podEvictionTimeout)) {
+						// if node is in autonomy status or eviction is held by eviction policy, skip evict pods from the node
+						if !nc.suppressPodEviction(node, "unresponsive", nc.nodeHealthMap[node.Name].probeTimestamp, decisionTimestamp) && nc.evictPods(node) {
+							klog.V(2).Infof("Node is unresponsive. Adding Pods on Node %s to eviction queues: %v is later than %v + %v",
+								node.Name,
+								decisionTimestamp,
+								nc.nodeHealthMap[node.Name].readyTransitionTimestamp,
+								nc.podEvictionTimeout-gracePeriod,
+							)
 						}
 					}
 				}
 			}
 			if observedReadyCondition.Status == v1.ConditionTrue {
+				delete(nc.autonomyEvictionSuppressed, node.Name)
 				if nc.useTaintBasedEvictions {
 					removed, err := nc.markNodeAsReachable(node)
 					if err != nil {
@@ -799,9 +808,17 @@ func (nc *Controller) monitorNodeHealth() error {
 
 			// Report node event.
 			if currentReadyCondition.Status != v1.ConditionTrue && observedReadyCondition.Status == v1.ConditionTrue {
-				nodeutil.RecordNodeStatusChange(nc.recorder, node, "NodeNotReady")
-				// if node in autonomy status, do not update pod status to not ready
-				if !(node != nil && node.Annotations != nil && node.Annotations[scheduler.AnnotationKeyNodeAutonomy] == "true") {
+				// the node pool in maintenance is expected to be unreachable, so it's not alerted
+				if scheduler.IsNodeInMaintenance(node, nc.now().Time) {
+					klog.V(2).Infof("Node %s is not ready during maintenance(%s) of its node pool", node.Name, node.Annotations[scheduler.AnnotationKeyMaintenance])
+				} else {
+					nodeutil.RecordNodeStatusChange(nc.recorder, node, "NodeNotReady")
+				}
+				// if node in autonomy status or pods are never evicted from it, do not update pod status to not ready
+				if scheduler.IsEvictionDisabled(node) {
+					scheduler.RecordAutonomyDecision(nc.recorder, node, scheduler.MarkPodsNotReadySkipped,
+						"%s, pods on the node are not marked as NotReady", evictionDisabledReason(node))
+				} else {
 					pods, err := nc.getPodsAssignedToNode(node.Name)
 					if err != nil {
 						utilruntime.HandleError(fmt.Errorf("unable to list pods of node %v: %v", node.Name, err))
@@ -819,6 +836,44 @@ func (nc *Controller) monitorNodeHealth() error {
 	return nil
 }
 
+// suppressPodEviction checks whether evicting pods from the node that is unhealthy since
+// unhealthySince is skipped, because the node is autonomous or eviction is held by eviction
+// policy of node. the decision is recorded once until the node is ready again.
+func (nc *Controller) suppressPodEviction(node *v1.Node, status string, unhealthySince, now metav1.Time) bool {
+	decision, reason := scheduler.EvictionSuppressed, evictionDisabledReason(node)
+	if len(reason) == 0 {
+		if !scheduler.IsEvictionDelayed(node, unhealthySince.Time, now.Time) {
+			return false
+		}
+		policy := scheduler.GetEvictionPolicy(node)
+		decision = scheduler.EvictionDelayed
+		reason = fmt.Sprintf("eviction policy of node delays eviction until %s", unhealthySince.Add(policy.EvictAfter).Format(time.RFC3339))
+	}
+
+	if nc.autonomyEvictionSuppressed[node.Name] {
+		return true
+	}
+
+	nc.autonomyEvictionSuppressed[node.Name] = true
+	klog.V(2).Infof("Node %s is %s, but %s, skip evicting pods from it", node.Name, status, reason)
+	scheduler.RecordAutonomyDecision(nc.recorder, node, decision,
+		"node is %s for longer than %v, but pods are not evicted because %s", status, nc.podEvictionTimeout, reason)
+	return true
+}
+
+// evictionDisabledReason returns why pods are never evicted from the node, empty
+// string is returned if pods can be evicted from the node.
+func evictionDisabledReason(node *v1.Node) string {
+	if scheduler.IsNodeAutonomous(node) {
+		return "node is autonomous"
+	} else if scheduler.IsNodeInMaintenance(node, time.Now()) {
+		return fmt.Sprintf("node pool of node is in maintenance(%s)", node.Annotations[scheduler.AnnotationKeyMaintenance])
+	} else if scheduler.IsEvictionDisabled(node) {
+		return "eviction policy of node is Never"
+	}
+	return ""
+}
+
 // tryUpdateNodeHealth checks a given node's conditions and tries to update it. Returns grace period to
 // which given node is entitled, state of current and last observed Ready Condition, and an error if it occurred.
 func (nc *Controller) tryUpdateNodeHealth(node *v1.Node) (time.Duration, v1.NodeCondition, *v1.NodeCondition, error) {
@@ -42,6 +42,7 @@ import (
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	testcore "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/kubernetes/pkg/controller"
 	"k8s.io/kubernetes/pkg/controller/testutil"
 	nodeutil "k8s.io/kubernetes/pkg/controller/util/node"
@@ -3083,3 +3084,95 @@ func TestReconcileNodeLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestSuppressPodEviction(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	nc := &Controller{
+		recorder:                   recorder,
+		podEvictionTimeout:         5 * time.Minute,
+		autonomyEvictionSuppressed: make(map[string]bool),
+	}
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "node0",
+			Annotations: map[string]string{scheduler.AnnotationKeyNodeAutonomy: "true"},
+		},
+	}
+	since := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := metav1.NewTime(since.Add(time.Hour))
+
+	// the decision is recorded once until the node is ready again
+	nc.suppressPodEviction(node, "NotReady", since, now)
+	nc.suppressPodEviction(node, "NotReady", since, now)
+	if len(recorder.Events) != 1 {
+		t.Fatalf("expect 1 event, but got %d", len(recorder.Events))
+	}
+	if event := <-recorder.Events; !strings.Contains(event, string(scheduler.EvictionSuppressed)) {
+		t.Errorf("unexpected event %q", event)
+	}
+
+	delete(nc.autonomyEvictionSuppressed, node.Name)
+	nc.suppressPodEviction(node, "unresponsive", since, now)
+	if len(recorder.Events) != 1 {
+		t.Errorf("expect 1 event after node is ready again, but got %d", len(recorder.Events))
+	}
+}
+
+func TestSuppressPodEvictionByPolicy(t *testing.T) {
+	since := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	testcases := map[string]struct {
+		annotations map[string]string
+		now         metav1.Time
+		suppressed  bool
+		decision    scheduler.AutonomyDecision
+	}{
+		"no policy": {
+			now: metav1.NewTime(since.Add(time.Hour)),
+		},
+		"never": {
+			annotations: map[string]string{scheduler.AnnotationKeyEvictionPolicy: "Never"},
+			now:         metav1.NewTime(since.Add(24 * time.Hour)),
+			suppressed:  true,
+			decision:    scheduler.EvictionSuppressed,
+		},
+		"delayed": {
+			annotations: map[string]string{scheduler.AnnotationKeyEvictionPolicy: "AfterDuration", scheduler.AnnotationKeyEvictAfterHours: "2"},
+			now:         metav1.NewTime(since.Add(time.Hour)),
+			suppressed:  true,
+			decision:    scheduler.EvictionDelayed,
+		},
+		"delay passed": {
+			annotations: map[string]string{scheduler.AnnotationKeyEvictionPolicy: "AfterDuration", scheduler.AnnotationKeyEvictAfterHours: "2"},
+			now:         metav1.NewTime(since.Add(3 * time.Hour)),
+		},
+		"non critical only": {
+			annotations: map[string]string{scheduler.AnnotationKeyEvictionPolicy: "NonCriticalOnly"},
+			now:         metav1.NewTime(since.Add(time.Hour)),
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			nc := &Controller{
+				recorder:                   recorder,
+				podEvictionTimeout:         5 * time.Minute,
+				autonomyEvictionSuppressed: make(map[string]bool),
+			}
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0", Annotations: tt.annotations}}
+
+			if got := nc.suppressPodEviction(node, "NotReady", since, tt.now); got != tt.suppressed {
+				t.Fatalf("expect suppressed %v, but got %v", tt.suppressed, got)
+			}
+			if !tt.suppressed {
+				if len(recorder.Events) != 0 {
+					t.Errorf("expect no event, but got %d", len(recorder.Events))
+				}
+				return
+			}
+			if event := <-recorder.Events; !strings.Contains(event, string(tt.decision)) {
+				t.Errorf("unexpected event %q", event)
+			}
+		})
+	}
+}
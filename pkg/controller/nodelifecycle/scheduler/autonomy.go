@@ -0,0 +1,63 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"fmt"
+
+	nodeutil "github.com/alibaba/openyurt/pkg/controller/util/node"
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// AutonomyDecision is the decision that node controller makes differently for an
+// autonomous node or by eviction policy of node, it's recorded as reason of the event of node.
+type AutonomyDecision string
+
+const (
+	// EvictionSuppressed means pods are not evicted from the unhealthy autonomous node
+	EvictionSuppressed AutonomyDecision = "AutonomyEvictionSuppressed"
+	// MarkPodsNotReadySkipped means pods on the unhealthy autonomous node are not marked as NotReady
+	MarkPodsNotReadySkipped AutonomyDecision = "AutonomyMarkPodsNotReadySkipped"
+	// EvictionDelayed means pods are not evicted yet from the unhealthy node by eviction policy AfterDuration
+	EvictionDelayed AutonomyDecision = "AutonomyEvictionDelayed"
+)
+
+// AutonomyDecisions counts the decisions made for autonomous nodes, it's
+// registered with the metrics of node lifecycle controller.
+var AutonomyDecisions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Subsystem: "edge_node_collector",
+		Name:      "autonomy_decisions_number",
+		Help:      "Number of decisions that node controller made differently for autonomous nodes.",
+	},
+	[]string{"decision"},
+)
+
+// IsNodeAutonomous checks the node is annotated as autonomous
+func IsNodeAutonomous(node *v1.Node) bool {
+	return node != nil && node.Annotations != nil && node.Annotations[AnnotationKeyNodeAutonomy] == "true"
+}
+
+// RecordAutonomyDecision records the decision for the autonomous node by event and metric
+func RecordAutonomyDecision(recorder record.EventRecorder, node *v1.Node, decision AutonomyDecision, format string, args ...interface{}) {
+	AutonomyDecisions.WithLabelValues(string(decision)).Inc()
+	if recorder != nil {
+		nodeutil.RecordNodeEvent(recorder, node.Name, string(node.UID), v1.EventTypeNormal, string(decision), fmt.Sprintf(format, args...))
+	}
+}
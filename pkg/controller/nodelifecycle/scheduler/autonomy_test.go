@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestIsNodeAutonomous(t *testing.T) {
+	testcases := map[string]struct {
+		node     *v1.Node
+		expected bool
+	}{
+		"nil node": {},
+		"no annotations": {
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		},
+		"autonomy is false": {
+			node: &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{AnnotationKeyNodeAutonomy: "false"}}},
+		},
+		"autonomous": {
+			node:     &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{AnnotationKeyNodeAutonomy: "true"}}},
+			expected: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := IsNodeAutonomous(tt.node); got != tt.expected {
+				t.Errorf("expect %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRecordAutonomyDecision(t *testing.T) {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "uid1"}}
+	recorder := record.NewFakeRecorder(10)
+	before := testutil.ToFloat64(AutonomyDecisions.WithLabelValues(string(EvictionSuppressed)))
+
+	RecordAutonomyDecision(recorder, node, EvictionSuppressed, "node is %s", "NotReady")
+	RecordAutonomyDecision(nil, node, EvictionSuppressed, "node is %s", "NotReady")
+
+	if got := testutil.ToFloat64(AutonomyDecisions.WithLabelValues(string(EvictionSuppressed))); got != before+2 {
+		t.Errorf("expect %v decisions, but got %v", before+2, got)
+	}
+
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, string(EvictionSuppressed)) || !strings.Contains(event, "node is NotReady") {
+			t.Errorf("unexpected event %q", event)
+		}
+	default:
+		t.Errorf("expect an event for the decision, but got none")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+)
+
+const (
+	// AnnotationKeyEvictionPolicy is the annotation of node that the eviction policy of
+	// its node pool is projected to by the eviction policy controller.
+	AnnotationKeyEvictionPolicy = "openyurt.io/eviction-policy"
+	// AnnotationKeyEvictAfterHours is the annotation of node for hours that pods are evicted
+	// after the node is unhealthy, it's only used by policy AfterDuration.
+	AnnotationKeyEvictAfterHours = "openyurt.io/evict-after-hours"
+)
+
+// EvictionPolicyType is how pods are evicted from the unhealthy nodes of a node pool
+type EvictionPolicyType string
+
+const (
+	// EvictionPolicyNever means pods are never evicted from the unhealthy node
+	EvictionPolicyNever EvictionPolicyType = "Never"
+	// EvictionPolicyAfterDuration means pods are evicted after the node is unhealthy for hours
+	EvictionPolicyAfterDuration EvictionPolicyType = "AfterDuration"
+	// EvictionPolicyNonCriticalOnly means only the pods that are not critical are evicted
+	EvictionPolicyNonCriticalOnly EvictionPolicyType = "NonCriticalOnly"
+)
+
+// EvictionPolicy is the eviction policy of node
+type EvictionPolicy struct {
+	Type       EvictionPolicyType
+	EvictAfter time.Duration
+}
+
+// GetEvictionPolicy returns the eviction policy of node, nil is returned if no
+// valid policy is set and the default behavior of node controller is used.
+func GetEvictionPolicy(node *v1.Node) *EvictionPolicy {
+	if node == nil || node.Annotations == nil {
+		return nil
+	}
+
+	switch policyType := EvictionPolicyType(node.Annotations[AnnotationKeyEvictionPolicy]); policyType {
+	case "":
+		return nil
+	case EvictionPolicyNever, EvictionPolicyNonCriticalOnly:
+		return &EvictionPolicy{Type: policyType}
+	case EvictionPolicyAfterDuration:
+		hours, err := strconv.Atoi(node.Annotations[AnnotationKeyEvictAfterHours])
+		if err != nil || hours < 0 {
+			klog.Warningf("invalid %s(%q) of node %s, eviction policy is ignored", AnnotationKeyEvictAfterHours, node.Annotations[AnnotationKeyEvictAfterHours], node.Name)
+			return nil
+		}
+		return &EvictionPolicy{Type: policyType, EvictAfter: time.Duration(hours) * time.Hour}
+	default:
+		klog.Warningf("unknown eviction policy %q of node %s is ignored", policyType, node.Name)
+		return nil
+	}
+}
+
+// IsEvictionDisabled checks that pods are never evicted from the node, because the node
+// is autonomous, its node pool is in maintenance or the eviction policy of node is Never.
+func IsEvictionDisabled(node *v1.Node) bool {
+	if IsNodeAutonomous(node) || IsNodeInMaintenance(node, time.Now()) {
+		return true
+	}
+	policy := GetEvictionPolicy(node)
+	return policy != nil && policy.Type == EvictionPolicyNever
+}
+
+// IsEvictionDelayed checks that pods are not evicted yet from the node that is
+// unhealthy since unhealthySince, by eviction policy AfterDuration.
+func IsEvictionDelayed(node *v1.Node, unhealthySince, now time.Time) bool {
+	policy := GetEvictionPolicy(node)
+	return policy != nil && policy.Type == EvictionPolicyAfterDuration && now.Before(unhealthySince.Add(policy.EvictAfter))
+}
+
+// IsPodEvictable checks that the pod can be evicted from the node by eviction policy,
+// critical pods are not evicted from the node with policy NonCriticalOnly.
+func IsPodEvictable(node *v1.Node, pod *v1.Pod) bool {
+	policy := GetEvictionPolicy(node)
+	return policy == nil || policy.Type != EvictionPolicyNonCriticalOnly || !IsCriticalPod(pod)
+}
+
+// IsCriticalPod checks that the pod has a system critical priority
+func IsCriticalPod(pod *v1.Pod) bool {
+	if pod.Spec.Priority != nil {
+		return *pod.Spec.Priority >= scheduling.SystemCriticalPriority
+	}
+	return pod.Spec.PriorityClassName == scheduling.SystemClusterCritical ||
+		pod.Spec.PriorityClassName == scheduling.SystemNodeCritical
+}
+
+// EvictionNotBefore returns the time before that pods are not evicted from the node by the
+// taints, zero time is returned if the eviction is not delayed by eviction policy of node.
+func EvictionNotBefore(node *v1.Node, taints []v1.Taint) time.Time {
+	policy := GetEvictionPolicy(node)
+	if policy == nil || policy.Type != EvictionPolicyAfterDuration {
+		return time.Time{}
+	}
+
+	var notBefore time.Time
+	for i := range taints {
+		if taints[i].TimeAdded == nil {
+			continue
+		}
+		if t := taints[i].TimeAdded.Add(policy.EvictAfter); notBefore.IsZero() || t.Before(notBefore) {
+			notBefore = t
+		}
+	}
+	return notBefore
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newPolicyNode(policy, hours string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{}}}
+	if len(policy) != 0 {
+		node.Annotations[AnnotationKeyEvictionPolicy] = policy
+	}
+	if len(hours) != 0 {
+		node.Annotations[AnnotationKeyEvictAfterHours] = hours
+	}
+	return node
+}
+
+func TestGetEvictionPolicy(t *testing.T) {
+	testcases := map[string]struct {
+		node     *v1.Node
+		expected *EvictionPolicy
+	}{
+		"nil node": {},
+		"no policy": {
+			node: newPolicyNode("", ""),
+		},
+		"never": {
+			node:     newPolicyNode("Never", ""),
+			expected: &EvictionPolicy{Type: EvictionPolicyNever},
+		},
+		"after duration": {
+			node:     newPolicyNode("AfterDuration", "6"),
+			expected: &EvictionPolicy{Type: EvictionPolicyAfterDuration, EvictAfter: 6 * time.Hour},
+		},
+		"after duration without hours": {
+			node: newPolicyNode("AfterDuration", ""),
+		},
+		"non critical only": {
+			node:     newPolicyNode("NonCriticalOnly", ""),
+			expected: &EvictionPolicy{Type: EvictionPolicyNonCriticalOnly},
+		},
+		"unknown policy": {
+			node: newPolicyNode("Sometimes", ""),
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := GetEvictionPolicy(tt.node); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expect %#v, but got %#v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsEvictionDisabled(t *testing.T) {
+	autonomous := newPolicyNode("NonCriticalOnly", "")
+	autonomous.Annotations[AnnotationKeyNodeAutonomy] = "true"
+	testcases := map[string]struct {
+		node     *v1.Node
+		expected bool
+	}{
+		"no policy": {
+			node: newPolicyNode("", ""),
+		},
+		"autonomous": {
+			node:     autonomous,
+			expected: true,
+		},
+		"never": {
+			node:     newPolicyNode("Never", ""),
+			expected: true,
+		},
+		"after duration": {
+			node: newPolicyNode("AfterDuration", "1"),
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := IsEvictionDisabled(tt.node); got != tt.expected {
+				t.Errorf("expect %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsEvictionDelayed(t *testing.T) {
+	since := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	node := newPolicyNode("AfterDuration", "2")
+	if !IsEvictionDelayed(node, since, since.Add(time.Hour)) {
+		t.Errorf("expect eviction is delayed in 2 hours")
+	}
+	if IsEvictionDelayed(node, since, since.Add(3*time.Hour)) {
+		t.Errorf("expect eviction is not delayed after 2 hours")
+	}
+	if IsEvictionDelayed(newPolicyNode("Never", ""), since, since) {
+		t.Errorf("expect eviction is not delayed by policy Never")
+	}
+}
+
+func TestIsPodEvictable(t *testing.T) {
+	critical := int32(2000000000)
+	normal := int32(0)
+	testcases := map[string]struct {
+		node     *v1.Node
+		pod      *v1.Pod
+		expected bool
+	}{
+		"no policy": {
+			node:     newPolicyNode("", ""),
+			pod:      &v1.Pod{Spec: v1.PodSpec{Priority: &critical}},
+			expected: true,
+		},
+		"critical pod by priority": {
+			node: newPolicyNode("NonCriticalOnly", ""),
+			pod:  &v1.Pod{Spec: v1.PodSpec{Priority: &critical}},
+		},
+		"critical pod by priority class": {
+			node: newPolicyNode("NonCriticalOnly", ""),
+			pod:  &v1.Pod{Spec: v1.PodSpec{PriorityClassName: "system-node-critical"}},
+		},
+		"non critical pod": {
+			node:     newPolicyNode("NonCriticalOnly", ""),
+			pod:      &v1.Pod{Spec: v1.PodSpec{Priority: &normal}},
+			expected: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := IsPodEvictable(tt.node, tt.pod); got != tt.expected {
+				t.Errorf("expect %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvictionNotBefore(t *testing.T) {
+	added := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	taints := []v1.Taint{{Key: "node.kubernetes.io/unreachable", Effect: v1.TaintEffectNoExecute, TimeAdded: &added}}
+
+	if got := EvictionNotBefore(newPolicyNode("", ""), taints); !got.IsZero() {
+		t.Errorf("expect zero time without policy, but got %v", got)
+	}
+	if got, expected := EvictionNotBefore(newPolicyNode("AfterDuration", "3"), taints), added.Add(3*time.Hour); !got.Equal(expected) {
+		t.Errorf("expect %v, but got %v", expected, got)
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// AnnotationKeyMaintenance is the annotation of node for the reason of planned maintenance
+	// (like a WAN outage) of its node pool, it's projected by the yurthub config controller.
+	AnnotationKeyMaintenance = "openyurt.io/maintenance"
+	// AnnotationKeyMaintenanceUntil is the annotation of node for the time that the maintenance
+	// ends in RFC3339, the maintenance doesn't end by itself if it's not set.
+	AnnotationKeyMaintenanceUntil = "openyurt.io/maintenance-until"
+)
+
+// IsNodeInMaintenance checks that the node pool of node is in planned maintenance at now,
+// pods are not evicted from the node and the node is expected to be unreachable.
+func IsNodeInMaintenance(node *v1.Node, now time.Time) bool {
+	if node == nil || len(node.Annotations[AnnotationKeyMaintenance]) == 0 {
+		return false
+	}
+
+	until, ok := node.Annotations[AnnotationKeyMaintenanceUntil]
+	if !ok {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, until)
+	if err != nil {
+		klog.Warningf("invalid %s(%q) of node %s, maintenance doesn't end by itself", AnnotationKeyMaintenanceUntil, until, node.Name)
+		return true
+	}
+	return now.Before(t)
+}
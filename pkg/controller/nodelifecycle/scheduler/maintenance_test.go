@@ -0,0 +1,75 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsNodeInMaintenance(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	newNode := func(reason, until string) *v1.Node {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: map[string]string{}}}
+		if len(reason) != 0 {
+			node.Annotations[AnnotationKeyMaintenance] = reason
+		}
+		if len(until) != 0 {
+			node.Annotations[AnnotationKeyMaintenanceUntil] = until
+		}
+		return node
+	}
+
+	testcases := map[string]struct {
+		node     *v1.Node
+		expected bool
+	}{
+		"nil node": {},
+		"no maintenance": {
+			node: newNode("", ""),
+		},
+		"without until": {
+			node:     newNode("wan upgrade", ""),
+			expected: true,
+		},
+		"before until": {
+			node:     newNode("wan upgrade", "2020-10-01T13:00:00Z"),
+			expected: true,
+		},
+		"after until": {
+			node: newNode("wan upgrade", "2020-10-01T11:00:00Z"),
+		},
+		"invalid until": {
+			node:     newNode("wan upgrade", "tomorrow"),
+			expected: true,
+		},
+		"until without reason": {
+			node: newNode("", "2020-10-01T13:00:00Z"),
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := IsNodeInMaintenance(tt.node, now); got != tt.expected {
+				t.Errorf("expect in maintenance %v, but got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -153,6 +153,14 @@ func getMinTolerationTime(tolerations []v1.Toleration) time.Duration {
 	return time.Duration(minTolerationTime) * time.Second
 }
 
+// laterTime returns the later one of a and b
+func laterTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
 // NewNoExecuteTaintManager creates a new NoExecuteTaintManager that will use passed clientset to
 // communicate with the API server.
 func NewNoExecuteTaintManager(c clientset.Interface, getPod GetPodFunc, getNode GetNodeFunc, getPodsAssignedToNode GetPodsByNodeNameFunc) *NoExecuteTaintManager {
@@ -325,12 +333,14 @@ func (tc *NoExecuteTaintManager) NodeUpdated(oldNode *v1.Node, newNode *v1.Node)
 		return
 	}
 
-	// if node in autonomy status, skip evict pods from the node
+	// if node in autonomy status or eviction policy is Never, skip evict pods from the node
 	if newNode != nil {
 		_, readyCondition := nodeutil.GetNodeCondition(&newNode.Status, v1.NodeReady)
 		if readyCondition != nil && readyCondition.Status != v1.ConditionTrue { // 断网状态
-			if newNode.Annotations != nil && newNode.Annotations[AnnotationKeyNodeAutonomy] == "true" {
+			if IsEvictionDisabled(newNode) {
 				klog.V(2).Infof("Node autonomy: skip evict pod from node(%s)", newNode.Name)
+				RecordAutonomyDecision(tc.recorder, newNode, EvictionSuppressed,
+					"node is not ready, but pods are not evicted by taints because node is autonomous, in maintenance or eviction policy of node is Never")
 				tc.taintedNodesLock.Lock()
 				defer tc.taintedNodesLock.Unlock()
 				delete(tc.taintedNodes, newNode.Name)
@@ -357,6 +367,7 @@ func (tc *NoExecuteTaintManager) processPodOnNode(
 	nodeName string,
 	tolerations []v1.Toleration,
 	taints []v1.Taint,
+	notBefore time.Time,
 	now time.Time,
 ) {
 	if len(taints) == 0 {
@@ -365,9 +376,10 @@ func (tc *NoExecuteTaintManager) processPodOnNode(
 	allTolerated, usedTolerations := v1helper.GetMatchingTolerations(taints, tolerations)
 	if !allTolerated {
 		klog.V(2).Infof("Not all taints are tolerated after update for Pod %v on %v", podNamespacedName.String(), nodeName)
-		// We're canceling scheduled work (if any), as we're going to delete the Pod right away.
+		// We're canceling scheduled work (if any), as we're going to delete the Pod right away,
+		// unless the eviction is delayed by eviction policy of node.
 		tc.cancelWorkWithEvent(podNamespacedName)
-		tc.taintEvictionQueue.AddWork(NewWorkArgs(podNamespacedName.Name, podNamespacedName.Namespace), time.Now(), time.Now())
+		tc.taintEvictionQueue.AddWork(NewWorkArgs(podNamespacedName.Name, podNamespacedName.Namespace), time.Now(), laterTime(time.Now(), notBefore))
 		return
 	}
 	minTolerationTime := getMinTolerationTime(usedTolerations)
@@ -378,11 +390,11 @@ func (tc *NoExecuteTaintManager) processPodOnNode(
 	}
 
 	startTime := now
-	triggerTime := startTime.Add(minTolerationTime)
+	triggerTime := laterTime(startTime.Add(minTolerationTime), notBefore)
 	scheduledEviction := tc.taintEvictionQueue.GetWorkerUnsafe(podNamespacedName.String())
 	if scheduledEviction != nil {
 		startTime = scheduledEviction.CreatedAt
-		if startTime.Add(minTolerationTime).Before(triggerTime) {
+		if laterTime(startTime.Add(minTolerationTime), notBefore).Before(triggerTime) {
 			return
 		}
 		tc.cancelWorkWithEvent(podNamespacedName)
@@ -427,7 +439,19 @@ func (tc *NoExecuteTaintManager) handlePodUpdate(podUpdate podUpdateItem) {
 	if !ok {
 		return
 	}
-	tc.processPodOnNode(podNamespacedName, nodeName, pod.Spec.Tolerations, taints, time.Now())
+
+	node, err := tc.getNode(nodeName)
+	if err != nil {
+		// eviction policy of node is not known, pod is evicted by default behavior
+		klog.V(4).Infof("Could not get node %s for eviction policy: %v", nodeName, err)
+		node = nil
+	}
+	if !IsPodEvictable(node, pod) {
+		klog.V(4).Infof("Pod %v is critical, skip evicting it from node %s by eviction policy", podNamespacedName.String(), nodeName)
+		tc.cancelWorkWithEvent(podNamespacedName)
+		return
+	}
+	tc.processPodOnNode(podNamespacedName, nodeName, pod.Spec.Tolerations, taints, EvictionNotBefore(node, taints), time.Now())
 }
 
 func (tc *NoExecuteTaintManager) handleNodeUpdate(nodeUpdate nodeUpdateItem) {
@@ -480,9 +504,15 @@ func (tc *NoExecuteTaintManager) handleNodeUpdate(nodeUpdate nodeUpdateItem) {
 	}
 
 	now := time.Now()
+	notBefore := EvictionNotBefore(node, taints)
 	for _, pod := range pods {
 		podNamespacedName := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
-		tc.processPodOnNode(podNamespacedName, node.Name, pod.Spec.Tolerations, taints, now)
+		if !IsPodEvictable(node, pod) {
+			klog.V(4).Infof("Pod %v is critical, skip evicting it from node %s by eviction policy", podNamespacedName.String(), node.Name)
+			tc.cancelWorkWithEvent(podNamespacedName)
+			continue
+		}
+		tc.processPodOnNode(podNamespacedName, node.Name, pod.Spec.Tolerations, taints, notBefore, now)
 	}
 }
 
@@ -0,0 +1,495 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+const (
+	nodeNameKeyIndex = "spec.nodeName"
+	unknownVersion   = "unknown"
+)
+
+// Controller maintains a NodePoolSummary for each node pool, so the health of node pools
+// can be read from a few objects instead of joining the nodes and pods of the cluster.
+// the work queue is keyed by node pool, the summary is created when the first node joins
+// the node pool and deleted when the last node leaves it.
+type Controller struct {
+	dynamicClient dynamic.Interface
+	lister        cache.GenericLister
+	synced        cache.InformerSynced
+	nodeLister    corelisters.NodeLister
+	nodeSynced    cache.InformerSynced
+	podIndexer    cache.Indexer
+	podSynced     cache.InformerSynced
+	queue         workqueue.RateLimitingInterface
+	now           func() time.Time
+}
+
+// NewController creates a controller for NodePoolSummary
+func NewController(informer informers.GenericInformer,
+	nodeInformer coreinformers.NodeInformer,
+	podInformer coreinformers.PodInformer,
+	dynamicClient dynamic.Interface) *Controller {
+	c := &Controller{
+		dynamicClient: dynamicClient,
+		lister:        informer.Lister(),
+		synced:        informer.Informer().HasSynced,
+		nodeLister:    nodeInformer.Lister(),
+		nodeSynced:    nodeInformer.Informer().HasSynced,
+		podIndexer:    podInformer.Informer().GetIndexer(),
+		podSynced:     podInformer.Informer().HasSynced,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "poolsummary"),
+		now:           time.Now,
+	}
+
+	// the index is shared with node lifecycle controller
+	if _, ok := c.podIndexer.GetIndexers()[nodeNameKeyIndex]; !ok {
+		podInformer.Informer().AddIndexers(cache.Indexers{
+			nodeNameKeyIndex: func(obj interface{}) ([]string, error) {
+				pod, ok := obj.(*v1.Pod)
+				if !ok || len(pod.Spec.NodeName) == 0 {
+					return []string{}, nil
+				}
+				return []string{pod.Spec.NodeName}, nil
+			},
+		})
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: c.enqueueSummary,
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.enqueuePool(obj.(*v1.Node).Labels[constants.LabelNodePool])
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, newNode := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if oldNode.Labels[constants.LabelNodePool] != newNode.Labels[constants.LabelNodePool] {
+				c.enqueuePool(oldNode.Labels[constants.LabelNodePool])
+				c.enqueuePool(newNode.Labels[constants.LabelNodePool])
+			} else if readyStatusOf(oldNode) != readyStatusOf(newNode) ||
+				oldNode.Annotations[AnnotationAutonomy] != newNode.Annotations[AnnotationAutonomy] ||
+				oldNode.Annotations[AnnotationAutonomyReadiness] != newNode.Annotations[AnnotationAutonomyReadiness] {
+				c.enqueuePool(newNode.Labels[constants.LabelNodePool])
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if node, ok := obj.(*v1.Node); ok {
+				c.enqueuePool(node.Labels[constants.LabelNodePool])
+			}
+		},
+	})
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, newPod := oldObj.(*v1.Pod), newObj.(*v1.Pod)
+			if oldPod.Spec.NodeName != newPod.Spec.NodeName {
+				c.enqueuePod(oldObj)
+			}
+			if oldPod.Spec.NodeName != newPod.Spec.NodeName || oldPod.Status.Phase != newPod.Status.Phase ||
+				podReady(oldPod) != podReady(newPod) {
+				c.enqueuePod(newObj)
+			}
+		},
+		DeleteFunc: c.enqueuePod,
+	})
+
+	return c
+}
+
+// Run starts workers to reconcile NodePoolSummary
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting node pool summary controller")
+	defer klog.Infof("Shutting down node pool summary controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.nodeSynced, c.podSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueuePool enqueues the node pool, the nodes that don't belong to any node pool are not summarized
+func (c *Controller) enqueuePool(pool string) {
+	if len(pool) != 0 {
+		c.queue.Add(pool)
+	}
+}
+
+// enqueueSummary enqueues the node pool of deleted NodePoolSummary, so it's created again
+// if the node pool still has nodes.
+func (c *Controller) enqueueSummary(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+
+	c.enqueuePool(u.GetName())
+}
+
+// enqueuePod enqueues the node pool of the node that pod is assigned to
+func (c *Controller) enqueuePod(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+	if len(pod.Spec.NodeName) == 0 {
+		return
+	}
+
+	node, err := c.nodeLister.Get(pod.Spec.NodeName)
+	if err != nil {
+		return
+	}
+	c.enqueuePool(node.Labels[constants.LabelNodePool])
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync summary of node pool %q, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(pool string) error {
+	nodes, err := c.nodeLister.List(labels.SelectorFromSet(labels.Set{constants.LabelNodePool: pool}))
+	if err != nil {
+		return err
+	}
+
+	obj, err := c.lister.Get(pool)
+	if apierrors.IsNotFound(err) {
+		obj = nil
+	} else if err != nil {
+		return err
+	}
+
+	if len(nodes) == 0 {
+		if obj == nil {
+			return nil
+		}
+		klog.Infof("delete summary of node pool %q, no node belongs to it", pool)
+		err := c.dynamicClient.Resource(SchemeGroupVersionResource).Delete(pool, &metav1.DeleteOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	status, err := c.summarize(nodes)
+	if err != nil {
+		return err
+	}
+
+	var u *unstructured.Unstructured
+	if obj == nil {
+		u, err = c.createSummary(pool)
+		if err != nil {
+			return err
+		}
+	} else {
+		summary, err := toNodePoolSummary(obj)
+		if err != nil {
+			return err
+		}
+		if statusEqual(&summary.Status, status) {
+			return nil
+		}
+		u = obj.(*unstructured.Unstructured).DeepCopy()
+	}
+
+	status.LastUpdateTime = metav1.NewTime(c.now())
+	return c.updateStatus(u, status)
+}
+
+// summarize aggregates the status of nodes and the pods on them
+func (c *Controller) summarize(nodes []*v1.Node) (*NodePoolSummaryStatus, error) {
+	status := &NodePoolSummaryStatus{Nodes: len(nodes)}
+	versions := make(map[string]int)
+	workloads := make(map[string]*WorkloadReference)
+	for _, node := range nodes {
+		switch readyStatusOf(node) {
+		case v1.ConditionTrue:
+			status.ReadyNodes++
+		case v1.ConditionFalse:
+			status.NotReadyNodes++
+		default:
+			status.UnreachableNodes++
+		}
+		if node.Annotations[AnnotationAutonomy] == "true" {
+			status.AutonomousNodes++
+		}
+		if readyForAutonomy(node) {
+			status.ReadyForAutonomyNodes++
+		}
+
+		objs, err := c.podIndexer.ByIndex(nodeNameKeyIndex, node.Name)
+		if err != nil {
+			return nil, err
+		}
+		version := unknownVersion
+		for _, obj := range objs {
+			pod, ok := obj.(*v1.Pod)
+			if !ok || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+				continue
+			}
+			if v, ok := hubVersionOf(pod); ok {
+				version = v
+			}
+
+			ready := podReady(pod)
+			status.Pods++
+			if ready {
+				status.ReadyPods++
+			}
+			ref := workloadOf(pod)
+			if ref == nil {
+				continue
+			}
+			key := strings.Join([]string{ref.Kind, ref.Namespace, ref.Name}, "/")
+			if _, ok := workloads[key]; !ok {
+				workloads[key] = ref
+			}
+			workloads[key].Pods++
+			if ready {
+				workloads[key].ReadyPods++
+			}
+		}
+		versions[version]++
+	}
+
+	status.Workloads = len(workloads)
+	var degraded []WorkloadReference
+	for _, ref := range workloads {
+		if ref.ReadyPods < ref.Pods {
+			degraded = append(degraded, *ref)
+		}
+	}
+	sort.Slice(degraded, func(i, j int) bool {
+		if degraded[i].Namespace != degraded[j].Namespace {
+			return degraded[i].Namespace < degraded[j].Namespace
+		}
+		if degraded[i].Name != degraded[j].Name {
+			return degraded[i].Name < degraded[j].Name
+		}
+		return degraded[i].Kind < degraded[j].Kind
+	})
+	status.DegradedWorkloads = len(degraded)
+	if len(degraded) > MaxDegradedWorkloadRefs {
+		degraded = degraded[:MaxDegradedWorkloadRefs]
+	}
+	status.DegradedWorkloadRefs = degraded
+
+	for version, n := range versions {
+		status.HubVersions = append(status.HubVersions, HubVersion{Version: version, Nodes: n})
+	}
+	sort.Slice(status.HubVersions, func(i, j int) bool {
+		return status.HubVersions[i].Version < status.HubVersions[j].Version
+	})
+	return status, nil
+}
+
+func (c *Controller) createSummary(pool string) (*unstructured.Unstructured, error) {
+	summary := &NodePoolSummary{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolSummary",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: pool},
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(summary)
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("create summary of node pool %q", pool)
+	return c.dynamicClient.Resource(SchemeGroupVersionResource).Create(&unstructured.Unstructured{Object: content}, metav1.CreateOptions{})
+}
+
+func (c *Controller) updateStatus(u *unstructured.Unstructured, status *NodePoolSummaryStatus) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(SchemeGroupVersionResource).UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// statusEqual compares the status without the update time
+func statusEqual(a, b *NodePoolSummaryStatus) bool {
+	x, y := *a, *b
+	x.LastUpdateTime, y.LastUpdateTime = metav1.Time{}, metav1.Time{}
+	if len(x.DegradedWorkloadRefs) == 0 && len(y.DegradedWorkloadRefs) == 0 {
+		x.DegradedWorkloadRefs, y.DegradedWorkloadRefs = nil, nil
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+// readyStatusOf returns the status of Ready condition of node, Unknown if it's not reported
+func readyStatusOf(node *v1.Node) v1.ConditionStatus {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status
+		}
+	}
+	return v1.ConditionUnknown
+}
+
+// readyForAutonomy returns true if yurthub reports the node is ready for autonomy
+func readyForAutonomy(node *v1.Node) bool {
+	value, ok := node.Annotations[AnnotationAutonomyReadiness]
+	if !ok {
+		return false
+	}
+
+	var summary struct {
+		Ready bool `json:"ready"`
+	}
+	if err := json.Unmarshal([]byte(value), &summary); err != nil {
+		klog.V(4).Infof("invalid autonomy readiness of node %s, %v", node.Name, err)
+		return false
+	}
+	return summary.Ready
+}
+
+func podReady(pod *v1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// hubVersionOf returns the image tag of yurthub container if pod is yurthub
+func hubVersionOf(pod *v1.Pod) (string, bool) {
+	selector, _ := labels.Parse(YurtHubLabelSelector)
+	if pod.Namespace != YurtHubNamespace || !selector.Matches(labels.Set(pod.Labels)) {
+		return "", false
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if container.Name != YurtHubContainerName {
+			continue
+		}
+		image := container.Image
+		if i := strings.Index(image, "@"); i >= 0 {
+			image = image[:i]
+		}
+		if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+			return image[i+1:], true
+		}
+		return "latest", true
+	}
+	return "", false
+}
+
+// workloadOf returns the controller of pod, the pods of ReplicaSet are counted for the
+// Deployment that owns the ReplicaSet, as ReplicaSets are replaced by rolling updates.
+func workloadOf(pod *v1.Pod) *WorkloadReference {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return nil
+	}
+
+	ref := &WorkloadReference{Kind: owner.Kind, Namespace: pod.Namespace, Name: owner.Name}
+	hash, ok := pod.Labels["pod-template-hash"]
+	if owner.Kind == "ReplicaSet" && ok && strings.HasSuffix(owner.Name, "-"+hash) {
+		ref.Kind = "Deployment"
+		ref.Name = strings.TrimSuffix(owner.Name, "-"+hash)
+	}
+	return ref
+}
+
+func toNodePoolSummary(obj runtime.Object) (*NodePoolSummary, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	summary := &NodePoolSummary{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), summary); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to node pool summary, %v", u.GetName(), err)
+	}
+
+	return summary, nil
+}
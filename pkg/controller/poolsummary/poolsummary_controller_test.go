@@ -0,0 +1,255 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolsummary
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestNode(name, pool string, ready v1.ConditionStatus, annotations map[string]string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        name,
+		Labels:      map[string]string{constants.LabelNodePool: pool},
+		Annotations: annotations,
+	}}
+	if len(ready) != 0 {
+		node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}}
+	}
+	return node
+}
+
+func newTestPod(namespace, name, node, owner string, ready bool) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{}},
+		Spec:       v1.PodSpec{NodeName: node},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if len(owner) != 0 {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: owner + "-5d4c8", Controller: &controller}}
+		pod.Labels["pod-template-hash"] = "5d4c8"
+	}
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: status}}
+	return pod
+}
+
+func newTestHubPod(node, image string) *v1.Pod {
+	pod := newTestPod(YurtHubNamespace, "yurt-hub-"+node, node, "", true)
+	pod.Labels["k8s-app"] = "yurt-hub"
+	pod.Spec.Containers = []v1.Container{{Name: YurtHubContainerName, Image: image}}
+	return pod
+}
+
+func newTestSummary(pool string, status *NodePoolSummaryStatus) *unstructured.Unstructured {
+	s := &NodePoolSummary{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolSummary",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: pool},
+		Status:     *status,
+	}
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(s)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestSync(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)
+	nodes := []*v1.Node{
+		newTestNode("node1", "hangzhou", v1.ConditionTrue, map[string]string{
+			AnnotationAutonomy:          "true",
+			AnnotationAutonomyReadiness: `{"ready":true,"score":100}`,
+		}),
+		newTestNode("node2", "hangzhou", v1.ConditionFalse, map[string]string{
+			AnnotationAutonomy:          "true",
+			AnnotationAutonomyReadiness: `{"ready":false,"score":40}`,
+		}),
+		newTestNode("node3", "hangzhou", "", nil),
+		newTestNode("node4", "beijing", v1.ConditionTrue, nil),
+	}
+	succeeded := newTestPod("default", "job1", "node1", "", false)
+	succeeded.Status.Phase = v1.PodSucceeded
+	pods := []*v1.Pod{
+		newTestHubPod("node1", "openyurt/yurthub:v0.2.0"),
+		newTestHubPod("node2", "registry.example.com:5000/openyurt/yurthub:v0.1.0@sha256:abc"),
+		newTestPod("default", "web-1", "node1", "web", true),
+		newTestPod("default", "web-2", "node2", "web", false),
+		newTestPod("default", "db-1", "node1", "db", true),
+		newTestPod("default", "bare", "node3", "", false),
+		newTestPod("default", "web-3", "node4", "web", false),
+		succeeded,
+	}
+	expected := &NodePoolSummaryStatus{
+		Nodes:                 3,
+		ReadyNodes:            1,
+		NotReadyNodes:         1,
+		UnreachableNodes:      1,
+		AutonomousNodes:       2,
+		ReadyForAutonomyNodes: 1,
+		Pods:                  6,
+		ReadyPods:             4,
+		Workloads:             2,
+		DegradedWorkloads:     1,
+		DegradedWorkloadRefs: []WorkloadReference{
+			{Kind: "Deployment", Namespace: "default", Name: "web", Pods: 2, ReadyPods: 1},
+		},
+		HubVersions: []HubVersion{
+			{Version: unknownVersion, Nodes: 1},
+			{Version: "v0.1.0", Nodes: 1},
+			{Version: "v0.2.0", Nodes: 1},
+		},
+		LastUpdateTime: metav1.NewTime(now),
+	}
+	stale := *expected
+	stale.ReadyPods = 3
+	stale.LastUpdateTime = metav1.NewTime(now.Add(-time.Hour))
+	current := *expected
+	current.LastUpdateTime = metav1.NewTime(now.Add(-time.Hour))
+
+	testcases := map[string]struct {
+		pool      string
+		summaries []*unstructured.Unstructured
+		verbs     []string
+		status    *NodePoolSummaryStatus
+	}{
+		"create summary": {
+			pool:   "hangzhou",
+			verbs:  []string{"create", "update"},
+			status: expected,
+		},
+		"update summary": {
+			pool:      "hangzhou",
+			summaries: []*unstructured.Unstructured{newTestSummary("hangzhou", &stale)},
+			verbs:     []string{"update"},
+			status:    expected,
+		},
+		"summary is not changed": {
+			pool:      "hangzhou",
+			summaries: []*unstructured.Unstructured{newTestSummary("hangzhou", &current)},
+		},
+		"delete summary of empty node pool": {
+			pool:      "shanghai",
+			summaries: []*unstructured.Unstructured{newTestSummary("shanghai", &current)},
+			verbs:     []string{"delete"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var dynamicObjs []runtime.Object
+			for _, s := range tt.summaries {
+				indexer.Add(s)
+				dynamicObjs = append(dynamicObjs, s.DeepCopy())
+			}
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			for _, node := range nodes {
+				nodeIndexer.Add(node)
+			}
+			podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+				nodeNameKeyIndex: func(obj interface{}) ([]string, error) {
+					return []string{obj.(*v1.Pod).Spec.NodeName}, nil
+				},
+			})
+			for _, pod := range pods {
+				podIndexer.Add(pod)
+			}
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjs...)
+			c := &Controller{
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+				podIndexer:    podIndexer,
+				now:           func() time.Time { return now },
+			}
+
+			if err := c.sync(tt.pool); err != nil {
+				t.Fatalf("failed to sync node pool %s, %v", tt.pool, err)
+			}
+
+			var verbs []string
+			var status *NodePoolSummaryStatus
+			for _, action := range dynamicClient.Actions() {
+				if action.GetVerb() == "get" || action.GetVerb() == "list" {
+					continue
+				}
+				verbs = append(verbs, action.GetVerb())
+				if action.GetSubresource() == "status" {
+					summary, err := toNodePoolSummary(action.(clienttesting.UpdateAction).GetObject())
+					if err != nil {
+						t.Fatalf("failed to convert summary, %v", err)
+					}
+					status = &summary.Status
+				}
+			}
+			if !reflect.DeepEqual(verbs, tt.verbs) {
+				t.Errorf("expect actions %v, but got %v", tt.verbs, verbs)
+			}
+			if tt.status != nil && !reflect.DeepEqual(status, tt.status) {
+				t.Errorf("expect status %#v, but got %#v", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestHubVersionOf(t *testing.T) {
+	testcases := map[string]struct {
+		pod     *v1.Pod
+		version string
+		isHub   bool
+	}{
+		"tagged image": {
+			pod:     newTestHubPod("node1", "openyurt/yurthub:v0.2.0"),
+			version: "v0.2.0",
+			isHub:   true,
+		},
+		"registry with port": {
+			pod:     newTestHubPod("node1", "registry.example.com:5000/openyurt/yurthub"),
+			version: "latest",
+			isHub:   true,
+		},
+		"not yurthub": {
+			pod: newTestPod("default", "web-1", "node1", "web", true),
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			version, isHub := hubVersionOf(tt.pod)
+			if version != tt.version || isHub != tt.isHub {
+				t.Errorf("expect %q(%v), but got %q(%v)", tt.version, tt.isHub, version, isHub)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package poolsummary
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of NodePoolSummary
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "nodepoolsummaries",
+}
+
+const (
+	// AnnotationAutonomy is the annotation of node that enables the autonomy of node
+	AnnotationAutonomy = "node.beta.alibabacloud.com/autonomy"
+	// AnnotationAutonomyReadiness is the annotation of node that yurthub reports the
+	// summary of autonomy readiness in
+	AnnotationAutonomyReadiness = "openyurt.io/autonomy-readiness"
+	// YurtHubNamespace and YurtHubLabelSelector select the yurthub pods, the version of
+	// yurthub on a node is the tag of image of the yurthub container.
+	YurtHubNamespace     = "kube-system"
+	YurtHubLabelSelector = "k8s-app=yurt-hub"
+	YurtHubContainerName = "yurt-hub"
+	// MaxDegradedWorkloadRefs is the max number of degraded workloads that are listed in status
+	MaxDegradedWorkloadRefs = 20
+)
+
+// NodePoolSummary is the aggregated health of the nodes and workloads in a node pool,
+// it's named after the node pool and maintained by the controller only.
+type NodePoolSummary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status NodePoolSummaryStatus `json:"status,omitempty"`
+}
+
+// NodePoolSummaryStatus is the status of NodePoolSummary
+type NodePoolSummaryStatus struct {
+	// Nodes is the number of nodes in the node pool
+	Nodes int `json:"nodes"`
+	// ReadyNodes is the number of nodes whose Ready condition is True
+	ReadyNodes int `json:"readyNodes"`
+	// NotReadyNodes is the number of nodes whose Ready condition is False
+	NotReadyNodes int `json:"notReadyNodes"`
+	// UnreachableNodes is the number of nodes whose Ready condition is Unknown or not reported
+	UnreachableNodes int `json:"unreachableNodes"`
+	// AutonomousNodes is the number of nodes that autonomy is enabled on
+	AutonomousNodes int `json:"autonomousNodes"`
+	// ReadyForAutonomyNodes is the number of nodes that yurthub reports ready for autonomy
+	ReadyForAutonomyNodes int `json:"readyForAutonomyNodes"`
+	// Pods is the number of running or pending pods on the nodes
+	Pods int `json:"pods"`
+	// ReadyPods is the number of ready pods on the nodes
+	ReadyPods int `json:"readyPods"`
+	// Workloads is the number of workloads that own pods on the nodes
+	Workloads int `json:"workloads"`
+	// DegradedWorkloads is the number of workloads that have pods not ready on the nodes
+	DegradedWorkloads int `json:"degradedWorkloads"`
+	// DegradedWorkloadRefs lists the degraded workloads, at most MaxDegradedWorkloadRefs of them
+	DegradedWorkloadRefs []WorkloadReference `json:"degradedWorkloadRefs,omitempty"`
+	// HubVersions is the number of nodes of each yurthub version, unknown version
+	// means yurthub is not found on the node.
+	HubVersions []HubVersion `json:"hubVersions,omitempty"`
+	// LastUpdateTime is the time that the status is changed last time
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// WorkloadReference is a workload with its pods in the node pool
+type WorkloadReference struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Pods and ReadyPods are the number of pods of workload in the node pool
+	Pods      int `json:"pods"`
+	ReadyPods int `json:"readyPods"`
+}
+
+// HubVersion is the number of nodes that run the version of yurthub
+type HubVersion struct {
+	Version string `json:"version"`
+	Nodes   int    `json:"nodes"`
+}
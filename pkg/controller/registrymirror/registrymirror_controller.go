@@ -0,0 +1,416 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrymirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	controllerutil "github.com/alibaba/openyurt/pkg/controller/util"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	batchinformers "k8s.io/client-go/informers/batch/v1"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// Controller keeps the registry mirrors of container runtime on the edge nodes of node pools
+// in sync with NodePoolRegistryMirror. the mirrors are configured by the servant jobs of
+// yurtctl, and the hash of configured mirrors is recorded in the annotation of node, so the
+// jobs are only run on the nodes that mirrors are changed. the work queue is keyed by node
+// pool, so mirrors are removed from the nodes when NodePoolRegistryMirror is deleted or the
+// nodes are moved to another node pool.
+type Controller struct {
+	kubeClient    clientset.Interface
+	dynamicClient dynamic.Interface
+	lister        cache.GenericLister
+	synced        cache.InformerSynced
+	nodeLister    corelisters.NodeLister
+	nodeSynced    cache.InformerSynced
+	jobLister     batchlisters.JobLister
+	jobSynced     cache.InformerSynced
+	queue         workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for NodePoolRegistryMirror
+func NewController(informer informers.GenericInformer,
+	nodeInformer coreinformers.NodeInformer,
+	jobInformer batchinformers.JobInformer,
+	kubeClient clientset.Interface,
+	dynamicClient dynamic.Interface) *Controller {
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		lister:        informer.Lister(),
+		synced:        informer.Informer().HasSynced,
+		nodeLister:    nodeInformer.Lister(),
+		nodeSynced:    nodeInformer.Informer().HasSynced,
+		jobLister:     jobInformer.Lister(),
+		jobSynced:     jobInformer.Informer().HasSynced,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "registrymirror"),
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueMirror,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			c.enqueueMirror(oldObj)
+			c.enqueueMirror(newObj)
+		},
+		DeleteFunc: c.enqueueMirror,
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			c.queue.Add(obj.(*v1.Node).Labels[constants.LabelNodePool])
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, newNode := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if oldNode.Labels[constants.LabelNodePool] != newNode.Labels[constants.LabelNodePool] {
+				c.queue.Add(oldNode.Labels[constants.LabelNodePool])
+				c.queue.Add(newNode.Labels[constants.LabelNodePool])
+			} else if oldNode.Labels[constants.LabelEdgeWorker] != newNode.Labels[constants.LabelEdgeWorker] ||
+				oldNode.Annotations[AnnotationRegistryMirrorsHash] != newNode.Annotations[AnnotationRegistryMirrorsHash] {
+				c.queue.Add(newNode.Labels[constants.LabelNodePool])
+			}
+		},
+	})
+
+	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueueJob,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueueJob(newObj) },
+		DeleteFunc: c.enqueueJob,
+	})
+
+	return c
+}
+
+// Run starts workers to reconcile NodePoolRegistryMirror
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting registry mirror controller")
+	defer klog.Infof("Shutting down registry mirror controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.nodeSynced, c.jobSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueueMirror enqueues the node pool of NodePoolRegistryMirror
+func (c *Controller) enqueueMirror(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+
+	pool, _, _ := unstructured.NestedString(u.Object, "spec", "nodePool")
+	c.queue.Add(pool)
+}
+
+// enqueueJob enqueues the node pool of the servant job that configures registry mirrors
+func (c *Controller) enqueueJob(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		utilruntime.HandleError(fmt.Errorf("unexpected object type %T", obj))
+		return
+	}
+
+	if pool, ok := job.Labels[LabelRegistryMirrorsNodePool]; ok {
+		c.queue.Add(pool)
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync registry mirrors of node pool %q, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(pool string) error {
+	obj, mirror, err := c.mirrorOf(pool)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := c.nodeLister.List(controllerutil.EdgeNodeSelector(pool))
+	if err != nil {
+		return err
+	}
+
+	mirrors := ""
+	if mirror != nil {
+		mirrors = registryMirrorsOf(mirror).String()
+	}
+	hash := mirrorsHash(mirrors)
+
+	var errs []error
+	synced := 0
+	for _, node := range nodes {
+		done, err := c.syncNode(node, pool, mirrors, hash)
+		if err != nil {
+			errs = append(errs, err)
+		} else if done {
+			synced++
+		}
+	}
+
+	if mirror != nil && (mirror.Status.Nodes != len(nodes) || mirror.Status.SyncedNodes != synced) {
+		if err := c.updateStatus(obj, &NodePoolRegistryMirrorStatus{Nodes: len(nodes), SyncedNodes: synced}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// mirrorOf returns the NodePoolRegistryMirror of node pool, the oldest one is used
+// if more than one mirrors are set for the same node pool.
+func (c *Controller) mirrorOf(pool string) (runtime.Object, *NodePoolRegistryMirror, error) {
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var candidates []*NodePoolRegistryMirror
+	mirrorObjs := make(map[string]runtime.Object)
+	for _, obj := range objs {
+		mirror, err := toNodePoolRegistryMirror(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		if mirror.Spec.NodePool != pool {
+			continue
+		}
+		if err := registryMirrorsOf(mirror).Validate(); err != nil {
+			klog.Warningf("registry mirror %s is ignored, %v", mirror.Name, err)
+			continue
+		}
+		candidates = append(candidates, mirror)
+		mirrorObjs[mirror.Name] = obj
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].CreationTimestamp.Equal(&candidates[j].CreationTimestamp) {
+			return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+		}
+		return candidates[i].Name < candidates[j].Name
+	})
+	if len(candidates) > 1 {
+		klog.Warningf("more than one registry mirrors are set for node pool %q, %s is used", pool, candidates[0].Name)
+	}
+
+	return mirrorObjs[candidates[0].Name], candidates[0], nil
+}
+
+// syncNode runs the servant job to configure the mirrors on node, the hash of mirrors is
+// recorded in the annotation of node after the job succeeds. it returns true if the mirrors
+// are configured on node already. empty mirrors remove the mirrors configured by the jobs.
+func (c *Controller) syncNode(node *v1.Node, pool, mirrors, hash string) (bool, error) {
+	applied, ok := node.Annotations[AnnotationRegistryMirrorsHash]
+	if (ok && applied == hash) || (!ok && mirrors == "") {
+		return true, nil
+	}
+
+	params := kubeutil.ServantJobParams{
+		Action:          kubeutil.ServantJobActionMirror,
+		RegistryMirrors: mirrors,
+	}
+	desired, err := kubeutil.NewServantJob(params, node.Name)
+	if err != nil {
+		return false, err
+	}
+
+	job, err := c.jobLister.Jobs(desired.Namespace).Get(desired.Name)
+	if apierrors.IsNotFound(err) {
+		desired.Labels = map[string]string{LabelRegistryMirrorsNodePool: pool}
+		desired.Annotations = map[string]string{AnnotationRegistryMirrorsHash: hash}
+		klog.Infof("configure registry mirrors(%s) on node %s by job %s", mirrors, node.Name, desired.Name)
+		_, err = c.kubeClient.BatchV1().Jobs(desired.Namespace).Create(desired)
+		return false, err
+	} else if err != nil {
+		return false, err
+	}
+
+	// the job is left by the last mirrors or by other node pools
+	if job.Annotations[AnnotationRegistryMirrorsHash] != hash || job.Labels[LabelRegistryMirrorsNodePool] != pool {
+		if job.Status.Active != 0 {
+			// wait for the job to be finished, then it's replaced
+			return false, nil
+		}
+		return false, c.deleteJob(job)
+	}
+
+	switch {
+	case jobFinished(job, batchv1.JobComplete):
+		if err := c.patchNode(node, mirrors, hash); err != nil {
+			return false, err
+		}
+		return true, c.deleteJob(job)
+	case jobFinished(job, batchv1.JobFailed):
+		if err := c.deleteJob(job); err != nil {
+			return false, err
+		}
+		// the job is created again when the node pool is retried
+		return false, fmt.Errorf("job %s failed to configure registry mirrors on node %s", job.Name, node.Name)
+	default:
+		return false, nil
+	}
+}
+
+// patchNode records the hash of mirrors configured on node, the annotation is removed
+// if the mirrors are removed.
+func (c *Controller) patchNode(node *v1.Node, mirrors, hash string) error {
+	var value interface{} = hash
+	if mirrors == "" {
+		value = nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				AnnotationRegistryMirrorsHash: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("registry mirrors(%s) are configured on node %s", mirrors, node.Name)
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+func (c *Controller) deleteJob(job *batchv1.Job) error {
+	err := c.kubeClient.BatchV1().Jobs(job.Namespace).Delete(job.Name, &metav1.DeleteOptions{
+		PropagationPolicy: &kubeutil.PropagationPolicy,
+	})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Controller) updateStatus(obj runtime.Object, status *NodePoolRegistryMirrorStatus) error {
+	u := obj.(*unstructured.Unstructured).DeepCopy()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(SchemeGroupVersionResource).UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// registryMirrorsOf returns the mirrors of NodePoolRegistryMirror in the format of servant jobs
+func registryMirrorsOf(mirror *NodePoolRegistryMirror) kubeutil.RegistryMirrors {
+	mirrors := kubeutil.RegistryMirrors{}
+	for _, m := range mirror.Spec.Mirrors {
+		mirrors[m.Registry] = append(mirrors[m.Registry], m.Endpoints...)
+	}
+	return mirrors
+}
+
+// mirrorsHash returns the hash of formatted mirrors, it's short enough for annotations
+func mirrorsHash(mirrors string) string {
+	sum := sha256.Sum256([]byte(mirrors))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func jobFinished(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == conditionType && cond.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+func toNodePoolRegistryMirror(obj runtime.Object) (*NodePoolRegistryMirror, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	mirror := &NodePoolRegistryMirror{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), mirror); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to registry mirror, %v", u.GetName(), err)
+	}
+
+	return mirror, nil
+}
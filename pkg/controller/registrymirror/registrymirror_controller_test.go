@@ -0,0 +1,291 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrymirror
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+const testMirrors = "docker.io=https://mirror.example.com"
+
+func newTestMirror(name, pool string, created time.Time) *unstructured.Unstructured {
+	m := &NodePoolRegistryMirror{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       "NodePoolRegistryMirror",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Spec: NodePoolRegistryMirrorSpec{
+			NodePool: pool,
+			Mirrors: []RegistryMirror{
+				{Registry: "docker.io", Endpoints: []string{"https://mirror.example.com"}},
+			},
+		},
+	}
+
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(m)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func newTestNode(name, pool string, edge bool, hash string) *v1.Node {
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	if len(pool) != 0 {
+		node.Labels[constants.LabelNodePool] = pool
+	}
+	if edge {
+		node.Labels[constants.LabelEdgeWorker] = "true"
+	}
+	if len(hash) != 0 {
+		node.Annotations = map[string]string{AnnotationRegistryMirrorsHash: hash}
+	}
+	return node
+}
+
+func newTestJob(node, pool, hash string, condition batchv1.JobConditionType) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "yurtctl-servant-mirror-" + node,
+			Namespace:   "kube-system",
+			Labels:      map[string]string{LabelRegistryMirrorsNodePool: pool},
+			Annotations: map[string]string{AnnotationRegistryMirrorsHash: hash},
+		},
+	}
+	if len(condition) != 0 {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: condition, Status: v1.ConditionTrue}}
+	} else {
+		job.Status.Active = 1
+	}
+	return job
+}
+
+func TestSync(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	hash := mirrorsHash(testMirrors)
+	testcases := map[string]struct {
+		pool        string
+		mirrors     []*unstructured.Unstructured
+		nodes       []*v1.Node
+		jobs        []*batchv1.Job
+		expectErr   bool
+		created     []string
+		deleted     []string
+		patches     map[string]string
+		status      int64
+		synced      int64
+		createdEnvs []v1.EnvVar
+	}{
+		"configure mirrors on edge nodes in node pool": {
+			pool:    "hangzhou",
+			mirrors: []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", true, ""),
+				newTestNode("node2", "hangzhou", false, ""),
+				newTestNode("node3", "beijing", true, ""),
+			},
+			created:     []string{"yurtctl-servant-mirror-node1"},
+			patches:     map[string]string{},
+			status:      1,
+			createdEnvs: []v1.EnvVar{{Name: "REGISTRY_MIRRORS", Value: testMirrors}},
+		},
+		"mirrors are configured already": {
+			pool:    "hangzhou",
+			mirrors: []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", true, hash)},
+			patches: map[string]string{},
+			status:  1,
+			synced:  1,
+		},
+		"job is running": {
+			pool:    "hangzhou",
+			mirrors: []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", true, "")},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", hash, "")},
+			patches: map[string]string{},
+			status:  1,
+		},
+		"job is completed": {
+			pool:    "hangzhou",
+			mirrors: []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", true, "")},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", hash, batchv1.JobComplete)},
+			deleted: []string{"yurtctl-servant-mirror-node1"},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/registry-mirrors-hash":"` + hash + `"}}}`,
+			},
+			status: 1,
+			synced: 1,
+		},
+		"job is failed": {
+			pool:      "hangzhou",
+			mirrors:   []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes:     []*v1.Node{newTestNode("node1", "hangzhou", true, "")},
+			jobs:      []*batchv1.Job{newTestJob("node1", "hangzhou", hash, batchv1.JobFailed)},
+			expectErr: true,
+			deleted:   []string{"yurtctl-servant-mirror-node1"},
+			patches:   map[string]string{},
+			status:    1,
+		},
+		"job of old mirrors is replaced": {
+			pool:    "hangzhou",
+			mirrors: []*unstructured.Unstructured{newTestMirror("foo", "hangzhou", created)},
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", true, "")},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", "0123456789abcdef", batchv1.JobComplete)},
+			deleted: []string{"yurtctl-servant-mirror-node1"},
+			patches: map[string]string{},
+			status:  1,
+		},
+		"remove mirrors from nodes": {
+			pool: "hangzhou",
+			nodes: []*v1.Node{
+				newTestNode("node1", "hangzhou", true, hash),
+				newTestNode("node2", "hangzhou", true, ""),
+			},
+			created: []string{"yurtctl-servant-mirror-node1"},
+			patches: map[string]string{},
+		},
+		"mirrors are removed from nodes": {
+			pool:    "hangzhou",
+			nodes:   []*v1.Node{newTestNode("node1", "hangzhou", true, hash)},
+			jobs:    []*batchv1.Job{newTestJob("node1", "hangzhou", mirrorsHash(""), batchv1.JobComplete)},
+			deleted: []string{"yurtctl-servant-mirror-node1"},
+			patches: map[string]string{
+				"node1": `{"metadata":{"annotations":{"openyurt.io/registry-mirrors-hash":null}}}`,
+			},
+		},
+		"configure mirrors on edge nodes without node pool": {
+			mirrors: []*unstructured.Unstructured{
+				newTestMirror("foo", "", created),
+				newTestMirror("bar", "hangzhou", created),
+			},
+			nodes: []*v1.Node{
+				newTestNode("node1", "", true, ""),
+				newTestNode("node2", "hangzhou", true, ""),
+			},
+			created: []string{"yurtctl-servant-mirror-node1"},
+			patches: map[string]string{},
+			status:  1,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var dynamicObjs []runtime.Object
+			for _, m := range tt.mirrors {
+				indexer.Add(m)
+				dynamicObjs = append(dynamicObjs, m.DeepCopy())
+			}
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			jobIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			var objs []runtime.Object
+			for _, node := range tt.nodes {
+				nodeIndexer.Add(node)
+				objs = append(objs, node.DeepCopy())
+			}
+			for _, job := range tt.jobs {
+				jobIndexer.Add(job)
+				objs = append(objs, job.DeepCopy())
+			}
+			kubeClient := fake.NewSimpleClientset(objs...)
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), dynamicObjs...)
+			c := &Controller{
+				kubeClient:    kubeClient,
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+				jobLister:     batchlisters.NewJobLister(jobIndexer),
+			}
+
+			err := c.sync(tt.pool)
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("expect error %v, but got %v", tt.expectErr, err)
+			}
+
+			var created, deleted []string
+			patches := make(map[string]string)
+			for _, action := range kubeClient.Actions() {
+				switch action.GetVerb() {
+				case "create":
+					job := action.(clienttesting.CreateAction).GetObject().(*batchv1.Job)
+					created = append(created, job.Name)
+					if job.Labels[LabelRegistryMirrorsNodePool] != tt.pool {
+						t.Errorf("expect node pool %q of job, but got %q", tt.pool, job.Labels[LabelRegistryMirrorsNodePool])
+					}
+					if tt.createdEnvs == nil {
+						continue
+					}
+					var envs []v1.EnvVar
+					for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+						if env.Name == "REGISTRY_MIRRORS" {
+							envs = append(envs, env)
+						}
+					}
+					if !reflect.DeepEqual(envs, tt.createdEnvs) {
+						t.Errorf("expect envs %v of job, but got %v", tt.createdEnvs, envs)
+					}
+				case "delete":
+					deleted = append(deleted, action.(clienttesting.DeleteAction).GetName())
+				case "patch":
+					patch := action.(clienttesting.PatchAction)
+					patches[patch.GetName()] = string(patch.GetPatch())
+				}
+			}
+			if !reflect.DeepEqual(created, tt.created) {
+				t.Errorf("expect created jobs %v, but got %v", tt.created, created)
+			}
+			if !reflect.DeepEqual(deleted, tt.deleted) {
+				t.Errorf("expect deleted jobs %v, but got %v", tt.deleted, deleted)
+			}
+			if !reflect.DeepEqual(patches, tt.patches) {
+				t.Errorf("expect patches %v, but got %v", tt.patches, patches)
+			}
+
+			if tt.status == 0 {
+				return
+			}
+			u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get(tt.mirrors[0].GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get registry mirror, %v", err)
+			}
+			if nodes, _, _ := unstructured.NestedInt64(u.Object, "status", "nodes"); nodes != tt.status {
+				t.Errorf("expect status nodes %d, but got %d", tt.status, nodes)
+			}
+			if synced, _, _ := unstructured.NestedInt64(u.Object, "status", "syncedNodes"); synced != tt.synced {
+				t.Errorf("expect status synced nodes %d, but got %d", tt.synced, synced)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registrymirror
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of NodePoolRegistryMirror
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "nodepoolregistrymirrors",
+}
+
+const (
+	// AnnotationRegistryMirrorsHash is the annotation of node for the hash of the registry
+	// mirrors that are configured on the node, and the annotation of the servant job for
+	// the hash of the registry mirrors that the job configures.
+	AnnotationRegistryMirrorsHash = "openyurt.io/registry-mirrors-hash"
+	// LabelRegistryMirrorsNodePool is the label of servant job for the node pool of the node
+	// that the job configures registry mirrors on.
+	LabelRegistryMirrorsNodePool = "openyurt.io/registry-mirrors-node-pool"
+)
+
+// NodePoolRegistryMirror is the registry mirrors of container runtime on the edge nodes of a
+// node pool, the mirrors are configured on the nodes by servant jobs.
+type NodePoolRegistryMirror struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodePoolRegistryMirrorSpec   `json:"spec"`
+	Status NodePoolRegistryMirrorStatus `json:"status,omitempty"`
+}
+
+// NodePoolRegistryMirrorSpec is the spec of NodePoolRegistryMirror
+type NodePoolRegistryMirrorSpec struct {
+	// NodePool is the node pool that the mirrors are configured on,
+	// empty means the edge nodes that don't belong to any node pool.
+	NodePool string `json:"nodePool,omitempty"`
+	// Mirrors are the mirrors of registries, docker only supports the mirrors of docker.io
+	Mirrors []RegistryMirror `json:"mirrors"`
+}
+
+// RegistryMirror is the mirror endpoints of a registry
+type RegistryMirror struct {
+	// Registry is the host of registry, like docker.io or registry.example.com:5000
+	Registry string `json:"registry"`
+	// Endpoints are the urls of mirrors, they are tried in order before the registry
+	Endpoints []string `json:"endpoints"`
+}
+
+// NodePoolRegistryMirrorStatus is the status of NodePoolRegistryMirror
+type NodePoolRegistryMirrorStatus struct {
+	// Nodes is the number of edge nodes in the node pool
+	Nodes int `json:"nodes"`
+	// SyncedNodes is the number of edge nodes that the mirrors are configured on
+	SyncedNodes int `json:"syncedNodes"`
+}
@@ -0,0 +1,345 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package strictbinding
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	nodeutil "github.com/alibaba/openyurt/pkg/controller/util/node"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// AnnotationStrictNodeBinding is the annotation of pod that the pod must not be
+// run on another node while its node is autonomous but unreachable.
+const AnnotationStrictNodeBinding = "openyurt.io/strict-node-binding"
+
+// AnnotationStrictNodeBindings is the annotation of node for the keys(namespace/name,
+// separated by comma) of strict node binding pods that are bound to the node.
+const AnnotationStrictNodeBindings = "openyurt.io/strict-node-bindings"
+
+// Controller prevents the replacement of a strict node binding pod from being scheduled
+// to another node, while the node of the original pod is autonomous but unreachable. the
+// original pod may be still running on the node, so a replacement on another node leads
+// to split-brain of stateful workloads like edge databases.
+//
+// the replacement is recognized by the name of pod(like the pods of StatefulSet). when a
+// node becomes autonomous but unreachable, the strict node binding pods on it are recorded
+// in the annotation of node, so the records survive restarts of controller. the scheduler
+// extender filters out other nodes for the pods that are recorded, and the controller binds
+// the replacement to the node before it's scheduled, the replacement starts after the node
+// is reachable again. the records are dropped when the node is ready again.
+type Controller struct {
+	kubeClient clientset.Interface
+	podLister  corelisters.PodLister
+	podSynced  cache.InformerSynced
+	nodeLister corelisters.NodeLister
+	nodeSynced cache.InformerSynced
+	recorder   record.EventRecorder
+	// queue is keyed by the name of node
+	queue workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for strict node binding pods
+func NewController(podInformer coreinformers.PodInformer,
+	nodeInformer coreinformers.NodeInformer,
+	kubeClient clientset.Interface) *Controller {
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(klog.Infof)
+	eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+
+	c := &Controller{
+		kubeClient: kubeClient,
+		podLister:  podInformer.Lister(),
+		podSynced:  podInformer.Informer().HasSynced,
+		nodeLister: nodeInformer.Lister(),
+		nodeSynced: nodeInformer.Informer().HasSynced,
+		recorder:   eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "strict-node-binding-controller"}),
+		queue:      workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "strictnodebinding"),
+	}
+
+	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueuePod,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueuePod(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			c.enqueuePod(obj)
+		},
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueNode,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueueNode(newObj)
+		},
+	})
+
+	return c
+}
+
+// Run starts workers to bind the replacements of strict node binding pods
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting strict node binding controller")
+	defer klog.Infof("Shutting down strict node binding controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.podSynced, c.nodeSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+// enqueueNode enqueues the node that is autonomous but unreachable, or has strict node bindings
+func (c *Controller) enqueueNode(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+	if isAutonomousButUnreachable(node) || len(BindingsOf(node)) != 0 {
+		c.queue.Add(node.Name)
+	}
+}
+
+// enqueuePod enqueues the node of strict node binding pod, or the node that the
+// pod is bound to if the pod is not scheduled.
+func (c *Controller) enqueuePod(obj interface{}) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok || !isStrictNodeBinding(pod) {
+		return
+	}
+
+	if len(pod.Spec.NodeName) != 0 {
+		c.queue.Add(pod.Spec.NodeName)
+		return
+	}
+
+	nodeName, err := BoundNodeOf(c.nodeLister, pod)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get bound node of pod %s/%s: %v", pod.Namespace, pod.Name, err))
+		return
+	}
+	if len(nodeName) != 0 {
+		c.queue.Add(nodeName)
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync strict node bindings of node %s, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+// sync records the strict node binding pods on the node while it's autonomous but unreachable,
+// binds the replacements of recorded pods to the node, and drops the records when the node is
+// reachable again.
+func (c *Controller) sync(nodeName string) error {
+	node, err := c.nodeLister.Get(nodeName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	unreachable := isAutonomousButUnreachable(node)
+	bindings := BindingsOf(node)
+	if unreachable {
+		pods, err := c.podLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods {
+			if pod.Spec.NodeName == nodeName && isStrictNodeBinding(pod) && pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+				bindings.Insert(pod.Namespace + "/" + pod.Name)
+			}
+		}
+	}
+
+	desired := sets.NewString()
+	for _, key := range bindings.List() {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		pod, err := c.podLister.Pods(namespace).Get(name)
+		if apierrors.IsNotFound(err) {
+			// the replacement is not created yet
+			if unreachable {
+				desired.Insert(key)
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		switch pod.Spec.NodeName {
+		case nodeName:
+			if unreachable {
+				desired.Insert(key)
+			}
+		case "":
+			if unreachable || isReady(node) {
+				if err := c.bind(pod, nodeName); err != nil {
+					return err
+				}
+			}
+			if unreachable {
+				desired.Insert(key)
+			}
+		default:
+			// the replacement is scheduled without the scheduler extender
+			c.recorder.Eventf(pod, v1.EventTypeWarning, "StrictNodeBindingViolated",
+				"Pod is scheduled to node %s, while its previous node %s is autonomous but unreachable", pod.Spec.NodeName, nodeName)
+		}
+	}
+
+	return c.updateBindings(node, desired)
+}
+
+func (c *Controller) bind(pod *v1.Pod, nodeName string) error {
+	binding := &v1.Binding{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+		Target:     v1.ObjectReference{Kind: "Node", Name: nodeName},
+	}
+	if err := c.kubeClient.CoreV1().Pods(pod.Namespace).Bind(binding); err != nil {
+		return err
+	}
+
+	klog.Infof("pod %s/%s is bound to node %s by strict node binding", pod.Namespace, pod.Name, nodeName)
+	c.recorder.Eventf(pod, v1.EventTypeNormal, "StrictNodeBinding",
+		"Pod is bound to node %s, because the node is autonomous but unreachable", nodeName)
+	return nil
+}
+
+// updateBindings patches the annotation of strict node bindings of node, the annotation
+// is removed if there is no binding.
+func (c *Controller) updateBindings(node *v1.Node, bindings sets.String) error {
+	if BindingsOf(node).Equal(bindings) {
+		return nil
+	}
+
+	var value interface{}
+	if bindings.Len() != 0 {
+		value = strings.Join(bindings.List(), ",")
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{
+				AnnotationStrictNodeBindings: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("update strict node bindings of node %s to %v", node.Name, bindings.List())
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// BindingsOf returns the keys of strict node binding pods that are bound to the node
+func BindingsOf(node *v1.Node) sets.String {
+	bindings := sets.NewString()
+	for _, key := range strings.Split(node.Annotations[AnnotationStrictNodeBindings], ",") {
+		if key = strings.TrimSpace(key); len(key) != 0 {
+			bindings.Insert(key)
+		}
+	}
+	return bindings
+}
+
+// BoundNodeOf returns the node that the strict node binding pod is bound to, an empty
+// name is returned if the pod is not bound.
+func BoundNodeOf(nodeLister corelisters.NodeLister, pod *v1.Pod) (string, error) {
+	if !isStrictNodeBinding(pod) {
+		return "", nil
+	}
+
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+
+	key := pod.Namespace + "/" + pod.Name
+	for _, node := range nodes {
+		if BindingsOf(node).Has(key) {
+			return node.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func isStrictNodeBinding(pod *v1.Pod) bool {
+	return pod.Annotations != nil && pod.Annotations[AnnotationStrictNodeBinding] == "true"
+}
+
+// isAutonomousButUnreachable checks that pods are not evicted from the node, and the node is not ready
+func isAutonomousButUnreachable(node *v1.Node) bool {
+	return scheduler.IsEvictionDisabled(node) && !isReady(node)
+}
+
+func isReady(node *v1.Node) bool {
+	_, condition := nodeutil.GetNodeCondition(&node.Status, v1.NodeReady)
+	return condition != nil && condition.Status == v1.ConditionTrue
+}
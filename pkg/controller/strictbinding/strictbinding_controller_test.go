@@ -0,0 +1,208 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package strictbinding
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+func newTestNode(name string, autonomous bool, ready v1.ConditionStatus, bindings ...string) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: map[string]string{}},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}},
+		},
+	}
+	if autonomous {
+		node.Annotations[scheduler.AnnotationKeyNodeAutonomy] = "true"
+	}
+	if len(bindings) != 0 {
+		node.Annotations[AnnotationStrictNodeBindings] = strings.Join(bindings, ",")
+	}
+	return node
+}
+
+func newTestPod(name, nodeName string, strict bool) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+	if strict {
+		pod.Annotations = map[string]string{AnnotationStrictNodeBinding: "true"}
+	}
+	return pod
+}
+
+func newTestController(nodes []*v1.Node, pods []*v1.Pod) (*Controller, *fake.Clientset, *record.FakeRecorder) {
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	var objs []runtime.Object
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+		objs = append(objs, node.DeepCopy())
+	}
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, pod := range pods {
+		podIndexer.Add(pod)
+		objs = append(objs, pod.DeepCopy())
+	}
+
+	kubeClient := fake.NewSimpleClientset(objs...)
+	recorder := record.NewFakeRecorder(10)
+	return &Controller{
+		kubeClient: kubeClient,
+		podLister:  corelisters.NewPodLister(podIndexer),
+		nodeLister: corelisters.NewNodeLister(nodeIndexer),
+		recorder:   recorder,
+	}, kubeClient, recorder
+}
+
+func TestSync(t *testing.T) {
+	testcases := map[string]struct {
+		node           *v1.Node
+		pods           []*v1.Pod
+		expectBindings []string
+		bound          bool
+		reason         string
+	}{
+		"record strict pod on autonomous but unreachable node": {
+			node:           newTestNode("node1", true, v1.ConditionUnknown),
+			pods:           []*v1.Pod{newTestPod("db-0", "node1", true), newTestPod("web-0", "node1", false)},
+			expectBindings: []string{"default/db-0"},
+		},
+		"node is not autonomous": {
+			node: newTestNode("node1", false, v1.ConditionUnknown),
+			pods: []*v1.Pod{newTestPod("db-0", "node1", true)},
+		},
+		"node is ready": {
+			node: newTestNode("node1", true, v1.ConditionTrue),
+			pods: []*v1.Pod{newTestPod("db-0", "node1", true)},
+		},
+		"keep binding of deleted pod": {
+			node:           newTestNode("node1", true, v1.ConditionUnknown, "default/db-0"),
+			expectBindings: []string{"default/db-0"},
+		},
+		"bind replacement to unreachable node": {
+			node:           newTestNode("node1", true, v1.ConditionUnknown, "default/db-0"),
+			pods:           []*v1.Pod{newTestPod("db-0", "", true)},
+			expectBindings: []string{"default/db-0"},
+			bound:          true,
+			reason:         "StrictNodeBinding",
+		},
+		"bind replacement when node is ready again": {
+			node:   newTestNode("node1", true, v1.ConditionTrue, "default/db-0"),
+			pods:   []*v1.Pod{newTestPod("db-0", "", true)},
+			bound:  true,
+			reason: "StrictNodeBinding",
+		},
+		"drop binding when node is ready again": {
+			node: newTestNode("node1", true, v1.ConditionTrue, "default/db-0"),
+			pods: []*v1.Pod{newTestPod("db-0", "node1", true)},
+		},
+		"replacement is scheduled to another node": {
+			node:   newTestNode("node1", true, v1.ConditionUnknown, "default/db-0"),
+			pods:   []*v1.Pod{newTestPod("db-0", "node2", true)},
+			reason: "StrictNodeBindingViolated",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c, kubeClient, recorder := newTestController([]*v1.Node{tt.node}, tt.pods)
+
+			if err := c.sync("node1"); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			bound := false
+			for _, action := range kubeClient.Actions() {
+				if create, ok := action.(clienttesting.CreateAction); ok && create.GetSubresource() == "binding" {
+					binding := create.GetObject().(*v1.Binding)
+					bound = binding.Name == "db-0" && binding.Target.Name == "node1"
+				}
+			}
+			if bound != tt.bound {
+				t.Errorf("expect bound %v, but got %v", tt.bound, bound)
+			}
+
+			if len(tt.reason) == 0 {
+				if len(recorder.Events) != 0 {
+					t.Errorf("expect no event, but got %s", <-recorder.Events)
+				}
+			} else if event := <-recorder.Events; !strings.Contains(event, tt.reason+" ") {
+				t.Errorf("expect event %s, but got %s", tt.reason, event)
+			}
+
+			// the fake client doesn't remove annotations by patch, so the bindings are read from the patch
+			bindings := BindingsOf(tt.node)
+			for _, action := range kubeClient.Actions() {
+				if patch, ok := action.(clienttesting.PatchAction); ok && patch.GetResource().Resource == "nodes" {
+					var node v1.Node
+					if err := json.Unmarshal(patch.GetPatch(), &node); err != nil {
+						t.Fatalf("failed to decode patch, %v", err)
+					}
+					bindings = BindingsOf(&node)
+				}
+			}
+			if !bindings.Equal(sets.NewString(tt.expectBindings...)) {
+				t.Errorf("expect bindings %v, but got %v", tt.expectBindings, bindings.List())
+			}
+		})
+	}
+}
+
+func TestBoundNodeOf(t *testing.T) {
+	nodes := []*v1.Node{
+		newTestNode("node1", true, v1.ConditionUnknown, "default/db-0"),
+		newTestNode("node2", true, v1.ConditionTrue),
+	}
+	c, _, _ := newTestController(nodes, nil)
+
+	testcases := map[string]struct {
+		pod    *v1.Pod
+		expect string
+	}{
+		"pod is bound":              {pod: newTestPod("db-0", "", true), expect: "node1"},
+		"pod is not bound":          {pod: newTestPod("db-1", "", true)},
+		"pod is not strict binding": {pod: newTestPod("db-0", "", false)},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			nodeName, err := BoundNodeOf(c.nodeLister, tt.pod)
+			if err != nil {
+				t.Fatalf("failed to get bound node, %v", err)
+			}
+			if nodeName != tt.expect {
+				t.Errorf("expect bound node %q, but got %q", tt.expect, nodeName)
+			}
+		})
+	}
+}
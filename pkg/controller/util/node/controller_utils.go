@@ -44,6 +44,12 @@ import (
 // and return true if any pods were deleted, or were found pending
 // deletion.
 func DeletePods(kubeClient clientset.Interface, recorder record.EventRecorder, nodeName, nodeUID string, daemonStore appsv1listers.DaemonSetLister) (bool, error) {
+	return DeletePodsWithFilter(kubeClient, recorder, nodeName, nodeUID, daemonStore, nil)
+}
+
+// DeletePodsWithFilter is the same as DeletePods, except that the pods that
+// filter returns false for are kept on the node. nil filter keeps no pods.
+func DeletePodsWithFilter(kubeClient clientset.Interface, recorder record.EventRecorder, nodeName, nodeUID string, daemonStore appsv1listers.DaemonSetLister, filter func(pod *v1.Pod) bool) (bool, error) {
 	remaining := false
 	selector := fields.OneTermEqualSelector(api.PodHostField, nodeName).String()
 	options := metav1.ListOptions{FieldSelector: selector}
@@ -63,6 +69,10 @@ func DeletePods(kubeClient clientset.Interface, recorder record.EventRecorder, n
 		if pod.Spec.NodeName != nodeName {
 			continue
 		}
+		if filter != nil && !filter(&pod) {
+			klog.V(2).Infof("Keeping pod %v/%v on node %s", pod.Namespace, pod.Name, nodeName)
+			continue
+		}
 
 		// Set reason and message in the pod object.
 		if _, err = SetPodTerminationReason(kubeClient, &pod, nodeName); err != nil {
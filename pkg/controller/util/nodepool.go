@@ -0,0 +1,37 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// EdgeNodeSelector selects the edge nodes of node pool, the edge nodes that don't
+// belong to any node pool are selected for empty node pool.
+func EdgeNodeSelector(pool string) labels.Selector {
+	edgeReq, _ := labels.NewRequirement(constants.LabelEdgeWorker, selection.Equals, []string{"true"})
+	if len(pool) != 0 {
+		poolReq, _ := labels.NewRequirement(constants.LabelNodePool, selection.Equals, []string{pool})
+		return labels.NewSelector().Add(*edgeReq, *poolReq)
+	}
+
+	poolReq, _ := labels.NewRequirement(constants.LabelNodePool, selection.DoesNotExist, nil)
+	return labels.NewSelector().Add(*edgeReq, *poolReq)
+}
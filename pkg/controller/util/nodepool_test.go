@@ -0,0 +1,60 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestEdgeNodeSelector(t *testing.T) {
+	testcases := map[string]struct {
+		pool   string
+		labels map[string]string
+		match  bool
+	}{
+		"edge node of pool": {
+			pool:   "hangzhou",
+			labels: map[string]string{constants.LabelEdgeWorker: "true", constants.LabelNodePool: "hangzhou"},
+			match:  true,
+		},
+		"edge node of another pool": {
+			pool:   "hangzhou",
+			labels: map[string]string{constants.LabelEdgeWorker: "true", constants.LabelNodePool: "beijing"},
+		},
+		"cloud node of pool": {
+			pool:   "hangzhou",
+			labels: map[string]string{constants.LabelEdgeWorker: "false", constants.LabelNodePool: "hangzhou"},
+		},
+		"edge node without pool": {
+			labels: map[string]string{constants.LabelEdgeWorker: "true"},
+			match:  true,
+		},
+		"edge node of pool for empty pool": {
+			labels: map[string]string{constants.LabelEdgeWorker: "true", constants.LabelNodePool: "hangzhou"},
+		},
+	}
+
+	for name, tc := range testcases {
+		if match := EdgeNodeSelector(tc.pool).Matches(labels.Set(tc.labels)); match != tc.match {
+			t.Errorf("%s: expect match %v, but got %v", name, tc.match, match)
+		}
+	}
+}
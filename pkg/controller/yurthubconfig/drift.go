@@ -0,0 +1,157 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/selfreport"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+const (
+	// NodeConfigDrifted is the condition of node that tells whether yurthub on the node
+	// drifts from the desired version and flags of YurtHubConfiguration, upgrade
+	// controllers select the nodes to upgrade by this condition.
+	NodeConfigDrifted v1.NodeConditionType = "YurtHubConfigDrifted"
+
+	reasonConfigDrifted = "ConfigDrifted"
+	reasonConfigInSync  = "ConfigInSync"
+	// nodePoolFlag is the flag of yurthub that the node pool of node is reported by
+	nodePoolFlag = "node-pool"
+)
+
+// syncDrift compares the version and flags reported by yurthubs in the node pool with the
+// desired ones, updates the drift condition of nodes and returns the drifted nodes. nodes
+// that don't report are skipped, the node pool of them is not known. the condition is added
+// when a node drifts, and it's set to false when the node is back in sync.
+func (c *Controller) syncDrift(hubCfg *YurtHubConfiguration) ([]string, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []string
+	for _, node := range nodes {
+		version, ok := node.Annotations[selfreport.VersionAnnotation]
+		if !ok {
+			continue
+		}
+		flags, err := selfreport.ParseFlags(node.Annotations[selfreport.FlagsAnnotation])
+		if err != nil {
+			klog.Errorf("failed to parse reported yurthub flags of node %s, %v", node.Name, err)
+			continue
+		}
+		if flags[nodePoolFlag] != hubCfg.Spec.NodePool {
+			continue
+		}
+
+		diffs := configDiffs(&hubCfg.Spec, version, flags)
+		if len(diffs) != 0 {
+			drifted = append(drifted, node.Name)
+		}
+		if err := c.updateDriftCondition(node, diffs); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(drifted)
+
+	driftedNodes.WithLabelValues(hubCfg.Name).Set(float64(len(drifted)))
+	return drifted, nil
+}
+
+// configDiffs returns the differences between the reported version and flags and the desired ones
+func configDiffs(spec *YurtHubConfigurationSpec, version string, flags map[string]string) []string {
+	var diffs []string
+	if len(spec.Version) != 0 && version != spec.Version {
+		diffs = append(diffs, fmt.Sprintf("version is %s, desired %s", version, spec.Version))
+	}
+
+	names := make([]string, 0, len(spec.Flags))
+	for name := range spec.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, ok := flags[name]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("flag --%s is not set, desired %q", name, spec.Flags[name]))
+		} else if value != spec.Flags[name] {
+			diffs = append(diffs, fmt.Sprintf("flag --%s is %q, desired %q", name, value, spec.Flags[name]))
+		}
+	}
+	return diffs
+}
+
+// updateDriftCondition patches the drift condition of node when it's changed
+func (c *Controller) updateDriftCondition(node *v1.Node, diffs []string) error {
+	condition := v1.NodeCondition{
+		Type:    NodeConfigDrifted,
+		Status:  v1.ConditionFalse,
+		Reason:  reasonConfigInSync,
+		Message: "yurthub is in sync with the desired configuration",
+	}
+	if len(diffs) != 0 {
+		condition.Status = v1.ConditionTrue
+		condition.Reason = reasonConfigDrifted
+		condition.Message = strings.Join(diffs, "; ")
+	}
+
+	var current *v1.NodeCondition
+	for i := range node.Status.Conditions {
+		if node.Status.Conditions[i].Type == NodeConfigDrifted {
+			current = &node.Status.Conditions[i]
+			break
+		}
+	}
+	if current == nil && condition.Status == v1.ConditionFalse {
+		return nil
+	}
+	if current != nil && current.Status == condition.Status && current.Reason == condition.Reason && current.Message == condition.Message {
+		return nil
+	}
+
+	now := metav1.Now()
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+	if current != nil && current.Status == condition.Status {
+		condition.LastTransitionTime = current.LastTransitionTime
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []v1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if condition.Status == v1.ConditionTrue {
+		klog.Infof("yurthub on node %s drifts from the desired configuration, %s", node.Name, condition.Message)
+	}
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, patch, "status")
+	return err
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/klog"
+)
+
+// syncMaintenance projects the maintenance of node pools to annotations of their nodes,
+// the annotations are removed from the nodes of node pools that are not in maintenance.
+// the annotations are kept after the maintenance ends, they are checked with the time.
+func (c *Controller) syncMaintenance() error {
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	maintenance := maintenanceOf(objs)
+	for _, node := range nodes {
+		if err := c.syncMaintenanceNode(node, desiredMaintenanceAnnotations(node, maintenance)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncMaintenanceNode patches the maintenance annotations of node, the annotations that
+// are not desired are removed from the node.
+func (c *Controller) syncMaintenanceNode(node *v1.Node, desired map[string]string) error {
+	annotations := make(map[string]interface{})
+	for _, key := range []string{scheduler.AnnotationKeyMaintenance, scheduler.AnnotationKeyMaintenanceUntil} {
+		value, ok := node.Annotations[key]
+		if desiredValue, desiredOk := desired[key]; desiredOk && (!ok || value != desiredValue) {
+			annotations[key] = desiredValue
+		} else if !desiredOk && ok {
+			annotations[key] = nil
+		}
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("update maintenance of node %s to %v", node.Name, desired)
+	_, err = c.kubeClient.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// maintenanceOf returns the maintenance of node pools that is set by YurtHubConfigurations
+func maintenanceOf(objs []runtime.Object) map[string]*hubconfig.Maintenance {
+	maintenance := make(map[string]*hubconfig.Maintenance)
+	for _, obj := range objs {
+		hubCfg, err := toYurtHubConfiguration(obj)
+		if err != nil {
+			utilruntime.HandleError(err)
+			continue
+		}
+		if m := toMaintenance(hubCfg.Spec.Maintenance); m != nil {
+			maintenance[hubCfg.Spec.NodePool] = m
+		}
+	}
+	return maintenance
+}
+
+// desiredMaintenanceAnnotations returns the maintenance annotations of node for the
+// maintenance of its node pool.
+func desiredMaintenanceAnnotations(node *v1.Node, maintenance map[string]*hubconfig.Maintenance) map[string]string {
+	annotations := make(map[string]string)
+	m, ok := maintenance[node.Labels[constants.LabelNodePool]]
+	if !ok {
+		return annotations
+	}
+
+	annotations[scheduler.AnnotationKeyMaintenance] = m.Reason
+	if !m.Until.IsZero() {
+		annotations[scheduler.AnnotationKeyMaintenanceUntil] = m.Until.UTC().Format(time.RFC3339)
+	}
+	return annotations
+}
+
+// maintenanceAnnotationsOf returns the maintenance annotations that are set on node
+func maintenanceAnnotationsOf(node *v1.Node) map[string]string {
+	annotations := make(map[string]string)
+	for _, key := range []string{scheduler.AnnotationKeyMaintenance, scheduler.AnnotationKeyMaintenanceUntil} {
+		if value, ok := node.Annotations[key]; ok {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+func toMaintenance(spec *MaintenanceSpec) *hubconfig.Maintenance {
+	if spec == nil || len(spec.Reason) == 0 {
+		return nil
+	}
+
+	m := &hubconfig.Maintenance{Reason: spec.Reason}
+	if spec.Until != nil {
+		m.Until = spec.Until.Time
+	}
+	return m
+}
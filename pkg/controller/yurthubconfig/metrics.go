@@ -0,0 +1,43 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	driftedNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_config",
+			Name:      "drifted_nodes",
+			Help:      "Gauge measuring number of nodes that yurthub drifts from the desired version and flags per YurtHubConfiguration.",
+		},
+		[]string{"yurthub_configuration"},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics that are to be monitored.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(driftedNodes)
+	})
+}
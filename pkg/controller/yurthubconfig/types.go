@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemeGroupVersionResource is the resource of YurtHubConfiguration
+var SchemeGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apps.openyurt.io",
+	Version:  "v1alpha1",
+	Resource: "yurthubconfigurations",
+}
+
+// YurtHubConfigurationKind is the kind of YurtHubConfiguration
+const YurtHubConfigurationKind = "YurtHubConfiguration"
+
+// LabelYurtHubConfiguration is the label of configmaps that are projected from YurtHubConfiguration,
+// the value is the name of YurtHubConfiguration.
+const LabelYurtHubConfiguration = "openyurt.io/yurthub-configuration"
+
+// YurtHubConfiguration is the settings of yurthubs in a node pool, the settings
+// are projected to a configmap that the yurthubs in the node pool watch.
+type YurtHubConfiguration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   YurtHubConfigurationSpec   `json:"spec"`
+	Status YurtHubConfigurationStatus `json:"status,omitempty"`
+}
+
+// YurtHubConfigurationSpec is the spec of YurtHubConfiguration
+type YurtHubConfigurationSpec struct {
+	// NodePool is the node pool that the settings are applied to,
+	// empty means the nodes that don't belong to any node pool.
+	NodePool string `json:"nodePool,omitempty"`
+
+	YurtHubSettings `json:",inline"`
+
+	// Version is the desired version of yurthub, the nodes that report another
+	// version are drifted, empty means the version is not checked.
+	Version string `json:"version,omitempty"`
+	// Flags is the desired flags of yurthub in the static pod manifest, the nodes
+	// that report other values of the flags are drifted.
+	Flags map[string]string `json:"flags,omitempty"`
+
+	// Canary is the settings that are rolled out to a percent of nodes in
+	// the node pool before they are set as the settings of all nodes.
+	Canary *CanarySpec `json:"canary,omitempty"`
+
+	// Maintenance is the planned maintenance(like a WAN outage) of the node pool,
+	// yurthubs pre-refresh caches aggressively and the unreachable nodes are neither
+	// alerted nor evicted while it's set.
+	Maintenance *MaintenanceSpec `json:"maintenance,omitempty"`
+}
+
+// YurtHubSettings is the settings of yurthub
+type YurtHubSettings struct {
+	// CacheAgents is the components that responses of their requests are cached by yurthub
+	CacheAgents []string `json:"cacheAgents,omitempty"`
+	// AggregatedAPICacheGroups is the aggregated api groups that responses can be cached
+	AggregatedAPICacheGroups []string `json:"aggregatedAPICacheGroups,omitempty"`
+	// MaxRequestsInFlight is the maximum number of parallel requests of yurthub
+	MaxRequestsInFlight int `json:"maxRequestsInFlight,omitempty"`
+}
+
+// CanarySpec is the spec of canary rollout for yurthub settings
+type CanarySpec struct {
+	YurtHubSettings `json:",inline"`
+
+	// Percent is the percent of nodes that the canary settings are applied to
+	Percent int `json:"percent"`
+	// MaxErrorPercent is the maximum percent of failed requests on a node,
+	// the canary settings are rolled back on the node when it is exceeded.
+	MaxErrorPercent int `json:"maxErrorPercent,omitempty"`
+	// MaxFailedNodes is the number of nodes that the canary settings are
+	// rolled back on before the rollout is halted, default is 1.
+	MaxFailedNodes int `json:"maxFailedNodes,omitempty"`
+}
+
+// MaintenanceSpec is the spec of planned maintenance of node pool
+type MaintenanceSpec struct {
+	// Reason is why the node pool is in maintenance, it's required
+	Reason string `json:"reason"`
+	// Until is the time that the maintenance ends, the maintenance
+	// doesn't end by itself when it's not set.
+	Until *metav1.Time `json:"until,omitempty"`
+}
+
+// YurtHubConfigurationStatus is the status of YurtHubConfiguration
+type YurtHubConfigurationStatus struct {
+	// CanaryRevision is the revision of canary settings in rollout
+	CanaryRevision string `json:"canaryRevision,omitempty"`
+	// Halted means the rollout of canary revision is halted, because the
+	// canary settings are rolled back on too many nodes.
+	Halted bool `json:"halted,omitempty"`
+	// FailedNodes is the nodes that the canary settings are rolled back on
+	FailedNodes []string `json:"failedNodes,omitempty"`
+	// DriftedNodes is the nodes that report a version or flags of yurthub
+	// other than the desired ones.
+	DriftedNodes []string `json:"driftedNodes,omitempty"`
+}
@@ -0,0 +1,424 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
+	"github.com/alibaba/openyurt/pkg/yurthub/selfreport"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+func init() {
+	// Register prometheus metrics
+	Register()
+}
+
+// Controller projects YurtHubConfiguration to the configmap of yurthub
+// settings for the node pool, so yurthubs in the node pool can be tuned
+// without editing the static pod on every node. the configmap is owned
+// by the YurtHubConfiguration, and is removed by garbage collector when
+// the YurtHubConfiguration is deleted. the configmaps are labeled with
+// the name of YurtHubConfiguration, so the configmap of the previous node
+// pool is removed when the node pool of YurtHubConfiguration is changed.
+//
+// canary settings are projected to the configmap together with settings,
+// yurthubs select themselves for canary by the percent, and report the
+// canary revision that is rolled back on the node by annotation of node.
+// the rollout is halted when the canary revision is rolled back on
+// MaxFailedNodes nodes, then only settings are projected to the configmap.
+//
+// yurthubs report their version and flags by annotations of node, the nodes
+// that drift from the desired version and flags of the node pool are marked
+// by condition YurtHubConfigDrifted, so they can be upgraded.
+//
+// the maintenance of node pool is projected to the configmap for yurthubs, and to
+// annotations of the nodes in the node pool for the node lifecycle controller.
+type Controller struct {
+	kubeClient    clientset.Interface
+	dynamicClient dynamic.Interface
+	lister        cache.GenericLister
+	synced        cache.InformerSynced
+	nodeLister    corelisters.NodeLister
+	nodeSynced    cache.InformerSynced
+	queue         workqueue.RateLimitingInterface
+}
+
+// NewController creates a controller for YurtHubConfiguration
+func NewController(informer informers.GenericInformer,
+	nodeInformer coreinformers.NodeInformer,
+	kubeClient clientset.Interface,
+	dynamicClient dynamic.Interface) *Controller {
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		lister:        informer.Lister(),
+		synced:        informer.Informer().HasSynced,
+		nodeLister:    nodeInformer.Lister(),
+		nodeSynced:    nodeInformer.Informer().HasSynced,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "yurthubconfig"),
+	}
+
+	informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueue,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueue(newObj)
+		},
+		DeleteFunc: c.enqueue,
+	})
+
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.addNode,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, newNode := oldObj.(*v1.Node), newObj.(*v1.Node)
+			if oldNode.Annotations[hubconfig.CanaryFailedAnnotation] != newNode.Annotations[hubconfig.CanaryFailedAnnotation] ||
+				oldNode.Annotations[selfreport.VersionAnnotation] != newNode.Annotations[selfreport.VersionAnnotation] ||
+				oldNode.Annotations[selfreport.FlagsAnnotation] != newNode.Annotations[selfreport.FlagsAnnotation] ||
+				oldNode.Labels[constants.LabelNodePool] != newNode.Labels[constants.LabelNodePool] ||
+				!reflect.DeepEqual(maintenanceAnnotationsOf(oldNode), maintenanceAnnotationsOf(newNode)) {
+				c.addNode(newObj)
+			}
+		},
+	})
+
+	return c
+}
+
+// Run starts workers to reconcile YurtHubConfiguration
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Infof("Starting yurthub config controller")
+	defer klog.Infof("Shutting down yurthub config controller")
+
+	if !cache.WaitForCacheSync(stopCh, c.synced, c.nodeSynced) {
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.worker, time.Second, stopCh)
+	}
+
+	<-stopCh
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// addNode enqueues all YurtHubConfigurations when canary failure or version of yurthub
+// is reported by node, because node pool of node is not known by the controller. they
+// are enqueued as well when the maintenance annotations of node are out of sync.
+func (c *Controller) addNode(obj interface{}) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+
+	objs, err := c.lister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to list yurthub configurations, %v", err))
+		return
+	}
+
+	if len(node.Annotations[hubconfig.CanaryFailedAnnotation]) == 0 && len(node.Annotations[selfreport.VersionAnnotation]) == 0 &&
+		reflect.DeepEqual(maintenanceAnnotationsOf(node), desiredMaintenanceAnnotations(node, maintenanceOf(objs))) {
+		return
+	}
+
+	for i := range objs {
+		c.enqueue(objs[i])
+	}
+}
+
+func (c *Controller) worker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	if err := c.sync(key.(string)); err != nil {
+		utilruntime.HandleError(fmt.Errorf("failed to sync yurthub configuration %s, %v", key, err))
+		c.queue.AddRateLimited(key)
+		return true
+	}
+
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *Controller) sync(key string) error {
+	// maintenance is synced for all node pools, the node pool of the deleted
+	// YurtHubConfiguration is not known.
+	if err := c.syncMaintenance(); err != nil {
+		return err
+	}
+
+	obj, err := c.lister.Get(key)
+	if apierrors.IsNotFound(err) {
+		// configmap is removed by garbage collector
+		driftedNodes.DeleteLabelValues(key)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	hubCfg, err := toYurtHubConfiguration(obj)
+	if err != nil {
+		return err
+	}
+
+	status, err := c.canaryStatus(hubCfg)
+	if err != nil {
+		return err
+	}
+
+	if status.DriftedNodes, err = c.syncDrift(hubCfg); err != nil {
+		return err
+	}
+
+	desired := newConfigMap(hubCfg, status)
+	if err := c.syncConfigMap(hubCfg, desired); err != nil {
+		return err
+	}
+	if err := c.deleteStaleConfigMaps(hubCfg, desired.Name); err != nil {
+		return err
+	}
+
+	if reflect.DeepEqual(hubCfg.Status, *status) {
+		return nil
+	}
+
+	if status.Halted && !hubCfg.Status.Halted {
+		klog.Warningf("rollout of canary revision %s for yurthub configuration %s is halted, canary settings are rolled back on nodes %v",
+			status.CanaryRevision, hubCfg.Name, status.FailedNodes)
+	}
+	return c.updateStatus(obj, status)
+}
+
+func (c *Controller) syncConfigMap(hubCfg *YurtHubConfiguration, desired *v1.ConfigMap) error {
+	cm, err := c.kubeClient.CoreV1().ConfigMaps(desired.Namespace).Get(desired.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Infof("create configmap %s/%s for yurthub configuration %s", desired.Namespace, desired.Name, hubCfg.Name)
+		_, err = c.kubeClient.CoreV1().ConfigMaps(desired.Namespace).Create(desired)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(cm, hubCfg) {
+		// more than one YurtHubConfiguration are set for the same node pool
+		return fmt.Errorf("configmap %s/%s is not controlled by yurthub configuration %s", cm.Namespace, cm.Name, hubCfg.Name)
+	}
+
+	if reflect.DeepEqual(cm.Data, desired.Data) && cm.Labels[LabelYurtHubConfiguration] == hubCfg.Name {
+		return nil
+	}
+
+	klog.Infof("update configmap %s/%s for yurthub configuration %s", cm.Namespace, cm.Name, hubCfg.Name)
+	cm = cm.DeepCopy()
+	cm.Data = desired.Data
+	if cm.Labels == nil {
+		cm.Labels = map[string]string{}
+	}
+	cm.Labels[LabelYurtHubConfiguration] = hubCfg.Name
+	_, err = c.kubeClient.CoreV1().ConfigMaps(cm.Namespace).Update(cm)
+	return err
+}
+
+// deleteStaleConfigMaps deletes the configmaps projected from YurtHubConfiguration except the desired
+// one, like the configmap of the previous node pool after the node pool of YurtHubConfiguration is changed.
+func (c *Controller) deleteStaleConfigMaps(hubCfg *YurtHubConfiguration, desiredName string) error {
+	selector := labels.SelectorFromSet(labels.Set{LabelYurtHubConfiguration: hubCfg.Name})
+	cms, err := c.kubeClient.CoreV1().ConfigMaps(hubconfig.Namespace).List(metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return err
+	}
+
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		// configmaps of a YurtHubConfiguration that is recreated with the same name are left to garbage collector
+		if cm.Name == desiredName || !metav1.IsControlledBy(cm, hubCfg) {
+			continue
+		}
+		klog.Infof("delete configmap %s/%s of yurthub configuration %s, its node pool is changed", cm.Namespace, cm.Name, hubCfg.Name)
+		err := c.kubeClient.CoreV1().ConfigMaps(cm.Namespace).Delete(cm.Name, &metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{UID: &cm.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// canaryStatus returns the status of canary rollout, the canary revision is halted
+// when it is rolled back on MaxFailedNodes nodes, and it stays halted.
+func (c *Controller) canaryStatus(hubCfg *YurtHubConfiguration) (*YurtHubConfigurationStatus, error) {
+	status := &YurtHubConfigurationStatus{}
+	if hubCfg.Spec.Canary == nil {
+		return status, nil
+	}
+
+	revision, err := canaryRevision(hubCfg)
+	if err != nil {
+		return nil, err
+	}
+	status.CanaryRevision = revision
+
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range nodes {
+		if node.Annotations[hubconfig.CanaryFailedAnnotation] == revision {
+			status.FailedNodes = append(status.FailedNodes, node.Name)
+		}
+	}
+	sort.Strings(status.FailedNodes)
+
+	maxFailedNodes := hubCfg.Spec.Canary.MaxFailedNodes
+	if maxFailedNodes <= 0 {
+		maxFailedNodes = 1
+	}
+	// a halted revision is never resumed, even if the nodes that it failed on are removed,
+	// the rollout goes on only when the canary settings are changed to a new revision.
+	status.Halted = len(status.FailedNodes) >= maxFailedNodes ||
+		(hubCfg.Status.Halted && hubCfg.Status.CanaryRevision == revision)
+
+	return status, nil
+}
+
+func (c *Controller) updateStatus(obj runtime.Object, status *YurtHubConfigurationStatus) error {
+	u := obj.(*unstructured.Unstructured).DeepCopy()
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return err
+	}
+
+	if err := unstructured.SetNestedField(u.Object, content, "status"); err != nil {
+		return err
+	}
+
+	_, err = c.dynamicClient.Resource(SchemeGroupVersionResource).UpdateStatus(u, metav1.UpdateOptions{})
+	return err
+}
+
+// canaryRevision returns the revision of canary settings, a new revision is
+// rolled out when canary settings are changed, even if the previous one is halted.
+func canaryRevision(hubCfg *YurtHubConfiguration) (string, error) {
+	b, err := json.Marshal(hubCfg.Spec.Canary.YurtHubSettings)
+	if err != nil {
+		return "", err
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(hubCfg.Name))
+	h.Write(b)
+	return fmt.Sprintf("%x", h.Sum32()), nil
+}
+
+func toYurtHubConfiguration(obj runtime.Object) (*YurtHubConfiguration, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+
+	hubCfg := &YurtHubConfiguration{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.UnstructuredContent(), hubCfg); err != nil {
+		return nil, fmt.Errorf("failed to convert %s to yurthub configuration, %v", u.GetName(), err)
+	}
+
+	return hubCfg, nil
+}
+
+// newConfigMap returns the configmap of yurthub settings that projected from YurtHubConfiguration
+func newConfigMap(hubCfg *YurtHubConfiguration, status *YurtHubConfigurationStatus) *v1.ConfigMap {
+	settings := toSettings(hubCfg.Spec.YurtHubSettings)
+	data := settings.Data()
+	if canary := hubCfg.Spec.Canary; canary != nil && !status.Halted {
+		canaryData := (&hubconfig.Canary{
+			Settings:        toSettings(canary.YurtHubSettings),
+			Percent:         canary.Percent,
+			Revision:        status.CanaryRevision,
+			MaxErrorPercent: canary.MaxErrorPercent,
+		}).Data()
+		for k, v := range canaryData {
+			data[k] = v
+		}
+	}
+	if m := toMaintenance(hubCfg.Spec.Maintenance); m != nil {
+		for k, v := range m.Data() {
+			data[k] = v
+		}
+	}
+
+	gvk := SchemeGroupVersionResource.GroupVersion().WithKind(YurtHubConfigurationKind)
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            hubconfig.ConfigMapName(hubCfg.Spec.NodePool),
+			Namespace:       hubconfig.Namespace,
+			Labels:          map[string]string{LabelYurtHubConfiguration: hubCfg.Name},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(hubCfg, gvk)},
+		},
+		Data: data,
+	}
+}
+
+func toSettings(s YurtHubSettings) hubconfig.Settings {
+	return hubconfig.Settings{
+		CacheAgents:              s.CacheAgents,
+		AggregatedAPICacheGroups: s.AggregatedAPICacheGroups,
+		MaxRequestsInFlight:      s.MaxRequestsInFlight,
+	}
+}
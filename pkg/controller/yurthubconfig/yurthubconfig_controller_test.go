@@ -0,0 +1,468 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yurthubconfig
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	nodeutil "github.com/alibaba/openyurt/pkg/controller/util/node"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
+	"github.com/alibaba/openyurt/pkg/yurthub/selfreport"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestYurtHubConfiguration(name, pool string, agents []string) *unstructured.Unstructured {
+	hubCfg := &YurtHubConfiguration{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: SchemeGroupVersionResource.GroupVersion().String(),
+			Kind:       YurtHubConfigurationKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			UID:  types.UID("uid-" + name),
+		},
+		Spec: YurtHubConfigurationSpec{
+			NodePool: pool,
+			YurtHubSettings: YurtHubSettings{
+				CacheAgents:         agents,
+				MaxRequestsInFlight: 100,
+			},
+		},
+	}
+
+	content, _ := runtime.DefaultUnstructuredConverter.ToUnstructured(hubCfg)
+	return &unstructured.Unstructured{Object: content}
+}
+
+func TestSync(t *testing.T) {
+	testcases := map[string]struct {
+		hubCfg    *unstructured.Unstructured
+		existing  []runtime.Object
+		expectErr bool
+		expected  map[string]string
+	}{
+		"create configmap for node pool": {
+			hubCfg: newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"}),
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:         "agent1",
+				hubconfig.MaxRequestsInFlightKey: "100",
+			},
+		},
+		"update configmap for node pool": {
+			hubCfg: newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1", "agent2"}),
+			existing: []runtime.Object{
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "yurt-hub-cfg-hangzhou",
+						Namespace: hubconfig.Namespace,
+						OwnerReferences: []metav1.OwnerReference{
+							{Name: "foo", UID: "uid-foo", Controller: boolPtr(true)},
+						},
+					},
+					Data: map[string]string{
+						hubconfig.CacheAgentsKey: "agent1",
+					},
+				},
+			},
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:         "agent1,agent2",
+				hubconfig.MaxRequestsInFlightKey: "100",
+			},
+		},
+		"configmap is controlled by another configuration": {
+			hubCfg: newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"}),
+			existing: []runtime.Object{
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "yurt-hub-cfg-hangzhou",
+						Namespace: hubconfig.Namespace,
+						OwnerReferences: []metav1.OwnerReference{
+							{Name: "bar", UID: "uid-bar", Controller: boolPtr(true)},
+						},
+					},
+				},
+			},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			indexer.Add(tt.hubCfg)
+			kubeClient := fake.NewSimpleClientset(tt.existing...)
+			c := &Controller{
+				kubeClient: kubeClient,
+				lister:     cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister: corelisters.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+			}
+
+			err := c.sync(tt.hubCfg.GetName())
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			cm, err := kubeClient.CoreV1().ConfigMaps(hubconfig.Namespace).Get("yurt-hub-cfg-hangzhou", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get configmap, %v", err)
+			}
+
+			if !reflect.DeepEqual(cm.Data, tt.expected) {
+				t.Errorf("expect data %v, but got %v", tt.expected, cm.Data)
+			}
+		})
+	}
+}
+
+func TestSyncNodePoolChanged(t *testing.T) {
+	newConfigMap := func(pool, owner string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      hubconfig.ConfigMapName(pool),
+				Namespace: hubconfig.Namespace,
+				Labels:    map[string]string{LabelYurtHubConfiguration: "foo"},
+				OwnerReferences: []metav1.OwnerReference{
+					{Name: "foo", UID: types.UID("uid-" + owner), Controller: boolPtr(true)},
+				},
+			},
+		}
+	}
+
+	hubCfg := newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"})
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(hubCfg)
+	kubeClient := fake.NewSimpleClientset(
+		newConfigMap("beijing", "foo"),
+		// projected from the previous YurtHubConfiguration with the same name
+		newConfigMap("shanghai", "old-foo"),
+	)
+	c := &Controller{
+		kubeClient: kubeClient,
+		lister:     cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+		nodeLister: corelisters.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+	}
+
+	if err := c.sync("foo"); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+
+	cms, err := kubeClient.CoreV1().ConfigMaps(hubconfig.Namespace).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list configmaps, %v", err)
+	}
+	var names []string
+	for _, cm := range cms.Items {
+		names = append(names, cm.Name)
+	}
+	sort.Strings(names)
+	expected := []string{hubconfig.ConfigMapName("hangzhou"), hubconfig.ConfigMapName("shanghai")}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expect configmaps %v, but got %v", expected, names)
+	}
+}
+
+func TestSyncNotFound(t *testing.T) {
+	c := &Controller{
+		kubeClient: fake.NewSimpleClientset(),
+		lister:     cache.NewGenericLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}), SchemeGroupVersionResource.GroupResource()),
+		nodeLister: corelisters.NewNodeLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+	}
+
+	if err := c.sync("foo"); err != nil {
+		t.Errorf("expect no error for deleted configuration, but got %v", err)
+	}
+}
+
+func TestSyncCanary(t *testing.T) {
+	hubCfg := newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"})
+	unstructured.SetNestedMap(hubCfg.Object, map[string]interface{}{
+		"cacheAgents": []interface{}{"agent2"},
+		"percent":     int64(10),
+	}, "spec", "canary")
+	typedCfg, err := toYurtHubConfiguration(hubCfg)
+	if err != nil {
+		t.Fatalf("failed to convert yurthub configuration, %v", err)
+	}
+	revision, _ := canaryRevision(typedCfg)
+
+	testcases := map[string]struct {
+		failedNodes  []string
+		status       map[string]interface{}
+		expectHalted bool
+		expected     map[string]string
+	}{
+		"canary in rollout": {
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:             "agent1",
+				hubconfig.MaxRequestsInFlightKey:     "100",
+				"canary." + hubconfig.CacheAgentsKey: "agent2",
+				hubconfig.CanaryPercentKey:           "10",
+				hubconfig.CanaryRevisionKey:          revision,
+			},
+		},
+		"canary is halted": {
+			failedNodes:  []string{"node1"},
+			expectHalted: true,
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:         "agent1",
+				hubconfig.MaxRequestsInFlightKey: "100",
+			},
+		},
+		"halted canary is not resumed after failed nodes are removed": {
+			status:       map[string]interface{}{"canaryRevision": revision, "halted": true},
+			expectHalted: true,
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:         "agent1",
+				hubconfig.MaxRequestsInFlightKey: "100",
+			},
+		},
+		"new canary revision is rolled out after halted": {
+			status: map[string]interface{}{"canaryRevision": "old", "halted": true},
+			expected: map[string]string{
+				hubconfig.CacheAgentsKey:             "agent1",
+				hubconfig.MaxRequestsInFlightKey:     "100",
+				"canary." + hubconfig.CacheAgentsKey: "agent2",
+				hubconfig.CanaryPercentKey:           "10",
+				hubconfig.CanaryRevisionKey:          revision,
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			hubCfg := hubCfg.DeepCopy()
+			if tt.status != nil {
+				unstructured.SetNestedMap(hubCfg.Object, tt.status, "status")
+			}
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			indexer.Add(hubCfg)
+			nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+			nodeIndexer.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node0"}})
+			for _, name := range tt.failedNodes {
+				nodeIndexer.Add(&v1.Node{ObjectMeta: metav1.ObjectMeta{
+					Name:        name,
+					Annotations: map[string]string{hubconfig.CanaryFailedAnnotation: revision},
+				}})
+			}
+			kubeClient := fake.NewSimpleClientset()
+			dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), hubCfg.DeepCopy())
+			c := &Controller{
+				kubeClient:    kubeClient,
+				dynamicClient: dynamicClient,
+				lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+				nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+			}
+
+			if err := c.sync(hubCfg.GetName()); err != nil {
+				t.Fatalf("failed to sync, %v", err)
+			}
+
+			cm, err := kubeClient.CoreV1().ConfigMaps(hubconfig.Namespace).Get("yurt-hub-cfg-hangzhou", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get configmap, %v", err)
+			}
+
+			if !reflect.DeepEqual(cm.Data, tt.expected) {
+				t.Errorf("expect data %v, but got %v", tt.expected, cm.Data)
+			}
+
+			u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get(hubCfg.GetName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("failed to get yurthub configuration, %v", err)
+			}
+
+			halted, _, _ := unstructured.NestedBool(u.Object, "status", "halted")
+			if halted != tt.expectHalted {
+				t.Errorf("expect halted %v, but got %v", tt.expectHalted, halted)
+			}
+		})
+	}
+}
+
+func newTestReportedNode(name, version string, flags string, conditions ...v1.NodeCondition) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Annotations: map[string]string{
+				selfreport.VersionAnnotation: version,
+				selfreport.FlagsAnnotation:   flags,
+			},
+		},
+		Status: v1.NodeStatus{Conditions: conditions},
+	}
+}
+
+func TestSyncDrift(t *testing.T) {
+	hubCfg := newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"})
+	unstructured.SetNestedField(hubCfg.Object, "v0.2.0", "spec", "version")
+	unstructured.SetNestedStringMap(hubCfg.Object, map[string]string{"cert-mgr-mode": "kubelet"}, "spec", "flags")
+	driftedCondition := v1.NodeCondition{Type: NodeConfigDrifted, Status: v1.ConditionTrue, Reason: reasonConfigDrifted, Message: "version is v0.1.0, desired v0.2.0"}
+
+	nodes := []*v1.Node{
+		newTestReportedNode("in-sync", "v0.2.0", `{"node-pool":"hangzhou","cert-mgr-mode":"kubelet"}`),
+		newTestReportedNode("version-drifted", "v0.1.0", `{"node-pool":"hangzhou","cert-mgr-mode":"kubelet"}`),
+		newTestReportedNode("flags-drifted", "v0.2.0", `{"node-pool":"hangzhou","cert-mgr-mode":"exec"}`),
+		newTestReportedNode("flag-not-set", "v0.2.0", `{"node-pool":"hangzhou"}`),
+		newTestReportedNode("back-in-sync", "v0.2.0", `{"node-pool":"hangzhou","cert-mgr-mode":"kubelet"}`, driftedCondition),
+		newTestReportedNode("another-pool", "v0.1.0", `{"node-pool":"beijing"}`),
+		{ObjectMeta: metav1.ObjectMeta{Name: "not-reported"}},
+	}
+	expected := map[string]*v1.NodeCondition{
+		"in-sync":         nil,
+		"version-drifted": {Status: v1.ConditionTrue, Reason: reasonConfigDrifted, Message: "version is v0.1.0, desired v0.2.0"},
+		"flags-drifted":   {Status: v1.ConditionTrue, Reason: reasonConfigDrifted, Message: `flag --cert-mgr-mode is "exec", desired "kubelet"`},
+		"flag-not-set":    {Status: v1.ConditionTrue, Reason: reasonConfigDrifted, Message: `flag --cert-mgr-mode is not set, desired "kubelet"`},
+		"back-in-sync":    {Status: v1.ConditionFalse, Reason: reasonConfigInSync},
+		"another-pool":    nil,
+		"not-reported":    nil,
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(hubCfg)
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	objs := make([]runtime.Object, 0, len(nodes))
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+		objs = append(objs, node)
+	}
+	kubeClient := fake.NewSimpleClientset(objs...)
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), hubCfg.DeepCopy())
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+		nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+	}
+
+	if err := c.sync(hubCfg.GetName()); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+
+	for name, want := range expected {
+		node, err := kubeClient.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("failed to get node %s, %v", name, err)
+		}
+		_, got := nodeutil.GetNodeCondition(&node.Status, NodeConfigDrifted)
+		if want == nil {
+			if got != nil {
+				t.Errorf("node %s: expect no drift condition, but got %v", name, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("node %s: expect drift condition, but got nil", name)
+			continue
+		}
+		if got.Status != want.Status || got.Reason != want.Reason || (len(want.Message) != 0 && got.Message != want.Message) {
+			t.Errorf("node %s: expect drift condition %s(%s) %q, but got %s(%s) %q", name, want.Status, want.Reason, want.Message, got.Status, got.Reason, got.Message)
+		}
+	}
+
+	u, err := dynamicClient.Resource(SchemeGroupVersionResource).Get(hubCfg.GetName(), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get yurthub configuration, %v", err)
+	}
+	drifted, _, _ := unstructured.NestedStringSlice(u.Object, "status", "driftedNodes")
+	if want := []string{"flag-not-set", "flags-drifted", "version-drifted"}; !reflect.DeepEqual(drifted, want) {
+		t.Errorf("expect drifted nodes %v, but got %v", want, drifted)
+	}
+}
+
+func TestSyncMaintenance(t *testing.T) {
+	hubCfg := newTestYurtHubConfiguration("foo", "hangzhou", []string{"agent1"})
+	unstructured.SetNestedMap(hubCfg.Object, map[string]interface{}{
+		"reason": "wan upgrade",
+		"until":  "2020-10-01T12:00:00Z",
+	}, "spec", "maintenance")
+	maintenanceAnnotations := map[string]string{
+		scheduler.AnnotationKeyMaintenance:      "wan upgrade",
+		scheduler.AnnotationKeyMaintenanceUntil: "2020-10-01T12:00:00Z",
+	}
+
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "in-pool", Labels: map[string]string{constants.LabelNodePool: "hangzhou"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "left-pool", Labels: map[string]string{constants.LabelNodePool: "beijing"}, Annotations: maintenanceAnnotations}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "no-pool"}},
+	}
+	expected := map[string]string{
+		"in-pool":   `{"metadata":{"annotations":{"openyurt.io/maintenance":"wan upgrade","openyurt.io/maintenance-until":"2020-10-01T12:00:00Z"}}}`,
+		"left-pool": `{"metadata":{"annotations":{"openyurt.io/maintenance":null,"openyurt.io/maintenance-until":null}}}`,
+	}
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	indexer.Add(hubCfg)
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	objs := make([]runtime.Object, 0, len(nodes))
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+		objs = append(objs, node)
+	}
+	kubeClient := fake.NewSimpleClientset(objs...)
+	c := &Controller{
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), hubCfg.DeepCopy()),
+		lister:        cache.NewGenericLister(indexer, SchemeGroupVersionResource.GroupResource()),
+		nodeLister:    corelisters.NewNodeLister(nodeIndexer),
+	}
+
+	if err := c.sync(hubCfg.GetName()); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+
+	// annotations are not removed by patch of fake client, so patches are checked
+	patches := make(map[string]string)
+	for _, action := range kubeClient.Actions() {
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			patches[patch.GetName()] = string(patch.GetPatch())
+		}
+	}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Errorf("expect patches %v, but got %v", expected, patches)
+	}
+
+	cm, err := kubeClient.CoreV1().ConfigMaps(hubconfig.Namespace).Get("yurt-hub-cfg-hangzhou", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get configmap, %v", err)
+	}
+	if cm.Data[hubconfig.MaintenanceKey] != "wan upgrade" || cm.Data[hubconfig.MaintenanceUntilKey] != "2020-10-01T12:00:00Z" {
+		t.Errorf("expect maintenance is projected to configmap, but got %v", cm.Data)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,35 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fips tells whether the binary is built with FIPS 140-2 validated
+// crypto(BoringCrypto), so components can refuse to start when FIPS mode is
+// required but the binary is not built for it.
+package fips
+
+import "fmt"
+
+// Enabled returns true if the crypto of binary is provided by BoringCrypto
+func Enabled() bool {
+	return enabled()
+}
+
+// Check returns an error if FIPS mode is required but it's not enabled
+func Check(required bool) error {
+	if required && !Enabled() {
+		return fmt.Errorf("fips mode is required, but the binary is not built with BoringCrypto, build it with YURT_FIPS=true")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fips
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	if err := Check(false); err != nil {
+		t.Errorf("expect no error when fips mode is not required, but got %v", err)
+	}
+
+	err := Check(true)
+	if Enabled() && err != nil {
+		t.Errorf("expect no error when fips mode is enabled, but got %v", err)
+	} else if !Enabled() && err == nil {
+		t.Errorf("expect error when fips mode is required but not enabled, but got nil")
+	}
+}
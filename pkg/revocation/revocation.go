@@ -0,0 +1,171 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package revocation checks the client certificates(like the ones of kube-apiserver or the
+// tunnel agent) against a CRL file and a max lifetime, so the servers that authenticate them
+// (like the node pool webhook) can cut off a decommissioned or stolen certificate without
+// rotating the CA.
+package revocation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// Checker rejects the client certificates that are revoked by the CRL file, or whose
+// lifetime is longer than MaxLifetime. the CRL file is reloaded when it's changed, so
+// a certificate is revoked by replacing the file without restarting the server.
+type Checker struct {
+	// crlFile is the path of CRL in PEM or DER, empty disables the CRL check
+	crlFile string
+	// issuer verifies the signature of CRL, nil skips the verification
+	issuer *x509.Certificate
+	// maxLifetime is the max lifetime(NotAfter - NotBefore) of certificates, 0 disables the check.
+	// with a short max lifetime, a stolen certificate is useless once it expires, because the
+	// rotation of certificates is enforced.
+	maxLifetime time.Duration
+
+	sync.Mutex
+	modTime time.Time
+	revoked map[string]bool
+}
+
+// NewChecker creates a Checker, the CRL file is loaded at once if it's set
+func NewChecker(crlFile string, issuer *x509.Certificate, maxLifetime time.Duration) (*Checker, error) {
+	c := &Checker{
+		crlFile:     crlFile,
+		issuer:      issuer,
+		maxLifetime: maxLifetime,
+		revoked:     make(map[string]bool),
+	}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Check returns an error if the certificate is revoked, or its lifetime is too long
+func (c *Checker) Check(cert *x509.Certificate) error {
+	if c.maxLifetime > 0 {
+		if lifetime := cert.NotAfter.Sub(cert.NotBefore); lifetime > c.maxLifetime {
+			return fmt.Errorf("lifetime %v of certificate %s is longer than %v", lifetime, cert.Subject.CommonName, c.maxLifetime)
+		}
+	}
+
+	if err := c.reload(); err != nil {
+		// the last loaded CRL is still used, so revoked certificates are never accepted
+		klog.Errorf("failed to reload crl %s, %v", c.crlFile, err)
+	}
+	if c.isRevoked(cert.SerialNumber) {
+		return fmt.Errorf("certificate %s(serial %s) is revoked", cert.Subject.CommonName, cert.SerialNumber.String())
+	}
+	return nil
+}
+
+// VerifyPeerCertificate checks the verified leaf certificates of client, it's set as
+// VerifyPeerCertificate of tls.Config that verifies client certificates.
+func (c *Checker) VerifyPeerCertificate(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+	for _, chain := range verifiedChains {
+		if len(chain) == 0 {
+			continue
+		}
+		if err := c.Check(chain[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithChecker sets the checker as VerifyPeerCertificate of config, the original one is
+// still called after the checker.
+func WithChecker(config *tls.Config, c *Checker) *tls.Config {
+	config = config.Clone()
+	verify := config.VerifyPeerCertificate
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := c.VerifyPeerCertificate(rawCerts, verifiedChains); err != nil {
+			return err
+		}
+		if verify != nil {
+			return verify(rawCerts, verifiedChains)
+		}
+		return nil
+	}
+	return config
+}
+
+func (c *Checker) isRevoked(serial *big.Int) bool {
+	c.Lock()
+	defer c.Unlock()
+	return c.revoked[serial.String()]
+}
+
+// reload loads the CRL file if it's changed since it's loaded
+func (c *Checker) reload() error {
+	if c.crlFile == "" {
+		return nil
+	}
+
+	info, err := os.Stat(c.crlFile)
+	if err != nil {
+		return err
+	}
+	c.Lock()
+	changed := !info.ModTime().Equal(c.modTime)
+	c.Unlock()
+	if !changed {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(c.crlFile)
+	if err != nil {
+		return err
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+	crl, err := x509.ParseRevocationList(b)
+	if err != nil {
+		return fmt.Errorf("failed to parse crl, %v", err)
+	}
+	if c.issuer != nil {
+		if err := crl.CheckSignatureFrom(c.issuer); err != nil {
+			return fmt.Errorf("failed to verify signature of crl, %v", err)
+		}
+	}
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		klog.Warningf("crl %s is out of date since %v, it's still used until it's updated", c.crlFile, crl.NextUpdate)
+	}
+
+	revoked := make(map[string]bool, len(crl.RevokedCertificates))
+	for _, cert := range crl.RevokedCertificates {
+		revoked[cert.SerialNumber.String()] = true
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.modTime, c.revoked = info.ModTime(), revoked
+	klog.Infof("crl %s is loaded, %d certificates are revoked", c.crlFile, len(revoked))
+	return nil
+}
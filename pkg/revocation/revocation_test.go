@@ -0,0 +1,148 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package revocation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create ca, %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) newCert(t *testing.T, serial int64, lifetime time.Duration) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "tunnel-agent"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(-time.Minute + lifetime),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create cert, %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func (ca *testCA) writeCRL(t *testing.T, path string, number int64, serials ...int64) {
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(number),
+		ThisUpdate:          time.Now(),
+		NextUpdate:          time.Now().Add(time.Hour),
+		RevokedCertificates: revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatalf("failed to create crl, %v", err)
+	}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write crl, %v", err)
+	}
+	// make sure the change of file is seen by its modification time
+	modTime := time.Now().Add(time.Duration(number) * time.Second)
+	os.Chtimes(path, modTime, modTime)
+}
+
+func TestCheck(t *testing.T) {
+	ca := newTestCA(t)
+	crlFile := filepath.Join(t.TempDir(), "ca.crl")
+	ca.writeCRL(t, crlFile, 1, 2)
+
+	c, err := NewChecker(crlFile, ca.cert, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create checker, %v", err)
+	}
+
+	testcases := map[string]struct {
+		cert      *x509.Certificate
+		expectErr bool
+	}{
+		"valid certificate":    {cert: ca.newCert(t, 3, time.Hour)},
+		"revoked certificate":  {cert: ca.newCert(t, 2, time.Hour), expectErr: true},
+		"lifetime is too long": {cert: ca.newCert(t, 4, 30*24*time.Hour), expectErr: true},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := c.VerifyPeerCertificate(nil, [][]*x509.Certificate{{tt.cert, ca.cert}})
+			if (err != nil) != tt.expectErr {
+				t.Errorf("expect error %v, but got %v", tt.expectErr, err)
+			}
+		})
+	}
+
+	// certificates are revoked by updating the crl file
+	ca.writeCRL(t, crlFile, 2, 2, 3)
+	if err := c.Check(ca.newCert(t, 3, time.Hour)); err == nil {
+		t.Errorf("expect certificate is revoked after crl is updated")
+	}
+}
+
+func TestCRLSignature(t *testing.T) {
+	ca, other := newTestCA(t), newTestCA(t)
+	crlFile := filepath.Join(t.TempDir(), "ca.crl")
+	other.writeCRL(t, crlFile, 1, 2)
+
+	if _, err := NewChecker(crlFile, ca.cert, 0); err == nil {
+		t.Errorf("expect error for crl that is not signed by the issuer")
+	}
+	if _, err := NewChecker(filepath.Join(t.TempDir(), "missing.crl"), ca.cert, 0); err == nil {
+		t.Errorf("expect error for missing crl")
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulerextender
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/controller/nodelifecycle/scheduler"
+	"github.com/alibaba/openyurt/pkg/controller/strictbinding"
+	nodeutil "github.com/alibaba/openyurt/pkg/controller/util/node"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	extenderv1 "k8s.io/kubernetes/pkg/scheduler/api/v1"
+)
+
+const (
+	// AnnotationPreferredNodePools is the annotation of pod for the node pools(separated
+	// by comma) that the pod prefers to be scheduled to.
+	AnnotationPreferredNodePools = "openyurt.io/preferred-node-pools"
+
+	// preferredPoolScore and colocatedPoolScore are the scores of a node in a preferred
+	// node pool and in a node pool that has replicas of the workload, their sum is MaxPriority.
+	preferredPoolScore = 6
+	colocatedPoolScore = schedulerapi.MaxPriority - preferredPoolScore
+)
+
+// Extender is a scheduler extender that understands node pools and autonomy. nodes in the
+// node pools that are disconnected from cloud are filtered out, and nodes in the preferred
+// node pools of pod, or in the node pools that have replicas of the same workload, are preferred.
+type Extender struct {
+	podLister  corelisters.PodLister
+	nodeLister corelisters.NodeLister
+	// disconnectedThreshold is the percent of unready nodes that a node pool is regarded as disconnected
+	disconnectedThreshold float64
+}
+
+// NewExtender creates an Extender
+func NewExtender(podLister corelisters.PodLister, nodeLister corelisters.NodeLister, disconnectedThreshold float64) *Extender {
+	return &Extender{
+		podLister:             podLister,
+		nodeLister:            nodeLister,
+		disconnectedThreshold: disconnectedThreshold,
+	}
+}
+
+// Filter filters out the nodes in disconnected node pools, the nodes that don't belong
+// to any node pool are never filtered out. for a strict node binding pod that is bound
+// to a node by the strictbinding controller, all the other nodes are filtered out.
+func (e *Extender) Filter(args *extenderv1.ExtenderArgs) *extenderv1.ExtenderFilterResult {
+	disconnected, err := e.disconnectedPools()
+	if err != nil {
+		return &extenderv1.ExtenderFilterResult{Error: err.Error()}
+	}
+	boundNode, err := strictbinding.BoundNodeOf(e.nodeLister, args.Pod)
+	if err != nil {
+		return &extenderv1.ExtenderFilterResult{Error: err.Error()}
+	}
+
+	result := &extenderv1.ExtenderFilterResult{FailedNodes: extenderv1.FailedNodesMap{}}
+	filterOut := func(name string, pool string) bool {
+		if len(boundNode) != 0 && name != boundNode {
+			result.FailedNodes[name] = fmt.Sprintf("pod is bound to node %s by strict node binding", boundNode)
+			return true
+		}
+		if len(pool) == 0 || !disconnected[pool] {
+			return false
+		}
+		result.FailedNodes[name] = fmt.Sprintf("node pool %s is disconnected from cloud", pool)
+		return true
+	}
+
+	if args.Nodes != nil {
+		nodes := &v1.NodeList{}
+		for _, node := range args.Nodes.Items {
+			if !filterOut(node.Name, node.Labels[constants.LabelNodePool]) {
+				nodes.Items = append(nodes.Items, node)
+			}
+		}
+		result.Nodes = nodes
+	} else if args.NodeNames != nil {
+		names := make([]string, 0, len(*args.NodeNames))
+		for _, name := range *args.NodeNames {
+			if !filterOut(name, e.poolOf(name)) {
+				names = append(names, name)
+			}
+		}
+		result.NodeNames = &names
+	}
+
+	if len(result.FailedNodes) != 0 {
+		klog.V(4).Infof("nodes %v are filtered out for pod %s/%s", result.FailedNodes, args.Pod.Namespace, args.Pod.Name)
+	}
+	return result
+}
+
+// Prioritize scores the nodes by whether the node pool of node is preferred by pod, and
+// whether the node pool has replicas of the same workload already.
+func (e *Extender) Prioritize(args *extenderv1.ExtenderArgs) (*extenderv1.HostPriorityList, error) {
+	var names []string
+	pools := make(map[string]string)
+	if args.Nodes != nil {
+		for _, node := range args.Nodes.Items {
+			names = append(names, node.Name)
+			pools[node.Name] = node.Labels[constants.LabelNodePool]
+		}
+	} else if args.NodeNames != nil {
+		for _, name := range *args.NodeNames {
+			names = append(names, name)
+			pools[name] = e.poolOf(name)
+		}
+	}
+
+	preferred := preferredPools(args.Pod)
+	colocated, err := e.colocatedPools(args.Pod)
+	if err != nil {
+		return nil, err
+	}
+
+	priorities := make(extenderv1.HostPriorityList, 0, len(names))
+	for _, name := range names {
+		pool, score := pools[name], 0
+		if len(pool) != 0 && preferred[pool] {
+			score += preferredPoolScore
+		}
+		if len(pool) != 0 && colocated[pool] {
+			score += colocatedPoolScore
+		}
+		priorities = append(priorities, extenderv1.HostPriority{Host: name, Score: score})
+	}
+	return &priorities, nil
+}
+
+// disconnectedPools returns the node pools that the percent of unready nodes is not
+// less than disconnectedThreshold, pods on autonomous nodes are kept during the
+// disconnection, but new pods can't be started on the nodes.
+func (e *Extender) disconnectedPools() (map[string]bool, error) {
+	nodes, err := e.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	total, unready := make(map[string]int), make(map[string]int)
+	for _, node := range nodes {
+		pool := node.Labels[constants.LabelNodePool]
+		if len(pool) == 0 {
+			continue
+		}
+		total[pool]++
+		if _, condition := nodeutil.GetNodeCondition(&node.Status, v1.NodeReady); condition == nil || condition.Status != v1.ConditionTrue {
+			unready[pool]++
+		}
+	}
+
+	disconnected := make(map[string]bool)
+	for pool, n := range total {
+		if float64(unready[pool]) >= e.disconnectedThreshold*float64(n) {
+			disconnected[pool] = true
+		}
+	}
+	return disconnected, nil
+}
+
+// colocatedPools returns the node pools that have replicas of the workload of pod, the
+// replicas on autonomous nodes are counted even if the nodes are unreachable, because
+// they are not evicted.
+func (e *Extender) colocatedPools(pod *v1.Pod) (map[string]bool, error) {
+	pools := make(map[string]bool)
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return pools, nil
+	}
+
+	pods, err := e.podLister.Pods(pod.Namespace).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range pods {
+		ref := metav1.GetControllerOf(p)
+		if ref == nil || ref.UID != owner.UID || p.UID == pod.UID || len(p.Spec.NodeName) == 0 {
+			continue
+		}
+
+		node, err := e.nodeLister.Get(p.Spec.NodeName)
+		if err != nil {
+			continue
+		}
+		if p.DeletionTimestamp != nil && !scheduler.IsEvictionDisabled(node) {
+			continue
+		}
+		if pool := node.Labels[constants.LabelNodePool]; len(pool) != 0 {
+			pools[pool] = true
+		}
+	}
+	return pools, nil
+}
+
+func (e *Extender) poolOf(nodeName string) string {
+	node, err := e.nodeLister.Get(nodeName)
+	if err != nil {
+		return ""
+	}
+	return node.Labels[constants.LabelNodePool]
+}
+
+func preferredPools(pod *v1.Pod) map[string]bool {
+	pools := make(map[string]bool)
+	for _, pool := range strings.Split(pod.Annotations[AnnotationPreferredNodePools], ",") {
+		if pool = strings.TrimSpace(pool); len(pool) != 0 {
+			pools[pool] = true
+		}
+	}
+	return pools
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulerextender
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/controller/strictbinding"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	extenderv1 "k8s.io/kubernetes/pkg/scheduler/api/v1"
+)
+
+func newTestNode(name, pool string, ready v1.ConditionStatus) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}},
+		},
+	}
+	if len(pool) != 0 {
+		node.Labels = map[string]string{constants.LabelNodePool: pool}
+	}
+	return node
+}
+
+func newTestPod(name, nodeName string, owner types.UID) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name)},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+	}
+	if len(owner) != 0 {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "rs", UID: owner, Controller: &controller}}
+	}
+	return pod
+}
+
+func newTestExtender(nodes []*v1.Node, pods []*v1.Pod) *Extender {
+	nodeIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		nodeIndexer.Add(node)
+	}
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range pods {
+		podIndexer.Add(pod)
+	}
+	return NewExtender(corelisters.NewPodLister(podIndexer), corelisters.NewNodeLister(nodeIndexer), 0.5)
+}
+
+var testNodes = []*v1.Node{
+	newTestNode("hz-1", "hangzhou", v1.ConditionTrue),
+	newTestNode("hz-2", "hangzhou", v1.ConditionTrue),
+	newTestNode("bj-1", "beijing", v1.ConditionTrue),
+	newTestNode("bj-2", "beijing", v1.ConditionUnknown),
+	newTestNode("sh-1", "shanghai", v1.ConditionTrue),
+	newTestNode("cloud-1", "", v1.ConditionUnknown),
+}
+
+func TestFilter(t *testing.T) {
+	e := newTestExtender(testNodes, nil)
+	names := []string{"hz-1", "bj-1", "sh-1", "cloud-1"}
+
+	result := e.Filter(&extenderv1.ExtenderArgs{Pod: newTestPod("pod1", "", ""), NodeNames: &names})
+	if len(result.Error) != 0 {
+		t.Fatalf("failed to filter, %s", result.Error)
+	}
+	if expected := []string{"hz-1", "sh-1", "cloud-1"}; !reflect.DeepEqual(*result.NodeNames, expected) {
+		t.Errorf("expect nodes %v, but got %v", expected, *result.NodeNames)
+	}
+	if _, ok := result.FailedNodes["bj-1"]; !ok || len(result.FailedNodes) != 1 {
+		t.Errorf("expect bj-1 is filtered out, but got %v", result.FailedNodes)
+	}
+
+	nodes := &v1.NodeList{Items: []v1.Node{*testNodes[0], *testNodes[2]}}
+	result = e.Filter(&extenderv1.ExtenderArgs{Pod: newTestPod("pod1", "", ""), Nodes: nodes})
+	if len(result.Nodes.Items) != 1 || result.Nodes.Items[0].Name != "hz-1" {
+		t.Errorf("expect only node hz-1, but got %v", result.Nodes.Items)
+	}
+}
+
+func TestFilterStrictNodeBinding(t *testing.T) {
+	bound := newTestNode("hz-2", "hangzhou", v1.ConditionTrue)
+	bound.Annotations = map[string]string{strictbinding.AnnotationStrictNodeBindings: "default/db-0"}
+	e := newTestExtender([]*v1.Node{testNodes[0], bound, testNodes[4]}, nil)
+	names := []string{"hz-1", "hz-2", "sh-1"}
+
+	pod := newTestPod("db-0", "", "")
+	pod.Annotations = map[string]string{strictbinding.AnnotationStrictNodeBinding: "true"}
+	result := e.Filter(&extenderv1.ExtenderArgs{Pod: pod, NodeNames: &names})
+	if len(result.Error) != 0 {
+		t.Fatalf("failed to filter, %s", result.Error)
+	}
+	if expected := []string{"hz-2"}; !reflect.DeepEqual(*result.NodeNames, expected) {
+		t.Errorf("expect nodes %v, but got %v", expected, *result.NodeNames)
+	}
+
+	other := newTestPod("db-1", "", "")
+	other.Annotations = map[string]string{strictbinding.AnnotationStrictNodeBinding: "true"}
+	result = e.Filter(&extenderv1.ExtenderArgs{Pod: other, NodeNames: &names})
+	if !reflect.DeepEqual(*result.NodeNames, names) {
+		t.Errorf("expect nodes %v for unbound pod, but got %v", names, *result.NodeNames)
+	}
+}
+
+func TestPrioritize(t *testing.T) {
+	pods := []*v1.Pod{
+		newTestPod("replica-1", "sh-1", "rs-uid"),
+		newTestPod("other-1", "hz-1", "other-uid"),
+	}
+	e := newTestExtender(testNodes, pods)
+	names := []string{"hz-1", "sh-1", "cloud-1"}
+
+	testcases := map[string]struct {
+		pod      *v1.Pod
+		expected extenderv1.HostPriorityList
+	}{
+		"no preference": {
+			pod:      newTestPod("pod1", "", ""),
+			expected: extenderv1.HostPriorityList{{Host: "hz-1"}, {Host: "sh-1"}, {Host: "cloud-1"}},
+		},
+		"colocated with replicas": {
+			pod:      newTestPod("pod1", "", "rs-uid"),
+			expected: extenderv1.HostPriorityList{{Host: "hz-1"}, {Host: "sh-1", Score: colocatedPoolScore}, {Host: "cloud-1"}},
+		},
+		"preferred node pools": {
+			pod: func() *v1.Pod {
+				pod := newTestPod("pod1", "", "rs-uid")
+				pod.Annotations = map[string]string{AnnotationPreferredNodePools: "hangzhou, shanghai"}
+				return pod
+			}(),
+			expected: extenderv1.HostPriorityList{
+				{Host: "hz-1", Score: preferredPoolScore},
+				{Host: "sh-1", Score: preferredPoolScore + colocatedPoolScore},
+				{Host: "cloud-1"},
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			priorities, err := e.Prioritize(&extenderv1.ExtenderArgs{Pod: tt.pod, NodeNames: &names})
+			if err != nil {
+				t.Fatalf("failed to prioritize, %v", err)
+			}
+			if !reflect.DeepEqual(*priorities, tt.expected) {
+				t.Errorf("expect priorities %v, but got %v", tt.expected, *priorities)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := NewHandler(newTestExtender(testNodes, nil))
+	names := []string{"hz-1", "bj-1"}
+	body, _ := json.Marshal(&extenderv1.ExtenderArgs{Pod: newTestPod("pod1", "", ""), NodeNames: &names})
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/filter", bytes.NewReader(body)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expect status %d, but got %d", http.StatusOK, resp.Code)
+	}
+	result := &extenderv1.ExtenderFilterResult{}
+	if err := json.Unmarshal(resp.Body.Bytes(), result); err != nil {
+		t.Fatalf("failed to decode filter result, %v", err)
+	}
+	if !reflect.DeepEqual(*result.NodeNames, []string{"hz-1"}) {
+		t.Errorf("expect nodes [hz-1], but got %v", *result.NodeNames)
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/prioritize", nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expect status %d for GET, but got %d", http.StatusBadRequest, resp.Code)
+	}
+}
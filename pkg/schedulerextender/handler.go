@@ -0,0 +1,80 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulerextender
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"k8s.io/klog"
+	extenderv1 "k8s.io/kubernetes/pkg/scheduler/api/v1"
+)
+
+// NewHandler returns the http handler of extender, the urlPrefix of extender
+// in scheduler policy is the root of the handler.
+func NewHandler(e *Extender) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/filter", func(w http.ResponseWriter, r *http.Request) {
+		args, err := decodeArgs(r)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &extenderv1.ExtenderFilterResult{Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, e.Filter(args))
+	})
+	mux.HandleFunc("/prioritize", func(w http.ResponseWriter, r *http.Request) {
+		args, err := decodeArgs(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		priorities, err := e.Prioritize(args)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, priorities)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	return mux
+}
+
+func decodeArgs(r *http.Request) (*extenderv1.ExtenderArgs, error) {
+	if r.Method != http.MethodPost {
+		return nil, fmt.Errorf("method %s is not supported", r.Method)
+	}
+
+	args := &extenderv1.ExtenderArgs{}
+	if err := json.NewDecoder(r.Body).Decode(args); err != nil {
+		return nil, fmt.Errorf("failed to decode extender args, %v", err)
+	} else if args.Pod == nil {
+		return nil, fmt.Errorf("pod is not set in extender args")
+	}
+	return args, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.Errorf("failed to encode response of extender, %v", err)
+	}
+}
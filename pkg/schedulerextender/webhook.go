@@ -0,0 +1,259 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulerextender
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	nodeutil "github.com/alibaba/openyurt/pkg/controller/util/node"
+	"github.com/alibaba/openyurt/pkg/revocation"
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	certutil "k8s.io/client-go/util/cert"
+	"k8s.io/klog"
+)
+
+const (
+	// AnnotationNodePoolImmutable is the annotation of node that the node pool of node can't be
+	// changed if it's "true", e.g. for the nodes whose node pool is decided by their sites.
+	AnnotationNodePoolImmutable = "openyurt.io/node-pool-immutable"
+
+	// maxReportedPods is the max number of pool-bound pods reported in the reason of denial
+	maxReportedPods = 3
+)
+
+// NodePoolValidator validates the changes of node pool membership of nodes, a node can't be moved
+// between node pools(or leave its node pool) while it's disconnected from cloud, or while it runs
+// pods bound to its node pool, otherwise the features depending on the topology of node pools(like
+// the config of yurthub and the eviction policies of node pools) would see inconsistent state.
+type NodePoolValidator struct {
+	podLister corelisters.PodLister
+}
+
+// NewNodePoolValidator creates a NodePoolValidator
+func NewNodePoolValidator(podLister corelisters.PodLister) *NodePoolValidator {
+	return &NodePoolValidator{podLister: podLister}
+}
+
+// Validate returns the reason that the node can't be changed from oldNode to node, oldNode
+// is nil for the node to create. an empty reason is returned if the change is allowed.
+func (v *NodePoolValidator) Validate(oldNode, node *v1.Node) (string, error) {
+	pool, hasPool := node.Labels[constants.LabelNodePool]
+	if hasPool {
+		if errs := validation.IsDNS1123Label(pool); len(errs) != 0 {
+			return fmt.Sprintf("node pool name(%s) is invalid: %s", pool, strings.Join(errs, "; ")), nil
+		}
+	}
+	if oldNode == nil {
+		return "", nil
+	}
+
+	oldPool := oldNode.Labels[constants.LabelNodePool]
+	if oldPool == pool || len(oldPool) == 0 {
+		// joining a node pool doesn't affect the other node pools
+		return "", nil
+	}
+
+	change := fmt.Sprintf("node %s can't be moved from node pool %s to %s", node.Name, oldPool, pool)
+	if !hasPool {
+		change = fmt.Sprintf("node %s can't leave node pool %s", node.Name, oldPool)
+	}
+	if oldNode.Annotations[AnnotationNodePoolImmutable] == "true" {
+		return fmt.Sprintf("%s, its node pool is immutable by annotation %s", change, AnnotationNodePoolImmutable), nil
+	}
+	if _, condition := nodeutil.GetNodeCondition(&oldNode.Status, v1.NodeReady); condition == nil || condition.Status != v1.ConditionTrue {
+		return fmt.Sprintf("%s while it's disconnected from cloud", change), nil
+	}
+
+	bound, err := v.poolBoundPods(node.Name)
+	if err != nil {
+		return "", err
+	}
+	if len(bound) != 0 {
+		names := bound
+		if len(names) > maxReportedPods {
+			names = append(names[:maxReportedPods:maxReportedPods], "...")
+		}
+		return fmt.Sprintf("%s while %d pods bound to the node pool are running on it: %s",
+			change, len(bound), strings.Join(names, ", ")), nil
+	}
+	return "", nil
+}
+
+// poolBoundPods returns the pods on the node that are bound to node pools by their node selector
+// or required node affinity, the terminated pods are not counted.
+func (v *NodePoolValidator) poolBoundPods(nodeName string) ([]string, error) {
+	pods, err := v.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	var bound []string
+	for _, pod := range pods {
+		if pod.Spec.NodeName != nodeName || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if isPoolBound(pod) {
+			bound = append(bound, pod.Namespace+"/"+pod.Name)
+		}
+	}
+	sort.Strings(bound)
+	return bound, nil
+}
+
+func isPoolBound(pod *v1.Pod) bool {
+	if _, ok := pod.Spec.NodeSelector[constants.LabelNodePool]; ok {
+		return true
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return false
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key == constants.LabelNodePool {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewWebhookHandler returns the http handler of the validating admission webhook for nodes
+func NewWebhookHandler(v *NodePoolValidator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/validate-nodes", func(w http.ResponseWriter, r *http.Request) {
+		review, err := decodeAdmissionReview(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		review.Response = v.admit(review.Request)
+		review.Response.UID = review.Request.UID
+		review.Request = nil
+		writeJSON(w, http.StatusOK, review)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	return mux
+}
+
+// NewWebhookTLSConfig returns the tls config of the webhook server. client certificates(like the
+// one of kube-apiserver in its admission config) are required and verified by clientCAFile if it's
+// set, and the certificates revoked by crlFile or whose lifetime is longer than maxLifetime are
+// rejected. crlFile is reloaded when it's changed, and its signature is verified if clientCAFile
+// holds only the ca that issues it.
+func NewWebhookTLSConfig(clientCAFile, crlFile string, maxLifetime time.Duration) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+
+	cas, err := certutil.CertsFromFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client ca %s, %v", clientCAFile, err)
+	}
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	config.ClientCAs = x509.NewCertPool()
+	for _, ca := range cas {
+		config.ClientCAs.AddCert(ca)
+	}
+	if crlFile == "" && maxLifetime == 0 {
+		return config, nil
+	}
+
+	var issuer *x509.Certificate
+	if len(cas) == 1 {
+		issuer = cas[0]
+	}
+	checker, err := revocation.NewChecker(crlFile, issuer, maxLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client crl %s, %v", crlFile, err)
+	}
+	return revocation.WithChecker(config, checker), nil
+}
+
+func (v *NodePoolValidator) admit(req *admissionv1beta1.AdmissionRequest) *admissionv1beta1.AdmissionResponse {
+	resp := &admissionv1beta1.AdmissionResponse{Allowed: true}
+	if req.Kind.Kind != "Node" || (req.Operation != admissionv1beta1.Create && req.Operation != admissionv1beta1.Update) {
+		return resp
+	}
+
+	node := &v1.Node{}
+	if err := json.Unmarshal(req.Object.Raw, node); err != nil {
+		return deny(http.StatusBadRequest, fmt.Sprintf("failed to decode node, %v", err))
+	}
+	var oldNode *v1.Node
+	if req.Operation == admissionv1beta1.Update {
+		oldNode = &v1.Node{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldNode); err != nil {
+			return deny(http.StatusBadRequest, fmt.Sprintf("failed to decode old node, %v", err))
+		}
+	}
+
+	reason, err := v.Validate(oldNode, node)
+	if err != nil {
+		klog.Errorf("failed to validate node %s, %v", req.Name, err)
+		return deny(http.StatusInternalServerError, fmt.Sprintf("failed to validate node %s, %v", req.Name, err))
+	}
+	if len(reason) != 0 {
+		klog.V(2).Infof("change of node %s is denied by %s: %s", req.Name, req.UserInfo.Username, reason)
+		return deny(http.StatusForbidden, reason)
+	}
+	return resp
+}
+
+func deny(code int32, reason string) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{
+		Allowed: false,
+		Result:  &metav1.Status{Status: metav1.StatusFailure, Code: code, Message: reason},
+	}
+}
+
+func decodeAdmissionReview(r *http.Request) (*admissionv1beta1.AdmissionReview, error) {
+	if r.Method != http.MethodPost {
+		return nil, fmt.Errorf("method %s is not supported", r.Method)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admission review, %v", err)
+	}
+	review := &admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(body, review); err != nil {
+		return nil, fmt.Errorf("failed to decode admission review, %v", err)
+	} else if review.Request == nil {
+		return nil, fmt.Errorf("request is not set in admission review")
+	}
+	return review, nil
+}
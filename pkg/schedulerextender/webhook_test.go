@@ -0,0 +1,281 @@
+/*
+Copyright 2020 The OpenYurt Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedulerextender
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestValidator(pods []*v1.Pod) *NodePoolValidator {
+	podIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range pods {
+		podIndexer.Add(pod)
+	}
+	return NewNodePoolValidator(corelisters.NewPodLister(podIndexer))
+}
+
+func newPoolBoundPod(name, nodeName string, byAffinity bool) *v1.Pod {
+	pod := newTestPod(name, nodeName, "")
+	if byAffinity {
+		pod.Spec.Affinity = &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+				NodeSelectorTerms: []v1.NodeSelectorTerm{{MatchExpressions: []v1.NodeSelectorRequirement{
+					{Key: constants.LabelNodePool, Operator: v1.NodeSelectorOpIn, Values: []string{"hangzhou"}},
+				}}},
+			},
+		}}
+	} else {
+		pod.Spec.NodeSelector = map[string]string{constants.LabelNodePool: "hangzhou"}
+	}
+	return pod
+}
+
+func TestValidateNodePool(t *testing.T) {
+	immutable := newTestNode("hz-1", "hangzhou", v1.ConditionTrue)
+	immutable.Annotations = map[string]string{AnnotationNodePoolImmutable: "true"}
+	succeeded := newPoolBoundPod("succeeded", "hz-1", false)
+	succeeded.Status.Phase = v1.PodSucceeded
+
+	testcases := map[string]struct {
+		oldNode *v1.Node
+		node    *v1.Node
+		pods    []*v1.Pod
+		denied  string
+	}{
+		"create node in pool": {
+			node: newTestNode("hz-1", "hangzhou", v1.ConditionTrue),
+		},
+		"create node with invalid pool": {
+			node:   newTestNode("hz-1", "Hang_Zhou", v1.ConditionTrue),
+			denied: "node pool name(Hang_Zhou) is invalid",
+		},
+		"pool is not changed": {
+			oldNode: newTestNode("hz-1", "hangzhou", v1.ConditionUnknown),
+			node:    newTestNode("hz-1", "hangzhou", v1.ConditionUnknown),
+			pods:    []*v1.Pod{newPoolBoundPod("bound", "hz-1", false)},
+		},
+		"join pool": {
+			oldNode: newTestNode("hz-1", "", v1.ConditionUnknown),
+			node:    newTestNode("hz-1", "hangzhou", v1.ConditionUnknown),
+		},
+		"move node": {
+			oldNode: newTestNode("hz-1", "hangzhou", v1.ConditionTrue),
+			node:    newTestNode("hz-1", "beijing", v1.ConditionTrue),
+			pods:    []*v1.Pod{newTestPod("unbound", "hz-1", ""), newPoolBoundPod("other-node", "hz-2", false), succeeded},
+		},
+		"move immutable node": {
+			oldNode: immutable,
+			node:    newTestNode("hz-1", "beijing", v1.ConditionTrue),
+			denied:  "node hz-1 can't be moved from node pool hangzhou to beijing, its node pool is immutable",
+		},
+		"move disconnected node": {
+			oldNode: newTestNode("hz-1", "hangzhou", v1.ConditionUnknown),
+			node:    newTestNode("hz-1", "beijing", v1.ConditionUnknown),
+			denied:  "node hz-1 can't be moved from node pool hangzhou to beijing while it's disconnected from cloud",
+		},
+		"leave pool with pool-bound pods": {
+			oldNode: newTestNode("hz-1", "hangzhou", v1.ConditionTrue),
+			node:    newTestNode("hz-1", "", v1.ConditionTrue),
+			pods: []*v1.Pod{
+				newPoolBoundPod("p1", "hz-1", false),
+				newPoolBoundPod("p2", "hz-1", true),
+				newPoolBoundPod("p3", "hz-1", false),
+				newPoolBoundPod("p4", "hz-1", true),
+			},
+			denied: "node hz-1 can't leave node pool hangzhou while 4 pods bound to the node pool are running on it: default/p1, default/p2, default/p3, ...",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			reason, err := newTestValidator(tt.pods).Validate(tt.oldNode, tt.node)
+			if err != nil {
+				t.Fatalf("failed to validate node, %v", err)
+			}
+			if len(tt.denied) == 0 && len(reason) != 0 {
+				t.Errorf("expect allowed, but got denied: %s", reason)
+			} else if !strings.HasPrefix(reason, tt.denied) {
+				t.Errorf("expect denied: %s, but got %q", tt.denied, reason)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	encode := func(node *v1.Node) runtime.RawExtension {
+		b, _ := json.Marshal(node)
+		return runtime.RawExtension{Raw: b}
+	}
+	review := &admissionv1beta1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1beta1", Kind: "AdmissionReview"},
+		Request: &admissionv1beta1.AdmissionRequest{
+			UID:       "uid-1",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Node"},
+			Name:      "hz-1",
+			Operation: admissionv1beta1.Update,
+			OldObject: encode(newTestNode("hz-1", "hangzhou", v1.ConditionUnknown)),
+			Object:    encode(newTestNode("hz-1", "beijing", v1.ConditionUnknown)),
+		},
+	}
+	body, _ := json.Marshal(review)
+
+	handler := NewWebhookHandler(newTestValidator(nil))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, "/validate-nodes", bytes.NewReader(body)))
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expect status %d, but got %d", http.StatusOK, resp.Code)
+	}
+	result := &admissionv1beta1.AdmissionReview{}
+	if err := json.Unmarshal(resp.Body.Bytes(), result); err != nil {
+		t.Fatalf("failed to decode admission review, %v", err)
+	}
+	if result.Response == nil || result.Response.UID != "uid-1" || result.Response.Allowed {
+		t.Fatalf("expect the change is denied, but got %#v", result.Response)
+	}
+	if result.Response.Result.Code != http.StatusForbidden || !strings.Contains(result.Response.Result.Message, "disconnected") {
+		t.Errorf("unexpected result %#v", result.Response.Result)
+	}
+
+	resp = httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/validate-nodes", nil))
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expect status %d, but got %d", http.StatusBadRequest, resp.Code)
+	}
+}
+
+// newTestCert creates a client certificate of serial and lifetime signed by ca, or a self-signed
+// ca if ca is nil
+func newTestCert(t *testing.T, ca *tls.Certificate, serial int64, lifetime time.Duration) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key, %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "kube-apiserver"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(-time.Minute + lifetime),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := template, interface{}(key)
+	if ca == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		parent, signer = ca.Leaf, ca.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("failed to create cert, %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func TestWebhookClientAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, nil, 1, 24*time.Hour)
+	caFile, crlFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.crl")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Certificate[0]}), 0600); err != nil {
+		t.Fatalf("failed to write ca, %v", err)
+	}
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now(),
+		NextUpdate:          time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{{SerialNumber: big.NewInt(3), RevocationTime: time.Now()}},
+	}, ca.Leaf, ca.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to create crl, %v", err)
+	}
+	if err := ioutil.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600); err != nil {
+		t.Fatalf("failed to write crl, %v", err)
+	}
+
+	tlsConfig, err := NewWebhookTLSConfig(caFile, crlFile, 48*time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create tls config, %v", err)
+	}
+	server := httptest.NewUnstartedServer(NewWebhookHandler(newTestValidator(nil)))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	testcases := map[string]struct {
+		cert    *tls.Certificate
+		allowed bool
+	}{
+		"valid cert":       {cert: newTestCert(t, ca, 2, 24*time.Hour), allowed: true},
+		"revoked cert":     {cert: newTestCert(t, ca, 3, 24*time.Hour)},
+		"long lived cert":  {cert: newTestCert(t, ca, 4, 72*time.Hour)},
+		"cert of other ca": {cert: newTestCert(t, newTestCert(t, nil, 1, 24*time.Hour), 2, 24*time.Hour)},
+		"no client cert":   {},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			transport := server.Client().Transport.(*http.Transport).Clone()
+			if tt.cert != nil {
+				transport.TLSClientConfig.Certificates = []tls.Certificate{*tt.cert}
+			}
+			client := &http.Client{Transport: transport}
+			defer transport.CloseIdleConnections()
+
+			resp, err := client.Get(server.URL + "/healthz")
+			if err == nil {
+				resp.Body.Close()
+			}
+			if allowed := err == nil && resp.StatusCode == http.StatusOK; allowed != tt.allowed {
+				t.Errorf("expect allowed %v, but got %v, %v", tt.allowed, allowed, err)
+			}
+		})
+	}
+}
+
+func TestWebhookTLSConfigWithoutClientCA(t *testing.T) {
+	tlsConfig, err := NewWebhookTLSConfig("", "", 0)
+	if err != nil {
+		t.Fatalf("failed to create tls config, %v", err)
+	}
+	if tlsConfig.ClientAuth != tls.NoClientCert || tlsConfig.VerifyPeerCertificate != nil {
+		t.Errorf("expect client certificates are not verified without client ca")
+	}
+}
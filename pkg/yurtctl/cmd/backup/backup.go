@@ -0,0 +1,301 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// FormatVersion is the version of the layout of the backup tarball
+	FormatVersion = "v1"
+	// metadataFile is the file in the tarball that describes the backup
+	metadataFile = "metadata.yaml"
+	// nodesFile is the file in the tarball that has the yurt labels and annotations of nodes
+	nodesFile = "nodes.yaml"
+)
+
+// Resources are the openyurt resources that are backed up, in the order that they are
+// restored. NodePoolSummary is not included, as it's computed by yurt-controller-manager.
+var Resources = []schema.GroupVersionResource{
+	{Group: "apps.openyurt.io", Version: "v1alpha1", Resource: "yurthubconfigurations"},
+	{Group: "apps.openyurt.io", Version: "v1alpha1", Resource: "nodepoolevictionpolicies"},
+	{Group: "apps.openyurt.io", Version: "v1alpha1", Resource: "nodepoolregistrymirrors"},
+	{Group: "apps.openyurt.io", Version: "v1alpha1", Resource: "nodepoolhostconfigs"},
+}
+
+// nodeLabels are the labels of node that are owned by openyurt
+var nodeLabels = []string{constants.LabelEdgeWorker, constants.LabelNodePool}
+
+// nodeAnnotations are the annotations of node that are set by users of openyurt, the
+// annotations maintained by yurt-controller-manager(like maintenance) are not included.
+var nodeAnnotations = []string{constants.AnnotationAutonomy}
+
+// Metadata describes a backup
+type Metadata struct {
+	Version   string         `json:"version"`
+	CreatedAt metav1.Time    `json:"createdAt"`
+	Server    string         `json:"server,omitempty"`
+	Objects   map[string]int `json:"objects"`
+	Nodes     int            `json:"nodes"`
+}
+
+// NodeState is the yurt labels and annotations of a node
+type NodeState struct {
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// BackupOptions has the information that required by backup operation
+type BackupOptions struct {
+	clientSet     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	server        string
+	output        string
+	out           io.Writer
+}
+
+// NewBackupOptions creates a new BackupOptions
+func NewBackupOptions() *BackupOptions {
+	return &BackupOptions{}
+}
+
+// NewBackupCmd generates a new backup command
+func NewBackupCmd() *cobra.Command {
+	bo := NewBackupOptions()
+	cmd := &cobra.Command{
+		Use:   "backup [-o FILE]",
+		Short: "Backs up the openyurt resources and the yurt labels of nodes to a tarball",
+		Long: "Backs up the openyurt resources(yurthubconfigurations, nodepoolevictionpolicies, " +
+			"nodepoolregistrymirrors and nodepoolhostconfigs) and the yurt labels and annotations of nodes to a tarball, " +
+			"which can be restored by yurtctl restore after the control plane is rebuilt or migrated.",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := bo.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the backup option: %s", err)
+			}
+			if err := bo.RunBackup(); err != nil {
+				klog.Fatalf("fail to backup: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "",
+		"The path of the tarball, defaults to openyurt-backup-<timestamp>.tar.gz in the current dir")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (bo *BackupOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	restCfg, err := kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+	bo.server = restCfg.Host
+
+	bo.clientSet, err = kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	bo.dynamicClient, err = dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	bo.output, err = flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	if bo.output == "" {
+		bo.output = fmt.Sprintf("openyurt-backup-%s.tar.gz", time.Now().Format("20060102150405"))
+	}
+	bo.out = out
+	return nil
+}
+
+// RunBackup writes the backup to the output file
+func (bo *BackupOptions) RunBackup() error {
+	f, err := os.OpenFile(bo.output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	meta, err := bo.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(bo.output)
+		return err
+	}
+
+	fmt.Fprintf(bo.out, "backed up %s and the yurt labels of %d nodes to %s\n",
+		describeObjects(meta.Objects), meta.Nodes, bo.output)
+	return nil
+}
+
+// write writes the backup as a gzipped tarball to w
+func (bo *BackupOptions) write(w io.Writer) (*Metadata, error) {
+	meta := &Metadata{
+		Version:   FormatVersion,
+		CreatedAt: metav1.Now(),
+		Server:    bo.server,
+		Objects:   map[string]int{},
+	}
+	files := map[string][]byte{}
+
+	for _, gvr := range Resources {
+		list, err := bo.dynamicClient.Resource(gvr).List(metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			klog.Warningf("%s is not installed, skip it", groupResource(gvr))
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("fail to list %s: %s", groupResource(gvr), err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			b, err := yaml.Marshal(sanitize(obj).Object)
+			if err != nil {
+				return nil, err
+			}
+			files[path.Join(groupResource(gvr), obj.GetName()+".yaml")] = b
+		}
+		meta.Objects[groupResource(gvr)] = len(list.Items)
+	}
+
+	nodes, err := bo.nodeStates()
+	if err != nil {
+		return nil, err
+	}
+	b, err := yaml.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+	files[nodesFile] = b
+	meta.Nodes = len(nodes)
+
+	if b, err = yaml.Marshal(meta); err != nil {
+		return nil, err
+	}
+	files[metadataFile] = b
+
+	return meta, writeTarball(w, files)
+}
+
+// nodeStates gets the yurt labels and annotations of the nodes that have any of them
+func (bo *BackupOptions) nodeStates() ([]NodeState, error) {
+	nodeLst, err := bo.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("fail to list nodes: %s", err)
+	}
+
+	var states []NodeState
+	for _, node := range nodeLst.Items {
+		state := NodeState{
+			Name:        node.Name,
+			Labels:      pick(node.Labels, nodeLabels),
+			Annotations: pick(node.Annotations, nodeAnnotations),
+		}
+		if len(state.Labels) != 0 || len(state.Annotations) != 0 {
+			states = append(states, state)
+		}
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states, nil
+}
+
+// sanitize removes the status and the fields of metadata that are set by the apiserver,
+// so that the object can be created in another cluster.
+func sanitize(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "status")
+	for _, field := range []string{"resourceVersion", "uid", "selfLink", "creationTimestamp",
+		"generation", "managedFields", "ownerReferences", "deletionTimestamp", "deletionGracePeriodSeconds"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	return obj
+}
+
+// pick returns the entries of m whose keys are in keys
+func pick(m map[string]string, keys []string) map[string]string {
+	var picked map[string]string
+	for _, k := range keys {
+		if v, ok := m[k]; ok {
+			if picked == nil {
+				picked = map[string]string{}
+			}
+			picked[k] = v
+		}
+	}
+	return picked
+}
+
+// writeTarball writes files to w as a gzipped tarball in the order of their names
+func writeTarball(w io.Writer, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	for _, name := range names {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: time.Now(),
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// groupResource is the name of the directory of resource in the tarball, like
+// yurthubconfigurations.apps.openyurt.io
+func groupResource(gvr schema.GroupVersionResource) string {
+	return gvr.GroupResource().String()
+}
+
+// describeObjects describes the number of objects of each resource
+func describeObjects(objects map[string]int) string {
+	var parts []string
+	for _, gvr := range Resources {
+		if n, ok := objects[groupResource(gvr)]; ok {
+			parts = append(parts, fmt.Sprintf("%d %s", n, gvr.Resource))
+		}
+	}
+	if len(parts) == 0 {
+		return "no openyurt resources"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,156 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func newObject(kind, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.openyurt.io/v1alpha1",
+		"kind":       kind,
+		"metadata": map[string]interface{}{
+			"name":            name,
+			"resourceVersion": "10",
+			"uid":             "0123",
+		},
+		"spec":   spec,
+		"status": map[string]interface{}{"nodes": int64(2)},
+	}}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	objs := []runtime.Object{
+		newObject("YurtHubConfiguration", "hangzhou", map[string]interface{}{"nodePool": "hangzhou"}),
+		newObject("NodePoolEvictionPolicy", "beijing", map[string]interface{}{"nodePool": "beijing"}),
+	}
+	nodes := []runtime.Object{
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1",
+			Labels: map[string]string{constants.LabelEdgeWorker: "true", constants.LabelNodePool: "hangzhou",
+				"kubernetes.io/hostname": "node1"},
+			Annotations: map[string]string{constants.AnnotationAutonomy: "true",
+				constants.AnnotationMaintenance: "upgrade"}}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2",
+			Labels: map[string]string{constants.LabelEdgeWorker: "false"}}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "master",
+			Labels: map[string]string{"kubernetes.io/hostname": "master"}}},
+	}
+
+	var buf bytes.Buffer
+	bo := &BackupOptions{
+		clientSet:     fake.NewSimpleClientset(nodes...),
+		dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objs...),
+		server:        "https://1.2.3.4:6443",
+	}
+	meta, err := bo.write(&buf)
+	if err != nil {
+		t.Fatalf("fail to backup: %s", err)
+	}
+	if meta.Nodes != 2 || meta.Objects["yurthubconfigurations.apps.openyurt.io"] != 1 ||
+		meta.Objects["nodepoolevictionpolicies.apps.openyurt.io"] != 1 {
+		t.Errorf("unexpected metadata %+v", meta)
+	}
+
+	files, err := readTarball(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("fail to read the tarball: %s", err)
+	}
+	b := files["yurthubconfigurations.apps.openyurt.io/hangzhou.yaml"]
+	if b == nil {
+		t.Fatalf("yurthubconfiguration hangzhou is not backed up, files: %v", files)
+	}
+	for _, field := range []string{"resourceVersion", "uid", "status"} {
+		if strings.Contains(string(b), field) {
+			t.Errorf("expect %s to be removed, but got\n%s", field, b)
+		}
+	}
+	var states []NodeState
+	if err := yaml.Unmarshal(files[nodesFile], &states); err != nil {
+		t.Fatalf("fail to decode nodes: %s", err)
+	}
+	expect := []NodeState{
+		{Name: "node1",
+			Labels:      map[string]string{constants.LabelEdgeWorker: "true", constants.LabelNodePool: "hangzhou"},
+			Annotations: map[string]string{constants.AnnotationAutonomy: "true"}},
+		{Name: "node2", Labels: map[string]string{constants.LabelEdgeWorker: "false"}},
+	}
+	if !reflect.DeepEqual(states, expect) {
+		t.Errorf("expect nodes %+v, but got %+v", expect, states)
+	}
+
+	// restore to a cluster that has the eviction policy(with another spec) and node1 only
+	live := newObject("NodePoolEvictionPolicy", "beijing", map[string]interface{}{"nodePool": "shanghai"})
+	clientSet := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live)
+	var out bytes.Buffer
+	ro := &RestoreOptions{clientSet: clientSet, dynamicClient: dynamicClient, out: &out}
+	if err := ro.restore(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("fail to restore: %s", err)
+	}
+
+	cfg, err := dynamicClient.Resource(Resources[0]).Get("hangzhou", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("yurthubconfiguration hangzhou is not restored: %s", err)
+	}
+	if pool, _, _ := unstructured.NestedString(cfg.Object, "spec", "nodePool"); pool != "hangzhou" {
+		t.Errorf("expect node pool hangzhou, but got %s", pool)
+	}
+	policy, err := dynamicClient.Resource(Resources[1]).Get("beijing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("fail to get nodepoolevictionpolicy beijing: %s", err)
+	}
+	if pool, _, _ := unstructured.NestedString(policy.Object, "spec", "nodePool"); pool != "beijing" {
+		t.Errorf("expect node pool beijing, but got %s", pool)
+	}
+
+	var patched []string
+	for _, action := range clientSet.Actions() {
+		if patch, ok := action.(clienttesting.PatchAction); ok {
+			patched = append(patched, patch.GetName())
+			var obj v1.Node
+			if err := json.Unmarshal(patch.GetPatch(), &obj); err != nil {
+				t.Fatalf("fail to decode the patch: %s", err)
+			}
+			if !reflect.DeepEqual(obj.Labels, expect[0].Labels) || !reflect.DeepEqual(obj.Annotations, expect[0].Annotations) {
+				t.Errorf("unexpected patch %s", patch.GetPatch())
+			}
+		}
+	}
+	if !reflect.DeepEqual(patched, []string{"node1"}) {
+		t.Errorf("expect node1 to be patched, but got %v", patched)
+	}
+	for _, s := range []string{"yurthubconfigurations.apps.openyurt.io/hangzhou created",
+		"nodepoolevictionpolicies.apps.openyurt.io/beijing updated", "not in the cluster: node2"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expect %q in the output, but got\n%s", s, out.String())
+		}
+	}
+}
+
+func TestRestoreUnsupportedVersion(t *testing.T) {
+	var buf bytes.Buffer
+	b, _ := yaml.Marshal(Metadata{Version: "v0"})
+	if err := writeTarball(&buf, map[string][]byte{metadataFile: b}); err != nil {
+		t.Fatalf("fail to write the tarball: %s", err)
+	}
+
+	ro := &RestoreOptions{out: ioutil.Discard}
+	if err := ro.restore(&buf); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("expect the version to be rejected, but got %v", err)
+	}
+}
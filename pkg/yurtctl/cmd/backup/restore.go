@@ -0,0 +1,250 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+// RestoreOptions has the information that required by restore operation
+type RestoreOptions struct {
+	clientSet     kubernetes.Interface
+	dynamicClient dynamic.Interface
+	file          string
+	skipNodes     bool
+	out           io.Writer
+}
+
+// NewRestoreOptions creates a new RestoreOptions
+func NewRestoreOptions() *RestoreOptions {
+	return &RestoreOptions{}
+}
+
+// NewRestoreCmd generates a new restore command
+func NewRestoreCmd() *cobra.Command {
+	ro := NewRestoreOptions()
+	cmd := &cobra.Command{
+		Use:   "restore -f FILE",
+		Short: "Restores the openyurt resources and the yurt labels of nodes from a tarball of yurtctl backup",
+		Long: "Restores the openyurt resources and the yurt labels of nodes from a tarball of yurtctl backup, " +
+			"the resources are created or updated, and the labels of the nodes that are not in the " +
+			"cluster(yet) are skipped. The crds of openyurt should be installed(e.g. by yurtctl convert) first.",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := ro.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the restore option: %s", err)
+			}
+			if err := ro.RunRestore(); err != nil {
+				klog.Fatalf("fail to restore: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("filename", "f", "", "The path of the tarball of yurtctl backup")
+	cmd.Flags().Bool("skip-nodes", false, "Don't restore the yurt labels and annotations of nodes")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (ro *RestoreOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	ro.file, err = flags.GetString("filename")
+	if err != nil {
+		return err
+	}
+	if ro.file == "" {
+		return errors.New("the tarball is not set by -f")
+	}
+
+	ro.skipNodes, err = flags.GetBool("skip-nodes")
+	if err != nil {
+		return err
+	}
+
+	restCfg, err := kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+
+	ro.clientSet, err = kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	ro.dynamicClient, err = dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+	ro.out = out
+	return nil
+}
+
+// RunRestore restores the backup from the file
+func (ro *RestoreOptions) RunRestore() error {
+	f, err := os.Open(ro.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ro.restore(f)
+}
+
+// restore restores the backup from the gzipped tarball in r
+func (ro *RestoreOptions) restore(r io.Reader) error {
+	files, err := readTarball(r)
+	if err != nil {
+		return fmt.Errorf("fail to read the tarball: %s", err)
+	}
+
+	var meta Metadata
+	b, ok := files[metadataFile]
+	if !ok {
+		return fmt.Errorf("%s is not found in the tarball", metadataFile)
+	}
+	if err := yaml.Unmarshal(b, &meta); err != nil {
+		return fmt.Errorf("fail to decode %s: %s", metadataFile, err)
+	}
+	if meta.Version != FormatVersion {
+		return fmt.Errorf("the version %q of backup is not supported, expect %q", meta.Version, FormatVersion)
+	}
+
+	for _, gvr := range Resources {
+		dir := groupResource(gvr)
+		var names []string
+		for name := range files {
+			if path.Dir(name) == dir {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			obj := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(files[name], &obj.Object); err != nil {
+				return fmt.Errorf("fail to decode %s: %s", name, err)
+			}
+			action, err := ro.apply(gvr.Resource, ro.dynamicClient.Resource(gvr), obj)
+			if err != nil {
+				return fmt.Errorf("fail to restore %s %s: %s", dir, obj.GetName(), err)
+			}
+			fmt.Fprintf(ro.out, "%s/%s %s\n", dir, obj.GetName(), action)
+		}
+	}
+
+	if ro.skipNodes {
+		return nil
+	}
+	var nodes []NodeState
+	if err := yaml.Unmarshal(files[nodesFile], &nodes); err != nil {
+		return fmt.Errorf("fail to decode %s: %s", nodesFile, err)
+	}
+	var missing []string
+	for _, state := range nodes {
+		if err := ro.patchNode(state); apierrors.IsNotFound(err) {
+			missing = append(missing, state.Name)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("fail to restore the labels of node %s: %s", state.Name, err)
+		}
+		fmt.Fprintf(ro.out, "node/%s labeled\n", state.Name)
+	}
+	if len(missing) != 0 {
+		fmt.Fprintf(ro.out, "the labels of %d nodes are skipped as they are not in the cluster: %s\n",
+			len(missing), strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// apply creates the object, or updates it if it exists
+func (ro *RestoreOptions) apply(resource string, client dynamic.ResourceInterface, obj *unstructured.Unstructured) (string, error) {
+	live, err := client.Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := client.Create(obj, metav1.CreateOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("%s is not installed in the cluster", resource)
+			}
+			return "", err
+		}
+		return "created", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	obj.SetResourceVersion(live.GetResourceVersion())
+	if _, err := client.Update(obj, metav1.UpdateOptions{}); err != nil {
+		return "", err
+	}
+	return "updated", nil
+}
+
+// patchNode sets the yurt labels and annotations of the node, it returns a NotFound
+// error if the node is not in the cluster.
+func (ro *RestoreOptions) patchNode(state NodeState) error {
+	if _, err := ro.clientSet.CoreV1().Nodes().Get(state.Name, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	// a null labels(or annotations) in the patch removes all labels of the node
+	metadata := map[string]interface{}{}
+	if len(state.Labels) != 0 {
+		metadata["labels"] = state.Labels
+	}
+	if len(state.Annotations) != 0 {
+		metadata["annotations"] = state.Annotations
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+	_, err = ro.clientSet.CoreV1().Nodes().Patch(state.Name, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// readTarball reads the regular files of the gzipped tarball in r
+func readTarball(r io.Reader) (map[string][]byte, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(hdr.Name)] = b
+	}
+}
@@ -0,0 +1,516 @@
+package clusterinfo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/clusterinfo"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// readerName is the name of role and rolebinding that allow anyone to read the published bundle
+	readerName = "yurt-cluster-info-reader"
+	// fetchTimeout is the timeout of fetching the bundle
+	fetchTimeout = 30 * time.Second
+	// bootstrapUser is the user of bootstrap token in the kubeconfig, the same as kubeadm
+	bootstrapUser = "tls-bootstrap-token-user"
+)
+
+// ClusterInfoOptions has the information that required by cluster-info operations
+type ClusterInfoOptions struct {
+	clientSet kubernetes.Interface
+	restCfg   *rest.Config
+	out       io.Writer
+}
+
+// NewClusterInfoOptions creates a new ClusterInfoOptions
+func NewClusterInfoOptions() *ClusterInfoOptions {
+	return &ClusterInfoOptions{}
+}
+
+// NewClusterInfoCmd generates a new cluster-info command
+func NewClusterInfoCmd() *cobra.Command {
+	co := NewClusterInfoOptions()
+	cmd := &cobra.Command{
+		Use:   "cluster-info",
+		Short: "Manages the signed cluster info for the bootstrap of edge nodes",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newInitCmd(co))
+	cmd.AddCommand(newRotateKeyCmd(co))
+	cmd.AddCommand(newRetireKeyCmd(co))
+	cmd.AddCommand(newFetchCmd(co))
+
+	return cmd
+}
+
+func newInitCmd(co *ClusterInfoOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Sets up the cluster info that is signed and published by yurt-controller-manager, and prints the pins of signing keys",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := co.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the cluster-info option: %s", err)
+			}
+			apiServerEndpoints, err := cmd.Flags().GetStringSlice("apiserver-endpoints")
+			if err != nil {
+				klog.Fatalf("fail to get apiserver-endpoints: %s", err)
+			}
+			tunnelEndpoints, err := cmd.Flags().GetStringSlice("tunnel-endpoints")
+			if err != nil {
+				klog.Fatalf("fail to get tunnel-endpoints: %s", err)
+			}
+			caFile, err := cmd.Flags().GetString("ca-file")
+			if err != nil {
+				klog.Fatalf("fail to get ca-file: %s", err)
+			}
+			if err := co.RunInit(apiServerEndpoints, tunnelEndpoints, caFile); err != nil {
+				klog.Fatalf("fail to init cluster info: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringSlice("apiserver-endpoints", []string{},
+		"The endpoints of kube-apiserver that edge nodes connect to, the server of kubeconfig is used if it's not set. "+
+			"(e.g. --apiserver-endpoints=https://1.2.3.4:6443)")
+	cmd.Flags().StringSlice("tunnel-endpoints", []string{},
+		"The endpoints of yurt-tunnel-server that edge nodes connect to. (e.g. --tunnel-endpoints=1.2.3.4:10262)")
+	cmd.Flags().String("ca-file", "",
+		"The CA certificates of kube-apiserver, like both the old and new CAs during the rotation of CA. "+
+			"the CA of yurt-controller-manager is used if it's not set.")
+
+	return cmd
+}
+
+func newRotateKeyCmd(co *ClusterInfoOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate-key",
+		Short: "Adds a new signing key, the cluster info is signed by both the old and new keys until the old one is retired",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := co.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the cluster-info option: %s", err)
+			}
+			if err := co.RunRotateKey(); err != nil {
+				klog.Fatalf("fail to rotate signing key: %s", err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+func newRetireKeyCmd(co *ClusterInfoOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "retire-key PIN",
+		Short: "Retires a signing key, the cluster info is not signed by it any more",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := co.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the cluster-info option: %s", err)
+			}
+			if err := co.RunRetireKey(args[0]); err != nil {
+				klog.Fatalf("fail to retire signing key: %s", err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+func newFetchCmd(co *ClusterInfoOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fetch --server URL --pin PIN [--token TOKEN] [-o FILE]",
+		Short: "Fetches and verifies the cluster info on edge node, and writes the bootstrap kubeconfig",
+		Run: func(cmd *cobra.Command, _ []string) {
+			server, err := cmd.Flags().GetString("server")
+			if err != nil {
+				klog.Fatalf("fail to get server: %s", err)
+			}
+			pins, err := cmd.Flags().GetStringSlice("pin")
+			if err != nil {
+				klog.Fatalf("fail to get pin: %s", err)
+			}
+			token, err := cmd.Flags().GetString("token")
+			if err != nil {
+				klog.Fatalf("fail to get token: %s", err)
+			}
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				klog.Fatalf("fail to get output: %s", err)
+			}
+			co.out = cmd.OutOrStdout()
+			if err := co.RunFetch(server, pins, token, output); err != nil {
+				klog.Fatalf("fail to fetch cluster info: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("server", "",
+		"The endpoint of kube-apiserver that the cluster info is fetched from, the network to it doesn't need to be trusted. "+
+			"(e.g. --server=https://1.2.3.4:6443)")
+	cmd.Flags().StringSlice("pin", []string{},
+		"The pins of signing keys printed by yurtctl cluster-info init, the cluster info must be signed by one of them.")
+	cmd.Flags().String("token", "",
+		"The bootstrap token that is written to the bootstrap kubeconfig, like the one created by yurtctl token create.")
+	cmd.Flags().StringP("output", "o", "",
+		"The file that the bootstrap kubeconfig is written to, it's written to stdout if it's not set.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (co *ClusterInfoOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	co.restCfg, err = kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+
+	co.clientSet, err = kubernetes.NewForConfig(co.restCfg)
+	if err != nil {
+		return err
+	}
+	co.out = out
+	return nil
+}
+
+// RunInit writes the source of cluster info, creates the signing key if there is none,
+// and allows anyone to read the published cluster info. yurt-controller-manager signs
+// and publishes the cluster info from them.
+func (co *ClusterInfoOptions) RunInit(apiServerEndpoints, tunnelEndpoints []string, caFile string) error {
+	if len(apiServerEndpoints) == 0 {
+		if co.restCfg == nil || co.restCfg.Host == "" {
+			return errors.New("apiserver endpoints are not set")
+		}
+		apiServerEndpoints = []string{co.restCfg.Host}
+	}
+	for _, endpoint := range apiServerEndpoints {
+		if !strings.HasPrefix(endpoint, "https://") {
+			return fmt.Errorf("apiserver endpoint %s must be https", endpoint)
+		}
+	}
+
+	data := map[string]string{
+		clusterinfo.SourceAPIServerEndpoints: strings.Join(apiServerEndpoints, ","),
+	}
+	if len(tunnelEndpoints) != 0 {
+		data[clusterinfo.SourceTunnelEndpoints] = strings.Join(tunnelEndpoints, ",")
+	}
+	if caFile != "" {
+		caData, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("fail to read ca file %s: %s", caFile, err)
+		}
+		if _, err := clusterinfo.CAHashes(caData); err != nil {
+			return err
+		}
+		data[clusterinfo.SourceCACertificates] = string(caData)
+	}
+
+	if err := co.applySource(data); err != nil {
+		return err
+	}
+	if err := co.applyReader(); err != nil {
+		return err
+	}
+
+	secret, err := co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Get(clusterinfo.SigningKeysName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		secret = &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterinfo.SigningKeysName, Namespace: clusterinfo.SourceNamespace},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{},
+		}
+		if err := addSigningKey(secret); err != nil {
+			return err
+		}
+		if secret, err = co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Create(secret); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	return co.printPins(secret)
+}
+
+// RunRotateKey adds a new signing key, edge nodes can pin the new key after the cluster
+// info signed by it is published, then the old key can be retired.
+func (co *ClusterInfoOptions) RunRotateKey() error {
+	secret, err := co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Get(clusterinfo.SigningKeysName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return errors.New("no signing key is found, run yurtctl cluster-info init first")
+	} else if err != nil {
+		return err
+	}
+
+	secret = secret.DeepCopy()
+	if err := addSigningKey(secret); err != nil {
+		return err
+	}
+	if secret, err = co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Update(secret); err != nil {
+		return err
+	}
+	return co.printPins(secret)
+}
+
+// RunRetireKey removes the signing key of pin, the last signing key can't be retired
+func (co *ClusterInfoOptions) RunRetireKey(pin string) error {
+	secret, err := co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Get(clusterinfo.SigningKeysName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return errors.New("no signing key is found")
+	} else if err != nil {
+		return err
+	}
+
+	name := strings.TrimPrefix(strings.ToLower(pin), "sha256:")
+	if _, ok := secret.Data[name]; !ok {
+		return fmt.Errorf("signing key %s is not found", pin)
+	}
+	if len(secret.Data) == 1 {
+		return fmt.Errorf("signing key %s is the last one, rotate the key before it's retired", pin)
+	}
+
+	secret = secret.DeepCopy()
+	delete(secret.Data, name)
+	if secret, err = co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Update(secret); err != nil {
+		return err
+	}
+	return co.printPins(secret)
+}
+
+// RunFetch fetches the cluster info from server, verifies it's signed by one of the pinned
+// keys and server is kube-apiserver with the CA in it, then writes the bootstrap kubeconfig
+// with the CA and the first apiserver endpoint in it.
+func (co *ClusterInfoOptions) RunFetch(server string, pins []string, token, output string) error {
+	if !strings.HasPrefix(server, "https://") {
+		return fmt.Errorf("server %s must be https", server)
+	}
+	if len(pins) == 0 {
+		return errors.New("at least one pin of signing key must be set")
+	}
+
+	bundle, err := fetchBundle(server, pins)
+	if err != nil {
+		return err
+	}
+
+	kubeconfig, err := bootstrapKubeconfig(bundle, token)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = co.out.Write(kubeconfig)
+		return err
+	}
+	if err := ioutil.WriteFile(output, kubeconfig, 0600); err != nil {
+		return fmt.Errorf("fail to write kubeconfig %s: %s", output, err)
+	}
+	fmt.Fprintf(co.out, "bootstrap kubeconfig is written to %s, apiserver: %s, tunnel: %s, signed at %s\n", output,
+		strings.Join(bundle.APIServerEndpoints, ","), strings.Join(bundle.TunnelEndpoints, ","), bundle.IssuedAt.Format(time.RFC3339))
+	return nil
+}
+
+// applySource creates or updates the source configmap of cluster info
+func (co *ClusterInfoOptions) applySource(data map[string]string) error {
+	cm, err := co.clientSet.CoreV1().ConfigMaps(clusterinfo.SourceNamespace).Get(clusterinfo.SourceName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = co.clientSet.CoreV1().ConfigMaps(clusterinfo.SourceNamespace).Create(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterinfo.SourceName, Namespace: clusterinfo.SourceNamespace},
+			Data:       data,
+		})
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	cm = cm.DeepCopy()
+	cm.Data = data
+	_, err = co.clientSet.CoreV1().ConfigMaps(clusterinfo.SourceNamespace).Update(cm)
+	return err
+}
+
+// applyReader allows anyone, including anonymous users, to get the published cluster info,
+// like the cluster-info configmap of kubeadm. it's safe because nothing secret is in it.
+func (co *ClusterInfoOptions) applyReader() error {
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: readerName, Namespace: clusterinfo.Namespace},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups:     []string{""},
+			Resources:     []string{"configmaps"},
+			ResourceNames: []string{clusterinfo.Name},
+			Verbs:         []string{"get"},
+		}},
+	}
+	if _, err := co.clientSet.RbacV1().Roles(clusterinfo.Namespace).Create(role); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: readerName, Namespace: clusterinfo.Namespace},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: readerName},
+		Subjects: []rbacv1.Subject{
+			{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "system:unauthenticated"},
+			{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "system:authenticated"},
+		},
+	}
+	if _, err := co.clientSet.RbacV1().RoleBindings(clusterinfo.Namespace).Create(binding); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// printPins prints the pins of signing keys in secret
+func (co *ClusterInfoOptions) printPins(secret *v1.Secret) error {
+	keys, err := clusterinfo.SigningKeys(secret)
+	if err != nil {
+		return err
+	}
+
+	pins := make([]string, 0, len(keys))
+	for id := range keys {
+		pins = append(pins, id)
+	}
+	sort.Strings(pins)
+	fmt.Fprintf(co.out, "the cluster info is signed by the keys(pin any of them with yurtctl cluster-info fetch --pin):\n%s\n",
+		strings.Join(pins, "\n"))
+	return nil
+}
+
+// addSigningKey generates a signing key and adds it to secret
+func addSigningKey(secret *v1.Secret) error {
+	name, key, err := clusterinfo.GenerateSigningKey()
+	if err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	secret.Data[name] = key
+	return nil
+}
+
+// fetchBundle gets the published cluster info from server anonymously. the certificate
+// of server is not verified during the handshake, because the CA is not known before
+// the cluster info is verified, it's verified by the CA in the cluster info after that.
+func fetchBundle(server string, pins []string) (*clusterinfo.Bundle, error) {
+	client := &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	url := fmt.Sprintf("%s/api/v1/namespaces/%s/configmaps/%s", strings.TrimSuffix(server, "/"), clusterinfo.Namespace, clusterinfo.Name)
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to get cluster info from %s: %s, %s", server, resp.Status, string(b))
+	}
+
+	var cm v1.ConfigMap
+	if err := json.Unmarshal(b, &cm); err != nil {
+		return nil, fmt.Errorf("fail to decode cluster info: %s", err)
+	}
+
+	bundle, err := clusterinfo.Verify(cm.Data, pins)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyServer(resp.TLS, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// verifyServer verifies the certificate of server is issued by the CA in bundle, so the
+// cluster info is served by kube-apiserver of the cluster instead of a replay of it.
+func verifyServer(state *tls.ConnectionState, bundle *clusterinfo.Bundle) error {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return errors.New("no certificate of server is found")
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(bundle.CACertificates)) {
+		return errors.New("no ca certificate is found in cluster info")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	if _, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	}); err != nil {
+		return fmt.Errorf("certificate of server is not issued by the ca in cluster info: %s", err)
+	}
+	return nil
+}
+
+// bootstrapKubeconfig returns the kubeconfig with the CA and the first apiserver endpoint
+// in bundle, the bootstrap token is the credential of it if token is set.
+func bootstrapKubeconfig(bundle *clusterinfo.Bundle, token string) ([]byte, error) {
+	if len(bundle.APIServerEndpoints) == 0 {
+		return nil, errors.New("no apiserver endpoint is found in cluster info")
+	}
+
+	context := bootstrapUser + "@kubernetes"
+	config := clientcmdv1.Config{
+		APIVersion: "v1",
+		Kind:       "Config",
+		Clusters: []clientcmdv1.NamedCluster{{
+			Name: "kubernetes",
+			Cluster: clientcmdv1.Cluster{
+				Server:                   bundle.APIServerEndpoints[0],
+				CertificateAuthorityData: []byte(bundle.CACertificates),
+			},
+		}},
+		AuthInfos: []clientcmdv1.NamedAuthInfo{{
+			Name:     bootstrapUser,
+			AuthInfo: clientcmdv1.AuthInfo{Token: token},
+		}},
+		Contexts: []clientcmdv1.NamedContext{{
+			Name:    context,
+			Context: clientcmdv1.Context{Cluster: "kubernetes", AuthInfo: bootstrapUser},
+		}},
+		CurrentContext: context,
+	}
+	return yaml.Marshal(&config)
+}
@@ -0,0 +1,229 @@
+package clusterinfo
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/alibaba/openyurt/pkg/clusterinfo"
+)
+
+func newTestCA(t *testing.T) string {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("fail to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, privateKey)
+	if err != nil {
+		t.Fatalf("fail to create certificate: %s", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func getSecret(t *testing.T, co *ClusterInfoOptions) *v1.Secret {
+	secret, err := co.clientSet.CoreV1().Secrets(clusterinfo.SourceNamespace).Get(clusterinfo.SigningKeysName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("fail to get signing keys: %s", err)
+	}
+	return secret
+}
+
+func TestRunInit(t *testing.T) {
+	var out bytes.Buffer
+	co := &ClusterInfoOptions{
+		clientSet: fake.NewSimpleClientset(),
+		restCfg:   &rest.Config{Host: "https://1.2.3.4:6443"},
+		out:       &out,
+	}
+
+	if err := co.RunInit(nil, []string{"1.2.3.4:10262"}, ""); err != nil {
+		t.Fatalf("fail to init cluster info: %s", err)
+	}
+
+	cm, err := co.clientSet.CoreV1().ConfigMaps(clusterinfo.SourceNamespace).Get(clusterinfo.SourceName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("fail to get source of cluster info: %s", err)
+	}
+	if cm.Data[clusterinfo.SourceAPIServerEndpoints] != "https://1.2.3.4:6443" || cm.Data[clusterinfo.SourceTunnelEndpoints] != "1.2.3.4:10262" {
+		t.Errorf("expect endpoints of kubeconfig and tunnel, but got %v", cm.Data)
+	}
+
+	if _, err := co.clientSet.RbacV1().RoleBindings(clusterinfo.Namespace).Get(readerName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expect rolebinding %s is created, but got %s", readerName, err)
+	}
+
+	secret := getSecret(t, co)
+	if len(secret.Data) != 1 {
+		t.Fatalf("expect 1 signing key, but got %d", len(secret.Data))
+	}
+	for name := range secret.Data {
+		if !strings.Contains(out.String(), "sha256:"+name) {
+			t.Errorf("expect pin sha256:%s is printed, but got %s", name, out.String())
+		}
+	}
+
+	// signing key is kept when init again
+	if err := co.RunInit([]string{"https://5.6.7.8:6443"}, nil, ""); err != nil {
+		t.Fatalf("fail to init cluster info again: %s", err)
+	}
+	if again := getSecret(t, co); len(again.Data) != 1 || !bytes.Equal(again.Data[firstKey(secret)], secret.Data[firstKey(secret)]) {
+		t.Errorf("expect signing key is kept, but got %v", again.Data)
+	}
+
+	if err := co.RunInit([]string{"http://1.2.3.4:8080"}, nil, ""); err == nil {
+		t.Errorf("expect error for insecure apiserver endpoint, but got nil")
+	}
+}
+
+func firstKey(secret *v1.Secret) string {
+	for name := range secret.Data {
+		return name
+	}
+	return ""
+}
+
+func TestRotateAndRetireKey(t *testing.T) {
+	co := &ClusterInfoOptions{
+		clientSet: fake.NewSimpleClientset(),
+		restCfg:   &rest.Config{Host: "https://1.2.3.4:6443"},
+		out:       &bytes.Buffer{},
+	}
+
+	if err := co.RunRotateKey(); err == nil {
+		t.Errorf("expect error for rotating key before init, but got nil")
+	}
+	if err := co.RunInit(nil, nil, ""); err != nil {
+		t.Fatalf("fail to init cluster info: %s", err)
+	}
+	oldKey := firstKey(getSecret(t, co))
+
+	if err := co.RunRetireKey("sha256:" + oldKey); err == nil {
+		t.Errorf("expect error for retiring the last key, but got nil")
+	}
+
+	if err := co.RunRotateKey(); err != nil {
+		t.Fatalf("fail to rotate key: %s", err)
+	}
+	if secret := getSecret(t, co); len(secret.Data) != 2 {
+		t.Fatalf("expect 2 signing keys after rotation, but got %d", len(secret.Data))
+	}
+
+	if err := co.RunRetireKey("sha256:" + oldKey); err != nil {
+		t.Fatalf("fail to retire key: %s", err)
+	}
+	secret := getSecret(t, co)
+	if _, ok := secret.Data[oldKey]; ok || len(secret.Data) != 1 {
+		t.Errorf("expect old key is retired, but got %v", secret.Data)
+	}
+
+	if err := co.RunRetireKey("sha256:" + oldKey); err == nil {
+		t.Errorf("expect error for retiring a key that doesn't exist, but got nil")
+	}
+}
+
+func TestRunFetch(t *testing.T) {
+	name, key, err := clusterinfo.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("fail to generate signing key: %s", err)
+	}
+	_, otherKey, _ := clusterinfo.GenerateSigningKey()
+	pin := "sha256:" + name
+
+	var published map[string]string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/namespaces/kube-public/configmaps/yurt-cluster-info" {
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(&v1.ConfigMap{Data: published})
+	}))
+	defer server.Close()
+	serverCA := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	otherCA := newTestCA(t)
+
+	testcases := map[string]struct {
+		caData    string
+		key       ed25519.PrivateKey
+		expectErr bool
+	}{
+		"verified": {
+			caData: serverCA,
+			key:    key,
+		},
+		"signed by unpinned key": {
+			caData:    serverCA,
+			key:       otherKey,
+			expectErr: true,
+		},
+		"server is not issued by ca in cluster info": {
+			caData:    otherCA,
+			key:       key,
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			bundle := &clusterinfo.Bundle{
+				APIServerEndpoints: []string{"https://1.2.3.4:6443"},
+				CACertificates:     tt.caData,
+				TunnelEndpoints:    []string{"1.2.3.4:10262"},
+			}
+			keys := map[string]ed25519.PrivateKey{clusterinfo.KeyID(tt.key.Public().(ed25519.PublicKey)): tt.key}
+			if published, err = clusterinfo.Sign(bundle, keys); err != nil {
+				t.Fatalf("fail to sign bundle: %s", err)
+			}
+
+			var out bytes.Buffer
+			co := &ClusterInfoOptions{out: &out}
+			output := filepath.Join(t.TempDir(), "bootstrap-kubelet.conf")
+			err := co.RunFetch(server.URL, []string{pin}, "abcdef.0123456789abcdef", output)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("fail to fetch cluster info: %s", err)
+			}
+
+			config, err := clientcmd.LoadFromFile(output)
+			if err != nil {
+				t.Fatalf("fail to load kubeconfig: %s", err)
+			}
+			cluster := config.Clusters["kubernetes"]
+			if cluster.Server != "https://1.2.3.4:6443" || string(cluster.CertificateAuthorityData) != serverCA {
+				t.Errorf("expect server and ca of cluster info, but got %v", cluster)
+			}
+			if token := config.AuthInfos[bootstrapUser].Token; token != "abcdef.0123456789abcdef" {
+				t.Errorf("expect bootstrap token in kubeconfig, but got %s", token)
+			}
+		})
+	}
+}
@@ -3,8 +3,21 @@ package cmd
 import (
 	"github.com/spf13/cobra"
 
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/backup"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/clusterinfo"
 	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/convert"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/debug"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/decommission"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/diff"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/doctor"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/emergencyrevert"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/migrate"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/prepull"
 	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/revert"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/seedcache"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/status"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/systemdunit"
+	"github.com/alibaba/openyurt/pkg/yurtctl/cmd/token"
 )
 
 // NewYurtctlCommand creates a new yurtctl command
@@ -17,8 +30,25 @@ func NewYurtctlCommand() *cobra.Command {
 
 	// add kubeconfig to persistent flags
 	cmds.PersistentFlags().String("kubeconfig", "", "The path to the kubeconfig file")
+	cmds.PersistentFlags().String("context", "", "The name of the kubeconfig context to use")
+	cmds.PersistentFlags().String("cluster", "",
+		"The alias of the cluster to use, which refers to a kubeconfig and context in $HOME/.yurtctl/clusters.yaml(or $YURTCTL_CLUSTERS)")
 	cmds.AddCommand(convert.NewConvertCmd())
 	cmds.AddCommand(revert.NewRevertCmd())
+	cmds.AddCommand(emergencyrevert.NewEmergencyRevertCmd())
+	cmds.AddCommand(migrate.NewMigrateCmd())
+	cmds.AddCommand(prepull.NewPrePullCmd())
+	cmds.AddCommand(token.NewTokenCmd())
+	cmds.AddCommand(status.NewStatusCmd())
+	cmds.AddCommand(debug.NewDebugCmd())
+	cmds.AddCommand(decommission.NewDecommissionCmd())
+	cmds.AddCommand(doctor.NewDoctorCmd())
+	cmds.AddCommand(diff.NewDiffCmd())
+	cmds.AddCommand(clusterinfo.NewClusterInfoCmd())
+	cmds.AddCommand(backup.NewBackupCmd())
+	cmds.AddCommand(backup.NewRestoreCmd())
+	cmds.AddCommand(seedcache.NewSeedCacheCmd())
+	cmds.AddCommand(systemdunit.NewSystemdUnitCmd())
 
 	return cmds
 }
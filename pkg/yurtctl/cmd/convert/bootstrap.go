@@ -0,0 +1,438 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	"github.com/alibaba/openyurt/pkg/yurtctl/util/templates"
+)
+
+const (
+	// bootstrapDefaultProfile is the profile of the edge nodes that are not in a node pool
+	bootstrapDefaultProfile = "default"
+	// bootstrapScriptPath is the path of bootstrap script on the edge nodes
+	bootstrapScriptPath = "/var/lib/openyurt/openyurt-bootstrap.sh"
+)
+
+const (
+	// bootstrapScriptFile converts the node after it joins the cluster
+	bootstrapScriptFile = "openyurt-bootstrap.sh"
+	// bootstrapCloudInitFile is the cloud-init user data that installs and runs the bootstrap script
+	bootstrapCloudInitFile = "cloud-init.yaml"
+	// bootstrapJoinConfigFile is the JoinConfiguration of kubeadm that labels the node when it's registered
+	bootstrapJoinConfigFile = "kubeadm-join-config.yaml"
+	// bootstrapKubeadmConfigTemplateFile is the KubeadmConfigTemplate of cluster-api for the edge nodes
+	bootstrapKubeadmConfigTemplateFile = "kubeadm-config-template.yaml"
+)
+
+// BootstrapProfile is the conversion of the edge nodes of a node pool when they are provisioned,
+// the artifacts of each profile are written to the sub directory of its name.
+type BootstrapProfile struct {
+	// NodePool is the node pool that the nodes join, it's empty for the nodes out of node pools
+	NodePool string `json:"nodePool,omitempty"`
+	// ServantJobParams are the parameters that setup_edgenode of the servant converts the nodes with
+	ServantJobParams kubeutil.ServantJobParams `json:"servantJobParams"`
+}
+
+// Name returns the name of profile, which is the name of sub directory of its artifacts
+func (bp BootstrapProfile) Name() string {
+	if bp.NodePool == "" {
+		return bootstrapDefaultProfile
+	}
+	return "nodepool-" + bp.NodePool
+}
+
+func (bp BootstrapProfile) String() string {
+	details := servantJobDetails(&bp.ServantJobParams)
+	return fmt.Sprintf("%s(%s)", bp.Name(), strings.Join(details, ", "))
+}
+
+// labels returns the labels of the nodes of profile
+func (bp BootstrapProfile) labels() map[string]string {
+	labels := map[string]string{constants.LabelEdgeWorker: "true"}
+	if bp.NodePool != "" {
+		labels[constants.LabelNodePool] = bp.NodePool
+	}
+	return labels
+}
+
+// validate makes sure the profile can be rendered into the artifacts, the node pool is
+// used as a label value and a directory, and the parameters are passed to shell commands.
+func (bp BootstrapProfile) validate() error {
+	if bp.NodePool != "" {
+		if err := validateBootstrapPool(bp.NodePool); err != nil {
+			return err
+		}
+	}
+	if bp.ServantJobParams.Action != kubeutil.ServantJobActionConvert {
+		return fmt.Errorf("action %s of profile %s is not %s", bp.ServantJobParams.Action, bp.Name(), kubeutil.ServantJobActionConvert)
+	}
+	return bp.ServantJobParams.Validate()
+}
+
+// bootstrapPools returns the node pools that have their own proxies or registry mirrors in order
+func bootstrapPools(proxy ProxyConfig, mirror MirrorConfig) []string {
+	pools := make([]string, 0, len(proxy.NodePoolHTTPSProxies)+len(mirror.NodePoolMirrors))
+	for pool := range proxy.NodePoolHTTPSProxies {
+		pools = append(pools, pool)
+	}
+	for pool := range mirror.NodePoolMirrors {
+		if _, ok := proxy.NodePoolHTTPSProxies[pool]; !ok {
+			pools = append(pools, pool)
+		}
+	}
+	sort.Strings(pools)
+	return pools
+}
+
+// validateBootstrapPools makes sure the node pools can be the profiles of bootstrap artifacts
+func validateBootstrapPools(proxy ProxyConfig, mirror MirrorConfig) error {
+	for _, pool := range bootstrapPools(proxy, mirror) {
+		if err := validateBootstrapPool(pool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateBootstrapPool(pool string) error {
+	if errs := validation.IsDNS1123Label(pool); len(errs) != 0 {
+		return fmt.Errorf("node pool %s is invalid for bootstrap artifacts: %s", pool, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// UseBootstrapArtifacts replaces the servant jobs with the artifacts that bake the conversion into
+// the bootstrap of edge nodes(cloud-init, kubeadm or cluster-api), so the nodes provisioned by
+// autoscaling groups come up converted. the existing edge nodes are neither converted nor labeled
+// and annotated, they are expected to be replaced by the provisioned nodes. the artifacts are
+// written for the nodes out of node pools, and for each node pool with its own proxy or mirrors.
+func (p *ConversionPlan) UseBootstrapArtifacts(dir string, proxy ProxyConfig, mirror MirrorConfig) {
+	if !hasComponent(p.Components, ComponentYurtHub) {
+		return
+	}
+
+	actions := make([]Action, 0, len(p.Actions)+1)
+	for _, action := range p.Actions {
+		switch {
+		case action.Type == ActionRunServantJobs, action.Type == ActionPrePullImages,
+			action.Type == ActionWriteBootstrapArtifacts:
+			continue
+		case action.Type == ActionLabelNode && action.Key == constants.LabelEdgeWorker && action.Value == "true":
+			continue
+		case action.Type == ActionAnnotateNode && action.Key == constants.AnnotationAutonomy:
+			continue
+		}
+		actions = append(actions, action)
+	}
+
+	pools := append([]string{""}, bootstrapPools(proxy, mirror)...)
+	profiles := make([]BootstrapProfile, 0, len(pools))
+	for _, pool := range pools {
+		params := kubeutil.ServantJobParams{
+			Action:          kubeutil.ServantJobActionConvert,
+			Provider:        string(p.Provider),
+			RegistryMirrors: mirror.mirrorsForPool(pool).String(),
+		}
+		if poolProxy := proxy.proxyForPool(pool); poolProxy != "" {
+			params.HTTPSProxy, params.NoProxy = poolProxy, proxy.NoProxy
+		}
+		profiles = append(profiles, BootstrapProfile{NodePool: pool, ServantJobParams: params})
+	}
+
+	p.Actions = append(actions, Action{
+		Type:              ActionWriteBootstrapArtifacts,
+		Dir:               dir,
+		BootstrapProfiles: profiles,
+	})
+	delete(p.Manifests, manifestServantJob)
+}
+
+// bootstrapContext is the context of the templates of bootstrap artifacts
+type bootstrapContext struct {
+	Provider     string
+	ServantImage string
+	PKIDir       string
+	Params       kubeutil.ServantJobParams
+	// NodeLabels are the labels of node in the format of --node-labels of kubelet
+	NodeLabels string
+	// NodePatch is the merge patch that labels and annotates the node
+	NodePatch string
+	// Name is the name of KubeadmConfigTemplate
+	Name string
+	// Script is the rendered bootstrap script
+	Script string
+}
+
+func newBootstrapContext(provider Provider, profile BootstrapProfile) (*bootstrapContext, error) {
+	labels := profile.labels()
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	nodeLabels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		nodeLabels = append(nodeLabels, key+"="+labels[key])
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels":      labels,
+			"annotations": map[string]string{constants.AnnotationAutonomy: "true"},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &bootstrapContext{
+		Provider:     string(provider),
+		ServantImage: constants.ServantImage,
+		PKIDir:       "/etc/kubernetes/pki",
+		Params:       profile.ServantJobParams,
+		NodeLabels:   strings.Join(nodeLabels, ","),
+		NodePatch:    string(patch),
+		Name:         "openyurt-edge",
+	}
+	if provider == ProviderMinikube {
+		c.PKIDir = "/var/lib/minikube/certs"
+	}
+	if profile.NodePool != "" {
+		c.Name += "-" + profile.NodePool
+	}
+	return c, nil
+}
+
+// WriteBootstrapArtifacts writes the artifacts of profiles to the sub directories of dir, the
+// artifacts of each profile are:
+// 1. openyurt-bootstrap.sh converts the node after it joins the cluster, it runs setup_edgenode
+// of yurtctl-servant by docker or containerd like the servant job, then labels and annotates the
+// node as an autonomous edge node.
+// 2. cloud-init.yaml installs the bootstrap script and runs it in background.
+// 3. kubeadm-join-config.yaml labels the node when it's registered by kubeadm join.
+// 4. kubeadm-config-template.yaml is the KubeadmConfigTemplate of cluster-api that runs the
+// bootstrap script after kubeadm join.
+func WriteBootstrapArtifacts(dir string, provider Provider, profiles []BootstrapProfile) error {
+	if dir == "" {
+		return fmt.Errorf("directory of bootstrap artifacts is not specified")
+	}
+	for _, profile := range profiles {
+		if err := profile.validate(); err != nil {
+			return err
+		}
+		artifacts, err := renderBootstrapArtifacts(provider, profile)
+		if err != nil {
+			return fmt.Errorf("fail to render bootstrap artifacts of %s: %s", profile.Name(), err)
+		}
+
+		profileDir := filepath.Join(dir, profile.Name())
+		if err := os.MkdirAll(profileDir, 0755); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(artifacts))
+		for name := range artifacts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			// the artifacts may contain the password of proxy
+			perm := os.FileMode(0600)
+			if name == bootstrapScriptFile {
+				perm = 0700
+			}
+			if err := ioutil.WriteFile(filepath.Join(profileDir, name), []byte(artifacts[name]), perm); err != nil {
+				return err
+			}
+		}
+		klog.Infof("bootstrap artifacts of %s are written to %s", profile.String(), profileDir)
+	}
+	return nil
+}
+
+// renderBootstrapArtifacts returns the contents of artifacts of profile by their file names
+func renderBootstrapArtifacts(provider Provider, profile BootstrapProfile) (map[string]string, error) {
+	c, err := newBootstrapContext(provider, profile)
+	if err != nil {
+		return nil, err
+	}
+	c.Script, err = templates.SubsituteTemplate(bootstrapScriptTemplate, c)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := map[string]string{bootstrapScriptFile: c.Script}
+	for name, tmpl := range map[string]string{
+		bootstrapCloudInitFile:             bootstrapCloudInitTemplate,
+		bootstrapJoinConfigFile:            bootstrapJoinConfigTemplate,
+		bootstrapKubeadmConfigTemplateFile: bootstrapKubeadmConfigTemplate,
+	} {
+		content, err := templates.SubsituteTemplate(tmpl, c)
+		if err != nil {
+			return nil, err
+		}
+		artifacts[name] = content
+	}
+	return artifacts, nil
+}
+
+// bootstrapScriptTemplate converts the node after it joins the cluster by kubeadm, the servant
+// reaches kube-apiserver in kubelet.conf instead of the service of kubernetes in the servant job.
+const bootstrapScriptTemplate = `#!/usr/bin/env bash
+# generated by yurtctl convert, do not edit
+# it converts the node to an edge node of openyurt after the node joins the cluster,
+# by setup_edgenode of yurtctl-servant like the servant job of yurtctl convert.
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+KUBELET_CONF=${KUBELET_CONF:-/etc/kubernetes/kubelet.conf}
+KUBELET_CLIENT_PEM=${KUBELET_CLIENT_PEM:-/var/lib/kubelet/pki/kubelet-client-current.pem}
+PKI_DIR=${PKI_DIR:-{{.PKIDir}}}
+OPENYURT_DIR=${OPENYURT_DIR:-/var/lib/openyurt}
+SERVANT_IMAGE=${SERVANT_IMAGE:-{{.ServantImage}}}
+NODE_NAME=${NODE_NAME:-$(hostname | tr '[:upper:]' '[:lower:]')}
+# JOIN_TIMEOUT is the seconds that the node is waited for to join the cluster
+JOIN_TIMEOUT=${JOIN_TIMEOUT:-1800}
+CONVERTED_FILE=$OPENYURT_DIR/bootstrap-converted
+
+log() {
+    echo "$(date +"%m/%d/%Y-%T-%Z") [YURT_BOOTSTRAP] $@"
+}
+
+if [ -f $CONVERTED_FILE ]; then
+    log "node $NODE_NAME is converted already"
+    exit 0
+fi
+
+# kubelet.conf and the client certificate of kubelet are written after the node joins
+waited=0
+until [ -f $KUBELET_CONF ] && [ -f $KUBELET_CLIENT_PEM ]; do
+    if [ $waited -ge $JOIN_TIMEOUT ]; then
+        log "node $NODE_NAME doesn't join the cluster in $JOIN_TIMEOUT seconds"
+        exit 1
+    fi
+    sleep 5
+    waited=$((waited+5))
+done
+
+server=$(sed -n 's|^ *server: *https://||p' $KUBELET_CONF | head -1)
+server=${server%%/*}
+APISERVER_HOST=${server%:*}
+APISERVER_PORT=443
+if [ "$APISERVER_HOST" != "$server" ]; then
+    APISERVER_PORT=${server##*:}
+fi
+
+SERVANT_ENVS=("NODE_NAME=$NODE_NAME" "MINIKUBE_PKI_DIR=$PKI_DIR")
+{{- if .Params.HTTPSProxy}}
+SERVANT_ENVS+=("YURTHUB_HTTPS_PROXY={{.Params.HTTPSProxy}}")
+{{- end}}
+{{- if .Params.NoProxy}}
+SERVANT_ENVS+=("YURTHUB_NO_PROXY={{.Params.NoProxy}}")
+{{- end}}
+{{- if .Params.RegistryMirrors}}
+SERVANT_ENVS+=("REGISTRY_MIRRORS={{.Params.RegistryMirrors}}")
+{{- end}}
+{{- if .Params.HubWatchdog}}
+SERVANT_ENVS+=("YURTHUB_WATCHDOG=true")
+{{- end}}
+SERVANT_CMD="sed -i 's|__kubernetes_service_host__|$APISERVER_HOST|g;s|__kubernetes_service_port_https__|$APISERVER_PORT|g;s|__node_name__|$NODE_NAME|g' /var/lib/openyurt/setup_edgenode && cp /var/lib/openyurt/setup_edgenode /tmp && nsenter -t 1 -m -u -n -i /var/tmp/setup_edgenode convert {{.Provider}}"
+
+log "convert node $NODE_NAME by $SERVANT_IMAGE"
+if command -v docker >/dev/null 2>&1 && docker info >/dev/null 2>&1; then
+    args=()
+    for env in "${SERVANT_ENVS[@]}"; do
+        args+=(-e "$env")
+    done
+    docker run --rm --privileged --pid=host --net=host -v /var/tmp:/tmp "${args[@]}" \
+        $SERVANT_IMAGE /bin/sh -c "$SERVANT_CMD"
+elif command -v ctr >/dev/null 2>&1; then
+    # ctr only pulls the images of fully qualified names
+    image=$SERVANT_IMAGE
+    case ${image%%/*} in
+        *.*|*:*|localhost) ;;
+        *) image=docker.io/$image ;;
+    esac
+    args=()
+    for env in "${SERVANT_ENVS[@]}"; do
+        args+=(--env "$env")
+    done
+    ctr -n k8s.io images pull $image
+    ctr -n k8s.io run --rm --privileged --net-host --with-ns pid:/proc/1/ns/pid \
+        --mount type=bind,src=/var/tmp,dst=/tmp,options=rbind:rw "${args[@]}" \
+        $image openyurt-bootstrap /bin/sh -c "$SERVANT_CMD"
+else
+    log "neither docker nor containerd is found"
+    exit 1
+fi
+
+# kubelet can label and annotate its own node, except the labels of kubernetes.io
+curl -sS --fail -o /dev/null -X PATCH \
+    --cert $KUBELET_CLIENT_PEM --key $KUBELET_CLIENT_PEM --cacert $PKI_DIR/ca.crt \
+    -H "Content-Type: application/merge-patch+json" \
+    -d '{{.NodePatch}}' \
+    https://$APISERVER_HOST:$APISERVER_PORT/api/v1/nodes/$NODE_NAME
+
+mkdir -p $OPENYURT_DIR
+touch $CONVERTED_FILE
+log "node $NODE_NAME is converted"
+`
+
+// bootstrapCloudInitTemplate runs the bootstrap script as a transient service, so it doesn't
+// block the other commands of user data(like kubeadm join) while it waits for the node to join.
+const bootstrapCloudInitTemplate = `#cloud-config
+# generated by yurtctl convert, merge it into the user data of the edge nodes
+write_files:
+- path: ` + bootstrapScriptPath + `
+  owner: root:root
+  permissions: "0700"
+  encoding: b64
+  content: {{.Script | b64enc}}
+runcmd:
+- [systemd-run, --unit=openyurt-bootstrap, ` + bootstrapScriptPath + `]
+`
+
+// bootstrapJoinConfigTemplate labels the node when it's registered, before the bootstrap script runs
+const bootstrapJoinConfigTemplate = `# generated by yurtctl convert, merge it into the config of kubeadm join --config
+apiVersion: kubeadm.k8s.io/v1beta2
+kind: JoinConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    node-labels: "{{.NodeLabels}}"
+`
+
+// bootstrapKubeadmConfigTemplate is referred by the MachineDeployment or MachinePool of the edge nodes
+const bootstrapKubeadmConfigTemplate = `# generated by yurtctl convert, refer to it by the MachineDeployment or MachinePool
+# of the edge nodes, or merge it into their KubeadmConfigTemplate
+apiVersion: bootstrap.cluster.x-k8s.io/v1beta1
+kind: KubeadmConfigTemplate
+metadata:
+  name: {{.Name}}
+spec:
+  template:
+    spec:
+      files:
+      - path: ` + bootstrapScriptPath + `
+        owner: root:root
+        permissions: "0700"
+        encoding: base64
+        content: {{.Script | b64enc}}
+      joinConfiguration:
+        nodeRegistration:
+          kubeletExtraArgs:
+            node-labels: "{{.NodeLabels}}"
+      postKubeadmCommands:
+      - ` + bootstrapScriptPath + `
+`
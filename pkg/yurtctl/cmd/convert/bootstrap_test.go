@@ -0,0 +1,226 @@
+package convert
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+func newTestBootstrapPlan() *ConversionPlan {
+	nodes := newTestNodes("cloud1", "edge1", "edge2")
+	nodes[2].Labels = map[string]string{constants.LabelNodePool: "hangzhou"}
+	proxy := ProxyConfig{
+		HTTPSProxy:           "http://10.0.0.1:3128",
+		NoProxy:              "10.96.0.0/12",
+		NodePoolHTTPSProxies: map[string]string{"hangzhou": "http://10.1.0.1:3128"},
+	}
+	mirror := MirrorConfig{
+		Mirrors: kubeutil.RegistryMirrors{"docker.io": {"https://mirror.example.com"}},
+		NodePoolMirrors: map[string]kubeutil.RegistryMirrors{
+			"beijing": {"docker.io": {"https://mirror.beijing.example.com"}},
+		},
+	}
+
+	plan := NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK,
+		[]Component{ComponentYurtHub, ComponentControllerManager}, proxy, mirror)
+	plan.UseBootstrapArtifacts("/tmp/artifacts", proxy, mirror)
+	plan.AddPrePullImages()
+	plan.EnableHubWatchdog()
+	return plan
+}
+
+func TestUseBootstrapArtifacts(t *testing.T) {
+	plan := newTestBootstrapPlan()
+
+	actions := make([]ActionType, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		actions = append(actions, action.Type)
+	}
+	// the existing edge nodes are neither labeled nor converted
+	expected := []ActionType{ActionLabelNode, ActionCreateDeployment, ActionDeleteServiceAccount, ActionWriteBootstrapArtifacts}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expect actions %v, but got %v", expected, actions)
+	}
+	if plan.Actions[0].Value != "false" {
+		t.Errorf("expect cloud nodes are labeled, but got %s", plan.Actions[0].String())
+	}
+	if _, ok := plan.Manifests[manifestServantJob]; ok {
+		t.Errorf("expect manifest %s is removed", manifestServantJob)
+	}
+
+	action := plan.Actions[3]
+	if action.Dir != "/tmp/artifacts" {
+		t.Errorf("expect artifacts are written to /tmp/artifacts, but got %s", action.Dir)
+	}
+	profiles := map[string]kubeutil.ServantJobParams{}
+	for _, profile := range action.BootstrapProfiles {
+		profiles[profile.Name()] = profile.ServantJobParams
+		if err := profile.validate(); err != nil {
+			t.Errorf("expect profile %s is valid, but got %v", profile.Name(), err)
+		}
+	}
+	expectedProfiles := map[string]kubeutil.ServantJobParams{
+		"default": {
+			Action: kubeutil.ServantJobActionConvert, Provider: "ack", HubWatchdog: true,
+			HTTPSProxy: "http://10.0.0.1:3128", NoProxy: "10.96.0.0/12",
+			RegistryMirrors: "docker.io=https://mirror.example.com",
+		},
+		"nodepool-beijing": {
+			Action: kubeutil.ServantJobActionConvert, Provider: "ack", HubWatchdog: true,
+			HTTPSProxy: "http://10.0.0.1:3128", NoProxy: "10.96.0.0/12",
+			RegistryMirrors: "docker.io=https://mirror.beijing.example.com",
+		},
+		"nodepool-hangzhou": {
+			Action: kubeutil.ServantJobActionConvert, Provider: "ack", HubWatchdog: true,
+			HTTPSProxy: "http://10.1.0.1:3128", NoProxy: "10.96.0.0/12",
+			RegistryMirrors: "docker.io=https://mirror.example.com",
+		},
+	}
+	if !reflect.DeepEqual(profiles, expectedProfiles) {
+		t.Errorf("expect profiles %v, but got %v", expectedProfiles, profiles)
+	}
+
+	// the plan is not changed without yurt-hub
+	plan = NewConversionPlan(newTestNodes("cloud1", "edge1"), []string{"cloud1"}, ProviderACK,
+		[]Component{ComponentControllerManager}, ProxyConfig{}, MirrorConfig{})
+	before := len(plan.Actions)
+	plan.UseBootstrapArtifacts("/tmp/artifacts", ProxyConfig{}, MirrorConfig{})
+	if len(plan.Actions) != before {
+		t.Errorf("expect %d actions without yurt-hub, but got %d", before, len(plan.Actions))
+	}
+}
+
+func TestWriteBootstrapArtifacts(t *testing.T) {
+	plan := newTestBootstrapPlan()
+	profiles := plan.Actions[len(plan.Actions)-1].BootstrapProfiles
+	dir := t.TempDir()
+	if err := WriteBootstrapArtifacts(dir, ProviderACK, profiles); err != nil {
+		t.Fatalf("failed to write bootstrap artifacts, %v", err)
+	}
+
+	profileDir := filepath.Join(dir, "nodepool-hangzhou")
+	b, err := ioutil.ReadFile(filepath.Join(profileDir, bootstrapScriptFile))
+	if err != nil {
+		t.Fatalf("failed to read bootstrap script, %v", err)
+	}
+	script := string(b)
+	for _, want := range []string{
+		`SERVANT_ENVS+=("YURTHUB_HTTPS_PROXY=http://10.1.0.1:3128")`,
+		`SERVANT_ENVS+=("REGISTRY_MIRRORS=docker.io=https://mirror.example.com")`,
+		`SERVANT_ENVS+=("YURTHUB_WATCHDOG=true")`,
+		"/var/tmp/setup_edgenode convert ack",
+		`"openyurt.io/node-pool":"hangzhou"`,
+		`"node.beta.alibabacloud.com/autonomy":"true"`,
+		"PKI_DIR=${PKI_DIR:-/etc/kubernetes/pki}",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("expect %q in bootstrap script, but got\n%s", want, script)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(profileDir, bootstrapScriptFile)); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("expect bootstrap script is executable, but got %v, %v", info, err)
+	}
+	if bash, err := exec.LookPath("bash"); err == nil {
+		if out, err := exec.Command(bash, "-n", filepath.Join(profileDir, bootstrapScriptFile)).CombinedOutput(); err != nil {
+			t.Errorf("expect bootstrap script is valid bash, but got %v, %s", err, out)
+		}
+	}
+
+	var cloudInit struct {
+		WriteFiles []struct {
+			Path    string `json:"path"`
+			Content string `json:"content"`
+		} `json:"write_files"`
+		RunCmd [][]string `json:"runcmd"`
+	}
+	b, err = ioutil.ReadFile(filepath.Join(profileDir, bootstrapCloudInitFile))
+	if err != nil {
+		t.Fatalf("failed to read cloud-init, %v", err)
+	}
+	if !strings.HasPrefix(string(b), "#cloud-config\n") {
+		t.Errorf("expect cloud-init starts with #cloud-config, but got %s", b)
+	}
+	if err := yaml.Unmarshal(b, &cloudInit); err != nil {
+		t.Fatalf("failed to parse cloud-init, %v", err)
+	}
+	if len(cloudInit.WriteFiles) != 1 || cloudInit.WriteFiles[0].Path != bootstrapScriptPath {
+		t.Fatalf("expect bootstrap script is written by cloud-init, but got %s", b)
+	}
+	if content, err := base64.StdEncoding.DecodeString(cloudInit.WriteFiles[0].Content); err != nil || string(content) != script {
+		t.Errorf("expect bootstrap script in cloud-init, but got %v", err)
+	}
+	if len(cloudInit.RunCmd) != 1 || cloudInit.RunCmd[0][len(cloudInit.RunCmd[0])-1] != bootstrapScriptPath {
+		t.Errorf("expect bootstrap script is run by cloud-init, but got %v", cloudInit.RunCmd)
+	}
+
+	var template struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Spec struct {
+			Template struct {
+				Spec struct {
+					JoinConfiguration struct {
+						NodeRegistration struct {
+							KubeletExtraArgs map[string]string `json:"kubeletExtraArgs"`
+						} `json:"nodeRegistration"`
+					} `json:"joinConfiguration"`
+					PostKubeadmCommands []string `json:"postKubeadmCommands"`
+				} `json:"spec"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	b, err = ioutil.ReadFile(filepath.Join(profileDir, bootstrapKubeadmConfigTemplateFile))
+	if err != nil {
+		t.Fatalf("failed to read KubeadmConfigTemplate, %v", err)
+	}
+	if err := yaml.Unmarshal(b, &template); err != nil {
+		t.Fatalf("failed to parse KubeadmConfigTemplate, %v", err)
+	}
+	spec := template.Spec.Template.Spec
+	if template.Kind != "KubeadmConfigTemplate" || template.Metadata.Name != "openyurt-edge-hangzhou" ||
+		spec.JoinConfiguration.NodeRegistration.KubeletExtraArgs["node-labels"] != "alibabacloud.com/is-edge-worker=true,openyurt.io/node-pool=hangzhou" ||
+		!reflect.DeepEqual(spec.PostKubeadmCommands, []string{bootstrapScriptPath}) {
+		t.Errorf("unexpected KubeadmConfigTemplate %s", b)
+	}
+
+	for _, name := range []string{"default", "nodepool-beijing"} {
+		for _, file := range []string{bootstrapScriptFile, bootstrapCloudInitFile, bootstrapJoinConfigFile, bootstrapKubeadmConfigTemplateFile} {
+			if _, err := os.Stat(filepath.Join(dir, name, file)); err != nil {
+				t.Errorf("expect %s of profile %s, but got %v", file, name, err)
+			}
+		}
+	}
+}
+
+func TestWriteBootstrapArtifactsWithInvalidProfile(t *testing.T) {
+	convert := kubeutil.ServantJobParams{Action: kubeutil.ServantJobActionConvert, Provider: "ack"}
+	testcases := map[string]BootstrapProfile{
+		"invalid node pool": {NodePool: "../hangzhou", ServantJobParams: convert},
+		"invalid action":    {ServantJobParams: kubeutil.ServantJobParams{Action: kubeutil.ServantJobActionRevert}},
+		"invalid proxy":     {ServantJobParams: kubeutil.ServantJobParams{Action: kubeutil.ServantJobActionConvert, Provider: "ack", HTTPSProxy: "ftp://10.0.0.1"}},
+	}
+	for name, profile := range testcases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := WriteBootstrapArtifacts(dir, ProviderACK, []BootstrapProfile{profile}); err == nil {
+				t.Errorf("expect error for %s", name)
+			}
+		})
+	}
+
+	if err := validateBootstrapPools(ProxyConfig{NodePoolHTTPSProxies: map[string]string{"Hang_Zhou": "http://10.1.0.1:3128"}}, MirrorConfig{}); err == nil {
+		t.Errorf("expect error for node pool that is not a dns label")
+	}
+}
@@ -4,21 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
-	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 	"k8s.io/klog"
 
-	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
 	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
-	strutil "github.com/alibaba/openyurt/pkg/yurtctl/util/strings"
 )
 
 // Provider signifies the provider type
@@ -31,11 +25,69 @@ const (
 	ProviderACK Provider = "ack"
 )
 
+// Component signifies a component of yurt cluster that can be installed by convert
+type Component string
+
+const (
+	// ComponentYurtHub is the yurt-hub deployed on every edge node
+	ComponentYurtHub Component = "hub"
+	// ComponentControllerManager is the yurt-controller-manager that replaces the node-controller
+	ComponentControllerManager Component = "controller-manager"
+	// ComponentTunnel is the yurt-tunnel that connects cloud to edge nodes
+	ComponentTunnel Component = "tunnel"
+	// ComponentAppManager is the yurt-app-manager that manages applications by node pool
+	ComponentAppManager Component = "appmanager"
+)
+
+// componentInfo describes how a component can be installed
+type componentInfo struct {
+	// dependencies are the components that must be installed with the component
+	dependencies []Component
+	// installable is false if the component can not be installed by this version of yurtctl
+	installable bool
+}
+
+var components = map[Component]componentInfo{
+	ComponentYurtHub: {installable: true},
+	// pods on an autonomous node are not evicted by yurt-controller-manager,
+	// so the node must be able to run offline by yurt-hub.
+	ComponentControllerManager: {dependencies: []Component{ComponentYurtHub}, installable: true},
+	// yurt-tunnel-agent connects to kube-apiserver through yurt-hub
+	ComponentTunnel:     {dependencies: []Component{ComponentYurtHub}},
+	ComponentAppManager: {dependencies: []Component{ComponentControllerManager}},
+}
+
+// defaultComponents are the components installed if --components is not set
+var defaultComponents = []string{string(ComponentYurtHub), string(ComponentControllerManager)}
+
 // ConvertOptions has the information that required by convert operation
 type ConvertOptions struct {
 	clientSet  *kubernetes.Clientset
 	CloudNodes []string
 	Provider   Provider
+	Components []Component
+	// Proxy is the proxy that yurt-hub on edge nodes connects kube-apiserver through
+	Proxy ProxyConfig
+	// Mirror is the registry mirrors of container runtime on edge nodes
+	Mirror MirrorConfig
+	// PrePull pulls the images needed by the servant jobs on the edge nodes ahead
+	PrePull bool
+	// HubWatchdog installs the yurthub-watchdog service on the edge nodes
+	HubWatchdog bool
+	// DryRun prints the conversion plan without applying it
+	DryRun bool
+	// SavePlan is the path that the conversion plan is saved to
+	SavePlan string
+	// RetryFailed re-runs the servant jobs on the nodes that failed in the last conversion
+	RetryFailed bool
+	// BootstrapArtifactsDir is the directory that the artifacts for converting the edge nodes
+	// when they are provisioned are written to, instead of running the servant jobs
+	BootstrapArtifactsDir string
+	// plan is the conversion plan loaded from file, which is applied
+	// instead of the plan for the current cluster
+	plan *ConversionPlan
+	// state is the stored state of the last conversion, which is loaded for RetryFailed
+	state *ConversionState
 }
 
 // NewConvertOptions creates a new ConvertOptions
@@ -66,6 +118,45 @@ func NewConvertCmd() *cobra.Command {
 		"The list of cloud nodes.(e.g. -c cloudnode1,cloudnode2)")
 	cmd.Flags().StringP("provider", "p", "ack",
 		"The provider of the original Kubernetes cluster.")
+	cmd.Flags().StringSlice("components", defaultComponents,
+		"The components to install, other components(like tunnel) can be provided by existing infrastructure. "+
+			"(e.g. --components=hub,controller-manager)")
+	cmd.Flags().String("edge-https-proxy", "",
+		"The proxy(http, https or socks5) that yurt-hub on edge nodes connects kube-apiserver through. "+
+			"(e.g. --edge-https-proxy=http://proxy.example.com:3128)")
+	cmd.Flags().String("edge-no-proxy", "",
+		"The addresses that yurt-hub on edge nodes connects directly instead of through the proxy. "+
+			"(e.g. --edge-no-proxy=10.0.0.0/8,.example.com)")
+	cmd.Flags().StringSlice("node-pool-https-proxies", []string{},
+		"The proxies of the edge nodes in node pools, they override --edge-https-proxy. "+
+			"(e.g. --node-pool-https-proxies=hangzhou=http://10.0.0.1:3128,beijing=socks5://10.1.0.1:1080)")
+	cmd.Flags().StringArray("registry-mirror", []string{},
+		"The mirrors of a registry for the container runtime(docker or containerd) on edge nodes, it can be set "+
+			"for multiple registries, docker only supports the mirrors of docker.io. "+
+			"(e.g. --registry-mirror=docker.io=https://mirror1.example.com,https://mirror2.example.com)")
+	cmd.Flags().StringArray("node-pool-registry-mirror", []string{},
+		"The mirrors of a registry for the edge nodes in a node pool, they override --registry-mirror of the registry. "+
+			"(e.g. --node-pool-registry-mirror=hangzhou/docker.io=https://mirror.hangzhou.example.com)")
+	cmd.Flags().Bool("prepull", false,
+		"Pull the images needed by the servant jobs on the edge nodes before they run, so the servant jobs don't time out over slow links.")
+	cmd.Flags().Bool("enable-hub-watchdog", false,
+		"Install the yurthub-watchdog service on the edge nodes, it falls back kubelet to connect kube-apiserver "+
+			"directly when yurt-hub keeps crashing and kube-apiserver is reachable, and switches kubelet back to "+
+			"yurt-hub after yurt-hub is healthy again.")
+	cmd.Flags().Bool("dry-run", false,
+		"Print the conversion plan without applying it.")
+	cmd.Flags().String("save-plan", "",
+		"The path that the conversion plan is saved to, the saved plan can be applied by --plan.")
+	cmd.Flags().String("plan", "",
+		"The path of the saved conversion plan to apply, --cloud-nodes, --provider, --components, --prepull, "+
+			"--enable-hub-watchdog and --bootstrap-artifacts-dir are ignored.")
+	cmd.Flags().Bool("retry-failed", false,
+		"Re-run the servant jobs only on the nodes that failed in the last conversion, with the parameters of "+
+			"the last conversion, the other flags of the conversion are ignored.")
+	cmd.Flags().String("bootstrap-artifacts-dir", "",
+		"Write the artifacts(bootstrap script, cloud-init user data, kubeadm join config and cluster-api "+
+			"KubeadmConfigTemplate) that convert the edge nodes when they are provisioned(e.g. by autoscaling "+
+			"groups) to the directory, instead of running the servant jobs on the existing edge nodes.")
 
 	return cmd
 }
@@ -84,117 +175,317 @@ func (co *ConvertOptions) Complete(flags *pflag.FlagSet) error {
 	}
 	co.Provider = Provider(pStr)
 
-	// parse kubeconfig and generate the clientset
-	kbCfgPath, err := flags.GetString("kubeconfig")
+	cpStrs, err := flags.GetStringSlice("components")
 	if err != nil {
 		return err
 	}
+	co.Components = make([]Component, 0, len(cpStrs))
+	for _, cpStr := range cpStrs {
+		co.Components = append(co.Components, Component(strings.TrimSpace(cpStr)))
+	}
 
-	if kbCfgPath == "" {
-		if home := homedir.HomeDir(); home != "" {
-			kbCfgPath = filepath.Join(home, ".kube", "config")
-		}
+	co.Proxy.HTTPSProxy, err = flags.GetString("edge-https-proxy")
+	if err != nil {
+		return err
 	}
 
-	if kbCfgPath == "" {
-		kbCfgPath = os.Getenv("KUBECONFIG")
+	co.Proxy.NoProxy, err = flags.GetString("edge-no-proxy")
+	if err != nil {
+		return err
 	}
 
-	if kbCfgPath == "" {
-		return errors.New("either '--kubeconfig', '$HOME/.kube/config' or '$KUBECONFIG' need to be set")
+	poolProxies, err := flags.GetStringSlice("node-pool-https-proxies")
+	if err != nil {
+		return err
+	}
+	co.Proxy.NodePoolHTTPSProxies, err = parseNodePoolProxies(poolProxies)
+	if err != nil {
+		return err
+	}
+
+	mirrors, err := flags.GetStringArray("registry-mirror")
+	if err != nil {
+		return err
+	}
+	co.Mirror.Mirrors, err = kubeutil.ParseRegistryMirrors(mirrors)
+	if err != nil {
+		return err
+	}
+
+	poolMirrors, err := flags.GetStringArray("node-pool-registry-mirror")
+	if err != nil {
+		return err
+	}
+	co.Mirror.NodePoolMirrors, err = parseNodePoolMirrors(poolMirrors)
+	if err != nil {
+		return err
 	}
 
-	restCfg, err := clientcmd.BuildConfigFromFlags("", kbCfgPath)
+	co.PrePull, err = flags.GetBool("prepull")
 	if err != nil {
 		return err
 	}
 
-	co.clientSet, err = kubernetes.NewForConfig(restCfg)
+	co.HubWatchdog, err = flags.GetBool("enable-hub-watchdog")
 	if err != nil {
 		return err
 	}
+
+	co.DryRun, err = flags.GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+
+	co.SavePlan, err = flags.GetString("save-plan")
+	if err != nil {
+		return err
+	}
+
+	planPath, err := flags.GetString("plan")
+	if err != nil {
+		return err
+	}
+	if planPath != "" {
+		co.plan, err = LoadConversionPlan(planPath)
+		if err != nil {
+			return err
+		}
+		co.Provider, co.Components = co.plan.Provider, co.plan.Components
+	}
+
+	co.RetryFailed, err = flags.GetBool("retry-failed")
+	if err != nil {
+		return err
+	}
+
+	co.BootstrapArtifactsDir, err = flags.GetString("bootstrap-artifacts-dir")
+	if err != nil {
+		return err
+	}
+
+	// parse kubeconfig and generate the clientset
+	co.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+
+	if co.RetryFailed && co.plan == nil {
+		co.state, err = LoadConversionState(co.clientSet)
+		if err != nil {
+			return err
+		}
+		co.Provider, co.Components = co.state.Plan.Provider, co.state.Plan.Components
+	}
 	return nil
 }
 
 // Validate makes sure provided values for ConvertOptions are valid
 func (co *ConvertOptions) Validate() error {
+	if co.RetryFailed && co.plan != nil {
+		return errors.New("--retry-failed can not be used with --plan")
+	}
 	if co.Provider != ProviderMinikube &&
 		co.Provider != ProviderACK {
 		return fmt.Errorf("unknown provider: %s, valid providers are: minikube, ack",
 			co.Provider)
 	}
+	if err := validateProxy(co.Proxy); err != nil {
+		return err
+	}
+	if co.BootstrapArtifactsDir != "" && co.plan == nil {
+		if co.RetryFailed {
+			return errors.New("--retry-failed can not be used with --bootstrap-artifacts-dir")
+		}
+		if !hasComponent(co.Components, ComponentYurtHub) {
+			return fmt.Errorf("--bootstrap-artifacts-dir requires component %s", ComponentYurtHub)
+		}
+		if err := validateBootstrapPools(co.Proxy, co.Mirror); err != nil {
+			return err
+		}
+	}
+	return validateComponents(co.Components)
+}
+
+// parseNodePoolProxies parses the proxies of node pools in the format of "pool=proxy"
+func parseNodePoolProxies(items []string) (map[string]string, error) {
+	proxies := map[string]string{}
+	for _, item := range items {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("proxy of node pool(%s) is invalid, the format is pool=proxy",
+				kubeutil.RedactProxy(item))
+		}
+		proxies[parts[0]] = parts[1]
+	}
+	return proxies, nil
+}
+
+// parseNodePoolMirrors parses the registry mirrors of node pools in the format of "pool/registry=endpoints"
+func parseNodePoolMirrors(items []string) (map[string]kubeutil.RegistryMirrors, error) {
+	itemsByPool := map[string][]string{}
+	for _, item := range items {
+		parts := strings.SplitN(item, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "=") {
+			return nil, fmt.Errorf("registry mirror of node pool(%s) is invalid, the format is pool/registry=endpoint1,endpoint2", item)
+		}
+		itemsByPool[parts[0]] = append(itemsByPool[parts[0]], parts[1])
+	}
+
+	mirrors := map[string]kubeutil.RegistryMirrors{}
+	for pool, poolItems := range itemsByPool {
+		poolMirrors, err := kubeutil.ParseRegistryMirrors(poolItems)
+		if err != nil {
+			return nil, fmt.Errorf("registry mirrors of node pool %s are invalid: %s", pool, err)
+		}
+		mirrors[pool] = poolMirrors
+	}
+	return mirrors, nil
+}
+
+// validateProxy makes sure the proxies of yurt-hub can be rendered into the servant jobs
+func validateProxy(pc ProxyConfig) error {
+	params := kubeutil.ServantJobParams{
+		Action:   kubeutil.ServantJobActionConvert,
+		Provider: string(ProviderACK),
+		NoProxy:  pc.NoProxy,
+	}
+	proxies := []string{pc.HTTPSProxy}
+	for _, proxy := range pc.NodePoolHTTPSProxies {
+		proxies = append(proxies, proxy)
+	}
+	for _, proxy := range proxies {
+		params.HTTPSProxy = proxy
+		if err := params.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// RunConvert performs the conversion
-func (co *ConvertOptions) RunConvert() error {
-	// 1. label nodes as cloud node or edge node
-	nodeLst, err := co.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
-	if err != nil {
-		return err
+// validateComponents makes sure the components are known, can be installed,
+// and the components they depend on are installed too.
+func validateComponents(cps []Component) error {
+	if len(cps) == 0 {
+		return errors.New("no component is specified")
 	}
-	var edgeNodeNames []string
-	for _, node := range nodeLst.Items {
-		if strutil.IsInStringLst(co.CloudNodes, node.GetName()) {
-			// label node as cloud node
-			klog.Infof("mark %s as the cloud-node", node.GetName())
-			if _, err := kubeutil.LabelNode(co.clientSet,
-				&node, constants.LabelEdgeWorker, "false"); err != nil {
-				return err
+
+	for _, cp := range cps {
+		info, ok := components[cp]
+		if !ok {
+			return fmt.Errorf("unknown component: %s, valid components are: %s, %s, %s, %s",
+				cp, ComponentYurtHub, ComponentControllerManager, ComponentTunnel, ComponentAppManager)
+		}
+		if !info.installable {
+			return fmt.Errorf("component %s can not be installed by this version of yurtctl", cp)
+		}
+		for _, dep := range info.dependencies {
+			if !hasComponent(cps, dep) {
+				return fmt.Errorf("component %s depends on component %s, which is not specified", cp, dep)
 			}
-			continue
 		}
-		// label node as edge node
-		klog.Infof("mark %s as the edge-node", node.GetName())
-		edgeNodeNames = append(edgeNodeNames, node.GetName())
-		edgeNode, err := kubeutil.LabelNode(co.clientSet,
-			&node, constants.LabelEdgeWorker, "true")
+	}
+	return nil
+}
+
+func hasComponent(cps []Component, cp Component) bool {
+	for _, c := range cps {
+		if c == cp {
+			return true
+		}
+	}
+	return false
+}
+
+// RunConvert performs the conversion, the plan of conversion is printed
+// before it's applied.
+func (co *ConvertOptions) RunConvert() error {
+	if co.RetryFailed {
+		return co.retryFailed()
+	}
+
+	plan := co.plan
+	if plan == nil {
+		nodeLst, err := co.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
 		if err != nil {
 			return err
 		}
-		// 2. annotate all edge nodes as autonomous
-		// NOTE pods running on an non-autonomous will be evicted, even though
-		// the node is marked as an edge node.
-		// TODO should we allow user to decide if a node is autonomous or not ?
-		klog.Infof("mark %s as autonomous node", node.GetName())
-		if _, err := kubeutil.AnnotateNode(co.clientSet,
-			edgeNode, constants.AnnotationAutonomy, "true"); err != nil {
+		plan = NewConversionPlan(nodeLst.Items, co.CloudNodes, co.Provider, co.Components, co.Proxy, co.Mirror)
+		if co.BootstrapArtifactsDir != "" {
+			plan.UseBootstrapArtifacts(co.BootstrapArtifactsDir, co.Proxy, co.Mirror)
+			if len(plan.EdgeNodes) != 0 {
+				klog.Warningf("the existing edge nodes %v are not converted with --bootstrap-artifacts-dir, "+
+					"replace them by the nodes provisioned with the artifacts", plan.EdgeNodes)
+			}
+		}
+		if co.PrePull {
+			plan.AddPrePullImages()
+		}
+		if co.HubWatchdog {
+			plan.EnableHubWatchdog()
+		}
+	}
+	plan.Print(os.Stdout)
+
+	if co.SavePlan != "" {
+		if err := plan.Save(co.SavePlan); err != nil {
 			return err
 		}
+		klog.Infof("conversion plan is saved to %s", co.SavePlan)
+	}
+
+	if co.DryRun {
+		return nil
 	}
+	results, err := plan.Apply(co.clientSet)
+	state := &ConversionState{Plan: plan, Results: results, Completed: err == nil}
+	return co.finishConversion(state, results, err)
+}
 
-	// 3. deploy yurt controller manager
-	dpObj, err := kubeutil.YamlToObject([]byte(constants.YurtControllerManagerDeployment))
+// retryFailed re-runs the servant jobs on the nodes that failed in the last conversion,
+// the failed servant jobs that are kept for debugging are deleted before they're re-run.
+func (co *ConvertOptions) retryFailed() error {
+	plan, err := co.state.RetryPlan()
 	if err != nil {
 		return err
 	}
-	ecmDp, ok := dpObj.(*appsv1.Deployment)
-	if !ok {
-		return errors.New("fail to assert YurtControllerManagerDeployment")
+	if plan == nil {
+		klog.Infof("no node failed in the last conversion, nothing to retry")
+		return nil
 	}
-	if _, err := co.clientSet.AppsV1().Deployments("kube-system").Create(ecmDp); err != nil {
-		return err
-	}
-	klog.Info("deploy the yurt controller manager")
+	plan.Print(os.Stdout)
 
-	// 4. delete the node-controller service account to disable node-controller
-	if err := co.clientSet.CoreV1().ServiceAccounts("kube-system").
-		Delete("node-controller", &metav1.DeleteOptions{
-			PropagationPolicy: &kubeutil.PropagationPolicy,
-		}); err != nil {
-		klog.Errorf("fail to delete ServiceAccount(node-controller): %s", err)
-		return err
+	if co.DryRun {
+		return nil
 	}
-
-	// 5. deploy yurt-hub and reset the kubelet service
-	klog.Infof("deploying the yurt-hub and resetting the kubelet service...")
-	if err := kubeutil.RunServantJobs(co.clientSet, map[string]string{
-		"provider": string(co.Provider),
-		"action":   "convert",
-	}, edgeNodeNames); err != nil {
-		klog.Errorf("fail to run ServantJobs: %s", err)
-		return err
+	for _, action := range plan.Actions {
+		if action.Type != ActionRunServantJobs {
+			continue
+		}
+		if err := kubeutil.DeleteServantJobs(co.clientSet, *action.ServantJobParams, action.Nodes,
+			kubeutil.WaitServantJobTimeout, kubeutil.CheckServantJobPeriod); err != nil {
+			return err
+		}
 	}
 
+	results, err := plan.Apply(co.clientSet)
+	co.state.MergeResults(results)
+	return co.finishConversion(co.state, results, err)
+}
+
+// finishConversion prints the results of servant jobs and stores the conversion state,
+// so the failed nodes can be retried by --retry-failed.
+func (co *ConvertOptions) finishConversion(state *ConversionState, results []kubeutil.ServantJobResult, applyErr error) error {
+	if len(results) != 0 {
+		kubeutil.PrintServantJobResults(os.Stdout, results)
+	}
+	if err := SaveConversionState(co.clientSet, state); err != nil {
+		klog.Errorf("%s", err)
+	}
+	if applyErr != nil {
+		return applyErr
+	}
+	if err := kubeutil.ServantJobsError(results); err != nil {
+		return fmt.Errorf("%s, retry the failed nodes by --retry-failed", err)
+	}
 	return nil
 }
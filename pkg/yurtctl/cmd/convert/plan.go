@@ -0,0 +1,485 @@
+package convert
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	strutil "github.com/alibaba/openyurt/pkg/yurtctl/util/strings"
+)
+
+// ActionType signifies the type of action in the conversion plan
+type ActionType string
+
+const (
+	// ActionLabelNode labels the node with the key and value
+	ActionLabelNode ActionType = "LabelNode"
+	// ActionAnnotateNode annotates the node with the key and value
+	ActionAnnotateNode ActionType = "AnnotateNode"
+	// ActionCreateDeployment creates the deployment in the manifest
+	ActionCreateDeployment ActionType = "CreateDeployment"
+	// ActionDeleteServiceAccount deletes the service account
+	ActionDeleteServiceAccount ActionType = "DeleteServiceAccount"
+	// ActionPrePullImages pulls the images on the nodes before the servant jobs run
+	ActionPrePullImages ActionType = "PrePullImages"
+	// ActionRunServantJobs runs the servant jobs in the manifest on the nodes
+	ActionRunServantJobs ActionType = "RunServantJobs"
+	// ActionWriteBootstrapArtifacts writes the artifacts that convert the edge nodes when
+	// they are provisioned, instead of the servant jobs
+	ActionWriteBootstrapArtifacts ActionType = "WriteBootstrapArtifacts"
+)
+
+const (
+	// manifestYurtControllerManager is the name of manifest of yurt controller manager deployment
+	manifestYurtControllerManager = "yurt-controller-manager"
+	// manifestServantJob is the name of manifest of servant job template
+	manifestServantJob = "servant-job"
+)
+
+// Action is a step of the conversion plan
+type Action struct {
+	Type      ActionType `json:"type"`
+	Nodes     []string   `json:"nodes,omitempty"`
+	Key       string     `json:"key,omitempty"`
+	Value     string     `json:"value,omitempty"`
+	Namespace string     `json:"namespace,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	// Manifest is the name of manifest in ConversionPlan.Manifests that the action applies
+	Manifest string `json:"manifest,omitempty"`
+	// Images are the images that pulled on the nodes
+	Images []string `json:"images,omitempty"`
+	// ServantJobParams are used to render the manifest of servant job
+	ServantJobParams *kubeutil.ServantJobParams `json:"servantJobParams,omitempty"`
+	// Dir is the directory that the bootstrap artifacts are written to
+	Dir string `json:"dir,omitempty"`
+	// BootstrapProfiles are the edge nodes that the bootstrap artifacts are written for
+	BootstrapProfiles []BootstrapProfile `json:"bootstrapProfiles,omitempty"`
+}
+
+// Manifest is a manifest that applied by the conversion plan, the hash
+// is verified before the manifest is applied.
+type Manifest struct {
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+}
+
+// ConversionPlan is the result of planning phase of convert, it records all the actions
+// and manifests of the conversion, so the conversion can be reviewed(and saved) before
+// it's applied.
+type ConversionPlan struct {
+	Provider   Provider            `json:"provider"`
+	Components []Component         `json:"components"`
+	CloudNodes []string            `json:"cloudNodes"`
+	EdgeNodes  []string            `json:"edgeNodes"`
+	Actions    []Action            `json:"actions"`
+	Manifests  map[string]Manifest `json:"manifests,omitempty"`
+}
+
+// ProxyConfig is the proxy that yurt-hub on edge nodes connects kube-apiserver through,
+// edge sites often reach the cloud only via the proxy of site.
+type ProxyConfig struct {
+	HTTPSProxy string
+	NoProxy    string
+	// NodePoolHTTPSProxies overrides HTTPSProxy for the edge nodes in the node pools
+	NodePoolHTTPSProxies map[string]string
+}
+
+// proxyFor returns the proxy of yurt-hub on node
+func (pc ProxyConfig) proxyFor(node *v1.Node) string {
+	return pc.proxyForPool(node.Labels[constants.LabelNodePool])
+}
+
+// proxyForPool returns the proxy of yurt-hub on the nodes of pool, pool is empty for
+// the nodes that are not in a node pool.
+func (pc ProxyConfig) proxyForPool(pool string) string {
+	if proxy, ok := pc.NodePoolHTTPSProxies[pool]; ok && pool != "" {
+		return proxy
+	}
+	return pc.HTTPSProxy
+}
+
+// MirrorConfig is the registry mirrors of container runtime on edge nodes, edge sites
+// often pull images from the registry mirrors of site.
+type MirrorConfig struct {
+	Mirrors kubeutil.RegistryMirrors
+	// NodePoolMirrors override the mirrors of registries for the edge nodes in the node pools
+	NodePoolMirrors map[string]kubeutil.RegistryMirrors
+}
+
+// mirrorsFor returns the registry mirrors on node
+func (mc MirrorConfig) mirrorsFor(node *v1.Node) kubeutil.RegistryMirrors {
+	return mc.mirrorsForPool(node.Labels[constants.LabelNodePool])
+}
+
+// mirrorsForPool returns the registry mirrors on the nodes of pool, pool is empty for
+// the nodes that are not in a node pool.
+func (mc MirrorConfig) mirrorsForPool(pool string) kubeutil.RegistryMirrors {
+	if mirrors, ok := mc.NodePoolMirrors[pool]; ok && pool != "" {
+		return mc.Mirrors.Merge(mirrors)
+	}
+	return mc.Mirrors
+}
+
+// servantGroup is the edge nodes that are converted by the servant jobs of the same parameters
+type servantGroup struct {
+	proxy   string
+	mirrors string
+}
+
+// NewConversionPlan plans the conversion of the cluster of nodes, it doesn't
+// access the cluster, so the plan is determined by the arguments only.
+func NewConversionPlan(nodes []v1.Node, cloudNodes []string, provider Provider, cps []Component, proxy ProxyConfig, mirror MirrorConfig) *ConversionPlan {
+	plan := &ConversionPlan{
+		Provider:   provider,
+		Components: cps,
+		CloudNodes: []string{},
+		EdgeNodes:  []string{},
+		Actions:    []Action{},
+		Manifests:  map[string]Manifest{},
+	}
+
+	// 1. label nodes as cloud node or edge node
+	edgeNodesByGroup := map[servantGroup][]string{}
+	for i := range nodes {
+		node := &nodes[i]
+		if strutil.IsInStringLst(cloudNodes, node.GetName()) {
+			plan.CloudNodes = append(plan.CloudNodes, node.GetName())
+		} else {
+			plan.EdgeNodes = append(plan.EdgeNodes, node.GetName())
+			group := servantGroup{proxy: proxy.proxyFor(node), mirrors: mirror.mirrorsFor(node).String()}
+			edgeNodesByGroup[group] = append(edgeNodesByGroup[group], node.GetName())
+		}
+	}
+	if len(plan.CloudNodes) != 0 {
+		plan.Actions = append(plan.Actions, Action{
+			Type:  ActionLabelNode,
+			Nodes: plan.CloudNodes,
+			Key:   constants.LabelEdgeWorker,
+			Value: "false",
+		})
+	}
+	if len(plan.EdgeNodes) != 0 {
+		plan.Actions = append(plan.Actions, Action{
+			Type:  ActionLabelNode,
+			Nodes: plan.EdgeNodes,
+			Key:   constants.LabelEdgeWorker,
+			Value: "true",
+		})
+
+		// 2. annotate all edge nodes as autonomous, node can not run offline without yurt-hub
+		// NOTE pods running on an non-autonomous will be evicted, even though
+		// the node is marked as an edge node.
+		if hasComponent(cps, ComponentYurtHub) {
+			plan.Actions = append(plan.Actions, Action{
+				Type:  ActionAnnotateNode,
+				Nodes: plan.EdgeNodes,
+				Key:   constants.AnnotationAutonomy,
+				Value: "true",
+			})
+		}
+	}
+
+	if hasComponent(cps, ComponentControllerManager) {
+		// 3. deploy yurt controller manager
+		plan.addManifest(manifestYurtControllerManager, constants.YurtControllerManagerDeployment)
+		plan.Actions = append(plan.Actions, Action{
+			Type:      ActionCreateDeployment,
+			Namespace: "kube-system",
+			Name:      "yurt-ctrl-mgr",
+			Manifest:  manifestYurtControllerManager,
+		})
+
+		// 4. delete the node-controller service account to disable node-controller
+		plan.Actions = append(plan.Actions, Action{
+			Type:      ActionDeleteServiceAccount,
+			Namespace: "kube-system",
+			Name:      "node-controller",
+		})
+	}
+
+	if hasComponent(cps, ComponentYurtHub) && len(plan.EdgeNodes) != 0 {
+		// 5. deploy yurt-hub and reset the kubelet service, the edge nodes behind
+		// different proxies or with different registry mirrors are converted by
+		// separate actions
+		plan.addManifest(manifestServantJob, constants.ServantJobTemplate)
+		groups := make([]servantGroup, 0, len(edgeNodesByGroup))
+		for group := range edgeNodesByGroup {
+			groups = append(groups, group)
+		}
+		sort.Slice(groups, func(i, j int) bool {
+			if groups[i].proxy != groups[j].proxy {
+				return groups[i].proxy < groups[j].proxy
+			}
+			return groups[i].mirrors < groups[j].mirrors
+		})
+		for _, group := range groups {
+			params := &kubeutil.ServantJobParams{
+				Action:          kubeutil.ServantJobActionConvert,
+				Provider:        string(provider),
+				RegistryMirrors: group.mirrors,
+			}
+			if group.proxy != "" {
+				params.HTTPSProxy, params.NoProxy = group.proxy, proxy.NoProxy
+			}
+			plan.Actions = append(plan.Actions, Action{
+				Type:             ActionRunServantJobs,
+				Nodes:            edgeNodesByGroup[group],
+				Manifest:         manifestServantJob,
+				ServantJobParams: params,
+			})
+		}
+	}
+
+	return plan
+}
+
+// AddPrePullImages adds the action that pulls the images needed by the servant jobs on the edge
+// nodes ahead, so the servant jobs don't time out in pulling images over slow links. the action
+// is added before the first servant jobs action, and the plan is not changed if no servant job runs.
+func (p *ConversionPlan) AddPrePullImages() {
+	for i, action := range p.Actions {
+		if action.Type == ActionPrePullImages {
+			return
+		}
+		if action.Type != ActionRunServantJobs {
+			continue
+		}
+		prepull := Action{
+			Type:   ActionPrePullImages,
+			Nodes:  p.EdgeNodes,
+			Images: kubeutil.ServantJobImages(constants.DefaultYurtHubImage),
+		}
+		p.Actions = append(p.Actions[:i], append([]Action{prepull}, p.Actions[i:]...)...)
+		return
+	}
+}
+
+// EnableHubWatchdog makes the servant jobs that convert the edge nodes install the yurthub-watchdog
+// service, it falls back kubelet to connect kube-apiserver directly when yurt-hub keeps crashing
+// and kube-apiserver is reachable, so a broken yurt-hub doesn't take the node offline.
+func (p *ConversionPlan) EnableHubWatchdog() {
+	for _, action := range p.Actions {
+		if action.Type == ActionRunServantJobs && action.ServantJobParams != nil &&
+			action.ServantJobParams.Action == kubeutil.ServantJobActionConvert {
+			action.ServantJobParams.HubWatchdog = true
+		}
+		for i := range action.BootstrapProfiles {
+			action.BootstrapProfiles[i].ServantJobParams.HubWatchdog = true
+		}
+	}
+}
+
+func (p *ConversionPlan) addManifest(name, content string) {
+	p.Manifests[name] = Manifest{
+		Content: content,
+		Hash:    manifestHash(content),
+	}
+}
+
+// manifest returns the content of manifest after its hash is verified
+func (p *ConversionPlan) manifest(name string) (string, error) {
+	m, ok := p.Manifests[name]
+	if !ok {
+		return "", fmt.Errorf("manifest %s is not found in plan", name)
+	}
+
+	if hash := manifestHash(m.Content); hash != m.Hash {
+		return "", fmt.Errorf("hash of manifest %s is %s, but %s is recorded in plan", name, hash, m.Hash)
+	}
+	return m.Content, nil
+}
+
+func manifestHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// Print prints the summary of plan
+func (p *ConversionPlan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Conversion plan(provider: %s, components: %v):\n", p.Provider, p.Components)
+	for i, action := range p.Actions {
+		fmt.Fprintf(w, "%d. %s\n", i+1, action.String())
+	}
+	for name, m := range p.Manifests {
+		fmt.Fprintf(w, "manifest %s: sha256:%s\n", name, m.Hash)
+	}
+}
+
+func (a Action) String() string {
+	switch a.Type {
+	case ActionLabelNode, ActionAnnotateNode:
+		return fmt.Sprintf("%s %s=%s on nodes %v", a.Type, a.Key, a.Value, a.Nodes)
+	case ActionCreateDeployment:
+		return fmt.Sprintf("%s %s/%s from manifest %s", a.Type, a.Namespace, a.Name, a.Manifest)
+	case ActionDeleteServiceAccount:
+		return fmt.Sprintf("%s %s/%s", a.Type, a.Namespace, a.Name)
+	case ActionPrePullImages:
+		return fmt.Sprintf("%s %v on nodes %v", a.Type, a.Images, a.Nodes)
+	case ActionRunServantJobs:
+		if a.ServantJobParams == nil {
+			return fmt.Sprintf("%s on nodes %v", a.Type, a.Nodes)
+		}
+		details := servantJobDetails(a.ServantJobParams)
+		return fmt.Sprintf("%s(%s) on nodes %v", a.Type, strings.Join(details, ", "), a.Nodes)
+	case ActionWriteBootstrapArtifacts:
+		profiles := make([]string, 0, len(a.BootstrapProfiles))
+		for _, profile := range a.BootstrapProfiles {
+			profiles = append(profiles, profile.String())
+		}
+		return fmt.Sprintf("%s to %s for %s", a.Type, a.Dir, strings.Join(profiles, ", "))
+	default:
+		return string(a.Type)
+	}
+}
+
+// servantJobDetails returns the parameters of servant job that are printed, the password
+// of proxy is redacted.
+func servantJobDetails(params *kubeutil.ServantJobParams) []string {
+	details := []string{string(params.Action)}
+	if params.HTTPSProxy != "" {
+		details = append(details, "proxy "+kubeutil.RedactProxy(params.HTTPSProxy))
+	}
+	if params.RegistryMirrors != "" {
+		details = append(details, "mirrors "+params.RegistryMirrors)
+	}
+	if params.HubWatchdog {
+		details = append(details, "watchdog")
+	}
+	return details
+}
+
+// Save writes the plan into the file of path in yaml format
+func (p *ConversionPlan) Save(path string) error {
+	b, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// LoadConversionPlan reads the plan saved in the file of path
+func LoadConversionPlan(path string) (*ConversionPlan, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p ConversionPlan
+	if err := yaml.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("fail to parse plan %s: %s", path, err)
+	}
+	return &p, nil
+}
+
+// Apply executes the actions of plan in order, it stops at the first failed action, except
+// the servant jobs, whose results are returned for reporting the failed nodes.
+func (p *ConversionPlan) Apply(cliSet *kubernetes.Clientset) ([]kubeutil.ServantJobResult, error) {
+	var results []kubeutil.ServantJobResult
+	for i, action := range p.Actions {
+		klog.Infof("applying action %d/%d: %s", i+1, len(p.Actions), action.String())
+		if action.Type == ActionRunServantJobs {
+			// the servant jobs on the other nodes go on if some of them fail
+			actionResults, err := p.runServantJobs(cliSet, action)
+			if err != nil {
+				return results, fmt.Errorf("fail to apply action(%s): %s", action.String(), err)
+			}
+			results = append(results, actionResults...)
+			continue
+		}
+		if err := p.applyAction(cliSet, action); err != nil {
+			return results, fmt.Errorf("fail to apply action(%s): %s", action.String(), err)
+		}
+	}
+	return results, nil
+}
+
+// runServantJobs runs the servant jobs of action, and returns the results of the servant jobs
+func (p *ConversionPlan) runServantJobs(cliSet *kubernetes.Clientset, action Action) ([]kubeutil.ServantJobResult, error) {
+	content, err := p.manifest(action.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	// servant jobs are rendered from the template of this version of yurtctl
+	if content != constants.ServantJobTemplate {
+		return nil, errors.New("servant job template in plan is not the template of this version of yurtctl")
+	}
+	if action.ServantJobParams == nil {
+		return nil, errors.New("parameters of servant job are not specified")
+	}
+	return kubeutil.RunServantJobs(cliSet, *action.ServantJobParams, action.Nodes)
+}
+
+func (p *ConversionPlan) applyAction(cliSet *kubernetes.Clientset, action Action) error {
+	switch action.Type {
+	case ActionLabelNode, ActionAnnotateNode:
+		for _, nodeName := range action.Nodes {
+			node, err := cliSet.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if action.Type == ActionLabelNode {
+				_, err = kubeutil.LabelNode(cliSet, node, action.Key, action.Value)
+			} else {
+				_, err = kubeutil.AnnotateNode(cliSet, node, action.Key, action.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+	case ActionCreateDeployment:
+		content, err := p.manifest(action.Manifest)
+		if err != nil {
+			return err
+		}
+		dpObj, err := kubeutil.YamlToObject([]byte(content))
+		if err != nil {
+			return err
+		}
+		dp, ok := dpObj.(*appsv1.Deployment)
+		if !ok {
+			return fmt.Errorf("manifest %s is not a deployment", action.Manifest)
+		}
+		if _, err := cliSet.AppsV1().Deployments(action.Namespace).Create(dp); err != nil {
+			return err
+		}
+
+	case ActionDeleteServiceAccount:
+		if err := cliSet.CoreV1().ServiceAccounts(action.Namespace).
+			Delete(action.Name, &metav1.DeleteOptions{
+				PropagationPolicy: &kubeutil.PropagationPolicy,
+			}); err != nil {
+			return err
+		}
+
+	case ActionPrePullImages:
+		if err := kubeutil.PrePullImages(cliSet, action.Images, action.Nodes,
+			kubeutil.WaitPrePullTimeout, kubeutil.CheckPrePullPeriod); err != nil {
+			return err
+		}
+
+	case ActionWriteBootstrapArtifacts:
+		if err := WriteBootstrapArtifacts(action.Dir, p.Provider, action.BootstrapProfiles); err != nil {
+			return err
+		}
+
+	default:
+		return fmt.Errorf("unknown action type: %s", action.Type)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package convert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+func newTestNodes(names ...string) []v1.Node {
+	nodes := make([]v1.Node, 0, len(names))
+	for _, name := range names {
+		nodes = append(nodes, v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return nodes
+}
+
+func TestNewConversionPlan(t *testing.T) {
+	nodes := newTestNodes("cloud1", "edge1", "edge2")
+
+	testcases := map[string]struct {
+		components []Component
+		actions    []ActionType
+		manifests  []string
+	}{
+		"default components": {
+			components: []Component{ComponentYurtHub, ComponentControllerManager},
+			actions: []ActionType{ActionLabelNode, ActionLabelNode, ActionAnnotateNode,
+				ActionCreateDeployment, ActionDeleteServiceAccount, ActionRunServantJobs},
+			manifests: []string{manifestYurtControllerManager, manifestServantJob},
+		},
+		"hub only": {
+			components: []Component{ComponentYurtHub},
+			actions:    []ActionType{ActionLabelNode, ActionLabelNode, ActionAnnotateNode, ActionRunServantJobs},
+			manifests:  []string{manifestServantJob},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			plan := NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK, tt.components, ProxyConfig{}, MirrorConfig{})
+			if !reflect.DeepEqual(plan.CloudNodes, []string{"cloud1"}) ||
+				!reflect.DeepEqual(plan.EdgeNodes, []string{"edge1", "edge2"}) {
+				t.Errorf("unexpected cloud nodes %v and edge nodes %v", plan.CloudNodes, plan.EdgeNodes)
+			}
+
+			actions := make([]ActionType, 0, len(plan.Actions))
+			for _, action := range plan.Actions {
+				actions = append(actions, action.Type)
+			}
+			if !reflect.DeepEqual(actions, tt.actions) {
+				t.Errorf("expect actions %v, but got %v", tt.actions, actions)
+			}
+
+			if len(plan.Manifests) != len(tt.manifests) {
+				t.Errorf("expect manifests %v, but got %d manifests", tt.manifests, len(plan.Manifests))
+			}
+			for _, name := range tt.manifests {
+				if _, err := plan.manifest(name); err != nil {
+					t.Errorf("expect manifest %s is valid, but got %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNewConversionPlanWithProxy(t *testing.T) {
+	nodes := newTestNodes("cloud1", "edge1", "edge2", "edge3", "edge4")
+	nodes[2].Labels = map[string]string{constants.LabelNodePool: "hangzhou"}
+	nodes[3].Labels = map[string]string{constants.LabelNodePool: "beijing"}
+	nodes[4].Labels = map[string]string{constants.LabelNodePool: "hangzhou"}
+
+	testcases := map[string]struct {
+		proxy   ProxyConfig
+		servant map[string][]string
+	}{
+		"no proxy": {
+			servant: map[string][]string{"": {"edge1", "edge2", "edge3", "edge4"}},
+		},
+		"default proxy": {
+			proxy:   ProxyConfig{HTTPSProxy: "http://10.0.0.1:3128"},
+			servant: map[string][]string{"http://10.0.0.1:3128": {"edge1", "edge2", "edge3", "edge4"}},
+		},
+		"node pool proxy": {
+			proxy: ProxyConfig{
+				HTTPSProxy:           "http://10.0.0.1:3128",
+				NoProxy:              "10.0.0.0/8",
+				NodePoolHTTPSProxies: map[string]string{"hangzhou": "socks5://10.1.0.1:1080"},
+			},
+			servant: map[string][]string{
+				"http://10.0.0.1:3128":   {"edge1", "edge3"},
+				"socks5://10.1.0.1:1080": {"edge2", "edge4"},
+			},
+		},
+		"node pool proxy only": {
+			proxy: ProxyConfig{NodePoolHTTPSProxies: map[string]string{"beijing": "http://10.2.0.1:3128"}},
+			servant: map[string][]string{
+				"":                     {"edge1", "edge2", "edge4"},
+				"http://10.2.0.1:3128": {"edge3"},
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			plan := NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK, []Component{ComponentYurtHub}, tt.proxy, MirrorConfig{})
+			servant := map[string][]string{}
+			for _, action := range plan.Actions {
+				if action.Type != ActionRunServantJobs {
+					continue
+				}
+				params := action.ServantJobParams
+				servant[params.HTTPSProxy] = action.Nodes
+				if params.HTTPSProxy == "" && params.NoProxy != "" {
+					t.Errorf("expect no proxy is not set without proxy, but got %s", params.NoProxy)
+				} else if params.HTTPSProxy != "" && params.NoProxy != tt.proxy.NoProxy {
+					t.Errorf("expect no proxy %s, but got %s", tt.proxy.NoProxy, params.NoProxy)
+				}
+			}
+			if !reflect.DeepEqual(servant, tt.servant) {
+				t.Errorf("expect servant jobs %v, but got %v", tt.servant, servant)
+			}
+		})
+	}
+}
+
+func TestNewConversionPlanWithMirrors(t *testing.T) {
+	nodes := newTestNodes("cloud1", "edge1", "edge2", "edge3")
+	nodes[2].Labels = map[string]string{constants.LabelNodePool: "hangzhou"}
+	nodes[3].Labels = map[string]string{constants.LabelNodePool: "beijing"}
+
+	proxy := ProxyConfig{NodePoolHTTPSProxies: map[string]string{"beijing": "http://10.2.0.1:3128"}}
+	mirror := MirrorConfig{
+		Mirrors: kubeutil.RegistryMirrors{
+			"docker.io": {"https://mirror.example.com"},
+			"quay.io":   {"https://quay.example.com"},
+		},
+		NodePoolMirrors: map[string]kubeutil.RegistryMirrors{
+			"hangzhou": {"docker.io": {"https://mirror.hangzhou.example.com"}},
+		},
+	}
+	expected := map[string][]string{
+		"|docker.io=https://mirror.example.com quay.io=https://quay.example.com":                     {"edge1"},
+		"|docker.io=https://mirror.hangzhou.example.com quay.io=https://quay.example.com":            {"edge2"},
+		"http://10.2.0.1:3128|docker.io=https://mirror.example.com quay.io=https://quay.example.com": {"edge3"},
+	}
+
+	plan := NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK, []Component{ComponentYurtHub}, proxy, mirror)
+	servant := map[string][]string{}
+	for _, action := range plan.Actions {
+		if action.Type != ActionRunServantJobs {
+			continue
+		}
+		params := action.ServantJobParams
+		servant[params.HTTPSProxy+"|"+params.RegistryMirrors] = action.Nodes
+		if err := params.Validate(); err != nil {
+			t.Errorf("expect servant job params are valid, but got %v", err)
+		}
+	}
+	if !reflect.DeepEqual(servant, expected) {
+		t.Errorf("expect servant jobs %v, but got %v", expected, servant)
+	}
+}
+
+func TestAddPrePullImages(t *testing.T) {
+	plan := NewConversionPlan(newTestNodes("cloud1", "edge1", "edge2"), []string{"cloud1"}, ProviderACK,
+		[]Component{ComponentYurtHub, ComponentControllerManager}, ProxyConfig{}, MirrorConfig{})
+	plan.AddPrePullImages()
+	plan.AddPrePullImages()
+
+	actions := make([]ActionType, 0, len(plan.Actions))
+	for _, action := range plan.Actions {
+		actions = append(actions, action.Type)
+	}
+	expected := []ActionType{ActionLabelNode, ActionLabelNode, ActionAnnotateNode, ActionCreateDeployment,
+		ActionDeleteServiceAccount, ActionPrePullImages, ActionRunServantJobs}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("expect actions %v, but got %v", expected, actions)
+	}
+	prepull := plan.Actions[5]
+	if !reflect.DeepEqual(prepull.Nodes, []string{"edge1", "edge2"}) ||
+		!reflect.DeepEqual(prepull.Images, []string{constants.ServantImage, constants.DefaultYurtHubImage}) {
+		t.Errorf("unexpected prepull action %s", prepull.String())
+	}
+
+	// no image is needed if yurt-hub is not installed
+	plan = NewConversionPlan(newTestNodes("cloud1"), []string{"cloud1"}, ProviderACK,
+		[]Component{ComponentYurtHub}, ProxyConfig{}, MirrorConfig{})
+	plan.AddPrePullImages()
+	for _, action := range plan.Actions {
+		if action.Type == ActionPrePullImages {
+			t.Errorf("expect no prepull action without edge nodes, but got %s", action.String())
+		}
+	}
+}
+
+func TestEnableHubWatchdog(t *testing.T) {
+	nodes := newTestNodes("cloud1", "edge1", "edge2")
+	nodes[2].Labels = map[string]string{constants.LabelNodePool: "hangzhou"}
+	proxy := ProxyConfig{NodePoolHTTPSProxies: map[string]string{"hangzhou": "http://10.1.0.1:3128"}}
+
+	plan := NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK, []Component{ComponentYurtHub}, proxy, MirrorConfig{})
+	plan.EnableHubWatchdog()
+	jobs := 0
+	for _, action := range plan.Actions {
+		if action.Type != ActionRunServantJobs {
+			continue
+		}
+		jobs++
+		if !action.ServantJobParams.HubWatchdog {
+			t.Errorf("expect watchdog is enabled for servant jobs on nodes %v", action.Nodes)
+		}
+	}
+	if jobs != 2 {
+		t.Errorf("expect 2 servant jobs actions, but got %d", jobs)
+	}
+
+	// no servant job runs without yurt-hub
+	plan = NewConversionPlan(nodes, []string{"cloud1"}, ProviderACK, []Component{ComponentControllerManager}, ProxyConfig{}, MirrorConfig{})
+	plan.EnableHubWatchdog()
+	for _, action := range plan.Actions {
+		if action.ServantJobParams != nil {
+			t.Errorf("expect no servant job, but got %v", action)
+		}
+	}
+}
+
+func TestConversionPlanSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yurtctl-plan")
+	if err != nil {
+		t.Fatalf("fail to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := NewConversionPlan(newTestNodes("cloud1", "edge1"), []string{"cloud1"}, ProviderMinikube,
+		[]Component{ComponentYurtHub, ComponentControllerManager}, ProxyConfig{HTTPSProxy: "http://10.0.0.1:3128"}, MirrorConfig{})
+	path := filepath.Join(dir, "plan.yaml")
+	if err := plan.Save(path); err != nil {
+		t.Fatalf("fail to save plan: %s", err)
+	}
+
+	loaded, err := LoadConversionPlan(path)
+	if err != nil {
+		t.Fatalf("fail to load plan: %s", err)
+	}
+	if !reflect.DeepEqual(plan, loaded) {
+		t.Errorf("expect loaded plan %#v, but got %#v", plan, loaded)
+	}
+
+	// a modified manifest is rejected
+	m := loaded.Manifests[manifestYurtControllerManager]
+	m.Content = constants.YurtControllerManagerDeployment + "\n# modified"
+	loaded.Manifests[manifestYurtControllerManager] = m
+	if _, err := loaded.manifest(manifestYurtControllerManager); err == nil {
+		t.Errorf("expect modified manifest is rejected")
+	}
+}
@@ -0,0 +1,172 @@
+package convert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/yaml"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// ConversionStateConfigMap is the configmap in kube-system that the state of the last conversion is stored in
+	ConversionStateConfigMap = "yurtctl-conversion-state"
+	// conversionStatePlanKey is the key of the applied plan(in yaml) in the configmap
+	conversionStatePlanKey = "plan"
+	// conversionStateResultsKey is the key of the results of servant jobs(in json) in the configmap
+	conversionStateResultsKey = "results"
+	// conversionStateCompletedKey is the key that records whether all actions of the plan are applied
+	conversionStateCompletedKey = "completed"
+)
+
+// ConversionState is the state of the last conversion, it's stored in the cluster, so the
+// nodes that fail to be converted can be retried with the original parameters.
+type ConversionState struct {
+	// Plan is the applied plan
+	Plan *ConversionPlan
+	// Results are the results of servant jobs on the nodes
+	Results []kubeutil.ServantJobResult
+	// Completed is false if the plan stops at an action that is not a servant job
+	Completed bool
+}
+
+// RetryPlan returns the plan that only runs the servant jobs(and pre-pulls the images) on
+// the nodes that the servant jobs failed on, with the parameters of the original plan. nil
+// is returned if no servant job failed.
+func (s *ConversionState) RetryPlan() (*ConversionPlan, error) {
+	if s.Plan == nil {
+		return nil, errors.New("no plan is found in the conversion state")
+	}
+	if !s.Completed {
+		return nil, errors.New("the last conversion stopped before all actions were applied, run convert again")
+	}
+
+	failed := map[kubeutil.ServantJobAction]map[string]bool{}
+	for _, r := range s.Results {
+		if r.Succeeded {
+			continue
+		}
+		if failed[r.Action] == nil {
+			failed[r.Action] = map[string]bool{}
+		}
+		failed[r.Action][r.NodeName] = true
+	}
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	var retryNodes []string
+	var actions []Action
+	for _, action := range s.Plan.Actions {
+		if action.Type != ActionRunServantJobs || action.ServantJobParams == nil {
+			continue
+		}
+		var nodes []string
+		for _, node := range action.Nodes {
+			if failed[action.ServantJobParams.Action][node] {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		action.Nodes = nodes
+		actions = append(actions, action)
+		retryNodes = append(retryNodes, nodes...)
+	}
+
+	// the images are pulled on the retried nodes only if they were pre-pulled by the original plan
+	for _, action := range s.Plan.Actions {
+		if action.Type == ActionPrePullImages {
+			action.Nodes = retryNodes
+			actions = append([]Action{action}, actions...)
+			break
+		}
+	}
+
+	return &ConversionPlan{
+		Provider:   s.Plan.Provider,
+		Components: s.Plan.Components,
+		CloudNodes: s.Plan.CloudNodes,
+		EdgeNodes:  retryNodes,
+		Actions:    actions,
+		Manifests:  s.Plan.Manifests,
+	}, nil
+}
+
+// MergeResults replaces the results of the nodes that are retried by their new results
+func (s *ConversionState) MergeResults(results []kubeutil.ServantJobResult) {
+	for _, r := range results {
+		merged := false
+		for i := range s.Results {
+			if s.Results[i].NodeName == r.NodeName && s.Results[i].Action == r.Action {
+				s.Results[i] = r
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			s.Results = append(s.Results, r)
+		}
+	}
+}
+
+// SaveConversionState stores the conversion state in the configmap, the configmap is created if it doesn't exist
+func SaveConversionState(cliSet kubernetes.Interface, state *ConversionState) error {
+	plan, err := yaml.Marshal(state.Plan)
+	if err != nil {
+		return err
+	}
+	results, err := json.Marshal(state.Results)
+	if err != nil {
+		return err
+	}
+	cm := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConversionStateConfigMap,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Data: map[string]string{
+			conversionStatePlanKey:      string(plan),
+			conversionStateResultsKey:   string(results),
+			conversionStateCompletedKey: fmt.Sprintf("%t", state.Completed),
+		},
+	}
+
+	_, err = cliSet.CoreV1().ConfigMaps(cm.GetNamespace()).Update(cm)
+	if apierrors.IsNotFound(err) {
+		_, err = cliSet.CoreV1().ConfigMaps(cm.GetNamespace()).Create(cm)
+	}
+	if err != nil {
+		return fmt.Errorf("fail to save conversion state to configmap(%s): %s", ConversionStateConfigMap, err)
+	}
+	return nil
+}
+
+// LoadConversionState reads the conversion state stored in the configmap
+func LoadConversionState(cliSet kubernetes.Interface) (*ConversionState, error) {
+	cm, err := cliSet.CoreV1().ConfigMaps(metav1.NamespaceSystem).Get(ConversionStateConfigMap, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("conversion state is not found, the cluster is not converted by this version of yurtctl")
+	} else if err != nil {
+		return nil, fmt.Errorf("fail to get configmap(%s): %s", ConversionStateConfigMap, err)
+	}
+
+	state := &ConversionState{Completed: cm.Data[conversionStateCompletedKey] == "true"}
+	if err := yaml.Unmarshal([]byte(cm.Data[conversionStatePlanKey]), &state.Plan); err != nil {
+		return nil, fmt.Errorf("fail to parse plan in conversion state: %s", err)
+	}
+	if state.Plan == nil {
+		return nil, errors.New("no plan is found in the conversion state")
+	}
+	if err := json.Unmarshal([]byte(cm.Data[conversionStateResultsKey]), &state.Results); err != nil {
+		return nil, fmt.Errorf("fail to parse results in conversion state: %s", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,117 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+func newTestState() *ConversionState {
+	plan := NewConversionPlan(newTestNodes("cloud1", "edge1", "edge2", "edge3"), []string{"cloud1"}, ProviderMinikube,
+		[]Component{ComponentYurtHub, ComponentControllerManager}, ProxyConfig{HTTPSProxy: "http://10.0.0.1:3128"}, MirrorConfig{})
+	plan.AddPrePullImages()
+	plan.EnableHubWatchdog()
+	return &ConversionState{
+		Plan: plan,
+		Results: []kubeutil.ServantJobResult{
+			{NodeName: "edge1", Action: kubeutil.ServantJobActionConvert, Succeeded: true},
+			{NodeName: "edge2", Action: kubeutil.ServantJobActionConvert, Step: kubeutil.ServantStepRestartKubelet},
+			{NodeName: "edge3", Action: kubeutil.ServantJobActionConvert, Step: kubeutil.ServantStepStartServant},
+		},
+		Completed: true,
+	}
+}
+
+func TestRetryPlan(t *testing.T) {
+	state := newTestState()
+	plan, err := state.RetryPlan()
+	if err != nil {
+		t.Fatalf("fail to get retry plan: %s", err)
+	}
+
+	if !reflect.DeepEqual(plan.EdgeNodes, []string{"edge2", "edge3"}) {
+		t.Errorf("expect edge nodes [edge2 edge3], but got %v", plan.EdgeNodes)
+	}
+	var types []ActionType
+	for _, action := range plan.Actions {
+		types = append(types, action.Type)
+		if !reflect.DeepEqual(action.Nodes, []string{"edge2", "edge3"}) {
+			t.Errorf("expect action %s on nodes [edge2 edge3], but got %v", action.Type, action.Nodes)
+		}
+	}
+	if !reflect.DeepEqual(types, []ActionType{ActionPrePullImages, ActionRunServantJobs}) {
+		t.Fatalf("expect actions [PrePullImages RunServantJobs], but got %v", types)
+	}
+	params := plan.Actions[1].ServantJobParams
+	if params.HTTPSProxy != "http://10.0.0.1:3128" || !params.HubWatchdog {
+		t.Errorf("expect the original parameters are preserved, but got %#v", params)
+	}
+	if _, err := plan.manifest(plan.Actions[1].Manifest); err != nil {
+		t.Errorf("expect the servant job manifest is in retry plan: %s", err)
+	}
+	// the original plan is not changed
+	if state.Plan.Actions[0].Type != ActionLabelNode {
+		t.Errorf("expect the original plan is not changed, but got %v", state.Plan.Actions[0])
+	}
+}
+
+func TestRetryPlanWithoutFailure(t *testing.T) {
+	state := newTestState()
+	state.Results = state.Results[:1]
+	plan, err := state.RetryPlan()
+	if err != nil || plan != nil {
+		t.Errorf("expect no retry plan, but got %v, %v", plan, err)
+	}
+}
+
+func TestRetryPlanOfIncompleteConversion(t *testing.T) {
+	state := newTestState()
+	state.Completed = false
+	if _, err := state.RetryPlan(); err == nil {
+		t.Errorf("expect incomplete conversion can't be retried")
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	state := newTestState()
+	state.MergeResults([]kubeutil.ServantJobResult{
+		{NodeName: "edge2", Action: kubeutil.ServantJobActionConvert, Succeeded: true},
+	})
+
+	expected := []kubeutil.ServantJobResult{
+		{NodeName: "edge1", Action: kubeutil.ServantJobActionConvert, Succeeded: true},
+		{NodeName: "edge2", Action: kubeutil.ServantJobActionConvert, Succeeded: true},
+		{NodeName: "edge3", Action: kubeutil.ServantJobActionConvert, Step: kubeutil.ServantStepStartServant},
+	}
+	if !reflect.DeepEqual(state.Results, expected) {
+		t.Errorf("expect results %v, but got %v", expected, state.Results)
+	}
+}
+
+func TestSaveAndLoadConversionState(t *testing.T) {
+	cliSet := fake.NewSimpleClientset()
+	if _, err := LoadConversionState(cliSet); err == nil {
+		t.Errorf("expect error when conversion state is not saved")
+	}
+
+	state := newTestState()
+	// the state is created and updated
+	for i := 0; i < 2; i++ {
+		if err := SaveConversionState(cliSet, state); err != nil {
+			t.Fatalf("fail to save conversion state: %s", err)
+		}
+		loaded, err := LoadConversionState(cliSet)
+		if err != nil {
+			t.Fatalf("fail to load conversion state: %s", err)
+		}
+		if !reflect.DeepEqual(state, loaded) {
+			t.Errorf("expect loaded state %#v, but got %#v", state, loaded)
+		}
+		state.MergeResults([]kubeutil.ServantJobResult{
+			{NodeName: "edge3", Action: kubeutil.ServantJobActionConvert, Succeeded: true},
+		})
+	}
+}
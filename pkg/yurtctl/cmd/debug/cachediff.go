@@ -0,0 +1,480 @@
+package debug
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// cacheIndexPodNamePrefix is the prefix of name of the pod that gets the cache index on the node
+	cacheIndexPodNamePrefix = "yurtctl-cache-index-"
+	// cacheIndexPath is the path of the admin api of yurt-hub that lists the cached objects
+	cacheIndexPath = "/v1/cache/objects"
+)
+
+// componentRegexp restricts the component, because it's passed to the cache index pod
+var componentRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]*$`)
+
+// DiffStatus is the status of a cached object compared with the object in the cluster
+type DiffStatus string
+
+const (
+	// DiffFresh means the cached object is the same version as the object in the cluster
+	DiffFresh DiffStatus = "Fresh"
+	// DiffStale means the cached object is an older version of the object in the cluster
+	DiffStale DiffStatus = "Stale"
+	// DiffReplaced means the object in the cluster is recreated(in different uid) after it's cached
+	DiffReplaced DiffStatus = "Replaced"
+	// DiffDeleted means the cached object is deleted in the cluster
+	DiffDeleted DiffStatus = "Deleted"
+	// DiffMissing means the object in the cluster is needed by the node but not cached,
+	// only the node and its pods are checked for kubelet.
+	DiffMissing DiffStatus = "Missing"
+	// DiffCorrupted means the content of cached object can not be decoded
+	DiffCorrupted DiffStatus = "Corrupted"
+	// DiffUnknown means the resource of cached object is not served by the cluster
+	DiffUnknown DiffStatus = "Unknown"
+)
+
+// diffStatuses are the columns of the summary, in order
+var diffStatuses = []DiffStatus{DiffFresh, DiffStale, DiffReplaced, DiffDeleted, DiffMissing, DiffCorrupted, DiffUnknown}
+
+// cachedObject is the object in the cache index that reported by yurt-hub
+type cachedObject struct {
+	Component       string `json:"component"`
+	Resource        string `json:"resource"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Corrupted       bool   `json:"corrupted,omitempty"`
+}
+
+// cacheIndex is the index of cached objects that reported by yurt-hub
+type cacheIndex struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Objects     []cachedObject `json:"objects"`
+}
+
+// liveObject is the identity and resource version of an object in the cluster
+type liveObject struct {
+	UID             string
+	ResourceVersion string
+}
+
+// errUnknownResource is returned by objectLister if the resource is not served by the cluster
+var errUnknownResource = errors.New("resource is not served by the cluster")
+
+// objectLister lists the objects of resource in namespace(all namespaces if it's empty), the
+// objects are keyed by "namespace/name", or "name" for the cluster scoped objects.
+type objectLister interface {
+	List(resource, namespace string, opts metav1.ListOptions) (map[string]liveObject, error)
+}
+
+// ObjectDiff is the divergence of a cached object from the object in the cluster
+type ObjectDiff struct {
+	Component             string
+	Resource              string
+	Namespace             string
+	Name                  string
+	Status                DiffStatus
+	CachedResourceVersion string
+	LiveResourceVersion   string
+}
+
+// ResourceDiff is the summary of divergence of the cached objects of a resource for a component
+type ResourceDiff struct {
+	Component string
+	Resource  string
+	Counts    map[DiffStatus]int
+}
+
+// CacheDiffOptions has the information that required by cache-diff operation
+type CacheDiffOptions struct {
+	clientSet kubernetes.Interface
+	lister    objectLister
+	out       io.Writer
+	NodeName  string
+	Component string
+	// HubAddress is the address that yurt-hub on the node is connected directly, the cache
+	// index is read by a pod on the node through kube-apiserver if it's not set
+	HubAddress string
+	Timeout    time.Duration
+}
+
+// NewCacheDiffOptions creates a new CacheDiffOptions
+func NewCacheDiffOptions() *CacheDiffOptions {
+	return &CacheDiffOptions{}
+}
+
+// NewCacheDiffCmd generates a new cache-diff command
+func NewCacheDiffCmd() *cobra.Command {
+	co := NewCacheDiffOptions()
+	cmd := &cobra.Command{
+		Use:   "cache-diff NODE",
+		Short: "Compares the objects cached by yurt-hub on the node with the objects in the cluster",
+		Long: "Compares the objects cached by yurt-hub on the node with the objects in the cluster, and reports the " +
+			"divergence per resource, so the cache can be verified to be fresh before a planned disconnection. " +
+			"The cached objects are read by a pod on the node through kube-apiserver(and yurt-tunnel), or from " +
+			"--hub-address directly. It fails if any cached object diverges from the cluster.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := co.Complete(cmd.Flags(), args, cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the cache-diff option: %s", err)
+			}
+			if err := co.Validate(); err != nil {
+				klog.Fatalf("cache-diff option is invalid: %s", err)
+			}
+			if err := co.RunCacheDiff(); err != nil {
+				klog.Fatalf("fail to diff cache: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("component", "",
+		"The component(like kubelet) whose cached objects are compared, all components if not set.")
+	cmd.Flags().String("hub-address", "",
+		"The address of yurt-hub on the node(e.g. http://10.0.0.5:10261), set it if yurt-hub is reachable directly.")
+	cmd.Flags().Duration("timeout", time.Minute,
+		"The time to wait for the cached objects to be read from the node.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (co *CacheDiffOptions) Complete(flags *pflag.FlagSet, args []string, out io.Writer) error {
+	co.NodeName = args[0]
+	co.out = out
+
+	var err error
+	co.Component, err = flags.GetString("component")
+	if err != nil {
+		return err
+	}
+
+	co.HubAddress, err = flags.GetString("hub-address")
+	if err != nil {
+		return err
+	}
+
+	co.Timeout, err = flags.GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+
+	restCfg, err := kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+	clientSet, err := kubernetes.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+	co.clientSet = clientSet
+	co.lister = &dynamicLister{
+		client: dynamicClient,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientSet.Discovery())),
+	}
+	return nil
+}
+
+// Validate makes sure provided values for CacheDiffOptions are valid
+func (co *CacheDiffOptions) Validate() error {
+	if co.NodeName == "" {
+		return errors.New("node is not specified")
+	}
+	if !componentRegexp.MatchString(co.Component) {
+		return fmt.Errorf("component(%s) contains invalid characters", co.Component)
+	}
+	if co.HubAddress != "" {
+		if err := validateHubAddress(co.HubAddress); err != nil {
+			return err
+		}
+	}
+	if co.Timeout <= 0 {
+		return fmt.Errorf("timeout(%v) must be positive", co.Timeout)
+	}
+	return nil
+}
+
+// RunCacheDiff reads the cached objects on the node, compares them with the objects
+// in the cluster, and prints the divergence.
+func (co *CacheDiffOptions) RunCacheDiff() error {
+	if _, err := co.clientSet.CoreV1().Nodes().Get(co.NodeName, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	var b []byte
+	var err error
+	if co.HubAddress != "" {
+		b, err = co.cacheIndexFromHub()
+	} else {
+		b, err = co.cacheIndexFromPod()
+	}
+	if err != nil {
+		return err
+	}
+
+	var index cacheIndex
+	if err := json.Unmarshal(b, &index); err != nil {
+		return fmt.Errorf("fail to parse cache index of node %s: %s", co.NodeName, err)
+	}
+	klog.Infof("%d objects are cached on node %s at %s", len(index.Objects), co.NodeName, index.GeneratedAt.Format(time.RFC3339))
+
+	summary, diffs, err := DiffCache(index.Objects, co.NodeName, co.Component, co.lister)
+	if err != nil {
+		return err
+	}
+	if err := PrintCacheDiff(co.out, summary, diffs); err != nil {
+		return err
+	}
+	if len(diffs) != 0 {
+		return fmt.Errorf("cache of node %s diverges from the cluster in %d objects", co.NodeName, len(diffs))
+	}
+	return nil
+}
+
+// cacheIndexPath returns the path of the admin api of yurt-hub that lists the cached objects of component
+func (co *CacheDiffOptions) cacheIndexPath() string {
+	return cacheIndexPath + "?component=" + url.QueryEscape(co.Component)
+}
+
+// cacheIndexFromHub reads the cache index from yurt-hub directly
+func (co *CacheDiffOptions) cacheIndexFromHub() ([]byte, error) {
+	return getFromHub(co.HubAddress, co.cacheIndexPath(), co.Timeout)
+}
+
+// cacheIndexFromPod runs a pod on the node that prints the cache index, and reads the log of the pod
+func (co *CacheDiffOptions) cacheIndexFromPod() ([]byte, error) {
+	return getFromPod(co.clientSet, cacheIndexPodNamePrefix+co.NodeName, co.NodeName, co.cacheIndexPath(), co.Timeout)
+}
+
+// DiffCache compares the cached objects on the node with the objects in the cluster, it returns the
+// summary per component and resource, and the objects that diverge from the cluster. besides the
+// cached objects, the node and its pods are checked to be cached for kubelet.
+func DiffCache(objects []cachedObject, nodeName, component string, lister objectLister) ([]ResourceDiff, []ObjectDiff, error) {
+	summaries := map[string]*ResourceDiff{}
+	var diffs []ObjectDiff
+	record := func(diff ObjectDiff) {
+		id := diff.Component + "/" + diff.Resource
+		summary, ok := summaries[id]
+		if !ok {
+			summary = &ResourceDiff{Component: diff.Component, Resource: diff.Resource, Counts: map[DiffStatus]int{}}
+			summaries[id] = summary
+		}
+		summary.Counts[diff.Status]++
+		if diff.Status != DiffFresh && diff.Status != DiffUnknown {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	// the objects in the cluster are listed once for each resource and namespace
+	lives := map[string]map[string]liveObject{}
+	listLive := func(resource, namespace string) (map[string]liveObject, error) {
+		id := resource + "/" + namespace
+		if live, ok := lives[id]; ok {
+			return live, nil
+		}
+		live, err := lister.List(resource, namespace, metav1.ListOptions{})
+		if err != nil && err != errUnknownResource {
+			return nil, fmt.Errorf("fail to list %s in namespace %q: %s", resource, namespace, err)
+		}
+		lives[id] = live
+		return live, nil
+	}
+
+	cachedKeys := map[string]bool{}
+	for _, obj := range objects {
+		key := objectKey(obj.Namespace, obj.Name)
+		cachedKeys[obj.Component+"/"+obj.Resource+"/"+key] = true
+		diff := ObjectDiff{
+			Component:             obj.Component,
+			Resource:              obj.Resource,
+			Namespace:             obj.Namespace,
+			Name:                  obj.Name,
+			CachedResourceVersion: obj.ResourceVersion,
+		}
+		if obj.Corrupted {
+			diff.Status = DiffCorrupted
+			record(diff)
+			continue
+		}
+
+		live, err := listLive(obj.Resource, obj.Namespace)
+		if err != nil {
+			return nil, nil, err
+		}
+		liveObj, found := live[key]
+		diff.LiveResourceVersion = liveObj.ResourceVersion
+		switch {
+		case live == nil:
+			diff.Status = DiffUnknown
+		case !found:
+			diff.Status = DiffDeleted
+		case obj.UID != "" && obj.UID != liveObj.UID:
+			diff.Status = DiffReplaced
+		case obj.ResourceVersion != liveObj.ResourceVersion:
+			diff.Status = DiffStale
+		default:
+			diff.Status = DiffFresh
+		}
+		record(diff)
+	}
+
+	// kubelet can not start the pods on the node without them in cache during disconnection
+	if component == "" || component == "kubelet" {
+		needed := map[string]metav1.ListOptions{
+			"nodes": {FieldSelector: fields.OneTermEqualSelector("metadata.name", nodeName).String()},
+			"pods":  {FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String()},
+		}
+		for _, resource := range []string{"nodes", "pods"} {
+			live, err := lister.List(resource, "", needed[resource])
+			if err != nil {
+				return nil, nil, fmt.Errorf("fail to list %s of node %s: %s", resource, nodeName, err)
+			}
+			for key, liveObj := range live {
+				if cachedKeys["kubelet/"+resource+"/"+key] {
+					continue
+				}
+				namespace, name := splitObjectKey(key)
+				record(ObjectDiff{
+					Component:           "kubelet",
+					Resource:            resource,
+					Namespace:           namespace,
+					Name:                name,
+					Status:              DiffMissing,
+					LiveResourceVersion: liveObj.ResourceVersion,
+				})
+			}
+		}
+	}
+
+	summary := make([]ResourceDiff, 0, len(summaries))
+	for _, s := range summaries {
+		summary = append(summary, *s)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Component != summary[j].Component {
+			return summary[i].Component < summary[j].Component
+		}
+		return summary[i].Resource < summary[j].Resource
+	})
+	sort.Slice(diffs, func(i, j int) bool {
+		a, b := diffs[i], diffs[j]
+		if a.Component != b.Component {
+			return a.Component < b.Component
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return objectKey(a.Namespace, a.Name) < objectKey(b.Namespace, b.Name)
+	})
+	return summary, diffs, nil
+}
+
+// PrintCacheDiff prints the summary of divergence per resource, and the diverged objects
+func PrintCacheDiff(out io.Writer, summary []ResourceDiff, diffs []ObjectDiff) error {
+	w := tabwriter.NewWriter(out, 10, 4, 3, ' ', 0)
+	header := []string{"COMPONENT", "RESOURCE"}
+	for _, status := range diffStatuses {
+		header = append(header, strings.ToUpper(string(status)))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, s := range summary {
+		row := []string{s.Component, s.Resource}
+		for _, status := range diffStatuses {
+			row = append(row, fmt.Sprintf("%d", s.Counts[status]))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tRESOURCE\tOBJECT\tSTATUS\tCACHED-VERSION\tLIVE-VERSION")
+	for _, d := range diffs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Component, d.Resource, objectKey(d.Namespace, d.Name),
+			d.Status, valueOrNone(d.CachedResourceVersion), valueOrNone(d.LiveResourceVersion))
+	}
+	return w.Flush()
+}
+
+// dynamicLister lists the objects in the cluster by the dynamic client, the resources
+// cached by yurt-hub are mapped to the preferred versions of the cluster.
+type dynamicLister struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+func (l *dynamicLister) List(resource, namespace string, opts metav1.ListOptions) (map[string]liveObject, error) {
+	gvr, err := l.mapper.ResourceFor(schema.GroupVersionResource{Resource: resource})
+	if err != nil {
+		klog.V(2).Infof("resource %s is not found in the cluster: %s", resource, err)
+		return nil, errUnknownResource
+	}
+
+	lst, err := l.client.Resource(gvr).Namespace(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+	objects := make(map[string]liveObject, len(lst.Items))
+	for _, item := range lst.Items {
+		objects[objectKey(item.GetNamespace(), item.GetName())] = liveObject{
+			UID:             string(item.GetUID()),
+			ResourceVersion: item.GetResourceVersion(),
+		}
+	}
+	return objects, nil
+}
+
+func objectKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+func splitObjectKey(key string) (namespace, name string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,198 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeLister lists the objects of resources in all namespaces, or the objects
+// selected by the field selector if it's set
+type fakeLister struct {
+	objects  map[string]map[string]liveObject
+	selected map[string]map[string]liveObject
+}
+
+func (l *fakeLister) List(resource, namespace string, opts metav1.ListOptions) (map[string]liveObject, error) {
+	if opts.FieldSelector != "" {
+		return l.selected[resource], nil
+	}
+	all, ok := l.objects[resource]
+	if !ok {
+		return nil, errUnknownResource
+	}
+	objects := map[string]liveObject{}
+	for key, obj := range all {
+		if ns, _ := splitObjectKey(key); ns == namespace || namespace == "" {
+			objects[key] = obj
+		}
+	}
+	return objects, nil
+}
+
+func TestDiffCache(t *testing.T) {
+	lister := &fakeLister{
+		objects: map[string]map[string]liveObject{
+			"nodes": {"node1": {UID: "n1", ResourceVersion: "20"}},
+			"pods": {
+				"default/fresh":    {UID: "p1", ResourceVersion: "10"},
+				"default/stale":    {UID: "p2", ResourceVersion: "12"},
+				"default/replaced": {UID: "p3-new", ResourceVersion: "15"},
+				"default/uncached": {UID: "p5", ResourceVersion: "16"},
+			},
+		},
+		selected: map[string]map[string]liveObject{
+			"nodes": {"node1": {UID: "n1", ResourceVersion: "20"}},
+			"pods": {
+				"default/fresh":    {UID: "p1", ResourceVersion: "10"},
+				"default/uncached": {UID: "p5", ResourceVersion: "16"},
+			},
+		},
+	}
+	objects := []cachedObject{
+		{Component: "kubelet", Resource: "nodes", Name: "node1", UID: "n1", ResourceVersion: "20"},
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "fresh", UID: "p1", ResourceVersion: "10"},
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "stale", UID: "p2", ResourceVersion: "11"},
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "replaced", UID: "p3", ResourceVersion: "9"},
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "deleted", UID: "p4", ResourceVersion: "8"},
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "corrupted", Corrupted: true},
+		{Component: "kube-proxy", Resource: "foos", Namespace: "default", Name: "foo1", UID: "f1", ResourceVersion: "1"},
+	}
+
+	summary, diffs, err := DiffCache(objects, "node1", "", lister)
+	if err != nil {
+		t.Fatalf("fail to diff cache: %s", err)
+	}
+
+	expectedSummary := []ResourceDiff{
+		{Component: "kube-proxy", Resource: "foos", Counts: map[DiffStatus]int{DiffUnknown: 1}},
+		{Component: "kubelet", Resource: "nodes", Counts: map[DiffStatus]int{DiffFresh: 1}},
+		{Component: "kubelet", Resource: "pods", Counts: map[DiffStatus]int{
+			DiffFresh: 1, DiffStale: 1, DiffReplaced: 1, DiffDeleted: 1, DiffCorrupted: 1, DiffMissing: 1}},
+	}
+	if !reflect.DeepEqual(summary, expectedSummary) {
+		t.Errorf("expect summary %v, but got %v", expectedSummary, summary)
+	}
+
+	var got []string
+	for _, d := range diffs {
+		got = append(got, fmt.Sprintf("%s/%s:%s(%s,%s)", d.Resource, d.Name, d.Status, d.CachedResourceVersion, d.LiveResourceVersion))
+	}
+	expected := []string{
+		"pods/corrupted:Corrupted(,)",
+		"pods/deleted:Deleted(8,)",
+		"pods/replaced:Replaced(9,15)",
+		"pods/stale:Stale(11,12)",
+		"pods/uncached:Missing(,16)",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expect diffs %v, but got %v", expected, got)
+	}
+
+	// the node and its pods are checked for kubelet only
+	_, diffs, err = DiffCache(nil, "node1", "kube-proxy", lister)
+	if err != nil || len(diffs) != 0 {
+		t.Errorf("expect no diff for kube-proxy, but got %v, %v", diffs, err)
+	}
+}
+
+func TestPrintCacheDiff(t *testing.T) {
+	summary := []ResourceDiff{
+		{Component: "kubelet", Resource: "pods", Counts: map[DiffStatus]int{DiffFresh: 2, DiffStale: 1}},
+	}
+	diffs := []ObjectDiff{
+		{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "pod1", Status: DiffStale,
+			CachedResourceVersion: "11", LiveResourceVersion: "12"},
+	}
+
+	var buf bytes.Buffer
+	if err := PrintCacheDiff(&buf, summary, diffs); err != nil {
+		t.Fatalf("fail to print cache diff: %s", err)
+	}
+	out := buf.String()
+	for _, s := range []string{"FRESH", "MISSING", "default/pod1", "Stale", "11", "12"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("expect %q in output:\n%s", s, out)
+		}
+	}
+}
+
+func TestCacheIndexFromHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != cacheIndexPath || r.URL.Query().Get("component") != "kubelet" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"objects":[]}`)
+	}))
+	defer server.Close()
+
+	co := &CacheDiffOptions{HubAddress: server.URL + "/", Component: "kubelet", Timeout: time.Second}
+	b, err := co.cacheIndexFromHub()
+	if err != nil || string(b) != `{"objects":[]}` {
+		t.Errorf("expect cache index is read, but got %s, %v", b, err)
+	}
+
+	co.Component = "kube-proxy"
+	if _, err := co.cacheIndexFromHub(); err == nil {
+		t.Errorf("expect error for the failed response")
+	}
+}
+
+func TestNewCacheIndexPod(t *testing.T) {
+	co := &CacheDiffOptions{NodeName: "edge-node1", Component: "kubelet"}
+	pod, err := newHubAdminPod(cacheIndexPodNamePrefix+co.NodeName, co.NodeName, co.cacheIndexPath())
+	if err != nil {
+		t.Fatalf("fail to render cache index pod: %s", err)
+	}
+	if pod.GetName() != "yurtctl-cache-index-edge-node1" || pod.Spec.NodeName != "edge-node1" || !pod.Spec.HostNetwork {
+		t.Errorf("unexpected cache index pod %#v", pod)
+	}
+	cmd := pod.Spec.Containers[0].Command
+	if cmd[len(cmd)-1] != "http://127.0.0.1:10261/v1/cache/objects?component=kubelet" {
+		t.Errorf("unexpected command of cache index pod %v", cmd)
+	}
+}
+
+func TestValidateCacheDiffOptions(t *testing.T) {
+	testcases := map[string]struct {
+		options CacheDiffOptions
+		valid   bool
+	}{
+		"valid": {
+			options: CacheDiffOptions{NodeName: "node1", Component: "kubelet", Timeout: time.Minute},
+			valid:   true,
+		},
+		"valid hub address": {
+			options: CacheDiffOptions{NodeName: "node1", HubAddress: "http://10.0.0.5:10261", Timeout: time.Minute},
+			valid:   true,
+		},
+		"invalid component": {
+			options: CacheDiffOptions{NodeName: "node1", Component: "kubelet&x=1", Timeout: time.Minute},
+		},
+		"invalid hub address": {
+			options: CacheDiffOptions{NodeName: "node1", HubAddress: "10.0.0.5:10261", Timeout: time.Minute},
+		},
+		"invalid timeout": {
+			options: CacheDiffOptions{NodeName: "node1"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expect valid, but got %s", err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expect invalid")
+			}
+		})
+	}
+}
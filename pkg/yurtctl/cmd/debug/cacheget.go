@@ -0,0 +1,170 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// cacheGetPodNamePrefix is the prefix of name of the pod that gets the cached objects on the node
+	cacheGetPodNamePrefix = "yurtctl-cache-get-"
+)
+
+// cacheKeyRegexp restricts the key of cached objects to COMPONENT/RESOURCE[/NAMESPACE][/NAME],
+// because it's passed to the cache get pod
+var cacheKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+(/[a-zA-Z0-9._:-]+){1,3}$`)
+
+// CacheGetOptions has the information that required by cache-get operation
+type CacheGetOptions struct {
+	clientSet kubernetes.Interface
+	out       io.Writer
+	NodeName  string
+	// Key is the key of cached objects, like kubelet/pods/default/nginx for an object,
+	// or kubelet/pods for all the pods cached for kubelet
+	Key    string
+	Output string
+	// HubAddress is the address that yurt-hub on the node is connected directly, the cached
+	// objects are read by a pod on the node through kube-apiserver if it's not set
+	HubAddress string
+	Timeout    time.Duration
+}
+
+// NewCacheGetOptions creates a new CacheGetOptions
+func NewCacheGetOptions() *CacheGetOptions {
+	return &CacheGetOptions{}
+}
+
+// NewCacheGetCmd generates a new cache-get command
+func NewCacheGetCmd() *cobra.Command {
+	co := NewCacheGetOptions()
+	cmd := &cobra.Command{
+		Use:   "cache-get NODE COMPONENT/RESOURCE[/NAMESPACE][/NAME]",
+		Short: "Prints the objects cached by yurt-hub on the node in yaml or json",
+		Long: "Prints the objects cached by yurt-hub on the node in yaml or json as kubectl does, they are the " +
+			"objects that served to the component during autonomy. A collection(like kubelet/pods) is printed " +
+			"as a List. The data of secrets is redacted. The cached objects are read by a pod on the node through " +
+			"kube-apiserver(and yurt-tunnel), or from --hub-address directly.",
+		Example: "  yurtctl debug cache-get edge-node1 kubelet/pods/default/nginx\n" +
+			"  yurtctl debug cache-get edge-node1 kube-proxy/services -o json",
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := co.Complete(cmd.Flags(), args, cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the cache-get option: %s", err)
+			}
+			if err := co.Validate(); err != nil {
+				klog.Fatalf("cache-get option is invalid: %s", err)
+			}
+			if err := co.RunCacheGet(); err != nil {
+				klog.Fatalf("fail to get cache: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "yaml",
+		"The format that the cached objects are printed in, valid formats are: yaml, json.")
+	cmd.Flags().String("hub-address", "",
+		"The address of yurt-hub on the node(e.g. http://10.0.0.5:10261), set it if yurt-hub is reachable directly.")
+	cmd.Flags().Duration("timeout", time.Minute,
+		"The time to wait for the cached objects to be read from the node.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (co *CacheGetOptions) Complete(flags *pflag.FlagSet, args []string, out io.Writer) error {
+	co.NodeName = args[0]
+	co.Key = strings.Trim(args[1], "/")
+	co.out = out
+
+	var err error
+	co.Output, err = flags.GetString("output")
+	if err != nil {
+		return err
+	}
+
+	co.HubAddress, err = flags.GetString("hub-address")
+	if err != nil {
+		return err
+	}
+
+	co.Timeout, err = flags.GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+
+	co.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate makes sure provided values for CacheGetOptions are valid
+func (co *CacheGetOptions) Validate() error {
+	if co.NodeName == "" {
+		return errors.New("node is not specified")
+	}
+	if !cacheKeyRegexp.MatchString(co.Key) {
+		return fmt.Errorf("key(%s) is invalid, it should be COMPONENT/RESOURCE[/NAMESPACE][/NAME]", co.Key)
+	}
+	for _, part := range strings.Split(co.Key, "/") {
+		if part == "." || part == ".." {
+			return fmt.Errorf("key(%s) is invalid, it contains %s", co.Key, part)
+		}
+	}
+	if co.Output != "yaml" && co.Output != "json" {
+		return fmt.Errorf("output(%s) is not supported, valid outputs are: yaml, json", co.Output)
+	}
+	if co.HubAddress != "" {
+		if err := validateHubAddress(co.HubAddress); err != nil {
+			return err
+		}
+	}
+	if co.Timeout <= 0 {
+		return fmt.Errorf("timeout(%v) must be positive", co.Timeout)
+	}
+	return nil
+}
+
+// RunCacheGet reads the cached objects on the node, and prints them
+func (co *CacheGetOptions) RunCacheGet() error {
+	if _, err := co.clientSet.CoreV1().Nodes().Get(co.NodeName, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	var b []byte
+	var err error
+	if co.HubAddress != "" {
+		b, err = getFromHub(co.HubAddress, co.cachedObjectPath(), co.Timeout)
+	} else {
+		b, err = getFromPod(co.clientSet, cacheGetPodNamePrefix+co.NodeName, co.NodeName, co.cachedObjectPath(), co.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := co.out.Write(b); err != nil {
+		return err
+	}
+	if len(b) != 0 && b[len(b)-1] != '\n' {
+		fmt.Fprintln(co.out)
+	}
+	return nil
+}
+
+// cachedObjectPath returns the path of the admin api of yurt-hub that renders the cached objects of key
+func (co *CacheGetOptions) cachedObjectPath() string {
+	return cacheIndexPath + "/" + co.Key + "?output=" + co.Output
+}
@@ -0,0 +1,111 @@
+package debug
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunCacheGetFromHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/cache/objects/kubelet/pods/default/nginx" || r.URL.Query().Get("output") != "yaml" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "kind: Pod")
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	co := &CacheGetOptions{
+		clientSet:  fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-node1"}}),
+		out:        out,
+		NodeName:   "edge-node1",
+		Key:        "kubelet/pods/default/nginx",
+		Output:     "yaml",
+		HubAddress: server.URL,
+		Timeout:    time.Second,
+	}
+	if err := co.RunCacheGet(); err != nil {
+		t.Fatalf("fail to get cache: %s", err)
+	}
+	if out.String() != "kind: Pod\n" {
+		t.Errorf("expect cached object is printed, but got %q", out.String())
+	}
+
+	co.Key = "kubelet/pods/default/nginx2"
+	if err := co.RunCacheGet(); err == nil {
+		t.Errorf("expect error for the failed response")
+	}
+	co.NodeName = "edge-node2"
+	if err := co.RunCacheGet(); err == nil {
+		t.Errorf("expect error for the node that doesn't exist")
+	}
+}
+
+func TestNewCacheGetPod(t *testing.T) {
+	co := &CacheGetOptions{NodeName: "edge-node1", Key: "kubelet/nodes/edge-node1", Output: "json"}
+	pod, err := newHubAdminPod(cacheGetPodNamePrefix+co.NodeName, co.NodeName, co.cachedObjectPath())
+	if err != nil {
+		t.Fatalf("fail to render cache get pod: %s", err)
+	}
+	if pod.GetName() != "yurtctl-cache-get-edge-node1" || pod.Spec.NodeName != "edge-node1" {
+		t.Errorf("unexpected cache get pod %#v", pod)
+	}
+	cmd := pod.Spec.Containers[0].Command
+	if cmd[len(cmd)-1] != "http://127.0.0.1:10261/v1/cache/objects/kubelet/nodes/edge-node1?output=json" {
+		t.Errorf("unexpected command of cache get pod %v", cmd)
+	}
+}
+
+func TestValidateCacheGetOptions(t *testing.T) {
+	testcases := map[string]struct {
+		options CacheGetOptions
+		valid   bool
+	}{
+		"object": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/clusterroles/system:node", Output: "yaml", Timeout: time.Minute},
+			valid:   true,
+		},
+		"collection": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/pods", Output: "json", Timeout: time.Minute},
+			valid:   true,
+		},
+		"component only": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet", Output: "yaml", Timeout: time.Minute},
+		},
+		"too many parts": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/pods/default/nginx/status", Output: "yaml", Timeout: time.Minute},
+		},
+		"invalid characters": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/pods?output=json&x", Output: "yaml", Timeout: time.Minute},
+		},
+		"parent dir": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/../../etc", Output: "yaml", Timeout: time.Minute},
+		},
+		"invalid output": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/pods", Output: "wide", Timeout: time.Minute},
+		},
+		"invalid hub address": {
+			options: CacheGetOptions{NodeName: "node1", Key: "kubelet/pods", Output: "yaml", HubAddress: "10.0.0.5:10261", Timeout: time.Minute},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expect valid, but got %s", err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expect invalid")
+			}
+		})
+	}
+}
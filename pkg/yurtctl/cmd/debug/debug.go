@@ -0,0 +1,21 @@
+package debug
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// NewDebugCmd generates a new debug command
+func NewDebugCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Troubleshoots the edge nodes of yurt cluster",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(NewCacheDiffCmd())
+	cmd.AddCommand(NewCacheGetCmd())
+
+	return cmd
+}
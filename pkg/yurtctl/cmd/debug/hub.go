@@ -0,0 +1,121 @@
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	tmplutil "github.com/alibaba/openyurt/pkg/yurtctl/util/templates"
+)
+
+// checkHubAdminPodPeriod is the period that the pod reads the admin api of yurt-hub is checked
+const checkHubAdminPodPeriod = 2 * time.Second
+
+// validateHubAddress makes sure the address of yurt-hub is a valid http(s) url
+func validateHubAddress(hubAddress string) error {
+	u, err := url.Parse(hubAddress)
+	if err != nil {
+		return fmt.Errorf("hub address(%s) is invalid: %s", hubAddress, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme of hub address(%s) is not supported, valid schemes are: http, https", hubAddress)
+	}
+	return nil
+}
+
+// getFromHub gets the path(with query) from the admin api of yurt-hub at hubAddress directly
+func getFromHub(hubAddress, path string, timeout time.Duration) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(strings.TrimSuffix(hubAddress, "/") + path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to get %s from %s: %s", path, hubAddress, err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read %s from %s: %s", path, hubAddress, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fail to get %s from %s: %s: %s", path, hubAddress, resp.Status, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// getFromPod runs a pod on the node that prints the response of path(with query) from the admin api
+// of yurt-hub, and reads the log of the pod, the pod is deleted before it returns.
+func getFromPod(clientSet kubernetes.Interface, podName, nodeName, path string, timeout time.Duration) ([]byte, error) {
+	pod, err := newHubAdminPod(podName, nodeName, path)
+	if err != nil {
+		return nil, err
+	}
+	pods := clientSet.CoreV1().Pods(pod.GetNamespace())
+	if _, err := pods.Create(pod); err != nil {
+		return nil, fmt.Errorf("fail to create pod(%s), delete it if it's left by the last run: %s",
+			pod.GetName(), err)
+	}
+	defer func() {
+		if err := pods.Delete(pod.GetName(), &metav1.DeleteOptions{}); err != nil {
+			klog.Errorf("fail to delete pod(%s): %s", pod.GetName(), err)
+		}
+	}()
+
+	var phase v1.PodPhase
+	err = wait.Poll(checkHubAdminPodPeriod, timeout, func() (bool, error) {
+		current, err := pods.Get(pod.GetName(), metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("fail to get pod(%s): %s", pod.GetName(), err)
+			return false, nil
+		}
+		phase = current.Status.Phase
+		return phase == v1.PodSucceeded || phase == v1.PodFailed, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return nil, fmt.Errorf("pod(%s) is not complete in %v", pod.GetName(), timeout)
+	} else if err != nil {
+		return nil, err
+	}
+
+	b, err := pods.GetLogs(pod.GetName(), &v1.PodLogOptions{}).Do().Raw()
+	if err != nil {
+		return nil, fmt.Errorf("fail to get log of pod(%s): %s", pod.GetName(), err)
+	}
+	if phase == v1.PodFailed {
+		return nil, fmt.Errorf("fail to get %s from yurt-hub on node %s, is yurt-hub running? %s",
+			path, nodeName, strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+// newHubAdminPod renders the pod that gets the path from the admin api of yurt-hub on the node
+func newHubAdminPod(name, nodeName, path string) (*v1.Pod, error) {
+	podYaml, err := tmplutil.SubsituteTemplate(constants.HubAdminPodTemplate, map[string]string{
+		"Name":     name,
+		"NodeName": nodeName,
+		"Path":     path,
+	})
+	if err != nil {
+		return nil, err
+	}
+	podObj, err := kubeutil.YamlToObject([]byte(podYaml))
+	if err != nil {
+		return nil, err
+	}
+	pod, ok := podObj.(*v1.Pod)
+	if !ok {
+		return nil, errors.New("fail to assert hub admin pod")
+	}
+	return pod, nil
+}
@@ -0,0 +1,295 @@
+package decommission
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+// nodeUserPrefix is the prefix of the user of node in its client certificate
+const nodeUserPrefix = "system:node:"
+
+// DecommissionOptions has the information that required by decommission operation
+type DecommissionOptions struct {
+	clientSet    kubernetes.Interface
+	NodeName     string
+	SkipDrain    bool
+	DrainTimeout time.Duration
+	// WipeCache reverts kubelet, removes yurt-hub and wipes its cache on the node by the
+	// servant job before the node is removed
+	WipeCache   bool
+	out         io.Writer
+	checkPeriod time.Duration
+}
+
+// NewDecommissionOptions creates a new DecommissionOptions
+func NewDecommissionOptions() *DecommissionOptions {
+	return &DecommissionOptions{checkPeriod: 5 * time.Second}
+}
+
+// NewDecommissionCmd generates a new decommission command
+func NewDecommissionCmd() *cobra.Command {
+	do := NewDecommissionOptions()
+	cmd := &cobra.Command{
+		Use:   "decommission NODE",
+		Short: "Removes a retired edge node from the yurt cluster",
+		Long: "Removes a retired edge node from the yurt cluster. The node is cordoned and drained(best-effort, " +
+			"the pods that can't be evicted in --drain-timeout are left to be garbage collected), yurt-hub and its " +
+			"cache are wiped on the node if --wipe-cache is set, the certificate signing requests of the node are " +
+			"deleted, and the node object is removed at last.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := do.Complete(cmd.Flags(), args, cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the decommission option: %s", err)
+			}
+			if err := do.Validate(); err != nil {
+				klog.Fatalf("decommission option is invalid: %s", err)
+			}
+			if err := do.RunDecommission(); err != nil {
+				klog.Fatalf("fail to decommission node %s: %s", do.NodeName, err)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("skip-drain", false,
+		"Remove the node without evicting its pods, e.g. the node is lost already.")
+	cmd.Flags().Duration("drain-timeout", 2*time.Minute,
+		"The time to wait for the pods on the node to be evicted, the node is removed anyway after the timeout.")
+	cmd.Flags().Bool("wipe-cache", false,
+		"Revert kubelet, remove yurt-hub and wipe its cache on the node by the servant job before the node is removed, "+
+			"the node must be connected to the cloud.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (do *DecommissionOptions) Complete(flags *pflag.FlagSet, args []string, out io.Writer) error {
+	do.NodeName = args[0]
+
+	var err error
+	do.SkipDrain, err = flags.GetBool("skip-drain")
+	if err != nil {
+		return err
+	}
+
+	do.DrainTimeout, err = flags.GetDuration("drain-timeout")
+	if err != nil {
+		return err
+	}
+
+	do.WipeCache, err = flags.GetBool("wipe-cache")
+	if err != nil {
+		return err
+	}
+
+	do.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+	do.out = out
+	return nil
+}
+
+// Validate makes sure provided values for DecommissionOptions are valid
+func (do *DecommissionOptions) Validate() error {
+	if do.NodeName == "" {
+		return errors.New("node is not specified")
+	}
+	if !do.SkipDrain && do.DrainTimeout <= 0 {
+		return fmt.Errorf("drain timeout(%v) must be positive", do.DrainTimeout)
+	}
+	return nil
+}
+
+// RunDecommission cordons and drains the node, wipes yurt-hub on the node if required,
+// deletes the certificate signing requests of the node and removes the node at last.
+func (do *DecommissionOptions) RunDecommission() error {
+	node, err := do.clientSet.CoreV1().Nodes().Get(do.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	// 1. cordon the node, so no new pod is placed on it
+	if !node.Spec.Unschedulable {
+		if _, err := do.clientSet.CoreV1().Nodes().Patch(node.Name, types.StrategicMergePatchType,
+			[]byte(`{"spec":{"unschedulable":true}}`)); err != nil {
+			return fmt.Errorf("fail to cordon the node: %s", err)
+		}
+		klog.Infof("node %s is cordoned", node.Name)
+	}
+
+	// 2. evict the pods on the node, the pods left are garbage collected after the node is removed
+	if !do.SkipDrain {
+		if left := do.drain(); len(left) != 0 {
+			klog.Warningf("%d pods are not evicted from node %s in %v: %s",
+				len(left), node.Name, do.DrainTimeout, strings.Join(left, ", "))
+		} else {
+			klog.Infof("node %s is drained", node.Name)
+		}
+	}
+
+	// 3. wipe yurt-hub and its cache, kubelet reports the result before the node is removed
+	if do.WipeCache {
+		klog.Infof("wiping yurt-hub and its cache on node %s...", node.Name)
+		if err := kubeutil.RunServantJob(do.clientSet, kubeutil.ServantJobParams{
+			Action: kubeutil.ServantJobActionWipe,
+		}, node.Name); err != nil {
+			return err
+		}
+	}
+
+	// 4. delete the certificate signing requests of the node, so they can't be reused
+	expiration, err := do.deleteCSRs()
+	if err != nil {
+		return err
+	}
+
+	// 5. remove the node
+	err = do.clientSet.CoreV1().Nodes().Delete(node.Name, &metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("fail to delete the node: %s", err)
+	}
+	klog.Infof("node %s is removed", node.Name)
+
+	if !expiration.IsZero() {
+		fmt.Fprintf(do.out, "The client certificates issued to node %s can not be revoked, they stay valid until %s. "+
+			"Make sure the node doesn't join the cluster again, or rotate the CA if the node is stolen.\n",
+			node.Name, expiration.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// drain evicts the pods on the node until they are gone or DrainTimeout, the mirror pods
+// and the pods of DaemonSets are not evicted. it returns the pods that are left.
+func (do *DecommissionOptions) drain() []string {
+	var left []string
+	wait.PollImmediate(do.checkPeriod, do.DrainTimeout, func() (bool, error) {
+		pods, err := do.podsToEvict()
+		if err != nil {
+			klog.Errorf("fail to list the pods on node %s: %s", do.NodeName, err)
+			return false, nil
+		}
+
+		left = left[:0]
+		for _, pod := range pods {
+			left = append(left, pod.Namespace+"/"+pod.Name)
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			err := do.clientSet.PolicyV1beta1().Evictions(pod.Namespace).Evict(&policyv1beta1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+			})
+			if err != nil && !apierrors.IsNotFound(err) {
+				// e.g. the eviction is disallowed by the pod disruption budget, it's retried
+				klog.V(2).Infof("fail to evict pod %s/%s: %s", pod.Namespace, pod.Name, err)
+			}
+		}
+		return len(left) == 0, nil
+	})
+	return left
+}
+
+func (do *DecommissionOptions) podsToEvict() ([]v1.Pod, error) {
+	podList, err := do.clientSet.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", do.NodeName).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []v1.Pod
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName != do.NodeName {
+			continue
+		}
+		if _, ok := pod.Annotations[v1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.Kind == "DaemonSet" {
+			continue
+		}
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		pods = append(pods, pod)
+	}
+	return pods, nil
+}
+
+// deleteCSRs deletes the certificate signing requests for the client certificates of the node,
+// and returns the latest expiration of the certificates issued by them.
+func (do *DecommissionOptions) deleteCSRs() (time.Time, error) {
+	var expiration time.Time
+	csrs, err := do.clientSet.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	if err != nil {
+		return expiration, fmt.Errorf("fail to list the certificate signing requests: %s", err)
+	}
+
+	for i := range csrs.Items {
+		csr := &csrs.Items[i]
+		if !requestedForNode(csr, do.NodeName) {
+			continue
+		}
+		if notAfter, ok := certificateExpiration(csr); ok && notAfter.After(expiration) {
+			expiration = notAfter
+		}
+
+		err := do.clientSet.CertificatesV1beta1().CertificateSigningRequests().Delete(csr.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return expiration, fmt.Errorf("fail to delete the certificate signing request %s: %s", csr.Name, err)
+		}
+		klog.Infof("certificate signing request %s of node %s is deleted", csr.Name, do.NodeName)
+	}
+	return expiration, nil
+}
+
+// requestedForNode returns true if the certificate signing request is for the user of node,
+// the requests of kubelet bootstrap are sent by the bootstrap token, so the subject is checked.
+func requestedForNode(csr *certv1beta1.CertificateSigningRequest, nodeName string) bool {
+	user := nodeUserPrefix + nodeName
+	if csr.Spec.Username == user {
+		return true
+	}
+
+	block, _ := pem.Decode(csr.Spec.Request)
+	if block == nil {
+		return false
+	}
+	req, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return false
+	}
+	return req.Subject.CommonName == user
+}
+
+// certificateExpiration returns the expiration of the certificate issued by the request
+func certificateExpiration(csr *certv1beta1.CertificateSigningRequest) (time.Time, bool) {
+	block, _ := pem.Decode(csr.Status.Certificate)
+	if block == nil {
+		return time.Time{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return cert.NotAfter, true
+}
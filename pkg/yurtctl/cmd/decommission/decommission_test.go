@@ -0,0 +1,174 @@
+package decommission
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	certv1beta1 "k8s.io/api/certificates/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newTestCSR(t *testing.T, name, username, commonName string, notAfter time.Time) *certv1beta1.CertificateSigningRequest {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("fail to generate key: %s", err)
+	}
+	subject := pkix.Name{CommonName: commonName, Organization: []string{"system:nodes"}}
+	req, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, key)
+	if err != nil {
+		t.Fatalf("fail to create certificate request: %s", err)
+	}
+	csr := &certv1beta1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: certv1beta1.CertificateSigningRequestSpec{
+			Username: username,
+			Request:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: req}),
+		},
+	}
+	if !notAfter.IsZero() {
+		tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: subject, NotBefore: notAfter.Add(-time.Hour), NotAfter: notAfter}
+		cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+		if err != nil {
+			t.Fatalf("fail to create certificate: %s", err)
+		}
+		csr.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	}
+	return csr
+}
+
+func newTestPod(name, nodeName, ownerKind string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+		Status:     v1.PodStatus{Phase: v1.PodRunning},
+	}
+	if ownerKind != "" {
+		controller := true
+		pod.OwnerReferences = []metav1.OwnerReference{{Kind: ownerKind, Name: "foo", Controller: &controller}}
+	}
+	return pod
+}
+
+func TestRunDecommission(t *testing.T) {
+	notAfter := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	objs := []runtime.Object{
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-node1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-node2"}},
+		newTestPod("web-1", "edge-node1", "ReplicaSet"),
+		newTestPod("proxy-1", "edge-node1", "DaemonSet"),
+		newTestPod("web-2", "edge-node2", "ReplicaSet"),
+		newTestCSR(t, "csr-bootstrap", "system:bootstrap:abcdef", "system:node:edge-node1", notAfter.Add(-time.Hour)),
+		newTestCSR(t, "csr-rotate", "system:node:edge-node1", "system:node:edge-node1", notAfter),
+		newTestCSR(t, "csr-other", "system:node:edge-node2", "system:node:edge-node2", notAfter.Add(time.Hour)),
+	}
+	clientSet := fake.NewSimpleClientset(objs...)
+	// the fake client doesn't delete the evicted pod, it's deleted after the reactor returns
+	clientSet.PrependReactor("create", "pods", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		name := action.(clienttesting.CreateAction).GetObject().(metav1.Object).GetName()
+		go clientSet.CoreV1().Pods(action.GetNamespace()).Delete(name, &metav1.DeleteOptions{})
+		return true, nil, nil
+	})
+
+	var out bytes.Buffer
+	do := &DecommissionOptions{
+		clientSet:    clientSet,
+		NodeName:     "edge-node1",
+		DrainTimeout: time.Second,
+		out:          &out,
+		checkPeriod:  10 * time.Millisecond,
+	}
+	if err := do.RunDecommission(); err != nil {
+		t.Fatalf("fail to decommission the node: %s", err)
+	}
+
+	if _, err := clientSet.CoreV1().Nodes().Get("edge-node1", metav1.GetOptions{}); err == nil {
+		t.Errorf("expect node edge-node1 is removed")
+	}
+
+	pods, _ := clientSet.CoreV1().Pods("default").List(metav1.ListOptions{})
+	var podNames []string
+	for _, pod := range pods.Items {
+		podNames = append(podNames, pod.Name)
+	}
+	sort.Strings(podNames)
+	if expected := []string{"proxy-1", "web-2"}; !reflect.DeepEqual(podNames, expected) {
+		t.Errorf("expect pods %v are left, but got %v", expected, podNames)
+	}
+
+	csrs, _ := clientSet.CertificatesV1beta1().CertificateSigningRequests().List(metav1.ListOptions{})
+	if len(csrs.Items) != 1 || csrs.Items[0].Name != "csr-other" {
+		t.Errorf("expect only csr-other is left, but got %v", csrs.Items)
+	}
+
+	if !strings.Contains(out.String(), notAfter.Format(time.RFC3339)) {
+		t.Errorf("expect the expiration of certificates is printed, but got %q", out.String())
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "edge-node1"}},
+		newTestPod("db-1", "edge-node1", "StatefulSet"),
+	)
+
+	do := &DecommissionOptions{
+		clientSet:    clientSet,
+		NodeName:     "edge-node1",
+		DrainTimeout: 50 * time.Millisecond,
+		checkPeriod:  10 * time.Millisecond,
+	}
+	if left := do.drain(); !reflect.DeepEqual(left, []string{"default/db-1"}) {
+		t.Errorf("expect default/db-1 is left, but got %v", left)
+	}
+}
+
+func TestValidateDecommissionOptions(t *testing.T) {
+	testcases := map[string]struct {
+		options DecommissionOptions
+		valid   bool
+	}{
+		"valid": {
+			options: DecommissionOptions{NodeName: "edge-node1", DrainTimeout: time.Minute},
+			valid:   true,
+		},
+		"skip drain": {
+			options: DecommissionOptions{NodeName: "edge-node1", SkipDrain: true},
+			valid:   true,
+		},
+		"no node": {
+			options: DecommissionOptions{DrainTimeout: time.Minute},
+		},
+		"invalid drain timeout": {
+			options: DecommissionOptions{NodeName: "edge-node1"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := tt.options.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("expect valid, but got %s", err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expect invalid")
+			}
+		})
+	}
+}
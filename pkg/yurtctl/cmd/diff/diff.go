@@ -0,0 +1,333 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+// ExternalDiffEnv is the environment variable that overrides the diff program, like
+// KUBECTL_EXTERNAL_DIFF of kubectl, the dirs of live and merged objects are appended
+// to it as the arguments.
+const ExternalDiffEnv = "YURTCTL_EXTERNAL_DIFF"
+
+// yurtImagePrefix is the prefix of the images of yurt components that are retagged by --version
+const yurtImagePrefix = "openyurt/"
+
+// errDiffFound is returned when the rendered objects differ from the live objects
+var errDiffFound = errors.New("differences are found")
+
+// DiffOptions has the information that required by diff operation
+type DiffOptions struct {
+	dynamicClient dynamic.Interface
+	mapper        meta.RESTMapper
+	// version is the tag of yurt images in the rendered manifests
+	version string
+	// files are the manifests that are rendered instead of the manifests of yurtctl
+	files []string
+	out   io.Writer
+}
+
+// NewDiffOptions creates a new DiffOptions
+func NewDiffOptions() *DiffOptions {
+	return &DiffOptions{}
+}
+
+// NewDiffCmd generates a new diff command
+func NewDiffCmd() *cobra.Command {
+	do := NewDiffOptions()
+	cmd := &cobra.Command{
+		Use:   "diff [--version VERSION] [-f MANIFEST]",
+		Short: "Diffs the manifests of a target version against the objects deployed in the cluster",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := do.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the diff option: %s", err)
+			}
+			if err := do.RunDiff(); err == errDiffFound {
+				// like diff(and kubectl diff), exit code 1 means differences are found
+				os.Exit(1)
+			} else if err != nil {
+				klog.Fatalf("fail to diff: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("version", "",
+		"The tag of yurt images(openyurt/*) in the rendered manifests, the tags in manifests are kept if it's not set. "+
+			"(e.g. --version=v0.3.0)")
+	cmd.Flags().StringSliceP("filename", "f", []string{},
+		"The manifests(like config/setup/*.yaml of the target version) that are diffed instead of the manifests "+
+			"deployed by yurtctl convert. (e.g. -f config/setup/yurt-ctrl-mgr.yaml)")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (do *DiffOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	restCfg, err := kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+
+	do.dynamicClient, err = dynamic.NewForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+
+	dc, err := discovery.NewDiscoveryClientForConfig(restCfg)
+	if err != nil {
+		return err
+	}
+	do.mapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
+
+	do.version, err = flags.GetString("version")
+	if err != nil {
+		return err
+	}
+
+	do.files, err = flags.GetStringSlice("filename")
+	if err != nil {
+		return err
+	}
+	do.out = out
+	return nil
+}
+
+// RunDiff renders the manifests, and diffs the objects in the cluster against the objects
+// that would be there if the manifests are applied. the objects that would be there are
+// returned by kube-apiserver with server-side dry-run, so the defaults and admission
+// webhooks are taken into account. errDiffFound is returned if there are differences.
+func (do *DiffOptions) RunDiff() error {
+	objs, err := do.render()
+	if err != nil {
+		return err
+	}
+
+	dir, err := ioutil.TempDir("", "yurtctl-diff-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	liveDir, mergedDir := filepath.Join(dir, "LIVE"), filepath.Join(dir, "MERGED")
+	for _, d := range []string{liveDir, mergedDir} {
+		if err := os.Mkdir(d, 0700); err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range objs {
+		live, merged, err := do.dryRun(obj)
+		if err != nil {
+			return fmt.Errorf("fail to dry-run %s %s: %s", obj.GetKind(), objectName(obj), err)
+		}
+		name := fileName(obj)
+		if err := writeObject(filepath.Join(liveDir, name), live); err != nil {
+			return err
+		}
+		if err := writeObject(filepath.Join(mergedDir, name), merged); err != nil {
+			return err
+		}
+	}
+
+	return runDiff(liveDir, mergedDir, do.out)
+}
+
+// render returns the objects of the manifests in files, or the manifests deployed by
+// yurtctl convert if no file is set, the yurt images are retagged with version.
+func (do *DiffOptions) render() ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	if len(do.files) == 0 {
+		manifestObjs, err := decodeManifest([]byte(constants.YurtControllerManagerDeployment))
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, manifestObjs...)
+	}
+
+	for _, file := range do.files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("fail to read manifest %s: %s", file, err)
+		}
+		manifestObjs, err := decodeManifest(b)
+		if err != nil {
+			return nil, fmt.Errorf("fail to decode manifest %s: %s", file, err)
+		}
+		objs = append(objs, manifestObjs...)
+	}
+
+	if do.version != "" {
+		for _, obj := range objs {
+			retagImages(obj.Object, do.version)
+		}
+	}
+	return objs, nil
+}
+
+// decodeManifest decodes the objects in manifest of yaml(or json) documents
+func decodeManifest(manifest []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err == io.EOF {
+			return objs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			// empty document
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("kind and name of object must be set in manifest")
+		}
+		objs = append(objs, obj)
+	}
+}
+
+// retagImages sets the tag of yurt images in the containers of obj(and its pod template)
+func retagImages(obj map[string]interface{}, version string) {
+	for key, value := range obj {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			retagImages(v, version)
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					retagImages(m, version)
+				}
+			}
+		case string:
+			if key == "image" && strings.HasPrefix(v, yurtImagePrefix) {
+				obj[key] = retagImage(v, version)
+			}
+		}
+	}
+}
+
+// retagImage replaces the tag(or digest) of image with version
+func retagImage(image, version string) string {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image + ":" + version
+}
+
+// dryRun returns the live object, and the object that would be in the cluster if obj is
+// applied, by the server-side dry-run of create(obj doesn't exist) or merge patch. live
+// is nil if obj doesn't exist.
+func (do *DiffOptions) dryRun(obj *unstructured.Unstructured) (live, merged *unstructured.Unstructured, err error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := do.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ri dynamic.ResourceInterface = do.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(metav1.NamespaceDefault)
+		}
+		ri = do.dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace())
+	}
+
+	dryRun := []string{metav1.DryRunAll}
+	live, err = ri.Get(obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		merged, err = ri.Create(obj, metav1.CreateOptions{DryRun: dryRun})
+		return nil, merged, err
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	// fields that are not in the manifest(like set by controllers) are kept by merge patch
+	patch, err := json.Marshal(obj.Object)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, err = ri.Patch(obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{DryRun: dryRun})
+	return live, merged, err
+}
+
+// objectName returns the namespace/name of obj
+func objectName(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// fileName returns the name of file that obj is written to, like kubectl diff
+func fileName(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	parts := []string{gvk.Group, gvk.Version, gvk.Kind, obj.GetNamespace(), obj.GetName()}
+	if gvk.Group == "" {
+		parts = parts[1:]
+	}
+	if obj.GetNamespace() == "" {
+		parts = append(parts[:len(parts)-2], parts[len(parts)-1])
+	}
+	return strings.Join(parts, ".")
+}
+
+// writeObject writes obj into file in yaml format, nothing is written if obj is nil,
+// so the diff shows the object is created. managed fields are left out as kubectl diff.
+func writeObject(file string, obj *unstructured.Unstructured) error {
+	if obj == nil {
+		return nil
+	}
+
+	obj = obj.DeepCopy()
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+	b, err := yaml.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, b, 0600)
+}
+
+// runDiff runs the diff program on the dirs of live and merged objects, errDiffFound is
+// returned if the diff program exits with 1.
+func runDiff(liveDir, mergedDir string, out io.Writer) error {
+	args := []string{"diff", "-u", "-N"}
+	if external := os.Getenv(ExternalDiffEnv); external != "" {
+		args = strings.Fields(external)
+	}
+
+	cmd := exec.Command(args[0], append(args[1:], liveDir, mergedDir)...)
+	cmd.Stdout, cmd.Stderr = out, out
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return errDiffFound
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func TestRetagImage(t *testing.T) {
+	testcases := map[string]struct {
+		image  string
+		expect string
+	}{
+		"tag": {
+			image:  "openyurt/yurt-ctrl-mgr:latest",
+			expect: "openyurt/yurt-ctrl-mgr:v0.3.0",
+		},
+		"no tag": {
+			image:  "openyurt/yurt-ctrl-mgr",
+			expect: "openyurt/yurt-ctrl-mgr:v0.3.0",
+		},
+		"digest": {
+			image:  "openyurt/yurt-ctrl-mgr@sha256:0123",
+			expect: "openyurt/yurt-ctrl-mgr:v0.3.0",
+		},
+		"registry with port": {
+			image:  "registry.example.com:5000/openyurt/yurt-hub",
+			expect: "registry.example.com:5000/openyurt/yurt-hub:v0.3.0",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if got := retagImage(tt.image, "v0.3.0"); got != tt.expect {
+				t.Errorf("expect image %s, but got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	do := &DiffOptions{version: "v0.3.0"}
+	objs, err := do.render()
+	if err != nil {
+		t.Fatalf("fail to render manifests: %s", err)
+	}
+	if len(objs) != 1 || objs[0].GetKind() != "Deployment" || objs[0].GetName() != "yurt-ctrl-mgr" {
+		t.Fatalf("expect deployment yurt-ctrl-mgr, but got %v", objs)
+	}
+
+	containers, _, _ := unstructured.NestedSlice(objs[0].Object, "spec", "template", "spec", "containers")
+	if image := containers[0].(map[string]interface{})["image"]; image != "openyurt/yurt-ctrl-mgr:v0.3.0" {
+		t.Errorf("expect image openyurt/yurt-ctrl-mgr:v0.3.0, but got %v", image)
+	}
+}
+
+func TestDecodeManifest(t *testing.T) {
+	manifest := `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: yurt-ctrl-mgr
+  namespace: kube-system
+---
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: yurt-ctrl-mgr
+`
+	objs, err := decodeManifest([]byte(manifest))
+	if err != nil {
+		t.Fatalf("fail to decode manifest: %s", err)
+	}
+
+	var names []string
+	for _, obj := range objs {
+		names = append(names, fileName(obj))
+	}
+	expect := "v1.ServiceAccount.kube-system.yurt-ctrl-mgr,rbac.authorization.k8s.io.v1.ClusterRole.yurt-ctrl-mgr"
+	if got := strings.Join(names, ","); got != expect {
+		t.Errorf("expect objects %s, but got %s", expect, got)
+	}
+
+	if _, err := decodeManifest([]byte("apiVersion: v1\nkind: ServiceAccount\n")); err == nil {
+		t.Errorf("expect object without name is refused, but got nil")
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	testcases := map[string]struct {
+		version     string
+		deployed    bool
+		expectErr   error
+		expectLines []string
+	}{
+		"not changed": {
+			deployed: true,
+		},
+		"image changed": {
+			version:     "v0.3.0",
+			deployed:    true,
+			expectErr:   errDiffFound,
+			expectLines: []string{"-        image: openyurt/yurt-ctrl-mgr:latest", "+        image: openyurt/yurt-ctrl-mgr:v0.3.0"},
+		},
+		"not deployed": {
+			expectErr:   errDiffFound,
+			expectLines: []string{"+  name: yurt-ctrl-mgr"},
+		},
+	}
+
+	gvk := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			var objs []runtime.Object
+			if tt.deployed {
+				deployed, err := decodeManifest([]byte(constants.YurtControllerManagerDeployment))
+				if err != nil {
+					t.Fatalf("fail to decode manifest: %s", err)
+				}
+				objs = append(objs, deployed[0])
+			}
+
+			mapper := meta.NewDefaultRESTMapper(nil)
+			mapper.Add(gvk, meta.RESTScopeNamespace)
+			var out bytes.Buffer
+			do := &DiffOptions{
+				dynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objs...),
+				mapper:        mapper,
+				version:       tt.version,
+				out:           &out,
+			}
+
+			if err := do.RunDiff(); err != tt.expectErr {
+				t.Fatalf("expect error %v, but got %v, output:\n%s", tt.expectErr, err, out.String())
+			}
+			for _, line := range tt.expectLines {
+				if !strings.Contains(out.String(), line) {
+					t.Errorf("expect line %q in output, but got:\n%s", line, out.String())
+				}
+			}
+			if tt.expectErr == nil && out.Len() != 0 {
+				t.Errorf("expect no differences, but got:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,592 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+// Status signifies the result of a check
+type Status string
+
+const (
+	// StatusOK means the prerequisite is met
+	StatusOK Status = "ok"
+	// StatusWarn means the conversion may succeed, but the prerequisite should be reviewed
+	StatusWarn Status = "warn"
+	// StatusFail means the conversion will fail unless the prerequisite is met
+	StatusFail Status = "fail"
+	// StatusSkip means the prerequisite is not checked
+	StatusSkip Status = "skip"
+
+	// defaultProbeImage is the image of the job that probes the tunnel server on the sample edge node
+	defaultProbeImage = "busybox:1.32"
+	// probeConnectTimeout is the seconds that the probe waits for the tunnel server to accept the connection
+	probeConnectTimeout = 5
+	// checkProbePeriod is the period to check whether the probe job is complete
+	checkProbePeriod = 2 * time.Second
+)
+
+// CheckResult is the result of a check of the cloud-side prerequisites
+type CheckResult struct {
+	Check       string
+	Status      Status
+	Message     string
+	Remediation string
+}
+
+// permission is a permission that convert needs
+type permission struct {
+	verb      string
+	group     string
+	resource  string
+	namespace string
+}
+
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	if p.namespace != "" {
+		return fmt.Sprintf("%s %s in %s", p.verb, resource, p.namespace)
+	}
+	return fmt.Sprintf("%s %s", p.verb, resource)
+}
+
+// requiredPermissions are the permissions that the caller of convert needs, it labels and
+// annotates nodes, creates the yurt-controller-manager, deletes the service account of
+// node-controller, runs the servant jobs(and the prepull daemonset) on edge nodes, and
+// stores the conversion state in a configmap.
+var requiredPermissions = []permission{
+	{verb: "list", resource: "nodes"},
+	{verb: "get", resource: "nodes"},
+	{verb: "update", resource: "nodes"},
+	{verb: "create", group: "apps", resource: "deployments", namespace: metav1.NamespaceSystem},
+	{verb: "delete", resource: "serviceaccounts", namespace: metav1.NamespaceSystem},
+	{verb: "create", group: "batch", resource: "jobs", namespace: metav1.NamespaceSystem},
+	{verb: "get", group: "batch", resource: "jobs", namespace: metav1.NamespaceSystem},
+	{verb: "delete", group: "batch", resource: "jobs", namespace: metav1.NamespaceSystem},
+	{verb: "list", resource: "pods", namespace: metav1.NamespaceSystem},
+	{verb: "create", group: "apps", resource: "daemonsets", namespace: metav1.NamespaceSystem},
+	{verb: "delete", group: "apps", resource: "daemonsets", namespace: metav1.NamespaceSystem},
+	{verb: "get", resource: "configmaps", namespace: metav1.NamespaceSystem},
+	{verb: "create", resource: "configmaps", namespace: metav1.NamespaceSystem},
+	{verb: "update", resource: "configmaps", namespace: metav1.NamespaceSystem},
+}
+
+// requiredAPI is an api that the yurt cluster needs from kube-apiserver
+type requiredAPI struct {
+	groupVersion string
+	resource     string
+	// featureGate is the feature gate of kube-apiserver that serves the api, if any
+	featureGate string
+	// optional apis are warned instead of failed if they are not served
+	optional bool
+	reason   string
+}
+
+// requiredAPIs are the apis that the yurt cluster needs. feature gates of kube-apiserver
+// are not exposed by its api, so they are checked by the apis that they enable.
+var requiredAPIs = []requiredAPI{
+	{groupVersion: "apps/v1", resource: "deployments", reason: "yurt-controller-manager is deployed by a deployment"},
+	{groupVersion: "apps/v1", resource: "daemonsets", reason: "images are pre-pulled by a daemonset"},
+	{groupVersion: "batch/v1", resource: "jobs", reason: "edge nodes are converted by servant jobs"},
+	{groupVersion: "coordination.k8s.io/v1", resource: "leases", featureGate: "NodeLease", optional: true,
+		reason: "node leases are the heartbeats of kubelet that yurt-hub caches"},
+	{groupVersion: "authorization.k8s.io/v1", resource: "subjectaccessreviews", optional: true,
+		reason: "remote wipe of yurt-hub authorizes the callers by subject access reviews"},
+}
+
+// convertWrite is a write of convert that admission webhooks may intercept
+type convertWrite struct {
+	operation admissionv1beta1.OperationType
+	group     string
+	resource  string
+}
+
+// convertWrites are the writes of convert(and the controllers that act for it, like the
+// pods created by the job controller), a webhook that intercepts them and fails closed
+// blocks the conversion when its service is unavailable.
+var convertWrites = []convertWrite{
+	{operation: admissionv1beta1.Update, resource: "nodes"},
+	{operation: admissionv1beta1.Create, group: "apps", resource: "deployments"},
+	{operation: admissionv1beta1.Delete, resource: "serviceaccounts"},
+	{operation: admissionv1beta1.Create, group: "batch", resource: "jobs"},
+	{operation: admissionv1beta1.Create, group: "apps", resource: "daemonsets"},
+	{operation: admissionv1beta1.Create, resource: "pods"},
+	{operation: admissionv1beta1.Create, resource: "configmaps"},
+	{operation: admissionv1beta1.Update, resource: "configmaps"},
+}
+
+// DoctorOptions has the information that required by doctor operation
+type DoctorOptions struct {
+	clientSet  kubernetes.Interface
+	CloudNodes []string
+	// TunnelServerAddress is the address that yurt-tunnel-agent on edge nodes connects to
+	TunnelServerAddress string
+	// SampleEdgeNode is the edge node that the tunnel server is probed from
+	SampleEdgeNode string
+	ProbeImage     string
+	ProbeTimeout   time.Duration
+	out            io.Writer
+	// probeTunnel probes the tunnel server address from the node, it's replaced in tests
+	probeTunnel func(nodeName, address string) error
+}
+
+// NewDoctorOptions creates a new DoctorOptions
+func NewDoctorOptions() *DoctorOptions {
+	do := &DoctorOptions{}
+	do.probeTunnel = do.runTunnelProbe
+	return do
+}
+
+// NewDoctorCmd generates a new doctor command
+func NewDoctorCmd() *cobra.Command {
+	do := NewDoctorOptions()
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Checks the cloud-side prerequisites of converting the cluster to a yurt cluster",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := do.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the doctor option: %s", err)
+			}
+			if err := do.RunDoctor(); err != nil {
+				klog.Fatalf("fail to pass the checks: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("cloud-nodes", "c", "",
+		"The list of cloud nodes that will be passed to convert.(e.g. -c cloudnode1,cloudnode2)")
+	cmd.Flags().String("tunnel-server-address", "",
+		"The address(host:port) that yurt-tunnel-agent on edge nodes connects to, it's probed from --sample-edge-node.")
+	cmd.Flags().String("sample-edge-node", "",
+		"The edge node that the tunnel server address is probed from, by a job with host network.")
+	cmd.Flags().String("probe-image", defaultProbeImage,
+		"The image(with nc) of the job that probes the tunnel server address.")
+	cmd.Flags().Duration("probe-timeout", kubeutil.WaitServantJobTimeout,
+		"The time to wait for the job that probes the tunnel server address.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (do *DoctorOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	cnStr, err := flags.GetString("cloud-nodes")
+	if err != nil {
+		return err
+	}
+	if cnStr != "" {
+		do.CloudNodes = strings.Split(cnStr, ",")
+	}
+
+	do.TunnelServerAddress, err = flags.GetString("tunnel-server-address")
+	if err != nil {
+		return err
+	}
+	do.SampleEdgeNode, err = flags.GetString("sample-edge-node")
+	if err != nil {
+		return err
+	}
+	if (do.TunnelServerAddress == "") != (do.SampleEdgeNode == "") {
+		return fmt.Errorf("--tunnel-server-address and --sample-edge-node must be set together")
+	}
+	if do.TunnelServerAddress != "" {
+		if _, _, err := net.SplitHostPort(do.TunnelServerAddress); err != nil {
+			return fmt.Errorf("tunnel server address(%s) is invalid: %s", do.TunnelServerAddress, err)
+		}
+	}
+	do.ProbeImage, err = flags.GetString("probe-image")
+	if err != nil {
+		return err
+	}
+	do.ProbeTimeout, err = flags.GetDuration("probe-timeout")
+	if err != nil {
+		return err
+	}
+
+	do.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+	do.out = out
+	return nil
+}
+
+// RunDoctor runs all checks of the cloud-side prerequisites, prints their results and the
+// remediation steps of the unmet ones. an error is returned if any check fails.
+func (do *DoctorOptions) RunDoctor() error {
+	var results []CheckResult
+	results = append(results, do.checkPermissions()...)
+	results = append(results, do.checkAPIs()...)
+	results = append(results, do.checkNodes()...)
+	results = append(results, do.checkWebhooks()...)
+	results = append(results, do.checkTunnel()...)
+
+	if err := PrintResults(do.out, results); err != nil {
+		return err
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status == StatusFail {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// PrintResults prints the results of checks, and the remediation steps of the unmet ones
+func PrintResults(out io.Writer, results []CheckResult) error {
+	w := tabwriter.NewWriter(out, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Check, r.Status, r.Message)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var steps []string
+	for _, r := range results {
+		if r.Remediation != "" && (r.Status == StatusFail || r.Status == StatusWarn) {
+			steps = append(steps, fmt.Sprintf("  - [%s] %s", r.Check, r.Remediation))
+		}
+	}
+	if len(steps) != 0 {
+		fmt.Fprintf(out, "\nRemediation:\n%s\n", strings.Join(steps, "\n"))
+	}
+	return nil
+}
+
+// checkPermissions checks the permissions that the caller needs to convert the cluster
+func (do *DoctorOptions) checkPermissions() []CheckResult {
+	var denied []string
+	for _, p := range requiredPermissions {
+		review, err := do.clientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(&authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:      p.verb,
+					Group:     p.group,
+					Resource:  p.resource,
+					Namespace: p.namespace,
+				},
+			},
+		})
+		if err != nil {
+			return []CheckResult{{Check: "rbac", Status: StatusFail,
+				Message:     fmt.Sprintf("fail to review the permissions of the caller: %s", err),
+				Remediation: "make sure the caller can create selfsubjectaccessreviews.authorization.k8s.io"}}
+		}
+		if !review.Status.Allowed {
+			denied = append(denied, p.String())
+		}
+	}
+
+	if len(denied) != 0 {
+		return []CheckResult{{Check: "rbac", Status: StatusFail,
+			Message: fmt.Sprintf("the caller can not %s", strings.Join(denied, ", ")),
+			Remediation: "bind the caller to a role with the permissions, like " +
+				"`kubectl create clusterrolebinding yurtctl --clusterrole=cluster-admin --user=<caller>`"}}
+	}
+	return []CheckResult{{Check: "rbac", Status: StatusOK,
+		Message: fmt.Sprintf("the caller has all %d permissions of convert", len(requiredPermissions))}}
+}
+
+// checkAPIs checks the apis(and the feature gates enabling them) that the yurt cluster needs
+func (do *DoctorOptions) checkAPIs() []CheckResult {
+	var results []CheckResult
+	for _, api := range requiredAPIs {
+		name := api.resource + "." + api.groupVersion
+		if api.featureGate != "" {
+			name += "(" + api.featureGate + ")"
+		}
+
+		served := false
+		resources, err := do.clientSet.Discovery().ServerResourcesForGroupVersion(api.groupVersion)
+		if err == nil {
+			for _, r := range resources.APIResources {
+				if r.Name == api.resource {
+					served = true
+					break
+				}
+			}
+		}
+		if served {
+			results = append(results, CheckResult{Check: "apis", Status: StatusOK, Message: name + " is served"})
+			continue
+		}
+
+		result := CheckResult{Check: "apis", Status: StatusFail,
+			Message:     fmt.Sprintf("%s is not served, %s", name, api.reason),
+			Remediation: fmt.Sprintf("enable %s on kube-apiserver by --runtime-config", api.groupVersion)}
+		if api.featureGate != "" {
+			result.Remediation = fmt.Sprintf("enable feature gate %s on kube-apiserver and kubelet by --feature-gates=%s=true",
+				api.featureGate, api.featureGate)
+		}
+		if api.optional {
+			result.Status = StatusWarn
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkNodes checks that the cloud nodes exist and are ready, yurt-controller-manager prefers
+// the cloud nodes, and the labels of nodes don't conflict with the cloud nodes.
+func (do *DoctorOptions) checkNodes() []CheckResult {
+	nodeLst, err := do.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return []CheckResult{{Check: "nodes", Status: StatusFail, Message: fmt.Sprintf("fail to list nodes: %s", err)}}
+	}
+	if len(do.CloudNodes) == 0 {
+		return []CheckResult{{Check: "nodes", Status: StatusWarn,
+			Message: "no cloud node is specified, all nodes will be converted to edge nodes, and yurt-controller-manager " +
+				"may run on an edge node",
+			Remediation: "specify the cloud nodes by --cloud-nodes, they will be passed to convert"}}
+	}
+
+	nodes := make(map[string]*v1.Node, len(nodeLst.Items))
+	for i := range nodeLst.Items {
+		nodes[nodeLst.Items[i].Name] = &nodeLst.Items[i]
+	}
+	cloudNodes := make(map[string]bool, len(do.CloudNodes))
+	var results []CheckResult
+	ready := 0
+	for _, name := range do.CloudNodes {
+		cloudNodes[name] = true
+		node, ok := nodes[name]
+		switch {
+		case !ok:
+			results = append(results, CheckResult{Check: "nodes", Status: StatusFail,
+				Message:     fmt.Sprintf("cloud node %s is not found", name),
+				Remediation: fmt.Sprintf("fix the name of cloud node %s in --cloud-nodes", name)})
+		case !nodeReady(node):
+			results = append(results, CheckResult{Check: "nodes", Status: StatusWarn,
+				Message:     fmt.Sprintf("cloud node %s is not ready", name),
+				Remediation: fmt.Sprintf("recover cloud node %s, or choose other cloud nodes", name)})
+		case node.Spec.Unschedulable:
+			results = append(results, CheckResult{Check: "nodes", Status: StatusWarn,
+				Message:     fmt.Sprintf("cloud node %s is cordoned", name),
+				Remediation: fmt.Sprintf("uncordon cloud node %s by `kubectl uncordon %s`", name, name)})
+		default:
+			ready++
+		}
+	}
+	if ready == 0 {
+		results = append(results, CheckResult{Check: "nodes", Status: StatusFail,
+			Message: fmt.Sprintf("no cloud node is available for yurt-controller-manager(node selector %s=false)",
+				constants.LabelEdgeWorker),
+			Remediation: "add a ready and schedulable node to --cloud-nodes"})
+	}
+
+	for _, node := range nodeLst.Items {
+		label, ok := node.Labels[constants.LabelEdgeWorker]
+		if !ok {
+			continue
+		}
+		if cloudNodes[node.Name] && label == "true" || !cloudNodes[node.Name] && label == "false" {
+			results = append(results, CheckResult{Check: "nodes", Status: StatusWarn,
+				Message: fmt.Sprintf("node %s is labeled %s=%s, it will be relabeled by convert",
+					node.Name, constants.LabelEdgeWorker, label),
+				Remediation: fmt.Sprintf("make sure node %s is classified correctly by --cloud-nodes", node.Name)})
+		}
+	}
+
+	if len(results) == 0 {
+		results = append(results, CheckResult{Check: "nodes", Status: StatusOK,
+			Message: fmt.Sprintf("%d cloud nodes are available", ready)})
+	}
+	return results
+}
+
+func nodeReady(node *v1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// checkWebhooks checks the admission webhooks that intercept the writes of convert and
+// fail closed, they block the conversion when their services are unavailable.
+func (do *DoctorOptions) checkWebhooks() []CheckResult {
+	var results []CheckResult
+	conflict := func(kind, config, webhook string, rules []admissionv1beta1.RuleWithOperations, policy *admissionv1beta1.FailurePolicyType) {
+		if policy == nil || *policy != admissionv1beta1.Fail {
+			return
+		}
+		if writes := interceptedWrites(rules); len(writes) != 0 {
+			results = append(results, CheckResult{Check: "webhooks", Status: StatusWarn,
+				Message: fmt.Sprintf("%s webhook %s of %s intercepts %s and fails closed",
+					kind, webhook, config, strings.Join(writes, ", ")),
+				Remediation: fmt.Sprintf("make sure the service of webhook %s is available during the conversion, "+
+					"or exclude kube-system by its namespaceSelector, or set its failurePolicy to Ignore", webhook)})
+		}
+	}
+
+	mutating, err := do.clientSet.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().List(metav1.ListOptions{})
+	if err != nil {
+		return []CheckResult{{Check: "webhooks", Status: StatusWarn, Message: fmt.Sprintf("fail to list mutating webhooks: %s", err)}}
+	}
+	for _, config := range mutating.Items {
+		for _, wh := range config.Webhooks {
+			conflict("mutating", config.Name, wh.Name, wh.Rules, wh.FailurePolicy)
+		}
+	}
+	validating, err := do.clientSet.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations().List(metav1.ListOptions{})
+	if err != nil {
+		return []CheckResult{{Check: "webhooks", Status: StatusWarn, Message: fmt.Sprintf("fail to list validating webhooks: %s", err)}}
+	}
+	for _, config := range validating.Items {
+		for _, wh := range config.Webhooks {
+			conflict("validating", config.Name, wh.Name, wh.Rules, wh.FailurePolicy)
+		}
+	}
+
+	if len(results) == 0 {
+		results = append(results, CheckResult{Check: "webhooks", Status: StatusOK,
+			Message: "no webhook fails closed on the writes of convert"})
+	}
+	return results
+}
+
+// interceptedWrites returns the writes of convert that match the rules
+func interceptedWrites(rules []admissionv1beta1.RuleWithOperations) []string {
+	var writes []string
+	for _, write := range convertWrites {
+		for _, rule := range rules {
+			if matchOperation(rule.Operations, write.operation) && matchAny(rule.APIGroups, write.group) &&
+				matchAny(rule.Resources, write.resource) {
+				writes = append(writes, fmt.Sprintf("%s %s", strings.ToLower(string(write.operation)), write.resource))
+				break
+			}
+		}
+	}
+	return writes
+}
+
+func matchOperation(operations []admissionv1beta1.OperationType, op admissionv1beta1.OperationType) bool {
+	for _, o := range operations {
+		if o == op || o == admissionv1beta1.OperationAll {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAny(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == "*" || v == "*/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTunnel checks that the tunnel server address is reachable from the sample edge node
+func (do *DoctorOptions) checkTunnel() []CheckResult {
+	if do.TunnelServerAddress == "" {
+		return []CheckResult{{Check: "tunnel", Status: StatusSkip,
+			Message: "set --tunnel-server-address and --sample-edge-node to probe the tunnel server from an edge node"}}
+	}
+	if err := do.probeTunnel(do.SampleEdgeNode, do.TunnelServerAddress); err != nil {
+		return []CheckResult{{Check: "tunnel", Status: StatusFail,
+			Message: fmt.Sprintf("%s is not reachable from node %s: %s", do.TunnelServerAddress, do.SampleEdgeNode, err),
+			Remediation: fmt.Sprintf("allow the edge network to connect %s(by firewall rules or security groups), "+
+				"or expose the tunnel server on an address that is reachable from the edge", do.TunnelServerAddress)}}
+	}
+	return []CheckResult{{Check: "tunnel", Status: StatusOK,
+		Message: fmt.Sprintf("%s is reachable from node %s", do.TunnelServerAddress, do.SampleEdgeNode)}}
+}
+
+// runTunnelProbe runs a job with host network on the node, which connects the address by nc,
+// the job is deleted after it's complete.
+func (do *DoctorOptions) runTunnelProbe(nodeName, address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	job := newTunnelProbeJob(nodeName, host, port, do.ProbeImage, do.ProbeTimeout)
+	jobs := do.clientSet.BatchV1().Jobs(job.GetNamespace())
+	if _, err := jobs.Create(job); err != nil {
+		return fmt.Errorf("fail to create job(%s): %s", job.GetName(), err)
+	}
+	defer func() {
+		if err := jobs.Delete(job.GetName(), &metav1.DeleteOptions{
+			PropagationPolicy: &kubeutil.PropagationPolicy,
+		}); err != nil {
+			klog.Errorf("fail to delete job(%s): %s", job.GetName(), err)
+		}
+	}()
+
+	var probeErr error
+	err = wait.PollImmediate(checkProbePeriod, do.ProbeTimeout, func() (bool, error) {
+		current, err := jobs.Get(job.GetName(), metav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("fail to get job(%s) when waiting for it to be complete: %s", job.GetName(), err)
+			return false, nil
+		}
+		if current.Status.Succeeded > 0 {
+			return true, nil
+		}
+		if current.Status.Failed > 0 {
+			probeErr = fmt.Errorf("connection is refused or timed out in %ds", probeConnectTimeout)
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("job(%s) is not complete in %v", job.GetName(), do.ProbeTimeout)
+	}
+	return probeErr
+}
+
+func newTunnelProbeJob(nodeName, host, port, image string, timeout time.Duration) *batchv1.Job {
+	backoffLimit := int32(0)
+	deadline := int64(timeout.Seconds())
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "yurtctl-doctor-tunnel-probe-" + nodeName,
+			Namespace: metav1.NamespaceSystem,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: &deadline,
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					NodeName:      nodeName,
+					HostNetwork:   true,
+					RestartPolicy: v1.RestartPolicyNever,
+					Tolerations:   []v1.Toleration{{Operator: v1.TolerationOpExists}},
+					Containers: []v1.Container{{
+						Name:    "probe",
+						Image:   image,
+						Command: []string{"nc", "-z", "-w", fmt.Sprint(probeConnectTimeout), host, port},
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func newNode(name string, ready bool, labels map[string]string) *v1.Node {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}},
+	}
+}
+
+func newClientSet(deniedResource string, objects ...runtime.Object) *fake.Clientset {
+	clientSet := fake.NewSimpleClientset(objects...)
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = review.Spec.ResourceAttributes.Resource != deniedResource
+		return true, review, nil
+	})
+	clientSet.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}, {Name: "daemonsets"}}},
+		{GroupVersion: "batch/v1", APIResources: []metav1.APIResource{{Name: "jobs"}}},
+		{GroupVersion: "authorization.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "subjectaccessreviews"}}},
+	}
+	return clientSet
+}
+
+func TestRunDoctor(t *testing.T) {
+	fail := admissionv1beta1.Fail
+	ignore := admissionv1beta1.Ignore
+	webhooks := &admissionv1beta1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "policy"},
+		Webhooks: []admissionv1beta1.Webhook{
+			{
+				Name:          "pods.policy.io",
+				FailurePolicy: &fail,
+				Rules: []admissionv1beta1.RuleWithOperations{{
+					Operations: []admissionv1beta1.OperationType{admissionv1beta1.Create},
+					Rule:       admissionv1beta1.Rule{APIGroups: []string{""}, Resources: []string{"pods"}},
+				}},
+			},
+			{
+				Name:          "all.policy.io",
+				FailurePolicy: &ignore,
+				Rules: []admissionv1beta1.RuleWithOperations{{
+					Operations: []admissionv1beta1.OperationType{admissionv1beta1.OperationAll},
+					Rule:       admissionv1beta1.Rule{APIGroups: []string{"*"}, Resources: []string{"*"}},
+				}},
+			},
+		},
+	}
+
+	testcases := map[string]struct {
+		deniedResource string
+		cloudNodes     []string
+		tunnelAddress  string
+		probeErr       error
+		failed         bool
+		expected       []string
+	}{
+		"prerequisites are met": {
+			cloudNodes:    []string{"cloud"},
+			tunnelAddress: "10.0.0.1:10262",
+			expected: []string{
+				"rbac ok the caller has all 14 permissions of convert",
+				"apis warn leases.coordination.k8s.io/v1(NodeLease) is not served",
+				"nodes ok 1 cloud nodes are available",
+				"webhooks warn validating webhook pods.policy.io of policy intercepts create pods and fails closed",
+				"tunnel ok 10.0.0.1:10262 is reachable from node edge",
+				"[apis] enable feature gate NodeLease on kube-apiserver and kubelet by --feature-gates=NodeLease=true",
+			},
+		},
+		"permission denied": {
+			deniedResource: "jobs",
+			cloudNodes:     []string{"cloud"},
+			failed:         true,
+			expected: []string{
+				"rbac fail the caller can not create jobs.batch in kube-system, get jobs.batch in kube-system, delete jobs.batch in kube-system",
+				"tunnel skip",
+			},
+		},
+		"cloud nodes are unavailable": {
+			cloudNodes: []string{"not-ready", "missing"},
+			failed:     true,
+			expected: []string{
+				"nodes warn cloud node not-ready is not ready",
+				"nodes fail cloud node missing is not found",
+				"nodes fail no cloud node is available for yurt-controller-manager",
+				"nodes warn node edge is labeled alibabacloud.com/is-edge-worker=false",
+			},
+		},
+		"tunnel is unreachable": {
+			cloudNodes:    []string{"cloud"},
+			tunnelAddress: "10.0.0.1:10262",
+			probeErr:      errors.New("connection is refused"),
+			failed:        true,
+			expected: []string{
+				"tunnel fail 10.0.0.1:10262 is not reachable from node edge: connection is refused",
+				"[tunnel] allow the edge network to connect 10.0.0.1:10262",
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			edgeLabels := map[string]string{}
+			if k == "cloud nodes are unavailable" {
+				edgeLabels[constants.LabelEdgeWorker] = "false"
+			}
+			out := &bytes.Buffer{}
+			do := &DoctorOptions{
+				clientSet: newClientSet(tt.deniedResource,
+					newNode("cloud", true, nil),
+					newNode("not-ready", false, nil),
+					newNode("edge", true, edgeLabels),
+					webhooks),
+				CloudNodes:          tt.cloudNodes,
+				TunnelServerAddress: tt.tunnelAddress,
+				SampleEdgeNode:      "edge",
+				out:                 out,
+				probeTunnel: func(nodeName, address string) error {
+					return tt.probeErr
+				},
+			}
+
+			err := do.RunDoctor()
+			if (err != nil) != tt.failed {
+				t.Errorf("expect failed %v, but got %v", tt.failed, err)
+			}
+			// columns are compared without their padding
+			var lines []string
+			for _, line := range strings.Split(out.String(), "\n") {
+				lines = append(lines, strings.Join(strings.Fields(line), " "))
+			}
+			output := strings.Join(lines, "\n")
+			for _, line := range tt.expected {
+				if !strings.Contains(output, line) {
+					t.Errorf("expect %q in output:\n%s", line, out.String())
+				}
+			}
+			if strings.Contains(out.String(), "all.policy.io") {
+				t.Errorf("webhook that fails open is reported:\n%s", out.String())
+			}
+		})
+	}
+}
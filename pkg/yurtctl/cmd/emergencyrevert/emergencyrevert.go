@@ -0,0 +1,212 @@
+package emergencyrevert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+const (
+	// hubServer is the server in the kubeconfig of kubelet that connects through yurt-hub
+	hubServer = "127.0.0.1:10261"
+	// watchdogService is the service installed by convert with --enable-hub-watchdog
+	watchdogService = "yurthub-watchdog.service"
+	// backupDirName is the directory in the openyurt dir that the changed files are backed up to
+	backupDirName = "emergency-revert-backup"
+)
+
+// kubeconfigArgRegexp matches the --kubeconfig argument of kubelet in the service drop-in
+var kubeconfigArgRegexp = regexp.MustCompile(`--kubeconfig=[^\s"']*`)
+
+// EmergencyRevertOptions has the information that required by emergency-revert operation,
+// the paths are the same as the ones used by the servant jobs.
+type EmergencyRevertOptions struct {
+	KubeletConf   string
+	KubeletSvc    string
+	OpenYurtDir   string
+	StaticPodPath string
+	WatchdogSvc   string
+	KeepYurtHub   bool
+	// runCommand runs the command on the node, it's replaced in tests
+	runCommand func(name string, args ...string) error
+}
+
+// NewEmergencyRevertOptions creates a new EmergencyRevertOptions
+func NewEmergencyRevertOptions() *EmergencyRevertOptions {
+	return &EmergencyRevertOptions{
+		runCommand: runCommand,
+	}
+}
+
+// NewEmergencyRevertCmd generates a new emergency-revert command
+func NewEmergencyRevertCmd() *cobra.Command {
+	eo := NewEmergencyRevertOptions()
+	cmd := &cobra.Command{
+		Use:   "emergency-revert",
+		Short: "Reverts the kubelet on this node to connect kube-apiserver directly, without the cloud",
+		Long: "Reverts the kubelet on this node to connect kube-apiserver directly, for recovery when the " +
+			"conversion broke the connectivity of the node and the servant jobs can not run on it. It runs " +
+			"on the edge node as root(e.g. over SSH), and doesn't access the cluster: the yurthub-watchdog " +
+			"service and the yurt-hub pod are removed, and kubelet is restarted with its original kubeconfig. " +
+			"The changed files are backed up to " + backupDirName + " in the openyurt dir.",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := eo.Complete(cmd.Flags()); err != nil {
+				klog.Fatalf("fail to complete the emergency-revert option: %s", err)
+			}
+			if err := eo.Validate(); err != nil {
+				klog.Fatalf("emergency-revert option is invalid: %s", err)
+			}
+			if err := eo.RunEmergencyRevert(); err != nil {
+				klog.Fatalf("fail to revert the node: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("kubelet-conf", "/etc/kubernetes/kubelet.conf",
+		"The original kubeconfig of kubelet that connects kube-apiserver directly.")
+	cmd.Flags().String("kubelet-svc", "/etc/systemd/system/kubelet.service.d/10-kubeadm.conf",
+		"The drop-in of kubelet service that sets --kubeconfig of kubelet.")
+	cmd.Flags().String("openyurt-dir", "/var/lib/openyurt",
+		"The working dir of openyurt on the node.")
+	cmd.Flags().String("static-pod-path", "/etc/kubernetes/manifests",
+		"The path of static pod manifests of kubelet.")
+	cmd.Flags().String("watchdog-svc", "/etc/systemd/system/"+watchdogService,
+		"The unit file of yurthub-watchdog service.")
+	cmd.Flags().Bool("keep-yurthub", false,
+		"Keep the yurt-hub pod running on the node, e.g. for debugging it.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (eo *EmergencyRevertOptions) Complete(flags *pflag.FlagSet) error {
+	var err error
+	for flag, val := range map[string]*string{
+		"kubelet-conf":    &eo.KubeletConf,
+		"kubelet-svc":     &eo.KubeletSvc,
+		"openyurt-dir":    &eo.OpenYurtDir,
+		"static-pod-path": &eo.StaticPodPath,
+		"watchdog-svc":    &eo.WatchdogSvc,
+	} {
+		if *val, err = flags.GetString(flag); err != nil {
+			return err
+		}
+	}
+
+	eo.KeepYurtHub, err = flags.GetBool("keep-yurthub")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate makes sure the original kubeconfig of kubelet can be reverted to
+func (eo *EmergencyRevertOptions) Validate() error {
+	content, err := ioutil.ReadFile(eo.KubeletConf)
+	if err != nil {
+		return fmt.Errorf("fail to read the original kubeconfig of kubelet: %s", err)
+	}
+	if strings.Contains(string(content), hubServer) {
+		return fmt.Errorf("%s connects yurt-hub, it's not the original kubeconfig of kubelet", eo.KubeletConf)
+	}
+	if !strings.Contains(string(content), "server:") {
+		return fmt.Errorf("no server is found in %s", eo.KubeletConf)
+	}
+
+	svc, err := ioutil.ReadFile(eo.KubeletSvc)
+	if err != nil {
+		return fmt.Errorf("fail to read the drop-in of kubelet service: %s", err)
+	}
+	if !kubeconfigArgRegexp.Match(svc) {
+		return fmt.Errorf("--kubeconfig is not found in %s", eo.KubeletSvc)
+	}
+	return nil
+}
+
+// RunEmergencyRevert reverts the kubelet on the node to connect kube-apiserver directly
+func (eo *EmergencyRevertOptions) RunEmergencyRevert() error {
+	backupDir := filepath.Join(eo.OpenYurtDir, backupDirName)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return err
+	}
+
+	// 1. stop the watchdog, so it doesn't switch kubelet back to yurt-hub
+	if _, err := os.Stat(eo.WatchdogSvc); err == nil {
+		if err := eo.runCommand("systemctl", "disable", "--now", watchdogService); err != nil {
+			klog.Warningf("fail to stop %s: %s", watchdogService, err)
+		}
+		if err := moveFile(eo.WatchdogSvc, backupDir); err != nil {
+			return err
+		}
+		if err := moveFile(filepath.Join(eo.OpenYurtDir, "yurthub-watchdog"), backupDir); err != nil {
+			return err
+		}
+		klog.Infof("%s is removed", watchdogService)
+	}
+
+	// 2. revise the kubelet.service drop-in to the original kubeconfig
+	svc, err := ioutil.ReadFile(eo.KubeletSvc)
+	if err != nil {
+		return err
+	}
+	revised := kubeconfigArgRegexp.ReplaceAllLiteral(svc, []byte("--kubeconfig="+eo.KubeletConf))
+	if string(revised) != string(svc) {
+		if err := ioutil.WriteFile(filepath.Join(backupDir, filepath.Base(eo.KubeletSvc)), svc, 0600); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(eo.KubeletSvc, revised, 0644); err != nil {
+			return err
+		}
+		klog.Infof("--kubeconfig of kubelet is revised to %s in %s", eo.KubeletConf, eo.KubeletSvc)
+	}
+
+	// 3. remove the yurt-hub pod and the kubeconfig that connects yurt-hub
+	if !eo.KeepYurtHub {
+		if err := moveFile(filepath.Join(eo.StaticPodPath, "yurt-hub.yaml"), backupDir); err != nil {
+			return err
+		}
+		klog.Info("yurt-hub pod is removed")
+	}
+	if err := moveFile(filepath.Join(eo.OpenYurtDir, "kubelet.conf"), backupDir); err != nil {
+		return err
+	}
+
+	// 4. restart kubelet
+	if err := eo.runCommand("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if err := eo.runCommand("systemctl", "restart", "kubelet.service"); err != nil {
+		return err
+	}
+	klog.Infof("kubelet is restarted with %s, the changed files are backed up to %s", eo.KubeletConf, backupDir)
+	klog.Infof("the node is still labeled with %s=true, run `yurtctl revert` or convert the node again "+
+		"after the cloud is reachable", constants.LabelEdgeWorker)
+	return nil
+}
+
+// moveFile moves the file into dir, it's ignored if the file doesn't exist
+func moveFile(file, dir string) error {
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		return nil
+	}
+	return os.Rename(file, filepath.Join(dir, filepath.Base(file)))
+}
+
+func runCommand(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return errors.New(strings.TrimSpace(fmt.Sprintf("%s %s: %s %s", name, strings.Join(args, " "), err, out)))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package emergencyrevert
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	testKubeletConf = `apiVersion: v1
+clusters:
+- cluster:
+    server: https://10.0.0.1:6443
+`
+	testHubKubeletConf = `apiVersion: v1
+clusters:
+- cluster:
+    server: http://127.0.0.1:10261
+`
+	testKubeletSvc = `[Service]
+Environment="KUBELET_KUBECONFIG_ARGS=--bootstrap-kubeconfig=/etc/kubernetes/bootstrap-kubelet.conf --kubeconfig=%s"
+ExecStart=/usr/bin/kubelet $KUBELET_KUBECONFIG_ARGS
+`
+)
+
+// newTestNode creates the files of a converted node in a temp dir
+func newTestNode(t *testing.T, watchdog bool) (string, *EmergencyRevertOptions) {
+	root, err := ioutil.TempDir("", "emergency-revert")
+	if err != nil {
+		t.Fatalf("fail to create temp dir: %s", err)
+	}
+	eo := &EmergencyRevertOptions{
+		KubeletConf:   filepath.Join(root, "kubelet.conf"),
+		KubeletSvc:    filepath.Join(root, "10-kubeadm.conf"),
+		OpenYurtDir:   filepath.Join(root, "openyurt"),
+		StaticPodPath: filepath.Join(root, "manifests"),
+		WatchdogSvc:   filepath.Join(root, watchdogService),
+	}
+	files := map[string]string{
+		eo.KubeletConf: testKubeletConf,
+		eo.KubeletSvc:  strings.Replace(testKubeletSvc, "%s", filepath.Join(eo.OpenYurtDir, "kubelet.conf"), 1),
+		filepath.Join(eo.OpenYurtDir, "kubelet.conf"):    testHubKubeletConf,
+		filepath.Join(eo.StaticPodPath, "yurt-hub.yaml"): "kind: Pod",
+	}
+	if watchdog {
+		files[eo.WatchdogSvc] = "[Service]"
+		files[filepath.Join(eo.OpenYurtDir, "yurthub-watchdog")] = "#!/usr/bin/env bash"
+	}
+	for file, content := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("fail to create dir: %s", err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("fail to write file: %s", err)
+		}
+	}
+	return root, eo
+}
+
+func exists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
+}
+
+func TestRunEmergencyRevert(t *testing.T) {
+	testcases := map[string]struct {
+		watchdog    bool
+		keepYurtHub bool
+		commands    []string
+	}{
+		"revert node": {
+			commands: []string{"systemctl daemon-reload", "systemctl restart kubelet.service"},
+		},
+		"revert node with watchdog": {
+			watchdog: true,
+			commands: []string{
+				"systemctl disable --now yurthub-watchdog.service",
+				"systemctl daemon-reload",
+				"systemctl restart kubelet.service",
+			},
+		},
+		"keep yurt-hub": {
+			keepYurtHub: true,
+			commands:    []string{"systemctl daemon-reload", "systemctl restart kubelet.service"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			root, eo := newTestNode(t, tt.watchdog)
+			defer os.RemoveAll(root)
+			eo.KeepYurtHub = tt.keepYurtHub
+			var commands []string
+			eo.runCommand = func(name string, args ...string) error {
+				commands = append(commands, strings.Join(append([]string{name}, args...), " "))
+				return nil
+			}
+
+			if err := eo.Validate(); err != nil {
+				t.Fatalf("expect options are valid, but got %v", err)
+			}
+			if err := eo.RunEmergencyRevert(); err != nil {
+				t.Fatalf("fail to revert: %v", err)
+			}
+
+			if !reflect.DeepEqual(commands, tt.commands) {
+				t.Errorf("expect commands %v, but got %v", tt.commands, commands)
+			}
+			svc, _ := ioutil.ReadFile(eo.KubeletSvc)
+			expected := strings.Replace(testKubeletSvc, "%s", eo.KubeletConf, 1)
+			if string(svc) != expected {
+				t.Errorf("expect kubelet drop-in %q, but got %q", expected, string(svc))
+			}
+			backupDir := filepath.Join(eo.OpenYurtDir, backupDirName)
+			if !exists(filepath.Join(backupDir, "10-kubeadm.conf")) {
+				t.Errorf("expect kubelet drop-in is backed up")
+			}
+			if exists(filepath.Join(eo.OpenYurtDir, "kubelet.conf")) {
+				t.Errorf("expect kubeconfig of yurt-hub is removed")
+			}
+			if exists(filepath.Join(eo.StaticPodPath, "yurt-hub.yaml")) == !tt.keepYurtHub {
+				t.Errorf("expect yurt-hub pod is kept %v", tt.keepYurtHub)
+			}
+			if exists(eo.WatchdogSvc) || exists(filepath.Join(eo.OpenYurtDir, "yurthub-watchdog")) {
+				t.Errorf("expect watchdog is removed")
+			}
+
+			// revert again is a no-op except restarting kubelet
+			if err := eo.RunEmergencyRevert(); err != nil {
+				t.Fatalf("fail to revert again: %v", err)
+			}
+			svc, _ = ioutil.ReadFile(eo.KubeletSvc)
+			if string(svc) != expected {
+				t.Errorf("expect kubelet drop-in %q after revert again, but got %q", expected, string(svc))
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	root, eo := newTestNode(t, false)
+	defer os.RemoveAll(root)
+
+	if err := ioutil.WriteFile(eo.KubeletConf, []byte(testHubKubeletConf), 0644); err != nil {
+		t.Fatalf("fail to write file: %s", err)
+	}
+	if err := eo.Validate(); err == nil {
+		t.Errorf("expect kubeconfig that connects yurt-hub is invalid, but got nil")
+	}
+
+	if err := os.Remove(eo.KubeletConf); err != nil {
+		t.Fatalf("fail to remove file: %s", err)
+	}
+	if err := eo.Validate(); err == nil {
+		t.Errorf("expect missing kubeconfig is invalid, but got nil")
+	}
+}
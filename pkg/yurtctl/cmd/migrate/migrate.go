@@ -0,0 +1,171 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	strutil "github.com/alibaba/openyurt/pkg/yurtctl/util/strings"
+)
+
+// MigrateOptions has the information that required by migrate operation
+type MigrateOptions struct {
+	clientSet    *kubernetes.Clientset
+	EdgeNodes    []string
+	Provider     string
+	YurtHubImage string
+	// PrePull pulls the images on the edge nodes before any node is migrated
+	PrePull bool
+}
+
+// NewMigrateOptions creates a new MigrateOptions
+func NewMigrateOptions() *MigrateOptions {
+	return &MigrateOptions{}
+}
+
+// NewMigrateCmd generates a new migrate command
+func NewMigrateCmd() *cobra.Command {
+	mo := NewMigrateOptions()
+	cmd := &cobra.Command{
+		Use:   "migrate --yurthub-image IMAGE",
+		Short: "Migrates the edge nodes of the yurt cluster to another OpenYurt version",
+		Long: "Migrates the edge nodes of the yurt cluster to another OpenYurt version node by node. " +
+			"On each node, the yurt-hub is stopped, its cache is backed up, the yurt-hub manifest is swapped " +
+			"to the new image(which upgrades the cache layout when it starts), and the new yurt-hub is verified. " +
+			"The migration stops at the first failed node, and the failed node is restored to the old yurt-hub.",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := mo.Complete(cmd.Flags()); err != nil {
+				klog.Fatalf("fail to complete the migrate option: %s", err)
+			}
+			if err := mo.Validate(); err != nil {
+				klog.Fatalf("migrate option is invalid: %s", err)
+			}
+			if err := mo.RunMigrate(); err != nil {
+				klog.Fatalf("fail to migrate the yurt cluster: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("edge-nodes", "e", "",
+		"The list of edge nodes to be migrated, all edge nodes will be migrated if not set.(e.g. -e edgenode1,edgenode2)")
+	cmd.Flags().StringP("provider", "p", "ack",
+		"The provider of the original Kubernetes cluster.")
+	cmd.Flags().String("yurthub-image", constants.DefaultYurtHubImage,
+		"The yurt-hub image of the OpenYurt version that migrated to.")
+	cmd.Flags().Bool("prepull", false,
+		"Pull the images on the edge nodes before any node is migrated, so the migration doesn't time out over slow links.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (mo *MigrateOptions) Complete(flags *pflag.FlagSet) error {
+	enStr, err := flags.GetString("edge-nodes")
+	if err != nil {
+		return err
+	}
+	if enStr != "" {
+		mo.EdgeNodes = strings.Split(enStr, ",")
+	}
+
+	mo.Provider, err = flags.GetString("provider")
+	if err != nil {
+		return err
+	}
+
+	mo.YurtHubImage, err = flags.GetString("yurthub-image")
+	if err != nil {
+		return err
+	}
+
+	mo.PrePull, err = flags.GetBool("prepull")
+	if err != nil {
+		return err
+	}
+
+	// parse kubeconfig and generate the clientset
+	mo.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate makes sure provided values for MigrateOptions are valid
+func (mo *MigrateOptions) Validate() error {
+	if mo.Provider != "minikube" && mo.Provider != "ack" {
+		return fmt.Errorf("unknown provider: %s, valid providers are: minikube, ack",
+			mo.Provider)
+	}
+	if mo.YurtHubImage == "" {
+		return errors.New("yurthub image is not specified")
+	}
+	// check the parameters of servant job before any node is migrated
+	params := kubeutil.ServantJobParams{
+		Action:       kubeutil.ServantJobActionMigrate,
+		Provider:     mo.Provider,
+		YurtHubImage: mo.YurtHubImage,
+	}
+	return params.Validate()
+}
+
+// RunMigrate migrates the edge nodes one by one
+func (mo *MigrateOptions) RunMigrate() error {
+	// 1. find out the edge nodes to be migrated
+	nodeLst, err := mo.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var edgeNodeNames []string
+	for _, node := range nodeLst.Items {
+		if node.Labels[constants.LabelEdgeWorker] != "true" {
+			continue
+		}
+		if len(mo.EdgeNodes) != 0 &&
+			!strutil.IsInStringLst(mo.EdgeNodes, node.GetName()) {
+			continue
+		}
+		edgeNodeNames = append(edgeNodeNames, node.GetName())
+	}
+	for _, nodeName := range mo.EdgeNodes {
+		if !strutil.IsInStringLst(edgeNodeNames, nodeName) {
+			return fmt.Errorf("node %s is not an edge node", nodeName)
+		}
+	}
+
+	// 2. pull the images on the edge nodes ahead, the migration stops
+	// before any node is migrated if the images can't be pulled
+	if mo.PrePull && len(edgeNodeNames) != 0 {
+		images := kubeutil.ServantJobImages(mo.YurtHubImage)
+		klog.Infof("pulling images %v on the edge nodes...", images)
+		if err := kubeutil.PrePullImages(mo.clientSet, images, edgeNodeNames,
+			kubeutil.WaitPrePullTimeout, kubeutil.CheckPrePullPeriod); err != nil {
+			return fmt.Errorf("fail to pre-pull images: %s", err)
+		}
+	}
+
+	// 3. migrate the edge nodes one by one, so a broken version
+	// only affects one node
+	for i, nodeName := range edgeNodeNames {
+		klog.Infof("migrating the edge node %s (%d/%d)...", nodeName, i+1, len(edgeNodeNames))
+		if err := kubeutil.RunServantJob(mo.clientSet, kubeutil.ServantJobParams{
+			Action:       kubeutil.ServantJobActionMigrate,
+			Provider:     mo.Provider,
+			YurtHubImage: mo.YurtHubImage,
+		}, nodeName); err != nil {
+			klog.Errorf("fail to migrate the edge node %s, the remaining nodes are not migrated: %s", nodeName, err)
+			return err
+		}
+		klog.Infof("the edge node %s is migrated", nodeName)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package prepull
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	strutil "github.com/alibaba/openyurt/pkg/yurtctl/util/strings"
+)
+
+// PrePullOptions has the information that required by prepull operation
+type PrePullOptions struct {
+	clientSet kubernetes.Interface
+	Nodes     []string
+	Images    []string
+	Timeout   time.Duration
+}
+
+// NewPrePullOptions creates a new PrePullOptions
+func NewPrePullOptions() *PrePullOptions {
+	return &PrePullOptions{}
+}
+
+// NewPrePullCmd generates a new prepull command
+func NewPrePullCmd() *cobra.Command {
+	po := NewPrePullOptions()
+	cmd := &cobra.Command{
+		Use:   "prepull",
+		Short: "Pulls the images needed by convert or migrate on the nodes ahead of time",
+		Long: "Pulls the images needed by convert or migrate on the nodes ahead of time by a daemonset, so the " +
+			"servant jobs don't time out in pulling images over slow links. The progress is printed until the " +
+			"images are pulled on all nodes, and the digests of the pulled images are verified to be the same.",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := po.Complete(cmd.Flags()); err != nil {
+				klog.Fatalf("fail to complete the prepull option: %s", err)
+			}
+			if err := po.Validate(); err != nil {
+				klog.Fatalf("prepull option is invalid: %s", err)
+			}
+			if err := po.RunPrePull(); err != nil {
+				klog.Fatalf("fail to pre-pull images: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("nodes", "n", "",
+		"The list of nodes to pull images on, all edge nodes if not set.(e.g. -n edgenode1,edgenode2)")
+	cmd.Flags().StringSlice("images", kubeutil.ServantJobImages(constants.DefaultYurtHubImage),
+		"The images to pull.")
+	cmd.Flags().Duration("timeout", kubeutil.WaitPrePullTimeout,
+		"The time to wait for the images to be pulled on all nodes.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (po *PrePullOptions) Complete(flags *pflag.FlagSet) error {
+	nStr, err := flags.GetString("nodes")
+	if err != nil {
+		return err
+	}
+	if nStr != "" {
+		po.Nodes = strings.Split(nStr, ",")
+	}
+
+	po.Images, err = flags.GetStringSlice("images")
+	if err != nil {
+		return err
+	}
+
+	po.Timeout, err = flags.GetDuration("timeout")
+	if err != nil {
+		return err
+	}
+
+	// parse kubeconfig and generate the clientset
+	po.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Validate makes sure provided values for PrePullOptions are valid
+func (po *PrePullOptions) Validate() error {
+	if po.Timeout <= 0 {
+		return fmt.Errorf("timeout(%v) must be positive", po.Timeout)
+	}
+	if len(po.Nodes) == 0 {
+		// edge nodes are found when images are pulled
+		return kubeutil.ValidateImages(po.Images)
+	}
+	params := kubeutil.PrePullParams{Images: po.Images, Nodes: po.Nodes}
+	return params.Validate()
+}
+
+// RunPrePull pulls the images on the nodes
+func (po *PrePullOptions) RunPrePull() error {
+	nodes, err := TargetNodes(po.clientSet, po.Nodes)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		klog.Infof("no edge node is found, no image is pulled")
+		return nil
+	}
+
+	klog.Infof("pulling images %v on nodes %v", po.Images, nodes)
+	return kubeutil.PrePullImages(po.clientSet, po.Images, nodes, po.Timeout, kubeutil.CheckPrePullPeriod)
+}
+
+// TargetNodes returns the nodes that exist in the cluster, or all edge nodes if nodes are not specified
+func TargetNodes(cliSet kubernetes.Interface, nodes []string) ([]string, error) {
+	nodeLst, err := cliSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []string
+	for _, node := range nodeLst.Items {
+		if len(nodes) == 0 && node.Labels[constants.LabelEdgeWorker] == "true" {
+			targets = append(targets, node.GetName())
+		} else if strutil.IsInStringLst(nodes, node.GetName()) {
+			targets = append(targets, node.GetName())
+		}
+	}
+	for _, nodeName := range nodes {
+		if !strutil.IsInStringLst(targets, nodeName) {
+			return nil, fmt.Errorf("node %s is not found", nodeName)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
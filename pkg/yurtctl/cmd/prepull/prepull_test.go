@@ -0,0 +1,65 @@
+package prepull
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func newNode(name string, edge bool) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{constants.LabelEdgeWorker: strconv.FormatBool(edge)},
+		},
+	}
+}
+
+func TestTargetNodes(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		newNode("edge-a", true),
+		newNode("edge-b", true),
+		newNode("cloud", false),
+	)
+
+	testcases := map[string]struct {
+		nodes   []string
+		targets []string
+		err     bool
+	}{
+		"all edge nodes": {
+			targets: []string{"edge-a", "edge-b"},
+		},
+		"specified nodes": {
+			nodes:   []string{"edge-b", "cloud"},
+			targets: []string{"cloud", "edge-b"},
+		},
+		"unknown node": {
+			nodes: []string{"edge-c"},
+			err:   true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			targets, err := TargetNodes(clientSet, tt.nodes)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("fail to get target nodes: %s", err)
+			}
+			if !reflect.DeepEqual(targets, tt.targets) {
+				t.Errorf("expect nodes %v, but got %v", tt.targets, targets)
+			}
+		})
+	}
+}
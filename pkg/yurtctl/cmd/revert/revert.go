@@ -1,9 +1,7 @@
 package revert
 
 import (
-	"errors"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -11,8 +9,6 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 	"k8s.io/klog"
 
 	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
@@ -46,31 +42,8 @@ func NewRevertCmd() *cobra.Command {
 
 func (ro *RevertOptions) Complete(flags *pflag.FlagSet) error {
 	// parse kubeconfig and generate the clientset
-	kbCfgPath, err := flags.GetString("kubeconfig")
-	if err != nil {
-		return err
-	}
-
-	if kbCfgPath == "" {
-		if home := homedir.HomeDir(); home != "" {
-			kbCfgPath = filepath.Join(home, ".kube", "config")
-		}
-	}
-
-	if kbCfgPath == "" {
-		kbCfgPath = os.Getenv("KUBECONFIG")
-	}
-
-	if kbCfgPath == "" {
-		return errors.New("either '--kubeconfig', '$HOME/.kube/config' or '$KUBECONFIG' need to be set")
-	}
-
-	restCfg, err := clientcmd.BuildConfigFromFlags("", kbCfgPath)
-	if err != nil {
-		return err
-	}
-
-	ro.clientSet, err = kubernetes.NewForConfig(restCfg)
+	var err error
+	ro.clientSet, err = kubeutil.GenClientSet(flags)
 	if err != nil {
 		return err
 	}
@@ -116,20 +89,26 @@ func (ro *RevertOptions) RunRevert() error {
 			Namespace: "kube-system",
 		},
 	}
+	// the service account is kept if yurt controller manager is not installed by convert
 	if _, err := ro.clientSet.CoreV1().
-		ServiceAccounts(ncSa.GetNamespace()).Create(ncSa); err != nil {
+		ServiceAccounts(ncSa.GetNamespace()).Create(ncSa); err != nil && !apierrors.IsAlreadyExists(err) {
 		klog.Errorf("fail to create node-controller service account: %s", err)
 		return err
 	}
 	klog.Info("ServiceAccount node-controller is created")
 
 	// 4. remove yurt-hub and revert kubelet service
-	if err := kubeutil.RunServantJobs(ro.clientSet,
-		map[string]string{"action": "revert"},
-		edgeNodeNames); err != nil {
+	results, err := kubeutil.RunServantJobs(ro.clientSet,
+		kubeutil.ServantJobParams{Action: kubeutil.ServantJobActionRevert},
+		edgeNodeNames)
+	if err != nil {
 		klog.Errorf("fail to revert edge node: %s", err)
 		return err
 	}
+	kubeutil.PrintServantJobResults(os.Stdout, results)
+	if err := kubeutil.ServantJobsError(results); err != nil {
+		return err
+	}
 	klog.Info("yurt-hub is removed, kubelet service is reset")
 
 	return nil
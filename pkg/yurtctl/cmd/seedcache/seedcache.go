@@ -0,0 +1,293 @@
+package seedcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+)
+
+// SeedCacheOptions has the information that required by seed-cache operation
+type SeedCacheOptions struct {
+	clientSet      kubernetes.Interface
+	NodeName       string
+	Component      string
+	Output         string
+	IncludeSecrets bool
+	out            io.Writer
+}
+
+// NewSeedCacheOptions creates a new SeedCacheOptions
+func NewSeedCacheOptions() *SeedCacheOptions {
+	return &SeedCacheOptions{}
+}
+
+// NewSeedCacheCmd generates a new seed-cache command
+func NewSeedCacheCmd() *cobra.Command {
+	so := NewSeedCacheOptions()
+	cmd := &cobra.Command{
+		Use:   "seed-cache NODE [-o FILE]",
+		Short: "Generates the seed of yurthub cache for a node from the cluster",
+		Long: "Generates the seed of yurthub cache for a node from the cluster, which is a gzipped tarball of the " +
+			"node, the pods on the node, the configmaps and secrets used by the pods, and the services, in the " +
+			"layout of yurthub cache. Provision it with the node and start yurthub with --seed-cache-from, so the " +
+			"pods can be started before the node is connected to cloud.",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := so.Complete(cmd.Flags(), args, cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the seed-cache option: %s", err)
+			}
+			if err := so.RunSeedCache(); err != nil {
+				klog.Fatalf("fail to seed cache: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "",
+		"The path of the tarball, defaults to cache-seed-<NODE>.tar.gz in the current dir")
+	cmd.Flags().String("component", "kubelet",
+		"The component that the objects are cached for in yurthub")
+	cmd.Flags().Bool("include-secrets", true,
+		"Include the secrets used by the pods, the tarball must be kept as secret as them")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (so *SeedCacheOptions) Complete(flags *pflag.FlagSet, args []string, out io.Writer) error {
+	so.NodeName = args[0]
+	var err error
+	if so.Output, err = flags.GetString("output"); err != nil {
+		return err
+	}
+	if so.Output == "" {
+		so.Output = fmt.Sprintf("cache-seed-%s.tar.gz", so.NodeName)
+	}
+	if so.Component, err = flags.GetString("component"); err != nil {
+		return err
+	}
+	if so.Component == "" {
+		return fmt.Errorf("component can not be empty")
+	}
+	if so.IncludeSecrets, err = flags.GetBool("include-secrets"); err != nil {
+		return err
+	}
+	so.out = out
+
+	so.clientSet, err = kubeutil.GenClientSet(flags)
+	return err
+}
+
+// RunSeedCache writes the seed of cache to the output file
+func (so *SeedCacheOptions) RunSeedCache() error {
+	f, err := os.OpenFile(so.Output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	n, err := so.write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(so.Output)
+		return err
+	}
+
+	fmt.Fprintf(so.out, "%d objects of node %s are written to %s\n", n, so.NodeName, so.Output)
+	return nil
+}
+
+// write writes the objects of node as the gzipped tarball of yurthub cache to w
+func (so *SeedCacheOptions) write(w io.Writer) (int, error) {
+	objs, err := so.objects()
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	store, err := memory.NewMemoryStorage(0)
+	if err != nil {
+		return 0, err
+	}
+	encoder := scheme.Codecs.LegacyCodec(v1.SchemeGroupVersion)
+	for key, obj := range objs {
+		var buf bytes.Buffer
+		if err := encoder.Encode(obj, &buf); err != nil {
+			return 0, fmt.Errorf("fail to encode %s: %s", key, err)
+		}
+		if err := store.Create(ctx, key, buf.Bytes()); err != nil {
+			return 0, fmt.Errorf("fail to add %s: %s", key, err)
+		}
+	}
+
+	return storage.Export(ctx, store, w)
+}
+
+// objects returns the objects that are cached for the node by their keys in yurthub cache
+func (so *SeedCacheOptions) objects() (map[string]runtime.Object, error) {
+	objs := map[string]runtime.Object{}
+	node, err := so.clientSet.CoreV1().Nodes().Get(so.NodeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		klog.Warningf("node %s is not found, only the objects of its pods are seeded", so.NodeName)
+	} else if err != nil {
+		return nil, fmt.Errorf("fail to get node %s: %s", so.NodeName, err)
+	} else {
+		objs[so.key("nodes", "", node.Name)] = node
+	}
+
+	podLst, err := so.clientSet.CoreV1().Pods("").List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", so.NodeName).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fail to list pods of node %s: %s", so.NodeName, err)
+	}
+	configMaps, secrets := map[string]bool{}, map[string]bool{}
+	for i := range podLst.Items {
+		pod := &podLst.Items[i]
+		if pod.Spec.NodeName != so.NodeName {
+			continue
+		}
+		objs[so.key("pods", pod.Namespace, pod.Name)] = pod
+		for _, name := range referencedConfigMaps(pod) {
+			configMaps[path.Join(pod.Namespace, name)] = true
+		}
+		for _, name := range referencedSecrets(pod) {
+			secrets[path.Join(pod.Namespace, name)] = true
+		}
+	}
+
+	for _, nsName := range sortedKeys(configMaps) {
+		ns, name := path.Split(nsName)
+		cm, err := so.clientSet.CoreV1().ConfigMaps(path.Clean(ns)).Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			klog.Warningf("configmap %s is not found, skip it", nsName)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("fail to get configmap %s: %s", nsName, err)
+		}
+		objs[so.key("configmaps", cm.Namespace, cm.Name)] = cm
+	}
+	if so.IncludeSecrets {
+		for _, nsName := range sortedKeys(secrets) {
+			ns, name := path.Split(nsName)
+			secret, err := so.clientSet.CoreV1().Secrets(path.Clean(ns)).Get(name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				klog.Warningf("secret %s is not found, skip it", nsName)
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("fail to get secret %s: %s", nsName, err)
+			}
+			objs[so.key("secrets", secret.Namespace, secret.Name)] = secret
+		}
+	}
+
+	// kubelet sets the environment variables of services in containers
+	svcLst, err := so.clientSet.CoreV1().Services("").List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("fail to list services: %s", err)
+	}
+	for i := range svcLst.Items {
+		svc := &svcLst.Items[i]
+		objs[so.key("services", svc.Namespace, svc.Name)] = svc
+	}
+	return objs, nil
+}
+
+// key is the key of object in yurthub cache, like kubelet/pods/default/pod1
+func (so *SeedCacheOptions) key(resource, ns, name string) string {
+	return path.Join(so.Component, resource, ns, name)
+}
+
+// referencedConfigMaps returns the names of configmaps that pod uses in volumes and env
+func referencedConfigMaps(pod *v1.Pod) []string {
+	var names []string
+	for _, vol := range pod.Spec.Volumes {
+		if vol.ConfigMap != nil {
+			names = append(names, vol.ConfigMap.Name)
+		}
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.ConfigMap != nil {
+					names = append(names, source.ConfigMap.Name)
+				}
+			}
+		}
+	}
+	for _, c := range allContainers(pod) {
+		for _, env := range c.EnvFrom {
+			if env.ConfigMapRef != nil {
+				names = append(names, env.ConfigMapRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				names = append(names, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+		}
+	}
+	return names
+}
+
+// referencedSecrets returns the names of secrets that pod uses in volumes, env and image pulls
+func referencedSecrets(pod *v1.Pod) []string {
+	var names []string
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		names = append(names, ref.Name)
+	}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.Secret != nil {
+			names = append(names, vol.Secret.SecretName)
+		}
+		if vol.Projected != nil {
+			for _, source := range vol.Projected.Sources {
+				if source.Secret != nil {
+					names = append(names, source.Secret.Name)
+				}
+			}
+		}
+	}
+	for _, c := range allContainers(pod) {
+		for _, env := range c.EnvFrom {
+			if env.SecretRef != nil {
+				names = append(names, env.SecretRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				names = append(names, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	return names
+}
+
+func allContainers(pod *v1.Pod) []v1.Container {
+	return append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
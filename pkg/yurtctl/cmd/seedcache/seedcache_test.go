@@ -0,0 +1,101 @@
+package seedcache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+)
+
+func TestWrite(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1"},
+		Spec: v1.PodSpec{
+			NodeName:         "node1",
+			ImagePullSecrets: []v1.LocalObjectReference{{Name: "registry"}},
+			Volumes: []v1.Volume{
+				{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "cm1"}}}},
+				{Name: "missing", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "cm3"}}}},
+			},
+			Containers: []v1.Container{{
+				Name:    "app",
+				EnvFrom: []v1.EnvFromSource{{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "token"}}}},
+			}},
+		},
+	}
+	objs := []runtime.Object{
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+		&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node2"}},
+		pod,
+		&v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod2"}, Spec: v1.PodSpec{NodeName: "node2"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm1"}},
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cm2"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "registry"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "token"}},
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "kubernetes"}},
+	}
+
+	testcases := map[string]struct {
+		includeSecrets bool
+		keys           []string
+	}{
+		"with secrets": {
+			includeSecrets: true,
+			keys: []string{"kubelet/configmaps/default/cm1", "kubelet/nodes/node1", "kubelet/pods/default/pod1",
+				"kubelet/secrets/default/registry", "kubelet/secrets/default/token", "kubelet/services/default/kubernetes"},
+		},
+		"without secrets": {
+			keys: []string{"kubelet/configmaps/default/cm1", "kubelet/nodes/node1", "kubelet/pods/default/pod1",
+				"kubelet/services/default/kubernetes"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			so := &SeedCacheOptions{
+				clientSet:      fake.NewSimpleClientset(objs...),
+				NodeName:       "node1",
+				Component:      "kubelet",
+				IncludeSecrets: tt.includeSecrets,
+			}
+			var buf bytes.Buffer
+			n, err := so.write(&buf)
+			if err != nil || n != len(tt.keys) {
+				t.Fatalf("expect %d objects, but got %d, %v", len(tt.keys), n, err)
+			}
+
+			ctx := context.Background()
+			store, _ := memory.NewMemoryStorage(0)
+			if _, err := storage.Import(ctx, store, &buf, false); err != nil {
+				t.Fatalf("failed to import seed, %v", err)
+			}
+			keys, _ := store.ListKeys(ctx, "")
+			if len(keys) != len(tt.keys) {
+				t.Fatalf("expect keys %v, but got %v", tt.keys, keys)
+			}
+			for i := range keys {
+				if keys[i] != tt.keys[i] {
+					t.Errorf("expect keys %v, but got %v", tt.keys, keys)
+					break
+				}
+			}
+
+			b, _ := store.Get(ctx, "kubelet/pods/default/pod1")
+			obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(b, nil, nil)
+			if err != nil {
+				t.Fatalf("failed to decode pod, %v", err)
+			}
+			if p, ok := obj.(*v1.Pod); !ok || p.Name != "pod1" || p.Kind != "Pod" {
+				t.Errorf("expect pod1 with its kind, but got %#v", obj)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+// readinessSummary is the summary of autonomy readiness that reported by yurthub
+type readinessSummary struct {
+	Ready    bool     `json:"ready"`
+	Score    int      `json:"score"`
+	Warnings []string `json:"warnings,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// StatusOptions has the information that required by status operation
+type StatusOptions struct {
+	clientSet    kubernetes.Interface
+	notReadyOnly bool
+	out          io.Writer
+}
+
+// NewStatusOptions creates a new StatusOptions
+func NewStatusOptions() *StatusOptions {
+	return &StatusOptions{}
+}
+
+// NewStatusCmd generates a new status command
+func NewStatusCmd() *cobra.Command {
+	so := NewStatusOptions()
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Shows the edge status, autonomy readiness and maintenance of nodes",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := so.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the status option: %s", err)
+			}
+			if err := so.RunStatus(); err != nil {
+				klog.Fatalf("fail to show status: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().Bool("not-ready-only", false,
+		"Only show the edge nodes that are not ready for autonomy.")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (so *StatusOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	so.clientSet, err = kubeutil.GenClientSet(flags)
+	if err != nil {
+		return err
+	}
+
+	so.notReadyOnly, err = flags.GetBool("not-ready-only")
+	if err != nil {
+		return err
+	}
+	so.out = out
+	return nil
+}
+
+// RunStatus prints the edge status, autonomy readiness and maintenance of nodes, the
+// autonomy readiness is reported by yurthub on the edge nodes.
+func (so *StatusOptions) RunStatus() error {
+	nodes, err := so.clientSet.CoreV1().Nodes().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+
+	w := tabwriter.NewWriter(so.out, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tEDGE\tAUTONOMY\tREADY-FOR-AUTONOMY\tSCORE\tISSUES\tMAINTENANCE")
+	now := time.Now()
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		edge := node.Labels[constants.LabelEdgeWorker] == "true"
+		autonomy := node.Annotations[constants.AnnotationAutonomy] == "true"
+		ready, score, issues := readinessOf(node)
+		if so.notReadyOnly && (!edge || ready == "true") {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%v\t%v\t%s\t%s\t%s\t%s\n", node.Name, edge, autonomy, ready, score, issues, maintenanceOf(node, now))
+	}
+
+	return w.Flush()
+}
+
+// readinessOf returns the columns of autonomy readiness of node, "<unknown>"
+// is returned if autonomy readiness is not reported.
+func readinessOf(node *v1.Node) (ready, score, issues string) {
+	value, ok := node.Annotations[constants.AnnotationAutonomyReadiness]
+	if !ok {
+		return "<unknown>", "<unknown>", "<none>"
+	}
+
+	var summary readinessSummary
+	if err := json.Unmarshal([]byte(value), &summary); err != nil {
+		klog.Warningf("autonomy readiness(%s) of node %s is invalid, %v", value, node.Name, err)
+		return "<unknown>", "<unknown>", "<none>"
+	}
+
+	var parts []string
+	for _, name := range summary.Failures {
+		parts = append(parts, name+"(fail)")
+	}
+	for _, name := range summary.Warnings {
+		parts = append(parts, name+"(warn)")
+	}
+	issues = "<none>"
+	if len(parts) != 0 {
+		issues = strings.Join(parts, ",")
+	}
+
+	return strconv.FormatBool(summary.Ready), strconv.Itoa(summary.Score), issues
+}
+
+// maintenanceOf returns the column of maintenance of node, "<none>" is returned
+// if the node pool of node is not in maintenance at now.
+func maintenanceOf(node *v1.Node, now time.Time) string {
+	reason := node.Annotations[constants.AnnotationMaintenance]
+	if len(reason) == 0 {
+		return "<none>"
+	}
+
+	value, ok := node.Annotations[constants.AnnotationMaintenanceUntil]
+	if !ok {
+		return reason
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		klog.Warningf("maintenance until(%s) of node %s is invalid, %v", value, node.Name, err)
+		return reason
+	}
+	if !now.Before(until) {
+		return "<none>"
+	}
+	return fmt.Sprintf("%s(until %s)", reason, value)
+}
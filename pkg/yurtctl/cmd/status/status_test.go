@@ -0,0 +1,120 @@
+package status
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func newNode(name string, edge bool, readiness string) *v1.Node {
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{constants.LabelEdgeWorker: "false"},
+			Annotations: map[string]string{},
+		},
+	}
+	if edge {
+		node.Labels[constants.LabelEdgeWorker] = "true"
+		node.Annotations[constants.AnnotationAutonomy] = "true"
+	}
+	if readiness != "" {
+		node.Annotations[constants.AnnotationAutonomyReadiness] = readiness
+	}
+	return node
+}
+
+func TestRunStatus(t *testing.T) {
+	inMaintenance := newNode("edge-b", true, `{"ready":false,"score":45,"warnings":["clock"],"failures":["cache","disk"]}`)
+	inMaintenance.Annotations[constants.AnnotationMaintenance] = "wan-upgrade"
+	clientSet := fake.NewSimpleClientset(
+		inMaintenance,
+		newNode("edge-a", true, `{"ready":true,"score":100}`),
+		newNode("edge-c", true, ""),
+		newNode("cloud", false, ""),
+	)
+
+	testcases := map[string]struct {
+		notReadyOnly bool
+		expected     []string
+	}{
+		"all nodes": {
+			expected: []string{
+				"NAME     EDGE    AUTONOMY   READY-FOR-AUTONOMY   SCORE       ISSUES                               MAINTENANCE",
+				"cloud    false   false      <unknown>            <unknown>   <none>                               <none>",
+				"edge-a   true    true       true                 100         <none>                               <none>",
+				"edge-b   true    true       false                45          cache(fail),disk(fail),clock(warn)   wan-upgrade",
+				"edge-c   true    true       <unknown>            <unknown>   <none>                               <none>",
+			},
+		},
+		"not ready only": {
+			notReadyOnly: true,
+			expected: []string{
+				"NAME     EDGE   AUTONOMY   READY-FOR-AUTONOMY   SCORE       ISSUES                               MAINTENANCE",
+				"edge-b   true   true       false                45          cache(fail),disk(fail),clock(warn)   wan-upgrade",
+				"edge-c   true   true       <unknown>            <unknown>   <none>                               <none>",
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			var out bytes.Buffer
+			so := &StatusOptions{clientSet: clientSet, notReadyOnly: tt.notReadyOnly, out: &out}
+			if err := so.RunStatus(); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			// columns are compared without the padding of tabwriter
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("expect %d lines, but got output:\n%s", len(tt.expected), out.String())
+			}
+			for i := range lines {
+				if strings.Join(strings.Fields(lines[i]), " ") != strings.Join(strings.Fields(tt.expected[i]), " ") {
+					t.Errorf("expect line %q, but got %q", tt.expected[i], lines[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenanceOf(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		annotations map[string]string
+		expected    string
+	}{
+		"no maintenance": {
+			expected: "<none>",
+		},
+		"without until": {
+			annotations: map[string]string{constants.AnnotationMaintenance: "wan"},
+			expected:    "wan",
+		},
+		"before until": {
+			annotations: map[string]string{constants.AnnotationMaintenance: "wan", constants.AnnotationMaintenanceUntil: "2020-10-01T13:00:00Z"},
+			expected:    "wan(until 2020-10-01T13:00:00Z)",
+		},
+		"after until": {
+			annotations: map[string]string{constants.AnnotationMaintenance: "wan", constants.AnnotationMaintenanceUntil: "2020-10-01T11:00:00Z"},
+			expected:    "<none>",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Annotations: tt.annotations}}
+			if got := maintenanceOf(node, now); got != tt.expected {
+				t.Errorf("expect maintenance %q, but got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package systemdunit
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+	"github.com/alibaba/openyurt/pkg/yurtctl/util/templates"
+)
+
+const (
+	// ComponentYurtHub is the yurt-hub that is run as a systemd service
+	ComponentYurtHub = "hub"
+	// ComponentTunnel is the tunnel agent, it's not part of this version
+	ComponentTunnel = "tunnel"
+
+	// DefaultHubBinary is the path of yurthub binary on edge nodes
+	DefaultHubBinary = "/usr/local/bin/yurthub"
+	// DefaultWatchdogSec is the timeout of systemd watchdog of yurt-hub
+	DefaultWatchdogSec = 60
+)
+
+// nodeNameSpecifiers are the specifiers of systemd that are expanded to the hostname
+var nodeNameSpecifiers = map[string]bool{"%H": true, "%l": true}
+
+// hubUnitTemplate is the systemd service of yurt-hub, it's started before kubelet and
+// notifies systemd when it's ready, the logs are written to the journal.
+const hubUnitTemplate = `[Unit]
+Description=yurt-hub of OpenYurt
+Documentation=https://github.com/alibaba/openyurt
+Wants=network-online.target
+After=network-online.target
+Before=kubelet.service
+
+[Service]
+Type=notify
+NotifyAccess=main
+{{- if .WatchdogSec }}
+WatchdogSec={{ .WatchdogSec }}
+{{- end }}
+{{- if .HTTPSProxy }}
+Environment="HTTPS_PROXY={{ .HTTPSProxy }}"
+{{- end }}
+{{- if .NoProxy }}
+Environment="NO_PROXY={{ .NoProxy }}"
+{{- end }}
+ExecStart={{ .Binary }} --v=2 --server-addr={{ .ServerAddr }} --node-name={{ .NodeName }}
+Restart=always
+RestartSec=10
+StandardOutput=journal
+StandardError=journal
+SyslogIdentifier=yurthub
+
+[Install]
+WantedBy=multi-user.target
+`
+
+// SystemdUnitOptions has the information that required by systemd-unit operation
+type SystemdUnitOptions struct {
+	Component   string
+	ServerAddr  string
+	NodeName    string
+	Binary      string
+	HTTPSProxy  string
+	NoProxy     string
+	WatchdogSec int
+	Output      string
+	out         io.Writer
+}
+
+// NewSystemdUnitOptions creates a new SystemdUnitOptions
+func NewSystemdUnitOptions() *SystemdUnitOptions {
+	return &SystemdUnitOptions{}
+}
+
+// NewSystemdUnitCmd generates a new systemd-unit command
+func NewSystemdUnitCmd() *cobra.Command {
+	so := NewSystemdUnitOptions()
+	cmd := &cobra.Command{
+		Use:   "systemd-unit --server-addr=ADDR [-o FILE]",
+		Short: "Generates the systemd unit file of yurthub for edge nodes",
+		Long: "Generates the systemd unit file of yurthub for edge nodes that run yurthub as a systemd service " +
+			"instead of a static pod, like minimal hosts where static pods are not desirable before kubelet is " +
+			"reconfigured. The service notifies systemd when yurthub is ready, so kubelet is started after it, " +
+			"and the logs of yurthub are written to the journal.",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := so.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the systemd-unit option: %s", err)
+			}
+			if err := so.Validate(); err != nil {
+				klog.Fatalf("systemd-unit option is invalid: %s", err)
+			}
+			if err := so.RunSystemdUnit(); err != nil {
+				klog.Fatalf("fail to generate systemd unit: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("component", ComponentYurtHub,
+		"The component that is run as a systemd service, only hub is supported now")
+	cmd.Flags().String("server-addr", "",
+		"The address of kube-apiserver that yurthub connects to, like https://10.0.0.1:6443")
+	cmd.Flags().String("node-name", "%H",
+		"The name of the node, defaults to the hostname that is expanded by systemd")
+	cmd.Flags().String("binary", DefaultHubBinary,
+		"The path of yurthub binary on the node")
+	cmd.Flags().String("edge-https-proxy", "",
+		"The https proxy(like http://10.0.0.1:3128) that yurthub connects to the apiserver through")
+	cmd.Flags().String("edge-no-proxy", "",
+		"The addresses that yurthub connects to directly when --edge-https-proxy is set")
+	cmd.Flags().Int("watchdog-sec", DefaultWatchdogSec,
+		"The seconds that systemd restarts yurthub after its healthz stops working, 0 disables the watchdog")
+	cmd.Flags().StringP("output", "o", "",
+		"The path of the unit file(like /etc/systemd/system/yurthub.service), defaults to stdout")
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (so *SystemdUnitOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	if so.Component, err = flags.GetString("component"); err != nil {
+		return err
+	}
+	if so.ServerAddr, err = flags.GetString("server-addr"); err != nil {
+		return err
+	}
+	if so.NodeName, err = flags.GetString("node-name"); err != nil {
+		return err
+	}
+	if so.Binary, err = flags.GetString("binary"); err != nil {
+		return err
+	}
+	if so.HTTPSProxy, err = flags.GetString("edge-https-proxy"); err != nil {
+		return err
+	}
+	if so.NoProxy, err = flags.GetString("edge-no-proxy"); err != nil {
+		return err
+	}
+	if so.WatchdogSec, err = flags.GetInt("watchdog-sec"); err != nil {
+		return err
+	}
+	if so.Output, err = flags.GetString("output"); err != nil {
+		return err
+	}
+	so.out = out
+	return nil
+}
+
+// Validate makes sure the options can be rendered into the unit file
+func (so *SystemdUnitOptions) Validate() error {
+	switch so.Component {
+	case ComponentYurtHub:
+	case ComponentTunnel:
+		return fmt.Errorf("component %s can not be run as a systemd service, the tunnel agent is not part of this version", so.Component)
+	default:
+		return fmt.Errorf("unknown component %s, valid components are: %s", so.Component, ComponentYurtHub)
+	}
+
+	if so.ServerAddr == "" {
+		return fmt.Errorf("server-addr is required")
+	} else if u, err := url.Parse(so.ServerAddr); err != nil || u.Scheme != "https" || u.Host == "" ||
+		strings.ContainsAny(so.ServerAddr, " \"%\\") {
+		return fmt.Errorf("server-addr(%s) should be an url like https://10.0.0.1:6443", so.ServerAddr)
+	}
+	if !nodeNameSpecifiers[so.NodeName] {
+		if errs := validation.IsDNS1123Subdomain(so.NodeName); len(errs) != 0 {
+			return fmt.Errorf("node-name(%s) is invalid: %s", so.NodeName, strings.Join(errs, ", "))
+		}
+	}
+	if !filepath.IsAbs(so.Binary) || strings.ContainsAny(so.Binary, " \"%\\") {
+		return fmt.Errorf("binary(%s) should be an absolute path without spaces", so.Binary)
+	}
+	if so.WatchdogSec < 0 {
+		return fmt.Errorf("watchdog-sec(%d) can not be negative", so.WatchdogSec)
+	}
+	if err := kubeutil.ValidateNoProxy(so.NoProxy); err != nil {
+		return err
+	}
+	return kubeutil.ValidateProxy(so.HTTPSProxy)
+}
+
+// RunSystemdUnit writes the unit file to the output file, or stdout if it's not set
+func (so *SystemdUnitOptions) RunSystemdUnit() error {
+	unit, err := so.render()
+	if err != nil {
+		return err
+	}
+	if so.Output == "" {
+		_, err := io.WriteString(so.out, unit)
+		return err
+	}
+
+	// the unit file may have the credentials of proxy
+	if err := ioutil.WriteFile(so.Output, []byte(unit), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(so.out, "the systemd unit of yurthub is written to %s, start it by: systemctl daemon-reload && systemctl enable --now %s\n",
+		so.Output, filepath.Base(so.Output))
+	return nil
+}
+
+// render returns the unit file of the component
+func (so *SystemdUnitOptions) render() (string, error) {
+	return templates.SubsituteTemplate(hubUnitTemplate, map[string]interface{}{
+		"ServerAddr":  so.ServerAddr,
+		"NodeName":    so.NodeName,
+		"Binary":      so.Binary,
+		"WatchdogSec": so.WatchdogSec,
+		// the percent-encoded credentials in proxy are escaped from the specifiers of systemd
+		"HTTPSProxy": strings.ReplaceAll(so.HTTPSProxy, "%", "%%"),
+		"NoProxy":    so.NoProxy,
+	})
+}
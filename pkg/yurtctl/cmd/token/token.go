@@ -0,0 +1,388 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	kubeutil "github.com/alibaba/openyurt/pkg/yurtctl/util/kubernetes"
+)
+
+const (
+	// bootstrapTokenSecretPrefix is the prefix of name of bootstrap token secret
+	bootstrapTokenSecretPrefix = "bootstrap-token-"
+	// defaultNodeTokenGroup is the group that kubeadm grants the permissions of joining nodes
+	defaultNodeTokenGroup = "system:bootstrappers:kubeadm:default-node-token"
+	// nodePoolTokenGroupPrefix is the prefix of group for the tokens of a node pool
+	nodePoolTokenGroupPrefix = "system:bootstrappers:openyurt:nodepool:"
+	// tokenChars are the characters that can be used in bootstrap token
+	tokenChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// tokenRegexp is the format of bootstrap token, like "abcdef.0123456789abcdef"
+var tokenRegexp = regexp.MustCompile(`^([a-z0-9]{6})\.([a-z0-9]{16})$`)
+
+// tokenIDRegexp is the format of id of bootstrap token
+var tokenIDRegexp = regexp.MustCompile(`^[a-z0-9]{6}$`)
+
+// TokenOptions has the information that required by token operations
+type TokenOptions struct {
+	clientSet *kubernetes.Clientset
+	restCfg   *rest.Config
+	out       io.Writer
+}
+
+// NewTokenOptions creates a new TokenOptions
+func NewTokenOptions() *TokenOptions {
+	return &TokenOptions{}
+}
+
+// NewTokenCmd generates a new token command
+func NewTokenCmd() *cobra.Command {
+	to := NewTokenOptions()
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manages the bootstrap tokens for joining edge nodes",
+		Run: func(cmd *cobra.Command, _ []string) {
+			cmd.Help()
+		},
+	}
+
+	cmd.AddCommand(newCreateCmd(to))
+	cmd.AddCommand(newListCmd(to))
+	cmd.AddCommand(newDeleteCmd(to))
+
+	return cmd
+}
+
+func newCreateCmd(to *TokenOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Creates a bootstrap token and prints the join command of edge node",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := to.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the token option: %s", err)
+			}
+			ttl, err := cmd.Flags().GetDuration("ttl")
+			if err != nil {
+				klog.Fatalf("fail to get ttl: %s", err)
+			}
+			nodePool, err := cmd.Flags().GetString("node-pool")
+			if err != nil {
+				klog.Fatalf("fail to get node pool: %s", err)
+			}
+			description, err := cmd.Flags().GetString("description")
+			if err != nil {
+				klog.Fatalf("fail to get description: %s", err)
+			}
+			printJoinCmd, err := cmd.Flags().GetBool("print-join-command")
+			if err != nil {
+				klog.Fatalf("fail to get print-join-command: %s", err)
+			}
+			if err := to.RunCreate(ttl, nodePool, description, printJoinCmd); err != nil {
+				klog.Fatalf("fail to create token: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().Duration("ttl", 24*time.Hour,
+		"The duration before the token is automatically deleted, 0 means the token never expires.")
+	cmd.Flags().String("node-pool", "",
+		"The node pool that the edge nodes joined by the token belong to.")
+	cmd.Flags().String("description", "",
+		"The description of the token, like where and by whom it is used.")
+	cmd.Flags().Bool("print-join-command", true,
+		"Print the command for joining an edge node with the token, instead of the token only.")
+
+	return cmd
+}
+
+func newListCmd(to *TokenOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the bootstrap tokens",
+		Run: func(cmd *cobra.Command, _ []string) {
+			if err := to.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the token option: %s", err)
+			}
+			nodePool, err := cmd.Flags().GetString("node-pool")
+			if err != nil {
+				klog.Fatalf("fail to get node pool: %s", err)
+			}
+			if err := to.RunList(nodePool); err != nil {
+				klog.Fatalf("fail to list tokens: %s", err)
+			}
+		},
+	}
+
+	cmd.Flags().String("node-pool", "",
+		"Only list the tokens of the node pool.")
+
+	return cmd
+}
+
+func newDeleteCmd(to *TokenOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete TOKEN_ID|TOKEN [TOKEN_ID|TOKEN...]",
+		Short: "Deletes the bootstrap tokens",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := to.Complete(cmd.Flags(), cmd.OutOrStdout()); err != nil {
+				klog.Fatalf("fail to complete the token option: %s", err)
+			}
+			if err := to.RunDelete(args); err != nil {
+				klog.Fatalf("fail to delete tokens: %s", err)
+			}
+		},
+	}
+
+	return cmd
+}
+
+// Complete completes all the required options
+func (to *TokenOptions) Complete(flags *pflag.FlagSet, out io.Writer) error {
+	var err error
+	to.restCfg, err = kubeutil.ClientConfigFromFlags(flags)
+	if err != nil {
+		return err
+	}
+
+	to.clientSet, err = kubernetes.NewForConfig(to.restCfg)
+	if err != nil {
+		return err
+	}
+	to.out = out
+	return nil
+}
+
+// RunCreate creates a bootstrap token, and prints the token or the join command
+func (to *TokenOptions) RunCreate(ttl time.Duration, nodePool, description string, printJoinCmd bool) error {
+	if ttl < 0 {
+		return errors.New("ttl can not be negative")
+	}
+
+	tokenID, err := randomString(6)
+	if err != nil {
+		return err
+	}
+	tokenSecret, err := randomString(16)
+	if err != nil {
+		return err
+	}
+	token := tokenID + "." + tokenSecret
+
+	var expiration time.Time
+	if ttl != 0 {
+		expiration = time.Now().Add(ttl)
+	}
+	secret := newTokenSecret(tokenID, tokenSecret, nodePool, description, expiration)
+	if _, err := to.clientSet.CoreV1().Secrets(metav1.NamespaceSystem).Create(secret); err != nil {
+		return err
+	}
+	klog.V(2).Infof("bootstrap token %s is created", tokenID)
+
+	if !printJoinCmd {
+		fmt.Fprintln(to.out, token)
+		return nil
+	}
+
+	joinCmd, err := joinCommand(to.restCfg, token, nodePool)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(to.out, joinCmd)
+	return nil
+}
+
+// RunList prints the bootstrap tokens
+func (to *TokenOptions) RunList(nodePool string) error {
+	secrets, err := to.clientSet.CoreV1().Secrets(metav1.NamespaceSystem).List(metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("type", string(v1.SecretTypeBootstrapToken)).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(to.out, 10, 4, 3, ' ', 0)
+	fmt.Fprintln(w, "TOKEN\tTTL\tEXPIRES\tNODEPOOL\tDESCRIPTION")
+	for _, secret := range secrets.Items {
+		tokenID := string(secret.Data["token-id"])
+		tokenSecret := string(secret.Data["token-secret"])
+		if tokenID == "" || tokenSecret == "" {
+			klog.Warningf("secret %s is not a valid bootstrap token, skip it", secret.Name)
+			continue
+		}
+		pool := secret.Labels[constants.LabelNodePool]
+		if nodePool != "" && pool != nodePool {
+			continue
+		}
+
+		ttl, expires := "<forever>", "<never>"
+		if exp := string(secret.Data["expiration"]); exp != "" {
+			expiration, err := time.Parse(time.RFC3339, exp)
+			if err != nil {
+				klog.Warningf("expiration(%s) of token %s is invalid: %s", exp, tokenID, err)
+			} else {
+				ttl = time.Until(expiration).Truncate(time.Second).String()
+				expires = exp
+				if expiration.Before(time.Now()) {
+					ttl = "<expired>"
+				}
+			}
+		}
+
+		fmt.Fprintf(w, "%s.%s\t%s\t%s\t%s\t%s\n", tokenID, tokenSecret, ttl, expires,
+			pool, string(secret.Data["description"]))
+	}
+	return w.Flush()
+}
+
+// RunDelete deletes the bootstrap tokens by token id or token
+func (to *TokenOptions) RunDelete(tokens []string) error {
+	for _, token := range tokens {
+		tokenID, err := parseTokenID(token)
+		if err != nil {
+			return err
+		}
+
+		if err := to.clientSet.CoreV1().Secrets(metav1.NamespaceSystem).
+			Delete(bootstrapTokenSecretPrefix+tokenID, &metav1.DeleteOptions{}); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("bootstrap token %s is not found", tokenID)
+			}
+			return err
+		}
+		fmt.Fprintf(to.out, "bootstrap token %s is deleted\n", tokenID)
+	}
+	return nil
+}
+
+// newTokenSecret generates the secret of bootstrap token, the tokens of a node pool
+// are labeled with the node pool, and are authenticated with an extra group of the
+// node pool, so the permissions can be granted per node pool.
+func newTokenSecret(tokenID, tokenSecret, nodePool, description string, expiration time.Time) *v1.Secret {
+	groups := []string{defaultNodeTokenGroup}
+	labels := map[string]string{}
+	if nodePool != "" {
+		groups = append(groups, nodePoolTokenGroupPrefix+nodePool)
+		labels[constants.LabelNodePool] = nodePool
+	}
+
+	data := map[string][]byte{
+		"token-id":                       []byte(tokenID),
+		"token-secret":                   []byte(tokenSecret),
+		"usage-bootstrap-authentication": []byte("true"),
+		"usage-bootstrap-signing":        []byte("true"),
+		"auth-extra-groups":              []byte(strings.Join(groups, ",")),
+	}
+	if description != "" {
+		data["description"] = []byte(description)
+	}
+	if !expiration.IsZero() {
+		data["expiration"] = []byte(expiration.UTC().Format(time.RFC3339))
+	}
+
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      bootstrapTokenSecretPrefix + tokenID,
+			Namespace: metav1.NamespaceSystem,
+			Labels:    labels,
+		},
+		Type: v1.SecretTypeBootstrapToken,
+		Data: data,
+	}
+}
+
+// joinCommand returns the command for joining an edge node to the cluster of restCfg,
+// the node is labeled with the node pool by kubelet when it registers.
+func joinCommand(restCfg *rest.Config, token, nodePool string) (string, error) {
+	caData := restCfg.CAData
+	if len(caData) == 0 && restCfg.CAFile != "" {
+		var err error
+		caData, err = ioutil.ReadFile(restCfg.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("fail to read ca file %s: %s", restCfg.CAFile, err)
+		}
+	}
+	if len(caData) == 0 {
+		return "", errors.New("the ca of cluster is not found in kubeconfig")
+	}
+
+	caHash, err := caCertHash(caData)
+	if err != nil {
+		return "", err
+	}
+
+	server := strings.TrimPrefix(strings.TrimPrefix(restCfg.Host, "https://"), "http://")
+	joinCmd := fmt.Sprintf("kubeadm join %s --token %s --discovery-token-ca-cert-hash sha256:%s",
+		server, token, caHash)
+	if nodePool != "" {
+		joinCmd = fmt.Sprintf("echo 'KUBELET_EXTRA_ARGS=--node-labels=%s=%s,%s=true' >> /etc/default/kubelet && %s",
+			constants.LabelNodePool, nodePool, constants.LabelEdgeWorker, joinCmd)
+	}
+	return joinCmd, nil
+}
+
+// caCertHash returns the sha256 hash of the public key of the first certificate in caData
+func caCertHash(caData []byte) (string, error) {
+	block, _ := pem.Decode(caData)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("no certificate is found in ca data")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("fail to parse ca certificate: %s", err)
+	}
+
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// parseTokenID returns the token id of token, token can be a token id or a whole token
+func parseTokenID(token string) (string, error) {
+	if tokenIDRegexp.MatchString(token) {
+		return token, nil
+	}
+
+	if matches := tokenRegexp.FindStringSubmatch(token); len(matches) == 3 {
+		return matches[1], nil
+	}
+	return "", fmt.Errorf("%s is neither a token id nor a token", token)
+}
+
+// randomString returns a random string of n characters in tokenChars
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(tokenChars)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = tokenChars[idx.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,152 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+func TestParseTokenID(t *testing.T) {
+	testcases := map[string]struct {
+		token   string
+		tokenID string
+		hasErr  bool
+	}{
+		"token id": {
+			token:   "abcdef",
+			tokenID: "abcdef",
+		},
+		"whole token": {
+			token:   "abcdef.0123456789abcdef",
+			tokenID: "abcdef",
+		},
+		"invalid token": {
+			token:  "ABCDEF.0123456789abcdef",
+			hasErr: true,
+		},
+		"short secret": {
+			token:  "abcdef.0123",
+			hasErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			tokenID, err := parseTokenID(tt.token)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expect error for token %s, but got %s", tt.token, tokenID)
+				}
+				return
+			}
+
+			if err != nil || tokenID != tt.tokenID {
+				t.Errorf("expect token id %s, but got %s, %v", tt.tokenID, tokenID, err)
+			}
+		})
+	}
+}
+
+func TestRandomString(t *testing.T) {
+	tokenID, _ := randomString(6)
+	tokenSecret, _ := randomString(16)
+	if token := tokenID + "." + tokenSecret; !tokenRegexp.MatchString(token) {
+		t.Errorf("generated token %s is invalid", token)
+	}
+}
+
+func TestNewTokenSecret(t *testing.T) {
+	expiration := time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC)
+	secret := newTokenSecret("abcdef", "0123456789abcdef", "hangzhou", "for hangzhou", expiration)
+
+	if secret.Name != "bootstrap-token-abcdef" || secret.Namespace != "kube-system" {
+		t.Errorf("unexpected secret %s/%s", secret.Namespace, secret.Name)
+	}
+	if secret.Labels[constants.LabelNodePool] != "hangzhou" {
+		t.Errorf("expect secret is labeled with node pool hangzhou, but got %v", secret.Labels)
+	}
+
+	expectData := map[string]string{
+		"token-id":          "abcdef",
+		"token-secret":      "0123456789abcdef",
+		"expiration":        "2020-10-01T00:00:00Z",
+		"description":       "for hangzhou",
+		"auth-extra-groups": defaultNodeTokenGroup + "," + nodePoolTokenGroupPrefix + "hangzhou",
+	}
+	for k, v := range expectData {
+		if string(secret.Data[k]) != v {
+			t.Errorf("expect %s of secret is %s, but got %s", k, v, string(secret.Data[k]))
+		}
+	}
+
+	secret = newTokenSecret("abcdef", "0123456789abcdef", "", "", time.Time{})
+	if _, ok := secret.Data["expiration"]; ok {
+		t.Errorf("expect no expiration for token that never expires")
+	}
+	if string(secret.Data["auth-extra-groups"]) != defaultNodeTokenGroup {
+		t.Errorf("expect auth-extra-groups is %s, but got %s", defaultNodeTokenGroup, string(secret.Data["auth-extra-groups"]))
+	}
+}
+
+func TestJoinCommand(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("fail to generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("fail to create certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	caHash := hex.EncodeToString(hash[:])
+
+	restCfg := &rest.Config{
+		Host: "https://192.168.0.1:6443",
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		},
+	}
+
+	joinCmd, err := joinCommand(restCfg, "abcdef.0123456789abcdef", "")
+	if err != nil {
+		t.Fatalf("fail to get join command: %s", err)
+	}
+	expect := "kubeadm join 192.168.0.1:6443 --token abcdef.0123456789abcdef --discovery-token-ca-cert-hash sha256:" + caHash
+	if joinCmd != expect {
+		t.Errorf("expect join command %s, but got %s", expect, joinCmd)
+	}
+
+	joinCmd, err = joinCommand(restCfg, "abcdef.0123456789abcdef", "hangzhou")
+	if err != nil {
+		t.Fatalf("fail to get join command: %s", err)
+	}
+	if !strings.Contains(joinCmd, constants.LabelNodePool+"=hangzhou") || !strings.HasSuffix(joinCmd, expect) {
+		t.Errorf("expect join command labels the node pool, but got %s", joinCmd)
+	}
+
+	if _, err := joinCommand(&rest.Config{Host: "https://192.168.0.1:6443"}, "abcdef.0123456789abcdef", ""); err == nil {
+		t.Errorf("expect error when ca is not found")
+	}
+}
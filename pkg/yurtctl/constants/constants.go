@@ -8,6 +8,27 @@ const (
 	// AnnotationAutonomy is used to identify if a node is automous
 	AnnotationAutonomy = "node.beta.alibabacloud.com/autonomy"
 
+	// AnnotationAutonomyReadiness is used by yurthub to report the summary of
+	// autonomy readiness of node in json
+	AnnotationAutonomyReadiness = "openyurt.io/autonomy-readiness"
+
+	// AnnotationMaintenance is used by yurt-controller-manager to mark the nodes whose
+	// node pool is in planned maintenance, the value is the reason of maintenance
+	AnnotationMaintenance = "openyurt.io/maintenance"
+
+	// AnnotationMaintenanceUntil is the time in RFC3339 that the maintenance of node ends
+	AnnotationMaintenanceUntil = "openyurt.io/maintenance-until"
+
+	// LabelNodePool is used to identify the node pool that a node(or a bootstrap
+	// token for joining nodes) belongs to
+	LabelNodePool = "openyurt.io/node-pool"
+
+	// DefaultYurtHubImage is the yurthub image that deployed by the servant job
+	DefaultYurtHubImage = "openyurt/yurt-hub:latest"
+
+	// ServantImage is the image of the servant job
+	ServantImage = "openyurt/yurtctl-servant:latest"
+
 	// YurtControllerManagerDeployment defines the yurt controller manager
 	// deployment in yaml format
 	YurtControllerManagerDeployment = `
@@ -43,19 +64,21 @@ spec:
         command:
         - edge-controller-manager	
 `
-	// ServantJobTemplate defines the servant job in yaml format
+	// ServantJobTemplate defines the servant job in yaml format, it's rendered
+	// with the ServantJobParams of yurtctl/util/kubernetes. the result of the
+	// servant is reported by the termination message of the container.
 	ServantJobTemplate = `
 apiVersion: batch/v1
 kind: Job
 metadata:
-  name: {{.jobName}}
+  name: {{.JobName}}
   namespace: kube-system
 spec:
   template:
     spec:
       hostPID: true
       restartPolicy: OnFailure
-      nodeName: {{.nodeName}}
+      nodeName: {{.NodeName}}
       volumes:
       - name: host-var-tmp
         hostPath:
@@ -63,12 +86,12 @@ spec:
           type: Directory
       containers:
       - name: yurtctl-servant
-        image: openyurt/yurtctl-servant:latest
+        image: ` + ServantImage + `
         command:
         - /bin/sh
         - -c
         args:
-        - "sed -i 's|__kubernetes_service_host__|$(KUBERNETES_SERVICE_HOST)|g;s|__kubernetes_service_port_https__|$(KUBERNETES_SERVICE_PORT_HTTPS)|g;s|__node_name__|$(NODE_NAME)|g' /var/lib/openyurt/setup_edgenode && cp /var/lib/openyurt/setup_edgenode /tmp && nsenter -t 1 -m -u -n -i /var/tmp/setup_edgenode {{.action}} {{.provider}}"
+        - "rm -f /tmp/yurtctl-servant-{{.Action}}.json && sed -i 's|__kubernetes_service_host__|$(KUBERNETES_SERVICE_HOST)|g;s|__kubernetes_service_port_https__|$(KUBERNETES_SERVICE_PORT_HTTPS)|g;s|__node_name__|$(NODE_NAME)|g' /var/lib/openyurt/setup_edgenode && cp /var/lib/openyurt/setup_edgenode /tmp && nsenter -t 1 -m -u -n -i /var/tmp/setup_edgenode {{.Action}} {{.Provider}}{{if .YurtHubImage}} {{.YurtHubImage}}{{end}}; rc=$?; cat /tmp/yurtctl-servant-{{.Action}}.json > /dev/termination-log 2>/dev/null; exit $rc"
         securityContext:
           privileged: true
         volumeMounts:
@@ -79,5 +102,121 @@ spec:
           valueFrom:
             fieldRef:
               fieldPath: spec.nodeName
+{{- if .HTTPSProxy}}
+        - name: YURTHUB_HTTPS_PROXY
+          value: "{{.HTTPSProxy}}"
+{{- end}}
+{{- if .NoProxy}}
+        - name: YURTHUB_NO_PROXY
+          value: "{{.NoProxy}}"
+{{- end}}
+{{- if .RegistryMirrors}}
+        - name: REGISTRY_MIRRORS
+          value: "{{.RegistryMirrors}}"
+{{- end}}
+{{- if .HubWatchdog}}
+        - name: YURTHUB_WATCHDOG
+          value: "true"
+{{- end}}
+{{- if .HostSysctls}}
+        - name: HOST_SYSCTLS
+          value: "{{.HostSysctls}}"
+{{- end}}
+{{- if .HostKernelModules}}
+        - name: HOST_KERNEL_MODULES
+          value: "{{.HostKernelModules}}"
+{{- end}}
+{{- if .HostNTPServers}}
+        - name: HOST_NTP_SERVERS
+          value: "{{.HostNTPServers}}"
+{{- end}}
+{{- if .HostRoutes}}
+        - name: HOST_ROUTES
+          value: "{{.HostRoutes}}"
+{{- end}}
+{{- if .NodeCommand}}
+        - name: NODE_COMMAND
+          value: "{{.NodeCommand}}"
+{{- end}}
+`
+	// PrePullDaemonSetTemplate defines the daemonset that pre-pulls images on nodes in yaml
+	// format, it's rendered with the PrePullParams of yurtctl/util/kubernetes. every image
+	// is pulled by an init container, so the pod is running after all images are pulled.
+	PrePullDaemonSetTemplate = `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: {{.Name}}
+  namespace: kube-system
+  labels:
+    app: {{.Name}}
+spec:
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      hostNetwork: true
+      terminationGracePeriodSeconds: 0
+      tolerations:
+      - operator: Exists
+      affinity:
+        nodeAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+            nodeSelectorTerms:
+            - matchFields:
+              - key: metadata.name
+                operator: In
+                values:
+{{- range .Nodes}}
+                - {{.}}
+{{- end}}
+      initContainers:
+{{- range $i, $image := .Images}}
+      - name: pull-{{$i}}
+        image: {{$image}}
+        imagePullPolicy: Always
+        command:
+        - /bin/sh
+        - -c
+        - "true"
+{{- end}}
+      containers:
+      - name: pulled
+        image: {{index .Images 0}}
+        imagePullPolicy: IfNotPresent
+        command:
+        - /bin/sh
+        - -c
+        - "while true; do sleep 3600; done"
+`
+	// HubAdminPodTemplate defines the pod that gets the path(like the index of cached objects) from
+	// the admin api of yurt-hub on the node in yaml format, the response is printed to the log of pod,
+	// so it can be read through kube-apiserver(and yurt-tunnel) when yurt-hub only listens on 127.0.0.1.
+	HubAdminPodTemplate = `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: {{.Name}}
+  namespace: kube-system
+spec:
+  hostNetwork: true
+  restartPolicy: Never
+  nodeName: {{.NodeName}}
+  terminationGracePeriodSeconds: 0
+  tolerations:
+  - operator: Exists
+  containers:
+  - name: hub-admin
+    image: ` + ServantImage + `
+    command:
+    - wget
+    - -q
+    - -O
+    - "-"
+    - "http://127.0.0.1:10261{{.Path}}"
 `
 )
@@ -0,0 +1,150 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/pflag"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+// ClustersFileEnv is the environment variable that overrides the path of clusters file
+const ClustersFileEnv = "YURTCTL_CLUSTERS"
+
+// ClusterAlias is the kubeconfig and context that an alias of cluster refers to
+type ClusterAlias struct {
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	Context    string `json:"context,omitempty"`
+}
+
+// ClustersFile is the file that records the aliases of clusters, like:
+//
+//	clusters:
+//	  hangzhou:
+//	    kubeconfig: /root/.kube/hangzhou.conf
+//	    context: edge-admin
+type ClustersFile struct {
+	Clusters map[string]ClusterAlias `json:"clusters"`
+}
+
+// DefaultClustersFilePath returns the path of clusters file, $YURTCTL_CLUSTERS
+// is used if it's set, otherwise $HOME/.yurtctl/clusters.yaml is used.
+func DefaultClustersFilePath() string {
+	if path := os.Getenv(ClustersFileEnv); path != "" {
+		return path
+	}
+
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".yurtctl", "clusters.yaml")
+	}
+	return ""
+}
+
+// LoadClusterAlias returns the cluster alias of name in the clusters file of path
+func LoadClusterAlias(path, name string) (*ClusterAlias, error) {
+	if path == "" {
+		return nil, errors.New("clusters file is not found, set $" + ClustersFileEnv + " to the path of it")
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fail to read clusters file %s: %s", path, err)
+	}
+
+	var cf ClustersFile
+	if err := yaml.Unmarshal(b, &cf); err != nil {
+		return nil, fmt.Errorf("fail to parse clusters file %s: %s", path, err)
+	}
+
+	alias, ok := cf.Clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s is not found in clusters file %s", name, path)
+	}
+	return &alias, nil
+}
+
+// ClientConfigFromFlags builds the rest config from the persistent flags of yurtctl,
+// --kubeconfig and --context are respected in the way of kubectl, that the kubeconfig
+// is loaded from --kubeconfig, $KUBECONFIG or $HOME/.kube/config in order.
+// --cluster selects the kubeconfig and context by the alias in the clusters file,
+// so it can not be used with --kubeconfig or --context.
+func ClientConfigFromFlags(flags *pflag.FlagSet) (*rest.Config, error) {
+	kbCfgPath, err := flags.GetString("kubeconfig")
+	if err != nil {
+		return nil, err
+	}
+
+	kbContext, err := flags.GetString("context")
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, err := flags.GetString("cluster")
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster != "" {
+		if kbCfgPath != "" || kbContext != "" {
+			return nil, errors.New("'--cluster' can not be used with '--kubeconfig' or '--context'")
+		}
+
+		alias, err := LoadClusterAlias(DefaultClustersFilePath(), cluster)
+		if err != nil {
+			return nil, err
+		}
+		kbCfgPath, kbContext = alias.Kubeconfig, alias.Context
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kbCfgPath
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kbContext}
+
+	restCfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		if clientcmd.IsEmptyConfig(err) {
+			return nil, errors.New("either '--kubeconfig', '--cluster', '$KUBECONFIG' or '$HOME/.kube/config' need to be set")
+		}
+		return nil, err
+	}
+
+	// the client connects kube-apiserver through the proxy in $HTTPS_PROXY(http, https or
+	// socks5) unless the host is in $NO_PROXY, like kubectl.
+	if proxy := proxyOf(restCfg.Host); proxy != "" {
+		klog.V(2).Infof("connect kube-apiserver %s through proxy %s", restCfg.Host, RedactProxy(proxy))
+	}
+	return restCfg, nil
+}
+
+// proxyOf returns the proxy from the environment that the requests to host go through
+func proxyOf(host string) string {
+	req, err := http.NewRequest(http.MethodGet, host, nil)
+	if err != nil {
+		return ""
+	}
+	proxy, err := utilnet.NewProxierWithNoProxyCIDR(http.ProxyFromEnvironment)(req)
+	if err != nil || proxy == nil {
+		return ""
+	}
+	return proxy.String()
+}
+
+// GenClientSet generates the clientset from the persistent flags of yurtctl
+func GenClientSet(flags *pflag.FlagSet) (*kubernetes.Clientset, error) {
+	restCfg, err := ClientConfigFromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return kubernetes.NewForConfig(restCfg)
+}
@@ -0,0 +1,115 @@
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: hangzhou
+  cluster:
+    server: https://hangzhou.example.com:6443
+- name: beijing
+  cluster:
+    server: https://beijing.example.com:6443
+contexts:
+- name: hangzhou
+  context:
+    cluster: hangzhou
+    user: admin
+- name: beijing
+  context:
+    cluster: beijing
+    user: admin
+current-context: hangzhou
+users:
+- name: admin
+  user:
+    token: foo
+`
+
+func TestClientConfigFromFlags(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yurtctl-client")
+	if err != nil {
+		t.Fatalf("fail to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	kbCfgPath := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(kbCfgPath, []byte(testKubeconfig), 0600); err != nil {
+		t.Fatalf("fail to write kubeconfig: %s", err)
+	}
+
+	clustersPath := filepath.Join(dir, "clusters.yaml")
+	clusters := "clusters:\n  bj:\n    kubeconfig: " + kbCfgPath + "\n    context: beijing\n"
+	if err := ioutil.WriteFile(clustersPath, []byte(clusters), 0600); err != nil {
+		t.Fatalf("fail to write clusters file: %s", err)
+	}
+	os.Setenv(ClustersFileEnv, clustersPath)
+	defer os.Unsetenv(ClustersFileEnv)
+
+	testcases := map[string]struct {
+		args   []string
+		host   string
+		hasErr bool
+	}{
+		"current context": {
+			args: []string{"--kubeconfig", kbCfgPath},
+			host: "https://hangzhou.example.com:6443",
+		},
+		"specified context": {
+			args: []string{"--kubeconfig", kbCfgPath, "--context", "beijing"},
+			host: "https://beijing.example.com:6443",
+		},
+		"cluster alias": {
+			args: []string{"--cluster", "bj"},
+			host: "https://beijing.example.com:6443",
+		},
+		"unknown cluster alias": {
+			args:   []string{"--cluster", "shanghai"},
+			hasErr: true,
+		},
+		"cluster alias with context": {
+			args:   []string{"--cluster", "bj", "--context", "hangzhou"},
+			hasErr: true,
+		},
+		"unknown context": {
+			args:   []string{"--kubeconfig", kbCfgPath, "--context", "shanghai"},
+			hasErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+			flags.String("kubeconfig", "", "")
+			flags.String("context", "", "")
+			flags.String("cluster", "", "")
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatalf("fail to parse flags: %s", err)
+			}
+
+			restCfg, err := ClientConfigFromFlags(flags)
+			if tt.hasErr {
+				if err == nil {
+					t.Errorf("expect error, but got host %s", restCfg.Host)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expect no error, but got %s", err)
+			}
+			if restCfg.Host != tt.host {
+				t.Errorf("expect host %s, but got %s", tt.host, restCfg.Host)
+			}
+		})
+	}
+}
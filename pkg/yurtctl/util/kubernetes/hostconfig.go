@@ -0,0 +1,175 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sysctlKeyRegexp restricts the key of sysctl(like net.ipv4.ip_forward or net/ipv4/conf/eth0.100/rp_filter)
+var sysctlKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+([./][a-zA-Z0-9_-]+)+$`)
+
+// sysctlValueRegexp restricts the value of sysctl, the fields of the value(like "4096 87380 6291456")
+// are separated by a single space.
+var sysctlValueRegexp = regexp.MustCompile(`^[a-zA-Z0-9._:/-]+( [a-zA-Z0-9._:/-]+)*$`)
+
+// kernelModuleRegexp restricts the name of kernel module
+var kernelModuleRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ntpServerRegexp restricts the host or ip of ntp server
+var ntpServerRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9.:-]*[a-zA-Z0-9])?$`)
+
+// deviceRegexp restricts the name of network interface
+var deviceRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,15}$`)
+
+// HostConfig is the configuration of the host OS of edge nodes, it's applied by the hostconfig
+// servant jobs, and the configuration applied by the jobs before is removed if it's empty.
+type HostConfig struct {
+	// Sysctls are the kernel parameters, like net.ipv4.ip_forward=1
+	Sysctls map[string]string
+	// KernelModules are the kernel modules that are loaded on boot
+	KernelModules []string
+	// NTPServers are the ntp servers of systemd-timesyncd or chrony
+	NTPServers []string
+	// Routes are the static routes that are added on boot
+	Routes []StaticRoute
+}
+
+// StaticRoute is a static route of the host
+type StaticRoute struct {
+	// Destination is the cidr of destination, like 10.0.0.0/8
+	Destination string
+	// Gateway is the ip of next hop, it may be empty if Device is set
+	Gateway string
+	// Device is the network interface that the route goes through, it's optional
+	Device string
+}
+
+// Validate makes sure the configuration can be passed to the servant job
+func (h *HostConfig) Validate() error {
+	for key, value := range h.Sysctls {
+		if !sysctlKeyRegexp.MatchString(key) {
+			return fmt.Errorf("sysctl(%s) is invalid", key)
+		}
+		if !sysctlValueRegexp.MatchString(value) {
+			return fmt.Errorf("value(%s) of sysctl %s is invalid", value, key)
+		}
+	}
+	for _, module := range h.KernelModules {
+		if !kernelModuleRegexp.MatchString(module) {
+			return fmt.Errorf("kernel module(%s) is invalid", module)
+		}
+	}
+	for _, server := range h.NTPServers {
+		if !ntpServerRegexp.MatchString(server) {
+			return fmt.Errorf("ntp server(%s) is invalid", server)
+		}
+	}
+	for _, route := range h.Routes {
+		if err := route.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate makes sure the destination is a cidr, and the route has a valid gateway or device
+func (r StaticRoute) Validate() error {
+	if _, _, err := net.ParseCIDR(r.Destination); err != nil {
+		return fmt.Errorf("destination(%s) of route is invalid: %s", r.Destination, err)
+	}
+	if r.Gateway == "" && r.Device == "" {
+		return fmt.Errorf("neither gateway nor device is specified for route to %s", r.Destination)
+	}
+	if r.Gateway != "" && net.ParseIP(r.Gateway) == nil {
+		return fmt.Errorf("gateway(%s) of route to %s is invalid", r.Gateway, r.Destination)
+	}
+	if r.Device != "" && !deviceRegexp.MatchString(r.Device) {
+		return fmt.Errorf("device(%s) of route to %s is invalid", r.Device, r.Destination)
+	}
+	return nil
+}
+
+// SetParams sets the formatted configuration to the parameters of servant job:
+// sysctls in the format of "key1=value1 key2=v1,v2"(spaces in values are replaced by commas),
+// kernel modules and ntp servers separated by spaces, and routes in the format of
+// "destination1,gateway1 destination2,gateway2,device2". sysctls are sorted by key, so the
+// same configuration is always formatted to the same parameters.
+func (h *HostConfig) SetParams(p *ServantJobParams) {
+	keys := make([]string, 0, len(h.Sysctls))
+	for key := range h.Sysctls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sysctls := make([]string, 0, len(keys))
+	for _, key := range keys {
+		sysctls = append(sysctls, key+"="+strings.Replace(h.Sysctls[key], " ", ",", -1))
+	}
+
+	routes := make([]string, 0, len(h.Routes))
+	for _, r := range h.Routes {
+		route := r.Destination + "," + r.Gateway
+		if r.Device != "" {
+			route += "," + r.Device
+		}
+		routes = append(routes, route)
+	}
+
+	p.HostSysctls = strings.Join(sysctls, " ")
+	p.HostKernelModules = strings.Join(h.KernelModules, " ")
+	p.HostNTPServers = strings.Join(h.NTPServers, " ")
+	p.HostRoutes = strings.Join(routes, " ")
+}
+
+// ParseHostConfig parses the host configuration from the parameters of servant job, it's
+// the reverse of HostConfig.SetParams.
+func ParseHostConfig(p *ServantJobParams) (*HostConfig, error) {
+	h := &HostConfig{}
+	if p.HostKernelModules != "" {
+		h.KernelModules = strings.Fields(p.HostKernelModules)
+	}
+	if p.HostNTPServers != "" {
+		h.NTPServers = strings.Fields(p.HostNTPServers)
+	}
+	for _, item := range strings.Fields(p.HostSysctls) {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("sysctl(%s) is invalid, the format is key=value", item)
+		}
+		if h.Sysctls == nil {
+			h.Sysctls = map[string]string{}
+		}
+		h.Sysctls[parts[0]] = strings.Replace(parts[1], ",", " ", -1)
+	}
+	for _, item := range strings.Fields(p.HostRoutes) {
+		parts := strings.Split(item, ",")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("route(%s) is invalid, the format is destination,gateway[,device]", item)
+		}
+		route := StaticRoute{Destination: parts[0], Gateway: parts[1]}
+		if len(parts) == 3 {
+			route.Device = parts[2]
+		}
+		h.Routes = append(h.Routes, route)
+	}
+	return h, h.Validate()
+}
+
+// ValidateHostConfig makes sure the formatted host configuration in the parameters of servant
+// job is valid, and only separated by single spaces, because it's passed to the shell command.
+func ValidateHostConfig(p *ServantJobParams) error {
+	for name, val := range map[string]string{
+		"hostSysctls":       p.HostSysctls,
+		"hostKernelModules": p.HostKernelModules,
+		"hostNTPServers":    p.HostNTPServers,
+		"hostRoutes":        p.HostRoutes,
+	} {
+		if val != strings.Join(strings.Fields(val), " ") {
+			return fmt.Errorf("%s(%s) should be separated by single spaces", name, val)
+		}
+	}
+	_, err := ParseHostConfig(p)
+	return err
+}
@@ -0,0 +1,83 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHostConfigParams(t *testing.T) {
+	testcases := map[string]struct {
+		config HostConfig
+		params ServantJobParams
+		valid  bool
+	}{
+		"empty": {
+			valid: true,
+		},
+		"host configuration": {
+			config: HostConfig{
+				Sysctls:       map[string]string{"net.ipv4.tcp_rmem": "4096 87380 6291456", "net/ipv4/conf/eth0.100/rp_filter": "2"},
+				KernelModules: []string{"br_netfilter", "overlay"},
+				NTPServers:    []string{"ntp.example.com", "10.0.0.1"},
+				Routes: []StaticRoute{
+					{Destination: "10.0.0.0/8", Gateway: "192.168.1.1"},
+					{Destination: "172.16.0.0/12", Device: "eth1"},
+				},
+			},
+			params: ServantJobParams{
+				HostSysctls:       "net.ipv4.tcp_rmem=4096,87380,6291456 net/ipv4/conf/eth0.100/rp_filter=2",
+				HostKernelModules: "br_netfilter overlay",
+				HostNTPServers:    "ntp.example.com 10.0.0.1",
+				HostRoutes:        "10.0.0.0/8,192.168.1.1 172.16.0.0/12,,eth1",
+			},
+			valid: true,
+		},
+		"invalid sysctl value": {
+			config: HostConfig{Sysctls: map[string]string{"kernel.core_pattern": "|/bin/sh -c reboot"}},
+		},
+		"invalid kernel module": {
+			config: HostConfig{KernelModules: []string{"$(reboot)"}},
+		},
+		"route without gateway and device": {
+			config: HostConfig{Routes: []StaticRoute{{Destination: "10.0.0.0/8"}}},
+		},
+		"invalid route destination": {
+			config: HostConfig{Routes: []StaticRoute{{Destination: "10.0.0.1", Gateway: "192.168.1.1"}}},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := tt.config.Validate()
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expect host config %+v is invalid, but got nil", tt.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect host config is valid, but got %v", err)
+			}
+
+			var params ServantJobParams
+			tt.config.SetParams(&params)
+			if !reflect.DeepEqual(params, tt.params) {
+				t.Errorf("expect params %+v, but got %+v", tt.params, params)
+			}
+			if err := ValidateHostConfig(&params); err != nil {
+				t.Errorf("expect formatted host config is valid, but got %v", err)
+			}
+			parsed, err := ParseHostConfig(&params)
+			if err != nil {
+				t.Fatalf("failed to parse host config, %v", err)
+			}
+			if !reflect.DeepEqual(*parsed, tt.config) {
+				t.Errorf("expect parsed host config %+v, but got %+v", tt.config, *parsed)
+			}
+		})
+	}
+
+	if err := ValidateHostConfig(&ServantJobParams{HostKernelModules: "overlay  br_netfilter"}); err == nil {
+		t.Errorf("expect kernel modules separated by two spaces are invalid, but got nil")
+	}
+}
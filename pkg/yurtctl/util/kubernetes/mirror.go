@@ -0,0 +1,90 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// registryRegexp restricts the registry host(like docker.io or registry.example.com:5000)
+var registryRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]+)?$`)
+
+// mirrorEndpointRegexp restricts the endpoint of mirror, because it's passed to the servant job
+var mirrorEndpointRegexp = regexp.MustCompile(`^https?://[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-zA-Z0-9._/-]*)?$`)
+
+// RegistryMirrors are the mirror endpoints of registries, the endpoints are tried in order
+// before the registry itself when images are pulled.
+type RegistryMirrors map[string][]string
+
+// ParseRegistryMirrors parses the mirrors in the format of "registry=endpoint1,endpoint2"
+func ParseRegistryMirrors(items []string) (RegistryMirrors, error) {
+	mirrors := RegistryMirrors{}
+	for _, item := range items {
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("registry mirror(%s) is invalid, the format is registry=endpoint1,endpoint2", item)
+		}
+		mirrors[parts[0]] = append(mirrors[parts[0]], strings.Split(parts[1], ",")...)
+	}
+	return mirrors, mirrors.Validate()
+}
+
+// Validate makes sure the registries and endpoints are valid
+func (m RegistryMirrors) Validate() error {
+	for registry, endpoints := range m {
+		if !registryRegexp.MatchString(registry) {
+			return fmt.Errorf("registry(%s) is invalid", registry)
+		}
+		if len(endpoints) == 0 {
+			return fmt.Errorf("no mirror is specified for registry %s", registry)
+		}
+		for _, endpoint := range endpoints {
+			if !mirrorEndpointRegexp.MatchString(endpoint) {
+				return fmt.Errorf("mirror(%s) of registry %s is invalid", endpoint, registry)
+			}
+			if _, err := url.Parse(endpoint); err != nil {
+				return fmt.Errorf("mirror(%s) of registry %s is invalid: %s", endpoint, registry, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Merge returns the mirrors that the mirrors of registries in override replace the ones in m
+func (m RegistryMirrors) Merge(override RegistryMirrors) RegistryMirrors {
+	merged := RegistryMirrors{}
+	for registry, endpoints := range m {
+		merged[registry] = endpoints
+	}
+	for registry, endpoints := range override {
+		merged[registry] = endpoints
+	}
+	return merged
+}
+
+// String formats the mirrors in the format of "registry1=endpoint1,endpoint2 registry2=endpoint3",
+// the registries are sorted, so the same mirrors are always formatted to the same string.
+func (m RegistryMirrors) String() string {
+	registries := make([]string, 0, len(m))
+	for registry := range m {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	items := make([]string, 0, len(registries))
+	for _, registry := range registries {
+		items = append(items, registry+"="+strings.Join(m[registry], ","))
+	}
+	return strings.Join(items, " ")
+}
+
+// ValidateRegistryMirrors makes sure the formatted mirrors can be passed to the servant job
+func ValidateRegistryMirrors(mirrors string) error {
+	if mirrors == "" {
+		return nil
+	}
+	_, err := ParseRegistryMirrors(strings.Split(mirrors, " "))
+	return err
+}
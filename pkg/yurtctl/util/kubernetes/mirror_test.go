@@ -0,0 +1,92 @@
+package kubernetes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRegistryMirrors(t *testing.T) {
+	testcases := map[string]struct {
+		items   []string
+		mirrors RegistryMirrors
+		str     string
+		valid   bool
+	}{
+		"no mirror": {
+			mirrors: RegistryMirrors{},
+			valid:   true,
+		},
+		"mirrors of registries": {
+			items: []string{
+				"registry.example.com:5000=http://10.0.0.10:5000",
+				"docker.io=https://m1.example.com,https://m2.example.com/v2",
+			},
+			mirrors: RegistryMirrors{
+				"docker.io":                 {"https://m1.example.com", "https://m2.example.com/v2"},
+				"registry.example.com:5000": {"http://10.0.0.10:5000"},
+			},
+			str:   "docker.io=https://m1.example.com,https://m2.example.com/v2 registry.example.com:5000=http://10.0.0.10:5000",
+			valid: true,
+		},
+		"mirrors of the same registry are appended": {
+			items:   []string{"docker.io=https://m1.example.com", "docker.io=https://m2.example.com"},
+			mirrors: RegistryMirrors{"docker.io": {"https://m1.example.com", "https://m2.example.com"}},
+			str:     "docker.io=https://m1.example.com,https://m2.example.com",
+			valid:   true,
+		},
+		"no endpoint": {
+			items: []string{"docker.io="},
+		},
+		"invalid registry": {
+			items: []string{"Docker.io/library=https://m1.example.com"},
+		},
+		"endpoint without scheme": {
+			items: []string{"docker.io=m1.example.com"},
+		},
+		"invalid characters": {
+			items: []string{"docker.io=https://m1.example.com\";reboot"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			mirrors, err := ParseRegistryMirrors(tt.items)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expect mirrors %v are invalid, but got nil", tt.items)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect mirrors %v are valid, but got %v", tt.items, err)
+			}
+			if !reflect.DeepEqual(mirrors, tt.mirrors) {
+				t.Errorf("expect mirrors %v, but got %v", tt.mirrors, mirrors)
+			}
+			if str := mirrors.String(); str != tt.str {
+				t.Errorf("expect formatted mirrors %q, but got %q", tt.str, str)
+			}
+			if err := ValidateRegistryMirrors(mirrors.String()); err != nil {
+				t.Errorf("expect formatted mirrors are valid, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestMergeRegistryMirrors(t *testing.T) {
+	mirrors := RegistryMirrors{
+		"docker.io": {"https://m1.example.com"},
+		"quay.io":   {"https://quay.example.com"},
+	}
+	merged := mirrors.Merge(RegistryMirrors{"docker.io": {"https://m2.example.com"}})
+	expected := RegistryMirrors{
+		"docker.io": {"https://m2.example.com"},
+		"quay.io":   {"https://quay.example.com"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expect merged mirrors %v, but got %v", expected, merged)
+	}
+	if mirrors["docker.io"][0] != "https://m1.example.com" {
+		t.Errorf("expect mirrors are not changed by merge, but got %v", mirrors)
+	}
+}
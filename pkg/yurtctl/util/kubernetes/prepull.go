@@ -0,0 +1,258 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+	tmplutil "github.com/alibaba/openyurt/pkg/yurtctl/util/templates"
+)
+
+// PrePullDaemonSetName is the name of daemonset that pre-pulls images
+const PrePullDaemonSetName = "yurtctl-prepull"
+
+var (
+	WaitPrePullTimeout = time.Minute * 30
+	CheckPrePullPeriod = time.Second * 10
+)
+
+// imagePullFailures are the reasons of waiting containers that the image can't be pulled
+var imagePullFailures = map[string]bool{
+	"ErrImagePull":      true,
+	"ImagePullBackOff":  true,
+	"InvalidImageName":  true,
+	"ErrImageNeverPull": true,
+}
+
+// PrePullParams are the parameters for rendering the pre-pull daemonset template
+type PrePullParams struct {
+	Name   string
+	Nodes  []string
+	Images []string
+}
+
+// ServantJobImages returns the images that the servant jobs need on edge nodes
+func ServantJobImages(yurtHubImage string) []string {
+	return []string{constants.ServantImage, yurtHubImage}
+}
+
+// ValidateImages makes sure the images to pre-pull are set and valid
+func ValidateImages(images []string) error {
+	if len(images) == 0 {
+		return errors.New("no image is specified")
+	}
+	for _, image := range images {
+		if !servantJobParamRegexp.MatchString(image) {
+			return fmt.Errorf("image(%s) contains invalid characters", image)
+		}
+	}
+	return nil
+}
+
+// Validate makes sure the images and nodes are set and valid
+func (p *PrePullParams) Validate() error {
+	if err := ValidateImages(p.Images); err != nil {
+		return err
+	}
+	if len(p.Nodes) == 0 {
+		return errors.New("no node is specified")
+	}
+	for _, node := range p.Nodes {
+		if !servantJobParamRegexp.MatchString(node) {
+			return fmt.Errorf("node name(%s) contains invalid characters", node)
+		}
+	}
+	return nil
+}
+
+// NewPrePullDaemonSet renders the daemonset that pre-pulls images on nodes
+func NewPrePullDaemonSet(params PrePullParams) (*appsv1.DaemonSet, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if params.Name == "" {
+		params.Name = PrePullDaemonSetName
+	}
+
+	dsYaml, err := tmplutil.SubsituteTemplate(constants.PrePullDaemonSetTemplate, params)
+	if err != nil {
+		return nil, err
+	}
+	dsObj, err := YamlToObject([]byte(dsYaml))
+	if err != nil {
+		return nil, err
+	}
+	ds, ok := dsObj.(*appsv1.DaemonSet)
+	if !ok {
+		return nil, errors.New("fail to assert prepull daemonset")
+	}
+	return ds, nil
+}
+
+// PrePullImages pulls the images on nodes by a daemonset ahead of conversion or migration,
+// so the servant jobs don't time out in pulling images over slow links. the progress is
+// logged every period, and it returns after the images are pulled on all nodes, or timeout.
+// the daemonset is deleted before it returns.
+func PrePullImages(cliSet kubernetes.Interface, images, nodes []string, timeout, period time.Duration) error {
+	ds, err := NewPrePullDaemonSet(PrePullParams{Nodes: nodes, Images: images})
+	if err != nil {
+		return err
+	}
+	if _, err := cliSet.AppsV1().DaemonSets(ds.GetNamespace()).Create(ds); err != nil {
+		return fmt.Errorf("fail to create daemonset(%s), delete it if it's left by the last pre-pull: %s",
+			ds.GetName(), err)
+	}
+	defer func() {
+		if err := cliSet.AppsV1().DaemonSets(ds.GetNamespace()).
+			Delete(ds.GetName(), &metav1.DeleteOptions{
+				PropagationPolicy: &PropagationPolicy,
+			}); err != nil {
+			klog.Errorf("fail to delete prepull daemonset(%s): %s", ds.GetName(), err)
+		}
+	}()
+
+	var statuses map[string]*NodePrePullStatus
+	err = wait.Poll(period, timeout, func() (bool, error) {
+		podLst, err := cliSet.CoreV1().Pods(ds.GetNamespace()).List(metav1.ListOptions{
+			LabelSelector: "app=" + ds.GetName(),
+		})
+		if err != nil {
+			klog.Errorf("fail to list pods of daemonset(%s): %s", ds.GetName(), err)
+			return false, nil
+		}
+		statuses = PrePullStatus(podLst.Items, images)
+
+		completed := 0
+		for _, node := range nodes {
+			status, ok := statuses[node]
+			if !ok {
+				continue
+			}
+			if status.Completed(images) {
+				completed++
+			} else if status.Failure != "" {
+				klog.Warningf("fail to pull images on node %s, retrying: %s", node, status.Failure)
+			}
+		}
+		klog.Infof("images are pulled on %d/%d nodes", completed, len(nodes))
+		return completed == len(nodes), nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("images are not pulled on nodes %v in %v", pendingNodes(statuses, nodes, images), timeout)
+	} else if err != nil {
+		return err
+	}
+
+	return VerifyPrePull(statuses, nodes, images)
+}
+
+// NodePrePullStatus is the pre-pull status of images on a node
+type NodePrePullStatus struct {
+	// ImageIDs are the ids(with digests) of the pulled images
+	ImageIDs map[string]string
+	// Failure is the reason that images fail to be pulled, if any
+	Failure string
+}
+
+// Completed returns true if all images are pulled on the node
+func (s *NodePrePullStatus) Completed(images []string) bool {
+	for _, image := range images {
+		if _, ok := s.ImageIDs[image]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// PrePullStatus returns the pre-pull status of the nodes that the pods of pre-pull daemonset run on,
+// an image is pulled on the node if the init container of the image is completed.
+func PrePullStatus(pods []v1.Pod, images []string) map[string]*NodePrePullStatus {
+	statuses := make(map[string]*NodePrePullStatus)
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		status := &NodePrePullStatus{ImageIDs: map[string]string{}}
+		for _, cs := range pod.Status.InitContainerStatuses {
+			image := containerImage(pod.Spec.InitContainers, cs.Name)
+			if image == "" {
+				continue
+			}
+			switch {
+			case cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0:
+				status.ImageIDs[image] = cs.ImageID
+			case cs.State.Terminated != nil:
+				status.Failure = fmt.Sprintf("fail to run /bin/sh in image %s, exit code %d",
+					image, cs.State.Terminated.ExitCode)
+			case cs.State.Waiting != nil && imagePullFailures[cs.State.Waiting.Reason]:
+				status.Failure = fmt.Sprintf("image %s: %s: %s", image, cs.State.Waiting.Reason, cs.State.Waiting.Message)
+			}
+		}
+		statuses[pod.Spec.NodeName] = status
+	}
+	return statuses
+}
+
+// VerifyPrePull makes sure the images are pulled on all nodes, and the images of the same
+// name are pulled in the same digest, otherwise the nodes would be converted to different
+// versions because of stale registry mirrors.
+func VerifyPrePull(statuses map[string]*NodePrePullStatus, nodes, images []string) error {
+	if pending := pendingNodes(statuses, nodes, images); len(pending) != 0 {
+		return fmt.Errorf("images are not pulled on nodes %v", pending)
+	}
+
+	for _, image := range images {
+		digests := map[string][]string{}
+		for _, node := range nodes {
+			digest := imageDigest(statuses[node].ImageIDs[image])
+			digests[digest] = append(digests[digest], node)
+		}
+		if len(digests) > 1 {
+			return fmt.Errorf("image %s is pulled in different digests on nodes: %v", image, digests)
+		}
+		for digest := range digests {
+			klog.Infof("image %s(%s) is pulled on %d nodes", image, digest, len(nodes))
+		}
+	}
+	return nil
+}
+
+// pendingNodes returns the nodes that images are not pulled on
+func pendingNodes(statuses map[string]*NodePrePullStatus, nodes, images []string) []string {
+	var pending []string
+	for _, node := range nodes {
+		if status, ok := statuses[node]; !ok || !status.Completed(images) {
+			pending = append(pending, node)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+func containerImage(containers []v1.Container, name string) string {
+	for _, c := range containers {
+		if c.Name == name {
+			return c.Image
+		}
+	}
+	return ""
+}
+
+// imageDigest returns the digest in image id(like docker-pullable://nginx@sha256:xxx),
+// the prefix of image id differs between container runtimes.
+func imageDigest(imageID string) string {
+	if i := strings.LastIndex(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return imageID
+}
@@ -0,0 +1,167 @@
+package kubernetes
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/alibaba/openyurt/pkg/yurtctl/constants"
+)
+
+var testImages = []string{constants.ServantImage, "openyurt/yurt-hub:v0.2.0"}
+
+// newPrePullPod returns the pod of prepull daemonset on node, the init containers of
+// images are in the states
+func newPrePullPod(node string, states ...v1.ContainerState) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PrePullDaemonSetName + "-" + node,
+			Namespace: "kube-system",
+			Labels:    map[string]string{"app": PrePullDaemonSetName},
+		},
+		Spec: v1.PodSpec{NodeName: node},
+	}
+	for i, state := range states {
+		name := "pull-" + strconv.Itoa(i)
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, v1.Container{Name: name, Image: testImages[i]})
+		pod.Status.InitContainerStatuses = append(pod.Status.InitContainerStatuses, v1.ContainerStatus{
+			Name:    name,
+			State:   state,
+			ImageID: imageIDOf(state),
+		})
+	}
+	return pod
+}
+
+func imageIDOf(state v1.ContainerState) string {
+	if state.Terminated == nil {
+		return ""
+	}
+	return "docker-pullable://image@" + state.Terminated.Message
+}
+
+func completed(digest string) v1.ContainerState {
+	return v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0, Message: digest}}
+}
+
+func waiting(reason string) v1.ContainerState {
+	return v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: reason}}
+}
+
+func TestNewPrePullDaemonSet(t *testing.T) {
+	ds, err := NewPrePullDaemonSet(PrePullParams{Nodes: []string{"edge-node1", "edge-node2"}, Images: testImages})
+	if err != nil {
+		t.Fatalf("NewPrePullDaemonSet failed: %s", err)
+	}
+	if ds.GetName() != PrePullDaemonSetName {
+		t.Fatalf("NewPrePullDaemonSet failed: want %s get %s", PrePullDaemonSetName, ds.GetName())
+	}
+
+	spec := ds.Spec.Template.Spec
+	var images []string
+	for _, c := range spec.InitContainers {
+		images = append(images, c.Image)
+	}
+	if !reflect.DeepEqual(images, testImages) {
+		t.Fatalf("NewPrePullDaemonSet failed: want images %v get %v", testImages, images)
+	}
+	nodes := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchFields[0].Values
+	if !reflect.DeepEqual(nodes, []string{"edge-node1", "edge-node2"}) {
+		t.Fatalf("NewPrePullDaemonSet failed: unexpected nodes %v", nodes)
+	}
+
+	if _, err := NewPrePullDaemonSet(PrePullParams{Nodes: []string{"edge-node1"}, Images: []string{"nginx; reboot"}}); err == nil {
+		t.Fatal("NewPrePullDaemonSet failed: want error for invalid image")
+	}
+	if _, err := NewPrePullDaemonSet(PrePullParams{Images: testImages}); err == nil {
+		t.Fatal("NewPrePullDaemonSet failed: want error for no node")
+	}
+}
+
+func TestPrePullStatus(t *testing.T) {
+	pods := []v1.Pod{
+		*newPrePullPod("edge-node1", completed("sha256:a"), completed("sha256:b")),
+		*newPrePullPod("edge-node2", completed("sha256:a"), waiting("ImagePullBackOff")),
+		*newPrePullPod("edge-node3", completed("sha256:a"), waiting("PodInitializing")),
+		*newPrePullPod(""),
+	}
+
+	statuses := PrePullStatus(pods, testImages)
+	if len(statuses) != 3 {
+		t.Fatalf("expect status of 3 nodes, but got %v", statuses)
+	}
+	if !statuses["edge-node1"].Completed(testImages) || statuses["edge-node1"].Failure != "" {
+		t.Errorf("expect images are pulled on edge-node1, but got %#v", statuses["edge-node1"])
+	}
+	if statuses["edge-node2"].Completed(testImages) || !strings.Contains(statuses["edge-node2"].Failure, "ImagePullBackOff") {
+		t.Errorf("expect images fail to be pulled on edge-node2, but got %#v", statuses["edge-node2"])
+	}
+	if statuses["edge-node3"].Completed(testImages) || statuses["edge-node3"].Failure != "" {
+		t.Errorf("expect images are being pulled on edge-node3, but got %#v", statuses["edge-node3"])
+	}
+}
+
+func TestVerifyPrePull(t *testing.T) {
+	testcases := map[string]struct {
+		pods  []v1.Pod
+		valid bool
+	}{
+		"same digests": {
+			pods: []v1.Pod{
+				*newPrePullPod("edge-node1", completed("sha256:a"), completed("sha256:b")),
+				*newPrePullPod("edge-node2", completed("sha256:a"), completed("sha256:b")),
+			},
+			valid: true,
+		},
+		"different digests": {
+			pods: []v1.Pod{
+				*newPrePullPod("edge-node1", completed("sha256:a"), completed("sha256:b")),
+				*newPrePullPod("edge-node2", completed("sha256:a"), completed("sha256:c")),
+			},
+		},
+		"not pulled": {
+			pods: []v1.Pod{
+				*newPrePullPod("edge-node1", completed("sha256:a"), completed("sha256:b")),
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := VerifyPrePull(PrePullStatus(tt.pods, testImages), []string{"edge-node1", "edge-node2"}, testImages)
+			if tt.valid && err != nil {
+				t.Errorf("expect images are verified, but got %v", err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expect images fail to be verified, but got nil")
+			}
+		})
+	}
+}
+
+func TestPrePullImages(t *testing.T) {
+	nodes := []string{"edge-node1", "edge-node2"}
+	cliSet := fake.NewSimpleClientset(
+		newPrePullPod("edge-node1", completed("sha256:a"), completed("sha256:b")),
+		newPrePullPod("edge-node2", completed("sha256:a"), completed("sha256:b")),
+	)
+	if err := PrePullImages(cliSet, testImages, nodes, time.Second, 10*time.Millisecond); err != nil {
+		t.Fatalf("PrePullImages failed: %s", err)
+	}
+	if _, err := cliSet.AppsV1().DaemonSets("kube-system").Get(PrePullDaemonSetName, metav1.GetOptions{}); err == nil {
+		t.Fatal("PrePullImages failed: want daemonset is deleted")
+	}
+
+	cliSet = fake.NewSimpleClientset(
+		newPrePullPod("edge-node1", completed("sha256:a"), waiting("ErrImagePull")),
+	)
+	err := PrePullImages(cliSet, testImages, nodes, 100*time.Millisecond, 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "[edge-node1 edge-node2]") {
+		t.Fatalf("PrePullImages failed: want error for pending nodes, get %v", err)
+	}
+}
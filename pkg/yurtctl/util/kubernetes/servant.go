@@ -0,0 +1,209 @@
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// ServantJobAction is the action that the servant job performs on the edge node
+type ServantJobAction string
+
+const (
+	// ServantJobActionConvert deploys yurt-hub and resets the kubelet service
+	ServantJobActionConvert ServantJobAction = "convert"
+	// ServantJobActionRevert removes yurt-hub and reverts the kubelet service
+	ServantJobActionRevert ServantJobAction = "revert"
+	// ServantJobActionMigrate swaps yurt-hub to another image
+	ServantJobActionMigrate ServantJobAction = "migrate"
+	// ServantJobActionMirror configures the registry mirrors of container runtime
+	ServantJobActionMirror ServantJobAction = "mirror"
+	// ServantJobActionWipe reverts the kubelet service, removes yurt-hub and wipes its cache
+	ServantJobActionWipe ServantJobAction = "wipe"
+	// ServantJobActionHostConfig configures the sysctls, kernel modules, ntp servers and
+	// static routes of the host
+	ServantJobActionHostConfig ServantJobAction = "hostconfig"
+	// ServantJobActionCommand runs an emergency operation(NodeCommand) on the host
+	ServantJobActionCommand ServantJobAction = "command"
+)
+
+// NodeCommand is the emergency operation that is run on the edge node by command, only the
+// operations in NodeCommands can be run, rather than any shell command.
+type NodeCommand string
+
+const (
+	// NodeCommandRestartKubelet restarts the kubelet service
+	NodeCommandRestartKubelet NodeCommand = "restart-kubelet"
+	// NodeCommandRestartContainerRuntime restarts docker or containerd, the restart is delayed
+	// for a few seconds, so the result is reported before the servant container is stopped.
+	NodeCommandRestartContainerRuntime NodeCommand = "restart-container-runtime"
+	// NodeCommandRestartYurtHub stops yurt-hub and waits for it to be healthy again
+	NodeCommandRestartYurtHub NodeCommand = "restart-yurthub"
+)
+
+// NodeCommands are the commands that can be run on edge nodes
+var NodeCommands = []NodeCommand{NodeCommandRestartKubelet, NodeCommandRestartContainerRuntime, NodeCommandRestartYurtHub}
+
+// ValidateNodeCommand makes sure the command is one of NodeCommands
+func ValidateNodeCommand(command NodeCommand) error {
+	for _, c := range NodeCommands {
+		if c == command {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown command: %s, valid commands are: %s, %s, %s", command,
+		NodeCommandRestartKubelet, NodeCommandRestartContainerRuntime, NodeCommandRestartYurtHub)
+}
+
+// servantJobParamRegexp restricts the parameters of servant job, because they are
+// passed to the shell command of the servant job.
+var servantJobParamRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._:/@-]*$`)
+
+// proxyRegexp restricts the url of proxy, the credentials in it may be percent-encoded
+var proxyRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._:/@%+-]*$`)
+
+// noProxyRegexp restricts the no proxy list(like "10.0.0.0/8,.svc,localhost") of yurt-hub
+var noProxyRegexp = regexp.MustCompile(`^[a-zA-Z0-9.*][a-zA-Z0-9.,*/:_-]*$`)
+
+// ServantJobParams are the parameters for rendering the servant job template
+type ServantJobParams struct {
+	Action ServantJobAction `json:"action"`
+	// Provider is required by convert and migrate
+	Provider string `json:"provider,omitempty"`
+	// YurtHubImage is required by migrate
+	YurtHubImage string `json:"yurthubImage,omitempty"`
+	// HTTPSProxy is the proxy(http, https or socks5) that yurt-hub connects kube-apiserver
+	// through, and NoProxy is the addresses that yurt-hub connects directly, they are used
+	// by convert and migrate.
+	HTTPSProxy string `json:"httpsProxy,omitempty"`
+	NoProxy    string `json:"noProxy,omitempty"`
+	// RegistryMirrors are the registry mirrors of container runtime(formatted by
+	// RegistryMirrors.String), they are used by convert and mirror, and mirror
+	// removes the mirrors configured by the servant jobs if they are empty.
+	RegistryMirrors string `json:"registryMirrors,omitempty"`
+	// HubWatchdog installs the yurthub-watchdog service by convert, it falls back kubelet
+	// to connect kube-apiserver directly when yurt-hub keeps crashing.
+	HubWatchdog bool `json:"hubWatchdog,omitempty"`
+	// HostSysctls, HostKernelModules, HostNTPServers and HostRoutes are the host configuration
+	// (formatted by HostConfig.SetParams) that is used by hostconfig, and hostconfig removes
+	// the configuration of the servant jobs if they are empty.
+	HostSysctls       string `json:"hostSysctls,omitempty"`
+	HostKernelModules string `json:"hostKernelModules,omitempty"`
+	HostNTPServers    string `json:"hostNTPServers,omitempty"`
+	HostRoutes        string `json:"hostRoutes,omitempty"`
+	// NodeCommand is required by command
+	NodeCommand NodeCommand `json:"nodeCommand,omitempty"`
+
+	// JobName and NodeName are set by NewServantJob for each edge node
+	JobName  string `json:"-"`
+	NodeName string `json:"-"`
+}
+
+// Validate makes sure the parameters required by the action are set and valid
+func (p *ServantJobParams) Validate() error {
+	switch p.Action {
+	case ServantJobActionConvert, ServantJobActionRevert, ServantJobActionMigrate, ServantJobActionMirror,
+		ServantJobActionWipe, ServantJobActionHostConfig, ServantJobActionCommand:
+	case "":
+		return errors.New("action is not specified")
+	default:
+		return fmt.Errorf("unknown action: %s, valid actions are: %s, %s, %s, %s, %s, %s, %s", p.Action,
+			ServantJobActionConvert, ServantJobActionRevert, ServantJobActionMigrate, ServantJobActionMirror,
+			ServantJobActionWipe, ServantJobActionHostConfig, ServantJobActionCommand)
+	}
+
+	if (p.Action == ServantJobActionConvert || p.Action == ServantJobActionMigrate) && p.Provider == "" {
+		return fmt.Errorf("provider is required by action %s", p.Action)
+	}
+	if p.Action == ServantJobActionMigrate && p.YurtHubImage == "" {
+		return fmt.Errorf("yurthub image is required by action %s", p.Action)
+	}
+	if p.Action == ServantJobActionCommand || p.NodeCommand != "" {
+		if err := ValidateNodeCommand(p.NodeCommand); err != nil {
+			return err
+		}
+	}
+
+	for name, val := range map[string]string{
+		"provider":     p.Provider,
+		"yurthubImage": p.YurtHubImage,
+	} {
+		if val != "" && !servantJobParamRegexp.MatchString(val) {
+			return fmt.Errorf("%s(%s) contains invalid characters", name, val)
+		}
+	}
+	if err := ValidateNoProxy(p.NoProxy); err != nil {
+		return err
+	}
+	if err := ValidateRegistryMirrors(p.RegistryMirrors); err != nil {
+		return err
+	}
+	if err := ValidateHostConfig(p); err != nil {
+		return err
+	}
+	return ValidateProxy(p.HTTPSProxy)
+}
+
+// ValidateProxy makes sure the proxy is an url of http, https or socks5 proxy
+func ValidateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	} else if !proxyRegexp.MatchString(proxy) {
+		return fmt.Errorf("proxy(%s) contains invalid characters", RedactProxy(proxy))
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Errorf("proxy(%s) is invalid: %s", RedactProxy(proxy), err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("scheme of proxy(%s) is not supported, valid schemes are: http, https, socks5", RedactProxy(proxy))
+	}
+	if u.Host == "" {
+		return fmt.Errorf("host of proxy(%s) is not specified", RedactProxy(proxy))
+	}
+	return nil
+}
+
+// ValidateNoProxy makes sure the no proxy list only has addresses, domains and cidrs
+func ValidateNoProxy(noProxy string) error {
+	if noProxy != "" && !noProxyRegexp.MatchString(noProxy) {
+		return fmt.Errorf("noProxy(%s) contains invalid characters", noProxy)
+	}
+	return nil
+}
+
+// RedactProxy hides the password in the url of proxy, so the proxy can be printed
+func RedactProxy(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
+
+// jobNameBase returns the base of the servant job name of the action
+func (p *ServantJobParams) jobNameBase() string {
+	switch p.Action {
+	case ServantJobActionConvert:
+		return ConvertJobNameBase
+	case ServantJobActionRevert:
+		return RevertJobNameBase
+	case ServantJobActionMirror:
+		return MirrorJobNameBase
+	case ServantJobActionWipe:
+		return WipeJobNameBase
+	case ServantJobActionHostConfig:
+		return HostConfigJobNameBase
+	case ServantJobActionCommand:
+		return CommandJobNameBase
+	default:
+		return MigrateJobNameBase
+	}
+}
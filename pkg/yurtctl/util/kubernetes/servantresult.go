@@ -0,0 +1,242 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+// ServantJobStep is the step of the action that the servant job performs on the edge node,
+// the servant reports the step that it fails in.
+type ServantJobStep string
+
+const (
+	// ServantStepStartServant is reported by yurtctl when the servant doesn't report a result,
+	// e.g. the servant image can't be pulled.
+	ServantStepStartServant      ServantJobStep = "StartServant"
+	ServantStepConfigureMirrors  ServantJobStep = "ConfigureMirrors"
+	ServantStepSetupYurtHub      ServantJobStep = "SetupYurtHub"
+	ServantStepBackupKubeletConf ServantJobStep = "BackupKubeletConf"
+	ServantStepConfigureKubelet  ServantJobStep = "ConfigureKubelet"
+	ServantStepRestartKubelet    ServantJobStep = "RestartKubelet"
+	ServantStepInstallWatchdog   ServantJobStep = "InstallWatchdog"
+	ServantStepRemoveWatchdog    ServantJobStep = "RemoveWatchdog"
+	ServantStepRemoveYurtHub     ServantJobStep = "RemoveYurtHub"
+	ServantStepStopYurtHub       ServantJobStep = "StopYurtHub"
+	ServantStepBackupCache       ServantJobStep = "BackupCache"
+	ServantStepSwapYurtHub       ServantJobStep = "SwapYurtHub"
+	ServantStepVerifyYurtHub     ServantJobStep = "VerifyYurtHub"
+	ServantStepWipeCache         ServantJobStep = "WipeCache"
+	ServantStepRestartRuntime    ServantJobStep = "RestartContainerRuntime"
+	ServantStepRestartYurtHub    ServantJobStep = "RestartYurtHub"
+)
+
+// servantStepFailures are the reasons of failures in the steps
+var servantStepFailures = map[ServantJobStep]string{
+	ServantStepStartServant:      "servant is not started",
+	ServantStepConfigureMirrors:  "registry mirrors configuration failed",
+	ServantStepSetupYurtHub:      "yurt-hub is not running",
+	ServantStepBackupKubeletConf: "kubelet.conf backup failed",
+	ServantStepConfigureKubelet:  "kubelet configuration failed",
+	ServantStepRestartKubelet:    "kubelet restart failed",
+	ServantStepInstallWatchdog:   "yurthub-watchdog installation failed",
+	ServantStepRemoveWatchdog:    "yurthub-watchdog removal failed",
+	ServantStepRemoveYurtHub:     "yurt-hub removal failed",
+	ServantStepStopYurtHub:       "yurt-hub stop failed",
+	ServantStepBackupCache:       "yurt-hub cache backup failed",
+	ServantStepSwapYurtHub:       "yurt-hub manifest swap failed",
+	ServantStepVerifyYurtHub:     "yurt-hub is unhealthy",
+	ServantStepWipeCache:         "yurt-hub cache wipe failed",
+	ServantStepRestartRuntime:    "container runtime restart failed",
+	ServantStepRestartYurtHub:    "yurt-hub restart failed",
+}
+
+// ServantJobResult is the result of the servant job on the edge node, the servant writes
+// it in json to the termination message of the servant container.
+type ServantJobResult struct {
+	// NodeName is set by yurtctl
+	NodeName  string           `json:"nodeName,omitempty"`
+	Action    ServantJobAction `json:"action"`
+	Succeeded bool             `json:"succeeded"`
+	// Step is the last step of the action, it's the step that fails if the action fails
+	Step    ServantJobStep `json:"step,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// Reason returns the reason of the failure, it's empty if the servant job succeeds
+func (r *ServantJobResult) Reason() string {
+	if r.Succeeded {
+		return ""
+	}
+	if reason, ok := servantStepFailures[r.Step]; ok {
+		return reason
+	} else if r.Step != "" {
+		return fmt.Sprintf("step %s failed", r.Step)
+	}
+	return "unknown failure"
+}
+
+// Err returns the failure of the servant job as an error, it's nil if the servant job succeeds
+func (r *ServantJobResult) Err() error {
+	if r.Succeeded {
+		return nil
+	}
+	return fmt.Errorf("%s on node %s: %s", r.Reason(), r.NodeName, r.Message)
+}
+
+// ParseServantJobResult parses the result in the termination message of the servant container
+func ParseServantJobResult(message string) (*ServantJobResult, error) {
+	result := &ServantJobResult{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(message)), result); err != nil {
+		return nil, fmt.Errorf("fail to parse servant job result(%s): %s", message, err)
+	}
+	return result, nil
+}
+
+// RunServantJobAndGetResult runs the servant job, waits for it to be complete, failed or timeout,
+// and returns the result reported by the servant. the job is deleted if it succeeds, otherwise
+// it's kept for debugging.
+func RunServantJobAndGetResult(cliSet kubernetes.Interface, job *batchv1.Job, timeout, period time.Duration) *ServantJobResult {
+	nodeName := job.Spec.Template.Spec.NodeName
+	failed := func(step ServantJobStep, format string, args ...interface{}) *ServantJobResult {
+		return &ServantJobResult{NodeName: nodeName, Action: servantJobAction(job), Step: step,
+			Message: fmt.Sprintf(format, args...)}
+	}
+
+	if _, err := cliSet.BatchV1().Jobs(job.GetNamespace()).Create(job); err != nil {
+		return failed(ServantStepStartServant, "fail to create job(%s): %s", job.GetName(), err)
+	}
+
+	waitJobTimeout := time.After(timeout)
+	for {
+		select {
+		case <-waitJobTimeout:
+			result := ServantPodResult(cliSet, job)
+			if result == nil || result.Succeeded {
+				result = failed(ServantStepStartServant, "job(%s) is not complete in %v", job.GetName(), timeout)
+			}
+			result.NodeName = nodeName
+			return result
+		case <-time.After(period):
+			current, err := cliSet.BatchV1().Jobs(job.GetNamespace()).Get(job.GetName(), metav1.GetOptions{})
+			if err != nil {
+				klog.Errorf("fail to get job(%s) when waiting for it to be succeeded: %s", job.GetName(), err)
+				continue
+			}
+			if current.Spec.Completions != nil && current.Status.Succeeded == *current.Spec.Completions {
+				if err := cliSet.BatchV1().Jobs(job.GetNamespace()).
+					Delete(job.GetName(), &metav1.DeleteOptions{
+						PropagationPolicy: &PropagationPolicy,
+					}); err != nil {
+					klog.Errorf("fail to delete succeeded servant job(%s): %s", job.GetName(), err)
+				}
+				return &ServantJobResult{NodeName: nodeName, Action: servantJobAction(job), Succeeded: true}
+			}
+			if jobFailed(current) {
+				result := ServantPodResult(cliSet, job)
+				if result == nil || result.Succeeded {
+					result = failed(ServantStepStartServant, "job(%s) is failed", job.GetName())
+				}
+				result.NodeName = nodeName
+				return result
+			}
+		}
+	}
+}
+
+// servantPodResult returns the result reported by the latest servant pod of the job, the result
+// of the last run is returned if the container is restarted. nil is returned if no result is found.
+func ServantPodResult(cliSet kubernetes.Interface, job *batchv1.Job) *ServantJobResult {
+	podLst, err := cliSet.CoreV1().Pods(job.GetNamespace()).List(metav1.ListOptions{
+		LabelSelector: "job-name=" + job.GetName(),
+	})
+	if err != nil || len(podLst.Items) == 0 {
+		return nil
+	}
+	pods := podLst.Items
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+	})
+
+	for _, cs := range pods[0].Status.ContainerStatuses {
+		for _, terminated := range []*v1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.Message == "" {
+				continue
+			}
+			result, err := ParseServantJobResult(terminated.Message)
+			if err != nil {
+				klog.Warningf("%s", err)
+				continue
+			}
+			return result
+		}
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "" {
+			return &ServantJobResult{
+				Action:  servantJobAction(job),
+				Step:    ServantStepStartServant,
+				Message: strings.TrimSpace(cs.State.Waiting.Reason + ": " + cs.State.Waiting.Message),
+			}
+		}
+	}
+	return nil
+}
+
+// PrintServantJobResults prints the results of servant jobs in table
+func PrintServantJobResults(w io.Writer, results []ServantJobResult) {
+	tw := tabwriter.NewWriter(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "NODE\tACTION\tRESULT\tREASON\tMESSAGE")
+	for _, r := range results {
+		status := "Succeeded"
+		if !r.Succeeded {
+			status = "Failed"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.NodeName, r.Action, status, r.Reason(), r.Message)
+	}
+	tw.Flush()
+}
+
+// ServantJobsError returns an error that summarizes the failed servant jobs in results,
+// it's nil if all servant jobs succeed.
+func ServantJobsError(results []ServantJobResult) error {
+	var failures []string
+	for i := range results {
+		if err := results[i].Err(); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return fmt.Errorf("servant jobs failed on %d/%d nodes: %s", len(failures), len(results), strings.Join(failures, "; "))
+}
+
+// servantJobAction returns the action of the servant job by the name of the job
+func servantJobAction(job *batchv1.Job) ServantJobAction {
+	for _, action := range []ServantJobAction{ServantJobActionConvert, ServantJobActionRevert,
+		ServantJobActionMigrate, ServantJobActionMirror, ServantJobActionWipe} {
+		params := ServantJobParams{Action: action}
+		if strings.HasPrefix(job.GetName(), params.jobNameBase()+"-") {
+			return action
+		}
+	}
+	return ""
+}
+
+func jobFailed(job *batchv1.Job) bool {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == v1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
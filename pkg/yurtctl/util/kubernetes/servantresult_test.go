@@ -0,0 +1,189 @@
+package kubernetes
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestParseServantJobResult(t *testing.T) {
+	result, err := ParseServantJobResult(`{"action":"convert","succeeded":false,"step":"RestartKubelet","message":"exit code 3"}` + "\n")
+	if err != nil {
+		t.Fatalf("fail to parse result: %s", err)
+	}
+	if result.Action != ServantJobActionConvert || result.Succeeded || result.Step != ServantStepRestartKubelet ||
+		result.Message != "exit code 3" {
+		t.Errorf("unexpected result %#v", result)
+	}
+	if result.Reason() != "kubelet restart failed" {
+		t.Errorf("unexpected reason %s", result.Reason())
+	}
+
+	if _, err := ParseServantJobResult("servant is killed"); err == nil {
+		t.Errorf("expect result that is not json is invalid, but got nil")
+	}
+}
+
+func TestServantJobResultReason(t *testing.T) {
+	testcases := map[string]struct {
+		result ServantJobResult
+		reason string
+	}{
+		"succeeded": {
+			result: ServantJobResult{Succeeded: true, Step: ServantStepRestartKubelet},
+		},
+		"backup failed": {
+			result: ServantJobResult{Step: ServantStepBackupCache},
+			reason: "yurt-hub cache backup failed",
+		},
+		"hub unhealthy": {
+			result: ServantJobResult{Step: ServantStepVerifyYurtHub},
+			reason: "yurt-hub is unhealthy",
+		},
+		"unknown step": {
+			result: ServantJobResult{Step: "Reboot"},
+			reason: "step Reboot failed",
+		},
+		"no step": {
+			reason: "unknown failure",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if reason := tt.result.Reason(); reason != tt.reason {
+				t.Errorf("expect reason %q, but got %q", tt.reason, reason)
+			}
+			if (tt.result.Err() == nil) != tt.result.Succeeded {
+				t.Errorf("expect error only for failed result, but got %v", tt.result.Err())
+			}
+		})
+	}
+}
+
+func newTestServantPod(job string, status v1.ContainerStatus) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job + "-abcde",
+			Namespace: "kube-system",
+			Labels:    map[string]string{"job-name": job},
+		},
+		Status: v1.PodStatus{ContainerStatuses: []v1.ContainerStatus{status}},
+	}
+}
+
+func TestRunServantJobAndGetResult(t *testing.T) {
+	jobName := ConvertJobNameBase + "-edge-node1"
+	testcases := map[string]struct {
+		jobStatus batchv1.JobStatus
+		pod       *v1.Pod
+		result    ServantJobResult
+		deleted   bool
+	}{
+		"job succeeded": {
+			jobStatus: batchv1.JobStatus{Succeeded: 1},
+			result:    ServantJobResult{NodeName: "edge-node1", Action: ServantJobActionConvert, Succeeded: true},
+			deleted:   true,
+		},
+		"job failed": {
+			jobStatus: batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue}}},
+			pod: newTestServantPod(jobName, v1.ContainerStatus{
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 1,
+					Message:  `{"action":"convert","succeeded":false,"step":"SetupYurtHub","message":"yurt-hub-edge-node1 failed"}`,
+				}},
+			}),
+			result: ServantJobResult{NodeName: "edge-node1", Action: ServantJobActionConvert,
+				Step: ServantStepSetupYurtHub, Message: "yurt-hub-edge-node1 failed"},
+		},
+		"container is restarting": {
+			pod: newTestServantPod(jobName, v1.ContainerStatus{
+				State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 3,
+					Message:  `{"action":"convert","succeeded":false,"step":"RestartKubelet","message":"exit code 3"}`,
+				}},
+			}),
+			result: ServantJobResult{NodeName: "edge-node1", Action: ServantJobActionConvert,
+				Step: ServantStepRestartKubelet, Message: "exit code 3"},
+		},
+		"image can not be pulled": {
+			pod: newTestServantPod(jobName, v1.ContainerStatus{
+				State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "timeout"}},
+			}),
+			result: ServantJobResult{NodeName: "edge-node1", Action: ServantJobActionConvert,
+				Step: ServantStepStartServant, Message: "ImagePullBackOff: timeout"},
+		},
+		"no servant pod": {
+			result: ServantJobResult{NodeName: "edge-node1", Action: ServantJobActionConvert,
+				Step: ServantStepStartServant, Message: "job(" + jobName + ") is not complete in 50ms"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			var objs []runtime.Object
+			if tt.pod != nil {
+				objs = append(objs, tt.pod)
+			}
+			cliSet := fake.NewSimpleClientset(objs...)
+			cliSet.PrependReactor("get", "jobs", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				completions := int32(1)
+				return true, &batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{Name: jobName, Namespace: "kube-system"},
+					Spec:       batchv1.JobSpec{Completions: &completions},
+					Status:     tt.jobStatus,
+				}, nil
+			})
+
+			job, err := NewServantJob(ServantJobParams{Action: ServantJobActionConvert, Provider: "ack"}, "edge-node1")
+			if err != nil {
+				t.Fatalf("NewServantJob failed: %s", err)
+			}
+			result := RunServantJobAndGetResult(cliSet, job, 50*time.Millisecond, 10*time.Millisecond)
+			if *result != tt.result {
+				t.Errorf("expect result %#v, but got %#v", tt.result, *result)
+			}
+
+			deleted := false
+			for _, action := range cliSet.Actions() {
+				if action.GetVerb() == "delete" && action.GetResource().Resource == "jobs" {
+					deleted = true
+				}
+			}
+			if deleted != tt.deleted {
+				t.Errorf("expect job is deleted %v, but got %v", tt.deleted, deleted)
+			}
+		})
+	}
+}
+
+func TestServantJobsError(t *testing.T) {
+	results := []ServantJobResult{
+		{NodeName: "edge-node1", Action: ServantJobActionConvert, Succeeded: true},
+		{NodeName: "edge-node2", Action: ServantJobActionConvert, Step: ServantStepRestartKubelet, Message: "exit code 3"},
+	}
+
+	err := ServantJobsError(results)
+	if err == nil || !strings.Contains(err.Error(), "1/2 nodes: kubelet restart failed on node edge-node2: exit code 3") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := ServantJobsError(results[:1]); err != nil {
+		t.Errorf("expect no error, but got %v", err)
+	}
+
+	var buf bytes.Buffer
+	PrintServantJobResults(&buf, results)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[2], "Failed") || !strings.Contains(lines[2], "kubelet restart failed") {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
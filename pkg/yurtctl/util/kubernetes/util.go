@@ -8,9 +8,11 @@ import (
 
 	batchv1 "k8s.io/api/batch/v1"
 	"k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
@@ -20,8 +22,13 @@ import (
 )
 
 const (
-	ConvertJobNameBase = "yurtctl-servant-convert"
-	RevertJobNameBase  = "yurtctl-servant-revert"
+	ConvertJobNameBase    = "yurtctl-servant-convert"
+	RevertJobNameBase     = "yurtctl-servant-revert"
+	MigrateJobNameBase    = "yurtctl-servant-migrate"
+	MirrorJobNameBase     = "yurtctl-servant-mirror"
+	WipeJobNameBase       = "yurtctl-servant-wipe"
+	HostConfigJobNameBase = "yurtctl-servant-hostconfig"
+	CommandJobNameBase    = "yurtctl-servant-command"
 )
 
 var (
@@ -75,7 +82,7 @@ func RunJobAndCleanup(cliSet *kubernetes.Clientset, job *batchv1.Job, timeout, p
 			job, err := cliSet.BatchV1().Jobs(job.GetNamespace()).
 				Get(job.GetName(), metav1.GetOptions{})
 			if err != nil {
-				klog.Error("fail to get job(%s) when waiting for it to be succeeded: %s",
+				klog.Errorf("fail to get job(%s) when waiting for it to be succeeded: %s",
 					job.GetName(), err)
 				return err
 			}
@@ -95,49 +102,114 @@ func RunJobAndCleanup(cliSet *kubernetes.Clientset, job *batchv1.Job, timeout, p
 	}
 }
 
-// RunServantJobs launchs servant jobs on specified edge nodes
-func RunServantJobs(cliSet *kubernetes.Clientset, tmplCtx map[string]string, edgeNodeNames []string) error {
-	var wg sync.WaitGroup
-	for _, nodeName := range edgeNodeNames {
-		action, exist := tmplCtx["action"]
-		if !exist {
-			return errors.New("action is not specified")
-		}
-
-		switch action {
-		case "convert":
-			tmplCtx["jobName"] = ConvertJobNameBase + "-" + nodeName
-		case "revert":
-			tmplCtx["jobName"] = RevertJobNameBase + "-" + nodeName
-		default:
-			return fmt.Errorf("unknown action: %s", action)
-		}
-		tmplCtx["nodeName"] = nodeName
+// RunServantJobs launchs servant jobs on specified edge nodes, and returns the results
+// of the servant jobs in the order of the nodes
+func RunServantJobs(cliSet kubernetes.Interface, params ServantJobParams, edgeNodeNames []string) ([]ServantJobResult, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
 
-		jobYaml, err := tmplutil.SubsituteTemplate(constants.ServantJobTemplate, tmplCtx)
-		if err != nil {
-			return err
-		}
-		srvJobObj, err := YamlToObject([]byte(jobYaml))
+	var wg sync.WaitGroup
+	results := make([]ServantJobResult, len(edgeNodeNames))
+	for i, nodeName := range edgeNodeNames {
+		srvJob, err := NewServantJob(params, nodeName)
 		if err != nil {
-			return err
-		}
-		srvJob, ok := srvJobObj.(*batchv1.Job)
-		if !ok {
-			return errors.New("fail to assert yurtctl-servant job")
+			return nil, err
 		}
 		wg.Add(1)
-		go func() {
+		go func(i int) {
 			defer wg.Done()
-			if err := RunJobAndCleanup(cliSet, srvJob,
-				WaitServantJobTimeout, CheckServantJobPeriod); err != nil {
-				klog.Errorf("fail to run servant job(%s): %s",
-					srvJob.GetName(), err)
+			result := RunServantJobAndGetResult(cliSet, srvJob,
+				WaitServantJobTimeout, CheckServantJobPeriod)
+			result.Action = params.Action
+			if err := result.Err(); err != nil {
+				klog.Errorf("fail to run servant job(%s): %s", srvJob.GetName(), err)
 			} else {
 				klog.Infof("servant job(%s) has succeeded", srvJob.GetName())
 			}
-		}()
+			results[i] = *result
+		}(i)
 	}
 	wg.Wait()
+	return results, nil
+}
+
+// RunServantJob launchs the servant job on the specified edge node, and
+// wait for it to be succeeded
+func RunServantJob(cliSet kubernetes.Interface, params ServantJobParams, nodeName string) error {
+	srvJob, err := NewServantJob(params, nodeName)
+	if err != nil {
+		return err
+	}
+	result := RunServantJobAndGetResult(cliSet, srvJob,
+		WaitServantJobTimeout, CheckServantJobPeriod)
+	if err := result.Err(); err != nil {
+		return fmt.Errorf("fail to run servant job(%s): %s", srvJob.GetName(), err)
+	}
+	klog.Infof("servant job(%s) has succeeded", srvJob.GetName())
 	return nil
 }
+
+// DeleteServantJobs deletes the servant jobs left on the nodes(e.g. the failed ones kept for
+// debugging), and waits for them to be gone, so the servant jobs can be run on the nodes again.
+func DeleteServantJobs(cliSet kubernetes.Interface, params ServantJobParams, nodeNames []string, timeout, period time.Duration) error {
+	var jobs []*batchv1.Job
+	for _, nodeName := range nodeNames {
+		srvJob, err := NewServantJob(params, nodeName)
+		if err != nil {
+			return err
+		}
+		err = cliSet.BatchV1().Jobs(srvJob.GetNamespace()).
+			Delete(srvJob.GetName(), &metav1.DeleteOptions{
+				PropagationPolicy: &PropagationPolicy,
+			})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("fail to delete servant job(%s): %s", srvJob.GetName(), err)
+		}
+		klog.Infof("servant job(%s) left by the last run is deleted", srvJob.GetName())
+		jobs = append(jobs, srvJob)
+	}
+
+	for _, job := range jobs {
+		err := wait.PollImmediate(period, timeout, func() (bool, error) {
+			_, err := cliSet.BatchV1().Jobs(job.GetNamespace()).Get(job.GetName(), metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				return true, nil
+			}
+			return false, nil
+		})
+		if err != nil {
+			return fmt.Errorf("servant job(%s) is not deleted in %v", job.GetName(), timeout)
+		}
+	}
+	return nil
+}
+
+// NewServantJob renders the servant job for the specified edge node,
+// the parameters are validated before rendering.
+func NewServantJob(params ServantJobParams, nodeName string) (*batchv1.Job, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if nodeName == "" {
+		return nil, errors.New("node name is not specified")
+	}
+	params.JobName = params.jobNameBase() + "-" + nodeName
+	params.NodeName = nodeName
+
+	jobYaml, err := tmplutil.SubsituteTemplate(constants.ServantJobTemplate, params)
+	if err != nil {
+		return nil, err
+	}
+	srvJobObj, err := YamlToObject([]byte(jobYaml))
+	if err != nil {
+		return nil, err
+	}
+	srvJob, ok := srvJobObj.(*batchv1.Job)
+	if !ok {
+		return nil, errors.New("fail to assert yurtctl-servant job")
+	}
+	return srvJob, nil
+}
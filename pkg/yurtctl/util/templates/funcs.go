@@ -0,0 +1,122 @@
+package templates
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// FuncMap returns the functions that can be used in templates, the functions
+// are compatible with the functions of the same name in sprig(the library of
+// template functions used by helm), so the templates can be shared.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"default":    defaultValue,
+		"empty":      empty,
+		"ternary":    ternary,
+		"indent":     indent,
+		"nindent":    nindent,
+		"b64enc":     b64enc,
+		"b64dec":     b64dec,
+		"quote":      quote,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"replace":    func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       join,
+		"list":       func(v ...interface{}) []interface{} { return v },
+	}
+}
+
+// defaultValue returns d if given is empty, like `{{ .Image | default "nginx" }}`
+func defaultValue(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || empty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+// empty checks the value is the zero value of its type, or an empty collection
+func empty(given interface{}) bool {
+	v := reflect.ValueOf(given)
+	if !v.IsValid() {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Struct:
+		return false
+	default:
+		return v.IsZero()
+	}
+}
+
+// ternary returns vt if cond is true, otherwise returns vf
+func ternary(vt, vf interface{}, cond bool) interface{} {
+	if cond {
+		return vt
+	}
+	return vf
+}
+
+// indent adds spaces to the beginning of each line of s
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
+
+// nindent is indent with a leading newline, so a block can be placed at a new line
+func nindent(spaces int, s string) string {
+	return "\n" + indent(spaces, s)
+}
+
+func b64enc(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func b64dec(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// quote wraps each of the values in double quotes
+func quote(v ...interface{}) string {
+	quoted := make([]string, 0, len(v))
+	for _, s := range v {
+		if s != nil {
+			quoted = append(quoted, fmt.Sprintf("%q", fmt.Sprint(s)))
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// join joins the elements of a list(like []string or the result of list) with sep
+func join(sep string, v interface{}) string {
+	switch l := v.(type) {
+	case []string:
+		return strings.Join(l, sep)
+	case []interface{}:
+		strs := make([]string, 0, len(l))
+		for _, s := range l {
+			strs = append(strs, fmt.Sprint(s))
+		}
+		return strings.Join(strs, sep)
+	default:
+		return fmt.Sprint(v)
+	}
+}
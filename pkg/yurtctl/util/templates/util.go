@@ -5,16 +5,47 @@ import (
 	"text/template"
 )
 
-// SubsituteTemplate fills out the kubeconfig templates based on the context
-func SubsituteTemplate(tmpl string, context interface{}) (string, error) {
-	t, tmplPrsErr := template.New("test").Option("missingkey=zero").Parse(tmpl)
-	if tmplPrsErr != nil {
-		return "", tmplPrsErr
+// Engine renders templates with the context
+type Engine interface {
+	Render(tmpl string, context interface{}) (string, error)
+}
+
+// textEngine renders templates by text/template, conditionals(if/else) and
+// loops(range) of text/template can be used, and the functions in funcs.
+type textEngine struct {
+	funcs template.FuncMap
+}
+
+// NewEngine creates an Engine that supports the functions of FuncMap, and the
+// extra funcs, which override the functions of the same name in FuncMap.
+func NewEngine(funcs template.FuncMap) Engine {
+	allFuncs := FuncMap()
+	for name, fn := range funcs {
+		allFuncs[name] = fn
 	}
+
+	return &textEngine{funcs: allFuncs}
+}
+
+// Render renders the template with the context
+func (e *textEngine) Render(tmpl string, context interface{}) (string, error) {
+	t, err := template.New("yurtctl").Option("missingkey=zero").Funcs(e.funcs).Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+
 	writer := bytes.NewBuffer([]byte{})
-	if err := t.Execute(writer, context); nil != err {
+	if err := t.Execute(writer, context); err != nil {
 		return "", err
 	}
 
 	return writer.String(), nil
 }
+
+// defaultEngine is the engine used by SubsituteTemplate
+var defaultEngine = NewEngine(nil)
+
+// SubsituteTemplate fills out the kubeconfig templates based on the context
+func SubsituteTemplate(tmpl string, context interface{}) (string, error) {
+	return defaultEngine.Render(tmpl, context)
+}
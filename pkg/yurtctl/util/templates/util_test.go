@@ -0,0 +1,100 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestSubsituteTemplate(t *testing.T) {
+	context := map[string]interface{}{
+		"name":    "yurt-hub",
+		"image":   "",
+		"arch":    "arm64",
+		"nodes":   []string{"node1", "node2"},
+		"content": "foo: bar\nbar: foo",
+	}
+
+	testcases := map[string]struct {
+		tmpl   string
+		expect string
+	}{
+		"field": {
+			tmpl:   "name: {{.name}}",
+			expect: "name: yurt-hub",
+		},
+		"default": {
+			tmpl:   "image: {{.image | default \"openyurt/yurt-hub:latest\"}}",
+			expect: "image: openyurt/yurt-hub:latest",
+		},
+		"default of missing key": {
+			tmpl:   "{{.missing | default \"none\"}}",
+			expect: "none",
+		},
+		"conditional": {
+			tmpl:   "{{if eq .arch \"amd64\"}}x86{{else}}{{.arch}}{{end}}",
+			expect: "arm64",
+		},
+		"loop": {
+			tmpl:   "{{range .nodes}}- {{.}}\n{{end}}",
+			expect: "- node1\n- node2\n",
+		},
+		"indent": {
+			tmpl:   "data:{{.content | nindent 2}}",
+			expect: "data:\n  foo: bar\n  bar: foo",
+		},
+		"b64enc": {
+			tmpl:   "{{.name | b64enc}}",
+			expect: "eXVydC1odWI=",
+		},
+		"b64dec": {
+			tmpl:   "{{\"eXVydC1odWI=\" | b64dec}}",
+			expect: "yurt-hub",
+		},
+		"quote": {
+			tmpl:   "{{.name | quote}}",
+			expect: "\"yurt-hub\"",
+		},
+		"join": {
+			tmpl:   "{{join \",\" .nodes}}",
+			expect: "node1,node2",
+		},
+		"ternary": {
+			tmpl:   "{{ternary \"edge\" \"cloud\" (hasPrefix \"node\" .name)}}",
+			expect: "cloud",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			got, err := SubsituteTemplate(tt.tmpl, context)
+			if err != nil {
+				t.Fatalf("fail to subsitute template %s: %s", tt.tmpl, err)
+			}
+			if got != tt.expect {
+				t.Errorf("expect %q, but got %q", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestEngineExtraFuncs(t *testing.T) {
+	e := NewEngine(template.FuncMap{
+		"upper": func(s string) string { return "overridden" },
+		"repeat": func(n int, s string) string {
+			return strings.Repeat(s, n)
+		},
+	})
+
+	got, err := e.Render("{{upper .}} {{repeat 3 .}}", "ab")
+	if err != nil {
+		t.Fatalf("fail to render template: %s", err)
+	}
+	if got != "overridden ababab" {
+		t.Errorf("expect %q, but got %q", "overridden ababab", got)
+	}
+
+	if _, err := e.Render("{{unknown .}}", "ab"); err == nil {
+		t.Errorf("expect error for unknown function")
+	}
+}
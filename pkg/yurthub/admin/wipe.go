@@ -0,0 +1,260 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// WipeSubresource is the subresource of node that the user needs to be allowed
+	// to create, like: {verbs: ["create"], resources: ["nodes/wipe"]}
+	WipeSubresource = "wipe"
+	// WipedReason is the reason of the event that audits the wipe of node
+	WipedReason = "YurtHubWiped"
+	// kubeletPairFiles are the client certificates of kubelet that are used by yurthub
+	// in kubelet cert manage mode, they are removed when credentials are wiped.
+	kubeletPairFiles = "/var/lib/kubelet/pki/kubelet-client-*.pem"
+	// storageTimeout bounds the time of wiping the cache
+	storageTimeout = time.Minute
+)
+
+// WipeResult is the result of wiping the cache and credentials of yurthub
+type WipeResult struct {
+	User         string    `json:"user"`
+	WipedAt      time.Time `json:"wipedAt"`
+	WipedKeys    int       `json:"wipedKeys"`
+	RemovedFiles []string  `json:"removedFiles,omitempty"`
+	Errors       []string  `json:"errors,omitempty"`
+}
+
+// Wiper wipes the local cache and credentials of yurthub for the authenticated and
+// authorized user in cloud, so an edge node can be reset(like for RMA or decommission)
+// without physical access. the user is authenticated by TokenReview and authorized by
+// SubjectAccessReview in kube-apiserver, and the wipe is audited by an event of the node,
+// so requests are rejected when cloud is unreachable.
+type Wiper struct {
+	sync.Mutex
+	nodeName        string
+	store           storage.Store
+	newClient       func() (clientset.Interface, error)
+	credentialFiles []string
+}
+
+// NewWiper creates a Wiper that uses the client of transportManager to talk with kube-apiserver
+func NewWiper(cfg *config.YurtHubConfiguration, store storage.Store, transportManager transport.Interface) *Wiper {
+	w := &Wiper{
+		nodeName: cfg.NodeName,
+		store:    store,
+		newClient: func() (clientset.Interface, error) {
+			restCfg := transportManager.GetRestClientConfig()
+			if restCfg == nil {
+				return nil, fmt.Errorf("rest config is not prepared")
+			}
+			return clientset.NewForConfig(restCfg)
+		},
+	}
+	// credentials of exec cert manage mode are in storage, they are wiped with the cache
+	if cfg.CertMgrMode == "kubelet" {
+		w.credentialFiles = []string{kubeletPairFiles}
+	}
+	return w
+}
+
+// ServeHTTP wipes the cache, and the credentials as well if query credentials=true.
+// the bearer token of request is used to identify the user.
+func (w *Wiper) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	token := bearerToken(req)
+	if len(token) == 0 {
+		http.Error(rw, "bearer token is required", http.StatusUnauthorized)
+		return
+	}
+	credentials := req.URL.Query().Get("credentials") == "true"
+
+	client, err := w.newClient()
+	if err != nil {
+		klog.Errorf("failed to create client for wipe, %v", err)
+		http.Error(rw, fmt.Sprintf("failed to create client, %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	user, err := w.authenticate(client, token)
+	if err != nil {
+		klog.Warningf("reject wipe request from %s, %v", req.RemoteAddr, err)
+		http.Error(rw, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := w.authorize(client, user); err != nil {
+		klog.Warningf("reject wipe request of user %s, %v", user.Username, err)
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	// the event is recorded before wiping, the credentials to record it may be removed
+	if err := w.audit(client, user.Username, credentials); err != nil {
+		klog.Errorf("failed to audit wipe of user %s, %v", user.Username, err)
+		http.Error(rw, fmt.Sprintf("failed to audit wipe, %v", err), http.StatusServiceUnavailable)
+		return
+	}
+
+	result := w.wipe(user.Username, credentials)
+	klog.Infof("cache(credentials: %v) is wiped by user %s, %d keys are wiped, removed files: %v, errors: %v",
+		credentials, user.Username, result.WipedKeys, result.RemovedFiles, result.Errors)
+
+	b, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("failed to encode wipe result, %v", err), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if len(result.Errors) != 0 {
+		rw.WriteHeader(http.StatusInternalServerError)
+	} else {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.Write(b)
+}
+
+func bearerToken(req *http.Request) string {
+	auth := strings.TrimSpace(req.Header.Get("Authorization"))
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
+
+func (w *Wiper) authenticate(client clientset.Interface, token string) (*authenticationv1.UserInfo, error) {
+	review, err := client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to review token, %v", err)
+	}
+	if !review.Status.Authenticated {
+		return nil, fmt.Errorf("token is not authenticated, %s", review.Status.Error)
+	}
+	return &review.Status.User, nil
+}
+
+func (w *Wiper) authorize(client clientset.Interface, user *authenticationv1.UserInfo) error {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := client.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Verb:        "create",
+				Resource:    "nodes",
+				Subresource: WipeSubresource,
+				Name:        w.nodeName,
+			},
+			User:   user.Username,
+			Groups: user.Groups,
+			UID:    user.UID,
+			Extra:  extra,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to review access, %v", err)
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("user %s is not allowed to create nodes/%s of %s, %s", user.Username, WipeSubresource, w.nodeName, review.Status.Reason)
+	}
+	return nil
+}
+
+// audit records an event of the node for the wipe
+func (w *Wiper) audit(client clientset.Interface, username string, credentials bool) error {
+	now := metav1.Now()
+	target := "cache is"
+	if credentials {
+		target = "cache and credentials are"
+	}
+	_, err := client.CoreV1().Events(metav1.NamespaceDefault).Create(&v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: w.nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Node", Name: w.nodeName},
+		Reason:         WipedReason,
+		Message:        fmt.Sprintf("yurthub %s wiped by user %s", target, username),
+		Source:         v1.EventSource{Component: "yurthub", Host: w.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           v1.EventTypeWarning,
+	})
+	return err
+}
+
+// wipe deletes all keys in storage, including the internal keys of yurthub, and removes
+// the credential files if credentials is true. it continues on errors, so as much as
+// possible is wiped, and the errors are returned in the result.
+func (w *Wiper) wipe(username string, credentials bool) *WipeResult {
+	result := &WipeResult{User: username, WipedAt: time.Now()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	keys, err := w.store.ListKeys(ctx, "")
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to list keys, %v", err))
+	}
+	roots := make(map[string]bool)
+	for _, key := range keys {
+		comp, _, _, _ := util.SplitKey(strings.Trim(key, "/"))
+		if len(comp) != 0 && !roots[comp] {
+			roots[comp] = true
+			if err := w.store.DeleteCollection(ctx, comp, true); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to delete %s, %v", comp, err))
+			}
+		}
+	}
+	remained, err := w.store.ListKeys(ctx, "")
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to list keys, %v", err))
+	}
+	result.WipedKeys = len(keys) - len(remained)
+
+	if !credentials {
+		return result
+	}
+	for _, pattern := range w.credentialFiles {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to find %s, %v", pattern, err))
+			continue
+		}
+		for _, f := range files {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				result.Errors = append(result.Errors, fmt.Sprintf("failed to remove %s, %v", f, err))
+				continue
+			}
+			result.RemovedFiles = append(result.RemovedFiles, f)
+		}
+	}
+	return result
+}
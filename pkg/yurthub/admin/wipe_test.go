@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientset "k8s.io/client-go/kubernetes"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func newFakeClient(allowed bool, events *[]*v1.Event) clientset.Interface {
+	client := fakeclient.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "admin-token" {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: "admin", Groups: []string{"system:masters"}}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		review.Status.Allowed = allowed && attrs.Resource == "nodes" && attrs.Subresource == WipeSubresource && attrs.Name == "node1"
+		return true, review, nil
+	})
+	client.PrependReactor("create", "events", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		event := action.(clienttesting.CreateAction).GetObject().(*v1.Event)
+		*events = append(*events, event)
+		return true, event, nil
+	})
+	return client
+}
+
+func TestWipe(t *testing.T) {
+	testcases := map[string]struct {
+		token       string
+		allowed     bool
+		credentials bool
+		code        int
+		wiped       bool
+	}{
+		"no token": {
+			code: http.StatusUnauthorized,
+		},
+		"invalid token": {
+			token: "invalid-token",
+			code:  http.StatusUnauthorized,
+		},
+		"not allowed": {
+			token: "admin-token",
+			code:  http.StatusForbidden,
+		},
+		"wipe cache": {
+			token:   "admin-token",
+			allowed: true,
+			code:    http.StatusOK,
+			wiped:   true,
+		},
+		"wipe cache and credentials": {
+			token:       "admin-token",
+			allowed:     true,
+			credentials: true,
+			code:        http.StatusOK,
+			wiped:       true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "yurthub-wipe")
+			if err != nil {
+				t.Fatalf("failed to create temp dir, %v", err)
+			}
+			defer os.RemoveAll(dir)
+			pairFile := filepath.Join(dir, "kubelet-client-current.pem")
+			ioutil.WriteFile(pairFile, []byte("pair"), 0600)
+
+			ctx := context.Background()
+			store, _ := fake.NewFakeStorage()
+			store.Create(ctx, "kubelet/pods/default/pod1", []byte("pod1"))
+			store.Create(ctx, "kube-proxy/services/default/svc1", []byte("svc1"))
+			store.Create(ctx, "_internal/exec-credential/credential.json", []byte("credential"))
+
+			var events []*v1.Event
+			client := newFakeClient(tt.allowed, &events)
+			w := &Wiper{
+				nodeName:        "node1",
+				store:           store,
+				newClient:       func() (clientset.Interface, error) { return client, nil },
+				credentialFiles: []string{filepath.Join(dir, "kubelet-client-*.pem")},
+			}
+
+			url := "/v1/admin/wipe"
+			if tt.credentials {
+				url += "?credentials=true"
+			}
+			req := httptest.NewRequest("POST", url, nil)
+			if len(tt.token) != 0 {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rw := httptest.NewRecorder()
+			w.ServeHTTP(rw, req)
+
+			if rw.Code != tt.code {
+				t.Errorf("expect code %d, but got %d: %s", tt.code, rw.Code, rw.Body.String())
+			}
+			keys, _ := store.ListKeys(ctx, "")
+			if tt.wiped != (len(keys) == 0) {
+				t.Errorf("expect wiped %v, but got keys %v", tt.wiped, keys)
+			}
+			if tt.wiped != (len(events) == 1) {
+				t.Errorf("expect wipe is audited %v, but got events %v", tt.wiped, events)
+			} else if tt.wiped && (events[0].Reason != WipedReason || events[0].InvolvedObject.Name != "node1") {
+				t.Errorf("unexpected audit event %#v", events[0])
+			}
+			if _, err := os.Stat(pairFile); tt.credentials != os.IsNotExist(err) {
+				t.Errorf("expect credentials are removed %v, but got %v", tt.credentials, err)
+			}
+		})
+	}
+}
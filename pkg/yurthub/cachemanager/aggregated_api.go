@@ -0,0 +1,385 @@
+package cachemanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+)
+
+// aggregatedAPIPrefix is the key prefix for raw responses of aggregated apis.
+// raw responses are kept apart from the component/resource layout, because
+// aggregated apis can use the same resource names as built-in apis
+// (like nodes and pods of metrics.k8s.io).
+const aggregatedAPIPrefix = "_aggregated"
+
+// listChunksSuffix is appended to the key of raw response for the directory
+// that chunks of a large list are stored in.
+const listChunksSuffix = ".chunks"
+
+// rawResponse is the format that a response of aggregated api is stored.
+// for a json list that has more items than the list chunk size, Body is the
+// list without items and the items are stored in Chunks, so an update of the
+// list only writes the chunks that have changed.
+type rawResponse struct {
+	ContentType string     `json:"contentType"`
+	Body        []byte     `json:"body"`
+	Chunks      []rawChunk `json:"chunks,omitempty"`
+}
+
+// rawChunk is the index entry of a chunk of list items. chunks are named by the
+// hash of contents, so a chunk is never rewritten in place and the index can be
+// replaced atomically. the list is always read with all of its chunks.
+type rawChunk struct {
+	Hash string `json:"hash"`
+}
+
+// IsExtensionAPI checks the request is for an api that is not built in kubernetes,
+// like aggregated apis(metrics.k8s.io, custom aggregators) or crds. because the
+// objects of these apis can not be decoded by yurthub, they are passed through
+// to kube-apiserver and only cached as raw responses if the group is allowed.
+func IsExtensionAPI(info *apirequest.RequestInfo) bool {
+	if info == nil || !info.IsResourceRequest {
+		return false
+	}
+
+	return !scheme.Scheme.IsVersionRegistered(schema.GroupVersion{
+		Group:   info.APIGroup,
+		Version: info.APIVersion,
+	})
+}
+
+// canCacheAggregatedAPI checks response of aggregated api request can be cached or not,
+// only get/list request for the group in the allow-list can be cached.
+func (ecm *cacheManager) canCacheAggregatedAPI(info *apirequest.RequestInfo) bool {
+	if info.Verb != "get" && info.Verb != "list" {
+		return false
+	}
+
+	ecm.RLock()
+	defer ecm.RUnlock()
+	return ecm.aggregatedAPIGroups[info.APIGroup] || ecm.pinnedAPIGroups[info.APIGroup]
+}
+
+// UpdateAggregatedAPIGroups resets the allow-list of aggregated api groups
+// that responses can be cached.
+func (ecm *cacheManager) UpdateAggregatedAPIGroups(groups []string) {
+	aggregatedAPIGroups := make(map[string]bool)
+	for _, group := range groups {
+		aggregatedAPIGroups[group] = true
+	}
+
+	ecm.Lock()
+	defer ecm.Unlock()
+	ecm.aggregatedAPIGroups = aggregatedAPIGroups
+}
+
+// ignoredQueryParams are the query parameters that do not change the result set
+// of a get/list request, so they are left out of the key of raw response.
+var ignoredQueryParams = []string{"timeout", "timeoutSeconds", "resourceVersion", "resourceVersionMatch"}
+
+// PinAggregatedAPIGroups makes responses of the aggregated api groups always cacheable,
+// it's used by the hub modules(like metrics shim) that serve the cached responses.
+func (ecm *cacheManager) PinAggregatedAPIGroups(groups ...string) {
+	ecm.Lock()
+	defer ecm.Unlock()
+	for _, group := range groups {
+		ecm.pinnedAPIGroups[group] = true
+	}
+}
+
+// aggregatedAPIKey returns the key of raw response for the request, responses
+// are cached per request path and query parameters(like label selector), so lists
+// with different selectors or limits do not overwrite each other. the query is
+// canonicalized and hashed to keep the key short.
+func aggregatedAPIKey(comp string, info *apirequest.RequestInfo, rawQuery string) (string, error) {
+	if comp == "" || info.Path == "" {
+		return "", fmt.Errorf("aggregatedAPIKey: comp, path can not be empty")
+	}
+
+	path := info.Path
+	if query := canonicalQuery(rawQuery); query != "" {
+		sum := sha256.Sum256([]byte(query))
+		path = path + "?" + hex.EncodeToString(sum[:8])
+	}
+
+	return filepath.Join(comp, aggregatedAPIPrefix, url.PathEscape(path)), nil
+}
+
+// canonicalQuery returns the query with sorted parameters and values, the parameters
+// in ignoredQueryParams are removed.
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// the unparsed query is still a valid key, only equal queries share the response
+		return rawQuery
+	}
+
+	for _, param := range ignoredQueryParams {
+		query.Del(param)
+	}
+	for param := range query {
+		sort.Strings(query[param])
+	}
+
+	return query.Encode()
+}
+
+// listChunkKey returns the key of list chunk with hash for the raw response of key
+func listChunkKey(key, hash string) string {
+	return filepath.Join(key+listChunksSuffix, hash)
+}
+
+// splitList splits the items of json list in body into chunks of chunkSize items,
+// and returns the list without items. ok is false if body is not a json list or
+// the items of list can be held in one chunk.
+func splitList(body []byte, chunkSize int) (list []byte, chunks [][]byte, ok bool, err error) {
+	if chunkSize <= 0 {
+		return nil, nil, false, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil, false, nil
+	}
+
+	var items []json.RawMessage
+	if rawItems, exists := fields["items"]; !exists {
+		return nil, nil, false, nil
+	} else if err := json.Unmarshal(rawItems, &items); err != nil || len(items) <= chunkSize {
+		return nil, nil, false, nil
+	}
+
+	delete(fields, "items")
+	list, err = json.Marshal(fields)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	for start := 0; start < len(items); start += chunkSize {
+		end := start + chunkSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		chunk, err := json.Marshal(items[start:end])
+		if err != nil {
+			return nil, nil, false, err
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	return list, chunks, true, nil
+}
+
+// joinList puts the items in chunks back into the json list
+func joinList(list []byte, chunks [][]byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(list, &fields); err != nil {
+		return nil, err
+	}
+
+	items := make([]json.RawMessage, 0)
+	for _, chunk := range chunks {
+		var chunkItems []json.RawMessage
+		if err := json.Unmarshal(chunk, &chunkItems); err != nil {
+			return nil, err
+		}
+		items = append(items, chunkItems...)
+	}
+
+	rawItems, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	fields["items"] = rawItems
+
+	return json.Marshal(fields)
+}
+
+func chunkHash(chunk []byte) string {
+	sum := sha256.Sum256(chunk)
+	return hex.EncodeToString(sum[:])
+}
+
+func (em *cacheManager) saveRawResponse(ctx context.Context, info *apirequest.RequestInfo, prc io.ReadCloser) error {
+	var buf bytes.Buffer
+	n, err := buf.ReadFrom(prc)
+	if err != nil {
+		klog.Errorf("failed to cache raw response, %v", err)
+		return err
+	} else if n == 0 {
+		err := fmt.Errorf("read 0-length data from response, %s", util.ReqInfoString(info))
+		klog.Errorf("failed to cache raw response, %v", err)
+		return err
+	}
+
+	comp, _ := util.ClientComponentFrom(ctx)
+	rawQuery, _ := util.ReqQueryFrom(ctx)
+	key, err := aggregatedAPIKey(comp, info, rawQuery)
+	if err != nil {
+		return err
+	}
+
+	respContentType, _ := util.RespContentTypeFrom(ctx)
+	resp := &rawResponse{
+		ContentType: respContentType,
+		Body:        buf.Bytes(),
+	}
+
+	var chunks [][]byte
+	if strings.HasPrefix(respContentType, runtime.ContentTypeJSON) {
+		list, listChunks, ok, err := splitList(buf.Bytes(), em.listChunkSize)
+		if err != nil {
+			klog.Errorf("failed to split list of raw response for %s, %v", util.ReqInfoString(info), err)
+			return err
+		} else if ok {
+			resp.Body = list
+			chunks = listChunks
+			for i := range chunks {
+				resp.Chunks = append(resp.Chunks, rawChunk{Hash: chunkHash(chunks[i])})
+			}
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	klog.V(5).Infof("cache %d bytes of raw response in %d chunks for %s", n, len(chunks), util.ReqInfoString(info))
+	storageCtx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	em.listChunkLock.Lock()
+	defer em.listChunkLock.Unlock()
+	// chunks that are referenced by the index in storage are not changed,
+	// only the new chunks are written before the index is replaced.
+	stored := make(map[string]bool)
+	if old, err := em.getRawResponse(storageCtx, key); err == nil {
+		for _, chunk := range old.Chunks {
+			stored[chunk.Hash] = true
+		}
+	}
+
+	for i := range chunks {
+		hash := resp.Chunks[i].Hash
+		if stored[hash] {
+			continue
+		}
+		if err := em.storage.UpdateRaw(storageCtx, listChunkKey(key, hash), chunks[i]); err != nil {
+			klog.Errorf("failed to cache list chunk %s for %s, %v", hash, util.ReqInfoString(info), err)
+			return err
+		}
+		stored[hash] = true
+	}
+
+	if err := em.storage.UpdateRaw(storageCtx, key, b); err != nil {
+		return err
+	}
+
+	em.deleteUnusedChunks(storageCtx, key, resp.Chunks)
+	return nil
+}
+
+// deleteUnusedChunks deletes the chunks of key that are not in the index any more
+func (em *cacheManager) deleteUnusedChunks(ctx context.Context, key string, chunks []rawChunk) {
+	used := make(map[string]bool, len(chunks))
+	for _, chunk := range chunks {
+		used[chunk.Hash] = true
+	}
+
+	chunkKeys, err := em.storage.ListKeys(ctx, key+listChunksSuffix)
+	if err != nil {
+		klog.Warningf("failed to list chunks of %s, %v", key, err)
+		return
+	}
+
+	for _, chunkKey := range chunkKeys {
+		if used[filepath.Base(chunkKey)] {
+			continue
+		}
+		if err := em.storage.Delete(ctx, chunkKey); err != nil {
+			klog.Warningf("failed to delete unused chunk %s, %v", chunkKey, err)
+		}
+	}
+}
+
+// getRawResponse gets the stored raw response of key, chunks of list are not read.
+func (em *cacheManager) getRawResponse(ctx context.Context, key string) (*rawResponse, error) {
+	b, err := em.storage.GetRaw(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp rawResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		klog.Errorf("failed to decode raw response for %s, %v", key, err)
+		return nil, storage.ErrCorrupted
+	}
+
+	return &resp, nil
+}
+
+func (em *cacheManager) queryRawResponse(ctx context.Context, comp string, info *apirequest.RequestInfo, rawQuery string) (runtime.Object, error) {
+	key, err := aggregatedAPIKey(comp, info, rawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	em.listChunkLock.RLock()
+	defer em.listChunkLock.RUnlock()
+	resp, err := em.getRawResponse(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := resp.Body
+	if len(resp.Chunks) != 0 {
+		chunks := make([][]byte, 0, len(resp.Chunks))
+		for _, chunk := range resp.Chunks {
+			b, err := em.storage.GetRaw(ctx, listChunkKey(key, chunk.Hash))
+			if err == storage.ErrNotFound {
+				klog.Errorf("list chunk %s of %s is missing", chunk.Hash, key)
+				return nil, storage.ErrCorrupted
+			} else if err != nil {
+				return nil, err
+			} else if chunkHash(b) != chunk.Hash {
+				klog.Errorf("list chunk %s of %s is corrupted", chunk.Hash, key)
+				return nil, storage.ErrCorrupted
+			}
+			chunks = append(chunks, b)
+		}
+
+		body, err = joinList(resp.Body, chunks)
+		if err != nil {
+			klog.Errorf("failed to join list chunks of %s, %v", key, err)
+			return nil, storage.ErrCorrupted
+		}
+	}
+
+	return &runtime.Unknown{
+		Raw:         body,
+		ContentType: resp.ContentType,
+	}, nil
+}
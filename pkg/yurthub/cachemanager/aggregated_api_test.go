@@ -0,0 +1,268 @@
+package cachemanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+)
+
+const chunkedListKey = "kubelet/_aggregated/%2Fapis%2Fcustom.metrics.k8s.io%2Fv1beta1%2Fpods"
+
+func metricsList(values ...string) []byte {
+	items := make([]string, 0, len(values))
+	for i, value := range values {
+		items = append(items, fmt.Sprintf(`{"metadata":{"name":"pod-%d"},"value":"%s"}`, i, value))
+	}
+	return []byte(fmt.Sprintf(`{"kind":"MetricValueList","apiVersion":"custom.metrics.k8s.io/v1beta1","metadata":{},"items":[%s]}`, strings.Join(items, ",")))
+}
+
+func serveAggregatedAPI(cm CacheManager, fn func(req *http.Request)) {
+	serveAggregatedAPIWithQuery(cm, "", fn)
+}
+
+func serveAggregatedAPIWithQuery(cm CacheManager, rawQuery string, fn func(req *http.Request)) {
+	req, _ := http.NewRequest("GET", "/apis/custom.metrics.k8s.io/v1beta1/pods?"+rawQuery, nil)
+	req.Header.Set("User-Agent", "kubelet")
+	req.Header.Set("Accept", "application/json")
+	req.RemoteAddr = "127.0.0.1"
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fn(req)
+	})
+	handler = proxyutil.WithRequestContentType(handler)
+	handler = proxyutil.WithRequestClientComponent(handler)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func cacheList(cm CacheManager, body []byte) error {
+	return cacheListWithQuery(cm, "", body)
+}
+
+func cacheListWithQuery(cm CacheManager, rawQuery string, body []byte) error {
+	var err error
+	serveAggregatedAPIWithQuery(cm, rawQuery, func(req *http.Request) {
+		ctx := util.WithRespContentType(req.Context(), "application/json")
+		ctx = util.WithReqQuery(ctx, req.URL.RawQuery)
+		err = cm.CacheResponse(ctx, ioutil.NopCloser(bytes.NewBuffer(body)), nil)
+	})
+	return err
+}
+
+func queryList(cm CacheManager) ([]byte, error) {
+	return queryListWithQuery(cm, "")
+}
+
+func queryListWithQuery(cm CacheManager, rawQuery string) ([]byte, error) {
+	var obj runtime.Object
+	var err error
+	serveAggregatedAPIWithQuery(cm, rawQuery, func(req *http.Request) {
+		obj, err = cm.QueryCache(req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return obj.(*runtime.Unknown).Raw, nil
+}
+
+func sameJSON(a, b []byte) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestCacheAggregatedAPIListInChunks(t *testing.T) {
+	testcases := map[string]struct {
+		chunkSize    int
+		lists        [][]byte
+		expectChunks int
+		// expectWritten is the number of chunks written by the last list
+		expectWritten int
+	}{
+		"chunks are disabled": {
+			chunkSize:    0,
+			lists:        [][]byte{metricsList("1", "2", "3", "4", "5")},
+			expectChunks: 0,
+		},
+		"list is held in one chunk": {
+			chunkSize:    5,
+			lists:        [][]byte{metricsList("1", "2", "3", "4", "5")},
+			expectChunks: 0,
+		},
+		"list is split into chunks": {
+			chunkSize:     2,
+			lists:         [][]byte{metricsList("1", "2", "3", "4", "5")},
+			expectChunks:  3,
+			expectWritten: 3,
+		},
+		"only changed chunk is written": {
+			chunkSize:     2,
+			lists:         [][]byte{metricsList("1", "2", "3", "4", "5"), metricsList("1", "2", "3", "40", "5")},
+			expectChunks:  3,
+			expectWritten: 1,
+		},
+		"unused chunks are deleted": {
+			chunkSize:     2,
+			lists:         [][]byte{metricsList("1", "2", "3", "4", "5"), metricsList("1", "2", "3")},
+			expectChunks:  2,
+			expectWritten: 1,
+		},
+		"list is not split any more": {
+			chunkSize:    2,
+			lists:        [][]byte{metricsList("1", "2", "3", "4", "5"), metricsList("1")},
+			expectChunks: 0,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			store, err := disk.NewDiskStorage(t.TempDir())
+			if err != nil {
+				t.Fatalf("failed to create disk storage, %v", err)
+			}
+			sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+			cm, _ := NewCacheManager(sw, serializer.NewSerializerManager(), []string{"custom.metrics.k8s.io"}, tt.chunkSize)
+
+			var before []string
+			for _, list := range tt.lists {
+				before, _ = sw.ListKeys(context.Background(), chunkedListKey+listChunksSuffix)
+				if err := cacheList(cm, list); err != nil {
+					t.Fatalf("failed to cache list, %v", err)
+				}
+			}
+
+			chunks, _ := sw.ListKeys(context.Background(), chunkedListKey+listChunksSuffix)
+			if len(chunks) != tt.expectChunks {
+				t.Errorf("Got %d chunks, but expect %d chunks", len(chunks), tt.expectChunks)
+			}
+
+			written := 0
+			for _, chunk := range chunks {
+				found := false
+				for _, old := range before {
+					found = found || old == chunk
+				}
+				if !found {
+					written++
+				}
+			}
+			if written != tt.expectWritten {
+				t.Errorf("Got %d chunks written, but expect %d chunks", written, tt.expectWritten)
+			}
+
+			body, err := queryList(cm)
+			if err != nil {
+				t.Fatalf("failed to query list, %v", err)
+			}
+			if expect := tt.lists[len(tt.lists)-1]; !sameJSON(body, expect) {
+				t.Errorf("Got list %s, but expect %s", string(body), string(expect))
+			}
+		})
+	}
+}
+
+func TestQueryAggregatedAPIListWithBrokenChunk(t *testing.T) {
+	store, err := disk.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+	cm, _ := NewCacheManager(sw, serializer.NewSerializerManager(), []string{"custom.metrics.k8s.io"}, 2)
+
+	if err := cacheList(cm, metricsList("1", "2", "3")); err != nil {
+		t.Fatalf("failed to cache list, %v", err)
+	}
+
+	chunks, _ := sw.ListKeys(context.Background(), chunkedListKey+listChunksSuffix)
+	if len(chunks) != 2 {
+		t.Fatalf("Got %d chunks, but expect 2 chunks", len(chunks))
+	}
+	if err := sw.UpdateRaw(context.Background(), chunks[0], []byte(`[]`)); err != nil {
+		t.Fatalf("failed to update chunk, %v", err)
+	}
+
+	if _, err := queryList(cm); err != storage.ErrCorrupted {
+		t.Errorf("Got error %v, but expect %v", err, storage.ErrCorrupted)
+	}
+
+	if err := sw.Delete(context.Background(), chunks[0]); err != nil {
+		t.Fatalf("failed to delete chunk, %v", err)
+	}
+
+	if _, err := queryList(cm); err != storage.ErrCorrupted {
+		t.Errorf("Got error %v, but expect %v", err, storage.ErrCorrupted)
+	}
+}
+
+func TestCacheAggregatedAPIListsWithDifferentQueries(t *testing.T) {
+	store, err := disk.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+	cm, _ := NewCacheManager(sw, serializer.NewSerializerManager(), []string{"custom.metrics.k8s.io"}, 0)
+
+	all := metricsList("1", "2", "3")
+	filtered := metricsList("1")
+	if err := cacheList(cm, all); err != nil {
+		t.Fatalf("failed to cache list, %v", err)
+	}
+	if err := cacheListWithQuery(cm, "labelSelector=app%3Dx&limit=500&resourceVersion=10", filtered); err != nil {
+		t.Fatalf("failed to cache filtered list, %v", err)
+	}
+
+	testcases := map[string]struct {
+		rawQuery string
+		expect   []byte
+	}{
+		"unfiltered list": {
+			expect: all,
+		},
+		"unfiltered list with ignored params": {
+			rawQuery: "timeout=30s&resourceVersion=0",
+			expect:   all,
+		},
+		"filtered list": {
+			rawQuery: "labelSelector=app%3Dx&limit=500",
+			expect:   filtered,
+		},
+		"filtered list with params in another order": {
+			rawQuery: "limit=500&resourceVersion=20&labelSelector=app%3Dx",
+			expect:   filtered,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			body, err := queryListWithQuery(cm, tt.rawQuery)
+			if err != nil {
+				t.Fatalf("failed to query list, %v", err)
+			}
+			if !sameJSON(body, tt.expect) {
+				t.Errorf("Got list %s, but expect %s", string(body), string(tt.expect))
+			}
+		})
+	}
+
+	if _, err := queryListWithQuery(cm, "labelSelector=app%3Dy"); err != storage.ErrNotFound {
+		t.Errorf("Got error %v for list with uncached selector, but expect %v", err, storage.ErrNotFound)
+	}
+}
@@ -1,9 +1,11 @@
 package cachemanager
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog"
@@ -55,11 +57,13 @@ var (
 
 func (ecm *cacheManager) initCacheAgents() error {
 	agents := make([]string, 0)
-	b, err := ecm.storage.GetRaw(cacheAgentsKey)
-	if err == nil && len(b) != 0 {
+	b, err := ecm.storage.GetRaw(context.Background(), cacheAgentsKey)
+	if err != nil && err != storage.ErrNotFound {
+		klog.Warningf("failed to get cache agents from storage, %v, and use default cache agents", err)
+	} else if err == nil && len(b) != 0 {
 		localAgents := strings.Split(string(b), sepForAgent)
-		if len(localAgents) < len(defaultCacheAgents) {
-			err = ecm.storage.Delete(cacheAgentsKey)
+		if len(localAgents) < len(defaultCacheAgents) && !ecm.readOnly {
+			err = ecm.storage.Delete(context.Background(), cacheAgentsKey)
 			if err != nil {
 				klog.Errorf("failed to delete agents cache, %v", err)
 				return err
@@ -83,7 +87,10 @@ func (ecm *cacheManager) initCacheAgents() error {
 	}
 
 	klog.Infof("reset cache agents to %v", agents)
-	return ecm.storage.UpdateRaw(cacheAgentsKey, []byte(strings.Join(agents, sepForAgent)))
+	if ecm.readOnly {
+		return nil
+	}
+	return ecm.storage.UpdateRaw(context.Background(), cacheAgentsKey, []byte(strings.Join(agents, sepForAgent)))
 }
 
 func (ecm *cacheManager) UpdateCacheAgents(agents []string) error {
@@ -118,7 +125,10 @@ func (ecm *cacheManager) UpdateCacheAgents(agents []string) error {
 		for _, agent := range agents {
 			ecm.cacheAgents[agent] = false
 		}
-		return ecm.storage.UpdateRaw(cacheAgentsKey, []byte(strings.Join(updatedAgents, sepForAgent)))
+		if ecm.readOnly {
+			return nil
+		}
+		return ecm.storage.UpdateRaw(context.Background(), cacheAgentsKey, []byte(strings.Join(updatedAgents, sepForAgent)))
 	}
 	return nil
 }
@@ -139,6 +149,7 @@ func (ecm *cacheManager) ListCacheAgents() []string {
 // 2. delete/deletecollection/proxy request
 // 3. sub-resource request but is not status
 // 4. csr resource request
+// 5. aggregated api request but the group is not in the allow-list
 func (ecm *cacheManager) CanCacheFor(req *http.Request) bool {
 	ctx := req.Context()
 	comp, ok := util.ClientComponentFrom(ctx)
@@ -175,6 +186,10 @@ func (ecm *cacheManager) CanCacheFor(req *http.Request) bool {
 		return false
 	}
 
+	if IsExtensionAPI(info) {
+		return ecm.canCacheAggregatedAPI(info)
+	}
+
 	if _, ok := ResourceToKindMap[info.Resource]; !ok {
 		return false
 	}
@@ -1,6 +1,7 @@
 package cachemanager
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -14,10 +15,10 @@ import (
 
 func TestInitCacheAgents(t *testing.T) {
 	s := NewFakeStorageWrapper()
-	m, _ := NewCacheManager(s, nil)
+	m, _ := NewCacheManager(s, nil, nil, 0)
 
 	// default cache agents in fake store
-	b, err := s.GetRaw(cacheAgentsKey)
+	b, err := s.GetRaw(context.Background(), cacheAgentsKey)
 	if err != nil {
 		t.Fatalf("failed to get agents, %v", err)
 	}
@@ -34,9 +35,9 @@ func TestInitCacheAgents(t *testing.T) {
 	// add agents for next init cache
 	_ = m.UpdateCacheAgents([]string{"agent1"})
 
-	_, _ = NewCacheManager(s, nil)
+	_, _ = NewCacheManager(s, nil, nil, 0)
 
-	b2, err := s.GetRaw(cacheAgentsKey)
+	b2, err := s.GetRaw(context.Background(), cacheAgentsKey)
 	if err != nil {
 		t.Fatalf("failed to get agents, %v", err)
 	}
@@ -54,7 +55,7 @@ func TestInitCacheAgents(t *testing.T) {
 
 func TestUpdateCacheAgents(t *testing.T) {
 	s := NewFakeStorageWrapper()
-	m, _ := NewCacheManager(s, nil)
+	m, _ := NewCacheManager(s, nil, nil, 0)
 
 	tests := []struct {
 		desc         string
@@ -74,7 +75,7 @@ func TestUpdateCacheAgents(t *testing.T) {
 				t.Fatalf("failed to add cache agents, %v", err)
 			}
 
-			b, err := s.GetRaw(cacheAgentsKey)
+			b, err := s.GetRaw(context.Background(), cacheAgentsKey)
 			if err != nil {
 				t.Fatalf("failed to get agents, %v", err)
 			}
@@ -122,7 +123,7 @@ func newTestRequestInfoResolver() *request.RequestInfoFactory {
 
 func TestCanCacheFor(t *testing.T) {
 	s := NewFakeStorageWrapper()
-	m, _ := NewCacheManager(s, nil)
+	m, _ := NewCacheManager(s, nil, nil, 0)
 
 	tests := []struct {
 		desc        string
@@ -275,3 +276,67 @@ func TestCanCacheFor(t *testing.T) {
 		})
 	}
 }
+
+func TestCanCacheForAggregatedAPI(t *testing.T) {
+	s := NewFakeStorageWrapper()
+	m, _ := NewCacheManager(s, nil, []string{"metrics.k8s.io"}, 0)
+
+	tests := []struct {
+		desc        string
+		userAgent   string
+		verb        string
+		path        string
+		expectCache bool
+	}{
+		{
+			desc:        "list allowed aggregated api",
+			userAgent:   "kubelet",
+			verb:        "GET",
+			path:        "/apis/metrics.k8s.io/v1beta1/nodes",
+			expectCache: true,
+		},
+		{
+			desc:        "get allowed aggregated api",
+			userAgent:   "kubelet",
+			verb:        "GET",
+			path:        "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods/test",
+			expectCache: true,
+		},
+		{
+			desc:        "watch allowed aggregated api",
+			userAgent:   "kubelet",
+			verb:        "GET",
+			path:        "/apis/metrics.k8s.io/v1beta1/nodes?watch=true",
+			expectCache: false,
+		},
+		{
+			desc:        "list not allowed aggregated api",
+			userAgent:   "kubelet",
+			verb:        "GET",
+			path:        "/apis/custom.metrics.k8s.io/v1beta1/nodes",
+			expectCache: false,
+		},
+	}
+
+	resolver := newTestRequestInfoResolver()
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.verb, tt.path, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = "127.0.0.1"
+
+			var reqCanCache bool
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				reqCanCache = m.CanCacheFor(req)
+			})
+
+			handler = proxyutil.WithRequestClientComponent(handler)
+			handler = filters.WithRequestInfo(handler, resolver)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if reqCanCache != tt.expectCache {
+				t.Errorf("Got request can cache %v, but expect request can cache %v", reqCanCache, tt.expectCache)
+			}
+		})
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
-	"os"
 	"path"
+	"sort"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
 	"github.com/alibaba/openyurt/pkg/yurthub/storage"
@@ -26,12 +28,20 @@ import (
 	"k8s.io/klog"
 )
 
+// storageTimeout bounds the storage operations for caching responses. responses are
+// cached in background and the caching goes on after request is finished, so the
+// storage operations are not bounded by the request context.
+const storageTimeout = 10 * time.Second
+
 type CacheManager interface {
 	CacheResponse(ctx context.Context, prc io.ReadCloser, stopCh <-chan struct{}) error
 	QueryCache(req *http.Request) (runtime.Object, error)
 	UpdateCacheAgents(agents []string) error
 	ListCacheAgents() []string
+	UpdateAggregatedAPIGroups(groups []string)
+	PinAggregatedAPIGroups(groups ...string)
 	CanCacheFor(req *http.Request) bool
+	Watermarks() *Watermarks
 }
 
 type cacheManager struct {
@@ -39,17 +49,39 @@ type cacheManager struct {
 	storage           StorageWrapper
 	serializerManager *serializer.SerializerManager
 	cacheAgents       map[string]bool
+	// aggregatedAPIGroups is the allow-list of aggregated api groups
+	// that responses can be cached.
+	aggregatedAPIGroups map[string]bool
+	// pinnedAPIGroups are the aggregated api groups that responses are always
+	// cached, whatever the allow-list is updated to.
+	pinnedAPIGroups map[string]bool
+	// listChunkSize is the number of items in a chunk when a large json list
+	// of aggregated api is stored in chunks, 0 means lists are not split.
+	listChunkSize int
+	listChunkLock sync.RWMutex
+	watermarks    *Watermarks
+	// readOnly is true if storage is read-only, responses are not cached and cache
+	// agents are not saved, the cache is only served.
+	readOnly bool
 }
 
 func NewCacheManager(
 	storage StorageWrapper,
 	serializerMgr *serializer.SerializerManager,
+	aggregatedAPIGroups []string,
+	listChunkSize int,
 ) (CacheManager, error) {
 	cm := &cacheManager{
-		storage:           storage,
-		serializerManager: serializerMgr,
-		cacheAgents:       make(map[string]bool),
+		storage:             storage,
+		serializerManager:   serializerMgr,
+		cacheAgents:         make(map[string]bool),
+		aggregatedAPIGroups: make(map[string]bool),
+		pinnedAPIGroups:     make(map[string]bool),
+		listChunkSize:       listChunkSize,
+		watermarks:          NewWatermarks(),
+		readOnly:            isReadOnly(storage),
 	}
+	cm.UpdateAggregatedAPIGroups(aggregatedAPIGroups)
 
 	err := cm.initCacheAgents()
 	if err != nil {
@@ -59,8 +91,23 @@ func NewCacheManager(
 	return cm, nil
 }
 
+// Watermarks returns the watermarks of resource versions observed for components
+func (em *cacheManager) Watermarks() *Watermarks {
+	return em.watermarks
+}
+
 func (em *cacheManager) CacheResponse(ctx context.Context, prc io.ReadCloser, stopCh <-chan struct{}) error {
+	if em.readOnly {
+		// the response is drained without caching it, so the response to client is not blocked
+		_, err := io.Copy(ioutil.Discard, prc)
+		return err
+	}
+
 	info, _ := apirequest.RequestInfoFrom(ctx)
+	if IsExtensionAPI(info) {
+		return em.saveRawResponse(ctx, info, prc)
+	}
+
 	if isWatch(ctx) {
 		return em.saveWatchObject(ctx, info, prc, stopCh)
 	} else {
@@ -97,6 +144,10 @@ func (em *cacheManager) QueryCache(req *http.Request) (runtime.Object, error) {
 		return nil, fmt.Errorf("failed to get component info")
 	}
 
+	if IsExtensionAPI(info) {
+		return em.queryRawResponse(ctx, comp, info, req.URL.RawQuery)
+	}
+
 	if info.IsResourceRequest && info.Verb == "list" {
 		return em.queryListObject(req)
 	} else if info.IsResourceRequest && (info.Verb == "get" || info.Verb == "patch" || info.Verb == "update") {
@@ -104,7 +155,11 @@ func (em *cacheManager) QueryCache(req *http.Request) (runtime.Object, error) {
 		if err != nil {
 			return nil, err
 		}
-		return em.storage.Get(key)
+		obj, err := em.storage.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return serializer.ConvertToVersion(obj, schema.GroupVersion{Group: info.APIGroup, Version: info.APIVersion})
 	}
 
 	return nil, fmt.Errorf("request(%#+v) is not supported", info)
@@ -133,11 +188,19 @@ func (em *cacheManager) queryListObject(req *http.Request) (runtime.Object, erro
 		return nil, err
 	}
 
-	objs, err := em.storage.List(key)
+	objs, err := em.storage.List(ctx, key)
 	if err != nil {
 		return nil, err
 	}
 
+	// objects may be cached in another version before apiserver is upgraded
+	gv := listGvk.GroupVersion()
+	for i := range objs {
+		if objs[i], err = serializer.ConvertToVersion(objs[i], gv); err != nil {
+			return nil, err
+		}
+	}
+
 	listRv := 0
 	rvStr := ""
 	rvInt := 0
@@ -243,6 +306,8 @@ func (em *cacheManager) saveWatchObject(ctx context.Context, info *apirequest.Re
 				klog.Errorf("failed to get cache path, %v", err)
 				continue
 			}
+			rv, _ := accessor.ResourceVersion(obj)
+			em.watermarks.Observe(comp, rv)
 
 			switch watchType {
 			case watch.Added, watch.Modified:
@@ -255,7 +320,8 @@ func (em *cacheManager) saveWatchObject(ctx context.Context, info *apirequest.Re
 					updateObjCnt++
 				}
 			case watch.Deleted:
-				err = em.storage.Delete(key)
+				em.watermarks.ObserveDeletion(key, rv)
+				err = em.deleteObject(key)
 				delObjCnt++
 			default:
 				// impossible go to here
@@ -305,6 +371,13 @@ func (em *cacheManager) saveListObject(ctx context.Context, info *apirequest.Req
 	}
 	klog.V(5).Infof("list items for %s is: %d", util.ReqInfoString(info), len(items))
 
+	comp, _ := util.ClientComponentFrom(ctx)
+	var listRv, listContinue string
+	if listAccessor, err := meta.ListAccessor(list); err == nil {
+		listRv, listContinue = listAccessor.GetResourceVersion(), listAccessor.GetContinue()
+		em.watermarks.Observe(comp, listRv)
+	}
+
 	kind := ResourceToKindMap[info.Resource]
 	apiVersion := schema.GroupVersion{
 		Group:   info.APIGroup,
@@ -312,8 +385,7 @@ func (em *cacheManager) saveListObject(ctx context.Context, info *apirequest.Req
 	}.String()
 	accessor := meta.NewAccessor()
 
-	comp, _ := util.ClientComponentFrom(ctx)
-	var errs []error
+	objs := make(map[string]runtime.Object, len(items))
 	for i := range items {
 		name, err := accessor.Name(items[i])
 		if err != nil || name == "" {
@@ -338,21 +410,142 @@ func (em *cacheManager) saveListObject(ctx context.Context, info *apirequest.Req
 
 		accessor.SetKind(items[i], kind)
 		accessor.SetAPIVersion(items[i], apiVersion)
-		err = em.saveOneObjectWithValidation(key, items[i])
-		if err == storage.ErrStorageAccessConflict {
-			klog.V(2).Infof("skip to cache list object because key(%s) is under processing", key)
-		} else if err != nil {
-			errs = append(errs, fmt.Errorf("failed to save object(%s), %v", key, err))
+		objs[key] = items[i]
+	}
+
+	// the full list of a collection replaces the cached one, so the objects that are
+	// deleted in cloud(like while the node is offline) are removed from cache too.
+	if partial, ok := util.PartialListFrom(ctx); ok && !partial && listContinue == "" {
+		rootKey, err := util.KeyFunc(comp, info.Resource, info.Namespace, "")
+		if err != nil {
+			return err
 		}
+		err = em.replaceListObjects(rootKey, listRv, objs)
+		if err == nil {
+			return nil
+		} else if err != storage.ErrStorageAccessConflict {
+			return fmt.Errorf("failed to replace list object(%s), %v", rootKey, err)
+		}
+		klog.V(2).Infof("cache list object one by one because key(%s) is under processing", rootKey)
 	}
 
-	if len(errs) != 0 {
-		return fmt.Errorf("failed to save list object, %#+v", errs)
+	if err := em.saveListObjectsWithValidation(objs); err == storage.ErrStorageAccessConflict {
+		klog.V(2).Infof("skip to cache some of list objects because they are under processing")
+	} else if err != nil {
+		return fmt.Errorf("failed to save list object, %v", err)
 	}
 
 	return nil
 }
 
+// replaceListObjects replaces the cached objects under rootKey with objs of the list in
+// resource version listRv. like saveOneObjectWithValidation, the cached objects that are
+// newer than the list(like written by a watch event) are kept, and the objects that are
+// deleted after the list are not cached again.
+func (em *cacheManager) replaceListObjects(rootKey, listRv string, objs map[string]runtime.Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	comp, _, _, _ := util.SplitKey(rootKey)
+	accessor := meta.NewAccessor()
+	listRvInt, _ := strconv.Atoi(listRv)
+	cachedKeys, err := em.storage.ListKeys(ctx, rootKey)
+	if err != nil {
+		return err
+	}
+	cachedObjs, err := em.storage.GetMulti(ctx, cachedKeys)
+	if err != nil {
+		return err
+	}
+	for key, oldObj := range cachedObjs {
+		oldRv, _ := accessor.ResourceVersion(oldObj)
+		oldRvInt, _ := strconv.Atoi(oldRv)
+		if obj, ok := objs[key]; ok {
+			newRv, _ := accessor.ResourceVersion(obj)
+			if newRvInt, _ := strconv.Atoi(newRv); newRvInt < oldRvInt {
+				em.watermarks.RefuseStaleWrite(comp)
+				objs[key] = oldObj
+			}
+		} else if listRvInt != 0 && oldRvInt > listRvInt {
+			// the object is created after the list
+			objs[key] = oldObj
+		}
+	}
+
+	for key, obj := range objs {
+		if newRv, _ := accessor.ResourceVersion(obj); em.watermarks.DeletedAfter(key, newRv) {
+			klog.V(2).Infof("skip to cache object(%s) in resource version %s, it's deleted after that", key, newRv)
+			em.watermarks.RefuseStaleWrite(comp)
+			delete(objs, key)
+		}
+	}
+
+	return em.storage.Replace(ctx, rootKey, objs)
+}
+
+// saveListObjectsWithValidation saves objs of a list like saveOneObjectWithValidation for each
+// of them, the keys are locked in ascending lexical order for the batch, and the cached objects
+// are read and the changed objects are written in a batch.
+func (em *cacheManager) saveListObjectsWithValidation(objs map[string]runtime.Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	keys := make([]string, 0, len(objs))
+	for key := range objs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lockedCtx, err := em.storage.LockKey(ctx, key)
+		if err != nil {
+			return err
+		}
+		defer em.storage.UnlockKey(lockedCtx, key)
+		ctx = lockedCtx
+	}
+
+	oldObjs, err := em.storage.GetMulti(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	accessor := meta.NewAccessor()
+	changed := make(map[string]runtime.Object, len(objs))
+	for _, key := range keys {
+		comp, _, _, _ := util.SplitKey(key)
+		newRv, _ := accessor.ResourceVersion(objs[key])
+		oldObj, ok := oldObjs[key]
+		if !ok {
+			// the corrupted objects are not read, and they are replaced too
+			if em.watermarks.DeletedAfter(key, newRv) {
+				klog.V(2).Infof("skip to cache object(%s) in resource version %s, it's deleted after that", key, newRv)
+				em.watermarks.RefuseStaleWrite(comp)
+				continue
+			}
+			changed[key] = objs[key]
+			continue
+		}
+
+		oldRv, _ := accessor.ResourceVersion(oldObj)
+		oldRvInt, _ := strconv.Atoi(oldRv)
+		newRvInt, _ := strconv.Atoi(newRv)
+		if newRvInt < oldRvInt {
+			em.watermarks.RefuseStaleWrite(comp)
+		} else if newRvInt == oldRvInt {
+			if err := em.storage.Touch(ctx, key); err != nil && err != storage.ErrStorageAccessConflict {
+				klog.V(4).Infof("failed to touch cached object %s, %v", key, err)
+			}
+		} else {
+			changed[key] = objs[key]
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+	return em.storage.SetMulti(ctx, changed)
+}
+
 func (em *cacheManager) saveOneObject(ctx context.Context, info *apirequest.RequestInfo, b []byte) error {
 	comp, _ := util.ClientComponentFrom(ctx)
 	reqContentType, _ := util.ReqContentTypeFrom(ctx)
@@ -407,6 +600,8 @@ func (em *cacheManager) saveOneObject(ctx context.Context, info *apirequest.Requ
 		return err
 	}
 
+	rv, _ := accessor.ResourceVersion(obj)
+	em.watermarks.Observe(comp, rv)
 	if err := em.saveOneObjectWithValidation(key, obj); err != nil {
 		if err != storage.ErrStorageAccessConflict {
 			return err
@@ -417,11 +612,28 @@ func (em *cacheManager) saveOneObject(ctx context.Context, info *apirequest.Requ
 	return nil
 }
 
+func (em *cacheManager) deleteObject(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return em.storage.Delete(ctx, key)
+}
+
 func (em *cacheManager) saveOneObjectWithValidation(key string, obj runtime.Object) error {
-	oldObj, err := em.storage.Get(key)
-	if err == nil && oldObj != nil {
-		accessor := meta.NewAccessor()
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	// the object is compared with the cached one and then written, lock the key so the
+	// writes of the same object(like by a watch event and a relist) are not interleaved.
+	ctx, err := em.storage.LockKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer em.storage.UnlockKey(ctx, key)
 
+	comp, _, _, _ := util.SplitKey(key)
+	accessor := meta.NewAccessor()
+	oldObj, err := em.storage.Get(ctx, key)
+	if err == nil {
 		oldRv, err := accessor.ResourceVersion(oldObj)
 		if err != nil {
 			klog.Errorf("failed to get old object resource version for %s, %v", key, err)
@@ -436,19 +648,35 @@ func (em *cacheManager) saveOneObjectWithValidation(key string, obj runtime.Obje
 
 		oldRvInt, _ := strconv.Atoi(oldRv)
 		newRvInt, _ := strconv.Atoi(newRv)
-		if newRvInt <= oldRvInt { // resource version is incremented or not
+		if newRvInt < oldRvInt {
+			// like the response of a retried request that lags behind a watch
+			em.watermarks.RefuseStaleWrite(comp)
+		}
+		if newRvInt == oldRvInt {
+			// the object is refreshed without changes(like by a relist), so it's not stale
+			if err := em.storage.Touch(ctx, key); err != nil && err != storage.ErrStorageAccessConflict {
+				klog.V(4).Infof("failed to touch cached object %s, %v", key, err)
+			}
+			return nil
+		}
+		if newRvInt < oldRvInt { // resource version is incremented or not
 			return nil
 		}
 
-		return em.storage.Update(key, obj)
-	} else if os.IsNotExist(err) || oldObj == nil {
-		return em.storage.Create(key, obj)
-	} else {
-		if err != storage.ErrStorageAccessConflict {
-			return em.storage.Create(key, obj)
+		return em.storage.Update(ctx, key, obj)
+	} else if err == storage.ErrNotFound {
+		if newRv, _ := accessor.ResourceVersion(obj); em.watermarks.DeletedAfter(key, newRv) {
+			klog.V(2).Infof("skip to cache object(%s) in resource version %s, it's deleted after that", key, newRv)
+			em.watermarks.RefuseStaleWrite(comp)
+			return nil
 		}
-		return err
+		return em.storage.Create(ctx, key, obj)
+	} else if err == storage.ErrCorrupted {
+		klog.Warningf("replace corrupted object for %s", key)
+		return em.storage.Update(ctx, key, obj)
 	}
+
+	return err
 }
 
 func isList(ctx context.Context) bool {
@@ -2,6 +2,7 @@ package cachemanager
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,8 +15,12 @@ import (
 
 	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
 	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/readonly"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
 	v1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -213,7 +218,7 @@ func TestCacheResponse(t *testing.T) {
 					t.Errorf("Got error %v", err)
 				}
 
-				obj, err := storage.Get(tt.key)
+				obj, err := storage.Get(context.Background(), tt.key)
 				if err != nil || obj == nil {
 					t.Errorf("failed to get object from storage")
 				}
@@ -253,9 +258,9 @@ func getEncoder() runtime.Encoder {
 }
 
 func resetStorage(s StorageWrapper, key string) {
-	keys, _ := s.ListKeys(key)
+	keys, _ := s.ListKeys(context.Background(), key)
 	for i := range keys {
-		s.Delete(keys[i])
+		s.Delete(context.Background(), keys[i])
 	}
 }
 
@@ -431,7 +436,7 @@ func TestCacheResponseForWatch(t *testing.T) {
 					t.Errorf("Got error %v", err)
 				}
 
-				objs, err := storage.List(tt.key)
+				objs, err := storage.List(context.Background(), tt.key)
 				if err != nil || len(objs) == 0 {
 					t.Errorf("failed to get object from storage")
 
@@ -674,7 +679,7 @@ func TestCacheResponseForList(t *testing.T) {
 					t.Errorf("Got error %v", err)
 				}
 
-				objs, err := storage.List(tt.key)
+				objs, err := storage.List(context.Background(), tt.key)
 				if err != nil || len(objs) == 0 {
 					t.Errorf("failed to get object from storage")
 				}
@@ -705,6 +710,92 @@ func TestCacheResponseForList(t *testing.T) {
 	}
 }
 
+func TestCacheResponseForRelist(t *testing.T) {
+	store, err := memory.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("failed to create memory storage, %v", err)
+	}
+	sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+	yurtCM, err := NewCacheManager(sw, serializer.NewSerializerManager(), nil, 0)
+	if err != nil {
+		t.Fatalf("failed to create cache manager, %v", err)
+	}
+	resolver := newTestRequestInfoResolver()
+
+	newPod := func(name, rv string) v1.Pod {
+		return v1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", ResourceVersion: rv},
+		}
+	}
+	cacheList := func(path, rv string, pods ...v1.Pod) {
+		list := &v1.PodList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+			ListMeta: metav1.ListMeta{ResourceVersion: rv},
+			Items:    pods,
+		}
+		buf := bytes.NewBuffer([]byte{})
+		if err := getEncoder().Encode(list, buf); err != nil {
+			t.Fatalf("could not encode pod list, %v", err)
+		}
+
+		var cacheErr error
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", "kubelet")
+		req.Header.Set("Accept", "application/json")
+		req.RemoteAddr = "127.0.0.1"
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := util.WithRespContentType(req.Context(), "application/json")
+			cacheErr = yurtCM.CacheResponse(ctx, ioutil.NopCloser(buf), nil)
+		})
+		handler = proxyutil.WithRequestContentType(handler)
+		handler = proxyutil.WithPartialListCheck(handler)
+		handler = proxyutil.WithRequestClientComponent(handler)
+		handler = filters.WithRequestInfo(handler, resolver)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if cacheErr != nil {
+			t.Fatalf("failed to cache list of %s, %v", path, cacheErr)
+		}
+	}
+	expectPods := func(desc string, expect map[string]string) {
+		keys, err := sw.ListKeys(context.Background(), "kubelet/pods/default")
+		if err != nil {
+			t.Fatalf("%s: failed to list keys, %v", desc, err)
+		}
+		if len(keys) != len(expect) {
+			t.Errorf("%s: expect keys of %v, but got %v", desc, expect, keys)
+		}
+		for name, rv := range expect {
+			obj, err := sw.Get(context.Background(), filepath.Join("kubelet/pods/default", name))
+			if err != nil {
+				t.Errorf("%s: expect pod %s is cached, but got %v", desc, name, err)
+				continue
+			}
+			if gotRv, _ := meta.NewAccessor().ResourceVersion(obj); gotRv != rv {
+				t.Errorf("%s: expect pod %s in rv %s, but got %s", desc, name, rv, gotRv)
+			}
+		}
+	}
+
+	cacheList("/api/v1/namespaces/default/pods", "5", newPod("mypod1", "1"), newPod("mypod2", "3"), newPod("mypod3", "5"))
+	expectPods("list", map[string]string{"mypod1": "1", "mypod2": "3", "mypod3": "5"})
+
+	// mypod3 is deleted and mypod4 is created by a watch event after the next list
+	if err := sw.Create(context.Background(), "kubelet/pods/default/mypod4", &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "mypod4", Namespace: "default", ResourceVersion: "9"},
+	}); err != nil {
+		t.Fatalf("failed to create pod, %v", err)
+	}
+	cacheList("/api/v1/namespaces/default/pods", "7", newPod("mypod1", "1"), newPod("mypod2", "6"))
+	expectPods("relist", map[string]string{"mypod1": "1", "mypod2": "6", "mypod4": "9"})
+
+	// a list with selector is a part of the collection, the others are kept, and the
+	// objects older than the cached ones are not written
+	cacheList("/api/v1/namespaces/default/pods?labelSelector=app%3Dnginx", "10", newPod("mypod1", "10"), newPod("mypod2", "2"))
+	expectPods("list with selector", map[string]string{"mypod1": "10", "mypod2": "6", "mypod4": "9"})
+}
+
 func TestQueryCacheForGet(t *testing.T) {
 	storage := NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
@@ -720,6 +811,8 @@ func TestQueryCacheForGet(t *testing.T) {
 		name string
 		ns   string
 		kind string
+		// apiVersion is checked if it's set
+		apiVersion string
 	}
 	tests := []struct {
 		desc         string
@@ -870,13 +963,67 @@ func TestQueryCacheForGet(t *testing.T) {
 				kind: "Node",
 			},
 		},
+		{
+			desc: "query lease cached in another version",
+			key:  "kubelet/leases/kube-node-lease/mynode1",
+			inputObj: runtime.Object(&coordinationv1beta1.Lease{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "coordination.k8s.io/v1beta1",
+					Kind:       "Lease",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "mynode1",
+					Namespace:       "kube-node-lease",
+					ResourceVersion: "5",
+				},
+			}),
+			userAgent:  "kubelet",
+			accept:     "application/json",
+			verb:       "GET",
+			path:       "/apis/coordination.k8s.io/v1/namespaces/kube-node-lease/leases/mynode1",
+			namespaced: true,
+			expectResult: expectData{
+				rv:         "5",
+				name:       "mynode1",
+				ns:         "kube-node-lease",
+				kind:       "Lease",
+				apiVersion: "coordination.k8s.io/v1",
+			},
+		},
+		{
+			desc: "query deployment cached in deprecated group",
+			key:  "kubelet/deployments/default/mydeploy1",
+			inputObj: runtime.Object(&extensionsv1beta1.Deployment{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "extensions/v1beta1",
+					Kind:       "Deployment",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "mydeploy1",
+					Namespace:       "default",
+					ResourceVersion: "6",
+				},
+			}),
+			userAgent:  "kubelet",
+			accept:     "application/json",
+			verb:       "GET",
+			path:       "/apis/apps/v1/namespaces/default/deployments/mydeploy1",
+			namespaced: true,
+			expectResult: expectData{
+				rv:         "6",
+				name:       "mydeploy1",
+				ns:         "default",
+				kind:       "Deployment",
+				apiVersion: "apps/v1",
+			},
+		},
 	}
 
 	accessor := meta.NewAccessor()
 	resolver := newTestRequestInfoResolver()
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
-			_ = storage.Create(tt.key, tt.inputObj)
+			_ = storage.Create(context.Background(), tt.key, tt.inputObj)
 			req, _ := http.NewRequest(tt.verb, tt.path, nil)
 			if len(tt.userAgent) != 0 {
 				req.Header.Set("User-Agent", tt.userAgent)
@@ -888,7 +1035,7 @@ func TestQueryCacheForGet(t *testing.T) {
 
 			req.RemoteAddr = "127.0.0.1"
 
-			var name, ns, rv, kind string
+			var name, ns, rv, kind, apiVersion string
 			var err error
 			var obj runtime.Object
 			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -898,6 +1045,7 @@ func TestQueryCacheForGet(t *testing.T) {
 					rv, _ = accessor.ResourceVersion(obj)
 					ns, _ = accessor.Namespace(obj)
 					kind, _ = accessor.Kind(obj)
+					apiVersion, _ = accessor.APIVersion(obj)
 				}
 			})
 
@@ -931,6 +1079,10 @@ func TestQueryCacheForGet(t *testing.T) {
 				if tt.expectResult.kind != kind {
 					t.Errorf("Got kind %s, but expect kind %s", kind, tt.expectResult.kind)
 				}
+
+				if len(tt.expectResult.apiVersion) != 0 && tt.expectResult.apiVersion != apiVersion {
+					t.Errorf("Got apiVersion %s, but expect apiVersion %s", apiVersion, tt.expectResult.apiVersion)
+				}
 			}
 		})
 	}
@@ -1085,7 +1237,7 @@ func TestQueryCacheForList(t *testing.T) {
 			for i := range tt.inputObj {
 				v, _ := accessor.Name(tt.inputObj[i])
 				key := filepath.Join(tt.keyPrefix, v)
-				_ = storage.Create(key, tt.inputObj[i])
+				_ = storage.Create(context.Background(), key, tt.inputObj[i])
 			}
 
 			req, _ := http.NewRequest(tt.verb, tt.path, nil)
@@ -1155,3 +1307,98 @@ func TestQueryCacheForList(t *testing.T) {
 		})
 	}
 }
+
+func TestCacheAndQueryAggregatedAPI(t *testing.T) {
+	storage := NewFakeStorageWrapper()
+	yurtCM, _ := NewCacheManager(storage, serializer.NewSerializerManager(), []string{"metrics.k8s.io"}, 0)
+
+	body := []byte(`{"kind":"NodeMetricsList","apiVersion":"metrics.k8s.io/v1beta1","items":[]}`)
+	resolver := newTestRequestInfoResolver()
+
+	var cacheErr error
+	req, _ := http.NewRequest("GET", "/apis/metrics.k8s.io/v1beta1/nodes", nil)
+	req.Header.Set("User-Agent", "kubelet")
+	req.Header.Set("Accept", "application/json")
+	req.RemoteAddr = "127.0.0.1"
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := util.WithRespContentType(req.Context(), "application/json")
+		cacheErr = yurtCM.CacheResponse(ctx, ioutil.NopCloser(bytes.NewBuffer(body)), nil)
+	})
+	handler = proxyutil.WithRequestContentType(handler)
+	handler = proxyutil.WithRequestClientComponent(handler)
+	handler = filters.WithRequestInfo(handler, resolver)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if cacheErr != nil {
+		t.Fatalf("failed to cache aggregated api response, %v", cacheErr)
+	}
+
+	var obj runtime.Object
+	var queryErr error
+	req, _ = http.NewRequest("GET", "/apis/metrics.k8s.io/v1beta1/nodes", nil)
+	req.Header.Set("User-Agent", "kubelet")
+	req.Header.Set("Accept", "application/json")
+	req.RemoteAddr = "127.0.0.1"
+	handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		obj, queryErr = yurtCM.QueryCache(req)
+	})
+	handler = proxyutil.WithRequestContentType(handler)
+	handler = proxyutil.WithRequestClientComponent(handler)
+	handler = filters.WithRequestInfo(handler, resolver)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if queryErr != nil {
+		t.Fatalf("failed to query aggregated api response, %v", queryErr)
+	}
+
+	raw, ok := obj.(*runtime.Unknown)
+	if !ok {
+		t.Fatalf("Got object %T, but expect *runtime.Unknown", obj)
+	}
+
+	if raw.ContentType != "application/json" {
+		t.Errorf("Got content type %s, but expect application/json", raw.ContentType)
+	}
+
+	if !bytes.Equal(raw.Raw, body) {
+		t.Errorf("Got body %s, but expect %s", string(raw.Raw), string(body))
+	}
+}
+
+func TestCacheResponseReadOnly(t *testing.T) {
+	store, err := memory.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("failed to create memory storage, %v", err)
+	}
+	sw := NewStorageWrapper(readonly.NewStore(store), serializer.NewStorageCodec())
+	yurtCM, err := NewCacheManager(sw, serializer.NewSerializerManager(), nil, 0)
+	if err != nil {
+		t.Fatalf("expect cache manager is created with read-only storage, but got %v", err)
+	}
+	if err := yurtCM.UpdateCacheAgents([]string{"coredns"}); err != nil {
+		t.Errorf("expect cache agents are updated in memory, but got %v", err)
+	}
+
+	body := []byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"mypod1","namespace":"default","resourceVersion":"1"}}`)
+	rc := ioutil.NopCloser(bytes.NewBuffer(body))
+	var cacheErr error
+	req, _ := http.NewRequest("GET", "/api/v1/namespaces/default/pods/mypod1", nil)
+	req.Header.Set("User-Agent", "kubelet")
+	req.Header.Set("Accept", "application/json")
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := util.WithRespContentType(req.Context(), "application/json")
+		cacheErr = yurtCM.CacheResponse(ctx, rc, nil)
+	})
+	handler = proxyutil.WithRequestContentType(handler)
+	handler = proxyutil.WithRequestClientComponent(handler)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if cacheErr != nil {
+		t.Errorf("expect response is skipped without error, but got %v", cacheErr)
+	}
+	if n, _ := rc.Read(make([]byte, 1)); n != 0 {
+		t.Errorf("expect response is drained")
+	}
+	if keys, _ := store.ListKeys(context.Background(), ""); len(keys) != 0 {
+		t.Errorf("expect nothing is written to read-only storage, but got %v", keys)
+	}
+}
@@ -0,0 +1,90 @@
+package cachemanager
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+// ExportCachedObjects returns the object cached in key(like kubelet/pods/default/nginx), or a v1.List
+// of the objects cached under key if it's a collection(like kubelet/pods), the objects are decoded
+// as they are served to the component during autonomy, so they can be rendered like kubectl does.
+// keys used by yurthub itself and aggregated api responses are not objects, they are rejected as
+// storage.ErrInvalidKey. the data of secrets is redacted, only the keys of data are exported.
+func ExportCachedObjects(ctx context.Context, sw StorageWrapper, key string) (runtime.Object, error) {
+	key = strings.Trim(key, "/")
+	comp, resource, _, _ := util.SplitKey(key)
+	if resource == "" || strings.HasPrefix(comp, "_") || strings.HasPrefix(resource, "_") {
+		return nil, storage.ErrInvalidKey
+	}
+
+	obj, err := sw.Get(ctx, key)
+	if err == nil {
+		return redactObject(resource, obj.DeepCopyObject()), nil
+	} else if err != storage.ErrKeyIsDir {
+		return nil, err
+	}
+
+	keys, err := sw.ListKeys(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	list := &v1.List{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"},
+		Items:    []runtime.RawExtension{},
+	}
+	for _, k := range keys {
+		_, res, _, name := util.SplitKey(strings.Trim(k, "/"))
+		if name == "" || strings.HasPrefix(res, "_") {
+			continue
+		}
+
+		obj, err := sw.Get(ctx, k)
+		if err == storage.ErrNotFound {
+			continue
+		} else if err == storage.ErrCorrupted {
+			klog.Warningf("cached object %s is not exported, it's corrupted", k)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, runtime.RawExtension{Object: redactObject(res, obj.DeepCopyObject())})
+	}
+	return list, nil
+}
+
+// redactObject clears the values of data in secrets, the keys of data are kept
+func redactObject(resource string, obj runtime.Object) runtime.Object {
+	if resource != "secrets" {
+		return obj
+	}
+
+	switch secret := obj.(type) {
+	case *v1.Secret:
+		for k := range secret.Data {
+			secret.Data[k] = []byte{}
+		}
+		for k := range secret.StringData {
+			secret.StringData[k] = ""
+		}
+	case *unstructured.Unstructured:
+		for _, field := range []string{"data", "stringData"} {
+			data, ok := secret.Object[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for k := range data {
+				data[k] = ""
+			}
+		}
+	}
+	return obj
+}
@@ -0,0 +1,119 @@
+package cachemanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestExportCachedObjects(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+	ctx := context.Background()
+
+	pod := &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1", ResourceVersion: "10"},
+	}
+	secret := &v1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "secret1"},
+		Data:       map[string][]byte{"password": []byte("123456")},
+	}
+	sw.Create(ctx, "kubelet/pods/default/pod1", pod)
+	sw.Create(ctx, "kubelet/secrets/default/secret1", secret)
+	store.Create(ctx, "kubelet/pods/default/pod2", []byte("corrupted"))
+	store.Create(ctx, "kubelet/_aggregated/apis", []byte("raw"))
+	store.Create(ctx, "_internal/cache-manager/cache-agent.conf", []byte("kubelet"))
+
+	testcases := map[string]struct {
+		key   string
+		names []string
+		kind  string
+		err   error
+	}{
+		"object": {
+			key:   "kubelet/pods/default/pod1",
+			kind:  "Pod",
+			names: []string{"pod1"},
+		},
+		"collection": {
+			key:   "kubelet/pods/",
+			kind:  "List",
+			names: []string{"pod1"},
+		},
+		"component": {
+			key: "kubelet",
+			err: storage.ErrInvalidKey,
+		},
+		"not found": {
+			key: "kubelet/pods/default/pod3",
+			err: storage.ErrNotFound,
+		},
+		"corrupted": {
+			key: "kubelet/pods/default/pod2",
+			err: storage.ErrCorrupted,
+		},
+		"internal key": {
+			key: "_internal/cache-manager/cache-agent.conf",
+			err: storage.ErrInvalidKey,
+		},
+		"aggregated api": {
+			key: "kubelet/_aggregated/apis",
+			err: storage.ErrInvalidKey,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			obj, err := ExportCachedObjects(ctx, sw, tt.key)
+			if err != tt.err {
+				t.Fatalf("expect %v, but got %v", tt.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != tt.kind {
+				t.Errorf("expect kind %s, but got %s", tt.kind, kind)
+			}
+
+			var names []string
+			if list, ok := obj.(*v1.List); ok {
+				for _, item := range list.Items {
+					accessor, _ := meta.Accessor(item.Object)
+					names = append(names, accessor.GetName())
+				}
+			} else {
+				accessor, _ := meta.Accessor(obj)
+				names = append(names, accessor.GetName())
+			}
+			if len(names) != len(tt.names) || (len(names) != 0 && names[0] != tt.names[0]) {
+				t.Errorf("expect objects %v, but got %v", tt.names, names)
+			}
+		})
+	}
+
+	obj, err := ExportCachedObjects(ctx, sw, "kubelet/secrets/default/secret1")
+	if err != nil {
+		t.Fatalf("failed to export secret, %v", err)
+	}
+	exported, ok := obj.(*v1.Secret)
+	if !ok {
+		t.Fatalf("expect secret, but got %T", obj)
+	}
+	if b, ok := exported.Data["password"]; !ok || len(b) != 0 {
+		t.Errorf("expect data of secret is redacted, but got %v", exported.Data)
+	}
+	// the object in cache is not changed by redaction
+	cached, _ := sw.Get(ctx, "kubelet/secrets/default/secret1")
+	if string(cached.(*v1.Secret).Data["password"]) != "123456" {
+		t.Errorf("expect cached secret is not changed, but got %v", cached.(*v1.Secret).Data)
+	}
+}
@@ -1,7 +1,7 @@
 package cachemanager
 
 import (
-	"os"
+	"context"
 	"strings"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/storage"
@@ -23,7 +23,7 @@ func NewFakeStorageWrapper() StorageWrapper {
 	}
 }
 
-func (fsw *fakeStorageWrapper) Create(key string, obj runtime.Object) error {
+func (fsw *fakeStorageWrapper) Create(ctx context.Context, key string, obj runtime.Object) error {
 	if fsw.data == nil {
 		fsw.data = make(map[string]runtime.Object)
 	}
@@ -32,22 +32,37 @@ func (fsw *fakeStorageWrapper) Create(key string, obj runtime.Object) error {
 	return nil
 }
 
-func (fsw *fakeStorageWrapper) Delete(key string) error {
+func (fsw *fakeStorageWrapper) Delete(ctx context.Context, key string) error {
 	delete(fsw.data, key)
 
 	return nil
 }
 
-func (fsw *fakeStorageWrapper) Get(key string) (runtime.Object, error) {
+func (fsw *fakeStorageWrapper) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	for k := range fsw.data {
+		if strings.HasPrefix(k, prefix) {
+			delete(fsw.data, k)
+		}
+	}
+
+	return fsw.s.DeleteCollection(ctx, key, force)
+}
+
+func (fsw *fakeStorageWrapper) Get(ctx context.Context, key string) (runtime.Object, error) {
 	obj, ok := fsw.data[key]
 	if ok {
 		return obj, nil
 	}
 
-	return nil, os.ErrNotExist
+	return nil, storage.ErrNotFound
 }
 
-func (fsw *fakeStorageWrapper) ListKeys(key string) ([]string, error) {
+func (fsw *fakeStorageWrapper) ListKeys(ctx context.Context, key string) ([]string, error) {
 	keys := make([]string, 0)
 	for k := range fsw.data {
 		keys = append(keys, k)
@@ -56,7 +71,7 @@ func (fsw *fakeStorageWrapper) ListKeys(key string) ([]string, error) {
 	return keys, nil
 }
 
-func (fsw *fakeStorageWrapper) List(key string) ([]runtime.Object, error) {
+func (fsw *fakeStorageWrapper) List(ctx context.Context, key string) ([]runtime.Object, error) {
 	objs := make([]runtime.Object, 0)
 	for k, obj := range fsw.data {
 		if strings.HasPrefix(k, key) {
@@ -67,7 +82,7 @@ func (fsw *fakeStorageWrapper) List(key string) ([]runtime.Object, error) {
 	return objs, nil
 }
 
-func (fsw *fakeStorageWrapper) Update(key string, obj runtime.Object) error {
+func (fsw *fakeStorageWrapper) Update(ctx context.Context, key string, obj runtime.Object) error {
 	if fsw.data == nil {
 		fsw.data = make(map[string]runtime.Object)
 	}
@@ -76,10 +91,61 @@ func (fsw *fakeStorageWrapper) Update(key string, obj runtime.Object) error {
 	return nil
 }
 
-func (fsw *fakeStorageWrapper) GetRaw(key string) ([]byte, error) {
-	return fsw.s.Get(key)
+func (fsw *fakeStorageWrapper) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	return fsw.s.Get(ctx, key)
+}
+
+func (fsw *fakeStorageWrapper) Touch(ctx context.Context, key string) error {
+	return nil
+}
+
+func (fsw *fakeStorageWrapper) GetMulti(ctx context.Context, keys []string) (map[string]runtime.Object, error) {
+	objs := make(map[string]runtime.Object, len(keys))
+	for _, k := range keys {
+		if obj, ok := fsw.data[k]; ok {
+			objs[k] = obj
+		}
+	}
+
+	return objs, nil
+}
+
+func (fsw *fakeStorageWrapper) SetMulti(ctx context.Context, objs map[string]runtime.Object) error {
+	if fsw.data == nil {
+		fsw.data = make(map[string]runtime.Object)
+	}
+	for k, obj := range objs {
+		fsw.data[k] = obj
+	}
+
+	return nil
+}
+
+func (fsw *fakeStorageWrapper) Replace(ctx context.Context, rootKey string, objs map[string]runtime.Object) error {
+	if err := storage.ValidateDeleteCollection(rootKey, false); err != nil {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(rootKey, "/") + "/"
+	for k := range fsw.data {
+		if strings.HasPrefix(k, prefix) {
+			delete(fsw.data, k)
+		}
+	}
+	for k, obj := range objs {
+		fsw.data[k] = obj
+	}
+
+	return nil
+}
+
+func (fsw *fakeStorageWrapper) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return ctx, nil
+}
+
+func (fsw *fakeStorageWrapper) UnlockKey(ctx context.Context, key string) {
 }
 
-func (fsw *fakeStorageWrapper) UpdateRaw(key string, contents []byte) error {
-	return fsw.s.Update(key, contents)
+func (fsw *fakeStorageWrapper) UpdateRaw(ctx context.Context, key string, contents []byte) error {
+	return fsw.s.Update(ctx, key, contents)
 }
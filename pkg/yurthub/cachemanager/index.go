@@ -0,0 +1,66 @@
+package cachemanager
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// CachedObject is the identity and resource version of an object in cache
+type CachedObject struct {
+	Component       string `json:"component"`
+	Resource        string `json:"resource"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name"`
+	UID             string `json:"uid,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	// Corrupted is true if the content of object can not be decoded
+	Corrupted bool `json:"corrupted,omitempty"`
+}
+
+// CacheIndex lists the objects in cache without their contents, so the cache
+// can be compared with the objects in the cluster.
+type CacheIndex struct {
+	GeneratedAt time.Time      `json:"generatedAt"`
+	Objects     []CachedObject `json:"objects"`
+}
+
+// NewCacheIndex lists the objects cached for component, or for all components if component is empty.
+// keys used by yurthub itself and aggregated api responses are not objects, they are skipped.
+func NewCacheIndex(ctx context.Context, sw StorageWrapper, component string) (*CacheIndex, error) {
+	keys, err := sw.ListKeys(ctx, component)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &CacheIndex{
+		GeneratedAt: time.Now(),
+		Objects:     []CachedObject{},
+	}
+	for _, key := range keys {
+		comp, resource, ns, name := util.SplitKey(strings.Trim(key, "/"))
+		if name == "" || strings.HasPrefix(comp, "_") || strings.HasPrefix(resource, "_") {
+			continue
+		}
+
+		cached := CachedObject{Component: comp, Resource: resource, Namespace: ns, Name: name}
+		obj, err := sw.Get(ctx, key)
+		if err == storage.ErrNotFound {
+			continue
+		} else if err == storage.ErrCorrupted {
+			cached.Corrupted = true
+		} else if err != nil {
+			return nil, err
+		} else if accessor, err := meta.Accessor(obj); err == nil {
+			cached.UID = string(accessor.GetUID())
+			cached.ResourceVersion = accessor.GetResourceVersion()
+		}
+		index.Objects = append(index.Objects, cached)
+	}
+	return index, nil
+}
@@ -0,0 +1,62 @@
+package cachemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewCacheIndex(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := NewStorageWrapper(store, serializer.NewStorageCodec())
+	ctx := context.Background()
+
+	pod := &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1", UID: "uid1", ResourceVersion: "10"},
+	}
+	node := &v1.Node{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", UID: "uid2", ResourceVersion: "20"},
+	}
+	sw.Create(ctx, "kubelet/pods/default/pod1", pod)
+	sw.Create(ctx, "kubelet/nodes/node1", node)
+	store.Create(ctx, "kubelet/pods/default/pod2", []byte("corrupted"))
+	store.Create(ctx, "kubelet/_aggregated/apis", []byte("raw"))
+	store.Create(ctx, "_internal/cache-manager/cache-agent.conf", []byte("kubelet"))
+
+	testcases := map[string]struct {
+		component string
+		expected  []CachedObject
+	}{
+		"all components": {
+			expected: []CachedObject{
+				{Component: "kubelet", Resource: "nodes", Name: "node1", UID: "uid2", ResourceVersion: "20"},
+				{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "pod1", UID: "uid1", ResourceVersion: "10"},
+				{Component: "kubelet", Resource: "pods", Namespace: "default", Name: "pod2", Corrupted: true},
+			},
+		},
+		"unknown component": {
+			component: "kube-proxy",
+			expected:  []CachedObject{},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			index, err := NewCacheIndex(ctx, sw, tt.component)
+			if err != nil {
+				t.Fatalf("failed to generate cache index, %v", err)
+			}
+			if !reflect.DeepEqual(index.Objects, tt.expected) {
+				t.Errorf("expect objects %#v, but got %#v", tt.expected, index.Objects)
+			}
+		})
+	}
+}
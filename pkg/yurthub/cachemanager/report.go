@@ -0,0 +1,124 @@
+package cachemanager
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+// CacheUsage is the usage of a part of cache, Newest is the time that the
+// part of cache is refreshed last. Oldest and Newest are not set if the
+// storage doesn't record modification time.
+type CacheUsage struct {
+	Keys   int        `json:"keys"`
+	Size   int64      `json:"size"`
+	Oldest *time.Time `json:"oldest,omitempty"`
+	Newest *time.Time `json:"newest,omitempty"`
+}
+
+// ResourceUsage is the usage of cache for a resource of a component, aggregated api
+// responses are reported as resource "_aggregated".
+type ResourceUsage struct {
+	Resource string `json:"resource"`
+	CacheUsage
+}
+
+// ComponentUsage is the usage of cache for a component(like kubelet), keys used by
+// yurthub itself are reported as component "_internal".
+type ComponentUsage struct {
+	Component string `json:"component"`
+	CacheUsage
+	Resources []ResourceUsage `json:"resources"`
+}
+
+// CacheReport is the report of what's in the cache, so operators can verify that
+// the cache is ready for node autonomy.
+type CacheReport struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	CacheUsage
+	Components []ComponentUsage `json:"components"`
+}
+
+// NewCacheReport walks all keys in store and reports the usage per component and resource
+func NewCacheReport(ctx context.Context, store storage.Store) (*CacheReport, error) {
+	infos, err := storage.StatKeys(ctx, store, "")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CacheReport{
+		GeneratedAt: time.Now(),
+		Components:  []ComponentUsage{},
+	}
+	components := make(map[string]map[string]*CacheUsage)
+	for _, info := range infos {
+		parts := strings.SplitN(strings.Trim(info.Key, "/"), "/", 3)
+		comp, resource := parts[0], ""
+		if len(parts) > 1 {
+			resource = parts[1]
+		}
+
+		if _, ok := components[comp]; !ok {
+			components[comp] = make(map[string]*CacheUsage)
+		}
+		usage, ok := components[comp][resource]
+		if !ok {
+			usage = &CacheUsage{}
+			components[comp][resource] = usage
+		}
+		usage.add(info)
+	}
+
+	for comp, resources := range components {
+		compUsage := ComponentUsage{
+			Component: comp,
+			Resources: make([]ResourceUsage, 0, len(resources)),
+		}
+		for resource, usage := range resources {
+			compUsage.Resources = append(compUsage.Resources, ResourceUsage{
+				Resource:   resource,
+				CacheUsage: *usage,
+			})
+			compUsage.merge(usage)
+		}
+		sort.Slice(compUsage.Resources, func(i, j int) bool {
+			return compUsage.Resources[i].Resource < compUsage.Resources[j].Resource
+		})
+
+		report.Components = append(report.Components, compUsage)
+		report.merge(&compUsage.CacheUsage)
+	}
+	sort.Slice(report.Components, func(i, j int) bool {
+		return report.Components[i].Component < report.Components[j].Component
+	})
+
+	return report, nil
+}
+
+func (u *CacheUsage) add(info storage.KeyInfo) {
+	u.Keys++
+	u.Size += info.Size
+	if !info.ModTime.IsZero() {
+		u.updateTime(info.ModTime, info.ModTime)
+	}
+}
+
+func (u *CacheUsage) merge(other *CacheUsage) {
+	u.Keys += other.Keys
+	u.Size += other.Size
+	if other.Oldest != nil && other.Newest != nil {
+		u.updateTime(*other.Oldest, *other.Newest)
+	}
+}
+
+func (u *CacheUsage) updateTime(oldest, newest time.Time) {
+	if u.Oldest == nil || oldest.Before(*u.Oldest) {
+		u.Oldest = &oldest
+	}
+	if u.Newest == nil || newest.After(*u.Newest) {
+		u.Newest = &newest
+	}
+}
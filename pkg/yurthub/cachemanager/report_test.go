@@ -0,0 +1,90 @@
+package cachemanager
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+type statStore struct {
+	storage.Store
+	infos []storage.KeyInfo
+}
+
+func (s *statStore) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	return s.infos, nil
+}
+
+func TestNewCacheReport(t *testing.T) {
+	t1 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t1.Add(2 * time.Hour)
+	store := &statStore{
+		infos: []storage.KeyInfo{
+			{Key: "_internal/cache-manager/cache-agent.conf", Size: 10, ModTime: t1},
+			{Key: "kubelet/nodes/node1", Size: 100, ModTime: t2},
+			{Key: "kubelet/pods/default/pod1", Size: 200, ModTime: t1},
+			{Key: "kubelet/pods/default/pod2", Size: 300, ModTime: t3},
+		},
+	}
+
+	report, err := NewCacheReport(context.Background(), store)
+	if err != nil {
+		t.Fatalf("failed to generate cache report, %v", err)
+	}
+
+	expected := []ComponentUsage{
+		{
+			Component:  "_internal",
+			CacheUsage: CacheUsage{Keys: 1, Size: 10, Oldest: &t1, Newest: &t1},
+			Resources: []ResourceUsage{
+				{Resource: "cache-manager", CacheUsage: CacheUsage{Keys: 1, Size: 10, Oldest: &t1, Newest: &t1}},
+			},
+		},
+		{
+			Component:  "kubelet",
+			CacheUsage: CacheUsage{Keys: 3, Size: 600, Oldest: &t1, Newest: &t3},
+			Resources: []ResourceUsage{
+				{Resource: "nodes", CacheUsage: CacheUsage{Keys: 1, Size: 100, Oldest: &t2, Newest: &t2}},
+				{Resource: "pods", CacheUsage: CacheUsage{Keys: 2, Size: 500, Oldest: &t1, Newest: &t3}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(report.Components, expected) {
+		t.Errorf("expect components %#v, but got %#v", expected, report.Components)
+	}
+
+	total := CacheUsage{Keys: 4, Size: 610, Oldest: &t1, Newest: &t3}
+	if !reflect.DeepEqual(report.CacheUsage, total) {
+		t.Errorf("expect total usage %#v, but got %#v", total, report.CacheUsage)
+	}
+}
+
+func TestNewCacheReportWithoutModTime(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	store.Create(context.Background(), "kubelet/pods/default/pod1", []byte("pod1"))
+	store.Create(context.Background(), "kubelet/_aggregated/apis", []byte("raw"))
+
+	report, err := NewCacheReport(context.Background(), store)
+	if err != nil {
+		t.Fatalf("failed to generate cache report, %v", err)
+	}
+
+	expected := []ComponentUsage{
+		{
+			Component:  "kubelet",
+			CacheUsage: CacheUsage{Keys: 2, Size: 7},
+			Resources: []ResourceUsage{
+				{Resource: "_aggregated", CacheUsage: CacheUsage{Keys: 1, Size: 3}},
+				{Resource: "pods", CacheUsage: CacheUsage{Keys: 1, Size: 4}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(report.Components, expected) {
+		t.Errorf("expect components %#v, but got %#v", expected, report.Components)
+	}
+}
@@ -2,51 +2,82 @@ package cachemanager
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"strings"
 	"sync"
 
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/serializer/json"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/klog"
 )
 
 type StorageWrapper interface {
-	Create(key string, obj runtime.Object) error
-	Delete(key string) error
-	Get(key string) (runtime.Object, error)
-	ListKeys(key string) ([]string, error)
-	List(key string) ([]runtime.Object, error)
-	Update(key string, obj runtime.Object) error
-	GetRaw(key string) ([]byte, error)
-	UpdateRaw(key string, contents []byte) error
+	Create(ctx context.Context, key string, obj runtime.Object) error
+	Delete(ctx context.Context, key string) error
+	DeleteCollection(ctx context.Context, key string, force bool) error
+	Get(ctx context.Context, key string) (runtime.Object, error)
+	ListKeys(ctx context.Context, key string) ([]string, error)
+	List(ctx context.Context, key string) ([]runtime.Object, error)
+	Update(ctx context.Context, key string, obj runtime.Object) error
+	GetRaw(ctx context.Context, key string) ([]byte, error)
+	UpdateRaw(ctx context.Context, key string, contents []byte) error
+	Touch(ctx context.Context, key string) error
+	// GetMulti returns the objects of keys, the keys that don't exist or can't be decoded
+	// are not in the result.
+	GetMulti(ctx context.Context, keys []string) (map[string]runtime.Object, error)
+	// SetMulti writes objs of keys in a batch, like the changed objects of a list.
+	SetMulti(ctx context.Context, objs map[string]runtime.Object) error
+	// Replace replaces all objects under rootKey with objs atomically, like the result of a list,
+	// the objects under rootKey that are not in objs are deleted.
+	Replace(ctx context.Context, rootKey string, objs map[string]runtime.Object) error
+	// LockKey locks key for a transaction of multiple operations(like get and then update),
+	// the operations in the transaction must be called with the returned context, and the
+	// operations of others on key wait until it's unlocked by UnlockKey.
+	LockKey(ctx context.Context, key string) (context.Context, error)
+	// UnlockKey unlocks key that is locked by LockKey, ctx is the context returned by LockKey.
+	UnlockKey(ctx context.Context, key string)
 }
 
 type storageWrapper struct {
 	sync.RWMutex
-	store             storage.Store
-	backendSerializer runtime.Serializer
-	cache             map[string]runtime.Object
+	store storage.Store
+	codec *serializer.StorageCodec
+	cache map[string]runtime.Object
 }
 
-func NewStorageWrapper(storage storage.Store) StorageWrapper {
+// NewStorageWrapper creates a StorageWrapper that encodes objects in storage by codec
+func NewStorageWrapper(storage storage.Store, codec *serializer.StorageCodec) StorageWrapper {
 	return &storageWrapper{
-		store:             storage,
-		backendSerializer: json.NewSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, false),
-		cache:             make(map[string]runtime.Object),
+		store: storage,
+		codec: codec,
+		cache: make(map[string]runtime.Object),
 	}
 }
 
-func (sw *storageWrapper) Create(key string, obj runtime.Object) error {
+// ReadOnly returns true if the storage of sw is read-only
+func (sw *storageWrapper) ReadOnly() bool {
+	return storage.IsReadOnly(sw.store)
+}
+
+// isReadOnly returns true if sw is read-only, the StorageWrapper that doesn't
+// implement storage.ReadOnlyStore is writable.
+func isReadOnly(sw StorageWrapper) bool {
+	ro, ok := sw.(storage.ReadOnlyStore)
+	return ok && ro.ReadOnly()
+}
+
+func (sw *storageWrapper) Create(ctx context.Context, key string, obj runtime.Object) error {
 	var buf bytes.Buffer
-	if err := sw.backendSerializer.Encode(obj, &buf); err != nil {
+	if err := sw.encode(key, obj, &buf); err != nil {
 		klog.Errorf("failed to encode object in create for %s, %v", key, err)
 		return err
 	}
 
-	if err := sw.store.Create(key, buf.Bytes()); err != nil {
+	if err := sw.store.Create(ctx, key, buf.Bytes()); err != nil {
 		return err
 	}
 
@@ -59,8 +90,8 @@ func (sw *storageWrapper) Create(key string, obj runtime.Object) error {
 	return nil
 }
 
-func (sw *storageWrapper) Delete(key string) error {
-	if err := sw.store.Delete(key); err != nil {
+func (sw *storageWrapper) Delete(ctx context.Context, key string) error {
+	if err := sw.store.Delete(ctx, key); err != nil {
 		return err
 	}
 
@@ -73,7 +104,27 @@ func (sw *storageWrapper) Delete(key string) error {
 	return nil
 }
 
-func (sw *storageWrapper) Get(key string) (runtime.Object, error) {
+// DeleteCollection deletes all objects under key, and the objects
+// under key in memory cache are dropped too.
+func (sw *storageWrapper) DeleteCollection(ctx context.Context, key string, force bool) error {
+	err := sw.store.DeleteCollection(ctx, key, force)
+	if err != nil && err != storage.ErrStorageAccessConflict {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(key, "/") + "/"
+	sw.Lock()
+	for k := range sw.cache {
+		if strings.HasPrefix(k, prefix) {
+			delete(sw.cache, k)
+		}
+	}
+	sw.Unlock()
+
+	return err
+}
+
+func (sw *storageWrapper) Get(ctx context.Context, key string) (runtime.Object, error) {
 	cachedKey := isCacheKey(key)
 	if cachedKey {
 		sw.RLock()
@@ -84,18 +135,21 @@ func (sw *storageWrapper) Get(key string) (runtime.Object, error) {
 		}
 	}
 
-	b, err := sw.store.Get(key)
+	b, err := sw.store.Get(ctx, key)
 	if err != nil {
-		klog.Errorf("could not get object for %s, %v", key, err)
+		if err != storage.ErrNotFound {
+			klog.Errorf("could not get object for %s, %v", key, err)
+		}
 		return nil, err
 	} else if len(b) == 0 {
-		return nil, nil
+		klog.Errorf("could not get object for %s, content is empty", key)
+		return nil, storage.ErrCorrupted
 	}
 
-	obj, gvk, err := sw.backendSerializer.Decode(b, nil, nil)
+	obj, gvk, err := sw.codec.Decode(b)
 	if err != nil {
 		klog.Errorf("could not decode %v for %s, %v", gvk, key, err)
-		return nil, err
+		return nil, storage.ErrCorrupted
 	}
 
 	if cachedKey {
@@ -106,40 +160,109 @@ func (sw *storageWrapper) Get(key string) (runtime.Object, error) {
 	return obj, nil
 }
 
-func (sw *storageWrapper) ListKeys(key string) ([]string, error) {
-	return sw.store.ListKeys(key)
+func (sw *storageWrapper) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return sw.store.ListKeys(ctx, key)
 }
 
-func (sw *storageWrapper) List(key string) ([]runtime.Object, error) {
-	objects := make([]runtime.Object, 0)
-	bb, err := sw.store.List(key)
+// List returns the objects under key in ascending lexical order of their keys,
+// the objects are read from storage in a batch.
+func (sw *storageWrapper) List(ctx context.Context, key string) ([]runtime.Object, error) {
+	keys, err := sw.store.ListKeys(ctx, key)
 	if err != nil {
 		klog.Errorf("could not list objects for %s, %v", key, err)
 		return nil, err
-	} else if len(bb) == 0 {
-		return objects, nil
 	}
 
-	for i := range bb {
-		obj, gvk, err := sw.backendSerializer.Decode(bb[i], nil, nil)
+	objs, err := sw.GetMulti(ctx, keys)
+	if err != nil {
+		klog.Errorf("could not list objects for %s, %v", key, err)
+		return nil, err
+	}
+
+	objects := make([]runtime.Object, 0, len(objs))
+	for _, k := range keys {
+		if obj, ok := objs[k]; ok {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects, nil
+}
+
+// GetMulti reads the objects of keys from storage in a batch, the objects of
+// keys in memory cache are returned from memory.
+func (sw *storageWrapper) GetMulti(ctx context.Context, keys []string) (map[string]runtime.Object, error) {
+	objs := make(map[string]runtime.Object, len(keys))
+	missing := make([]string, 0, len(keys))
+	sw.RLock()
+	for _, key := range keys {
+		if obj, ok := sw.cache[key]; ok && obj != nil {
+			objs[key] = obj
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	sw.RUnlock()
+	if len(missing) == 0 {
+		return objs, nil
+	}
+
+	contents, err := storage.GetMulti(ctx, sw.store, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, b := range contents {
+		obj, gvk, err := sw.codec.Decode(b)
 		if err != nil {
 			klog.Errorf("could not decode %v for %s, %v", gvk, key, err)
 			continue
 		}
-		objects = append(objects, obj)
+		objs[key] = obj
 	}
 
-	return objects, nil
+	return objs, nil
 }
 
-func (sw *storageWrapper) Update(key string, obj runtime.Object) error {
+// SetMulti encodes objs and writes them to storage in a batch
+func (sw *storageWrapper) SetMulti(ctx context.Context, objs map[string]runtime.Object) error {
+	contents := make(map[string][]byte, len(objs))
+	for key, obj := range objs {
+		var buf bytes.Buffer
+		if err := sw.encode(key, obj, &buf); err != nil {
+			klog.Errorf("failed to encode object in batch for %s, %v", key, err)
+			return err
+		}
+		contents[key] = buf.Bytes()
+	}
+
+	err := storage.SetMulti(ctx, sw.store, contents)
+
+	// the keys written before an error are kept in storage, so memory cache is
+	// refreshed by the next read of them.
+	sw.Lock()
+	for key, obj := range objs {
+		if !isCacheKey(key) {
+			continue
+		} else if err == nil {
+			sw.cache[key] = obj
+		} else {
+			delete(sw.cache, key)
+		}
+	}
+	sw.Unlock()
+
+	return err
+}
+
+func (sw *storageWrapper) Update(ctx context.Context, key string, obj runtime.Object) error {
 	var buf bytes.Buffer
-	if err := sw.backendSerializer.Encode(obj, &buf); err != nil {
+	if err := sw.encode(key, obj, &buf); err != nil {
 		klog.Errorf("failed to encode object in update for %s, %v", key, err)
 		return err
 	}
 
-	if err := sw.store.Update(key, buf.Bytes()); err != nil {
+	if err := sw.store.Update(ctx, key, buf.Bytes()); err != nil {
 		return err
 	}
 
@@ -152,12 +275,68 @@ func (sw *storageWrapper) Update(key string, obj runtime.Object) error {
 	return nil
 }
 
-func (sw *storageWrapper) GetRaw(key string) ([]byte, error) {
-	return sw.store.Get(key)
+// Replace encodes objs and replaces the objects under rootKey in storage with them,
+// the objects under rootKey in memory cache are replaced too.
+func (sw *storageWrapper) Replace(ctx context.Context, rootKey string, objs map[string]runtime.Object) error {
+	contents := make(map[string][]byte, len(objs))
+	for key, obj := range objs {
+		var buf bytes.Buffer
+		if err := sw.encode(key, obj, &buf); err != nil {
+			klog.Errorf("failed to encode object in replace for %s, %v", key, err)
+			return err
+		}
+		contents[key] = buf.Bytes()
+	}
+
+	if err := sw.store.Replace(ctx, rootKey, contents); err != nil {
+		return err
+	}
+
+	prefix := strings.TrimSuffix(rootKey, "/") + "/"
+	sw.Lock()
+	for k := range sw.cache {
+		if strings.HasPrefix(k, prefix) {
+			delete(sw.cache, k)
+		}
+	}
+	for key, obj := range objs {
+		if isCacheKey(key) {
+			sw.cache[key] = obj
+		}
+	}
+	sw.Unlock()
+
+	return nil
+}
+
+func (sw *storageWrapper) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	return sw.store.Get(ctx, key)
+}
+
+func (sw *storageWrapper) UpdateRaw(ctx context.Context, key string, contents []byte) error {
+	return sw.store.Update(ctx, key, contents)
+}
+
+// Touch marks the object of key as refreshed, like when it's received from cloud without changes
+func (sw *storageWrapper) Touch(ctx context.Context, key string) error {
+	return storage.Touch(ctx, sw.store, key)
+}
+
+// LockKey locks key of storage for a transaction, it's a no-op for the storage that
+// doesn't support locking keys.
+func (sw *storageWrapper) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return storage.LockKey(ctx, sw.store, key)
+}
+
+// UnlockKey unlocks key of storage that is locked by LockKey
+func (sw *storageWrapper) UnlockKey(ctx context.Context, key string) {
+	storage.UnlockKey(ctx, sw.store, key)
 }
 
-func (sw *storageWrapper) UpdateRaw(key string, contents []byte) error {
-	return sw.store.Update(key, contents)
+// encode encodes obj in the format selected for the resource of key
+func (sw *storageWrapper) encode(key string, obj runtime.Object, w io.Writer) error {
+	_, resource, _, _ := util.SplitKey(key)
+	return sw.codec.Encode(resource, obj, w)
 }
 
 // isCacheKey verify runtime object is cached for specified key.
@@ -0,0 +1,268 @@
+package cachemanager
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// maxTombstones bounds the number of deleted keys that are remembered, so the
+	// objects deleted recently are not cached again by lagging responses.
+	maxTombstones = 4096
+	// cloudSyncPeriod is the period that the resource version of cloud is observed
+	cloudSyncPeriod = time.Minute
+)
+
+var (
+	watermarkLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "watermark_lag",
+			Help:      "Gauge measuring the number of resource versions that the watermark of a component is behind cloud.",
+		},
+		[]string{"component"},
+	)
+	staleWrites = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "stale_writes_refused_total",
+			Help:      "Counter of the objects in responses that are not cached because they are older than the cache.",
+		},
+		[]string{"component"},
+	)
+	registerMetrics sync.Once
+)
+
+// ComponentWatermark is the highest resource version observed in the responses for a component
+type ComponentWatermark struct {
+	Component       string    `json:"component"`
+	ResourceVersion uint64    `json:"resourceVersion"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+	// Lag is the number of resource versions that the watermark is behind cloud
+	Lag uint64 `json:"lag"`
+}
+
+// WatermarkReport lists the watermarks of components and the resource version of cloud
+type WatermarkReport struct {
+	CloudResourceVersion uint64               `json:"cloudResourceVersion"`
+	CloudObservedAt      time.Time            `json:"cloudObservedAt"`
+	Components           []ComponentWatermark `json:"components"`
+}
+
+// Watermarks tracks the high-watermark of resource versions observed for each component,
+// and the resource versions of the objects that are deleted recently. resource versions
+// are compared as integers, they are opaque in kubernetes but are the revisions of etcd.
+// methods of nil Watermarks do nothing.
+type Watermarks struct {
+	sync.RWMutex
+	components      map[string]*ComponentWatermark
+	cloudRV         uint64
+	cloudObservedAt time.Time
+	tombstones      map[string]uint64
+	tombstoneKeys   []string
+}
+
+// NewWatermarks creates Watermarks and registers the metrics of watermarks
+func NewWatermarks() *Watermarks {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(watermarkLag)
+		prometheus.MustRegister(staleWrites)
+	})
+	return &Watermarks{
+		components: make(map[string]*ComponentWatermark),
+		tombstones: make(map[string]uint64),
+	}
+}
+
+func parseResourceVersion(rv string) uint64 {
+	n, _ := strconv.ParseUint(rv, 10, 64)
+	return n
+}
+
+// Observe raises the watermark of component to rv if rv is higher
+func (w *Watermarks) Observe(component, rv string) {
+	n := parseResourceVersion(rv)
+	if w == nil || n == 0 {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	wm, ok := w.components[component]
+	if !ok {
+		wm = &ComponentWatermark{Component: component}
+		w.components[component] = wm
+	}
+	if n > wm.ResourceVersion {
+		wm.ResourceVersion = n
+		wm.UpdatedAt = time.Now()
+		w.updateLag(wm)
+	}
+}
+
+// ObserveDeletion remembers that the object of key is deleted at rv
+func (w *Watermarks) ObserveDeletion(key, rv string) {
+	n := parseResourceVersion(rv)
+	if w == nil || n == 0 {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	if _, ok := w.tombstones[key]; !ok {
+		if len(w.tombstoneKeys) >= maxTombstones {
+			delete(w.tombstones, w.tombstoneKeys[0])
+			w.tombstoneKeys = w.tombstoneKeys[1:]
+		}
+		w.tombstoneKeys = append(w.tombstoneKeys, key)
+	}
+	if n > w.tombstones[key] {
+		w.tombstones[key] = n
+	}
+}
+
+// DeletedAfter returns true if the object of key is deleted at or after rv, so the
+// object in rv is from a lagging response and should not be cached again.
+func (w *Watermarks) DeletedAfter(key, rv string) bool {
+	if w == nil {
+		return false
+	}
+
+	w.RLock()
+	defer w.RUnlock()
+	deleted, ok := w.tombstones[key]
+	return ok && parseResourceVersion(rv) <= deleted
+}
+
+// RefuseStaleWrite records that an object older than the cache is not cached for component
+func (w *Watermarks) RefuseStaleWrite(component string) {
+	if w == nil {
+		return
+	}
+	staleWrites.WithLabelValues(component).Inc()
+}
+
+// SetCloudResourceVersion records the current resource version of cloud
+func (w *Watermarks) SetCloudResourceVersion(rv string) {
+	n := parseResourceVersion(rv)
+	if w == nil || n == 0 {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+	w.cloudRV = n
+	w.cloudObservedAt = time.Now()
+	for _, wm := range w.components {
+		w.updateLag(wm)
+	}
+}
+
+// updateLag updates the lag of watermark with the lock held
+func (w *Watermarks) updateLag(wm *ComponentWatermark) {
+	wm.Lag = 0
+	if w.cloudRV > wm.ResourceVersion {
+		wm.Lag = w.cloudRV - wm.ResourceVersion
+	}
+	watermarkLag.WithLabelValues(wm.Component).Set(float64(wm.Lag))
+}
+
+// Report returns the watermarks of components sorted by component
+func (w *Watermarks) Report() *WatermarkReport {
+	report := &WatermarkReport{Components: []ComponentWatermark{}}
+	if w == nil {
+		return report
+	}
+
+	w.RLock()
+	defer w.RUnlock()
+	report.CloudResourceVersion = w.cloudRV
+	report.CloudObservedAt = w.cloudObservedAt
+	for _, wm := range w.components {
+		report.Components = append(report.Components, *wm)
+	}
+	sort.Slice(report.Components, func(i, j int) bool {
+		return report.Components[i].Component < report.Components[j].Component
+	})
+	return report
+}
+
+// WatermarkSyncer observes the current resource version of cloud periodically when
+// remote servers are healthy, so the lag of watermarks can be measured. the node is
+// listed from the watch cache of kube-apiserver, which is cheap for kube-apiserver.
+type WatermarkSyncer struct {
+	nodeName         string
+	watermarks       *Watermarks
+	remoteServers    []*url.URL
+	healthChecker    healthchecker.HealthChecker
+	transportManager transport.Interface
+	stopCh           <-chan struct{}
+}
+
+// NewWatermarkSyncer creates a WatermarkSyncer for watermarks
+func NewWatermarkSyncer(cfg *config.YurtHubConfiguration,
+	watermarks *Watermarks,
+	transportManager transport.Interface,
+	healthChecker healthchecker.HealthChecker,
+	stopCh <-chan struct{}) *WatermarkSyncer {
+	return &WatermarkSyncer{
+		nodeName:         cfg.NodeName,
+		watermarks:       watermarks,
+		remoteServers:    cfg.RemoteServers,
+		healthChecker:    healthChecker,
+		transportManager: transportManager,
+		stopCh:           stopCh,
+	}
+}
+
+// Run observes the resource version of cloud until stopCh is closed
+func (s *WatermarkSyncer) Run() {
+	go wait.Until(s.sync, cloudSyncPeriod, s.stopCh)
+}
+
+func (s *WatermarkSyncer) sync() {
+	healthy := false
+	for _, server := range s.remoteServers {
+		if s.healthChecker.IsHealthy(server) {
+			healthy = true
+			break
+		}
+	}
+	if !healthy {
+		return
+	}
+
+	cfg := s.transportManager.GetRestClientConfig()
+	if cfg == nil {
+		return
+	}
+	kubeClient, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Errorf("could not new kube client, %v", err)
+		return
+	}
+
+	nodes, err := kubeClient.CoreV1().Nodes().List(metav1.ListOptions{
+		FieldSelector:   fields.OneTermEqualSelector("metadata.name", s.nodeName).String(),
+		ResourceVersion: "0",
+	})
+	if err != nil {
+		klog.Errorf("failed to observe resource version of cloud, %v", err)
+		return
+	}
+	s.watermarks.SetCloudResourceVersion(nodes.ResourceVersion)
+}
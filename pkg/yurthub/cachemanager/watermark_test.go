@@ -0,0 +1,109 @@
+package cachemanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWatermarks(t *testing.T) {
+	w := NewWatermarks()
+	w.Observe("kubelet", "10")
+	w.Observe("kubelet", "8")
+	w.Observe("kubelet", "invalid")
+	w.Observe("kube-proxy", "5")
+	w.SetCloudResourceVersion("12")
+
+	report := w.Report()
+	if report.CloudResourceVersion != 12 || len(report.Components) != 2 {
+		t.Fatalf("unexpected watermark report %#v", report)
+	}
+	expected := map[string][2]uint64{"kube-proxy": {5, 7}, "kubelet": {10, 2}}
+	for _, wm := range report.Components {
+		if e := expected[wm.Component]; wm.ResourceVersion != e[0] || wm.Lag != e[1] {
+			t.Errorf("expect watermark %d and lag %d for %s, but got %d and %d", e[0], e[1], wm.Component, wm.ResourceVersion, wm.Lag)
+		}
+	}
+	if report.Components[0].Component != "kube-proxy" {
+		t.Errorf("expect watermarks are sorted by component, but got %#v", report.Components)
+	}
+
+	// the watermark ahead of cloud is not lagging
+	w.Observe("kubelet", "15")
+	if wm := w.Report().Components[1]; wm.Lag != 0 {
+		t.Errorf("expect no lag for kubelet, but got %d", wm.Lag)
+	}
+
+	var nilWatermarks *Watermarks
+	nilWatermarks.Observe("kubelet", "1")
+	if nilWatermarks.DeletedAfter("kubelet/pods/default/pod1", "1") || len(nilWatermarks.Report().Components) != 0 {
+		t.Errorf("expect nil watermarks do nothing")
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	w := NewWatermarks()
+	w.ObserveDeletion("kubelet/pods/default/pod1", "10")
+
+	testcases := map[string]struct {
+		key     string
+		rv      string
+		deleted bool
+	}{
+		"lagging object":    {key: "kubelet/pods/default/pod1", rv: "9", deleted: true},
+		"deleted object":    {key: "kubelet/pods/default/pod1", rv: "10", deleted: true},
+		"re-created object": {key: "kubelet/pods/default/pod1", rv: "11"},
+		"other object":      {key: "kubelet/pods/default/pod2", rv: "9"},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if deleted := w.DeletedAfter(tt.key, tt.rv); deleted != tt.deleted {
+				t.Errorf("expect deleted %v, but got %v", tt.deleted, deleted)
+			}
+		})
+	}
+
+	for i := 0; i < maxTombstones; i++ {
+		w.ObserveDeletion(fmt.Sprintf("kubelet/pods/default/pod-%d", i), "20")
+	}
+	if w.DeletedAfter("kubelet/pods/default/pod1", "9") || len(w.tombstones) != maxTombstones {
+		t.Errorf("expect the oldest tombstone is dropped, but got %d tombstones", len(w.tombstones))
+	}
+}
+
+func TestRefuseStaleWrites(t *testing.T) {
+	sw := NewFakeStorageWrapper()
+	cm := &cacheManager{storage: sw, watermarks: NewWatermarks()}
+	newPod := func(rv string) *v1.Pod {
+		return &v1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1", ResourceVersion: rv},
+		}
+	}
+	key := "kubelet/pods/default/pod1"
+
+	if err := cm.saveOneObjectWithValidation(key, newPod("10")); err != nil {
+		t.Fatalf("failed to save pod, %v", err)
+	}
+	// the response of a lagging retry doesn't overwrite the newer object
+	cm.saveOneObjectWithValidation(key, newPod("8"))
+	if obj, _ := sw.Get(context.Background(), key); obj.(*v1.Pod).ResourceVersion != "10" {
+		t.Errorf("expect pod in resource version 10, but got %s", obj.(*v1.Pod).ResourceVersion)
+	}
+
+	// the deleted object is not cached again by a lagging response
+	cm.watermarks.ObserveDeletion(key, "12")
+	cm.deleteObject(key)
+	cm.saveOneObjectWithValidation(key, newPod("10"))
+	if _, err := sw.Get(context.Background(), key); err == nil {
+		t.Errorf("expect deleted pod is not cached again")
+	}
+
+	cm.saveOneObjectWithValidation(key, newPod("13"))
+	if _, err := sw.Get(context.Background(), key); err != nil {
+		t.Errorf("expect re-created pod is cached, but got %v", err)
+	}
+}
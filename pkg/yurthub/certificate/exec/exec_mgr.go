@@ -0,0 +1,420 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/certificate"
+	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/klog"
+)
+
+const (
+	CertificateManagerName = "exec"
+	defaultCaFile          = "/etc/kubernetes/pki/ca.crt"
+	// credentialKey is the key in storage that the last issued credential is cached,
+	// so it's used within its ttl after yurthub restarts while the plugin can't issue
+	// a new one(like the cloud IAM service is unreachable).
+	credentialKey = "_internal/exec-credential/credential.json"
+	execInfoEnv   = "KUBERNETES_EXEC_INFO"
+	execTimeout   = 30 * time.Second
+	refreshPeriod = 10 * time.Second
+	// a credential is refreshed after refreshRatio of its lifetime is passed
+	refreshRatio = 0.8
+)
+
+func Register(cmr *certificate.CertificateManagerRegistry) {
+	cmr.Register(CertificateManagerName, func(cfg *config.YurtHubConfiguration) (interfaces.YurtCertificateManager, error) {
+		return NewExecCertManager(cfg)
+	})
+}
+
+// credential is the status of ExecCredential issued by the plugin with the time it's issued
+type credential struct {
+	Token                 string       `json:"token,omitempty"`
+	ClientCertificateData string       `json:"clientCertificateData,omitempty"`
+	ClientKeyData         string       `json:"clientKeyData,omitempty"`
+	ExpirationTimestamp   *metav1.Time `json:"expirationTimestamp,omitempty"`
+	IssuedAt              metav1.Time  `json:"issuedAt"`
+
+	cert *tls.Certificate
+}
+
+// expired returns true if the credential is expired at now, credentials without
+// expiration are used until they are rejected by remote servers.
+func (c *credential) expired(now time.Time) bool {
+	return c.ExpirationTimestamp != nil && !now.Before(c.ExpirationTimestamp.Time)
+}
+
+// needRefresh returns true if the credential should be replaced by a new one at now
+func (c *credential) needRefresh(now time.Time) bool {
+	if c.ExpirationTimestamp == nil {
+		return false
+	}
+	lifetime := c.ExpirationTimestamp.Sub(c.IssuedAt.Time)
+	return !now.Before(c.IssuedAt.Add(time.Duration(float64(lifetime) * refreshRatio)))
+}
+
+// execCertManager authenticates yurthub to remote servers by the credentials issued by an
+// exec credential plugin(like cloud IAM authenticators) that is set in a kubeconfig, for the
+// managed clusters where the client certificates of kubelet are not available. the issued
+// credential is cached in storage and used within its ttl when the plugin fails.
+type execCertManager struct {
+	credLock      sync.RWMutex
+	refreshLock   sync.Mutex
+	execConfig    *clientcmdapi.ExecConfig
+	caFile        string
+	remoteServers []*url.URL
+	checker       healthchecker.HealthChecker
+	store         storage.Store
+	cred          *credential
+	// tokenOnly is returned by Current when the credential has no client certificate
+	tokenOnly *tls.Certificate
+	run       func(ctx context.Context) ([]byte, error)
+	now       func() time.Time
+	stopCh    chan struct{}
+	stopped   bool
+}
+
+// NewExecCertManager creates a cert manager with the exec plugin of the current context in
+// the kubeconfig of cfg, the certificate authority of the cluster is used as ca file.
+func NewExecCertManager(cfg *config.YurtHubConfiguration) (interfaces.YurtCertificateManager, error) {
+	if cfg == nil || len(cfg.RemoteServers) == 0 {
+		return nil, fmt.Errorf("yurthub configuration is invalid")
+	}
+
+	execConfig, caFile, err := loadExecConfig(cfg.ExecKubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ecm := &execCertManager{
+		execConfig:    execConfig,
+		caFile:        caFile,
+		remoteServers: cfg.RemoteServers,
+		tokenOnly:     &tls.Certificate{},
+		now:           time.Now,
+		stopCh:        make(chan struct{}),
+	}
+	ecm.run = ecm.runPlugin
+	return ecm, nil
+}
+
+// loadExecConfig returns the exec config of the user and the certificate authority of the
+// cluster in the current context of kubeconfig.
+func loadExecConfig(kubeconfig string) (*clientcmdapi.ExecConfig, string, error) {
+	if len(kubeconfig) == 0 {
+		return nil, "", fmt.Errorf("kubeconfig of exec credential plugin is not set")
+	}
+
+	kc, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig %s, %v", kubeconfig, err)
+	}
+
+	ctx, ok := kc.Contexts[kc.CurrentContext]
+	if !ok {
+		return nil, "", fmt.Errorf("current context %q is not found in kubeconfig %s", kc.CurrentContext, kubeconfig)
+	}
+	authInfo, ok := kc.AuthInfos[ctx.AuthInfo]
+	if !ok || authInfo.Exec == nil {
+		return nil, "", fmt.Errorf("exec credential plugin of user %q is not set in kubeconfig %s", ctx.AuthInfo, kubeconfig)
+	}
+
+	caFile := defaultCaFile
+	if cluster, ok := kc.Clusters[ctx.Cluster]; ok && len(cluster.CertificateAuthority) != 0 {
+		caFile = cluster.CertificateAuthority
+	}
+	return authInfo.Exec, caFile, nil
+}
+
+func (ecm *execCertManager) SetHealthChecker(checker healthchecker.HealthChecker) {
+	ecm.checker = checker
+}
+
+func (ecm *execCertManager) SetStorage(store storage.Store) {
+	ecm.store = store
+}
+
+func (ecm *execCertManager) Stop() {
+	ecm.credLock.Lock()
+	defer ecm.credLock.Unlock()
+	if ecm.stopped {
+		return
+	}
+	close(ecm.stopCh)
+	ecm.stopped = true
+}
+
+// Start loads the cached credential and refreshes the credential in background
+func (ecm *execCertManager) Start() {
+	ecm.loadCache()
+	go wait.Until(ecm.refresh, refreshPeriod, ecm.stopCh)
+}
+
+// Current returns the client certificate of credential, or an empty certificate
+// if the credential only has a token. nil is returned if there is no valid credential.
+func (ecm *execCertManager) Current() *tls.Certificate {
+	ecm.credLock.RLock()
+	defer ecm.credLock.RUnlock()
+	if ecm.cred == nil || ecm.cred.expired(ecm.now()) {
+		return nil
+	}
+	if ecm.cred.cert != nil {
+		return ecm.cred.cert
+	}
+	return ecm.tokenOnly
+}
+
+func (ecm *execCertManager) Token() string {
+	ecm.credLock.RLock()
+	defer ecm.credLock.RUnlock()
+	if ecm.cred == nil || ecm.cred.expired(ecm.now()) {
+		return ""
+	}
+	return ecm.cred.Token
+}
+
+func (ecm *execCertManager) InvalidateToken(token string) {
+	ecm.credLock.Lock()
+	invalidated := ecm.cred != nil && ecm.cred.Token == token
+	if invalidated {
+		klog.Infof("token issued by exec credential plugin is rejected, issue a new one")
+		ecm.cred = nil
+	}
+	ecm.credLock.Unlock()
+
+	if invalidated {
+		go ecm.refresh()
+	}
+}
+
+func (ecm *execCertManager) ServerHealthy() bool {
+	return true
+}
+
+func (ecm *execCertManager) GetCaFile() string {
+	return ecm.caFile
+}
+
+func (ecm *execCertManager) GetRestConfig() *rest.Config {
+	var s *url.URL
+	for _, server := range ecm.remoteServers {
+		if ecm.checker.IsHealthy(server) {
+			s = server
+		}
+	}
+	if s == nil {
+		return nil
+	}
+
+	cfg := &rest.Config{
+		Host: s.String(),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAFile: ecm.caFile,
+		},
+	}
+	ecm.credLock.RLock()
+	if ecm.cred != nil && ecm.cred.cert != nil {
+		cfg.TLSClientConfig.CertData = []byte(ecm.cred.ClientCertificateData)
+		cfg.TLSClientConfig.KeyData = []byte(ecm.cred.ClientKeyData)
+	}
+	ecm.credLock.RUnlock()
+	cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		return transport.NewBearerTokenRoundTripper(ecm, rt)
+	}
+	return cfg
+}
+
+func (ecm *execCertManager) NotExpired() bool {
+	ecm.credLock.RLock()
+	defer ecm.credLock.RUnlock()
+	if ecm.cred == nil || ecm.cred.expired(ecm.now()) {
+		klog.V(2).Infof("Current credential is expired.")
+		return false
+	}
+	return true
+}
+
+func (ecm *execCertManager) Update(cfg *config.YurtHubConfiguration) error {
+	return nil
+}
+
+// loadCache loads the credential cached in storage if it's not expired
+func (ecm *execCertManager) loadCache() {
+	if ecm.store == nil {
+		return
+	}
+
+	b, err := ecm.store.Get(context.Background(), credentialKey)
+	if err == storage.ErrNotFound {
+		return
+	} else if err != nil {
+		klog.Errorf("failed to load cached exec credential, %v", err)
+		return
+	}
+
+	cred := &credential{}
+	if err := json.Unmarshal(b, cred); err != nil {
+		klog.Errorf("failed to decode cached exec credential, %v", err)
+		return
+	}
+	if err := parseCert(cred); err != nil {
+		klog.Errorf("cached exec credential is invalid, %v", err)
+		return
+	}
+	if cred.expired(ecm.now()) {
+		klog.Infof("cached exec credential is expired at %s", cred.ExpirationTimestamp)
+		return
+	}
+
+	klog.Infof("use cached exec credential issued at %s", cred.IssuedAt)
+	ecm.credLock.Lock()
+	ecm.cred = cred
+	ecm.credLock.Unlock()
+}
+
+// refresh issues a new credential by the plugin if there is no credential or the current one
+// is going to expire. the current credential is kept if the plugin fails, so it's used within
+// its ttl while the plugin can't reach the cloud.
+func (ecm *execCertManager) refresh() {
+	ecm.refreshLock.Lock()
+	defer ecm.refreshLock.Unlock()
+
+	ecm.credLock.RLock()
+	cred := ecm.cred
+	ecm.credLock.RUnlock()
+	if cred != nil && !cred.needRefresh(ecm.now()) {
+		return
+	}
+
+	newCred, err := ecm.issue()
+	if err != nil {
+		if cred != nil && !cred.expired(ecm.now()) {
+			klog.Warningf("failed to refresh exec credential, use the current one until %s, %v", cred.ExpirationTimestamp, err)
+		} else {
+			klog.Errorf("failed to issue exec credential, %v", err)
+		}
+		return
+	}
+
+	ecm.credLock.Lock()
+	ecm.cred = newCred
+	ecm.credLock.Unlock()
+	klog.Infof("exec credential is issued, expires at %v", newCred.ExpirationTimestamp)
+
+	if ecm.store == nil {
+		return
+	}
+	b, err := json.Marshal(newCred)
+	if err != nil {
+		klog.Errorf("failed to encode exec credential, %v", err)
+		return
+	}
+	if err := ecm.store.Update(context.Background(), credentialKey, b); err != nil {
+		klog.Errorf("failed to cache exec credential, %v", err)
+	}
+}
+
+// issue runs the plugin and decodes the ExecCredential from its output
+func (ecm *execCertManager) issue() (*credential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+
+	out, err := ecm.run(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var execCred struct {
+		metav1.TypeMeta `json:",inline"`
+		Status          *credential `json:"status,omitempty"`
+	}
+	if err := json.Unmarshal(out, &execCred); err != nil {
+		return nil, fmt.Errorf("failed to decode output of plugin %s, %v", ecm.execConfig.Command, err)
+	}
+	if execCred.Kind != "ExecCredential" || execCred.APIVersion != ecm.execConfig.APIVersion {
+		return nil, fmt.Errorf("plugin %s returns %s of %s, expect ExecCredential of %s",
+			ecm.execConfig.Command, execCred.Kind, execCred.APIVersion, ecm.execConfig.APIVersion)
+	}
+
+	cred := execCred.Status
+	if cred == nil || (len(cred.Token) == 0 && len(cred.ClientCertificateData) == 0) {
+		return nil, fmt.Errorf("plugin %s returns neither token nor client certificate", ecm.execConfig.Command)
+	}
+	if err := parseCert(cred); err != nil {
+		return nil, err
+	}
+	cred.IssuedAt = metav1.NewTime(ecm.now())
+	if cred.expired(cred.IssuedAt.Time) {
+		return nil, fmt.Errorf("plugin %s returns an expired credential", ecm.execConfig.Command)
+	}
+	return cred, nil
+}
+
+// runPlugin runs the command of exec config with its args and envs
+func (ecm *execCertManager) runPlugin(ctx context.Context) ([]byte, error) {
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": ecm.execConfig.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	env := append(os.Environ(), fmt.Sprintf("%s=%s", execInfoEnv, info))
+	for _, e := range ecm.execConfig.Env {
+		env = append(env, fmt.Sprintf("%s=%s", e.Name, e.Value))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := osexec.CommandContext(ctx, ecm.execConfig.Command, ecm.execConfig.Args...)
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to run plugin %s, %v: %s", ecm.execConfig.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseCert parses the client certificate of credential if it's set
+func parseCert(cred *credential) error {
+	if len(cred.ClientCertificateData) == 0 && len(cred.ClientKeyData) == 0 {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair([]byte(cred.ClientCertificateData), []byte(cred.ClientKeyData))
+	if err != nil {
+		return fmt.Errorf("failed to load client certificate of exec credential, %v", err)
+	}
+	certs, err := x509.ParseCertificates(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("unable to parse certificate data: %v", err)
+	}
+	cert.Leaf = certs[0]
+	cred.cert = &cert
+	return nil
+}
@@ -3,19 +3,27 @@ package initializer
 import (
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
 	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 )
 
 type WantsHealthChecker interface {
 	SetHealthChecker(checker healthchecker.HealthChecker)
 }
 
+// WantsStorage is implemented by certificate managers that keep credentials in storage
+type WantsStorage interface {
+	SetStorage(store storage.Store)
+}
+
 type CertificateManagerInitializer struct {
 	checker healthchecker.HealthChecker
+	store   storage.Store
 }
 
-func NewCMInitializer(checker healthchecker.HealthChecker) *CertificateManagerInitializer {
+func NewCMInitializer(checker healthchecker.HealthChecker, store storage.Store) *CertificateManagerInitializer {
 	return &CertificateManagerInitializer{
 		checker: checker,
+		store:   store,
 	}
 }
 
@@ -23,4 +31,7 @@ func (cmi *CertificateManagerInitializer) Initialize(cm interfaces.YurtCertifica
 	if wants, ok := cm.(WantsHealthChecker); ok {
 		wants.SetHealthChecker(cmi.checker)
 	}
+	if wants, ok := cm.(WantsStorage); ok {
+		wants.SetStorage(cmi.store)
+	}
 }
@@ -14,3 +14,13 @@ type YurtCertificateManager interface {
 	GetCaFile() string
 	NotExpired() bool
 }
+
+// TokenSource is implemented by certificate managers that authenticate to remote
+// servers by bearer tokens, the token is set on each request to remote servers.
+type TokenSource interface {
+	// Token returns the current bearer token, empty if there is no valid token
+	Token() string
+	// InvalidateToken drops token after it's rejected by remote servers, so a new
+	// token is issued instead of using it until it's expired.
+	InvalidateToken(token string)
+}
@@ -4,8 +4,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,8 +18,10 @@ import (
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/util"
 
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 )
 
@@ -27,6 +31,9 @@ const (
 	defaultPairFile        = "kubelet-client-current.pem"
 	defaultCaFile          = "/etc/kubernetes/pki/ca.crt"
 	certVerifyDuration     = 30 * time.Minute
+	// bootstrapCheckPeriod is the period that the cert file is checked while yurthub
+	// authenticates by the bootstrap token, so the issued cert is used soon.
+	bootstrapCheckPeriod = 5 * time.Second
 )
 
 func Register(cmr *certificate.CertificateManagerRegistry) {
@@ -35,16 +42,29 @@ func Register(cmr *certificate.CertificateManagerRegistry) {
 	})
 }
 
+// kubeletCertManager uses the client certificate of kubelet. when the certificate is not
+// issued yet(like the first boot of node) or is expired(like an emergency re-bootstrap),
+// the bootstrap token of kubelet is used instead if bootstrap kubeconfig is set, so kubelet
+// can request its certificate through yurthub. yurthub switches to the certificate once it's
+// issued, and back to the token if it expires, without restarting.
 type kubeletCertManager struct {
-	certAccessLock     sync.RWMutex
-	pairFile           string
-	cert               *tls.Certificate
-	stopCh             chan struct{}
-	remoteServers      []*url.URL
-	caFile             string
-	certVerifyDuration time.Duration
-	checker            healthchecker.HealthChecker
-	stopped            bool
+	certAccessLock      sync.RWMutex
+	pairFile            string
+	cert                *tls.Certificate
+	stopCh              chan struct{}
+	remoteServers       []*url.URL
+	caFile              string
+	certVerifyDuration  time.Duration
+	lastVerify          time.Time
+	bootstrapKubeconfig string
+	bootstrapToken      string
+	// tokenOnly is returned by Current while the bootstrap token is used
+	tokenOnly *tls.Certificate
+	checker   healthchecker.HealthChecker
+	stopped   bool
+	// clock is replaced by a fake clock in tests, so the expiration and rotation
+	// of certificates can be simulated.
+	clock clock.Clock
 }
 
 func NewKubeletCertManager(cfg *config.YurtHubConfiguration, period time.Duration, certDir string) (interfaces.YurtCertificateManager, error) {
@@ -73,14 +93,22 @@ func NewKubeletCertManager(cfg *config.YurtHubConfiguration, period time.Duratio
 		}
 	}
 
-	return &kubeletCertManager{
-		pairFile:           pairFile,
-		cert:               cert,
-		remoteServers:      cfg.RemoteServers,
-		caFile:             defaultCaFile,
-		certVerifyDuration: period,
-		stopCh:             make(chan struct{}),
-	}, nil
+	kcm := &kubeletCertManager{
+		pairFile:            pairFile,
+		cert:                cert,
+		remoteServers:       cfg.RemoteServers,
+		caFile:              defaultCaFile,
+		certVerifyDuration:  period,
+		lastVerify:          time.Now(),
+		clock:               clock.RealClock{},
+		bootstrapKubeconfig: cfg.BootstrapKubeconfig,
+		tokenOnly:           &tls.Certificate{},
+		stopCh:              make(chan struct{}),
+	}
+	if !kcm.certValid() {
+		kcm.loadBootstrapToken()
+	}
+	return kcm, nil
 }
 
 func (kcm *kubeletCertManager) SetHealthChecker(checker healthchecker.HealthChecker) {
@@ -98,16 +126,33 @@ func (kcm *kubeletCertManager) Stop() {
 }
 
 func (kcm *kubeletCertManager) Start() {
-	go wait.Until(func() {
-		newCert, err := loadFile(kcm.pairFile)
-		if err != nil {
+	period := kcm.certVerifyDuration
+	if len(kcm.bootstrapKubeconfig) != 0 && period > bootstrapCheckPeriod {
+		period = bootstrapCheckPeriod
+	}
+	go wait.Until(kcm.sync, period, kcm.stopCh)
+}
+
+// sync loads the cert file every certVerifyDuration, or every bootstrapCheckPeriod while
+// there is no valid cert, and reloads the bootstrap token if the cert is not valid.
+func (kcm *kubeletCertManager) sync() {
+	valid := kcm.certValid()
+	if valid && kcm.clock.Since(kcm.lastVerify) < kcm.certVerifyDuration {
+		return
+	}
+	kcm.lastVerify = kcm.clock.Now()
+
+	newCert, err := loadFile(kcm.pairFile)
+	if err != nil {
+		if valid || len(kcm.bootstrapKubeconfig) == 0 {
 			klog.Errorf("failed to load cert file %s, %v", kcm.pairFile, err)
-			return
+		} else {
+			klog.V(4).Infof("cert file %s is not ready, %v", kcm.pairFile, err)
 		}
-
+	} else {
 		certChanged := true
 		kcm.certAccessLock.RLock()
-		if kcm.cert.Leaf.NotAfter.Equal(newCert.Leaf.NotAfter) {
+		if kcm.cert != nil && kcm.cert.Leaf.NotAfter.Equal(newCert.Leaf.NotAfter) {
 			certChanged = false
 		}
 		kcm.certAccessLock.RUnlock()
@@ -116,16 +161,79 @@ func (kcm *kubeletCertManager) Start() {
 			klog.Infof("cert file %s is updated", kcm.pairFile)
 			kcm.updateCert(newCert)
 		}
+	}
 
-	}, kcm.certVerifyDuration, kcm.stopCh)
+	if kcm.certValid() {
+		kcm.setBootstrapToken("")
+	} else {
+		kcm.loadBootstrapToken()
+	}
 }
 
+// Current returns the cert of kubelet, or an empty cert while the bootstrap token is used
 func (kcm *kubeletCertManager) Current() *tls.Certificate {
 	kcm.certAccessLock.RLock()
 	defer kcm.certAccessLock.RUnlock()
+	if len(kcm.bootstrapToken) != 0 && !certNotExpired(kcm.cert, kcm.clock.Now()) {
+		return kcm.tokenOnly
+	}
 	return kcm.cert
 }
 
+// Token returns the bootstrap token if there is no valid cert
+func (kcm *kubeletCertManager) Token() string {
+	kcm.certAccessLock.RLock()
+	defer kcm.certAccessLock.RUnlock()
+	if certNotExpired(kcm.cert, kcm.clock.Now()) {
+		return ""
+	}
+	return kcm.bootstrapToken
+}
+
+// InvalidateToken drops the rejected bootstrap token, it's reloaded from bootstrap kubeconfig
+// in the next sync, so a token replaced in the kubeconfig is picked up.
+func (kcm *kubeletCertManager) InvalidateToken(token string) {
+	kcm.certAccessLock.Lock()
+	defer kcm.certAccessLock.Unlock()
+	if len(token) != 0 && kcm.bootstrapToken == token {
+		klog.Warningf("bootstrap token in %s is rejected", kcm.bootstrapKubeconfig)
+		kcm.bootstrapToken = ""
+	}
+}
+
+func (kcm *kubeletCertManager) loadBootstrapToken() {
+	if len(kcm.bootstrapKubeconfig) == 0 {
+		return
+	}
+
+	token, err := loadBootstrapToken(kcm.bootstrapKubeconfig)
+	if err != nil {
+		klog.Errorf("failed to load bootstrap token, %v", err)
+		return
+	}
+	kcm.setBootstrapToken(token)
+}
+
+func (kcm *kubeletCertManager) setBootstrapToken(token string) {
+	kcm.certAccessLock.Lock()
+	defer kcm.certAccessLock.Unlock()
+	if kcm.bootstrapToken == token {
+		return
+	}
+	if len(token) != 0 {
+		klog.Infof("no valid cert in %s, use bootstrap token in %s", kcm.pairFile, kcm.bootstrapKubeconfig)
+	} else if len(kcm.bootstrapToken) != 0 {
+		klog.Infof("switch from bootstrap token to cert in %s", kcm.pairFile)
+	}
+	kcm.bootstrapToken = token
+}
+
+func (kcm *kubeletCertManager) certValid() bool {
+	kcm.certAccessLock.RLock()
+	defer kcm.certAccessLock.RUnlock()
+	return certNotExpired(kcm.cert, kcm.clock.Now())
+}
+
 func (kcm *kubeletCertManager) ServerHealthy() bool {
 	return true
 }
@@ -156,7 +264,7 @@ func (kcm *kubeletCertManager) GetRestConfig() *rest.Config {
 func (kcm *kubeletCertManager) NotExpired() bool {
 	kcm.certAccessLock.RLock()
 	defer kcm.certAccessLock.RUnlock()
-	if kcm.cert == nil || kcm.cert.Leaf == nil || time.Now().After(kcm.cert.Leaf.NotAfter) {
+	if !certNotExpired(kcm.cert, kcm.clock.Now()) && len(kcm.bootstrapToken) == 0 {
 		klog.V(2).Infof("Current certificate is expired.")
 		return false
 	}
@@ -173,6 +281,39 @@ func (ecm *kubeletCertManager) Update(cfg *config.YurtHubConfiguration) error {
 	return nil
 }
 
+func certNotExpired(cert *tls.Certificate, now time.Time) bool {
+	return cert != nil && cert.Leaf != nil && !now.After(cert.Leaf.NotAfter)
+}
+
+// loadBootstrapToken returns the token of the current user in bootstrap kubeconfig
+func loadBootstrapToken(kubeconfig string) (string, error) {
+	kc, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return "", fmt.Errorf("could not load bootstrap kubeconfig %s, %v", kubeconfig, err)
+	}
+
+	ctx, ok := kc.Contexts[kc.CurrentContext]
+	if !ok {
+		return "", fmt.Errorf("current context %q is not found in %s", kc.CurrentContext, kubeconfig)
+	}
+	authInfo, ok := kc.AuthInfos[ctx.AuthInfo]
+	if !ok {
+		return "", fmt.Errorf("user %q is not found in %s", ctx.AuthInfo, kubeconfig)
+	}
+
+	if len(authInfo.Token) != 0 {
+		return authInfo.Token, nil
+	}
+	if len(authInfo.TokenFile) != 0 {
+		b, err := ioutil.ReadFile(authInfo.TokenFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+	return "", fmt.Errorf("token of user %q is not set in %s", ctx.AuthInfo, kubeconfig)
+}
+
 func loadFile(pairFile string) (*tls.Certificate, error) {
 	cert, err := tls.LoadX509KeyPair(pairFile, pairFile)
 	if err != nil {
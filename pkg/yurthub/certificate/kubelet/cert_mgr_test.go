@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	yurttesting "github.com/alibaba/openyurt/pkg/yurthub/testing"
 )
 
 var storeCertData = newCertificateData(`-----BEGIN CERTIFICATE-----
@@ -145,3 +146,116 @@ func TestCurrent(t *testing.T) {
 		t.Errorf("Got an empty leaf, expected private data")
 	}
 }
+
+func TestBootstrapToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-test-bootstrap-token")
+	if err != nil {
+		t.Fatalf("Unable to create the test directory %q: %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	bootstrapKubeconfig := filepath.Join(dir, "bootstrap-kubelet.conf")
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: default-cluster
+  cluster:
+    server: https://10.0.0.1:6443
+users:
+- name: tls-bootstrap-token-user
+  user:
+    token: abcdef.0123456789abcdef
+contexts:
+- name: tls-bootstrap-token-user@kubernetes
+  context:
+    cluster: default-cluster
+    user: tls-bootstrap-token-user
+current-context: tls-bootstrap-token-user@kubernetes
+`
+	if err := ioutil.WriteFile(bootstrapKubeconfig, []byte(kubeconfig), 0600); err != nil {
+		t.Fatalf("Unable to create the file %q: %v", bootstrapKubeconfig, err)
+	}
+
+	u, _ := url.Parse("http://127.0.0.1:8080")
+	cfg := &config.YurtHubConfiguration{
+		RemoteServers:       []*url.URL{u},
+		BootstrapKubeconfig: bootstrapKubeconfig,
+	}
+	m, err := NewKubeletCertManager(cfg, 10*time.Second, dir)
+	if err != nil {
+		t.Fatalf("failed to new kubelet cert manager, %v", err)
+	}
+	kcm := m.(*kubeletCertManager)
+
+	// first boot, the cert of kubelet is not issued yet
+	if m.Current() != kcm.tokenOnly || kcm.Token() != "abcdef.0123456789abcdef" || !m.NotExpired() {
+		t.Fatalf("expect bootstrap token is used before cert is issued")
+	}
+
+	// the cert is issued
+	pairFile := filepath.Join(dir, "kubelet-client-current.pem")
+	certData := bytes.Join([][]byte{storeCertData.certificatePEM, storeCertData.keyPEM}, []byte("\n"))
+	if err := ioutil.WriteFile(pairFile, certData, 0600); err != nil {
+		t.Fatalf("Unable to create the file %q: %v", pairFile, err)
+	}
+	kcm.sync()
+	if cert := m.Current(); cert == nil || cert == kcm.tokenOnly || kcm.Token() != "" {
+		t.Fatalf("expect cert is used after it's issued")
+	}
+
+	// the cert is expired, and kubelet re-bootstraps
+	expired := *storeCertData.certificate
+	leaf := *expired.Leaf
+	leaf.NotAfter = time.Now().Add(-time.Hour)
+	expired.Leaf = &leaf
+	kcm.updateCert(&expired)
+	os.Remove(pairFile)
+	kcm.sync()
+	if m.Current() != kcm.tokenOnly || kcm.Token() != "abcdef.0123456789abcdef" || !m.NotExpired() {
+		t.Errorf("expect bootstrap token is used after cert is expired")
+	}
+
+	// the bootstrap token is rejected
+	kcm.InvalidateToken("abcdef.0123456789abcdef")
+	if m.NotExpired() {
+		t.Errorf("expect no valid credential after bootstrap token is rejected")
+	}
+}
+
+func TestExpirationWithFakeClock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s-test-cert-expiration")
+	if err != nil {
+		t.Fatalf("Unable to create the test directory %q: %v", dir, err)
+	}
+	defer os.RemoveAll(dir)
+
+	pairFile := filepath.Join(dir, "kubelet-client-current.pem")
+	certData := bytes.Join([][]byte{storeCertData.certificatePEM, storeCertData.keyPEM}, []byte("\n"))
+	if err := ioutil.WriteFile(pairFile, certData, 0600); err != nil {
+		t.Fatalf("Unable to create the file %q: %v", pairFile, err)
+	}
+
+	u, _ := url.Parse("http://127.0.0.1:8080")
+	m, err := NewKubeletCertManager(&config.YurtHubConfiguration{RemoteServers: []*url.URL{u}}, 10*time.Second, dir)
+	if err != nil {
+		t.Fatalf("failed to new kubelet cert manager, %v", err)
+	}
+	kcm := m.(*kubeletCertManager)
+	clk := yurttesting.NewFakeClock(time.Now())
+	kcm.clock = clk
+	kcm.lastVerify = clk.Now()
+
+	if !m.NotExpired() {
+		t.Fatalf("expect cert is not expired")
+	}
+
+	// the cert expires, and the cert file is not rotated
+	clk.SetTime(storeCertData.certificate.Leaf.NotAfter.Add(time.Second))
+	if m.NotExpired() {
+		t.Errorf("expect cert is expired")
+	}
+	kcm.sync()
+	if m.NotExpired() || !kcm.lastVerify.Equal(clk.Now()) {
+		t.Errorf("expect the expired cert is reloaded and still expired")
+	}
+}
@@ -0,0 +1,441 @@
+package credentialprovider
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/klog"
+)
+
+const (
+	// dialTimeout bounds the time of connecting the hosts
+	dialTimeout = 10 * time.Second
+	// upstreamTimeout bounds the time of http requests, the cached response is served
+	// when the host doesn't respond in time.
+	upstreamTimeout = 10 * time.Second
+	// defaultCacheTTL bounds the time a cached response is served for, if neither the headers
+	// nor the credentials in body tell when it expires.
+	defaultCacheTTL = time.Hour
+	// maxCachedResponses bounds the number of cached responses in memory
+	maxCachedResponses = 64
+	// maxBodySize bounds the size of requests and responses of http requests, they are
+	// expected to carry credentials rather than bulk data.
+	maxBodySize = 1024 * 1024
+	// cacheHeader tells the client whether the response is stale or a miss
+	cacheHeader = "X-Yurthub-Cache"
+)
+
+// hopHeaders are the headers of a connection between client and proxy, they are not forwarded
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// hostPattern is a host allowed to be connected, host is a suffix of domains if wildcard is true
+type hostPattern struct {
+	host     string
+	port     string
+	wildcard bool
+}
+
+// Hosts are the hosts that image credential providers are allowed to connect through yurthub
+type Hosts []hostPattern
+
+// ParseHosts parses the hosts in the format of "host:port", host can be a domain, an ip(ipv6 in
+// brackets) or a wildcard of subdomains like "*.amazonaws.com".
+func ParseHosts(entries []string) (Hosts, error) {
+	hosts := make(Hosts, 0, len(entries))
+	for _, entry := range entries {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(entry))
+		if err != nil || host == "" {
+			return nil, fmt.Errorf("credential provider host(%s) is invalid, the format is host:port", entry)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return nil, fmt.Errorf("port of credential provider host(%s) is invalid", entry)
+		}
+
+		p := hostPattern{host: strings.ToLower(host), port: port}
+		if strings.HasPrefix(p.host, "*.") {
+			p.host = p.host[1:]
+			p.wildcard = true
+		}
+		if strings.Contains(p.host, "*") {
+			return nil, fmt.Errorf("credential provider host(%s) is invalid, only a leading wildcard is supported", entry)
+		}
+		hosts = append(hosts, p)
+	}
+	return hosts, nil
+}
+
+// Allows returns true if hostport(like api.ecr.us-east-1.amazonaws.com:443) is allowed
+func (hosts Hosts) Allows(hostport string) bool {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, p := range hosts {
+		if p.port != port {
+			continue
+		}
+		if host == p.host || (p.wildcard && strings.HasSuffix(host, p.host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedResponse is the response of a http request
+type cachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	FetchedAt  time.Time
+	// ExpiresAt is when the response(or the credentials in it) expires, it's never served after that
+	ExpiresAt time.Time
+}
+
+// Proxy is the forward proxy for image credential providers of kubelet(like ecr-credential-provider),
+// which fetch credentials from cloud metadata services and registry auth endpoints, so image pulls
+// work when only yurthub has the outbound path(like the resolver of yurthub) to them. https is
+// tunneled by CONNECT, and plain http(like metadata services) is forwarded with its successful
+// responses cached, a cached response is served when the host is unavailable until it expires.
+// responses carry credentials, so they are cached in memory only and never written to disk.
+// only the allowed hosts can be connected.
+type Proxy struct {
+	hosts  Hosts
+	dial   util.DialFunc
+	client *http.Client
+	now    func() time.Time
+
+	sync.Mutex
+	cache map[string]*cachedResponse
+}
+
+// NewProxy creates a Proxy for hosts, dial is used to connect the hosts
+func NewProxy(hosts Hosts, dial util.DialFunc) *Proxy {
+	return &Proxy{
+		hosts: hosts,
+		dial:  dial,
+		cache: make(map[string]*cachedResponse),
+		client: &http.Client{
+			Timeout: upstreamTimeout,
+			Transport: &http.Transport{
+				DialContext:         dial,
+				MaxIdleConnsPerHost: 2,
+			},
+			// redirects are returned to the client, so they are checked against hosts as well
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// IsProxyRequest returns true if req is for a forward proxy(CONNECT, or a request of absolute url)
+// rather than for yurthub itself.
+func IsProxyRequest(req *http.Request) bool {
+	return req.Method == http.MethodConnect || req.URL.IsAbs()
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		p.tunnel(w, req)
+		return
+	}
+	p.forward(w, req)
+}
+
+// tunnel connects the host of CONNECT and copies bytes between the client and the host
+func (p *Proxy) tunnel(w http.ResponseWriter, req *http.Request) {
+	if !p.hosts.Allows(req.Host) {
+		klog.Warningf("credential provider is not allowed to connect %s", req.Host)
+		http.Error(w, fmt.Sprintf("%s is not allowed for credential providers", req.Host), http.StatusForbidden)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), dialTimeout)
+	upstream, err := p.dial(ctx, "tcp", req.Host)
+	cancel()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to connect %s, %v", req.Host, err), http.StatusBadGateway)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "connection can not be hijacked", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		klog.Errorf("failed to hijack connection for %s, %v", req.Host, err)
+		return
+	}
+	defer conn.Close()
+	defer upstream.Close()
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+	// bytes sent by the client right after CONNECT may be buffered already
+	if n := buf.Reader.Buffered(); n > 0 {
+		if _, err := io.CopyN(upstream, buf, int64(n)); err != nil {
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		// unblock the copy of the other direction
+		dst.Close()
+		src.Close()
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	wg.Wait()
+}
+
+// forward sends the http request to its host, the successful responses of requests without
+// credentials are cached, and served when the host is unavailable until they expire.
+func (p *Proxy) forward(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Scheme != "http" {
+		http.Error(w, fmt.Sprintf("scheme %s is not supported, use CONNECT for https", req.URL.Scheme), http.StatusBadRequest)
+		return
+	}
+	hostport := req.URL.Host
+	if req.URL.Port() == "" {
+		hostport = net.JoinHostPort(req.URL.Hostname(), "80")
+	}
+	if !p.hosts.Allows(hostport) {
+		klog.Warningf("credential provider is not allowed to connect %s", hostport)
+		http.Error(w, fmt.Sprintf("%s is not allowed for credential providers", hostport), http.StatusForbidden)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxBodySize+1))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request, %v", err), http.StatusBadRequest)
+		return
+	} else if len(body) > maxBodySize {
+		http.Error(w, fmt.Sprintf("request exceeds %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	cacheable := isCacheableRequest(req)
+	key := cacheKey(req.Method, req.URL.String(), body)
+
+	resp, err := p.fetch(req, body)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		var cached *cachedResponse
+		if cacheable {
+			cached = p.load(key)
+		}
+		if cached != nil {
+			klog.Warningf("serve stale response of %s %s fetched at %s, host is unavailable, %v", req.Method, req.URL.String(), cached.FetchedAt.Format(time.RFC3339), err)
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			writeResponse(w, cached, "stale")
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s is unavailable, %v", hostport, err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	if cacheable && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		if expiresAt, ok := expiresAt(resp); ok {
+			resp.ExpiresAt = expiresAt
+			p.save(key, resp)
+		} else {
+			p.remove(key)
+		}
+	}
+	writeResponse(w, resp, "miss")
+}
+
+// cacheKey is the key of response for the method, url and body of request
+func cacheKey(method, url string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + "\n" + url + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// isCacheableRequest returns false for the requests with credentials, like the ones signed
+// per request(aws sigv4), or with the session token of metadata service(X-aws-ec2-metadata-token
+// of IMDSv2), their responses are neither reusable nor shareable between clients.
+func isCacheableRequest(req *http.Request) bool {
+	for h := range req.Header {
+		h = strings.ToLower(h)
+		if h == "authorization" || h == "cookie" || strings.Contains(h, "token") {
+			return false
+		}
+	}
+	return true
+}
+
+// expiresAt returns when the response expires, false is returned if the response must
+// not be cached(Cache-Control: no-store, private or max-age=0). it's the earliest of
+// max-age(or Expires header) and the expiration of credentials in body, like Expiration
+// of aws credentials and expires_in of oauth2 tokens.
+func expiresAt(resp *cachedResponse) (time.Time, bool) {
+	var expires time.Time
+	earlier := func(t time.Time) {
+		if expires.IsZero() || t.Before(expires) {
+			expires = t
+		}
+	}
+
+	maxAge := -1
+	for _, directive := range strings.Split(resp.Header.Get("Cache-Control"), ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-store" || directive == "private" || directive == "no-cache":
+			return time.Time{}, false
+		case strings.HasPrefix(directive, "max-age="):
+			if n, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+				maxAge = n
+			}
+		}
+	}
+	if maxAge >= 0 {
+		earlier(resp.FetchedAt.Add(time.Duration(maxAge) * time.Second))
+	} else if v := resp.Header.Get("Expires"); v != "" {
+		// an invalid Expires(like 0) means the response is already expired
+		t, _ := http.ParseTime(v)
+		earlier(t)
+	}
+
+	var credentials struct {
+		Expiration string `json:"Expiration"`
+		ExpiresIn  *int64 `json:"expires_in"`
+	}
+	if err := json.Unmarshal(resp.Body, &credentials); err == nil {
+		if t, err := time.Parse(time.RFC3339, credentials.Expiration); err == nil {
+			earlier(t)
+		}
+		if credentials.ExpiresIn != nil {
+			earlier(resp.FetchedAt.Add(time.Duration(*credentials.ExpiresIn) * time.Second))
+		}
+	}
+
+	if expires.IsZero() {
+		expires = resp.FetchedAt.Add(defaultCacheTTL)
+	}
+	if !expires.After(resp.FetchedAt) {
+		return time.Time{}, false
+	}
+	return expires, true
+}
+
+func (p *Proxy) fetch(req *http.Request, body []byte) (*cachedResponse, error) {
+	out, err := http.NewRequest(req.Method, req.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	out = out.WithContext(req.Context())
+	out.Header = req.Header.Clone()
+	removeHopHeaders(out.Header)
+
+	resp, err := p.client.Do(out)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	} else if len(b) > maxBodySize {
+		return nil, fmt.Errorf("response of %s exceeds %d bytes", req.URL.String(), maxBodySize)
+	}
+
+	header := resp.Header.Clone()
+	removeHopHeaders(header)
+	header.Del("Content-Length")
+	return &cachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     header,
+		Body:       b,
+		FetchedAt:  p.now(),
+	}, nil
+}
+
+// load returns the cached response of key, nil is returned if it's expired
+func (p *Proxy) load(key string) *cachedResponse {
+	p.Lock()
+	defer p.Unlock()
+	cached, ok := p.cache[key]
+	if !ok {
+		return nil
+	}
+	if !p.now().Before(cached.ExpiresAt) {
+		delete(p.cache, key)
+		return nil
+	}
+	return cached
+}
+
+// save caches the response of key, expired responses are dropped first, and the response
+// that expires earliest is evicted if the cache is still full.
+func (p *Proxy) save(key string, resp *cachedResponse) {
+	p.Lock()
+	defer p.Unlock()
+	now := p.now()
+	for k, cached := range p.cache {
+		if !now.Before(cached.ExpiresAt) {
+			delete(p.cache, k)
+		}
+	}
+	if _, ok := p.cache[key]; !ok && len(p.cache) >= maxCachedResponses {
+		evicted := ""
+		for k, cached := range p.cache {
+			if evicted == "" || cached.ExpiresAt.Before(p.cache[evicted].ExpiresAt) {
+				evicted = k
+			}
+		}
+		delete(p.cache, evicted)
+	}
+	p.cache[key] = resp
+}
+
+func (p *Proxy) remove(key string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.cache, key)
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, h := range hopHeaders {
+		header.Del(h)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp *cachedResponse, cacheStatus string) {
+	for h, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+	w.Header().Set(cacheHeader, cacheStatus)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
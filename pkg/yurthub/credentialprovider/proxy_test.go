@@ -0,0 +1,250 @@
+package credentialprovider
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHostsAndAllows(t *testing.T) {
+	hosts, err := ParseHosts([]string{"169.254.169.254:80", "*.amazonaws.com:443", "[fd00:ec2::254]:80"})
+	if err != nil {
+		t.Fatalf("failed to parse hosts, %v", err)
+	}
+
+	testcases := map[string]bool{
+		"169.254.169.254:80":                  true,
+		"169.254.169.254:8080":                false,
+		"api.ecr.us-east-1.amazonaws.com:443": true,
+		"API.ECR.US-EAST-1.AMAZONAWS.COM:443": true,
+		"amazonaws.com:443":                   false,
+		"evil-amazonaws.com:443":              false,
+		"[fd00:ec2::254]:80":                  true,
+		"169.254.169.254":                     false,
+	}
+	for hostport, allowed := range testcases {
+		if hosts.Allows(hostport) != allowed {
+			t.Errorf("expect %s allowed: %v, but got %v", hostport, allowed, !allowed)
+		}
+	}
+
+	for _, entry := range []string{"169.254.169.254", ":443", "a.com:0", "a.com:http", "a.*.com:443"} {
+		if _, err := ParseHosts([]string{entry}); err == nil {
+			t.Errorf("expect %s is invalid", entry)
+		}
+	}
+}
+
+// newTestProxy serves a proxy that allows the host of upstream, the returned client sends requests through it
+func newTestProxy(t *testing.T, upstream string) (*Proxy, *httptest.Server, *http.Client) {
+	u, _ := url.Parse(upstream)
+	hosts, err := ParseHosts([]string{u.Host})
+	if err != nil {
+		t.Fatalf("failed to parse hosts, %v", err)
+	}
+	p := NewProxy(hosts, (&net.Dialer{}).DialContext)
+	server := httptest.NewServer(p)
+
+	proxyURL, _ := url.Parse(server.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	return p, server, client
+}
+
+func TestForward(t *testing.T) {
+	available := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, r.Header.Get("Metadata-Flavor"))
+	}))
+	defer upstream.Close()
+	_, server, client := newTestProxy(t, upstream.URL)
+	defer server.Close()
+
+	get := func(method, path string, header map[string]string) (int, string, string) {
+		req, _ := http.NewRequest(method, upstream.URL+path, nil)
+		req.Header.Set("Metadata-Flavor", "Google")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to request %s, %v", path, err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(b), resp.Header.Get(cacheHeader)
+	}
+
+	if code, body, cache := get("GET", "/token", nil); code != http.StatusOK || body != "GET /token Google" || cache != "miss" {
+		t.Errorf("expect response of upstream, but got %d %s %s", code, body, cache)
+	}
+	if code, body, _ := get("PUT", "/api/token", nil); code != http.StatusOK || body != "PUT /api/token Google" {
+		t.Errorf("expect response of upstream, but got %d %s", code, body)
+	}
+	signed := map[string]string{"Authorization": "AWS4-HMAC-SHA256 Signature=abc"}
+	withToken := map[string]string{"X-aws-ec2-metadata-token": "abc"}
+	get("GET", "/signed", signed)
+	get("GET", "/latest/meta-data/iam/security-credentials/role", withToken)
+
+	available = false
+	testcases := map[string]struct {
+		method string
+		path   string
+		header map[string]string
+		code   int
+		cache  string
+	}{
+		"cached get":              {method: "GET", path: "/token", code: http.StatusOK, cache: "stale"},
+		"cached put":              {method: "PUT", path: "/api/token", code: http.StatusOK, cache: "stale"},
+		"not cached":              {method: "GET", path: "/other", code: http.StatusServiceUnavailable, cache: "miss"},
+		"request with credential": {method: "GET", path: "/signed", header: signed, code: http.StatusServiceUnavailable, cache: "miss"},
+		"request with token":      {method: "GET", path: "/latest/meta-data/iam/security-credentials/role", header: withToken, code: http.StatusServiceUnavailable, cache: "miss"},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			code, _, cache := get(tt.method, tt.path, tt.header)
+			if code != tt.code || cache != tt.cache {
+				t.Errorf("expect %d %s, but got %d %s", tt.code, tt.cache, code, cache)
+			}
+		})
+	}
+}
+
+func TestForwardExpiration(t *testing.T) {
+	available := true
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/credentials":
+			fmt.Fprintf(w, `{"AccessKeyId":"a","Expiration":"%s"}`, time.Now().Add(10*time.Minute).UTC().Format(time.RFC3339))
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer upstream.Close()
+	p, server, client := newTestProxy(t, upstream.URL)
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := client.Get(upstream.URL + path)
+		if err != nil {
+			t.Fatalf("failed to request %s, %v", path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get(cacheHeader)
+	}
+	for _, path := range []string{"/no-store", "/max-age", "/credentials", "/default"} {
+		get(path)
+	}
+	available = false
+
+	now := time.Now()
+	testcases := []struct {
+		desc  string
+		after time.Duration
+		path  string
+		code  int
+	}{
+		{desc: "no-store is not cached", path: "/no-store", code: http.StatusServiceUnavailable},
+		{desc: "max-age is not expired", path: "/max-age", code: http.StatusOK},
+		{desc: "credentials are not expired", after: 2 * time.Minute, path: "/credentials", code: http.StatusOK},
+		{desc: "max-age is expired", after: 2 * time.Minute, path: "/max-age", code: http.StatusServiceUnavailable},
+		{desc: "default is not expired", after: 30 * time.Minute, path: "/default", code: http.StatusOK},
+		{desc: "credentials are expired", after: 30 * time.Minute, path: "/credentials", code: http.StatusServiceUnavailable},
+		{desc: "default is expired", after: 2 * time.Hour, path: "/default", code: http.StatusServiceUnavailable},
+	}
+	for _, tt := range testcases {
+		t.Run(tt.desc, func(t *testing.T) {
+			p.now = func() time.Time { return now.Add(tt.after) }
+			if code, _ := get(tt.path); code != tt.code {
+				t.Errorf("expect %d, but got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestForwardNotAllowed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	}))
+	defer upstream.Close()
+	_, server, client := newTestProxy(t, "http://169.254.169.254:80")
+	defer server.Close()
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("failed to request, %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expect %d, but got %d", http.StatusForbidden, resp.StatusCode)
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "credentials")
+	}))
+	defer upstream.Close()
+	_, server, client := newTestProxy(t, upstream.URL)
+	defer server.Close()
+	client.Transport.(*http.Transport).TLSClientConfig = upstream.Client().Transport.(*http.Transport).TLSClientConfig
+
+	resp, err := client.Get(upstream.URL + "/v2/token")
+	if err != nil {
+		t.Fatalf("failed to request through tunnel, %v", err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(b) != "credentials" {
+		t.Errorf("expect response of upstream, but got %d %s", resp.StatusCode, string(b))
+	}
+
+	// hosts that are not allowed can not be connected
+	other := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer other.Close()
+	client.Transport.(*http.Transport).TLSClientConfig = other.Client().Transport.(*http.Transport).TLSClientConfig
+	if _, err := client.Get(other.URL); err == nil || !strings.Contains(err.Error(), "Forbidden") {
+		t.Errorf("expect the tunnel is forbidden, but got %v", err)
+	}
+}
+
+func TestIsProxyRequest(t *testing.T) {
+	testcases := map[string]struct {
+		request string
+		proxy   bool
+	}{
+		"connect":      {request: "CONNECT api.ecr.us-east-1.amazonaws.com:443 HTTP/1.1\r\nHost: api.ecr.us-east-1.amazonaws.com:443\r\n\r\n", proxy: true},
+		"absolute url": {request: "GET http://169.254.169.254/latest/meta-data HTTP/1.1\r\nHost: 169.254.169.254\r\n\r\n", proxy: true},
+		"apiserver":    {request: "GET /api/v1/pods HTTP/1.1\r\nHost: 127.0.0.1:10261\r\n\r\n"},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(tt.request)))
+			if err != nil {
+				t.Fatalf("failed to read request, %v", err)
+			}
+			if IsProxyRequest(req) != tt.proxy {
+				t.Errorf("expect proxy request: %v", tt.proxy)
+			}
+		})
+	}
+}
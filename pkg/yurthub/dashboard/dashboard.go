@@ -0,0 +1,287 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/readiness"
+	"github.com/alibaba/openyurt/pkg/yurthub/selfreport"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	// watchPeriod is the period that the health of remote servers and the readiness checks
+	// are watched for errors
+	watchPeriod = 10 * time.Second
+	// maxErrors is the number of recent errors that are kept
+	maxErrors = 50
+	// statusTimeout bounds the time of generating the status
+	statusTimeout = 10 * time.Second
+)
+
+// ServerStatus is the connectivity of a remote server
+type ServerStatus struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+}
+
+// CertificateStatus is the client certificate of yurthub
+type CertificateStatus struct {
+	Subject  string    `json:"subject"`
+	NotAfter time.Time `json:"notAfter"`
+	Expired  bool      `json:"expired"`
+}
+
+// Error is an error of yurthub that is shown on the dashboard
+type Error struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// Status is what's shown on the dashboard, it doesn't include the contents of cache or
+// any credentials, as the dashboard is not authenticated.
+type Status struct {
+	NodeName    string                    `json:"nodeName"`
+	Version     string                    `json:"version"`
+	GeneratedAt time.Time                 `json:"generatedAt"`
+	Connected   bool                      `json:"connected"`
+	Servers     []ServerStatus            `json:"servers"`
+	Cache       *cachemanager.CacheReport `json:"cache,omitempty"`
+	CacheError  string                    `json:"cacheError,omitempty"`
+	Certificate *CertificateStatus        `json:"certificate,omitempty"`
+	Readiness   *readiness.Report         `json:"readiness,omitempty"`
+	Errors      []Error                   `json:"errors"`
+}
+
+// Dashboard serves a read-only web page of the status of yurthub, so technicians on site
+// can check the node with a browser on the LAN without any access to cloud.
+type Dashboard struct {
+	sync.Mutex
+	nodeName      string
+	servers       []*url.URL
+	healthChecker healthchecker.HealthChecker
+	store         storage.Store
+	certificate   func() *tls.Certificate
+	readiness     func() *readiness.Report
+	now           func() time.Time
+	stopCh        <-chan struct{}
+
+	// errors are the recent errors, the oldest first
+	errors []Error
+	// healthy is the last observed health of remote servers
+	healthy map[string]bool
+	// checks are the last observed status of readiness checks
+	checks map[string]readiness.Status
+	// evaluatedAt is the time of last observed readiness report
+	evaluatedAt time.Time
+}
+
+// NewDashboard creates a Dashboard for the node of yurthub
+func NewDashboard(cfg *config.YurtHubConfiguration,
+	healthChecker healthchecker.HealthChecker,
+	store storage.Store,
+	certManager interfaces.YurtCertificateManager,
+	evaluator *readiness.Evaluator,
+	stopCh <-chan struct{}) *Dashboard {
+	return &Dashboard{
+		nodeName:      cfg.NodeName,
+		servers:       cfg.RemoteServers,
+		healthChecker: healthChecker,
+		store:         store,
+		certificate:   certManager.Current,
+		readiness:     evaluator.Last,
+		now:           time.Now,
+		stopCh:        stopCh,
+		healthy:       make(map[string]bool),
+		checks:        make(map[string]readiness.Status),
+	}
+}
+
+// Run watches the errors of yurthub, and serves the dashboard on addr until stopCh is closed.
+// an error is returned if addr can't be listened on.
+func (d *Dashboard) Run(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go wait.Until(d.watch, watchPeriod, d.stopCh)
+
+	server := &http.Server{Handler: d}
+	go func() {
+		klog.Infof("yurthub dashboard listens on %s", addr)
+		if err := server.Serve(l); err != nil && err != http.ErrServerClosed {
+			klog.Errorf("yurthub dashboard is stopped, %v", err)
+		}
+	}()
+	go func() {
+		<-d.stopCh
+		server.Close()
+	}()
+	return nil
+}
+
+// ServeHTTP serves the page at / and the status in json at /status, only GET is allowed
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "dashboard is read-only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/":
+		d.servePage(w, r)
+	case "/status":
+		d.serveStatus(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *Dashboard) servePage(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), statusTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if err := page.Execute(w, d.Status(ctx)); err != nil {
+		klog.Errorf("failed to render dashboard, %v", err)
+	}
+}
+
+func (d *Dashboard) serveStatus(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), statusTimeout)
+	defer cancel()
+
+	b, err := json.MarshalIndent(d.Status(ctx), "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode dashboard status, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// Status collects the status of yurthub that is shown on the dashboard
+func (d *Dashboard) Status(ctx context.Context) *Status {
+	now := d.now()
+	status := &Status{
+		NodeName:    d.nodeName,
+		Version:     selfreport.Version,
+		GeneratedAt: now,
+		Servers:     make([]ServerStatus, 0, len(d.servers)),
+		Readiness:   d.readiness(),
+	}
+
+	for _, server := range d.servers {
+		healthy := d.healthChecker.IsHealthy(server)
+		status.Servers = append(status.Servers, ServerStatus{URL: server.String(), Healthy: healthy})
+		status.Connected = status.Connected || healthy
+	}
+
+	report, err := cachemanager.NewCacheReport(ctx, d.store)
+	if err != nil {
+		status.CacheError = err.Error()
+	} else {
+		// only the usage of components is shown, resources are listed by /v1/cache/report
+		for i := range report.Components {
+			report.Components[i].Resources = nil
+		}
+		status.Cache = report
+	}
+
+	status.Certificate = certificateStatus(d.certificate(), now)
+
+	d.Lock()
+	status.Errors = make([]Error, 0, len(d.errors))
+	for i := len(d.errors) - 1; i >= 0; i-- {
+		status.Errors = append(status.Errors, d.errors[i])
+	}
+	d.Unlock()
+	return status
+}
+
+// watch records the remote servers that become unhealthy and the readiness checks that
+// become failed since last watch as errors.
+func (d *Dashboard) watch() {
+	now := d.now()
+	d.Lock()
+	defer d.Unlock()
+
+	for _, server := range d.servers {
+		key := server.String()
+		healthy := d.healthChecker.IsHealthy(server)
+		if last, ok := d.healthy[key]; (!ok || last) && !healthy {
+			d.recordLocked(now, "connectivity", fmt.Sprintf("remote server %s is unhealthy", key))
+		}
+		d.healthy[key] = healthy
+	}
+
+	report := d.readiness()
+	if report == nil || !report.EvaluatedAt.After(d.evaluatedAt) {
+		return
+	}
+	d.evaluatedAt = report.EvaluatedAt
+	for _, c := range report.Checks {
+		if c.Status != readiness.StatusPass && d.checks[c.Name] != c.Status {
+			d.recordLocked(report.EvaluatedAt, "readiness",
+				fmt.Sprintf("%s check is %s, %s", c.Name, c.Status, c.Message))
+		}
+		d.checks[c.Name] = c.Status
+	}
+}
+
+// recordLocked records an error of source, only the recent errors are kept
+func (d *Dashboard) recordLocked(t time.Time, source, message string) {
+	d.errors = append(d.errors, Error{Time: t, Source: source, Message: message})
+	if len(d.errors) > maxErrors {
+		d.errors = d.errors[len(d.errors)-maxErrors:]
+	}
+}
+
+// certificateStatus returns the status of cert, nil if there is no certificate
+func certificateStatus(cert *tls.Certificate, now time.Time) *CertificateStatus {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil
+	}
+
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil
+		}
+	}
+	return &CertificateStatus{
+		Subject:  leaf.Subject.String(),
+		NotAfter: leaf.NotAfter,
+		Expired:  !now.Before(leaf.NotAfter),
+	}
+}
+
+// sortedComponents sorts the components by size, the largest first
+func sortedComponents(components []cachemanager.ComponentUsage) []cachemanager.ComponentUsage {
+	sorted := append([]cachemanager.ComponentUsage(nil), components...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Size > sorted[j].Size })
+	return sorted
+}
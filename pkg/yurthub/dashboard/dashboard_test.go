@@ -0,0 +1,194 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/readiness"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+type fakeHealthChecker map[string]bool
+
+func (f fakeHealthChecker) IsHealthy(server *url.URL) bool {
+	return f[server.String()]
+}
+
+func newTestDashboard(t *testing.T, now time.Time) (*Dashboard, fakeHealthChecker, *readiness.Report) {
+	store, _ := fake.NewFakeStorage()
+	if err := store.Create(context.Background(), "kubelet/pods/default/foo", []byte("foo")); err != nil {
+		t.Fatalf("failed to create cache, %v", err)
+	}
+
+	server1, _ := url.Parse("https://10.0.0.1:6443")
+	server2, _ := url.Parse("https://10.0.0.2:6443")
+	health := fakeHealthChecker{server1.String(): true, server2.String(): true}
+	report := &readiness.Report{}
+	cert := &tls.Certificate{Certificate: [][]byte{{}}, Leaf: &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "system:node:node1"},
+		NotAfter: now.Add(-time.Hour),
+	}}
+	return &Dashboard{
+		nodeName:      "node1",
+		servers:       []*url.URL{server1, server2},
+		healthChecker: health,
+		store:         store,
+		certificate:   func() *tls.Certificate { return cert },
+		readiness:     func() *readiness.Report { return report },
+		now:           func() time.Time { return now },
+		healthy:       make(map[string]bool),
+		checks:        make(map[string]readiness.Status),
+	}, health, report
+}
+
+func TestStatus(t *testing.T) {
+	now := time.Now()
+	d, health, _ := newTestDashboard(t, now)
+	health["https://10.0.0.1:6443"] = false
+
+	status := d.Status(context.Background())
+	if !status.Connected || len(status.Servers) != 2 || status.Servers[0].Healthy || !status.Servers[1].Healthy {
+		t.Errorf("unexpected connectivity %v, %+v", status.Connected, status.Servers)
+	}
+	if status.Cache == nil || status.Cache.Keys != 1 {
+		t.Errorf("expect 1 object in cache, but got %+v(%s)", status.Cache, status.CacheError)
+	}
+	for _, c := range status.Cache.Components {
+		if c.Resources != nil {
+			t.Errorf("expect resources of %s to be left out, but got %v", c.Component, c.Resources)
+		}
+	}
+	if status.Certificate == nil || !status.Certificate.Expired || status.Certificate.Subject != "CN=system:node:node1" {
+		t.Errorf("unexpected certificate %+v", status.Certificate)
+	}
+
+	health["https://10.0.0.2:6443"] = false
+	if status := d.Status(context.Background()); status.Connected {
+		t.Errorf("expect disconnected when all servers are unhealthy")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	now := time.Now()
+	d, health, report := newTestDashboard(t, now)
+
+	d.watch()
+	if len(d.errors) != 0 {
+		t.Fatalf("expect no errors, but got %v", d.errors)
+	}
+
+	// an unhealthy server and a failed check are recorded once until they recover
+	health["https://10.0.0.1:6443"] = false
+	report.EvaluatedAt = now
+	report.Checks = []readiness.CheckResult{
+		{Name: "cache", Status: readiness.StatusPass},
+		{Name: "disk", Status: readiness.StatusFail, Message: "disk is full"},
+	}
+	d.watch()
+	d.watch()
+	if len(d.errors) != 2 || d.errors[0].Source != "connectivity" || d.errors[1].Message != "disk check is Fail, disk is full" {
+		t.Fatalf("unexpected errors %+v", d.errors)
+	}
+
+	health["https://10.0.0.1:6443"] = true
+	d.watch()
+	health["https://10.0.0.1:6443"] = false
+	d.watch()
+	if len(d.errors) != 3 {
+		t.Errorf("expect the server to be recorded again after it recovered, but got %+v", d.errors)
+	}
+
+	// the newest errors are shown first, and only the recent ones are kept
+	for i := 0; i < maxErrors; i++ {
+		d.recordLocked(now.Add(time.Duration(i)*time.Second), "test", "error")
+	}
+	errs := d.Status(context.Background()).Errors
+	if len(errs) != maxErrors || !errs[0].Time.After(errs[1].Time) {
+		t.Errorf("unexpected recent errors %+v", errs)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	d, _, report := newTestDashboard(t, time.Now())
+	report.Checks = []readiness.CheckResult{{Name: "disk", Status: readiness.StatusWarn, Message: "<script>"}}
+
+	testcases := map[string]struct {
+		method      string
+		path        string
+		code        int
+		contentType string
+		contains    []string
+	}{
+		"page": {
+			method:      http.MethodGet,
+			path:        "/",
+			code:        http.StatusOK,
+			contentType: "text/html; charset=utf-8",
+			contains:    []string{"yurthub on node1", "https://10.0.0.1:6443", "kubelet", "expired at", "&lt;script&gt;"},
+		},
+		"status": {
+			method:      http.MethodGet,
+			path:        "/status",
+			code:        http.StatusOK,
+			contentType: "application/json",
+			contains:    []string{`"nodeName": "node1"`},
+		},
+		"read-only": {
+			method: http.MethodPost,
+			path:   "/",
+			code:   http.StatusMethodNotAllowed,
+		},
+		"not found": {
+			method: http.MethodGet,
+			path:   "/v1/admin/wipe",
+			code:   http.StatusNotFound,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			d.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.code {
+				t.Fatalf("expect code %d, but got %d", tt.code, rec.Code)
+			}
+			if tt.contentType != "" && rec.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("expect content type %s, but got %s", tt.contentType, rec.Header().Get("Content-Type"))
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(rec.Body.String(), s) {
+					t.Errorf("expect %q in body, but got\n%s", s, rec.Body.String())
+				}
+			}
+			if tt.path == "/status" {
+				var status Status
+				if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+					t.Errorf("failed to decode status, %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testcases := map[int64]string{
+		0:           "0 B",
+		1023:        "1023 B",
+		1536:        "1.5 KiB",
+		5 * 1 << 20: "5.0 MiB",
+	}
+	for n, expect := range testcases {
+		if got := formatBytes(n); got != expect {
+			t.Errorf("expect %s for %d bytes, but got %s", expect, n, got)
+		}
+	}
+}
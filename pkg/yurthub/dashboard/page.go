@@ -0,0 +1,88 @@
+package dashboard
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// page is the dashboard, it's refreshed every 10 seconds and has no external resources,
+// so it works on a LAN without internet.
+var page = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"time":       formatTime,
+	"bytes":      formatBytes,
+	"components": sortedComponents,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="10">
+<title>yurthub {{.NodeName}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+.ok { color: #2e7d32; font-weight: bold; }
+.bad { color: #c62828; font-weight: bold; }
+.warn { color: #ef6c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>yurthub on {{.NodeName}}</h1>
+<p>version {{.Version}}, generated at {{time .GeneratedAt}}</p>
+
+<h2>Connectivity</h2>
+<p>{{if .Connected}}<span class="ok">connected to cloud</span>{{else}}<span class="bad">disconnected from cloud, serving from cache</span>{{end}}</p>
+<table>
+<tr><th>remote server</th><th>status</th></tr>
+{{range .Servers}}<tr><td>{{.URL}}</td><td>{{if .Healthy}}<span class="ok">healthy</span>{{else}}<span class="bad">unhealthy</span>{{end}}</td></tr>
+{{end}}</table>
+
+<h2>Cache</h2>
+{{with .Cache}}<p>{{.Keys}} objects, {{bytes .Size}}{{with .Newest}}, last written at {{time .}}{{end}}</p>
+<table>
+<tr><th>component</th><th>objects</th><th>size</th><th>last written</th></tr>
+{{range components .Components}}<tr><td>{{.Component}}</td><td>{{.Keys}}</td><td>{{bytes .Size}}</td><td>{{with .Newest}}{{time .}}{{end}}</td></tr>
+{{end}}</table>
+{{else}}<p class="bad">failed to read cache, {{.CacheError}}</p>
+{{end}}
+<h2>Certificate</h2>
+{{with .Certificate}}<p>{{.Subject}}, {{if .Expired}}<span class="bad">expired at {{time .NotAfter}}</span>{{else}}expires at {{time .NotAfter}}{{end}}</p>
+{{else}}<p class="bad">certificate is not prepared</p>
+{{end}}
+<h2>Autonomy readiness</h2>
+{{with .Readiness}}<p>{{if .Ready}}<span class="ok">ready</span>{{else}}<span class="bad">not ready</span>{{end}}, score {{.Score}}, evaluated at {{time .EvaluatedAt}}</p>
+<table>
+<tr><th>check</th><th>status</th><th>message</th></tr>
+{{range .Checks}}<tr><td>{{.Name}}</td><td>{{if eq .Status "Pass"}}<span class="ok">{{.Status}}</span>{{else if eq .Status "Warn"}}<span class="warn">{{.Status}}</span>{{else}}<span class="bad">{{.Status}}</span>{{end}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}<p>not evaluated yet</p>
+{{end}}
+<h2>Recent errors</h2>
+{{if .Errors}}<table>
+<tr><th>time</th><th>source</th><th>message</th></tr>
+{{range .Errors}}<tr><td>{{time .Time}}</td><td>{{.Source}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}<p>no errors</p>
+{{end}}</body>
+</html>
+`))
+
+// formatTime formats t in RFC3339 of the local time zone, as the page is read on site
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatBytes formats n bytes in a human readable unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,183 @@
+package dedup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+)
+
+const (
+	// maxWriteSize bounds the size of writes and responses that are de-duplicated, node status,
+	// events and leases are small, larger ones are passed through.
+	maxWriteSize = 64 * 1024
+	// dedupHeader tells the client that the response is replayed by yurthub
+	dedupHeader = "X-Yurthub-Dedup"
+)
+
+// target is the resource that writes are de-duplicated for
+type target struct {
+	group       string
+	resource    string
+	subresource string
+}
+
+// writes are the verbs of writes that are de-duplicated for each target, they are retried by node
+// agents until they succeed, and a retry of a write that was applied is not idempotent, like a node
+// status patch or an event that is applied twice, or a lease update that is rejected by conflict.
+var writes = map[target]sets.String{
+	{resource: "nodes", subresource: "status"}:         sets.NewString("patch", "update"),
+	{resource: "events"}:                               sets.NewString("create", "patch"),
+	{group: "events.k8s.io", resource: "events"}:       sets.NewString("create", "patch"),
+	{group: "coordination.k8s.io", resource: "leases"}: sets.NewString("update"),
+}
+
+// write is a write in flight, done is closed when it's finished
+type write struct {
+	done chan struct{}
+}
+
+// Deduplicator de-duplicates the retries of writes(node status patches, events and lease updates)
+// that node agents send to kube-apiserver through yurthub. the digest of a write(its path, query,
+// credentials, content types and body) is its idempotency key, the response of a successful write
+// is recorded for ttl, and a retry of the write(like after the connection is broken by flapping
+// network after the write is applied) is responded with the recorded response instead of being
+// applied again. a retry of a write in flight waits for it. responses are recorded in memory only
+// and bounded by size, the least recently used ones are evicted, and the expired ones are dropped.
+type Deduplicator struct {
+	ttl       time.Duration
+	responses *cache.LRUExpireCache
+	sync.Mutex
+	inFlight map[string]*write
+}
+
+// NewDeduplicator creates a Deduplicator that records size responses for ttl
+func NewDeduplicator(size int, ttl time.Duration) *Deduplicator {
+	return NewDeduplicatorWithClock(size, ttl, clock.RealClock{})
+}
+
+// NewDeduplicatorWithClock creates a Deduplicator that expires responses by clk
+func NewDeduplicatorWithClock(size int, ttl time.Duration, clk clock.Clock) *Deduplicator {
+	return &Deduplicator{
+		ttl:       ttl,
+		responses: cache.NewLRUExpireCacheWithClock(size, clk),
+		inFlight:  make(map[string]*write),
+	}
+}
+
+// WithWriteDedup de-duplicates the retries of writes that are sent to handler, other requests are
+// passed to handler. a nil Deduplicator de-duplicates nothing.
+func (d *Deduplicator) WithWriteDedup(handler http.Handler) http.Handler {
+	if d == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := apirequest.RequestInfoFrom(req.Context())
+		if !ok || !info.IsResourceRequest || !writes[target{info.APIGroup, info.Resource, info.Subresource}].Has(info.Verb) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxWriteSize+1))
+		if err != nil {
+			req.Body.Close()
+			util.Err(apierrors.NewBadRequest(fmt.Sprintf("failed to read request, %v", err)), w, req)
+			return
+		}
+		if len(body) > maxWriteSize {
+			req.Body = &util.ReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			handler.ServeHTTP(w, req)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key := idempotencyKey(req, body)
+		resp, wr := d.begin(key)
+		if resp != nil {
+			klog.V(4).Infof("%s is a retry of a successful write, replay its response", util.ReqString(req))
+			writeResponse(w, resp, "replayed")
+			return
+		}
+
+		rw := util.NewResponseRecorder()
+		func() {
+			defer d.finish(key, wr, rw)
+			handler.ServeHTTP(rw, req)
+		}()
+		writeResponse(w, rw, "")
+	})
+}
+
+// begin returns the recorded response of the successful write of key, or starts the write of key
+// if the response is not recorded. a write of key in flight is waited for, and the write is started
+// again if it's failed.
+func (d *Deduplicator) begin(key string) (*util.ResponseRecorder, *write) {
+	for {
+		d.Lock()
+		if v, ok := d.responses.Get(key); ok {
+			d.Unlock()
+			return v.(*util.ResponseRecorder), nil
+		}
+		wr, ok := d.inFlight[key]
+		if !ok {
+			wr = &write{done: make(chan struct{})}
+			d.inFlight[key] = wr
+			d.Unlock()
+			return nil, wr
+		}
+		d.Unlock()
+		<-wr.done
+	}
+}
+
+// finish records the response of the write of key if it's successful, and wakes up the retries
+// that are waiting for it
+func (d *Deduplicator) finish(key string, wr *write, rw *util.ResponseRecorder) {
+	d.Lock()
+	defer d.Unlock()
+	if rw.StatusCode >= http.StatusOK && rw.StatusCode < http.StatusMultipleChoices && rw.Body.Len() <= maxWriteSize {
+		d.responses.Add(key, rw, d.ttl)
+	}
+	delete(d.inFlight, key)
+	close(wr.done)
+}
+
+// idempotencyKey is the key of write for the request, the credentials and the content types of
+// request are included, so responses are not shared between agents with different credentials.
+func idempotencyKey(req *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("Authorization"), req.Header.Get("Accept"), req.Header.Get("Content-Type")} {
+		h.Write([]byte(s))
+		h.Write([]byte("\n"))
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeResponse(w http.ResponseWriter, resp *util.ResponseRecorder, dedupStatus string) {
+	for h, values := range resp.Header() {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+	if dedupStatus != "" {
+		w.Header().Set(dedupHeader, dedupStatus)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body.Bytes())
+}
@@ -0,0 +1,201 @@
+package dedup
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	nodeStatusPath = "/api/v1/nodes/node1/status"
+	eventsPath     = "/api/v1/namespaces/default/events"
+	leasePath      = "/apis/coordination.k8s.io/v1/namespaces/kube-node-lease/leases/node1"
+)
+
+// fakeAPIServer applies the writes and responds them with the number of writes applied, the writes
+// are blocked until release is closed if it's set, and it responds 503 when it's offline.
+type fakeAPIServer struct {
+	sync.Mutex
+	applied int
+	offline bool
+	release chan struct{}
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.release != nil {
+		<-s.release
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.offline {
+		http.Error(w, "kube-apiserver is unreachable", http.StatusServiceUnavailable)
+		return
+	}
+	s.applied++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, `{"applied":%d}`, s.applied)
+}
+
+func (s *fakeAPIServer) appliedCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.applied
+}
+
+func newHandler(d *Deduplicator, server http.Handler) http.Handler {
+	resolver := &apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+	return filters.WithRequestInfo(d.WithWriteDedup(server), resolver)
+}
+
+func send(handler http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestWriteDedup(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	server := &fakeAPIServer{}
+	handler := newHandler(NewDeduplicatorWithClock(10, time.Minute, clk), server)
+
+	testcases := []struct {
+		desc    string
+		step    time.Duration
+		offline bool
+		method  string
+		path    string
+		token   string
+		body    string
+		code    int
+		dedup   string
+		applied int
+	}{
+		{desc: "node status patch is applied", method: "PATCH", path: nodeStatusPath, token: "node1", body: `{"status":1}`, code: http.StatusOK, applied: 1},
+		{desc: "retry of node status patch is replayed", step: 10 * time.Second, method: "PATCH", path: nodeStatusPath, token: "node1", body: `{"status":1}`, code: http.StatusOK, dedup: "replayed", applied: 1},
+		{desc: "new node status patch is applied", method: "PATCH", path: nodeStatusPath, token: "node1", body: `{"status":2}`, code: http.StatusOK, applied: 2},
+		{desc: "same patch of another agent is applied", method: "PATCH", path: nodeStatusPath, token: "node2", body: `{"status":2}`, code: http.StatusOK, applied: 3},
+		{desc: "event is created", method: "POST", path: eventsPath, token: "node1", body: `{"event":1}`, code: http.StatusOK, applied: 4},
+		{desc: "retry of event is replayed", method: "POST", path: eventsPath, token: "node1", body: `{"event":1}`, code: http.StatusOK, dedup: "replayed", applied: 4},
+		{desc: "failed write is not recorded", offline: true, method: "POST", path: eventsPath, token: "node1", body: `{"event":2}`, code: http.StatusServiceUnavailable, applied: 4},
+		{desc: "retry of failed write is applied", method: "POST", path: eventsPath, token: "node1", body: `{"event":2}`, code: http.StatusOK, applied: 5},
+		{desc: "retry of expired write is applied", step: time.Minute, method: "PATCH", path: nodeStatusPath, token: "node1", body: `{"status":1}`, code: http.StatusOK, applied: 6},
+		{desc: "other writes are passed through", method: "PATCH", path: "/api/v1/namespaces/default/configmaps/cm1", token: "node1", body: `{"data":1}`, code: http.StatusOK, applied: 7},
+		{desc: "other writes are not replayed", method: "PATCH", path: "/api/v1/namespaces/default/configmaps/cm1", token: "node1", body: `{"data":1}`, code: http.StatusOK, applied: 8},
+		{desc: "lease update is applied", method: "PUT", path: leasePath, token: "node1", body: `{"resourceVersion":"1"}`, code: http.StatusOK, applied: 9},
+		{desc: "retry of lease update is replayed", method: "PUT", path: leasePath, token: "node1", body: `{"resourceVersion":"1"}`, code: http.StatusOK, dedup: "replayed", applied: 9},
+	}
+
+	var recorded = map[string]string{}
+	for _, tt := range testcases {
+		clk.Step(tt.step)
+		server.offline = tt.offline
+		w := send(handler, tt.method, tt.path, tt.token, tt.body)
+		if w.Code != tt.code {
+			t.Errorf("%s: expect status code %d, but got %d", tt.desc, tt.code, w.Code)
+		}
+		if got := w.Header().Get(dedupHeader); got != tt.dedup {
+			t.Errorf("%s: expect dedup %q, but got %q", tt.desc, tt.dedup, got)
+		}
+		if server.appliedCount() != tt.applied {
+			t.Errorf("%s: expect %d writes are applied, but got %d", tt.desc, tt.applied, server.appliedCount())
+		}
+
+		key := tt.method + tt.path + tt.token + tt.body
+		if tt.dedup == "replayed" && w.Body.String() != recorded[key] {
+			t.Errorf("%s: expect the response %s of the write is replayed, but got %s", tt.desc, recorded[key], w.Body.String())
+		}
+		recorded[key] = w.Body.String()
+	}
+}
+
+func TestRetryInFlight(t *testing.T) {
+	server := &fakeAPIServer{release: make(chan struct{})}
+	d := NewDeduplicator(10, time.Minute)
+	handler := newHandler(d, server)
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 2)
+	patch := func(i int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = send(handler, "PATCH", nodeStatusPath, "node1", `{"status":1}`)
+		}()
+	}
+
+	// the retry is sent while the first write is in flight
+	patch(0)
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		d.Lock()
+		n := len(d.inFlight)
+		d.Unlock()
+		if n == 1 {
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("expect the write is in flight")
+		}
+	}
+	patch(1)
+	time.Sleep(50 * time.Millisecond)
+	close(server.release)
+	wg.Wait()
+
+	if server.appliedCount() != 1 {
+		t.Errorf("expect the write is applied once, but got %d", server.appliedCount())
+	}
+	replayed := 0
+	for _, w := range results {
+		if w.Code != http.StatusOK || w.Body.String() != `{"applied":1}` {
+			t.Errorf("expect both get the response of the write, but got %d %s", w.Code, w.Body.String())
+		}
+		if w.Header().Get(dedupHeader) == "replayed" {
+			replayed++
+		}
+	}
+	if replayed != 1 {
+		t.Errorf("expect the retry is replayed, but got %d replayed", replayed)
+	}
+	if len(d.inFlight) != 0 {
+		t.Errorf("expect no write is in flight, but got %d", len(d.inFlight))
+	}
+}
+
+func TestResponsesAreBounded(t *testing.T) {
+	server := &fakeAPIServer{}
+	handler := newHandler(NewDeduplicator(2, time.Minute), server)
+
+	for _, body := range []string{`{"event":1}`, `{"event":2}`, `{"event":3}`, `{"event":1}`} {
+		send(handler, "POST", eventsPath, "node1", body)
+	}
+	if server.appliedCount() != 4 {
+		t.Errorf("expect the least recently used response is evicted, but got %d writes applied", server.appliedCount())
+	}
+}
+
+func TestNilDeduplicator(t *testing.T) {
+	server := &fakeAPIServer{}
+	handler := newHandler(nil, server)
+
+	for i := 0; i < 2; i++ {
+		send(handler, "PATCH", nodeStatusPath, "node1", `{"status":1}`)
+	}
+	if server.appliedCount() != 2 {
+		t.Errorf("expect all writes are applied by nil Deduplicator, but got %d", server.appliedCount())
+	}
+}
@@ -0,0 +1,168 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	// endpointsNamespace and endpointsName is the endpoints of kube-apiservers that is
+	// maintained by kube-apiservers themselves
+	endpointsNamespace = "default"
+	endpointsName      = "kubernetes"
+	// portName is the name of the port of kube-apiservers in endpoints
+	portName = "https"
+	// syncPeriod is the period that the cached endpoints are checked for changes
+	syncPeriod = 30 * time.Second
+	// storageTimeout bounds the time of reading the cached endpoints
+	storageTimeout = 10 * time.Second
+)
+
+// Discoverer discovers the addresses of kube-apiservers from the endpoints default/kubernetes
+// that is cached for clients on the node(like kube-proxy), so remote servers are added and
+// removed at runtime when the control plane is scaled or moved. EndpointSlices are not
+// cached by yurthub, so only the endpoints are used.
+type Discoverer struct {
+	storageWrapper cachemanager.StorageWrapper
+	listAgents     func() []string
+	scheme         string
+	servers        []*url.URL
+	stopCh         <-chan struct{}
+}
+
+// NewDiscoverer creates a Discoverer that reads the endpoints cached by the agents that
+// are returned by listAgents.
+func NewDiscoverer(cfg *config.YurtHubConfiguration,
+	storageWrapper cachemanager.StorageWrapper,
+	listAgents func() []string,
+	stopCh <-chan struct{}) *Discoverer {
+	scheme := "https"
+	if len(cfg.RemoteServers) != 0 && cfg.RemoteServers[0].Scheme != "" {
+		scheme = cfg.RemoteServers[0].Scheme
+	}
+	return &Discoverer{
+		storageWrapper: storageWrapper,
+		listAgents:     listAgents,
+		scheme:         scheme,
+		stopCh:         stopCh,
+	}
+}
+
+// Run checks the cached endpoints periodically in background, and calls onUpdate with the
+// discovered servers when they are changed.
+func (d *Discoverer) Run(onUpdate func(servers []*url.URL)) {
+	go wait.Until(func() {
+		servers, changed := d.discover()
+		if changed {
+			klog.Infof("discovered remote servers are changed to %v", servers)
+			onUpdate(servers)
+		}
+	}, syncPeriod, d.stopCh)
+}
+
+// discover returns the servers in the newest cached endpoints, and whether they are
+// different from the servers discovered last time. the servers are kept when no endpoints
+// is cached, like before clients list endpoints or after the cache is cleaned.
+func (d *Discoverer) discover() ([]*url.URL, bool) {
+	ep := d.newestEndpoints()
+	if ep == nil {
+		return d.servers, false
+	}
+
+	servers := serversFrom(ep, d.scheme)
+	if len(servers) == 0 || equal(servers, d.servers) {
+		return d.servers, false
+	}
+	d.servers = servers
+	return servers, true
+}
+
+// newestEndpoints returns the cached endpoints with the largest resource version, because
+// the agents may cache the endpoints at different time.
+func (d *Discoverer) newestEndpoints() *v1.Endpoints {
+	var newest *v1.Endpoints
+	var newestVersion uint64
+	for _, agent := range d.listAgents() {
+		key, err := util.KeyFunc(agent, "endpoints", endpointsNamespace, endpointsName)
+		if err != nil {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+		obj, err := d.storageWrapper.Get(ctx, key)
+		cancel()
+		if err != nil {
+			if err != storage.ErrNotFound {
+				klog.Errorf("failed to get cached endpoints %s, %v", key, err)
+			}
+			continue
+		}
+
+		ep, ok := obj.(*v1.Endpoints)
+		if !ok {
+			continue
+		}
+		version, _ := strconv.ParseUint(ep.ResourceVersion, 10, 64)
+		if newest == nil || version > newestVersion {
+			newest, newestVersion = ep, version
+		}
+	}
+	return newest
+}
+
+// serversFrom returns the sorted urls of the ready addresses in ep
+func serversFrom(ep *v1.Endpoints, scheme string) []*url.URL {
+	seen := make(map[string]bool)
+	servers := make([]*url.URL, 0)
+	for _, subset := range ep.Subsets {
+		var port int32
+		for _, p := range subset.Ports {
+			if p.Name == portName || (len(subset.Ports) == 1 && p.Name == "") {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+
+		for _, addr := range subset.Addresses {
+			host := net.JoinHostPort(addr.IP, strconv.Itoa(int(port)))
+			if addr.IP == "" || seen[host] {
+				continue
+			}
+			seen[host] = true
+			servers = append(servers, &url.URL{Scheme: scheme, Host: host})
+		}
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Host < servers[j].Host
+	})
+	return servers
+}
+
+func equal(a, b []*url.URL) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
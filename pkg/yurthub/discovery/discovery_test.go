@@ -0,0 +1,119 @@
+package discovery
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func endpoints(rv string, port v1.EndpointPort, ips ...string) *v1.Endpoints {
+	addrs := make([]v1.EndpointAddress, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, v1.EndpointAddress{IP: ip})
+	}
+	return &v1.Endpoints{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Endpoints"},
+		ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: "default", ResourceVersion: rv},
+		Subsets: []v1.EndpointSubset{{
+			Addresses:         addrs,
+			NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.9"}},
+			Ports:             []v1.EndpointPort{port},
+		}},
+	}
+}
+
+func strings(servers []*url.URL) []string {
+	s := make([]string, 0, len(servers))
+	for _, server := range servers {
+		s = append(s, server.String())
+	}
+	return s
+}
+
+func TestServersFrom(t *testing.T) {
+	testcases := map[string]struct {
+		ep      *v1.Endpoints
+		servers []string
+	}{
+		"https port": {
+			ep:      endpoints("1", v1.EndpointPort{Name: "https", Port: 6443}, "10.0.0.2", "10.0.0.1"),
+			servers: []string{"https://10.0.0.1:6443", "https://10.0.0.2:6443"},
+		},
+		"unnamed port": {
+			ep:      endpoints("1", v1.EndpointPort{Port: 443}, "10.0.0.1"),
+			servers: []string{"https://10.0.0.1:443"},
+		},
+		"ipv6": {
+			ep:      endpoints("1", v1.EndpointPort{Name: "https", Port: 6443}, "fd00::1"),
+			servers: []string{"https://[fd00::1]:6443"},
+		},
+		"other port": {
+			ep:      endpoints("1", v1.EndpointPort{Name: "metrics", Port: 8080}, "10.0.0.1"),
+			servers: []string{},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			servers := strings(serversFrom(tt.ep, "https"))
+			if len(servers) != len(tt.servers) {
+				t.Fatalf("expect servers %v, but got %v", tt.servers, servers)
+			}
+			for i := range servers {
+				if servers[i] != tt.servers[i] {
+					t.Errorf("expect servers %v, but got %v", tt.servers, servers)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec())
+	ctx := context.Background()
+	port := v1.EndpointPort{Name: "https", Port: 6443}
+	if err := sw.Create(ctx, "kubelet/endpoints/default/kubernetes", endpoints("10", port, "10.0.0.1")); err != nil {
+		t.Fatalf("failed to create endpoints, %v", err)
+	}
+	if err := sw.Create(ctx, "kube-proxy/endpoints/default/kubernetes", endpoints("20", port, "10.0.0.1", "10.0.0.2")); err != nil {
+		t.Fatalf("failed to create endpoints, %v", err)
+	}
+
+	agents := []string{"coredns", "kubelet", "kube-proxy"}
+	d := &Discoverer{
+		storageWrapper: sw,
+		listAgents:     func() []string { return agents },
+		scheme:         "https",
+	}
+
+	servers, changed := d.discover()
+	if !changed || len(servers) != 2 {
+		t.Fatalf("expect the servers in the newest endpoints are discovered, but got %v(changed %v)", strings(servers), changed)
+	}
+
+	if _, changed := d.discover(); changed {
+		t.Errorf("expect no change when the endpoints is not changed")
+	}
+
+	if err := sw.Update(ctx, "kube-proxy/endpoints/default/kubernetes", endpoints("30", port, "10.0.0.3")); err != nil {
+		t.Fatalf("failed to update endpoints, %v", err)
+	}
+	servers, changed = d.discover()
+	if !changed || len(servers) != 1 || servers[0].String() != "https://10.0.0.3:6443" {
+		t.Errorf("expect the moved server is discovered, but got %v(changed %v)", strings(servers), changed)
+	}
+
+	agents = nil
+	servers, changed = d.discover()
+	if changed || len(servers) != 1 {
+		t.Errorf("expect the servers are kept when no endpoints is cached, but got %v(changed %v)", strings(servers), changed)
+	}
+}
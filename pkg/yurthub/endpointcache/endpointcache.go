@@ -0,0 +1,300 @@
+package endpointcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/klog"
+)
+
+const (
+	// PathPrefix is the prefix of paths that the cached endpoints are served on,
+	// like /v1/endpoints/{name}/{path of endpoint}
+	PathPrefix = "/v1/endpoints/"
+	// cacheKeyPrefix is the key in storage that responses of endpoints are cached under
+	cacheKeyPrefix = "_internal/endpoint-cache"
+	// defaultTTL is the time that a cached response is fresh if ttl of endpoint is not set
+	defaultTTL = 5 * time.Minute
+	// maxCachedBodySize bounds the size of responses, the endpoints are expected to
+	// serve configs rather than bulk data, larger responses are failed.
+	maxCachedBodySize = 10 * 1024 * 1024
+	// upstreamTimeout bounds the time of requesting an endpoint, the cached response
+	// is served when the endpoint doesn't respond in time.
+	upstreamTimeout = 10 * time.Second
+	// storageTimeout bounds the time of reading and writing a cached response
+	storageTimeout = 10 * time.Second
+	// cacheHeader tells the client whether the response is a fresh hit, a stale hit or a miss
+	cacheHeader = "X-Yurthub-Cache"
+)
+
+// cachedHeaders are the response headers that are cached with the body
+var cachedHeaders = []string{"Content-Type", "Content-Encoding", "ETag", "Last-Modified"}
+
+// Endpoint is an https endpoint outside of kubernetes(like an internal config service) that
+// node agents read through yurthub, so its responses are cached for offline operation.
+type Endpoint struct {
+	Name   string
+	URL    *url.URL
+	TTL    time.Duration
+	CAFile string
+}
+
+// ParseEndpoints parses the endpoints in the format of "name=url[;ttl=seconds][;ca=file]",
+// url must be https, ttl is the time that a cached response is fresh(5 minutes by default),
+// and ca is the certificate authority to verify the endpoint(system roots by default).
+func ParseEndpoints(entries []string) ([]*Endpoint, error) {
+	names := make(map[string]bool)
+	endpoints := make([]*Endpoint, 0, len(entries))
+	for _, entry := range entries {
+		fields := strings.Split(entry, ";")
+		parts := strings.SplitN(fields[0], "=", 2)
+		if len(parts) != 2 || parts[0] == "" || strings.Contains(parts[0], "/") {
+			return nil, fmt.Errorf("cached endpoint(%s) is invalid, the format is name=url[;ttl=seconds][;ca=file]", entry)
+		}
+		name := strings.TrimSpace(parts[0])
+		if names[name] {
+			return nil, fmt.Errorf("cached endpoint %s is duplicated", name)
+		}
+		names[name] = true
+
+		u, err := url.Parse(strings.TrimSpace(parts[1]))
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("url of cached endpoint %s must be https", name)
+		}
+
+		ep := &Endpoint{Name: name, URL: u, TTL: defaultTTL}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("option(%s) of cached endpoint %s is invalid", field, name)
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "ttl":
+				seconds, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+				if err != nil || seconds <= 0 {
+					return nil, fmt.Errorf("ttl of cached endpoint %s is not a positive integer", name)
+				}
+				ep.TTL = time.Duration(seconds) * time.Second
+			case "ca":
+				ep.CAFile = strings.TrimSpace(kv[1])
+			default:
+				return nil, fmt.Errorf("option(%s) of cached endpoint %s is not supported", field, name)
+			}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// cachedResponse is the response of an endpoint in storage
+type cachedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	FetchedAt  time.Time   `json:"fetchedAt"`
+}
+
+type endpointClient struct {
+	*Endpoint
+	client *http.Client
+}
+
+// Cache proxies the gets of node agents to the endpoints, and caches their successful responses.
+// a cached response is served without requesting the endpoint while it's fresh(within ttl), and
+// it's served as stale when the endpoint is unavailable(like the node is offline), so node agents
+// keep working beyond the kubernetes api. responses are cached per authorization of requests, so
+// they are not shared between agents with different credentials.
+type Cache struct {
+	store     storage.Store
+	endpoints map[string]*endpointClient
+	now       func() time.Time
+}
+
+// NewCache creates a Cache for endpoints, dial is used to connect the endpoints
+func NewCache(endpoints []*Endpoint, store storage.Store, dial util.DialFunc) (*Cache, error) {
+	c := &Cache{
+		store:     store,
+		endpoints: make(map[string]*endpointClient),
+		now:       time.Now,
+	}
+	for _, ep := range endpoints {
+		tlsConfig := &tls.Config{}
+		if ep.CAFile != "" {
+			pem, err := ioutil.ReadFile(ep.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read ca of cached endpoint %s, %v", ep.Name, err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate in ca of cached endpoint %s", ep.Name)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		c.endpoints[ep.Name] = &endpointClient{
+			Endpoint: ep,
+			client: &http.Client{
+				Timeout: upstreamTimeout,
+				Transport: &http.Transport{
+					Proxy:               http.ProxyFromEnvironment,
+					DialContext:         dial,
+					TLSClientConfig:     tlsConfig,
+					TLSHandshakeTimeout: 10 * time.Second,
+					MaxIdleConnsPerHost: 2,
+				},
+			},
+		}
+	}
+	return c, nil
+}
+
+func (c *Cache) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "only get is supported for cached endpoints", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, PathPrefix), "/", 2)
+	ep, ok := c.endpoints[parts[0]]
+	if !ok {
+		http.Error(w, fmt.Sprintf("endpoint %s is not cached", parts[0]), http.StatusNotFound)
+		return
+	}
+	subPath := ""
+	if len(parts) == 2 {
+		subPath = parts[1]
+	}
+
+	target := *ep.URL
+	target.Path = path.Join("/", ep.URL.Path, subPath)
+	target.RawQuery = req.URL.RawQuery
+	key := c.cacheKey(ep, &target, req.Header.Get("Authorization"))
+
+	cached := c.load(key)
+	if cached != nil && c.now().Sub(cached.FetchedAt) < ep.TTL {
+		writeResponse(w, cached, "hit")
+		return
+	}
+
+	resp, err := c.fetch(req.Context(), ep, &target, req.Header)
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if cached != nil {
+			klog.Warningf("serve stale response of %s fetched at %s, endpoint is unavailable, %v", target.String(), cached.FetchedAt.Format(time.RFC3339), err)
+			w.Header().Set("Warning", `110 - "Response is Stale"`)
+			writeResponse(w, cached, "stale")
+			return
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("endpoint %s is unavailable, %v", ep.Name, err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		c.save(key, resp)
+	}
+	writeResponse(w, resp, "miss")
+}
+
+// cacheKey is the key of response for the target url and authorization
+func (c *Cache) cacheKey(ep *endpointClient, target *url.URL, authorization string) string {
+	sum := sha256.Sum256([]byte(target.String() + "\n" + authorization))
+	return path.Join(cacheKeyPrefix, ep.Name, hex.EncodeToString(sum[:]))
+}
+
+func (c *Cache) fetch(ctx context.Context, ep *endpointClient, target *url.URL, header http.Header) (*cachedResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for _, h := range []string{"Accept", "Authorization", "User-Agent"} {
+		if v := header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+
+	resp, err := ep.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCachedBodySize+1))
+	if err != nil {
+		return nil, err
+	} else if len(body) > maxCachedBodySize {
+		return nil, fmt.Errorf("response of %s exceeds %d bytes", target.String(), maxCachedBodySize)
+	}
+
+	cached := &cachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     http.Header{},
+		Body:       body,
+		FetchedAt:  c.now(),
+	}
+	for _, h := range cachedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			cached.Header.Set(h, v)
+		}
+	}
+	return cached, nil
+}
+
+func (c *Cache) load(key string) *cachedResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	b, err := c.store.Get(ctx, key)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			klog.Errorf("failed to get cached response %s, %v", key, err)
+		}
+		return nil
+	}
+
+	cached := &cachedResponse{}
+	if err := json.Unmarshal(b, cached); err != nil {
+		klog.Errorf("failed to decode cached response %s, %v", key, err)
+		return nil
+	}
+	return cached
+}
+
+func (c *Cache) save(key string, resp *cachedResponse) {
+	b, err := json.Marshal(resp)
+	if err != nil {
+		klog.Errorf("failed to encode response for %s, %v", key, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := c.store.Update(ctx, key, b); err != nil {
+		klog.Errorf("failed to cache response %s, %v", key, err)
+	}
+}
+
+func writeResponse(w http.ResponseWriter, resp *cachedResponse, cacheStatus string) {
+	for h, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+	w.Header().Set(cacheHeader, cacheStatus)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
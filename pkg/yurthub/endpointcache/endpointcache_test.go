@@ -0,0 +1,147 @@
+package endpointcache
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	testcases := map[string]struct {
+		entries []string
+		ttl     time.Duration
+		valid   bool
+	}{
+		"default ttl": {
+			entries: []string{"config=https://config.internal:8443/v1"},
+			ttl:     defaultTTL,
+			valid:   true,
+		},
+		"ttl and ca": {
+			entries: []string{"config=https://config.internal/v1;ttl=60;ca=/etc/config/ca.crt"},
+			ttl:     time.Minute,
+			valid:   true,
+		},
+		"http": {
+			entries: []string{"config=http://config.internal/v1"},
+		},
+		"no name": {
+			entries: []string{"=https://config.internal/v1"},
+		},
+		"duplicated": {
+			entries: []string{"config=https://a.internal", "config=https://b.internal"},
+		},
+		"invalid ttl": {
+			entries: []string{"config=https://config.internal;ttl=0"},
+		},
+		"unknown option": {
+			entries: []string{"config=https://config.internal;retries=3"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			endpoints, err := ParseEndpoints(tt.entries)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expect invalid")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect valid, but got %v", err)
+			}
+			if endpoints[0].Name != "config" || endpoints[0].TTL != tt.ttl {
+				t.Errorf("unexpected endpoint %#v", endpoints[0])
+			}
+		})
+	}
+}
+
+func TestCache(t *testing.T) {
+	requests := 0
+	available := true
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		requests++
+		if r.URL.Path != "/v1/agents/agent1" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"version":%d,"auth":%q}`, requests, r.Header.Get("Authorization"))
+	}))
+	defer upstream.Close()
+
+	dir, err := ioutil.TempDir("", "endpointcache")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.crt")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: upstream.Certificate().Raw}), 0600)
+
+	endpoints, err := ParseEndpoints([]string{fmt.Sprintf("config=%s/v1;ttl=60;ca=%s", upstream.URL, caFile)})
+	if err != nil {
+		t.Fatalf("failed to parse endpoints, %v", err)
+	}
+	store, _ := fake.NewFakeStorage()
+	cache, err := NewCache(endpoints, store, (&net.Dialer{}).DialContext)
+	if err != nil {
+		t.Fatalf("failed to new cache, %v", err)
+	}
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	get := func(path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rw := httptest.NewRecorder()
+		cache.ServeHTTP(rw, req)
+		return rw
+	}
+
+	steps := []struct {
+		desc    string
+		advance time.Duration
+		path    string
+		auth    string
+		down    bool
+		code    int
+		status  string
+		body    string
+	}{
+		{desc: "miss", path: "/v1/endpoints/config/agents/agent1", code: http.StatusOK, status: "miss", body: `{"version":1,"auth":""}`},
+		{desc: "fresh hit", path: "/v1/endpoints/config/agents/agent1", code: http.StatusOK, status: "hit", body: `{"version":1,"auth":""}`},
+		{desc: "another authorization", path: "/v1/endpoints/config/agents/agent1", auth: "Bearer a", code: http.StatusOK, status: "miss", body: `{"version":2,"auth":"Bearer a"}`},
+		{desc: "expired", advance: 2 * time.Minute, path: "/v1/endpoints/config/agents/agent1", code: http.StatusOK, status: "miss", body: `{"version":3,"auth":""}`},
+		{desc: "stale", advance: 2 * time.Minute, path: "/v1/endpoints/config/agents/agent1", down: true, code: http.StatusOK, status: "stale", body: `{"version":3,"auth":""}`},
+		{desc: "not cached", path: "/v1/endpoints/config/agents/agent2", code: http.StatusNotFound, status: "miss"},
+		{desc: "unknown endpoint", path: "/v1/endpoints/other/agents/agent1", code: http.StatusNotFound},
+	}
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		available = !s.down
+		rw := get(s.path, s.auth)
+		if rw.Code != s.code || rw.Header().Get(cacheHeader) != s.status {
+			t.Errorf("%s: expect %d(%s), but got %d(%s)", s.desc, s.code, s.status, rw.Code, rw.Header().Get(cacheHeader))
+		}
+		if s.body != "" && rw.Body.String() != s.body {
+			t.Errorf("%s: expect body %s, but got %s", s.desc, s.body, rw.Body.String())
+		}
+	}
+}
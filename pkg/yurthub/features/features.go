@@ -0,0 +1,47 @@
+package features
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// CacheEncryption encrypts the cached objects of encrypted resources on disk when
+	// encryption-key-file or encryption-kms-endpoint is set.
+	CacheEncryption featuregate.Feature = "CacheEncryption"
+
+	// LeaseBatching queues, coalesces and locally renews the updates of leases when
+	// enable-lease-batching is set.
+	LeaseBatching featuregate.Feature = "LeaseBatching"
+
+	// RequestMirroring mirrors the sampled read requests to the cluster of
+	// mirror-kubeconfig when it's set.
+	RequestMirroring featuregate.Feature = "RequestMirroring"
+)
+
+var (
+	// DefaultMutableFeatureGate is the feature gate of yurthub that is shared by all its
+	// subsystems, it's set by --feature-gates. subsystems register their features by Add
+	// in init, as features can't be added after the flag is added.
+	DefaultMutableFeatureGate featuregate.MutableFeatureGate = featuregate.NewFeatureGate()
+
+	// DefaultFeatureGate is the read-only view of DefaultMutableFeatureGate
+	DefaultFeatureGate featuregate.FeatureGate = DefaultMutableFeatureGate
+)
+
+// defaultFeatureGates are the features of yurthub and their defaults, alpha features are
+// disabled by default.
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	CacheEncryption:  {Default: true, PreRelease: featuregate.Beta},
+	LeaseBatching:    {Default: false, PreRelease: featuregate.Alpha},
+	RequestMirroring: {Default: false, PreRelease: featuregate.Alpha},
+}
+
+func init() {
+	runtime.Must(DefaultMutableFeatureGate.Add(defaultFeatureGates))
+}
+
+// Enabled returns true if feature is enabled in DefaultFeatureGate
+func Enabled(feature featuregate.Feature) bool {
+	return DefaultFeatureGate.Enabled(feature)
+}
@@ -0,0 +1,60 @@
+package features
+
+import (
+	"testing"
+
+	"k8s.io/component-base/featuregate"
+)
+
+func TestFeatureGates(t *testing.T) {
+	testcases := map[string]struct {
+		value   string
+		enabled map[featuregate.Feature]bool
+		err     bool
+	}{
+		"defaults": {
+			enabled: map[featuregate.Feature]bool{CacheEncryption: true, LeaseBatching: false, RequestMirroring: false},
+		},
+		"alpha features are enabled": {
+			value:   "LeaseBatching=true,RequestMirroring=true",
+			enabled: map[featuregate.Feature]bool{CacheEncryption: true, LeaseBatching: true, RequestMirroring: true},
+		},
+		"beta feature is disabled": {
+			value:   "CacheEncryption=false",
+			enabled: map[featuregate.Feature]bool{CacheEncryption: false, LeaseBatching: false, RequestMirroring: false},
+		},
+		"unknown feature": {
+			value: "UnknownFeature=true",
+			err:   true,
+		},
+		"invalid value": {
+			value: "LeaseBatching=yes",
+			err:   true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			gate := DefaultFeatureGate.DeepCopy()
+			if len(tt.value) != 0 {
+				err := gate.Set(tt.value)
+				if (err != nil) != tt.err {
+					t.Fatalf("expect error %v, but got %v", tt.err, err)
+				}
+				if err != nil {
+					return
+				}
+			}
+			for feature, enabled := range tt.enabled {
+				if gate.Enabled(feature) != enabled {
+					t.Errorf("expect %s enabled %v, but got %v", feature, enabled, !enabled)
+				}
+			}
+		})
+	}
+
+	// the copies don't change the default gate
+	if Enabled(LeaseBatching) || !Enabled(CacheEncryption) {
+		t.Errorf("expect default feature gate is not changed")
+	}
+}
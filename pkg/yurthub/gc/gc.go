@@ -1,6 +1,7 @@
 package gc
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
@@ -22,9 +24,13 @@ var (
 	defaultEventGcInterval = 60
 )
 
+// ClientFunc returns the client of kube-apiserver, an error is returned if it's not available
+type ClientFunc func() (clientset.Interface, error)
+
 type GCManager struct {
 	store             storage.Store
-	transportManager  transport.Interface
+	newClient         ClientFunc
+	clock             clock.Clock
 	nodeName          string
 	eventsGCFrequency time.Duration
 	lastTime          time.Time
@@ -32,13 +38,27 @@ type GCManager struct {
 }
 
 func NewGCManager(cfg *config.YurtHubConfiguration, store storage.Store, transportManager transport.Interface, stopCh <-chan struct{}) (*GCManager, error) {
+	newClient := func() (clientset.Interface, error) {
+		restCfg := transportManager.GetRestClientConfig()
+		if restCfg == nil {
+			return nil, fmt.Errorf("could not get rest config")
+		}
+		return clientset.NewForConfig(restCfg)
+	}
+	return NewGCManagerWith(cfg, store, newClient, clock.RealClock{}, stopCh)
+}
+
+// NewGCManagerWith creates a GCManager that gets the client of kube-apiserver by newClient, and waits
+// for the next gc by clk. it's used to gc with a simulated network and time in tests.
+func NewGCManagerWith(cfg *config.YurtHubConfiguration, store storage.Store, newClient ClientFunc, clk clock.Clock, stopCh <-chan struct{}) (*GCManager, error) {
 	gcFrequency := cfg.GCFrequency
 	if gcFrequency == 0 {
 		gcFrequency = defaultEventGcInterval
 	}
 	mgr := &GCManager{
 		store:             store,
-		transportManager:  transportManager,
+		newClient:         newClient,
+		clock:             clk,
 		nodeName:          cfg.NodeName,
 		eventsGCFrequency: time.Duration(gcFrequency) * time.Minute,
 		stopCh:            stopCh,
@@ -49,41 +69,50 @@ func NewGCManager(cfg *config.YurtHubConfiguration, store storage.Store, transpo
 
 func (m *GCManager) Run() {
 	// run gc events after a time duration between eventsGCFrequency and 3 * eventsGCFrequency
-	m.lastTime = time.Now()
-	go wait.JitterUntil(func() {
-		klog.V(2).Infof("start gc events after waiting %v from previous gc", time.Now().Sub(m.lastTime))
-		m.lastTime = time.Now()
-		cfg := m.transportManager.GetRestClientConfig()
-		if cfg == nil {
-			klog.Errorf("could not get rest config, so skip gc")
-			return
-		}
-		kubeClient, err := clientset.NewForConfig(cfg)
-		if err != nil {
-			klog.Errorf("could not new kube client, %v", err)
-			return
+	m.lastTime = m.clock.Now()
+	go func() {
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			default:
+			}
+			m.gcEventsOnce()
+
+			timer := m.clock.NewTimer(wait.Jitter(m.eventsGCFrequency, 2))
+			select {
+			case <-m.stopCh:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
 		}
+	}()
+}
 
-		m.gcEvents(kubeClient, "kubelet")
-		m.gcEvents(kubeClient, "kube-proxy")
-	}, m.eventsGCFrequency, 2, true, m.stopCh)
+func (m *GCManager) gcEventsOnce() {
+	klog.V(2).Infof("start gc events after waiting %v from previous gc", m.clock.Since(m.lastTime))
+	m.lastTime = m.clock.Now()
+	kubeClient, err := m.newClient()
+	if err != nil {
+		klog.Errorf("could not new kube client, so skip gc, %v", err)
+		return
+	}
+
+	m.gcEvents(kubeClient, "kubelet")
+	m.gcEvents(kubeClient, "kube-proxy")
 }
 
 func (m *GCManager) gcPodsWhenRestart() error {
-	localPodKeys, err := m.store.ListKeys("kubelet/pods")
+	localPodKeys, err := m.store.ListKeys(context.Background(), "kubelet/pods")
 	if err != nil || len(localPodKeys) == 0 {
 		return nil
 	}
 	klog.Infof("list pod keys from storage, total: %d", len(localPodKeys))
 
-	cfg := m.transportManager.GetRestClientConfig()
-	if cfg == nil {
-		klog.Errorf("could not get rest config, so skip gc pods when restart")
-		return err
-	}
-	kubeClient, err := clientset.NewForConfig(cfg)
+	kubeClient, err := m.newClient()
 	if err != nil {
-		klog.Errorf("could not new kube client, %v", err)
+		klog.Errorf("could not new kube client, so skip gc pods when restart, %v", err)
 		return err
 	}
 
@@ -117,7 +146,7 @@ func (m *GCManager) gcPodsWhenRestart() error {
 	}
 
 	for _, key := range deletedPods {
-		if err := m.store.Delete(key); err != nil {
+		if err := m.store.Delete(context.Background(), key); err != nil {
 			klog.Errorf("failed to gc pod %s, %v", key, err)
 		} else {
 			klog.Infof("gc pod %s successfully", key)
@@ -132,7 +161,8 @@ func (m *GCManager) gcEvents(kubeClient clientset.Interface, component string) {
 		return
 	}
 
-	localEventKeys, err := m.store.ListKeys(fmt.Sprintf("%s/events", component))
+	// check the oldest events first, because gc stops at the first failure
+	localEventKeys, err := storage.ListKeysInOrder(context.Background(), m.store, fmt.Sprintf("%s/events", component), storage.OrderByModTime)
 	if err != nil {
 		klog.Errorf("could not list keys for %s events, %v", component, err)
 		return
@@ -160,7 +190,7 @@ func (m *GCManager) gcEvents(kubeClient clientset.Interface, component string) {
 	}
 
 	for _, key := range deletedEvents {
-		if err := m.store.Delete(key); err != nil {
+		if err := m.store.Delete(context.Background(), key); err != nil {
 			klog.Errorf("failed to gc events %s, %v", key, err)
 		} else {
 			klog.Infof("gc events %s successfully", key)
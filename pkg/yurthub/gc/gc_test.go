@@ -0,0 +1,84 @@
+package gc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+	yurttesting "github.com/alibaba/openyurt/pkg/yurthub/testing"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fakeclient "k8s.io/client-go/kubernetes/fake"
+)
+
+// orderedStore lists keys by mod time in the order of keys, the fake storage has no mod time
+type orderedStore struct {
+	storage.Store
+}
+
+func (s *orderedStore) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	return s.ListKeys(ctx, key)
+}
+
+func (s *orderedStore) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	return s.List(ctx, key)
+}
+
+func TestGCEventsWithFakeNetwork(t *testing.T) {
+	fakeStore, _ := fake.NewFakeStorage()
+	store := &orderedStore{Store: fakeStore}
+	existingKey, _ := util.KeyFunc("kubelet", "events", "default", "existing")
+	deletedKey, _ := util.KeyFunc("kubelet", "events", "default", "deleted")
+	store.Create(context.Background(), existingKey, []byte("{}"))
+	store.Create(context.Background(), deletedKey, []byte("{}"))
+
+	client := fakeclient.NewSimpleClientset(&v1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "existing"}})
+	network := yurttesting.NewFakeNetwork()
+	clk := yurttesting.NewFakeClock(time.Now())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	cfg := &config.YurtHubConfiguration{NodeName: "node1", GCFrequency: 10}
+	m, err := NewGCManagerWith(cfg, store, network.ClientFunc("10.0.0.1:6443", client), clk, stopCh)
+	if err != nil {
+		t.Fatalf("failed to new gc manager, %v", err)
+	}
+
+	// waitGC waits for the gc to wait for the next gc
+	waitGC := func() {
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return clk.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("gc is not complete")
+		}
+	}
+	keys := func() int {
+		keys, _ := store.ListKeys(context.Background(), "kubelet/events")
+		return len(keys)
+	}
+
+	// gc is skipped while kube-apiserver is unreachable
+	network.Disconnect()
+	m.Run()
+	waitGC()
+	if n := keys(); n != 2 {
+		t.Fatalf("expect no event is gc while disconnected, but got %d events", n)
+	}
+
+	// the next gc is after 1 to 3 times of gc frequency
+	network.Connect()
+	clk.Step(3 * m.eventsGCFrequency)
+	waitGC()
+	if _, err := store.Get(context.Background(), deletedKey); err != storage.ErrNotFound {
+		t.Errorf("expect deleted event is gc, but got %v", err)
+	}
+	if _, err := store.Get(context.Background(), existingKey); err != nil {
+		t.Errorf("expect existing event is kept, but got %v", err)
+	}
+}
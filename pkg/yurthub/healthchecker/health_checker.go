@@ -11,6 +11,7 @@ import (
 
 	"github.com/alibaba/openyurt/pkg/yurthub/transport"
 
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/klog"
 )
 
@@ -24,32 +25,131 @@ type HealthChecker interface {
 	IsHealthy(server *url.URL) bool
 }
 
+// DynamicHealthChecker is implemented by HealthChecker that remote servers can be added to
+// and removed from at runtime, like when kube-apiservers are scaled or moved.
+type DynamicHealthChecker interface {
+	HealthChecker
+	// UpdateServers checks the health of servers, the checking of servers that are not in
+	// servers any more is stopped.
+	UpdateServers(servers []*url.URL)
+}
+
+// Prober probes the healthz of remote servers, an error is returned if the server is unhealthy
+// or unreachable. it's replaced by a fake network(like in pkg/yurthub/testing) in tests.
+type Prober interface {
+	Probe(healthzAddr string) error
+}
+
+// httpProber probes the healthz by the http client
+type httpProber struct {
+	client *http.Client
+}
+
+func (p *httpProber) Probe(healthzAddr string) error {
+	_, err := pingClusterHealthz(p.client, healthzAddr)
+	return err
+}
+
 type healthCheckerManager struct {
 	sync.RWMutex
 	checkers map[string]*checker
+	// stops stop the checking of servers when they are removed
+	stops map[string]chan struct{}
+
+	prober           Prober
+	clock            clock.Clock
+	onFailure        func(string)
+	failedRetry      int
+	healthyThreshold int
+	stopCh           <-chan struct{}
 }
 
 func NewHealthChecker(remoteServers []*url.URL, tp transport.Interface, failedRetry, healthyThreshold int, stopCh <-chan struct{}) (HealthChecker, error) {
+	return NewHealthCheckerWith(remoteServers, &httpProber{client: tp.HealthzHttpClient()}, clock.RealClock{}, tp.Close, failedRetry, healthyThreshold, stopCh)
+}
+
+// NewHealthCheckerWith creates a HealthChecker that probes remote servers by prober every heartbeat
+// of clk, and onFailure is called with the host of server when it becomes unhealthy. it's used to
+// check the health with a simulated network and time, so the checking is deterministic in tests.
+func NewHealthCheckerWith(remoteServers []*url.URL, prober Prober, clk clock.Clock, onFailure func(string), failedRetry, healthyThreshold int, stopCh <-chan struct{}) (HealthChecker, error) {
 	if len(remoteServers) == 0 {
 		return nil, fmt.Errorf("no remote servers")
 	}
 
 	hcm := &healthCheckerManager{
-		checkers: make(map[string]*checker),
+		checkers:         make(map[string]*checker),
+		stops:            make(map[string]chan struct{}),
+		prober:           prober,
+		clock:            clk,
+		onFailure:        onFailure,
+		failedRetry:      failedRetry,
+		healthyThreshold: healthyThreshold,
+		stopCh:           stopCh,
 	}
 
 	for _, server := range remoteServers {
-		checker, err := newChecker(server, tp, failedRetry, healthyThreshold, stopCh)
-		if err != nil {
-			klog.Errorf("new health checker for %s err, %v", server.String(), err)
+		if err := hcm.addServer(server); err != nil {
 			return nil, err
 		}
-		hcm.checkers[server.String()] = checker
 	}
 
 	return hcm, nil
 }
 
+// addServer starts to check the health of server until it's removed or stopCh is closed
+func (hcm *healthCheckerManager) addServer(server *url.URL) error {
+	stop := make(chan struct{})
+	checkerStopCh := make(chan struct{})
+	go func() {
+		defer close(checkerStopCh)
+		select {
+		case <-hcm.stopCh:
+		case <-stop:
+		}
+	}()
+
+	checker, err := newChecker(server, hcm.prober, hcm.clock, hcm.onFailure, hcm.failedRetry, hcm.healthyThreshold, checkerStopCh)
+	if err != nil {
+		close(stop)
+		klog.Errorf("new health checker for %s err, %v", server.String(), err)
+		return err
+	}
+
+	hcm.Lock()
+	defer hcm.Unlock()
+	hcm.checkers[server.String()] = checker
+	hcm.stops[server.String()] = stop
+	return nil
+}
+
+// UpdateServers starts to check the health of the servers that are added, and stops
+// checking the servers that are removed.
+func (hcm *healthCheckerManager) UpdateServers(servers []*url.URL) {
+	desired := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		desired[server.String()] = true
+
+		hcm.RLock()
+		_, ok := hcm.checkers[server.String()]
+		hcm.RUnlock()
+		if !ok {
+			klog.Infof("start to check the health of remote server %s", server.String())
+			hcm.addServer(server)
+		}
+	}
+
+	hcm.Lock()
+	defer hcm.Unlock()
+	for server, stop := range hcm.stops {
+		if !desired[server] {
+			klog.Infof("stop checking the health of remote server %s", server)
+			close(stop)
+			delete(hcm.stops, server)
+			delete(hcm.checkers, server)
+		}
+	}
+}
+
 func (hcm *healthCheckerManager) IsHealthy(server *url.URL) bool {
 	hcm.RLock()
 	defer hcm.RUnlock()
@@ -63,7 +163,8 @@ func (hcm *healthCheckerManager) IsHealthy(server *url.URL) bool {
 type checker struct {
 	sync.RWMutex
 	serverHealthzAddr string
-	healthzClient     *http.Client
+	prober            Prober
+	clock             clock.Clock
 	clusterHealthy    bool
 	lastTime          time.Time
 	onFailureFunc     func(string)
@@ -72,7 +173,7 @@ type checker struct {
 	healthyThreshold  int
 }
 
-func newChecker(url *url.URL, tp transport.Interface, failedRetry, healthyThreshold int, stopCh <-chan struct{}) (*checker, error) {
+func newChecker(url *url.URL, prober Prober, clk clock.Clock, onFailure func(string), failedRetry, healthyThreshold int, stopCh <-chan struct{}) (*checker, error) {
 	serverHealthzUrl := *url
 	if serverHealthzUrl.Path == "" || serverHealthzUrl.Path == "/" {
 		serverHealthzUrl.Path = "/healthz"
@@ -88,20 +189,21 @@ func newChecker(url *url.URL, tp transport.Interface, failedRetry, healthyThresh
 
 	c := &checker{
 		serverHealthzAddr: serverHealthzUrl.String(),
-		healthzClient:     tp.HealthzHttpClient(),
+		prober:            prober,
+		clock:             clk,
 		clusterHealthy:    false,
-		lastTime:          time.Now(),
-		onFailureFunc:     tp.Close,
+		lastTime:          clk.Now(),
+		onFailureFunc:     onFailure,
 		netAddress:        url.Host,
 		failedRetry:       failedRetry,
 		healthyThreshold:  healthyThreshold,
 	}
 
-	initHealthyStatus, err := pingClusterHealthz(c.healthzClient, c.serverHealthzAddr)
+	err := c.prober.Probe(c.serverHealthzAddr)
 	if err != nil {
 		klog.Errorf("cluster(%s) init status: unhealthy, %v", c.serverHealthzAddr, err)
 	}
-	c.clusterHealthy = initHealthyStatus
+	c.clusterHealthy = err == nil
 
 	go c.healthyCheckLoop(stopCh)
 	return c, nil
@@ -109,12 +211,12 @@ func newChecker(url *url.URL, tp transport.Interface, failedRetry, healthyThresh
 
 func (c *checker) isHealthy() bool {
 	c.RLock()
-	c.RUnlock()
+	defer c.RUnlock()
 	return c.clusterHealthy
 }
 
 func (c *checker) healthyCheckLoop(stopCh <-chan struct{}) {
-	intervalTicker := time.NewTicker(heartbeatFrequency)
+	intervalTicker := c.clock.NewTicker(heartbeatFrequency)
 	defer intervalTicker.Stop()
 	healthyCnt := 0
 	isHealthy := false
@@ -125,9 +227,10 @@ func (c *checker) healthyCheckLoop(stopCh <-chan struct{}) {
 		case <-stopCh:
 			klog.Infof("exit normally in health check loop for %s", c.netAddress)
 			return
-		case <-intervalTicker.C:
+		case <-intervalTicker.C():
 			for i := 0; i < c.failedRetry; i++ {
-				isHealthy, err = pingClusterHealthz(c.healthzClient, c.serverHealthzAddr)
+				err = c.prober.Probe(c.serverHealthzAddr)
+				isHealthy = err == nil
 				if err != nil {
 					klog.V(2).Infof("ping cluster healthz with result, %v", err)
 					if !c.clusterHealthy {
@@ -149,7 +252,7 @@ func (c *checker) healthyCheckLoop(stopCh <-chan struct{}) {
 					c.Lock()
 					c.clusterHealthy = false
 					c.Unlock()
-					now := time.Now()
+					now := c.clock.Now()
 					klog.Infof("cluster becomes unhealthy from %v, healthy status lasts %v", now, now.Sub(c.lastTime))
 					c.onFailureFunc(c.netAddress)
 					c.lastTime = now
@@ -161,7 +264,7 @@ func (c *checker) healthyCheckLoop(stopCh <-chan struct{}) {
 					c.Lock()
 					c.clusterHealthy = true
 					c.Unlock()
-					now := time.Now()
+					now := c.clock.Now()
 					klog.Infof("cluster becomes healthy from %v, unhealthy status lasts %v", now, now.Sub(c.lastTime))
 					c.lastTime = now
 				}
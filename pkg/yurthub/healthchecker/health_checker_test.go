@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	yurttesting "github.com/alibaba/openyurt/pkg/yurthub/testing"
 	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 func TestHealthyCheckerWithHealthyServers(t *testing.T) {
@@ -41,7 +45,7 @@ func TestHealthyCheckerWithHealthyServers(t *testing.T) {
 
 	stopCh := make(chan struct{})
 	// new transport manager
-	transportManager, err := transport.NewTransportManager(2, stopCh)
+	transportManager, err := transport.NewTransportManager(2, nil, stopCh)
 	if err != nil {
 		t.Fatalf("new transport manager failed, %v", err)
 	}
@@ -102,7 +106,7 @@ func TestHealthyCheckerWithUnhealthyServers(t *testing.T) {
 
 	stopCh := make(chan struct{})
 	// new transport manager
-	transportManager, err := transport.NewTransportManager(2, stopCh)
+	transportManager, err := transport.NewTransportManager(2, nil, stopCh)
 	if err != nil {
 		t.Fatalf("new transport manager failed, %v", err)
 	}
@@ -170,7 +174,7 @@ func TestHealthyCheckerFromHealthyToUnhealthy(t *testing.T) {
 
 	stopCh := make(chan struct{})
 	// new transport manager
-	transportManager, err := transport.NewTransportManager(2, stopCh)
+	transportManager, err := transport.NewTransportManager(2, nil, stopCh)
 	if err != nil {
 		t.Fatalf("new transport manager failed, %v", err)
 	}
@@ -250,7 +254,7 @@ func TestHealthyCheckerFromUnHealthyToHealthy(t *testing.T) {
 
 	stopCh := make(chan struct{})
 	// new transport manager
-	transportManager, err := transport.NewTransportManager(2, stopCh)
+	transportManager, err := transport.NewTransportManager(2, nil, stopCh)
 	if err != nil {
 		t.Fatalf("new transport manager failed, %v", err)
 	}
@@ -294,3 +298,112 @@ func TestHealthyCheckerFromUnHealthyToHealthy(t *testing.T) {
 	// wait some for goroutine exit
 	time.Sleep(time.Second)
 }
+
+func TestHealthCheckerWithFakeNetwork(t *testing.T) {
+	network := yurttesting.NewFakeNetwork()
+	clk := yurttesting.NewFakeClock(time.Now())
+	server, _ := url.Parse("https://10.0.0.1:6443")
+	var failures int32
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	hc, err := NewHealthCheckerWith([]*url.URL{server}, network, clk, func(string) { atomic.AddInt32(&failures, 1) }, 3, 2, stopCh)
+	if err != nil {
+		t.Fatalf("failed to new health checker, %v", err)
+	}
+	if !hc.IsHealthy(server) {
+		t.Fatalf("expect server is healthy at start")
+	}
+
+	// heartbeat steps the clock, and waits for the probes of the heartbeat
+	heartbeat := func(probes int) {
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return clk.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("health check loop is not started")
+		}
+		before := network.Probes(server.Host)
+		clk.Step(heartbeatFrequency)
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return network.Probes(server.Host) == before+probes, nil
+		}); err != nil {
+			t.Fatalf("expect %d probes in a heartbeat, but got %d", probes, network.Probes(server.Host)-before)
+		}
+	}
+	expectHealthy := func(desc string, healthy bool) {
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return hc.IsHealthy(server) == healthy, nil
+		}); err != nil {
+			t.Fatalf("%s: expect healthy %v", desc, healthy)
+		}
+	}
+
+	// healthy server is probed once
+	heartbeat(1)
+	expectHealthy("connected", true)
+
+	// disconnected server is retried before it's unhealthy
+	network.Disconnect(server.Host)
+	heartbeat(3)
+	expectHealthy("disconnected", false)
+	if n := atomic.LoadInt32(&failures); n != 1 {
+		t.Errorf("expect onFailure is called once, but got %d", n)
+	}
+
+	// reconnected server is healthy after healthyThreshold heartbeats
+	network.Connect()
+	heartbeat(3)
+	if hc.IsHealthy(server) {
+		t.Errorf("expect server is unhealthy before healthy threshold")
+	}
+	heartbeat(3)
+	expectHealthy("reconnected", true)
+}
+
+func TestUpdateServers(t *testing.T) {
+	network := yurttesting.NewFakeNetwork()
+	clk := yurttesting.NewFakeClock(time.Now())
+	server1, _ := url.Parse("https://10.0.0.1:6443")
+	server2, _ := url.Parse("https://10.0.0.2:6443")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	hc, err := NewHealthCheckerWith([]*url.URL{server1}, network, clk, func(string) {}, 3, 2, stopCh)
+	if err != nil {
+		t.Fatalf("failed to new health checker, %v", err)
+	}
+	dhc, ok := hc.(DynamicHealthChecker)
+	if !ok {
+		t.Fatalf("expect health checker is dynamic")
+	}
+	if dhc.IsHealthy(server2) {
+		t.Errorf("expect unknown server is not healthy")
+	}
+
+	dhc.UpdateServers([]*url.URL{server2})
+	if !dhc.IsHealthy(server2) {
+		t.Errorf("expect added server is healthy")
+	}
+	if dhc.IsHealthy(server1) {
+		t.Errorf("expect removed server is not healthy")
+	}
+
+	// the checking of removed server is stopped
+	before := network.Probes(server1.Host)
+	for i := 0; i < 3; i++ {
+		if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+			return clk.HasWaiters(), nil
+		}); err != nil {
+			t.Fatalf("health check loop is not started")
+		}
+		clk.Step(heartbeatFrequency)
+	}
+	if err := wait.PollImmediate(time.Millisecond, time.Second, func() (bool, error) {
+		return network.Probes(server2.Host) > 0, nil
+	}); err != nil {
+		t.Errorf("expect added server is probed")
+	}
+	if n := network.Probes(server1.Host); n != before {
+		t.Errorf("expect removed server is not probed, but got %d probes", n-before)
+	}
+}
@@ -0,0 +1,241 @@
+package hubconfig
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Namespace is the namespace of configmaps that hold yurthub settings
+	Namespace = "kube-system"
+	// configMapNamePrefix is the name of configmap that holds yurthub settings
+	// for nodes which don't belong to any node pool
+	configMapNamePrefix = "yurt-hub-cfg"
+
+	// CacheAgentsKey is the key of cache agents in configmap, the value is like "agent1,agent2"
+	CacheAgentsKey = "cache-agents"
+	// AggregatedAPICacheGroupsKey is the key of aggregated api groups that responses can be cached,
+	// the value is like "metrics.k8s.io,custom.metrics.k8s.io"
+	AggregatedAPICacheGroupsKey = "aggregated-api-cache-groups"
+	// MaxRequestsInFlightKey is the key of the maximum number of parallel requests
+	MaxRequestsInFlightKey = "max-requests-in-flight"
+
+	// CanaryPercentKey is the key of percent of nodes that apply the canary settings,
+	// the canary settings use the same keys as settings with prefix "canary."
+	CanaryPercentKey = "canary-percent"
+	// CanaryRevisionKey is the key of revision of the canary settings
+	CanaryRevisionKey = "canary-revision"
+	// CanaryMaxErrorPercentKey is the key of the maximum percent of failed requests,
+	// the canary settings are rolled back on the node when the percent is exceeded.
+	CanaryMaxErrorPercentKey = "canary-max-error-percent"
+	// CanaryFailedAnnotation is the annotation on node that records the canary revision
+	// which is rolled back on the node, the rollout is halted by yurthubconfig controller
+	// when the canary revision is failed on nodes.
+	CanaryFailedAnnotation = "openyurt.io/hub-config-canary-failed"
+
+	// MaintenanceKey is the key of reason of planned maintenance(like a WAN outage) of the node pool,
+	// hubs pre-refresh caches aggressively and the controller suppresses evictions while it's set.
+	MaintenanceKey = "maintenance"
+	// MaintenanceUntilKey is the key of time in RFC3339 that the maintenance ends,
+	// the maintenance doesn't end by itself when it's not set.
+	MaintenanceUntilKey = "maintenance-until"
+
+	canaryKeyPrefix              = "canary."
+	defaultCanaryMaxErrorPercent = 50
+	sepForList                   = ","
+)
+
+// Settings is the yurthub settings that can be projected from cloud
+type Settings struct {
+	CacheAgents              []string
+	AggregatedAPICacheGroups []string
+	// MaxRequestsInFlight is not changed when it is 0
+	MaxRequestsInFlight int
+}
+
+// Canary is the yurthub settings that are applied to a percent of nodes
+type Canary struct {
+	Settings
+	Percent         int
+	Revision        string
+	MaxErrorPercent int
+}
+
+// Maintenance is the planned maintenance of node pool
+type Maintenance struct {
+	Reason string
+	// Until is zero when the maintenance doesn't end by itself
+	Until time.Time
+}
+
+// ConfigMapName returns the name of configmap that holds yurthub settings for the node pool
+func ConfigMapName(pool string) string {
+	if len(pool) == 0 {
+		return configMapNamePrefix
+	}
+
+	return fmt.Sprintf("%s-%s", configMapNamePrefix, pool)
+}
+
+// ParseSettings parses the yurthub settings from data of configmap
+func ParseSettings(data map[string]string) (*Settings, error) {
+	return parseSettings(data, "")
+}
+
+// Data returns the data of configmap for the yurthub settings
+func (s *Settings) Data() map[string]string {
+	data := make(map[string]string)
+	s.addData(data, "")
+	return data
+}
+
+// ParseCanary parses the canary settings from data of configmap,
+// nil is returned when no canary is set.
+func ParseCanary(data map[string]string) (*Canary, error) {
+	percent, err := parseInt(data, CanaryPercentKey, 100)
+	if err != nil || percent == 0 {
+		return nil, err
+	}
+
+	maxErrorPercent, err := parseInt(data, CanaryMaxErrorPercentKey, 100)
+	if err != nil {
+		return nil, err
+	} else if maxErrorPercent == 0 {
+		maxErrorPercent = defaultCanaryMaxErrorPercent
+	}
+
+	s, err := parseSettings(data, canaryKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Canary{
+		Settings:        *s,
+		Percent:         percent,
+		Revision:        data[CanaryRevisionKey],
+		MaxErrorPercent: maxErrorPercent,
+	}, nil
+}
+
+// Data returns the data of configmap for the canary settings
+func (c *Canary) Data() map[string]string {
+	data := make(map[string]string)
+	c.addData(data, canaryKeyPrefix)
+	data[CanaryPercentKey] = strconv.Itoa(c.Percent)
+	data[CanaryRevisionKey] = c.Revision
+	if c.MaxErrorPercent != 0 {
+		data[CanaryMaxErrorPercentKey] = strconv.Itoa(c.MaxErrorPercent)
+	}
+
+	return data
+}
+
+// ParseMaintenance parses the maintenance from data of configmap,
+// nil is returned when no maintenance is set.
+func ParseMaintenance(data map[string]string) (*Maintenance, error) {
+	reason := strings.TrimSpace(data[MaintenanceKey])
+	if len(reason) == 0 {
+		return nil, nil
+	}
+
+	m := &Maintenance{Reason: reason}
+	if v := strings.TrimSpace(data[MaintenanceUntilKey]); len(v) != 0 {
+		until, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s(%s) is invalid, it should be a time in RFC3339", MaintenanceUntilKey, v)
+		}
+		m.Until = until
+	}
+
+	return m, nil
+}
+
+// Data returns the data of configmap for the maintenance
+func (m *Maintenance) Data() map[string]string {
+	data := map[string]string{MaintenanceKey: m.Reason}
+	if !m.Until.IsZero() {
+		data[MaintenanceUntilKey] = m.Until.UTC().Format(time.RFC3339)
+	}
+
+	return data
+}
+
+// Active checks the maintenance is in effect at now
+func (m *Maintenance) Active(now time.Time) bool {
+	return m != nil && (m.Until.IsZero() || now.Before(m.Until))
+}
+
+// InCanary checks the node applies the canary settings or not, nodes are
+// selected by hash of node name, so the nodes selected by a smaller percent
+// are always selected when the percent is increased.
+func InCanary(nodeName string, percent int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(nodeName))
+	return int(h.Sum32()%100) < percent
+}
+
+func parseSettings(data map[string]string, prefix string) (*Settings, error) {
+	maxRequestsInFlight, err := parseInt(data, prefix+MaxRequestsInFlightKey, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Settings{
+		CacheAgents:              splitList(data[prefix+CacheAgentsKey]),
+		AggregatedAPICacheGroups: splitList(data[prefix+AggregatedAPICacheGroupsKey]),
+		MaxRequestsInFlight:      maxRequestsInFlight,
+	}, nil
+}
+
+func (s *Settings) addData(data map[string]string, prefix string) {
+	if len(s.CacheAgents) != 0 {
+		data[prefix+CacheAgentsKey] = joinList(s.CacheAgents)
+	}
+
+	if len(s.AggregatedAPICacheGroups) != 0 {
+		data[prefix+AggregatedAPICacheGroupsKey] = joinList(s.AggregatedAPICacheGroups)
+	}
+
+	if s.MaxRequestsInFlight != 0 {
+		data[prefix+MaxRequestsInFlightKey] = strconv.Itoa(s.MaxRequestsInFlight)
+	}
+}
+
+// parseInt parses the non-negative integer of key in data, max < 0 means no upper limit
+func parseInt(data map[string]string, key string, max int) (int, error) {
+	v := strings.TrimSpace(data[key])
+	if len(v) == 0 {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s(%s) is invalid, it should be a non-negative integer", key, v)
+	} else if max >= 0 && n > max {
+		return 0, fmt.Errorf("%s(%s) is invalid, it should not be greater than %d", key, v, max)
+	}
+
+	return n, nil
+}
+
+func splitList(v string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(v, sepForList) {
+		if item = strings.TrimSpace(item); len(item) != 0 {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// joinList joins the items in order, so the data of configmap is stable
+func joinList(items []string) string {
+	sorted := append([]string{}, items...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, sepForList)
+}
@@ -0,0 +1,426 @@
+package hubconfig
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseSettings(t *testing.T) {
+	testcases := map[string]struct {
+		data      map[string]string
+		expectErr bool
+		settings  *Settings
+	}{
+		"empty data": {
+			data: map[string]string{},
+			settings: &Settings{
+				CacheAgents:              []string{},
+				AggregatedAPICacheGroups: []string{},
+			},
+		},
+		"all settings": {
+			data: map[string]string{
+				CacheAgentsKey:              "foo, bar",
+				AggregatedAPICacheGroupsKey: "metrics.k8s.io",
+				MaxRequestsInFlightKey:      "100",
+			},
+			settings: &Settings{
+				CacheAgents:              []string{"foo", "bar"},
+				AggregatedAPICacheGroups: []string{"metrics.k8s.io"},
+				MaxRequestsInFlight:      100,
+			},
+		},
+		"invalid max requests in flight": {
+			data: map[string]string{
+				MaxRequestsInFlightKey: "-1",
+			},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			s, err := ParseSettings(tt.data)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("failed to parse settings, %v", err)
+			}
+
+			if !reflect.DeepEqual(s, tt.settings) {
+				t.Errorf("expect settings %#v, but got %#v", tt.settings, s)
+			}
+		})
+	}
+}
+
+func TestSettingsData(t *testing.T) {
+	s := &Settings{
+		CacheAgents:         []string{"foo", "bar"},
+		MaxRequestsInFlight: 100,
+	}
+
+	expected := map[string]string{
+		CacheAgentsKey:         "bar,foo",
+		MaxRequestsInFlightKey: "100",
+	}
+	if data := s.Data(); !reflect.DeepEqual(data, expected) {
+		t.Errorf("expect data %v, but got %v", expected, data)
+	}
+}
+
+func TestConfigMapName(t *testing.T) {
+	if name := ConfigMapName(""); name != "yurt-hub-cfg" {
+		t.Errorf("expect configmap name yurt-hub-cfg, but got %s", name)
+	}
+
+	if name := ConfigMapName("hangzhou"); name != "yurt-hub-cfg-hangzhou" {
+		t.Errorf("expect configmap name yurt-hub-cfg-hangzhou, but got %s", name)
+	}
+}
+
+func TestApply(t *testing.T) {
+	cacheMgr, err := cachemanager.NewCacheManager(cachemanager.NewFakeStorageWrapper(), nil, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to new cache manager, %v", err)
+	}
+	limiter := proxyutil.NewRequestLimiter(250)
+	m := &Manager{
+		defaults: &Settings{
+			MaxRequestsInFlight: 250,
+		},
+		cacheMgr: cacheMgr,
+		limiter:  limiter,
+	}
+
+	m.apply(&Settings{
+		CacheAgents:         []string{"foo"},
+		MaxRequestsInFlight: 100,
+	})
+	if limiter.Limit() != 100 {
+		t.Errorf("expect limit 100, but got %d", limiter.Limit())
+	}
+
+	found := false
+	for _, agent := range cacheMgr.ListCacheAgents() {
+		if agent == "foo" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expect cache agent foo is added, but got %v", cacheMgr.ListCacheAgents())
+	}
+
+	m.apply(&Settings{})
+	if limiter.Limit() != 250 {
+		t.Errorf("expect limit is restored to 250, but got %d", limiter.Limit())
+	}
+}
+
+func TestParseCanary(t *testing.T) {
+	testcases := map[string]struct {
+		data      map[string]string
+		expectErr bool
+		canary    *Canary
+	}{
+		"no canary": {
+			data: map[string]string{
+				CacheAgentsKey: "foo",
+			},
+		},
+		"canary with default max error percent": {
+			data: map[string]string{
+				CacheAgentsKey:                   "foo",
+				canaryKeyPrefix + CacheAgentsKey: "bar",
+				CanaryPercentKey:                 "10",
+				CanaryRevisionKey:                "abc",
+			},
+			canary: &Canary{
+				Settings: Settings{
+					CacheAgents:              []string{"bar"},
+					AggregatedAPICacheGroups: []string{},
+				},
+				Percent:         10,
+				Revision:        "abc",
+				MaxErrorPercent: defaultCanaryMaxErrorPercent,
+			},
+		},
+		"invalid canary percent": {
+			data: map[string]string{
+				CanaryPercentKey: "101",
+			},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c, err := ParseCanary(tt.data)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("failed to parse canary, %v", err)
+			}
+
+			if !reflect.DeepEqual(c, tt.canary) {
+				t.Errorf("expect canary %#v, but got %#v", tt.canary, c)
+			}
+		})
+	}
+}
+
+func TestParseMaintenance(t *testing.T) {
+	until := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		data        map[string]string
+		expectErr   bool
+		maintenance *Maintenance
+	}{
+		"no maintenance": {
+			data: map[string]string{
+				MaintenanceUntilKey: "2020-10-01T12:00:00Z",
+			},
+		},
+		"maintenance without until": {
+			data: map[string]string{
+				MaintenanceKey: "wan upgrade",
+			},
+			maintenance: &Maintenance{Reason: "wan upgrade"},
+		},
+		"maintenance with until": {
+			data: map[string]string{
+				MaintenanceKey:      "wan upgrade",
+				MaintenanceUntilKey: "2020-10-01T12:00:00Z",
+			},
+			maintenance: &Maintenance{Reason: "wan upgrade", Until: until},
+		},
+		"invalid until": {
+			data: map[string]string{
+				MaintenanceKey:      "wan upgrade",
+				MaintenanceUntilKey: "tomorrow",
+			},
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			m, err := ParseMaintenance(tt.data)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("failed to parse maintenance, %v", err)
+			}
+
+			if !reflect.DeepEqual(m, tt.maintenance) {
+				t.Errorf("expect maintenance %#v, but got %#v", tt.maintenance, m)
+			}
+
+			if m != nil {
+				if parsed, _ := ParseMaintenance(m.Data()); !reflect.DeepEqual(parsed, m) {
+					t.Errorf("expect maintenance %#v from data, but got %#v", m, parsed)
+				}
+			}
+		})
+	}
+}
+
+func TestMaintenanceActive(t *testing.T) {
+	now := time.Date(2020, 10, 1, 12, 0, 0, 0, time.UTC)
+	var none *Maintenance
+	if none.Active(now) {
+		t.Errorf("expect nil maintenance is not active")
+	}
+	if !(&Maintenance{Reason: "wan"}).Active(now) {
+		t.Errorf("expect maintenance without until is active")
+	}
+	if !(&Maintenance{Reason: "wan", Until: now.Add(time.Minute)}).Active(now) {
+		t.Errorf("expect maintenance before until is active")
+	}
+	if (&Maintenance{Reason: "wan", Until: now}).Active(now) {
+		t.Errorf("expect maintenance after until is not active")
+	}
+}
+
+func TestInCanary(t *testing.T) {
+	if InCanary("foo", 0) {
+		t.Errorf("expect node is not selected by 0 percent")
+	}
+
+	if !InCanary("foo", 100) {
+		t.Errorf("expect node is selected by 100 percent")
+	}
+
+	for percent := 1; percent < 100; percent++ {
+		if InCanary("foo", percent) && !InCanary("foo", percent+1) {
+			t.Errorf("expect node selected by %d percent is selected by %d percent", percent, percent+1)
+		}
+	}
+}
+
+func TestCheckCanary(t *testing.T) {
+	cacheMgr, err := cachemanager.NewCacheManager(cachemanager.NewFakeStorageWrapper(), nil, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to new cache manager, %v", err)
+	}
+	kubeClient := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "foo"}})
+	limiter := proxyutil.NewRequestLimiter(250)
+	m := &Manager{
+		nodeName: "foo",
+		defaults: &Settings{
+			MaxRequestsInFlight: 250,
+		},
+		stable:     &Settings{},
+		cacheMgr:   cacheMgr,
+		limiter:    limiter,
+		kubeClient: kubeClient,
+	}
+
+	m.addConfigMap(&v1.ConfigMap{
+		Data: map[string]string{
+			canaryKeyPrefix + MaxRequestsInFlightKey: "1",
+			CanaryPercentKey:                         "100",
+			CanaryRevisionKey:                        "abc",
+		},
+	})
+	if limiter.Limit() != 1 {
+		t.Errorf("expect canary limit 1 is applied, but got %d", limiter.Limit())
+	}
+
+	for i := 0; i < minCanaryRequests; i++ {
+		m.stats.record(http.StatusTooManyRequests)
+	}
+	m.checkCanary()
+	if limiter.Limit() != 250 {
+		t.Errorf("expect canary is rolled back to limit 250, but got %d", limiter.Limit())
+	}
+
+	err = wait.Poll(100*time.Millisecond, 5*time.Second, func() (bool, error) {
+		node, err := kubeClient.CoreV1().Nodes().Get("foo", metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return node.Annotations[CanaryFailedAnnotation] == "abc", nil
+	})
+	if err != nil {
+		t.Errorf("expect canary failure is reported, %v", err)
+	}
+
+	// failed revision is not applied again
+	m.addConfigMap(&v1.ConfigMap{
+		Data: map[string]string{
+			canaryKeyPrefix + MaxRequestsInFlightKey: "1",
+			CanaryPercentKey:                         "100",
+			CanaryRevisionKey:                        "abc",
+		},
+	})
+	if limiter.Limit() != 250 {
+		t.Errorf("expect failed canary is not applied again, but got limit %d", limiter.Limit())
+	}
+}
+
+func TestLoadFailedRevision(t *testing.T) {
+	cacheMgr, err := cachemanager.NewCacheManager(cachemanager.NewFakeStorageWrapper(), nil, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to new cache manager, %v", err)
+	}
+	kubeClient := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "foo",
+		Annotations: map[string]string{CanaryFailedAnnotation: "abc"},
+	}})
+	limiter := proxyutil.NewRequestLimiter(250)
+	m := &Manager{
+		nodeName: "foo",
+		defaults: &Settings{
+			MaxRequestsInFlight: 250,
+		},
+		stable:   &Settings{},
+		cacheMgr: cacheMgr,
+		limiter:  limiter,
+	}
+
+	// yurthub is restarted after the canary revision is failed on the node
+	if err := m.loadFailedRevision(kubeClient); err != nil {
+		t.Fatalf("failed to load failed revision, %v", err)
+	}
+	canary := func(revision string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			Data: map[string]string{
+				canaryKeyPrefix + MaxRequestsInFlightKey: "1",
+				CanaryPercentKey:                         "100",
+				CanaryRevisionKey:                        revision,
+			},
+		}
+	}
+	m.addConfigMap(canary("abc"))
+	if limiter.Limit() != 250 {
+		t.Errorf("expect failed canary is not applied after restart, but got limit %d", limiter.Limit())
+	}
+
+	// a new canary revision is applied
+	m.addConfigMap(canary("def"))
+	if limiter.Limit() != 1 {
+		t.Errorf("expect new canary revision is applied, but got limit %d", limiter.Limit())
+	}
+}
+
+func TestOnMaintenance(t *testing.T) {
+	cacheMgr, err := cachemanager.NewCacheManager(cachemanager.NewFakeStorageWrapper(), nil, nil, 0)
+	if err != nil {
+		t.Fatalf("failed to new cache manager, %v", err)
+	}
+	m := &Manager{
+		nodeName: "foo",
+		defaults: &Settings{},
+		stable:   &Settings{},
+		cacheMgr: cacheMgr,
+		limiter:  proxyutil.NewRequestLimiter(250),
+	}
+
+	notified := make([]*Maintenance, 0)
+	m.OnMaintenance(func(maintenance *Maintenance) {
+		notified = append(notified, maintenance)
+	})
+
+	cm := &v1.ConfigMap{
+		Data: map[string]string{
+			MaintenanceKey: "wan upgrade",
+		},
+	}
+	m.addConfigMap(cm)
+	// handlers are not called when the maintenance is not changed
+	m.addConfigMap(cm)
+	if len(notified) != 1 || notified[0] == nil || notified[0].Reason != "wan upgrade" {
+		t.Fatalf("expect maintenance is notified once, but got %v", notified)
+	}
+
+	m.deleteConfigMap(cm)
+	if len(notified) != 2 || notified[1] != nil {
+		t.Errorf("expect removed maintenance is notified, but got %v", notified)
+	}
+}
@@ -0,0 +1,303 @@
+package hubconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	// canaryCheckPeriod is the period to check the requests after canary settings are applied
+	canaryCheckPeriod = 30 * time.Second
+	// minCanaryRequests is the minimum number of requests in a check period for
+	// checking the canary settings, too few requests can not tell the settings are bad.
+	minCanaryRequests = 20
+	// canaryReportRetryPeriod is the period to retry reporting the canary failure to cloud
+	canaryReportRetryPeriod = 10 * time.Second
+)
+
+// Manager watches the configmap of yurthub settings for the node pool
+// and applies the settings to yurthub at runtime. when canary settings
+// are selected for the node, the failed requests are checked periodically,
+// and the canary settings are rolled back on the node if there are too many
+// failed requests, then the failure is reported to cloud by annotation of node.
+// the annotation is read when yurthub starts, so the failed canary revision is
+// not applied again after yurthub restarts.
+// the maintenance of node pool is passed to the handlers registered by OnMaintenance.
+type Manager struct {
+	sync.Mutex
+	nodeName         string
+	configMapName    string
+	defaults         *Settings
+	stable           *Settings
+	canary           *Canary
+	failedRevision   string
+	maintenance      *Maintenance
+	onMaintenance    []func(*Maintenance)
+	stats            requestStats
+	lastTotal        uint64
+	lastFailed       uint64
+	kubeClient       clientset.Interface
+	cacheMgr         cachemanager.CacheManager
+	limiter          *proxyutil.RequestLimiter
+	transportManager transport.Interface
+	stopCh           <-chan struct{}
+}
+
+// NewManager creates a manager for yurthub settings, the settings from command line
+// are restored when the configmap is deleted.
+func NewManager(cfg *config.YurtHubConfiguration,
+	cacheMgr cachemanager.CacheManager,
+	limiter *proxyutil.RequestLimiter,
+	transportManager transport.Interface,
+	stopCh <-chan struct{}) *Manager {
+	return &Manager{
+		nodeName:      cfg.NodeName,
+		configMapName: ConfigMapName(cfg.NodePool),
+		defaults: &Settings{
+			AggregatedAPICacheGroups: cfg.AggregatedAPICacheGroups,
+			MaxRequestsInFlight:      cfg.MaxRequestInFlight,
+		},
+		stable:           &Settings{},
+		cacheMgr:         cacheMgr,
+		limiter:          limiter,
+		transportManager: transportManager,
+		stopCh:           stopCh,
+	}
+}
+
+// Run starts to watch the configmap of yurthub settings
+func (m *Manager) Run() {
+	go func() {
+		var kubeClient clientset.Interface
+		// rest config is not ready until the certificate of yurthub is prepared
+		err := wait.PollUntil(5*time.Second, func() (bool, error) {
+			cfg := m.transportManager.GetRestClientConfig()
+			if cfg == nil {
+				klog.V(4).Infof("rest config is not ready, wait for watching configmap %s/%s", Namespace, m.configMapName)
+				return false, nil
+			}
+
+			if kubeClient == nil {
+				client, err := clientset.NewForConfig(cfg)
+				if err != nil {
+					klog.Errorf("could not new kube client, %v", err)
+					return false, nil
+				}
+				kubeClient = client
+			}
+			// the canary revision that is failed on the node before yurthub restarts is
+			// loaded before the configmap is watched, so it's never applied again.
+			if err := m.loadFailedRevision(kubeClient); err != nil {
+				klog.Errorf("could not get failed canary revision of node %s, %v", m.nodeName, err)
+				return false, nil
+			}
+			return true, nil
+		}, m.stopCh)
+		if err != nil {
+			return
+		}
+		m.kubeClient = kubeClient
+
+		factory := informers.NewSharedInformerFactoryWithOptions(kubeClient, 24*time.Hour,
+			informers.WithNamespace(Namespace),
+			informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+				options.FieldSelector = fields.OneTermEqualSelector("metadata.name", m.configMapName).String()
+			}))
+		factory.Core().V1().ConfigMaps().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: m.addConfigMap,
+			UpdateFunc: func(_, newObj interface{}) {
+				m.addConfigMap(newObj)
+			},
+			DeleteFunc: m.deleteConfigMap,
+		})
+
+		klog.Infof("start to watch yurthub settings in configmap %s/%s", Namespace, m.configMapName)
+		factory.Start(m.stopCh)
+		wait.Until(m.checkCanary, canaryCheckPeriod, m.stopCh)
+	}()
+}
+
+// loadFailedRevision loads the canary revision that is failed on the node from annotation of node
+func (m *Manager) loadFailedRevision(kubeClient clientset.Interface) error {
+	node, err := kubeClient.CoreV1().Nodes().Get(m.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	if revision := node.Annotations[CanaryFailedAnnotation]; len(revision) != 0 {
+		klog.Infof("canary yurthub settings(revision %s) are failed on node %s, they are not applied", revision, m.nodeName)
+		m.failedRevision = revision
+	}
+	return nil
+}
+
+func (m *Manager) addConfigMap(obj interface{}) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		return
+	}
+
+	stable, err := ParseSettings(cm.Data)
+	if err != nil {
+		klog.Errorf("failed to parse yurthub settings in configmap %s/%s, %v", cm.Namespace, cm.Name, err)
+		return
+	}
+
+	canary, err := ParseCanary(cm.Data)
+	if err != nil {
+		klog.Errorf("failed to parse canary yurthub settings in configmap %s/%s, %v", cm.Namespace, cm.Name, err)
+		return
+	}
+
+	maintenance, err := ParseMaintenance(cm.Data)
+	if err != nil {
+		klog.Errorf("failed to parse maintenance in configmap %s/%s, %v", cm.Namespace, cm.Name, err)
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	m.setMaintenance(maintenance)
+	m.stable = stable
+	if canary != nil && canary.Revision != m.failedRevision && InCanary(m.nodeName, canary.Percent) {
+		if m.canary == nil || m.canary.Revision != canary.Revision {
+			klog.Infof("node %s is selected for canary yurthub settings(revision %s)", m.nodeName, canary.Revision)
+			m.lastTotal, m.lastFailed = m.stats.snapshot()
+		}
+		m.canary = canary
+		m.apply(&canary.Settings)
+		return
+	}
+
+	m.canary = nil
+	m.apply(stable)
+}
+
+func (m *Manager) deleteConfigMap(obj interface{}) {
+	klog.Infof("configmap %s/%s is deleted, restore yurthub settings from command line", Namespace, m.configMapName)
+	m.Lock()
+	defer m.Unlock()
+	m.stable = &Settings{}
+	m.canary = nil
+	m.setMaintenance(nil)
+	m.apply(m.stable)
+}
+
+// OnMaintenance registers handler that is called with the maintenance of node pool when
+// it's changed, nil is passed when the maintenance is removed. handler is called right away
+// if the node pool is already in maintenance.
+func (m *Manager) OnMaintenance(handler func(*Maintenance)) {
+	m.Lock()
+	defer m.Unlock()
+	m.onMaintenance = append(m.onMaintenance, handler)
+	if m.maintenance != nil {
+		handler(m.maintenance)
+	}
+}
+
+func (m *Manager) setMaintenance(maintenance *Maintenance) {
+	if reflect.DeepEqual(m.maintenance, maintenance) {
+		return
+	}
+
+	if maintenance != nil {
+		klog.Infof("node pool of node %s is in maintenance(%s) until %v", m.nodeName, maintenance.Reason, maintenance.Until)
+	} else {
+		klog.Infof("maintenance of node pool of node %s is removed", m.nodeName)
+	}
+	m.maintenance = maintenance
+	for _, handler := range m.onMaintenance {
+		handler(maintenance)
+	}
+}
+
+// checkCanary rolls back the canary settings on the node when the percent of failed
+// requests since last check exceeds the limit of canary.
+func (m *Manager) checkCanary() {
+	m.Lock()
+	defer m.Unlock()
+	total, failed := m.stats.snapshot()
+	deltaTotal, deltaFailed := total-m.lastTotal, failed-m.lastFailed
+	if m.canary == nil || deltaTotal < minCanaryRequests {
+		// too few requests, go on checking with requests of next period
+		if m.canary == nil {
+			m.lastTotal, m.lastFailed = total, failed
+		}
+		return
+	}
+	m.lastTotal, m.lastFailed = total, failed
+
+	if deltaFailed*100 <= deltaTotal*uint64(m.canary.MaxErrorPercent) {
+		return
+	}
+
+	klog.Errorf("%d of %d requests failed with canary yurthub settings(revision %s), roll back to stable settings",
+		deltaFailed, deltaTotal, m.canary.Revision)
+	m.failedRevision = m.canary.Revision
+	m.canary = nil
+	m.apply(m.stable)
+	go m.reportCanaryFailure(m.failedRevision)
+}
+
+// reportCanaryFailure records the failed canary revision in annotation of node, it's retried
+// until it's recorded, because the rollout is halted and the failed revision is kept across
+// restarts of yurthub by the annotation.
+func (m *Manager) reportCanaryFailure(revision string) {
+	if m.kubeClient == nil {
+		return
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, CanaryFailedAnnotation, revision)
+	wait.PollImmediateUntil(canaryReportRetryPeriod, func() (bool, error) {
+		if _, err := m.kubeClient.CoreV1().Nodes().Patch(m.nodeName, types.StrategicMergePatchType, []byte(patch)); err != nil {
+			klog.Errorf("failed to report canary failure of revision %s for node %s, %v", revision, m.nodeName, err)
+			return false, nil
+		}
+		return true, nil
+	}, m.stopCh)
+}
+
+// apply applies the settings to yurthub, the settings that are not set fall back to
+// the settings from command line, except cache agents which are kept as they are,
+// because cache agents are persisted by cache manager.
+func (m *Manager) apply(s *Settings) {
+	if len(s.CacheAgents) != 0 {
+		if err := m.cacheMgr.UpdateCacheAgents(s.CacheAgents); err != nil {
+			klog.Errorf("failed to update cache agents to %v, %v", s.CacheAgents, err)
+		}
+	}
+
+	aggregatedAPICacheGroups := s.AggregatedAPICacheGroups
+	if len(aggregatedAPICacheGroups) == 0 {
+		aggregatedAPICacheGroups = m.defaults.AggregatedAPICacheGroups
+	}
+	m.cacheMgr.UpdateAggregatedAPIGroups(aggregatedAPICacheGroups)
+
+	maxRequestsInFlight := s.MaxRequestsInFlight
+	if maxRequestsInFlight == 0 {
+		maxRequestsInFlight = m.defaults.MaxRequestsInFlight
+	}
+	m.limiter.SetLimit(maxRequestsInFlight)
+
+	klog.Infof("yurthub settings are applied, cache agents: %v, aggregated api cache groups: %v, max requests in flight: %d",
+		s.CacheAgents, aggregatedAPICacheGroups, maxRequestsInFlight)
+}
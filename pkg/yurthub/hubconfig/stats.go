@@ -0,0 +1,62 @@
+package hubconfig
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"k8s.io/klog"
+)
+
+// requestStats counts the requests served by yurthub, it's used for
+// checking the canary settings break the requests of node or not.
+type requestStats struct {
+	total  uint64
+	failed uint64
+}
+
+func (rs *requestStats) record(statusCode int) {
+	atomic.AddUint64(&rs.total, 1)
+	// 429 is counted, because a bad max-requests-in-flight rejects requests of node
+	if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests {
+		atomic.AddUint64(&rs.failed, 1)
+	}
+}
+
+func (rs *requestStats) snapshot() (uint64, uint64) {
+	return atomic.LoadUint64(&rs.total), atomic.LoadUint64(&rs.failed)
+}
+
+// WithRequestStats counts the status of responses for checking the canary settings
+func (m *Manager) WithRequestStats(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		handler.ServeHTTP(sw, req)
+		m.stats.record(sw.statusCode)
+	})
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusResponseWriter) WriteHeader(statusCode int) {
+	sw.statusCode = statusCode
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (sw *statusResponseWriter) CloseNotify() <-chan bool {
+	if cn, ok := sw.ResponseWriter.(http.CloseNotifier); ok {
+		return cn.CloseNotify()
+	}
+	klog.Errorf("can't get http.CloseNotifier from http.ResponseWriter")
+	return make(chan bool)
+}
+
+func (sw *statusResponseWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	} else {
+		klog.Errorf("can't get http.Flusher from http.ResponseWriter")
+	}
+}
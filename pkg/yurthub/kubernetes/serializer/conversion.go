@@ -0,0 +1,91 @@
+package serializer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+	appsinstall "k8s.io/kubernetes/pkg/apis/apps/install"
+	batchinstall "k8s.io/kubernetes/pkg/apis/batch/install"
+	coordinationinstall "k8s.io/kubernetes/pkg/apis/coordination/install"
+	coreinstall "k8s.io/kubernetes/pkg/apis/core/install"
+	eventsinstall "k8s.io/kubernetes/pkg/apis/events/install"
+	extensionsinstall "k8s.io/kubernetes/pkg/apis/extensions/install"
+	networkinginstall "k8s.io/kubernetes/pkg/apis/networking/install"
+	policyinstall "k8s.io/kubernetes/pkg/apis/policy/install"
+	rbacinstall "k8s.io/kubernetes/pkg/apis/rbac/install"
+	schedulinginstall "k8s.io/kubernetes/pkg/apis/scheduling/install"
+	storageinstall "k8s.io/kubernetes/pkg/apis/storage/install"
+)
+
+// conversionScheme has the internal versions and conversion functions of the groups that
+// have deprecated versions, objects of these groups are converted between versions(and
+// groups, like deployments from extensions/v1beta1 to apps/v1) through the internal version.
+var conversionScheme = runtime.NewScheme()
+
+func init() {
+	coreinstall.Install(conversionScheme)
+	appsinstall.Install(conversionScheme)
+	batchinstall.Install(conversionScheme)
+	coordinationinstall.Install(conversionScheme)
+	eventsinstall.Install(conversionScheme)
+	extensionsinstall.Install(conversionScheme)
+	networkinginstall.Install(conversionScheme)
+	policyinstall.Install(conversionScheme)
+	rbacinstall.Install(conversionScheme)
+	schedulinginstall.Install(conversionScheme)
+	storageinstall.Install(conversionScheme)
+}
+
+// ConvertToVersion converts obj to the group version gv, so objects cached in deprecated versions
+// (like by older components, or before apiserver is upgraded) can be served for the version that
+// clients request. obj is returned as is if it's in gv or its group version is unknown.
+func ConvertToVersion(obj runtime.Object, gv schema.GroupVersion) (runtime.Object, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if len(gvk.Version) == 0 || gvk.GroupVersion() == gv {
+		return obj, nil
+	}
+
+	target := gv.WithKind(gvk.Kind)
+	if !scheme.Scheme.Recognizes(target) {
+		return nil, fmt.Errorf("can not convert %v to %v, target is not registered", gvk, target)
+	}
+	out, err := convertByInternalVersion(obj, gv)
+	if err == nil {
+		return out, nil
+	}
+	klog.V(4).Infof("failed to convert %v to %v by internal version, %v", gvk, target, err)
+
+	// for the groups that are not installed in conversionScheme, convert by the default conversion
+	// of scheme or by json, it works for the versions that share the same schema, and the fields
+	// unknown to the target version are dropped.
+	if out, err := scheme.Scheme.ConvertToVersion(obj, gv); err == nil {
+		return out, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %v to unstructured, %v", gvk, err)
+	}
+	content["apiVersion"] = gv.String()
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %v, %v", gvk, err)
+	}
+	out, _, err = jsonSerializer.Decode(b, &target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert %v to %v, %v", gvk, target, err)
+	}
+	return out, nil
+}
+
+// convertByInternalVersion converts obj to gv by the conversion functions in conversionScheme
+func convertByInternalVersion(obj runtime.Object, gv schema.GroupVersion) (runtime.Object, error) {
+	internal, err := conversionScheme.ConvertToVersion(obj, runtime.InternalGroupVersioner)
+	if err != nil {
+		return nil, err
+	}
+	return conversionScheme.ConvertToVersion(internal, gv)
+}
@@ -0,0 +1,98 @@
+package serializer
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta1 "k8s.io/api/apps/v1beta1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestConvertToVersion(t *testing.T) {
+	holder := "node1"
+	lease := &coordinationv1beta1.Lease{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "kube-node-lease", ResourceVersion: "10"},
+		Spec:       coordinationv1beta1.LeaseSpec{HolderIdentity: &holder},
+	}
+
+	out, err := ConvertToVersion(lease, coordinationv1.SchemeGroupVersion)
+	if err != nil {
+		t.Fatalf("failed to convert lease, %v", err)
+	}
+	converted, ok := out.(*coordinationv1.Lease)
+	if !ok {
+		t.Fatalf("expect *v1.Lease, but got %T", out)
+	}
+	if converted.APIVersion != "coordination.k8s.io/v1" || converted.ResourceVersion != "10" ||
+		converted.Spec.HolderIdentity == nil || *converted.Spec.HolderIdentity != holder {
+		t.Errorf("lease is not converted correctly, %#v", converted)
+	}
+	if lease.APIVersion != "coordination.k8s.io/v1beta1" {
+		t.Errorf("expect original lease is not changed, but got %s", lease.APIVersion)
+	}
+
+	if out, err := ConvertToVersion(lease, coordinationv1beta1.SchemeGroupVersion); err != nil || out != runtime.Object(lease) {
+		t.Errorf("expect lease returned as is, but got %v, %v", out, err)
+	}
+
+	if _, err := ConvertToVersion(lease, schema.GroupVersion{Group: "coordination.k8s.io", Version: "v2"}); err == nil {
+		t.Errorf("expect error for unknown version, but got nil")
+	}
+}
+
+func TestConvertDeprecatedVersions(t *testing.T) {
+	replicas := int32(3)
+	labels := map[string]string{"app": "nginx"}
+	deployment := &appsv1beta1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1beta1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "nginx", Namespace: "default"},
+		Spec: appsv1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			RollbackTo: &appsv1beta1.RollbackConfig{
+				Revision: 2,
+			},
+		},
+	}
+	out, err := ConvertToVersion(deployment, appsv1.SchemeGroupVersion)
+	if err != nil {
+		t.Fatalf("failed to convert deployment, %v", err)
+	}
+	d, ok := out.(*appsv1.Deployment)
+	if !ok {
+		t.Fatalf("expect *appsv1.Deployment, but got %T", out)
+	}
+	if d.APIVersion != "apps/v1" || d.Spec.Replicas == nil || *d.Spec.Replicas != replicas {
+		t.Errorf("deployment is not converted correctly, %#v", d)
+	}
+	// rollbackTo is kept in annotation by the conversion functions
+	if _, ok := d.Annotations[appsv1.DeprecatedRollbackTo]; !ok {
+		t.Errorf("expect rollbackTo is converted to annotation, but got %v", d.Annotations)
+	}
+
+	daemonSet := &extensionsv1beta1.DaemonSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "extensions/v1beta1", Kind: "DaemonSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-proxy", Namespace: "kube-system"},
+		Spec: extensionsv1beta1.DaemonSetSpec{
+			Selector:           &metav1.LabelSelector{MatchLabels: labels},
+			TemplateGeneration: 3,
+		},
+	}
+	out, err = ConvertToVersion(daemonSet, appsv1.SchemeGroupVersion)
+	if err != nil {
+		t.Fatalf("failed to convert daemonset, %v", err)
+	}
+	ds, ok := out.(*appsv1.DaemonSet)
+	if !ok {
+		t.Fatalf("expect *appsv1.DaemonSet, but got %T", out)
+	}
+	if ds.APIVersion != "apps/v1" || ds.Annotations[appsv1.DeprecatedTemplateGeneration] != "3" {
+		t.Errorf("daemonset is not converted correctly, %#v", ds)
+	}
+}
@@ -0,0 +1,188 @@
+package serializer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	jsonserializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+)
+
+const (
+	// StorageFormatJSON encodes cached objects in json, it's the default format
+	StorageFormatJSON = "json"
+	// StorageFormatProtobuf encodes cached objects in protobuf, objects that can not be
+	// encoded in protobuf(like custom resources) are encoded in json.
+	StorageFormatProtobuf = "protobuf"
+	// StorageFormatCBOR is experimental and not registered by default, a serializer of
+	// cbor needs to be registered by RegisterFormat before it's selected.
+	StorageFormatCBOR = "cbor"
+
+	contentTypeProtobuf = "application/vnd.kubernetes.protobuf"
+)
+
+var (
+	protobufPrefix = []byte{0x6b, 0x38, 0x73, 0x00}
+	// cborPrefix is the self-described cbor tag
+	cborPrefix = []byte{0xd9, 0xd9, 0xf7}
+
+	jsonSerializer = jsonserializer.NewSerializer(jsonserializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme, false)
+)
+
+// storageFormat is a registered format of cached objects
+type storageFormat struct {
+	serializer runtime.Serializer
+	recognize  func(data []byte) bool
+}
+
+// StorageCodec encodes cached objects in the format selected for their resources, and
+// decodes cached objects in any registered format, so the cache survives a change of format.
+type StorageCodec struct {
+	sync.RWMutex
+	formats         map[string]*storageFormat
+	defaultFormat   string
+	resourceFormats map[string]string
+}
+
+// NewStorageCodec creates a StorageCodec with json and protobuf registered, and
+// objects of all resources are encoded in json.
+func NewStorageCodec() *StorageCodec {
+	c := &StorageCodec{
+		formats:         make(map[string]*storageFormat),
+		defaultFormat:   StorageFormatJSON,
+		resourceFormats: make(map[string]string),
+	}
+	c.RegisterFormat(StorageFormatJSON, jsonSerializer, isJSON)
+	c.RegisterFormat(StorageFormatProtobuf, protobuf.NewSerializer(scheme.Scheme, scheme.Scheme, contentTypeProtobuf), func(data []byte) bool {
+		return bytes.HasPrefix(data, protobufPrefix)
+	})
+	return c
+}
+
+// RegisterFormat registers a serializer for format, recognize reports whether data is
+// encoded in the format.
+func (c *StorageCodec) RegisterFormat(format string, s runtime.Serializer, recognize func(data []byte) bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.formats[format] = &storageFormat{serializer: s, recognize: recognize}
+}
+
+// SetFormats selects the formats of resources, each item is in the format of "resource=format",
+// or "format" for the resources that are not specified, like "pods=protobuf,json".
+func (c *StorageCodec) SetFormats(items []string) error {
+	c.Lock()
+	defer c.Unlock()
+
+	defaultFormat := StorageFormatJSON
+	resourceFormats := make(map[string]string)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+
+		resource, format := "", item
+		if i := strings.Index(item, "="); i >= 0 {
+			resource, format = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+			if len(resource) == 0 {
+				return fmt.Errorf("resource of cache encoding(%s) is empty", item)
+			}
+		}
+		if _, ok := c.formats[format]; !ok {
+			return fmt.Errorf("cache encoding %s is not supported, supported encodings: %s", format, strings.Join(c.supportedFormats(), ","))
+		}
+
+		if len(resource) == 0 {
+			defaultFormat = format
+		} else {
+			resourceFormats[resource] = format
+		}
+	}
+
+	c.defaultFormat = defaultFormat
+	c.resourceFormats = resourceFormats
+	return nil
+}
+
+// FormatFor returns the format that objects of resource are encoded in
+func (c *StorageCodec) FormatFor(resource string) string {
+	c.RLock()
+	defer c.RUnlock()
+	if format, ok := c.resourceFormats[resource]; ok {
+		return format
+	}
+	return c.defaultFormat
+}
+
+// Encode writes obj of resource into w in the format selected for resource, obj is
+// encoded in json if it can not be encoded in the selected format.
+func (c *StorageCodec) Encode(resource string, obj runtime.Object, w io.Writer) error {
+	format := c.FormatFor(resource)
+	c.RLock()
+	f := c.formats[format]
+	c.RUnlock()
+	if f == nil || format == StorageFormatJSON {
+		return jsonSerializer.Encode(obj, w)
+	}
+
+	var buf bytes.Buffer
+	if err := f.serializer.Encode(obj, &buf); err != nil {
+		klog.V(4).Infof("failed to encode %s in %s, fall back to json, %v", resource, format, err)
+		return jsonSerializer.Encode(obj, w)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Decode decodes data in the registered format that recognizes it, the group version
+// kind of the object is set.
+func (c *StorageCodec) Decode(data []byte) (runtime.Object, *schema.GroupVersionKind, error) {
+	c.RLock()
+	var s runtime.Serializer
+	for _, f := range c.formats {
+		if f.recognize(data) {
+			s = f.serializer
+			break
+		}
+	}
+	c.RUnlock()
+
+	if s == nil {
+		if bytes.HasPrefix(data, cborPrefix) {
+			return nil, nil, fmt.Errorf("data is encoded in %s, which is not registered", StorageFormatCBOR)
+		}
+		return nil, nil, fmt.Errorf("format of data is not recognized")
+	}
+	obj, gvk, err := s.Decode(data, nil, nil)
+	if err != nil {
+		return nil, gvk, err
+	}
+	// type meta is not kept in protobuf, set it for the version conversion on read
+	if gvk != nil {
+		obj.GetObjectKind().SetGroupVersionKind(*gvk)
+	}
+	return obj, gvk, nil
+}
+
+func (c *StorageCodec) supportedFormats() []string {
+	formats := make([]string, 0, len(c.formats))
+	for format := range c.formats {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// isJSON reports whether data looks like a json object
+func isJSON(data []byte) bool {
+	data = bytes.TrimLeft(data, " \t\r\n")
+	return len(data) > 0 && data[0] == '{'
+}
@@ -0,0 +1,126 @@
+package serializer
+
+import (
+	"bytes"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestSetFormats(t *testing.T) {
+	testcases := map[string]struct {
+		items   []string
+		err     bool
+		formats map[string]string
+	}{
+		"default": {
+			formats: map[string]string{"pods": StorageFormatJSON, "nodes": StorageFormatJSON},
+		},
+		"resource and default": {
+			items:   []string{"pods=protobuf", " protobuf ", "nodes = json"},
+			formats: map[string]string{"pods": StorageFormatProtobuf, "nodes": StorageFormatJSON, "leases": StorageFormatProtobuf},
+		},
+		"cbor is not registered": {
+			items: []string{"pods=cbor"},
+			err:   true,
+		},
+		"empty resource": {
+			items: []string{"=json"},
+			err:   true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			c := NewStorageCodec()
+			err := c.SetFormats(tt.items)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("failed to set formats, %v", err)
+			}
+
+			for resource, format := range tt.formats {
+				if got := c.FormatFor(resource); got != format {
+					t.Errorf("expect format %s for %s, but got %s", format, resource, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+	c := NewStorageCodec()
+	if err := c.SetFormats([]string{"pods=protobuf", "leases=protobuf"}); err != nil {
+		t.Fatalf("failed to set formats, %v", err)
+	}
+
+	pod := &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+	}
+	node := &v1.Node{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Node"},
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+	}
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps.openyurt.io/v1alpha1",
+		"kind":       "NodePool",
+		"metadata":   map[string]interface{}{"name": "pool1"},
+	}}
+
+	testcases := map[string]struct {
+		resource string
+		obj      runtime.Object
+		protobuf bool
+	}{
+		"pod in protobuf": {
+			resource: "pods",
+			obj:      pod,
+			protobuf: true,
+		},
+		"node in json": {
+			resource: "nodes",
+			obj:      node,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.Encode(tt.resource, tt.obj, &buf); err != nil {
+				t.Fatalf("failed to encode, %v", err)
+			}
+			if isProtobuf := bytes.HasPrefix(buf.Bytes(), protobufPrefix); isProtobuf != tt.protobuf {
+				t.Errorf("expect encoded in protobuf %v, but got %v", tt.protobuf, isProtobuf)
+			}
+
+			obj, _, err := c.Decode(buf.Bytes())
+			if err != nil {
+				t.Fatalf("failed to decode, %v", err)
+			}
+			if gvk := obj.GetObjectKind().GroupVersionKind(); gvk != tt.obj.GetObjectKind().GroupVersionKind() {
+				t.Errorf("expect %v, but got %v", tt.obj.GetObjectKind().GroupVersionKind(), gvk)
+			}
+		})
+	}
+
+	// objects that can not be encoded in protobuf fall back to json
+	var buf bytes.Buffer
+	if err := c.Encode("pods", crd, &buf); err != nil {
+		t.Fatalf("failed to encode custom resource, %v", err)
+	}
+	if !isJSON(buf.Bytes()) {
+		t.Errorf("expect custom resource encoded in json, but got %q", buf.String())
+	}
+
+	if _, _, err := c.Decode(append([]byte{0xd9, 0xd9, 0xf7}, 0xa0)); err == nil {
+		t.Errorf("expect error for data in cbor, but got nil")
+	}
+}
@@ -0,0 +1,360 @@
+package leasebatch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+)
+
+const (
+	// maxLeaseSize bounds the size of lease updates and responses that are batched,
+	// leases are small, larger ones are passed through.
+	maxLeaseSize = 64 * 1024
+	// maxInFlight bounds the number of lease updates that are sent to kube-apiserver at
+	// the same time, the others are queued.
+	maxInFlight = 4
+	// leaseHeader tells the client whether the update is skipped or coalesced by yurthub
+	leaseHeader = "X-Yurthub-Lease"
+)
+
+// update is a lease update that is sent to kube-apiserver, or coalesced into a newer one
+type update struct {
+	resourceVersion string
+	// start is closed when the update can be sent, or it's coalesced into by
+	start chan struct{}
+	// done is closed when resp is set
+	done chan struct{}
+	by   *update
+	resp *util.ResponseRecorder
+}
+
+// renewal is the lease that kube-apiserver responded to the last successful update
+type renewal struct {
+	lease     runtime.Object
+	mediaType string
+	renewedAt time.Time
+}
+
+// leaseState is the queue of updates of a lease
+type leaseState struct {
+	busy  bool
+	queue []*update
+	last  *renewal
+}
+
+// Batcher queues and de-duplicates the updates of Leases(like node heartbeats of kubelet and the
+// leader election of node agents) that are sent to kube-apiserver through yurthub, so they are
+// cheaper on metered links:
+//   - updates of a lease are sent one at a time, and a queued update is coalesced into a newer one
+//     that is based on the same resourceVersion, both of them get the response of the newer one.
+//   - at most maxInFlight updates of all leases are sent at the same time.
+//   - a renewal that only moves renewTime forward is responded locally if the lease was renewed in
+//     kube-apiserver within minRenewInterval(half of leaseDurationSeconds at most), so the lease
+//     never expires in kube-apiserver because of the skipped renewals.
+type Batcher struct {
+	minRenewInterval time.Duration
+	clock            clock.Clock
+	inFlight         chan struct{}
+	sync.Mutex
+	leases map[string]*leaseState
+}
+
+// NewBatcher creates a Batcher, 0 minRenewInterval disables the local renewals
+func NewBatcher(minRenewInterval time.Duration) *Batcher {
+	return NewBatcherWithClock(minRenewInterval, clock.RealClock{})
+}
+
+// NewBatcherWithClock creates a Batcher that decides the local renewals by clk
+func NewBatcherWithClock(minRenewInterval time.Duration, clk clock.Clock) *Batcher {
+	return &Batcher{
+		minRenewInterval: minRenewInterval,
+		clock:            clk,
+		inFlight:         make(chan struct{}, maxInFlight),
+		leases:           make(map[string]*leaseState),
+	}
+}
+
+// WithLeaseBatching batches the updates of leases that are sent to handler, other requests are
+// passed to handler. a nil Batcher batches nothing.
+func (b *Batcher) WithLeaseBatching(handler http.Handler) http.Handler {
+	if b == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := apirequest.RequestInfoFrom(req.Context())
+		if !ok || !info.IsResourceRequest || info.Verb != "update" || info.APIGroup != coordinationv1.GroupName || info.Resource != "leases" || info.Subresource != "" {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxLeaseSize+1))
+		if err != nil {
+			req.Body.Close()
+			util.Err(apierrors.NewBadRequest(fmt.Sprintf("failed to read lease, %v", err)), w, req)
+			return
+		}
+		if len(body) > maxLeaseSize {
+			req.Body = &util.ReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			handler.ServeHTTP(w, req)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		lease, _, err := scheme.Codecs.UniversalDeserializer().Decode(body, nil, nil)
+		if err != nil {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		resourceVersion, ok := resourceVersionOf(lease)
+		if !ok {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		key := leaseKey(req)
+		if b.renewLocally(key, lease, w) {
+			klog.V(4).Infof("renew %s locally, it's renewed in kube-apiserver recently", util.ReqString(req))
+			return
+		}
+
+		u := b.enqueue(key, resourceVersion)
+		<-u.start
+		if u.by != nil {
+			<-u.by.done
+			u.resp = u.by.resp
+			close(u.done)
+			klog.V(4).Infof("update of %s is coalesced into a newer one", util.ReqString(req))
+			writeResponse(w, u.resp, "coalesced")
+			return
+		}
+
+		rw := util.NewResponseRecorder()
+		func() {
+			b.inFlight <- struct{}{}
+			defer func() {
+				<-b.inFlight
+				b.finish(key, rw)
+				u.resp = rw
+				close(u.done)
+			}()
+			handler.ServeHTTP(rw, req)
+		}()
+		writeResponse(w, rw, "")
+	})
+}
+
+// leaseKey is the key of lease for the request, the credentials of request are included, so the
+// local renewals are not shared between agents with different credentials.
+func leaseKey(req *http.Request) string {
+	h := sha256.New()
+	h.Write([]byte(req.URL.Path + "\n" + req.Header.Get("Authorization")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// enqueue adds the update of lease key based on resourceVersion, the update is started at once
+// if no update of the lease is being sent, otherwise it coalesces the last queued update that is
+// based on the same resourceVersion, or it's queued.
+func (b *Batcher) enqueue(key, resourceVersion string) *update {
+	b.Lock()
+	defer b.Unlock()
+	s, ok := b.leases[key]
+	if !ok {
+		s = &leaseState{}
+		b.leases[key] = s
+	}
+
+	u := &update{resourceVersion: resourceVersion, start: make(chan struct{}), done: make(chan struct{})}
+	if !s.busy {
+		s.busy = true
+		close(u.start)
+		return u
+	}
+	if n := len(s.queue); n > 0 && s.queue[n-1].resourceVersion == resourceVersion {
+		prev := s.queue[n-1]
+		prev.by = u
+		close(prev.start)
+		s.queue[n-1] = u
+		return u
+	}
+	s.queue = append(s.queue, u)
+	return u
+}
+
+// finish records the lease of a successful update of lease key, and starts the next update
+func (b *Batcher) finish(key string, rw *util.ResponseRecorder) {
+	last := b.renewalOf(rw)
+
+	b.Lock()
+	defer b.Unlock()
+	s := b.leases[key]
+	s.last = last
+	if len(s.queue) != 0 {
+		next := s.queue[0]
+		s.queue = s.queue[1:]
+		close(next.start)
+		return
+	}
+	s.busy = false
+	if s.last == nil {
+		delete(b.leases, key)
+	}
+}
+
+// renewalOf decodes the lease in a successful response, nil is returned if it's not a lease
+func (b *Batcher) renewalOf(rw *util.ResponseRecorder) *renewal {
+	if rw.StatusCode != http.StatusOK || rw.Header().Get("Content-Encoding") != "" || rw.Body.Len() > maxLeaseSize {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	lease, _, err := scheme.Codecs.UniversalDeserializer().Decode(rw.Body.Bytes(), nil, nil)
+	if err != nil {
+		return nil
+	}
+	if _, ok := resourceVersionOf(lease); !ok {
+		return nil
+	}
+	return &renewal{lease: lease, mediaType: mediaType, renewedAt: b.clock.Now()}
+}
+
+// renewLocally responds the update of lease key with the last renewal if the update only renews
+// the lease that was renewed in kube-apiserver within minRenewInterval, true is returned if the
+// update is responded.
+func (b *Batcher) renewLocally(key string, lease runtime.Object, w http.ResponseWriter) bool {
+	if b.minRenewInterval <= 0 {
+		return false
+	}
+
+	b.Lock()
+	s, ok := b.leases[key]
+	if !ok || s.busy || s.last == nil {
+		b.Unlock()
+		return false
+	}
+	last := s.last
+	b.Unlock()
+
+	renewTime, ok := renewTimeOf(lease)
+	if !ok || renewTime == nil {
+		return false
+	}
+	interval := b.minRenewInterval
+	if half := durationOf(last.lease) / 2; half < interval {
+		interval = half
+	}
+	if b.clock.Since(last.renewedAt) >= interval {
+		return false
+	}
+	if !apiequality.Semantic.DeepEqual(withRenewTime(lease, nil), withRenewTime(last.lease, nil)) {
+		return false
+	}
+
+	info, ok := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), last.mediaType)
+	if !ok {
+		return false
+	}
+	gvks, _, err := scheme.Scheme.ObjectKinds(last.lease)
+	if err != nil || len(gvks) == 0 {
+		return false
+	}
+	var buf bytes.Buffer
+	encoder := scheme.Codecs.EncoderForVersion(info.Serializer, gvks[0].GroupVersion())
+	if err := encoder.Encode(withRenewTime(last.lease, renewTime), &buf); err != nil {
+		klog.Errorf("failed to encode lease, %v", err)
+		return false
+	}
+
+	w.Header().Set("Content-Type", last.mediaType)
+	w.Header().Set(leaseHeader, "renewed-locally")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+	return true
+}
+
+func resourceVersionOf(lease runtime.Object) (string, bool) {
+	switch l := lease.(type) {
+	case *coordinationv1.Lease:
+		return l.ResourceVersion, true
+	case *coordinationv1beta1.Lease:
+		return l.ResourceVersion, true
+	}
+	return "", false
+}
+
+func renewTimeOf(lease runtime.Object) (*metav1.MicroTime, bool) {
+	switch l := lease.(type) {
+	case *coordinationv1.Lease:
+		return l.Spec.RenewTime, true
+	case *coordinationv1beta1.Lease:
+		return l.Spec.RenewTime, true
+	}
+	return nil, false
+}
+
+func durationOf(lease runtime.Object) time.Duration {
+	var seconds *int32
+	switch l := lease.(type) {
+	case *coordinationv1.Lease:
+		seconds = l.Spec.LeaseDurationSeconds
+	case *coordinationv1beta1.Lease:
+		seconds = l.Spec.LeaseDurationSeconds
+	}
+	if seconds == nil {
+		return 0
+	}
+	return time.Duration(*seconds) * time.Second
+}
+
+// withRenewTime returns a copy of lease with renewTime, its managed fields are dropped, so the
+// copies without renewTime are equal if the lease is only renewed.
+func withRenewTime(lease runtime.Object, renewTime *metav1.MicroTime) runtime.Object {
+	switch l := lease.(type) {
+	case *coordinationv1.Lease:
+		c := l.DeepCopy()
+		c.ManagedFields = nil
+		c.Spec.RenewTime = renewTime
+		return c
+	case *coordinationv1beta1.Lease:
+		c := l.DeepCopy()
+		c.ManagedFields = nil
+		c.Spec.RenewTime = renewTime
+		return c
+	}
+	return lease
+}
+
+func writeResponse(w http.ResponseWriter, resp *util.ResponseRecorder, leaseStatus string) {
+	for h, values := range resp.Header() {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+	if leaseStatus != "" {
+		w.Header().Set(leaseHeader, leaseStatus)
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body.Bytes())
+}
@@ -0,0 +1,246 @@
+package leasebatch
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	leasePath = "/apis/coordination.k8s.io/v1/namespaces/kube-node-lease/leases/node1"
+	jsonType  = "application/json"
+	protoType = "application/vnd.kubernetes.protobuf"
+)
+
+// fakeAPIServer updates the lease and responds it in the content type of request, the updates
+// are blocked until release is closed if it's set.
+type fakeAPIServer struct {
+	sync.Mutex
+	resourceVersion int
+	requests        int
+	release         chan struct{}
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if s.release != nil {
+		<-s.release
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.requests++
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(readAll(req), nil, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lease := obj.(*coordinationv1.Lease)
+	if lease.ResourceVersion != strconv.Itoa(s.resourceVersion) {
+		http.Error(w, "conflict", http.StatusConflict)
+		return
+	}
+	s.resourceVersion++
+	lease.ResourceVersion = strconv.Itoa(s.resourceVersion)
+
+	w.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+	w.WriteHeader(http.StatusOK)
+	w.Write(encode(lease, req.Header.Get("Content-Type")))
+}
+
+func (s *fakeAPIServer) requestCount() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.requests
+}
+
+func readAll(req *http.Request) []byte {
+	var buf bytes.Buffer
+	buf.ReadFrom(req.Body)
+	return buf.Bytes()
+}
+
+func encode(obj runtime.Object, mediaType string) []byte {
+	info, _ := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+	var buf bytes.Buffer
+	scheme.Codecs.EncoderForVersion(info.Serializer, coordinationv1.SchemeGroupVersion).Encode(obj, &buf)
+	return buf.Bytes()
+}
+
+func newHandler(b *Batcher, server http.Handler) http.Handler {
+	resolver := &apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+	return filters.WithRequestInfo(b.WithLeaseBatching(server), resolver)
+}
+
+func newLease(resourceVersion int, holder string, renewTime time.Time) *coordinationv1.Lease {
+	duration := int32(40)
+	renew := metav1.NewMicroTime(renewTime)
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-node-lease", Name: "node1", ResourceVersion: strconv.Itoa(resourceVersion)},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renew,
+		},
+	}
+}
+
+func updateLease(handler http.Handler, lease *coordinationv1.Lease, mediaType string) (*httptest.ResponseRecorder, *coordinationv1.Lease) {
+	req := httptest.NewRequest("PUT", leasePath, bytes.NewReader(encode(lease, mediaType)))
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(w.Body.Bytes(), nil, nil)
+	if err != nil {
+		return w, nil
+	}
+	return w, obj.(*coordinationv1.Lease)
+}
+
+func TestRenewLocally(t *testing.T) {
+	for _, mediaType := range []string{jsonType, protoType} {
+		t.Run(mediaType, func(t *testing.T) {
+			clk := clock.NewFakeClock(time.Now())
+			server := &fakeAPIServer{}
+			handler := newHandler(NewBatcherWithClock(30*time.Second, clk), server)
+
+			testcases := []struct {
+				desc            string
+				step            time.Duration
+				resourceVersion int
+				holder          string
+				code            int
+				lease           string
+				requests        int
+			}{
+				{desc: "lease is renewed in kube-apiserver", holder: "node1", code: http.StatusOK, requests: 1},
+				{desc: "lease is renewed locally", step: 10 * time.Second, resourceVersion: 1, holder: "node1", code: http.StatusOK, lease: "renewed-locally", requests: 1},
+				{desc: "lease is renewed in kube-apiserver in half of duration", step: 10 * time.Second, resourceVersion: 1, holder: "node1", code: http.StatusOK, requests: 2},
+				{desc: "lease is renewed locally again", step: 5 * time.Second, resourceVersion: 2, holder: "node1", code: http.StatusOK, lease: "renewed-locally", requests: 2},
+				{desc: "holder is changed", step: time.Second, resourceVersion: 2, holder: "node2", code: http.StatusOK, requests: 3},
+				{desc: "conflict is not renewed locally", step: time.Second, resourceVersion: 2, holder: "node2", code: http.StatusConflict, requests: 4},
+				{desc: "lease is renewed in kube-apiserver after conflict", step: time.Second, resourceVersion: 3, holder: "node2", code: http.StatusOK, requests: 5},
+			}
+
+			for _, tt := range testcases {
+				clk.Step(tt.step)
+				renewTime := clk.Now()
+				w, lease := updateLease(handler, newLease(tt.resourceVersion, tt.holder, renewTime), mediaType)
+				if w.Code != tt.code {
+					t.Errorf("%s: expect status code %d, but got %d", tt.desc, tt.code, w.Code)
+				}
+				if got := w.Header().Get(leaseHeader); got != tt.lease {
+					t.Errorf("%s: expect lease %q, but got %q", tt.desc, tt.lease, got)
+				}
+				if server.requestCount() != tt.requests {
+					t.Errorf("%s: expect %d requests to kube-apiserver, but got %d", tt.desc, tt.requests, server.requestCount())
+				}
+				if tt.code != http.StatusOK {
+					continue
+				}
+				if lease == nil || lease.Spec.RenewTime == nil || !lease.Spec.RenewTime.Time.Truncate(time.Microsecond).Equal(renewTime.Truncate(time.Microsecond)) {
+					t.Errorf("%s: expect lease renewed at %v, but got %v", tt.desc, renewTime, lease)
+				} else if lease.ResourceVersion != strconv.Itoa(server.resourceVersion) {
+					t.Errorf("%s: expect resourceVersion %d, but got %s", tt.desc, server.resourceVersion, lease.ResourceVersion)
+				}
+			}
+		})
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	server := &fakeAPIServer{release: make(chan struct{})}
+	b := NewBatcher(0)
+	handler := newHandler(b, server)
+
+	queued := func(n int) {
+		for i := 0; i < 100; i++ {
+			b.Lock()
+			count := 0
+			for _, s := range b.leases {
+				count += len(s.queue)
+			}
+			b.Unlock()
+			if count == n {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("expect %d updates are queued", n)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 3)
+	send := func(i int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = updateLease(handler, newLease(0, "node1", time.Now()), jsonType)
+		}()
+	}
+
+	// the first update is sent, the second is queued and coalesced into the third
+	send(0)
+	queued(0)
+	time.Sleep(50 * time.Millisecond)
+	send(1)
+	queued(1)
+	send(2)
+	time.Sleep(50 * time.Millisecond)
+	queued(1)
+	close(server.release)
+	wg.Wait()
+
+	if server.requestCount() != 2 {
+		t.Errorf("expect 2 requests to kube-apiserver, but got %d", server.requestCount())
+	}
+	if results[0].Code != http.StatusOK {
+		t.Errorf("expect the first update succeeds, but got %d", results[0].Code)
+	}
+	if results[1].Header().Get(leaseHeader) != "coalesced" || results[1].Code != results[2].Code || results[1].Body.String() != results[2].Body.String() {
+		t.Errorf("expect the second update gets the response of the third, but got %d %s", results[1].Code, results[1].Body.String())
+	}
+	if len(b.leases) != 0 {
+		t.Errorf("expect no lease is kept when local renewals are disabled, but got %d", len(b.leases))
+	}
+}
+
+func TestPassThrough(t *testing.T) {
+	var requests int
+	handler := newHandler(NewBatcher(time.Minute), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", leasePath, nil),
+		httptest.NewRequest("PUT", "/api/v1/namespaces/default/configmaps/cm1", bytes.NewReader([]byte("{}"))),
+		httptest.NewRequest("PUT", leasePath, bytes.NewReader([]byte("not a lease"))),
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Header().Get(leaseHeader) != "" {
+			t.Errorf("expect %s %s is passed through", req.Method, req.URL.Path)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("expect 3 requests are passed through, but got %d", requests)
+	}
+}
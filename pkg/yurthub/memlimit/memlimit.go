@@ -0,0 +1,141 @@
+package memlimit
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	cgroupRoot   = "/sys/fs/cgroup"
+	procCgroup   = "/proc/self/cgroup"
+	cgroupV2File = "memory.max"
+	cgroupV1File = "memory.limit_in_bytes"
+
+	// unlimitedV1 is the limit above which the limit of cgroup v1 is taken as unlimited,
+	// cgroup v1 reports the max int64 rounded down to the page size when it's not limited.
+	unlimitedV1 = int64(1) << 62
+)
+
+// Detect returns the memory limit in bytes of the cgroup that yurthub runs in(like the
+// limit of its static pod), 0 is returned if the memory is not limited, or the cgroup
+// is not found(like on non-linux platforms).
+func Detect() (int64, error) {
+	return detect(cgroupRoot, procCgroup)
+}
+
+// detect reads the memory limit of the cgroup in procCgroupFile from the cgroup fs under
+// root. cgroup v2 is tried before v1, and the limit in the root of cgroup fs is read if
+// the cgroup of process is not mounted(like the cgroup namespace of containers).
+func detect(root, procCgroupFile string) (int64, error) {
+	v2Path, v1Path, err := cgroupPaths(procCgroupFile)
+	if err != nil {
+		return 0, err
+	}
+
+	candidates := []struct {
+		path string
+		v1   bool
+	}{
+		{path: filepath.Join(root, v2Path, cgroupV2File)},
+		{path: filepath.Join(root, cgroupV2File)},
+		{path: filepath.Join(root, "memory", v1Path, cgroupV1File), v1: true},
+		{path: filepath.Join(root, "memory", cgroupV1File), v1: true},
+	}
+	for _, c := range candidates {
+		b, err := ioutil.ReadFile(c.path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return 0, fmt.Errorf("failed to read memory limit in %s, %v", c.path, err)
+		}
+
+		value := strings.TrimSpace(string(b))
+		if value == "max" {
+			return 0, nil
+		}
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("memory limit(%s) in %s is invalid, %v", value, c.path, err)
+		}
+		if c.v1 && limit >= unlimitedV1 {
+			return 0, nil
+		}
+		return limit, nil
+	}
+
+	return 0, nil
+}
+
+// cgroupPaths returns the paths of cgroup v2 and the memory cgroup v1 in procCgroupFile,
+// like "0::/kubepods/pod1" and "4:memory:/kubepods/pod1". empty paths are returned if
+// procCgroupFile doesn't exist.
+func cgroupPaths(procCgroupFile string) (string, string, error) {
+	f, err := os.Open(procCgroupFile)
+	if os.IsNotExist(err) {
+		return "", "", nil
+	} else if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	var v2Path, v1Path string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			v2Path = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "memory" {
+				v1Path = parts[2]
+			}
+		}
+	}
+	return v2Path, v1Path, scanner.Err()
+}
+
+// Sizes are the sizes of memory-bound caches and limits of yurthub
+type Sizes struct {
+	// MemoryCacheSizeMB is the size of the memory cache in front of the cache storage
+	MemoryCacheSizeMB int
+	// MemoryStorageSizeMB is the size of the cache when the cache is stored in memory
+	MemoryStorageSizeMB int
+	// MaxRequestInFlight is the maximum number of parallel requests
+	MaxRequestInFlight int
+	// ReviewCacheSize is the number of cached auth review results
+	ReviewCacheSize int
+	// WriteDedupSize is the number of recorded responses of writes
+	WriteDedupSize int
+}
+
+// SizesFor returns the sizes that fit in limit bytes of memory. a quarter of memory is
+// given to the cache in memory storage, an eighth to the memory cache, an in-flight
+// request is counted as 2MB for its buffers and decoded objects(10 requests at least),
+// a review result as 256 bytes, and a recorded response of write as 16KB(like a node).
+// the rest is left for the runtime and the objects being proxied.
+func SizesFor(limit int64) Sizes {
+	mb := int(limit / (1024 * 1024))
+	return Sizes{
+		MemoryCacheSizeMB:   mb / 8,
+		MemoryStorageSizeMB: mb / 4,
+		MaxRequestInFlight:  atLeast(mb/2, 10),
+		ReviewCacheSize:     mb * 4,
+		WriteDedupSize:      mb * 2,
+	}
+}
+
+func atLeast(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
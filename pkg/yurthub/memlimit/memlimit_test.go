@@ -0,0 +1,104 @@
+package memlimit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	testcases := map[string]struct {
+		procCgroup string
+		files      map[string]string
+		limit      int64
+		err        bool
+	}{
+		"cgroup v2 of pod": {
+			procCgroup: "0::/kubepods/pod1/c1\n",
+			files:      map[string]string{"kubepods/pod1/c1/memory.max": "134217728\n", "memory.max": "max\n"},
+			limit:      134217728,
+		},
+		"cgroup v2 in cgroup namespace": {
+			procCgroup: "0::/\n",
+			files:      map[string]string{"memory.max": "134217728\n"},
+			limit:      134217728,
+		},
+		"cgroup v2 not limited": {
+			procCgroup: "0::/\n",
+			files:      map[string]string{"memory.max": "max\n"},
+		},
+		"cgroup v1 of pod": {
+			procCgroup: "5:cpu,cpuacct:/kubepods/pod1/c1\n4:memory:/kubepods/pod1/c1\n",
+			files:      map[string]string{"memory/kubepods/pod1/c1/memory.limit_in_bytes": "268435456\n"},
+			limit:      268435456,
+		},
+		"cgroup v1 in container": {
+			procCgroup: "4:memory:/kubepods/pod1/c1\n",
+			files:      map[string]string{"memory/memory.limit_in_bytes": "268435456\n"},
+			limit:      268435456,
+		},
+		"cgroup v1 not limited": {
+			procCgroup: "4:memory:/\n",
+			files:      map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712\n"},
+		},
+		"no cgroup": {},
+		"invalid limit": {
+			procCgroup: "0::/\n",
+			files:      map[string]string{"memory.max": "128Mi\n"},
+			err:        true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			root := t.TempDir()
+			for name, contents := range tt.files {
+				path := filepath.Join(root, name)
+				os.MkdirAll(filepath.Dir(path), 0755)
+				ioutil.WriteFile(path, []byte(contents), 0644)
+			}
+			procCgroup := filepath.Join(root, "proc-cgroup")
+			if len(tt.procCgroup) != 0 {
+				ioutil.WriteFile(procCgroup, []byte(tt.procCgroup), 0644)
+			}
+
+			limit, err := detect(root, procCgroup)
+			if (err != nil) != tt.err {
+				t.Fatalf("expect error %v, but got %v", tt.err, err)
+			}
+			if limit != tt.limit {
+				t.Errorf("expect limit %d, but got %d", tt.limit, limit)
+			}
+		})
+	}
+}
+
+func TestSizesFor(t *testing.T) {
+	testcases := map[string]struct {
+		limit int64
+		sizes Sizes
+	}{
+		"128Mi": {
+			limit: 128 * 1024 * 1024,
+			sizes: Sizes{MemoryCacheSizeMB: 16, MemoryStorageSizeMB: 32, MaxRequestInFlight: 64, ReviewCacheSize: 512, WriteDedupSize: 256},
+		},
+		"1Gi": {
+			limit: 1024 * 1024 * 1024,
+			sizes: Sizes{MemoryCacheSizeMB: 128, MemoryStorageSizeMB: 256, MaxRequestInFlight: 512, ReviewCacheSize: 4096, WriteDedupSize: 2048},
+		},
+		"16Mi": {
+			limit: 16 * 1024 * 1024,
+			sizes: Sizes{MemoryCacheSizeMB: 2, MemoryStorageSizeMB: 4, MaxRequestInFlight: 10, ReviewCacheSize: 64, WriteDedupSize: 32},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if sizes := SizesFor(tt.limit); !reflect.DeepEqual(sizes, tt.sizes) {
+				t.Errorf("expect sizes %+v, but got %+v", tt.sizes, sizes)
+			}
+		})
+	}
+}
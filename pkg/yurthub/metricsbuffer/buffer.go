@@ -0,0 +1,214 @@
+package metricsbuffer
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+)
+
+const (
+	// BufferedPath is the path that the buffered samples are served on
+	BufferedPath = "/v1/metrics/buffered"
+	// metricPrefix is the prefix of the metrics of yurthub itself, only they are buffered
+	metricPrefix = "yurthub_"
+)
+
+var (
+	bufferedSnapshots = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_metrics_buffer",
+			Name:      "snapshots",
+			Help:      "Gauge of snapshots of yurthub metrics that are buffered while remote servers are disconnected.",
+		},
+	)
+	droppedSnapshots = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "yurthub_metrics_buffer",
+			Name:      "dropped_snapshots_total",
+			Help:      "Counter of buffered snapshots that are dropped because the buffer is full.",
+		},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics of metrics buffer.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(bufferedSnapshots)
+		prometheus.MustRegister(droppedSnapshots)
+	})
+}
+
+// snapshot is the samples of yurthub metrics gathered at a time
+type snapshot struct {
+	at       time.Time
+	families []*dto.MetricFamily
+}
+
+// Buffer gathers the metrics of yurthub periodically while all remote servers are
+// disconnected, as the samples can't be scraped by the monitoring in cloud then. the
+// buffered samples are served with their timestamps on BufferedPath after the node is
+// reconnected, so the monitoring can catch up on the samples of the outage. the buffer is
+// in memory and bounded by maxSnapshots, the oldest snapshots are dropped when it's full.
+type Buffer struct {
+	gatherer      prometheus.Gatherer
+	interval      time.Duration
+	maxSnapshots  int
+	remoteServers []*url.URL
+	healthChecker healthchecker.HealthChecker
+	clock         clock.Clock
+	stopCh        <-chan struct{}
+
+	sync.Mutex
+	snapshots []snapshot
+}
+
+// NewBuffer creates a Buffer of the metrics registered in the default registry of prometheus
+func NewBuffer(cfg *config.YurtHubConfiguration, healthChecker healthchecker.HealthChecker, stopCh <-chan struct{}) *Buffer {
+	Register()
+	return newBuffer(prometheus.DefaultGatherer,
+		time.Duration(cfg.MetricsBufferInterval)*time.Second,
+		cfg.MetricsBufferMaxSnapshots,
+		cfg.RemoteServers,
+		healthChecker,
+		clock.RealClock{},
+		stopCh)
+}
+
+func newBuffer(gatherer prometheus.Gatherer,
+	interval time.Duration,
+	maxSnapshots int,
+	remoteServers []*url.URL,
+	healthChecker healthchecker.HealthChecker,
+	clk clock.Clock,
+	stopCh <-chan struct{}) *Buffer {
+	return &Buffer{
+		gatherer:      gatherer,
+		interval:      interval,
+		maxSnapshots:  maxSnapshots,
+		remoteServers: remoteServers,
+		healthChecker: healthChecker,
+		clock:         clk,
+		stopCh:        stopCh,
+	}
+}
+
+// Run gathers the metrics in background while remote servers are disconnected
+func (b *Buffer) Run() {
+	go wait.Until(func() {
+		if !b.connected() {
+			b.record()
+		}
+	}, b.interval, b.stopCh)
+}
+
+// connected returns true if any of remote servers is healthy
+func (b *Buffer) connected() bool {
+	for _, server := range b.remoteServers {
+		if b.healthChecker.IsHealthy(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// record gathers the metrics of yurthub and buffers them as a snapshot
+func (b *Buffer) record() {
+	families, err := b.gatherer.Gather()
+	if err != nil {
+		// the metrics that are gathered are still buffered
+		klog.Warningf("failed to gather some metrics for buffer, %v", err)
+	}
+
+	now := b.clock.Now()
+	ts := now.UnixNano() / int64(time.Millisecond)
+	owned := make([]*dto.MetricFamily, 0, len(families))
+	for _, family := range families {
+		if !strings.HasPrefix(family.GetName(), metricPrefix) {
+			continue
+		}
+		for _, m := range family.Metric {
+			m.TimestampMs = &ts
+		}
+		owned = append(owned, family)
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	b.snapshots = append(b.snapshots, snapshot{at: now, families: owned})
+	if dropped := len(b.snapshots) - b.maxSnapshots; dropped > 0 {
+		b.snapshots = b.snapshots[dropped:]
+		droppedSnapshots.Add(float64(dropped))
+	}
+	bufferedSnapshots.Set(float64(len(b.snapshots)))
+}
+
+// ServeHTTP writes the buffered samples in the text format of prometheus with their timestamps,
+// the samples of a metric family are grouped together in the order of time. only the samples
+// after the query parameter since(in unix milliseconds) are written if it's set, so the samples
+// can be caught up on incrementally.
+func (b *Buffer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var since int64
+	if s := req.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid since %q, it should be unix milliseconds", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, family := range b.merge(since) {
+		if _, err := expfmt.MetricFamilyToText(&buf, family); err != nil {
+			klog.Errorf("failed to encode buffered metrics %s, %v", family.GetName(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		klog.Errorf("failed to write buffered metrics, %v", err)
+	}
+}
+
+// merge groups the samples of the snapshots after since by metric family
+func (b *Buffer) merge(since int64) []*dto.MetricFamily {
+	b.Lock()
+	defer b.Unlock()
+
+	merged := make([]*dto.MetricFamily, 0)
+	index := make(map[string]*dto.MetricFamily)
+	for _, s := range b.snapshots {
+		if s.at.UnixNano()/int64(time.Millisecond) <= since {
+			continue
+		}
+		for _, family := range s.families {
+			m, ok := index[family.GetName()]
+			if !ok {
+				m = &dto.MetricFamily{Name: family.Name, Help: family.Help, Type: family.Type}
+				index[family.GetName()] = m
+				merged = append(merged, m)
+			}
+			m.Metric = append(m.Metric, family.Metric...)
+		}
+	}
+	return merged
+}
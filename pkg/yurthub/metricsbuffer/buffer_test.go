@@ -0,0 +1,124 @@
+package metricsbuffer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type fakeHealthChecker struct {
+	healthy bool
+}
+
+func (f *fakeHealthChecker) IsHealthy(server *url.URL) bool {
+	return f.healthy
+}
+
+func newTestBuffer(maxSnapshots int) (*Buffer, prometheus.Gauge, *clock.FakeClock, *fakeHealthChecker) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "yurthub_test_value", Help: "test value"})
+	registry.MustRegister(gauge)
+	registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "other_value", Help: "other value"}))
+
+	u, _ := url.Parse("https://127.0.0.1:6443")
+	checker := &fakeHealthChecker{healthy: true}
+	clk := clock.NewFakeClock(time.Unix(1000, 0))
+	return newBuffer(registry, time.Minute, maxSnapshots, []*url.URL{u}, checker, clk, nil), gauge, clk, checker
+}
+
+func TestRecord(t *testing.T) {
+	b, gauge, clk, checker := newTestBuffer(2)
+	if !b.connected() {
+		t.Errorf("expect connected when remote server is healthy")
+	}
+	checker.healthy = false
+	if b.connected() {
+		t.Errorf("expect disconnected when remote server is unhealthy")
+	}
+
+	for i := 1; i <= 3; i++ {
+		gauge.Set(float64(i))
+		b.record()
+		clk.Step(time.Minute)
+	}
+
+	// the oldest snapshot is dropped, and only the metrics of yurthub are buffered
+	if len(b.snapshots) != 2 {
+		t.Fatalf("expect 2 snapshots, but got %d", len(b.snapshots))
+	}
+	for _, s := range b.snapshots {
+		if len(s.families) != 1 || s.families[0].GetName() != "yurthub_test_value" {
+			t.Errorf("expect only yurthub_test_value is buffered, but got %v", s.families)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	b, gauge, clk, _ := newTestBuffer(10)
+	for i := 1; i <= 3; i++ {
+		gauge.Set(float64(i))
+		b.record()
+		clk.Step(time.Minute)
+	}
+
+	testcases := map[string]struct {
+		query    string
+		code     int
+		expected []string
+	}{
+		"all samples": {
+			code:     http.StatusOK,
+			expected: []string{"yurthub_test_value 1 1000000", "yurthub_test_value 2 1060000", "yurthub_test_value 3 1120000"},
+		},
+		"samples since": {
+			query:    "?since=1060000",
+			code:     http.StatusOK,
+			expected: []string{"yurthub_test_value 3 1120000"},
+		},
+		"no samples since": {
+			query: "?since=1120000",
+			code:  http.StatusOK,
+		},
+		"invalid since": {
+			query: "?since=yesterday",
+			code:  http.StatusBadRequest,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			b.ServeHTTP(w, httptest.NewRequest("GET", BufferedPath+tt.query, nil))
+			if w.Code != tt.code {
+				t.Fatalf("expect code %d, but got %d", tt.code, w.Code)
+			} else if tt.code != http.StatusOK {
+				return
+			}
+
+			body := w.Body.String()
+			samples := []string{}
+			for _, line := range strings.Split(body, "\n") {
+				if strings.HasPrefix(line, "yurthub_test_value") {
+					samples = append(samples, line)
+				}
+			}
+			if strings.Join(samples, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expect samples %v, but got %v", tt.expected, samples)
+			}
+			if strings.Count(body, "# TYPE yurthub_test_value") > 1 {
+				t.Errorf("expect samples of a family are grouped, but got %s", body)
+			}
+			var parser expfmt.TextParser
+			if _, err := parser.TextToMetricFamilies(strings.NewReader(body)); err != nil {
+				t.Errorf("failed to parse buffered metrics, %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package metricsshim
+
+import (
+	"net/http"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+)
+
+const (
+	// MetricsAPIGroup is the api group served by metrics-server
+	MetricsAPIGroup = "metrics.k8s.io"
+	// CacheStatusHeader is set to "stale" for metrics served from local cache
+	CacheStatusHeader = "Yurthub-Cache-Status"
+)
+
+type IsHealthy func() bool
+
+// MetricsShim makes the metrics.k8s.io responses of local consumers(like kubectl top
+// or HPA-like agents) cached as raw responses of aggregated api when cluster is healthy,
+// so they are served from local cache when cluster is unhealthy. the responses served
+// from cache are marked stale via headers.
+type MetricsShim struct {
+	isHealthy IsHealthy
+}
+
+// NewMetricsShim creates a metrics shim, metrics.k8s.io is pinned in the aggregated
+// api groups of cache manager, so metrics are cached whatever the allow-list is.
+func NewMetricsShim(cacheMgr cachemanager.CacheManager, isHealthy IsHealthy) *MetricsShim {
+	cacheMgr.PinAggregatedAPIGroups(MetricsAPIGroup)
+	return &MetricsShim{
+		isHealthy: isHealthy,
+	}
+}
+
+// WithMetricsShim wraps the handler, get/list requests of metrics.k8s.io from
+// any component can be cached, and the responses are marked stale when cluster
+// is unhealthy.
+func (ms *MetricsShim) WithMetricsShim(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := apirequest.RequestInfoFrom(req.Context())
+		if !ok || !isMetricsRequest(info) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		req = req.WithContext(util.WithReqCanCache(req.Context(), true))
+		if ms.isHealthy() {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		klog.V(3).Infof("serve stale metrics for %s", util.ReqString(req))
+		handler.ServeHTTP(&staleResponseWriter{ResponseWriter: w}, req)
+	})
+}
+
+func isMetricsRequest(info *apirequest.RequestInfo) bool {
+	return info.IsResourceRequest &&
+		info.APIGroup == MetricsAPIGroup &&
+		(info.Verb == "get" || info.Verb == "list")
+}
+
+// staleResponseWriter marks the successful response written through it stale
+type staleResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (rw *staleResponseWriter) WriteHeader(statusCode int) {
+	if !rw.wroteHeader && statusCode == http.StatusOK {
+		rw.Header().Set("Warning", `110 - "Response is Stale"`)
+		rw.Header().Set(CacheStatusHeader, "stale")
+	}
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *staleResponseWriter) Write(b []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *staleResponseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
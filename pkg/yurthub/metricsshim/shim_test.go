@@ -0,0 +1,158 @@
+package metricsshim
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/proxy/local"
+	"github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	hubutil "github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func newTestRequestInfoResolver() *request.RequestInfoFactory {
+	return &request.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+}
+
+func TestWithMetricsShim(t *testing.T) {
+	store, err := disk.NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	cacheMgr, err := cachemanager.NewCacheManager(cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec()),
+		serializer.NewSerializerManager(), nil, 0)
+	if err != nil {
+		t.Fatalf("failed to create cache manager, %v", err)
+	}
+
+	healthy := true
+	isHealthy := func() bool {
+		return healthy
+	}
+	ms := NewMetricsShim(cacheMgr, isHealthy)
+	localProxy := local.NewLocalProxy(cacheMgr, isHealthy)
+
+	body := `{"kind":"NodeMetricsList","apiVersion":"metrics.k8s.io/v1beta1","items":[]}`
+	filteredBody := `{"kind":"PodMetricsList","apiVersion":"metrics.k8s.io/v1beta1","items":[]}`
+	// proxyHandler caches the responses as remote proxy does when cluster is healthy,
+	// and serves the requests by local proxy when cluster is unhealthy.
+	proxyHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !healthy {
+			localProxy.ServeHTTP(w, req)
+			return
+		}
+
+		respBody := body
+		if req.URL.RawQuery != "" {
+			respBody = filteredBody
+		}
+		if cacheMgr.CanCacheFor(req) {
+			ctx := hubutil.WithRespContentType(req.Context(), "application/json")
+			ctx = hubutil.WithReqQuery(ctx, req.URL.RawQuery)
+			if err := cacheMgr.CacheResponse(ctx, ioutil.NopCloser(bytes.NewBufferString(respBody)), nil); err != nil {
+				t.Errorf("failed to cache response, %v", err)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(respBody))
+	})
+
+	var handler http.Handler = ms.WithMetricsShim(proxyHandler)
+	handler = util.WithRequestContentType(handler)
+	handler = util.WithRequestClientComponent(handler)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+
+	tests := []struct {
+		desc        string
+		healthy     bool
+		path        string
+		code        int
+		body        string
+		cacheStatus string
+	}{
+		{
+			desc:    "offline without cached metrics",
+			healthy: false,
+			path:    "/apis/metrics.k8s.io/v1beta1/nodes",
+			code:    http.StatusNotFound,
+		},
+		{
+			desc:    "online metrics request",
+			healthy: true,
+			path:    "/apis/metrics.k8s.io/v1beta1/nodes",
+			code:    http.StatusOK,
+			body:    body,
+		},
+		{
+			desc:    "online metrics request with selector",
+			healthy: true,
+			path:    "/apis/metrics.k8s.io/v1beta1/nodes?labelSelector=app%3Dx",
+			code:    http.StatusOK,
+			body:    filteredBody,
+		},
+		{
+			desc:        "offline with cached metrics",
+			healthy:     false,
+			path:        "/apis/metrics.k8s.io/v1beta1/nodes",
+			code:        http.StatusOK,
+			body:        body,
+			cacheStatus: "stale",
+		},
+		{
+			desc:        "offline with cached metrics for selector",
+			healthy:     false,
+			path:        "/apis/metrics.k8s.io/v1beta1/nodes?labelSelector=app%3Dx",
+			code:        http.StatusOK,
+			body:        filteredBody,
+			cacheStatus: "stale",
+		},
+		{
+			desc:    "offline with other path",
+			healthy: false,
+			path:    "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods",
+			code:    http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			healthy = tt.healthy
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("User-Agent", "kubectl/v1.18.0")
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			result := resp.Result()
+			if result.StatusCode != tt.code {
+				t.Errorf("Got status code %d, but expect %d", result.StatusCode, tt.code)
+			}
+
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			b, _ := ioutil.ReadAll(result.Body)
+			if string(b) != tt.body {
+				t.Errorf("Got body %s, but expect %s", string(b), tt.body)
+			}
+
+			if status := result.Header.Get(CacheStatusHeader); status != tt.cacheStatus {
+				t.Errorf("Got cache status %q, but expect %q", status, tt.cacheStatus)
+			}
+		})
+	}
+}
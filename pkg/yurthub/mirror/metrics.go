@@ -0,0 +1,45 @@
+package mirror
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	mirrorSubsystem = "yurthub_mirror"
+	// the results of mirrored requests in metrics
+	resultMatched    = "matched"
+	resultMismatched = "mismatched"
+	resultFailed     = "failed"
+	resultDropped    = "dropped"
+)
+
+var (
+	mirroredRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: mirrorSubsystem,
+			Name:      "requests_total",
+			Help:      "Counter of the sampled read requests that are mirrored to the secondary cluster, by result(matched or mismatched status code of the primary, failed, or dropped when too many are in flight).",
+		},
+		[]string{"result"},
+	)
+	mirrorDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Subsystem: mirrorSubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Histogram of the duration of requests mirrored to the secondary cluster.",
+			Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics of mirrored requests.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(mirroredRequests)
+		prometheus.MustRegister(mirrorDuration)
+	})
+}
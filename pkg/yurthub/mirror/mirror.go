@@ -0,0 +1,204 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+)
+
+const (
+	// maxInFlight bounds the number of mirrored requests in flight, requests beyond it are
+	// not mirrored, so a slow secondary cluster never holds up the requests of the primary.
+	maxInFlight = 8
+	// mirrorTimeout bounds the time of a mirrored request
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirroredHeaders are the headers of requests that are copied to the mirrored requests. credentials
+// (like Authorization and impersonation) are never copied, the mirrored requests are authenticated
+// by the kubeconfig of the secondary cluster.
+var mirroredHeaders = []string{"Accept", "User-Agent"}
+
+// Mirror duplicates the sampled read requests(get and list of resources, and discovery) that are
+// proxied by yurthub to a secondary cluster(like the staging control plane that an edge fleet is
+// moving to), and compares the status codes of the secondary with the primary, so the migration
+// can be rehearsed with the real traffic of nodes. writes, watches, upgrades(like exec) and the
+// subresources other than status are never mirrored. the responses of the secondary are
+// discarded, and requests are not mirrored when maxInFlight is reached.
+type Mirror struct {
+	server   *url.URL
+	client   *http.Client
+	inFlight chan struct{}
+	sample   func() bool
+}
+
+// NewMirror creates a Mirror to the cluster of kubeconfig for percent of read requests
+func NewMirror(kubeconfig string, percent int) (*Mirror, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig %s, %v", kubeconfig, err)
+	}
+	rt, err := rest.TransportFor(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transport for %s, %v", cfg.Host, err)
+	}
+	server, err := url.Parse(cfg.Host)
+	if err != nil || server.Scheme == "" || server.Host == "" {
+		return nil, fmt.Errorf("server(%s) of kubeconfig %s is invalid", cfg.Host, kubeconfig)
+	}
+
+	return newMirror(server, &http.Client{Transport: rt, Timeout: mirrorTimeout}, func() bool {
+		return rand.Intn(100) < percent
+	}), nil
+}
+
+func newMirror(server *url.URL, client *http.Client, sample func() bool) *Mirror {
+	return &Mirror{
+		server:   server,
+		client:   client,
+		inFlight: make(chan struct{}, maxInFlight),
+		sample:   sample,
+	}
+}
+
+// WithMirroring mirrors the sampled read requests that are served by handler. a nil Mirror mirrors
+// nothing.
+func (m *Mirror) WithMirroring(handler http.Handler) http.Handler {
+	if m == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !isReadRequest(req) || !m.sample() {
+			handler.ServeHTTP(w, req)
+			return
+		}
+		select {
+		case m.inFlight <- struct{}{}:
+		default:
+			mirroredRequests.WithLabelValues(resultDropped).Inc()
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		primary := make(chan int, 1)
+		go m.mirror(m.newRequest(req), util.ReqString(req), primary)
+
+		rw := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		defer func() {
+			primary <- rw.statusCode
+		}()
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// isReadRequest returns true if req only reads from the cluster and it's not long-running
+func isReadRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet || req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	if !ok {
+		return false
+	}
+	if !info.IsResourceRequest {
+		return true
+	}
+	if info.Verb != "get" && info.Verb != "list" {
+		return false
+	}
+	return info.Subresource == "" || info.Subresource == "status"
+}
+
+// newRequest creates the request to the secondary cluster for req, it's not bound to the context
+// of req, so it's not canceled when req is finished.
+func (m *Mirror) newRequest(req *http.Request) *http.Request {
+	u := *m.server
+	u.Path = strings.TrimSuffix(m.server.Path, "/") + req.URL.Path
+	u.RawPath = ""
+	u.RawQuery = req.URL.RawQuery
+
+	out := (&http.Request{
+		Method:     http.MethodGet,
+		URL:        &u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Host:       u.Host,
+	}).WithContext(context.Background())
+	for _, h := range mirroredHeaders {
+		if v := req.Header.Get(h); v != "" {
+			out.Header.Set(h, v)
+		}
+	}
+	return out
+}
+
+// mirror sends req to the secondary cluster, and compares its status code with the status code
+// of the primary that is sent to primary.
+func (m *Mirror) mirror(req *http.Request, reqString string, primary <-chan int) {
+	defer func() {
+		<-m.inFlight
+	}()
+
+	start := time.Now()
+	code := 0
+	resp, err := m.client.Do(req)
+	if err == nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		code = resp.StatusCode
+	}
+	mirrorDuration.Observe(time.Since(start).Seconds())
+
+	primaryCode := <-primary
+	switch {
+	case err != nil:
+		klog.V(2).Infof("failed to mirror %s to %s, %v", reqString, m.server.Host, err)
+		mirroredRequests.WithLabelValues(resultFailed).Inc()
+	case code == primaryCode:
+		mirroredRequests.WithLabelValues(resultMatched).Inc()
+	default:
+		klog.V(2).Infof("mirror of %s is responded %d by %s, but %d by the primary", reqString, code, m.server.Host, primaryCode)
+		mirroredRequests.WithLabelValues(resultMismatched).Inc()
+	}
+}
+
+// statusRecorder records the status code of response that is written to ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.statusCode = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
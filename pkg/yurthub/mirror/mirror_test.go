@@ -0,0 +1,148 @@
+package mirror
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// fakeSecondary records the requests that are mirrored to it
+type fakeSecondary struct {
+	sync.Mutex
+	requests []*http.Request
+}
+
+func (s *fakeSecondary) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.Lock()
+	s.requests = append(s.requests, req)
+	s.Unlock()
+	if strings.HasSuffix(req.URL.Path, "/missing") {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fakeSecondary) received() []*http.Request {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*http.Request{}, s.requests...)
+}
+
+func newHandler(m *Mirror, primary http.Handler) http.Handler {
+	resolver := &apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+	return filters.WithRequestInfo(m.WithMirroring(primary), resolver)
+}
+
+func TestWithMirroring(t *testing.T) {
+	secondary := &fakeSecondary{}
+	server := httptest.NewServer(secondary)
+	defer server.Close()
+	u, _ := url.Parse(server.URL + "/prefix")
+	m := newMirror(u, server.Client(), func() bool { return true })
+
+	var primaryRequests int
+	handler := newHandler(m, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		primaryRequests++
+		w.Write([]byte("primary"))
+	}))
+
+	testcases := map[string]struct {
+		method   string
+		path     string
+		header   map[string]string
+		mirrored bool
+	}{
+		"get":                 {method: "GET", path: "/api/v1/namespaces/default/pods/pod1", mirrored: true},
+		"list":                {method: "GET", path: "/api/v1/pods?fieldSelector=spec.nodeName%3Dnode1&limit=500", mirrored: true},
+		"status":              {method: "GET", path: "/api/v1/nodes/node1/status", mirrored: true},
+		"discovery":           {method: "GET", path: "/apis", mirrored: true},
+		"watch":               {method: "GET", path: "/api/v1/pods?watch=true"},
+		"log":                 {method: "GET", path: "/api/v1/namespaces/default/pods/pod1/log"},
+		"exec":                {method: "GET", path: "/api/v1/namespaces/default/pods/pod1/exec?command=ls", header: map[string]string{"Upgrade": "SPDY/3.1", "Connection": "Upgrade"}},
+		"create":              {method: "POST", path: "/api/v1/namespaces/default/pods"},
+		"update":              {method: "PUT", path: "/api/v1/nodes/node1"},
+		"patch":               {method: "PATCH", path: "/api/v1/nodes/node1/status"},
+		"delete":              {method: "DELETE", path: "/api/v1/namespaces/default/pods/pod1"},
+		"get with credential": {method: "GET", path: "/api/v1/namespaces/default/secrets/token", header: map[string]string{"Authorization": "Bearer token"}, mirrored: true},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			before := len(secondary.received())
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for h, v := range tt.header {
+				req.Header.Set(h, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Body.String() != "primary" {
+				t.Errorf("expect response of primary, but got %s", w.Body.String())
+			}
+
+			// mirrored requests are sent asynchronously
+			var received []*http.Request
+			for i := 0; i < 50; i++ {
+				if received = secondary.received(); len(received) > before || !tt.mirrored {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if !tt.mirrored {
+				time.Sleep(50 * time.Millisecond)
+				if n := len(secondary.received()); n != before {
+					t.Errorf("expect %s %s is not mirrored", tt.method, tt.path)
+				}
+				return
+			}
+			if len(received) != before+1 {
+				t.Fatalf("expect %s %s is mirrored", tt.method, tt.path)
+			}
+			mirrored := received[before]
+			if mirrored.Method != "GET" || mirrored.URL.String() != "/prefix"+tt.path {
+				t.Errorf("expect GET /prefix%s, but got %s %s", tt.path, mirrored.Method, mirrored.URL.String())
+			}
+			if mirrored.Header.Get("Authorization") != "" {
+				t.Errorf("expect credentials of request are not mirrored")
+			}
+		})
+	}
+}
+
+func TestMirrorIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	u, _ := url.Parse(server.URL)
+	m := newMirror(u, server.Client(), func() bool { return true })
+	handler := newHandler(m, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	// the primary is never blocked by the secondary
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 2*maxInFlight; i++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/pods", nil))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expect requests are served while the secondary is blocked")
+	}
+	if len(m.inFlight) != maxInFlight {
+		t.Errorf("expect %d mirrored requests in flight, but got %d", maxInFlight, len(m.inFlight))
+	}
+}
@@ -0,0 +1,276 @@
+// Package v1alpha1 is the v1alpha1 api of yurthub plugins. plugins are out-of-process grpc servers
+// that listen on unix sockets in the plugin dir of yurthub, so third parties can extend yurthub
+// without forking it. messages are encoded in json, so plugins can be written in any language
+// with a grpc library, and the content-subtype of calls is "json".
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+const (
+	// Version is the version of plugin api
+	Version = "v1alpha1"
+	// ServiceName is the name of grpc service that plugins serve
+	ServiceName = "yurthub.plugin.v1alpha1.Plugin"
+	// CodecName is the content-subtype of calls to plugins
+	CodecName = "json"
+
+	// CapabilityRequestFilter is the capability of plugins that filter the requests to yurthub
+	CapabilityRequestFilter = "RequestFilter"
+	// CapabilityStorage is the capability of plugins that store the cache of yurthub, they serve
+	// StorageServiceName on the same socket, and are used by --cache-storage=plugin.
+	CapabilityStorage = "Storage"
+
+	// StorageServiceName is the name of grpc service that storage plugins serve
+	StorageServiceName = "yurthub.plugin.v1alpha1.Storage"
+)
+
+// the errors of storage plugins in StorageResponse, they are the errors of storage.Store in yurthub
+const (
+	StorageErrorNotFound       = "NotFound"
+	StorageErrorKeyIsDir       = "KeyIsDir"
+	StorageErrorInvalidKey     = "InvalidKey"
+	StorageErrorProtectedKey   = "ProtectedKey"
+	StorageErrorExceedQuota    = "ExceedQuota"
+	StorageErrorCorrupted      = "Corrupted"
+	StorageErrorAccessConflict = "AccessConflict"
+)
+
+// InfoRequest is the request to get the info of plugin
+type InfoRequest struct {
+	// Version is the version of plugin api that yurthub uses
+	Version string `json:"version"`
+}
+
+// PluginInfo is the info of plugin
+type PluginInfo struct {
+	Name string `json:"name"`
+	// Version is the version of plugin api that the plugin serves
+	Version string `json:"version"`
+	// Capabilities are what the plugin extends, like RequestFilter
+	Capabilities []string `json:"capabilities"`
+}
+
+// FilterRequest is a request to yurthub that is filtered by plugins
+type FilterRequest struct {
+	Method    string              `json:"method"`
+	Path      string              `json:"path"`
+	Header    map[string][]string `json:"header,omitempty"`
+	Component string              `json:"component,omitempty"`
+	// the request info of kubernetes api, they are empty for non-resource requests
+	Verb        string `json:"verb,omitempty"`
+	APIGroup    string `json:"apiGroup,omitempty"`
+	Resource    string `json:"resource,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// FilterResponse is the decision of plugin for a request
+type FilterResponse struct {
+	// Allowed is true if the request goes on to the next plugin and yurthub
+	Allowed bool `json:"allowed"`
+	// StatusCode and Message are responded to the client if the request is not allowed
+	StatusCode int    `json:"statusCode,omitempty"`
+	Message    string `json:"message,omitempty"`
+	// SetHeaders are set on the allowed request
+	SetHeaders map[string]string `json:"setHeaders,omitempty"`
+}
+
+// StorageRequest is a call to the storage of plugin, the fields are set by the method
+type StorageRequest struct {
+	// Key is the key of Create, Delete, Get, Update, the key of collection of DeleteCollection,
+	// ListKeys and List, and the root key of Replace
+	Key string `json:"key"`
+	// Contents are the contents of Create and Update
+	Contents []byte `json:"contents,omitempty"`
+	// Force deletes the protected keys(the root of a component or internal keys) by DeleteCollection
+	Force bool `json:"force,omitempty"`
+	// Objects are the contents of keys under Key that replace all keys under it by Replace
+	Objects map[string][]byte `json:"objects,omitempty"`
+}
+
+// StorageResponse is the result of a call to the storage of plugin
+type StorageResponse struct {
+	// Contents are the contents of Get(one item) and List, in ascending lexical order of their keys
+	Contents [][]byte `json:"contents,omitempty"`
+	// Keys are the keys of ListKeys in ascending lexical order
+	Keys []string `json:"keys,omitempty"`
+	// Error is one of StorageError* if the call is failed by the storage, like a missing key,
+	// other failures of plugin are returned as grpc errors.
+	Error string `json:"error,omitempty"`
+	// Message is the message of Error
+	Message string `json:"message,omitempty"`
+}
+
+// PluginServer is the server api that plugins implement. a plugin only needs to implement
+// the methods of its capabilities, the others can return an Unimplemented error.
+type PluginServer interface {
+	GetInfo(ctx context.Context, req *InfoRequest) (*PluginInfo, error)
+	Filter(ctx context.Context, req *FilterRequest) (*FilterResponse, error)
+}
+
+// PluginClient is the client api of plugins that yurthub uses
+type PluginClient interface {
+	GetInfo(ctx context.Context, req *InfoRequest) (*PluginInfo, error)
+	Filter(ctx context.Context, req *FilterRequest) (*FilterResponse, error)
+}
+
+type pluginClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewPluginClient creates a PluginClient on cc
+func NewPluginClient(cc *grpc.ClientConn) PluginClient {
+	return &pluginClient{cc: cc}
+}
+
+func (c *pluginClient) GetInfo(ctx context.Context, req *InfoRequest) (*PluginInfo, error) {
+	out := &PluginInfo{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/GetInfo", req, out, grpc.CallContentSubtype(CodecName)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pluginClient) Filter(ctx context.Context, req *FilterRequest) (*FilterResponse, error) {
+	out := &FilterResponse{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/Filter", req, out, grpc.CallContentSubtype(CodecName)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StorageServer is the server api that storage plugins implement, the methods are the ones of
+// storage.Store in yurthub, and they must behave the same.
+type StorageServer interface {
+	Create(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	Delete(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	DeleteCollection(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	Get(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	ListKeys(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	List(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	Update(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+	Replace(ctx context.Context, req *StorageRequest) (*StorageResponse, error)
+}
+
+// StorageClient is the client api of storage plugins that yurthub uses
+type StorageClient interface {
+	// Call invokes method(like "Get") of StorageServer
+	Call(ctx context.Context, method string, req *StorageRequest) (*StorageResponse, error)
+}
+
+type storageClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewStorageClient creates a StorageClient on cc
+func NewStorageClient(cc *grpc.ClientConn) StorageClient {
+	return &storageClient{cc: cc}
+}
+
+func (c *storageClient) Call(ctx context.Context, method string, req *StorageRequest) (*StorageResponse, error) {
+	out := &StorageResponse{}
+	if err := c.cc.Invoke(ctx, "/"+StorageServiceName+"/"+method, req, out, grpc.CallContentSubtype(CodecName)); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RegisterStorageServer registers srv on s, it's registered on the same server as PluginServer
+func RegisterStorageServer(s *grpc.Server, srv StorageServer) {
+	s.RegisterService(&storageServiceDesc, srv)
+}
+
+// storageMethods are the methods of StorageServer by name
+var storageMethods = map[string]func(StorageServer, context.Context, *StorageRequest) (*StorageResponse, error){
+	"Create":           StorageServer.Create,
+	"Delete":           StorageServer.Delete,
+	"DeleteCollection": StorageServer.DeleteCollection,
+	"Get":              StorageServer.Get,
+	"ListKeys":         StorageServer.ListKeys,
+	"List":             StorageServer.List,
+	"Update":           StorageServer.Update,
+	"Replace":          StorageServer.Replace,
+}
+
+var storageServiceDesc = newStorageServiceDesc()
+
+func newStorageServiceDesc() grpc.ServiceDesc {
+	desc := grpc.ServiceDesc{
+		ServiceName: StorageServiceName,
+		HandlerType: (*StorageServer)(nil),
+		Metadata:    "yurthub/plugin/v1alpha1",
+	}
+	for name, method := range storageMethods {
+		method := method
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &StorageRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return method(srv.(StorageServer), ctx, in)
+			},
+		})
+	}
+	return desc
+}
+
+// RegisterPluginServer registers srv on s
+func RegisterPluginServer(s *grpc.Server, srv PluginServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*PluginServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetInfo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &InfoRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(PluginServer).GetInfo(ctx, in)
+			},
+		},
+		{
+			MethodName: "Filter",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &FilterRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				return srv.(PluginServer).Filter(ctx, in)
+			},
+		},
+	},
+	Metadata: "yurthub/plugin/v1alpha1",
+}
+
+// jsonCodec encodes the messages of plugin api in json
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return CodecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,274 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin/api/v1alpha1"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"google.golang.org/grpc"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/klog"
+)
+
+const (
+	// FailurePolicyIgnore lets requests go on when a plugin fails
+	FailurePolicyIgnore = "Ignore"
+	// FailurePolicyFail rejects requests when a plugin fails
+	FailurePolicyFail = "Fail"
+
+	// socketSuffix is the suffix of plugin sockets in the plugin dir
+	socketSuffix = ".sock"
+	// syncPeriod is the period to discover new plugins and check the registered ones
+	syncPeriod = 10 * time.Second
+	// dialTimeout bounds the time of connecting a plugin and getting its info
+	dialTimeout = 5 * time.Second
+	// filterTimeout bounds the time of a plugin to filter a request, so a stuck plugin
+	// doesn't stall the requests of node agents.
+	filterTimeout = time.Second
+)
+
+// IsSupportedFailurePolicy returns true if policy is a supported failure policy of plugins
+func IsSupportedFailurePolicy(policy string) bool {
+	return policy == FailurePolicyIgnore || policy == FailurePolicyFail
+}
+
+type plugin struct {
+	name         string
+	socket       string
+	capabilities sets.String
+	conn         *grpc.ClientConn
+	client       v1alpha1.PluginClient
+}
+
+// Manager manages the lifecycle of plugins in the plugin dir. a plugin is registered after it
+// creates its unix socket in the dir and serves the same plugin api version as yurthub, it's
+// unregistered after its socket is removed or it fails to respond, and it's registered again
+// once it's back, so plugins can be installed, upgraded and removed without restarting yurthub.
+type Manager struct {
+	sync.RWMutex
+	dir           string
+	failurePolicy string
+	plugins       map[string]*plugin
+}
+
+// NewManager creates a Manager for the plugins in dir
+func NewManager(dir, failurePolicy string) *Manager {
+	return &Manager{
+		dir:           dir,
+		failurePolicy: failurePolicy,
+		plugins:       make(map[string]*plugin),
+	}
+}
+
+// Run syncs the plugins periodically until stopCh is closed
+func (m *Manager) Run(stopCh <-chan struct{}) {
+	go wait.Until(m.sync, syncPeriod, stopCh)
+	go func() {
+		<-stopCh
+		m.Lock()
+		defer m.Unlock()
+		for socket, p := range m.plugins {
+			p.conn.Close()
+			delete(m.plugins, socket)
+		}
+	}()
+}
+
+// sync registers the new plugins in dir, and unregisters the removed or failed ones
+func (m *Manager) sync() {
+	sockets, err := filepath.Glob(filepath.Join(m.dir, "*"+socketSuffix))
+	if err != nil {
+		klog.Errorf("failed to list plugins in %s, %v", m.dir, err)
+		return
+	}
+	present := sets.NewString(sockets...)
+
+	m.RLock()
+	registered := make(map[string]*plugin, len(m.plugins))
+	for socket, p := range m.plugins {
+		registered[socket] = p
+	}
+	m.RUnlock()
+
+	for socket, p := range registered {
+		if !present.Has(socket) {
+			klog.Infof("plugin %s is unregistered, socket %s is removed", p.name, socket)
+			m.unregister(socket)
+		} else if _, err := getInfo(p.client); err != nil {
+			klog.Errorf("plugin %s is unregistered, failed to get its info, %v", p.name, err)
+			m.unregister(socket)
+		}
+	}
+
+	for _, socket := range sockets {
+		if _, ok := registered[socket]; ok {
+			continue
+		}
+		p, err := connect(socket)
+		if err != nil {
+			klog.Errorf("failed to register plugin %s, %v", socket, err)
+			continue
+		}
+		klog.Infof("plugin %s(%s) is registered with capabilities %v", p.name, socket, p.capabilities.List())
+		m.Lock()
+		m.plugins[socket] = p
+		m.Unlock()
+	}
+}
+
+func (m *Manager) unregister(socket string) {
+	m.Lock()
+	defer m.Unlock()
+	if p, ok := m.plugins[socket]; ok {
+		p.conn.Close()
+		delete(m.plugins, socket)
+	}
+}
+
+// connect connects the plugin on socket and checks its version
+func connect(socket string) (*plugin, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, socket,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect, %v", err)
+	}
+
+	client := v1alpha1.NewPluginClient(conn)
+	info, err := getInfo(client)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get info, %v", err)
+	}
+	if info.Version != v1alpha1.Version {
+		conn.Close()
+		return nil, fmt.Errorf("plugin %s serves api %s, but %s is required", info.Name, info.Version, v1alpha1.Version)
+	}
+
+	name := info.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(socket), socketSuffix)
+	}
+	return &plugin{
+		name:         name,
+		socket:       socket,
+		capabilities: sets.NewString(info.Capabilities...),
+		conn:         conn,
+		client:       client,
+	}, nil
+}
+
+func getInfo(client v1alpha1.PluginClient) (*v1alpha1.PluginInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	return client.GetInfo(ctx, &v1alpha1.InfoRequest{Version: v1alpha1.Version})
+}
+
+// pluginsWith returns the registered plugins with capability, sorted by name
+func (m *Manager) pluginsWith(capability string) []*plugin {
+	m.RLock()
+	defer m.RUnlock()
+	plugins := make([]*plugin, 0, len(m.plugins))
+	for _, p := range m.plugins {
+		if p.capabilities.Has(capability) {
+			plugins = append(plugins, p)
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		if plugins[i].name != plugins[j].name {
+			return plugins[i].name < plugins[j].name
+		}
+		return plugins[i].socket < plugins[j].socket
+	})
+	return plugins
+}
+
+// WithRequestFilters filters the requests by the request filter plugins in the order of their names,
+// a request is rejected by the first plugin that disallows it, and headers set by plugins are set
+// on the request before it's proxied. a nil Manager filters nothing.
+func (m *Manager) WithRequestFilters(handler http.Handler) http.Handler {
+	if m == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		plugins := m.pluginsWith(v1alpha1.CapabilityRequestFilter)
+		if len(plugins) == 0 {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		filterReq := newFilterRequest(req)
+		for _, p := range plugins {
+			ctx, cancel := context.WithTimeout(req.Context(), filterTimeout)
+			resp, err := p.client.Filter(ctx, filterReq)
+			cancel()
+			if err != nil {
+				if m.failurePolicy == FailurePolicyFail {
+					klog.Errorf("%s is rejected, plugin %s failed, %v", util.ReqString(req), p.name, err)
+					util.Err(util.NewStatusError(http.StatusInternalServerError, util.CauseTypeRejected,
+						fmt.Sprintf("plugin %s of yurthub failed", p.name), 0), w, req)
+					return
+				}
+				klog.Warningf("ignore plugin %s for %s, %v", p.name, util.ReqString(req), err)
+				continue
+			}
+
+			if !resp.Allowed {
+				code := resp.StatusCode
+				if code < http.StatusBadRequest || code > 599 {
+					code = http.StatusForbidden
+				}
+				klog.Infof("%s is rejected by plugin %s with status code %d", util.ReqString(req), p.name, code)
+				util.Err(util.NewStatusError(code, util.CauseTypeRejected, resp.Message, 0), w, req)
+				return
+			}
+			for k, v := range resp.SetHeaders {
+				req.Header.Set(k, v)
+			}
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+func newFilterRequest(req *http.Request) *v1alpha1.FilterRequest {
+	filterReq := &v1alpha1.FilterRequest{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Header: make(map[string][]string, len(req.Header)),
+	}
+	for k, v := range req.Header {
+		// credentials of node agents are not exposed to plugins
+		if k == "Authorization" {
+			continue
+		}
+		filterReq.Header[k] = v
+	}
+	if comp, ok := util.ClientComponentFrom(req.Context()); ok {
+		filterReq.Component = comp
+	}
+	if info, ok := apirequest.RequestInfoFrom(req.Context()); ok && info.IsResourceRequest {
+		filterReq.Verb = info.Verb
+		filterReq.APIGroup = info.APIGroup
+		filterReq.Resource = info.Resource
+		filterReq.Subresource = info.Subresource
+		filterReq.Namespace = info.Namespace
+		filterReq.Name = info.Name
+	}
+	return filterReq
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin/api/v1alpha1"
+
+	"google.golang.org/grpc"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type fakePlugin struct {
+	info    v1alpha1.PluginInfo
+	allowed bool
+}
+
+func (p *fakePlugin) GetInfo(ctx context.Context, req *v1alpha1.InfoRequest) (*v1alpha1.PluginInfo, error) {
+	return &p.info, nil
+}
+
+func (p *fakePlugin) Filter(ctx context.Context, req *v1alpha1.FilterRequest) (*v1alpha1.FilterResponse, error) {
+	if !p.allowed {
+		return &v1alpha1.FilterResponse{StatusCode: http.StatusForbidden, Message: "denied by " + p.info.Name}, nil
+	}
+	return &v1alpha1.FilterResponse{Allowed: true, SetHeaders: map[string]string{"X-Filtered-By": p.info.Name + ":" + req.Verb + "/" + req.Resource}}, nil
+}
+
+// servePlugin serves p on socket, register registers the services of other capabilities of p
+func servePlugin(t *testing.T, socket string, p *fakePlugin, register ...func(s *grpc.Server)) *grpc.Server {
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("failed to listen on %s, %v", socket, err)
+	}
+	s := grpc.NewServer()
+	v1alpha1.RegisterPluginServer(s, p)
+	for _, r := range register {
+		r(s)
+	}
+	go s.Serve(l)
+	return s
+}
+
+func withRequestInfo(ctx context.Context, verb, resource string) context.Context {
+	return apirequest.WithRequestInfo(ctx, &apirequest.RequestInfo{IsResourceRequest: true, Verb: verb, Resource: resource})
+}
+
+func TestManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	audit := &fakePlugin{
+		info:    v1alpha1.PluginInfo{Name: "audit", Version: v1alpha1.Version, Capabilities: []string{v1alpha1.CapabilityRequestFilter}},
+		allowed: true,
+	}
+	auditServer := servePlugin(t, filepath.Join(dir, "audit.sock"), audit)
+	defer auditServer.Stop()
+	oldServer := servePlugin(t, filepath.Join(dir, "old.sock"), &fakePlugin{
+		info: v1alpha1.PluginInfo{Name: "old", Version: "v0", Capabilities: []string{v1alpha1.CapabilityRequestFilter}},
+	})
+	defer oldServer.Stop()
+
+	m := NewManager(dir, FailurePolicyIgnore)
+	m.sync()
+	if plugins := m.pluginsWith(v1alpha1.CapabilityRequestFilter); len(plugins) != 1 || plugins[0].name != "audit" {
+		t.Fatalf("expect plugin audit is registered only, but got %v", plugins)
+	}
+
+	var filtered string
+	handler := m.WithRequestFilters(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		filtered = req.Header.Get("X-Filtered-By")
+	}))
+	serve := func() int {
+		req := httptest.NewRequest("GET", "/api/v1/pods", nil)
+		req = req.WithContext(withRequestInfo(req.Context(), "list", "pods"))
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	if code := serve(); code != http.StatusOK || filtered != "audit:list/pods" {
+		t.Errorf("expect request is allowed and filtered by audit, but got %d(%s)", code, filtered)
+	}
+
+	audit.allowed = false
+	if code := serve(); code != http.StatusForbidden {
+		t.Errorf("expect request is rejected by audit, but got %d", code)
+	}
+
+	// plugin failed, the request is rejected or not by failure policy
+	auditServer.Stop()
+	m.failurePolicy = FailurePolicyFail
+	if code := serve(); code != http.StatusInternalServerError {
+		t.Errorf("expect request is rejected when plugin failed, but got %d", code)
+	}
+	m.failurePolicy = FailurePolicyIgnore
+	if code := serve(); code != http.StatusOK {
+		t.Errorf("expect failed plugin is ignored, but got %d", code)
+	}
+
+	// failed plugin is unregistered, and it's registered again once it's back
+	m.sync()
+	if plugins := m.pluginsWith(v1alpha1.CapabilityRequestFilter); len(plugins) != 0 {
+		t.Fatalf("expect failed plugin is unregistered, but got %v", plugins)
+	}
+	os.Remove(filepath.Join(dir, "audit.sock"))
+	audit.allowed = true
+	auditServer = servePlugin(t, filepath.Join(dir, "audit.sock"), audit)
+	defer auditServer.Stop()
+	m.sync()
+	if code := serve(); code != http.StatusOK || filtered != "audit:list/pods" {
+		t.Errorf("expect plugin is registered again, but got %d(%s)", code, filtered)
+	}
+}
+
+func TestNilManager(t *testing.T) {
+	var m *Manager
+	served := false
+	m.WithRequestFilters(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		served = true
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/pods", nil))
+	if !served {
+		t.Errorf("expect request is served without plugins")
+	}
+}
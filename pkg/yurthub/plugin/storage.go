@@ -0,0 +1,132 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin/api/v1alpha1"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"k8s.io/klog"
+)
+
+// storageErrors are the errors of storage.Store for the errors in responses of storage plugins
+var storageErrors = map[string]error{
+	v1alpha1.StorageErrorNotFound:       storage.ErrNotFound,
+	v1alpha1.StorageErrorKeyIsDir:       storage.ErrKeyIsDir,
+	v1alpha1.StorageErrorInvalidKey:     storage.ErrInvalidKey,
+	v1alpha1.StorageErrorProtectedKey:   storage.ErrProtectedKey,
+	v1alpha1.StorageErrorExceedQuota:    storage.ErrExceedQuota,
+	v1alpha1.StorageErrorCorrupted:      storage.ErrCorrupted,
+	v1alpha1.StorageErrorAccessConflict: storage.ErrStorageAccessConflict,
+}
+
+// pluginStorage stores cache in a storage plugin, unlike the plugins in the plugin dir, it's
+// connected once when yurthub starts, as the cache can't be switched to another storage at runtime.
+type pluginStorage struct {
+	name   string
+	client v1alpha1.StorageClient
+}
+
+// NewStore connects the storage plugin on socket, and creates a storage.Store that stores cache in it.
+// the plugin must serve the same plugin api version as yurthub with capability Storage, and it
+// validates the keys itself, only the protected keys are checked before they are sent to the plugin.
+func NewStore(socket string) (storage.Store, error) {
+	p, err := connect(socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect storage plugin %s, %v", socket, err)
+	}
+	if !p.capabilities.Has(v1alpha1.CapabilityStorage) {
+		p.conn.Close()
+		return nil, fmt.Errorf("plugin %s(%s) doesn't have capability %s", p.name, socket, v1alpha1.CapabilityStorage)
+	}
+
+	klog.Infof("cache is stored in plugin %s(%s)", p.name, socket)
+	return &pluginStorage{
+		name:   p.name,
+		client: v1alpha1.NewStorageClient(p.conn),
+	}, nil
+}
+
+func (ps *pluginStorage) call(ctx context.Context, method string, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	resp, err := ps.client.Call(ctx, method, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("storage plugin %s failed to %s %s, %v", ps.name, method, req.Key, err)
+	}
+	if resp.Error != "" {
+		if err, ok := storageErrors[resp.Error]; ok {
+			return nil, err
+		}
+		return nil, fmt.Errorf("storage plugin %s failed to %s %s, %s: %s", ps.name, method, req.Key, resp.Error, resp.Message)
+	}
+	return resp, nil
+}
+
+// Create writes contents of key
+func (ps *pluginStorage) Create(ctx context.Context, key string, contents []byte) error {
+	_, err := ps.call(ctx, "Create", &v1alpha1.StorageRequest{Key: key, Contents: contents})
+	return err
+}
+
+// Delete deletes the content of key
+func (ps *pluginStorage) Delete(ctx context.Context, key string) error {
+	_, err := ps.call(ctx, "Delete", &v1alpha1.StorageRequest{Key: key})
+	return err
+}
+
+// DeleteCollection deletes all keys under key
+func (ps *pluginStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+	_, err := ps.call(ctx, "DeleteCollection", &v1alpha1.StorageRequest{Key: key, Force: force})
+	return err
+}
+
+// Get returns the content of key
+func (ps *pluginStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := ps.call(ctx, "Get", &v1alpha1.StorageRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Contents) != 1 {
+		return nil, fmt.Errorf("storage plugin %s returned %d contents for key %s", ps.name, len(resp.Contents), key)
+	}
+	return resp.Contents[0], nil
+}
+
+// ListKeys returns the keys under key
+func (ps *pluginStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
+	resp, err := ps.call(ctx, "ListKeys", &v1alpha1.StorageRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Keys, nil
+}
+
+// List returns the contents under key
+func (ps *pluginStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	resp, err := ps.call(ctx, "List", &v1alpha1.StorageRequest{Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Contents, nil
+}
+
+// Update replaces contents of key
+func (ps *pluginStorage) Update(ctx context.Context, key string, contents []byte) error {
+	_, err := ps.call(ctx, "Update", &v1alpha1.StorageRequest{Key: key, Contents: contents})
+	return err
+}
+
+// Replace replaces all keys under rootKey with contents
+func (ps *pluginStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+	_, err := ps.call(ctx, "Replace", &v1alpha1.StorageRequest{Key: rootKey, Objects: contents})
+	return err
+}
@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin/api/v1alpha1"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+
+	"google.golang.org/grpc"
+)
+
+// fakeStoragePlugin serves a storage.Store as a storage plugin
+type fakeStoragePlugin struct {
+	fakePlugin
+	store storage.Store
+}
+
+func (p *fakeStoragePlugin) respond(contents [][]byte, keys []string, err error) (*v1alpha1.StorageResponse, error) {
+	if err != nil {
+		for name, storageErr := range storageErrors {
+			if err == storageErr {
+				return &v1alpha1.StorageResponse{Error: name, Message: err.Error()}, nil
+			}
+		}
+		return nil, err
+	}
+	return &v1alpha1.StorageResponse{Contents: contents, Keys: keys}, nil
+}
+
+func (p *fakeStoragePlugin) Create(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	return p.respond(nil, nil, p.store.Create(ctx, req.Key, req.Contents))
+}
+
+func (p *fakeStoragePlugin) Delete(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	return p.respond(nil, nil, p.store.Delete(ctx, req.Key))
+}
+
+func (p *fakeStoragePlugin) DeleteCollection(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	return p.respond(nil, nil, p.store.DeleteCollection(ctx, req.Key, req.Force))
+}
+
+func (p *fakeStoragePlugin) Get(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	b, err := p.store.Get(ctx, req.Key)
+	return p.respond([][]byte{b}, nil, err)
+}
+
+func (p *fakeStoragePlugin) ListKeys(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	keys, err := p.store.ListKeys(ctx, req.Key)
+	return p.respond(nil, keys, err)
+}
+
+func (p *fakeStoragePlugin) List(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	contents, err := p.store.List(ctx, req.Key)
+	return p.respond(contents, nil, err)
+}
+
+func (p *fakeStoragePlugin) Update(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	return p.respond(nil, nil, p.store.Update(ctx, req.Key, req.Contents))
+}
+
+func (p *fakeStoragePlugin) Replace(ctx context.Context, req *v1alpha1.StorageRequest) (*v1alpha1.StorageResponse, error) {
+	return p.respond(nil, nil, p.store.Replace(ctx, req.Key, req.Objects))
+}
+
+func TestPluginStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugins")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	backend, _ := memory.NewMemoryStorage(0)
+	p := &fakeStoragePlugin{
+		fakePlugin: fakePlugin{info: v1alpha1.PluginInfo{Name: "kv", Version: v1alpha1.Version, Capabilities: []string{v1alpha1.CapabilityStorage}}},
+		store:      backend,
+	}
+	socket := filepath.Join(dir, "kv.sock")
+	s := servePlugin(t, socket, &p.fakePlugin, func(s *grpc.Server) {
+		v1alpha1.RegisterStorageServer(s, p)
+	})
+	defer s.Stop()
+
+	filterSocket := filepath.Join(dir, "audit.sock")
+	filterServer := servePlugin(t, filterSocket, &fakePlugin{
+		info: v1alpha1.PluginInfo{Name: "audit", Version: v1alpha1.Version, Capabilities: []string{v1alpha1.CapabilityRequestFilter}},
+	})
+	defer filterServer.Stop()
+	if _, err := NewStore(filterSocket); err == nil {
+		t.Errorf("expect plugin without capability %s is rejected", v1alpha1.CapabilityStorage)
+	}
+
+	store, err := NewStore(socket)
+	if err != nil {
+		t.Fatalf("failed to create plugin storage, %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Create(ctx, "kubelet/pods/default/pod1", []byte("pod1")); err != nil {
+		t.Fatalf("failed to create key, %v", err)
+	}
+	if err := store.Update(ctx, "kubelet/pods/default/pod2", []byte("pod2")); err != nil {
+		t.Fatalf("failed to update key, %v", err)
+	}
+	if b, err := store.Get(ctx, "kubelet/pods/default/pod1"); err != nil || string(b) != "pod1" {
+		t.Errorf("expect pod1, but got %s, %v", string(b), err)
+	}
+	if _, err := store.Get(ctx, "kubelet/pods/default/pod3"); err != storage.ErrNotFound {
+		t.Errorf("expect %v, but got %v", storage.ErrNotFound, err)
+	}
+	if keys, err := store.ListKeys(ctx, "kubelet/pods"); err != nil || !reflect.DeepEqual(keys, []string{"kubelet/pods/default/pod1", "kubelet/pods/default/pod2"}) {
+		t.Errorf("expect keys of pod1 and pod2, but got %v, %v", keys, err)
+	}
+
+	if err := store.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/pods/default/pod3": []byte("pod3")}); err != nil {
+		t.Fatalf("failed to replace keys, %v", err)
+	}
+	if contents, err := store.List(ctx, "kubelet/pods"); err != nil || len(contents) != 1 || string(contents[0]) != "pod3" {
+		t.Errorf("expect contents of pod3, but got %v, %v", contents, err)
+	}
+
+	if err := store.DeleteCollection(ctx, "kubelet", false); err != storage.ErrProtectedKey {
+		t.Errorf("expect %v, but got %v", storage.ErrProtectedKey, err)
+	}
+	if err := store.Delete(ctx, "kubelet/pods/default/pod3"); err != nil {
+		t.Fatalf("failed to delete key, %v", err)
+	}
+	if keys, err := store.ListKeys(ctx, "kubelet"); err != nil || len(keys) != 0 {
+		t.Errorf("expect no keys, but got %v, %v", keys, err)
+	}
+}
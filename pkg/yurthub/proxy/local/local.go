@@ -10,11 +10,14 @@ import (
 	"time"
 
 	manager "github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
 
 	"k8s.io/apimachinery/pkg/api/errors"
 	metainternalversion "k8s.io/apimachinery/pkg/apis/meta/internalversion"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog"
 )
@@ -43,6 +46,14 @@ func (lp *LocalProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	if reqInfo, ok := apirequest.RequestInfoFrom(ctx); ok && reqInfo != nil && reqInfo.IsResourceRequest {
 		klog.V(3).Infof("go into local proxy for request %s", util.ReqString(req))
+		if manager.IsExtensionAPI(reqInfo) && !lp.cacheMgr.CanCacheFor(req) {
+			// aggregated api is passthrough only, so it's unavailable when cluster is unhealthy
+			err = fmt.Errorf("aggregated api %s/%s is not available when cluster is unhealthy", reqInfo.APIGroup, reqInfo.APIVersion)
+			klog.Errorf("could not proxy local for %s, %v", util.ReqString(req), err)
+			util.Err(util.NewRemoteUnavailable(err.Error()), w, req)
+			return
+		}
+
 		switch reqInfo.Verb {
 		case "watch":
 			err = lp.LocalWatch(w, req)
@@ -56,7 +67,10 @@ func (lp *LocalProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 		if err != nil {
 			klog.Errorf("could not proxy local for %s, %v", util.ReqString(req), err)
-			util.Err(errors.NewBadRequest(err.Error()), w, req)
+			if _, ok := err.(errors.APIStatus); !ok {
+				err = util.NewNotCached(err.Error())
+			}
+			util.Err(err, w, req)
 			return
 		}
 		return
@@ -64,7 +78,7 @@ func (lp *LocalProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	err = fmt.Errorf("request(%s) is not supported when cluster is unhealthy", util.ReqString(req))
 	klog.Errorf("%v", err)
-	util.Err(errors.NewBadRequest(err.Error()), w, req)
+	util.Err(util.NewNotCached(err.Error()), w, req)
 }
 
 func localDelete(w http.ResponseWriter, req *http.Request) error {
@@ -179,17 +193,44 @@ func (lp *LocalProxy) LocalReqCache(w http.ResponseWriter, req *http.Request) er
 	}
 
 	obj, err := lp.cacheMgr.QueryCache(req)
-	if err != nil || obj == nil {
-		klog.Errorf("failed to query cache for %s, %v", util.ReqString(req), err)
-		err = fmt.Errorf("failed to query cache for %s, %v", util.ReqString(req), err)
-		util.Err(errors.NewBadRequest(err.Error()), w, req)
-		return err
+	if err != nil {
+		info, _ := apirequest.RequestInfoFrom(req.Context())
+		return cacheStatusError(err, info)
+	} else if obj == nil {
+		return fmt.Errorf("failed to query cache for %s, object is nil", util.ReqString(req))
 	}
 
-	util.WriteObject(http.StatusOK, obj, w, req)
+	if raw, ok := obj.(*runtime.Unknown); ok {
+		// raw response of aggregated api is written back as it is
+		w.Header().Set("Content-Type", raw.ContentType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(raw.Raw); err != nil {
+			klog.Errorf("failed to write raw response for %s, %v", util.ReqString(req), err)
+		}
+		return nil
+	}
+
+	util.WriteObject(http.StatusOK, withEdgePriority(obj), w, req)
 	return nil
 }
 
+// cacheStatusError converts the error of querying cache into the status error of api,
+// so clients can tell a missing object from a broken cache.
+func cacheStatusError(err error, info *apirequest.RequestInfo) error {
+	switch err {
+	case storage.ErrNotFound:
+		return errors.NewNotFound(schema.GroupResource{Group: info.APIGroup, Resource: info.Resource}, info.Name)
+	case storage.ErrStorageAccessConflict:
+		return errors.NewTooManyRequests(fmt.Sprintf("cache of %s is under accessing", info.Path), 1)
+	case context.DeadlineExceeded:
+		return errors.NewTimeoutError(fmt.Sprintf("query cache of %s timeout", info.Path), 1)
+	case storage.ErrCorrupted, storage.ErrKeyIsDir, storage.ErrExceedQuota:
+		return errors.NewInternalError(fmt.Errorf("failed to query cache of %s, %v", info.Path, err))
+	default:
+		return fmt.Errorf("failed to query cache of %s, %v", info.Path, err)
+	}
+}
+
 func copyHeader(dst, src http.Header) {
 	for k, vv := range src {
 		if k == "Content-Type" || k == "Content-Length" {
@@ -2,6 +2,8 @@ package local
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -12,7 +14,10 @@ import (
 	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
 	proxyutil "github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	ystorage "github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -31,7 +36,7 @@ func newTestRequestInfoResolver() *request.RequestInfoFactory {
 func TestServeHTTPForWatch(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	fn := func() bool {
 		return false
@@ -118,7 +123,7 @@ func TestServeHTTPForWatch(t *testing.T) {
 func TestServeHTTPForWatchWithHealthyChange(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	cnt := 0
 	fn := func() bool {
@@ -201,7 +206,7 @@ func TestServeHTTPForWatchWithHealthyChange(t *testing.T) {
 func TestServeHTTPForPost(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	fn := func() bool {
 		return false
@@ -275,7 +280,7 @@ func TestServeHTTPForPost(t *testing.T) {
 func TestServeHTTPForDelete(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	fn := func() bool {
 		return false
@@ -336,7 +341,7 @@ func TestServeHTTPForDelete(t *testing.T) {
 func TestServeHTTPForGetReqCache(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	fn := func() bool {
 		return false
@@ -399,7 +404,7 @@ func TestServeHTTPForGetReqCache(t *testing.T) {
 			for i := range tt.inputObj {
 				name, _ := accessor.Name(tt.inputObj[i])
 				key := filepath.Join(tt.keyPrefix, name)
-				_ = storage.Update(key, tt.inputObj[i])
+				_ = storage.Update(context.Background(), key, tt.inputObj[i])
 			}
 
 			req, _ := http.NewRequest(tt.verb, tt.path, nil)
@@ -461,7 +466,7 @@ func TestServeHTTPForGetReqCache(t *testing.T) {
 func TestServeHTTPForListReqCache(t *testing.T) {
 	storage := cachemanager.NewFakeStorageWrapper()
 	serializerM := serializer.NewSerializerManager()
-	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM)
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
 
 	fn := func() bool {
 		return false
@@ -548,7 +553,7 @@ func TestServeHTTPForListReqCache(t *testing.T) {
 			for i := range tt.inputObj {
 				name, _ := accessor.Name(tt.inputObj[i])
 				key := filepath.Join(tt.keyPrefix, name)
-				_ = storage.Update(key, tt.inputObj[i])
+				_ = storage.Update(context.Background(), key, tt.inputObj[i])
 			}
 
 			req, _ := http.NewRequest(tt.verb, tt.path, nil)
@@ -609,3 +614,175 @@ func TestServeHTTPForListReqCache(t *testing.T) {
 		})
 	}
 }
+
+func TestServeHTTPForUnavailableRequests(t *testing.T) {
+	storage := cachemanager.NewFakeStorageWrapper()
+	serializerM := serializer.NewSerializerManager()
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
+
+	fn := func() bool {
+		return false
+	}
+
+	lp := NewLocalProxy(cacheM, fn)
+
+	testcases := []struct {
+		desc  string
+		verb  string
+		path  string
+		code  int
+		cause metav1.CauseType
+	}{
+		{
+			desc:  "list aggregated api",
+			verb:  "GET",
+			path:  "/apis/metrics.k8s.io/v1beta1/nodes",
+			code:  http.StatusServiceUnavailable,
+			cause: util.CauseTypeRemoteUnavailable,
+		},
+		{
+			desc:  "create aggregated api",
+			verb:  "POST",
+			path:  "/apis/custom.metrics.k8s.io/v1beta1/namespaces/default/pods",
+			code:  http.StatusServiceUnavailable,
+			cause: util.CauseTypeRemoteUnavailable,
+		},
+		{
+			desc:  "get non-resource url",
+			verb:  "GET",
+			path:  "/version",
+			code:  http.StatusServiceUnavailable,
+			cause: util.CauseTypeNotCached,
+		},
+	}
+
+	resolver := newTestRequestInfoResolver()
+	for _, tt := range testcases {
+		t.Run(tt.desc, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.verb, tt.path, nil)
+			req.Header.Set("Accept", "application/json")
+			req.Header.Set("User-Agent", "kubelet")
+			req.RemoteAddr = "127.0.0.1"
+
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				lp.ServeHTTP(w, req)
+			})
+
+			handler = proxyutil.WithRequestClientComponent(handler)
+			handler = proxyutil.WithRequestContentType(handler)
+			handler = filters.WithRequestInfo(handler, resolver)
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			result := resp.Result()
+			if result.StatusCode != tt.code {
+				t.Errorf("got status code %d, but expect %d", result.StatusCode, tt.code)
+			}
+			if result.Header.Get("Retry-After") != "1" {
+				t.Errorf("expect Retry-After 1, but got %q", result.Header.Get("Retry-After"))
+			}
+			status := &metav1.Status{}
+			if err := json.Unmarshal(resp.Body.Bytes(), status); err != nil {
+				t.Fatalf("failed to decode status %s, %v", resp.Body.String(), err)
+			}
+			if status.Details == nil || len(status.Details.Causes) == 0 || status.Details.Causes[0].Type != tt.cause {
+				t.Errorf("expect cause %s, but got %#v", tt.cause, status.Details)
+			}
+		})
+	}
+}
+
+func TestServeHTTPForGetReqCacheNotFound(t *testing.T) {
+	storage := cachemanager.NewFakeStorageWrapper()
+	serializerM := serializer.NewSerializerManager()
+	cacheM, _ := cachemanager.NewCacheManager(storage, serializerM, nil, 0)
+
+	fn := func() bool {
+		return false
+	}
+
+	lp := NewLocalProxy(cacheM, fn)
+
+	req, _ := http.NewRequest("GET", "/api/v1/namespaces/default/pods/mypod", nil)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "kubelet")
+	req.RemoteAddr = "127.0.0.1"
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lp.ServeHTTP(w, req)
+	})
+
+	handler = proxyutil.WithRequestClientComponent(handler)
+	handler = proxyutil.WithRequestContentType(handler)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	result := resp.Result()
+	if result.StatusCode != http.StatusNotFound {
+		t.Errorf("got status code %d, but expect %d", result.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestCacheStatusError(t *testing.T) {
+	info := &request.RequestInfo{
+		IsResourceRequest: true,
+		Path:              "/api/v1/namespaces/default/pods/mypod",
+		Verb:              "get",
+		APIVersion:        "v1",
+		Resource:          "pods",
+		Namespace:         "default",
+		Name:              "mypod",
+	}
+
+	testcases := map[string]struct {
+		err  error
+		code int32
+	}{
+		"not found": {
+			err:  ystorage.ErrNotFound,
+			code: http.StatusNotFound,
+		},
+		"access conflict": {
+			err:  ystorage.ErrStorageAccessConflict,
+			code: http.StatusTooManyRequests,
+		},
+		"timeout": {
+			err:  context.DeadlineExceeded,
+			code: http.StatusGatewayTimeout,
+		},
+		"corrupted": {
+			err:  ystorage.ErrCorrupted,
+			code: http.StatusInternalServerError,
+		},
+		"key is dir": {
+			err:  ystorage.ErrKeyIsDir,
+			code: http.StatusInternalServerError,
+		},
+		"other error": {
+			err:  fmt.Errorf("unknown error"),
+			code: 0,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			err := cacheStatusError(tt.err, info)
+			status, ok := err.(errors.APIStatus)
+			if tt.code == 0 {
+				if ok {
+					t.Errorf("expect no status error, but got %v", status.Status())
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("expect status error, but got %v", err)
+			}
+
+			if status.Status().Code != tt.code {
+				t.Errorf("expect status code %d, but got %d", tt.code, status.Status().Code)
+			}
+		})
+	}
+}
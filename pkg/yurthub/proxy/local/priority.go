@@ -0,0 +1,56 @@
+package local
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+)
+
+// AnnotationEdgePriority is the annotation of pod for the priority that kubelet ranks the pod by
+// when it evicts pods under resource pressure while the node is offline, it's annotated by the
+// edge priority controller of yurt-controller-manager.
+const AnnotationEdgePriority = "openyurt.io/edge-priority"
+
+// withEdgePriority sets the edge priority of pods in obj as their priority, so kubelet evicts the
+// pods of low edge priority first and keeps the agents needed for reconnection under resource
+// pressure while the node is offline. pods are served from cache only while the node is offline,
+// and kubelet gets the priority in cloud again after the node is reconnected.
+func withEdgePriority(obj runtime.Object) runtime.Object {
+	switch o := obj.(type) {
+	case *v1.Pod:
+		if priority, ok := edgePriorityOf(o); ok {
+			o = o.DeepCopy()
+			o.Spec.Priority = &priority
+			return o
+		}
+	case *v1.PodList:
+		var list *v1.PodList
+		for i := range o.Items {
+			if priority, ok := edgePriorityOf(&o.Items[i]); ok {
+				if list == nil {
+					list = o.DeepCopy()
+				}
+				list.Items[i].Spec.Priority = &priority
+			}
+		}
+		if list != nil {
+			return list
+		}
+	}
+	return obj
+}
+
+func edgePriorityOf(pod *v1.Pod) (int32, bool) {
+	value, ok := pod.Annotations[AnnotationEdgePriority]
+	if !ok {
+		return 0, false
+	}
+	priority, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		klog.Warningf("edge priority(%s) of pod %s/%s is invalid, %v", value, pod.Namespace, pod.Name, err)
+		return 0, false
+	}
+	return int32(priority), true
+}
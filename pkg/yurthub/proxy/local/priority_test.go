@@ -0,0 +1,75 @@
+package local
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newPriorityPod(name, edgePriority string, priority int32) v1.Pod {
+	pod := v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       v1.PodSpec{Priority: &priority},
+	}
+	if len(edgePriority) != 0 {
+		pod.Annotations = map[string]string{AnnotationEdgePriority: edgePriority}
+	}
+	return pod
+}
+
+func TestWithEdgePriority(t *testing.T) {
+	agent := newPriorityPod("agent", "2000000000", 0)
+	app := newPriorityPod("app", "-100", 0)
+	plain := newPriorityPod("plain", "", 10)
+	invalid := newPriorityPod("invalid", "high", 10)
+
+	testcases := map[string]struct {
+		obj      runtime.Object
+		expected []int32
+	}{
+		"pod with edge priority": {
+			obj:      &agent,
+			expected: []int32{2000000000},
+		},
+		"pod without edge priority": {
+			obj:      &plain,
+			expected: []int32{10},
+		},
+		"pod with invalid edge priority": {
+			obj:      &invalid,
+			expected: []int32{10},
+		},
+		"pod list": {
+			obj:      &v1.PodList{Items: []v1.Pod{agent, app, plain, invalid}},
+			expected: []int32{2000000000, -100, 10, 10},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			original := tt.obj.DeepCopyObject()
+			var pods []v1.Pod
+			switch o := withEdgePriority(tt.obj).(type) {
+			case *v1.Pod:
+				pods = []v1.Pod{*o}
+			case *v1.PodList:
+				pods = o.Items
+			}
+			if len(pods) != len(tt.expected) {
+				t.Fatalf("expect %d pods, but got %d", len(tt.expected), len(pods))
+			}
+			for i, pod := range pods {
+				if *pod.Spec.Priority != tt.expected[i] {
+					t.Errorf("expect priority %d of pod %s, but got %d", tt.expected[i], pod.Name, *pod.Spec.Priority)
+				}
+			}
+			// the object in cache is not changed
+			if !apiequality.Semantic.DeepEqual(original, tt.obj) {
+				t.Errorf("object in cache is changed")
+			}
+		})
+	}
+}
@@ -1,38 +1,66 @@
 package proxy
 
 import (
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
 	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
+	"github.com/alibaba/openyurt/pkg/yurthub/dedup"
+	"github.com/alibaba/openyurt/pkg/yurthub/discovery"
 	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/leasebatch"
+	"github.com/alibaba/openyurt/pkg/yurthub/metricsshim"
+	"github.com/alibaba/openyurt/pkg/yurthub/mirror"
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin"
 	"github.com/alibaba/openyurt/pkg/yurthub/proxy/local"
 	"github.com/alibaba/openyurt/pkg/yurthub/proxy/remote"
 	"github.com/alibaba/openyurt/pkg/yurthub/proxy/util"
+	"github.com/alibaba/openyurt/pkg/yurthub/reviewcache"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+	hubutil "github.com/alibaba/openyurt/pkg/yurthub/util"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/endpoints/filters"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/apiserver/pkg/server"
+	genericfilters "k8s.io/apiserver/pkg/server/filters"
+	"k8s.io/klog"
 )
 
 type yurtReverseProxy struct {
-	resolver            apirequest.RequestInfoResolver
-	loadBalancer        remote.LoadBalancer
-	localProxy          *local.LocalProxy
-	cacheMgr            cachemanager.CacheManager
-	maxRequestsInFlight int
-	stopCh              <-chan struct{}
+	resolver     apirequest.RequestInfoResolver
+	loadBalancer remote.LoadBalancer
+	localProxy   *local.LocalProxy
+	cacheMgr     cachemanager.CacheManager
+	metricsShim  *metricsshim.MetricsShim
+	limiter      *util.RequestLimiter
+	longRunning  apirequest.LongRunningRequestCheck
+	timeouts     *util.RequestTimeouts
+	plugins      *plugin.Manager
+	reviewCache  *reviewcache.Cache
+	mirror       *mirror.Mirror
+	// upstream sends requests to kube-apiserver through loadBalancer, with lease updates batched
+	upstream http.Handler
+	// serveCacheWhenThrottled serves gets and lists from cache while they are throttled
+	serveCacheWhenThrottled bool
+	stopCh                  <-chan struct{}
 }
 
 func NewYurtReverseProxyHandler(
 	yurtHubCfg *config.YurtHubConfiguration,
 	cacheMgr cachemanager.CacheManager,
+	store storage.Store,
 	transportMgr transport.Interface,
 	healthChecker healthchecker.HealthChecker,
 	certManager interfaces.YurtCertificateManager,
+	limiter *util.RequestLimiter,
+	discoverer *discovery.Discoverer,
 	stopCh <-chan struct{}) (http.Handler, error) {
 	cfg := &server.Config{
 		LegacyAPIGroupPrefixes: sets.NewString(server.DefaultLegacyAPIPrefix),
@@ -50,23 +78,77 @@ func NewYurtReverseProxyHandler(
 	if err != nil {
 		return nil, err
 	}
+	if discoverer != nil {
+		discoverer.Run(lb.UpdateRemoteServers)
+	}
+
+	timeouts, err := util.ParseRequestTimeouts(yurtHubCfg.RequestTimeouts, time.Duration(yurtHubCfg.RequestTimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
 
+	util.Register()
 	yurtProxy := &yurtReverseProxy{
-		resolver:            resolver,
-		loadBalancer:        lb,
-		localProxy:          local.NewLocalProxy(cacheMgr, lb.IsHealthy),
-		cacheMgr:            cacheMgr,
-		maxRequestsInFlight: yurtHubCfg.MaxRequestInFlight,
-		stopCh:              stopCh,
+		resolver:                resolver,
+		loadBalancer:            lb,
+		localProxy:              local.NewLocalProxy(cacheMgr, lb.IsHealthy),
+		cacheMgr:                cacheMgr,
+		limiter:                 limiter,
+		longRunning:             genericfilters.BasicLongRunningRequestCheck(sets.NewString(yurtHubCfg.LongRunningVerbs...), sets.NewString(yurtHubCfg.LongRunningSubresources...)),
+		timeouts:                timeouts,
+		serveCacheWhenThrottled: yurtHubCfg.ServeCacheWhenThrottled,
+		stopCh:                  stopCh,
+	}
+
+	if yurtHubCfg.PluginDir != "" {
+		yurtProxy.plugins = plugin.NewManager(yurtHubCfg.PluginDir, yurtHubCfg.PluginFailurePolicy)
+		yurtProxy.plugins.Run(stopCh)
+	}
+
+	if yurtHubCfg.ReviewCacheSize > 0 && yurtHubCfg.ReviewCacheTTLSeconds > 0 {
+		yurtProxy.reviewCache = reviewcache.NewCache(yurtHubCfg.ReviewCacheSize,
+			time.Duration(yurtHubCfg.ReviewCacheTTLSeconds)*time.Second,
+			time.Duration(yurtHubCfg.ReviewCacheMaxStaleSeconds)*time.Second)
+	}
+
+	var batcher *leasebatch.Batcher
+	if yurtHubCfg.EnableLeaseBatching {
+		batcher = leasebatch.NewBatcher(time.Duration(yurtHubCfg.LeaseMinRenewSeconds) * time.Second)
+	}
+	var deduplicator *dedup.Deduplicator
+	if yurtHubCfg.WriteDedupSize > 0 && yurtHubCfg.WriteDedupTTLSeconds > 0 {
+		deduplicator = dedup.NewDeduplicator(yurtHubCfg.WriteDedupSize, time.Duration(yurtHubCfg.WriteDedupTTLSeconds)*time.Second)
+	}
+	yurtProxy.upstream = deduplicator.WithWriteDedup(batcher.WithLeaseBatching(lb))
+
+	if yurtHubCfg.MirrorKubeconfig != "" {
+		yurtProxy.mirror, err = mirror.NewMirror(yurtHubCfg.MirrorKubeconfig, yurtHubCfg.MirrorSamplePercent)
+		if err != nil {
+			return nil, err
+		}
+		mirror.Register()
+	}
+
+	if yurtHubCfg.EnableMetricsShim {
+		yurtProxy.metricsShim = metricsshim.NewMetricsShim(cacheMgr, lb.IsHealthy)
 	}
 
 	return yurtProxy.buildHandlerChain(yurtProxy), nil
 }
 
 func (p *yurtReverseProxy) buildHandlerChain(apiHandler http.Handler) http.Handler {
-	handler := util.WithRequestContentType(apiHandler)
+	handler := apiHandler
+	if p.metricsShim != nil {
+		handler = p.metricsShim.WithMetricsShim(handler)
+	}
+	handler = util.WithRequestContentType(handler)
 	handler = util.WithCacheHeaderCheck(handler)
-	handler = util.WithRequestTrace(handler, p.maxRequestsInFlight)
+	handler = util.WithPartialListCheck(handler)
+	handler = p.reviewCache.WithReviewCache(handler)
+	handler = p.mirror.WithMirroring(handler)
+	handler = util.WithRequestTimeout(handler, p.longRunning, p.timeouts)
+	handler = util.WithRequestTrace(handler, p.limiter, p.longRunning)
+	handler = p.plugins.WithRequestFilters(handler)
 	handler = util.WithRequestClientComponent(handler)
 	handler = filters.WithRequestInfo(handler, p.resolver)
 	return handler
@@ -74,8 +156,28 @@ func (p *yurtReverseProxy) buildHandlerChain(apiHandler http.Handler) http.Handl
 
 func (p *yurtReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	if p.loadBalancer.IsHealthy() {
-		p.loadBalancer.ServeHTTP(rw, req)
+		if d := p.loadBalancer.ThrottledFor(req); d > 0 {
+			p.serveThrottled(rw, req, d)
+			return
+		}
+		p.upstream.ServeHTTP(rw, req)
 	} else {
 		p.localProxy.ServeHTTP(rw, req)
 	}
 }
+
+// serveThrottled serves the gets and lists from cache if it's enabled while the component
+// of request is throttled by kube-apiserver, and rejects the other requests with Retry-After,
+// so the client backs off without reaching kube-apiserver.
+func (p *yurtReverseProxy) serveThrottled(rw http.ResponseWriter, req *http.Request, d time.Duration) {
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	if ok && p.serveCacheWhenThrottled && info.IsResourceRequest && (info.Verb == "get" || info.Verb == "list") && p.cacheMgr.CanCacheFor(req) {
+		klog.V(2).Infof("serve %s from cache, it's throttled by kube-apiserver for %v", hubutil.ReqString(req), d)
+		p.localProxy.ServeHTTP(rw, req)
+		return
+	}
+
+	seconds := int(math.Ceil(d.Seconds()))
+	rw.Header().Set("Retry-After", strconv.Itoa(seconds))
+	hubutil.Err(apierrors.NewTooManyRequests("requests are throttled by kube-apiserver, please try again later", seconds), rw, req)
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
@@ -17,6 +19,7 @@ import (
 type loadBalancerAlgo interface {
 	PickOne() *RemoteProxy
 	Name() string
+	SetBackends(backends []*RemoteProxy)
 }
 
 type rrLoadBalancerAlgo struct {
@@ -29,7 +32,16 @@ func (rr *rrLoadBalancerAlgo) Name() string {
 	return "rr algorithm"
 }
 
+func (rr *rrLoadBalancerAlgo) SetBackends(backends []*RemoteProxy) {
+	rr.Lock()
+	defer rr.Unlock()
+	rr.backends = backends
+	rr.next = 0
+}
+
 func (rr *rrLoadBalancerAlgo) PickOne() *RemoteProxy {
+	rr.Lock()
+	defer rr.Unlock()
 	if len(rr.backends) == 0 {
 		return nil
 	} else if len(rr.backends) == 1 {
@@ -39,8 +51,6 @@ func (rr *rrLoadBalancerAlgo) PickOne() *RemoteProxy {
 		return nil
 	} else {
 		// round robin
-		rr.Lock()
-		defer rr.Unlock()
 		hasFound := false
 		selected := rr.next
 		for i := 0; i < len(rr.backends); i++ {
@@ -69,7 +79,15 @@ func (prio *priorityLoadBalancerAlgo) Name() string {
 	return "priority algorithm"
 }
 
+func (prio *priorityLoadBalancerAlgo) SetBackends(backends []*RemoteProxy) {
+	prio.Lock()
+	defer prio.Unlock()
+	prio.backends = backends
+}
+
 func (prio *priorityLoadBalancerAlgo) PickOne() *RemoteProxy {
+	prio.Lock()
+	defer prio.Unlock()
 	if len(prio.backends) == 0 {
 		return nil
 	} else if len(prio.backends) == 1 {
@@ -78,8 +96,6 @@ func (prio *priorityLoadBalancerAlgo) PickOne() *RemoteProxy {
 		}
 		return nil
 	} else {
-		prio.Lock()
-		defer prio.Unlock()
 		for i := 0; i < len(prio.backends); i++ {
 			if prio.backends[i].IsHealthy() {
 				return prio.backends[i]
@@ -92,13 +108,26 @@ func (prio *priorityLoadBalancerAlgo) PickOne() *RemoteProxy {
 
 type LoadBalancer interface {
 	IsHealthy() bool
+	// ThrottledFor returns the remaining backoff of the component of req after its requests are
+	// throttled by kube-apiserver, 0 if it's not throttled.
+	ThrottledFor(req *http.Request) time.Duration
 	ServeHTTP(rw http.ResponseWriter, req *http.Request)
+	// UpdateRemoteServers replaces the remote servers discovered at runtime with servers,
+	// the remote servers specified statically are always kept.
+	UpdateRemoteServers(servers []*url.URL)
 }
 
 type loadBalancer struct {
-	backends    []*RemoteProxy
-	algo        loadBalancerAlgo
-	certManager interfaces.YurtCertificateManager
+	sync.RWMutex
+	remoteServers []*url.URL
+	backends      []*RemoteProxy
+	algo          loadBalancerAlgo
+	certManager   interfaces.YurtCertificateManager
+	throttler     *Throttler
+	cacheMgr      cachemanager.CacheManager
+	transportMgr  transport.Interface
+	healthChecker healthchecker.HealthChecker
+	stopCh        <-chan struct{}
 }
 
 func NewLoadBalancer(
@@ -109,9 +138,10 @@ func NewLoadBalancer(
 	healthChecker healthchecker.HealthChecker,
 	certManager interfaces.YurtCertificateManager,
 	stopCh <-chan struct{}) (LoadBalancer, error) {
+	throttler := NewThrottler()
 	backends := make([]*RemoteProxy, 0)
 	for i := range remoteServers {
-		b, err := NewRemoteProxy(remoteServers[i], cacheMgr, transportMgr, healthChecker, stopCh)
+		b, err := NewRemoteProxy(remoteServers[i], cacheMgr, transportMgr, healthChecker, throttler, stopCh)
 		if err != nil {
 			klog.Errorf("could not new proxy backend(%s), %v", remoteServers[i].String(), err)
 			continue
@@ -133,18 +163,80 @@ func NewLoadBalancer(
 	}
 
 	return &loadBalancer{
-		backends:    backends,
-		algo:        algo,
-		certManager: certManager,
+		remoteServers: remoteServers,
+		backends:      backends,
+		algo:          algo,
+		certManager:   certManager,
+		throttler:     throttler,
+		cacheMgr:      cacheMgr,
+		transportMgr:  transportMgr,
+		healthChecker: healthChecker,
+		stopCh:        stopCh,
 	}, nil
 }
 
+func (lb *loadBalancer) UpdateRemoteServers(servers []*url.URL) {
+	// static remote servers go first so that they are preferred by priority algorithm
+	desired := make([]*url.URL, 0, len(lb.remoteServers)+len(servers))
+	seen := make(map[string]bool)
+	for _, server := range lb.remoteServers {
+		desired = append(desired, server)
+		seen[server.String()] = true
+	}
+	discovered := make([]*url.URL, 0, len(servers))
+	for _, server := range servers {
+		if !seen[server.String()] {
+			discovered = append(discovered, server)
+			seen[server.String()] = true
+		}
+	}
+	sort.Slice(discovered, func(i, j int) bool {
+		return discovered[i].String() < discovered[j].String()
+	})
+	desired = append(desired, discovered...)
+
+	if hc, ok := lb.healthChecker.(healthchecker.DynamicHealthChecker); ok {
+		hc.UpdateServers(desired)
+	}
+
+	lb.Lock()
+	defer lb.Unlock()
+	current := make(map[string]*RemoteProxy, len(lb.backends))
+	for _, b := range lb.backends {
+		current[b.Name()] = b
+	}
+
+	backends := make([]*RemoteProxy, 0, len(desired))
+	for _, server := range desired {
+		if b, ok := current[server.String()]; ok {
+			backends = append(backends, b)
+			continue
+		}
+		b, err := NewRemoteProxy(server, lb.cacheMgr, lb.transportMgr, lb.healthChecker, lb.throttler, lb.stopCh)
+		if err != nil {
+			klog.Errorf("could not new proxy backend(%s), %v", server.String(), err)
+			continue
+		}
+		klog.Infof("add proxy backend %s to lb", server.String())
+		backends = append(backends, b)
+	}
+	if len(backends) == 0 {
+		klog.Errorf("no backends can be used by lb after updating remote servers, keep the current backends")
+		return
+	}
+
+	lb.backends = backends
+	lb.algo.SetBackends(backends)
+}
+
 func (lb *loadBalancer) IsHealthy() bool {
 	// both certificate is not expired and
 	// have at least one healthy remote server,
 	// load balancer can proxy the request to
 	// remote server
 	if lb.certManager.NotExpired() {
+		lb.RLock()
+		defer lb.RUnlock()
 		for i := range lb.backends {
 			if lb.backends[i].IsHealthy() {
 				return true
@@ -154,12 +246,17 @@ func (lb *loadBalancer) IsHealthy() bool {
 	return false
 }
 
+func (lb *loadBalancer) ThrottledFor(req *http.Request) time.Duration {
+	comp, _ := util.ClientComponentFrom(req.Context())
+	return lb.throttler.ThrottledFor(comp)
+}
+
 func (lb *loadBalancer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	b := lb.algo.PickOne()
 	if b == nil {
 		// exceptional case
 		klog.Errorf("could not pick one healthy backends by %s for request %s", lb.algo.Name(), util.ReqString(req))
-		http.Error(rw, "could not pick one healthy backends, try again to go through local proxy.", http.StatusInternalServerError)
+		util.Err(util.NewRemoteUnavailable("could not pick one healthy backends, try again to go through local proxy"), rw, req)
 		return
 	}
 	klog.V(3).Infof("picked backend %s by %s for request %s", b.Name(), lb.algo.Name(), util.ReqString(req))
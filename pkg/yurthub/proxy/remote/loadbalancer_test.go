@@ -1,10 +1,13 @@
 package remote
 
 import (
+	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
 )
 
 type PickBackend struct {
@@ -275,3 +278,73 @@ func TestPriorityLoadBalancerAlgoWithReverseHealthy(t *testing.T) {
 		}
 	}
 }
+
+type fakeTransportManager struct {
+	transport.Interface
+}
+
+func (tm *fakeTransportManager) CurrentTransport() http.RoundTripper {
+	return http.DefaultTransport
+}
+
+func TestUpdateRemoteServers(t *testing.T) {
+	static, _ := url.Parse("https://192.168.0.1:6443")
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	lb, err := NewLoadBalancer("priority", []*url.URL{static}, nil, &fakeTransportManager{},
+		healthchecker.NewFakeChecker(true, map[string]int{}), nil, stopCh)
+	if err != nil {
+		t.Fatalf("failed to new load balancer, %v", err)
+	}
+
+	backends := func() string {
+		names := make([]string, 0)
+		for _, b := range lb.(*loadBalancer).backends {
+			names = append(names, b.Name())
+		}
+		return strings.Join(names, ",")
+	}
+
+	testcases := []struct {
+		desc       string
+		discovered []string
+		backends   string
+	}{
+		{
+			desc:       "scaled out",
+			discovered: []string{"https://10.0.0.2:6443", "https://10.0.0.1:6443"},
+			backends:   "https://192.168.0.1:6443,https://10.0.0.1:6443,https://10.0.0.2:6443",
+		},
+		{
+			desc:       "static server is discovered",
+			discovered: []string{"https://10.0.0.1:6443", "https://192.168.0.1:6443"},
+			backends:   "https://192.168.0.1:6443,https://10.0.0.1:6443",
+		},
+		{
+			desc:       "moved",
+			discovered: []string{"https://10.0.0.3:6443"},
+			backends:   "https://192.168.0.1:6443,https://10.0.0.3:6443",
+		},
+		{
+			desc:       "no server is discovered",
+			discovered: []string{},
+			backends:   "https://192.168.0.1:6443",
+		},
+	}
+
+	for _, tc := range testcases {
+		servers := make([]*url.URL, 0, len(tc.discovered))
+		for _, s := range tc.discovered {
+			u, _ := url.Parse(s)
+			servers = append(servers, u)
+		}
+		lb.UpdateRemoteServers(servers)
+		if got := backends(); got != tc.backends {
+			t.Errorf("%s: expect backends %s, but got %s", tc.desc, tc.backends, got)
+		}
+		if b := lb.(*loadBalancer).algo.PickOne(); b == nil || b.Name() != static.String() {
+			t.Errorf("%s: expect static server is picked by priority algorithm", tc.desc)
+		}
+	}
+}
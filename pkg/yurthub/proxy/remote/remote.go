@@ -7,12 +7,14 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"time"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
 	"github.com/alibaba/openyurt/pkg/yurthub/transport"
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog"
 )
@@ -22,6 +24,7 @@ type RemoteProxy struct {
 	reverseProxy *httputil.ReverseProxy
 	cacheMgr     cachemanager.CacheManager
 	remoteServer *url.URL
+	throttler    *Throttler
 	stopCh       <-chan struct{}
 }
 
@@ -29,6 +32,7 @@ func NewRemoteProxy(remoteServer *url.URL,
 	cacheMgr cachemanager.CacheManager,
 	transportMgr transport.Interface,
 	healthChecker healthchecker.HealthChecker,
+	throttler *Throttler,
 	stopCh <-chan struct{}) (*RemoteProxy, error) {
 	currentTransport := transportMgr.CurrentTransport()
 	if currentTransport == nil {
@@ -40,11 +44,13 @@ func NewRemoteProxy(remoteServer *url.URL,
 		reverseProxy: httputil.NewSingleHostReverseProxy(remoteServer),
 		cacheMgr:     cacheMgr,
 		remoteServer: remoteServer,
+		throttler:    throttler,
 		stopCh:       stopCh,
 	}
 
 	proxyBackend.reverseProxy.Transport = currentTransport
 	proxyBackend.reverseProxy.ModifyResponse = proxyBackend.modifyResponse
+	proxyBackend.reverseProxy.ErrorHandler = proxyBackend.errorHandler
 	proxyBackend.reverseProxy.FlushInterval = -1
 
 	return proxyBackend, nil
@@ -58,6 +64,21 @@ func (rp *RemoteProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	rp.reverseProxy.ServeHTTP(rw, req)
 }
 
+// errorHandler responds the status error for the failure of proxying the request, instead of
+// an empty 502, so client-go retries the request after Retry-After.
+func (rp *RemoteProxy) errorHandler(rw http.ResponseWriter, req *http.Request, err error) {
+	klog.Errorf("failed to proxy request %s to %s, %v", util.ReqString(req), rp.remoteServer.String(), err)
+	switch req.Context().Err() {
+	case context.DeadlineExceeded:
+		util.Err(apierrors.NewTimeoutError(fmt.Sprintf("request is timed out by yurthub when proxying to %s", rp.remoteServer.Host), util.DefaultRetryAfterSeconds), rw, req)
+	case context.Canceled:
+		// the client is gone, nothing to respond
+		return
+	default:
+		util.Err(util.NewRemoteUnavailable(fmt.Sprintf("failed to proxy to %s, %v", rp.remoteServer.Host, err)), rw, req)
+	}
+}
+
 func (rp *RemoteProxy) IsHealthy() bool {
 	return rp.checker.IsHealthy(rp.remoteServer)
 }
@@ -82,11 +103,24 @@ func (rp *RemoteProxy) modifyResponse(resp *http.Response) error {
 		}
 	}
 
+	// back off the requests of component if it's throttled by kube-apiserver
+	if rp.throttler != nil {
+		comp, _ := util.ClientComponentFrom(ctx)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			d := rp.throttler.Throttle(comp, parseRetryAfter(resp.Header, time.Now()))
+			klog.Warningf("%s is throttled by %s(priority level: %s), back off requests of %s for %v",
+				util.ReqString(req), rp.Name(), resp.Header.Get("X-Kubernetes-PF-PriorityLevel-UID"), comp, d)
+		} else if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusBadRequest {
+			rp.throttler.Reset(comp)
+		}
+	}
+
 	// cache resp with storage interface
 	if resp.StatusCode >= http.StatusOK && resp.StatusCode <= http.StatusPartialContent {
 		if rp.cacheMgr.CanCacheFor(req) {
 			respContentType := resp.Header.Get("Content-Type")
 			ctx = util.WithRespContentType(ctx, respContentType)
+			ctx = util.WithReqQuery(ctx, req.URL.RawQuery)
 			reqContentType, _ := util.ReqContentTypeFrom(ctx)
 			if len(reqContentType) == 0 || reqContentType == "*/*" {
 				ctx = util.WithReqContentType(ctx, respContentType)
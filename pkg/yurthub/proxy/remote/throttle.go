@@ -0,0 +1,105 @@
+package remote
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// minThrottleBackoff and maxThrottleBackoff bound the backoff of a component after
+	// its requests are throttled(429) by kube-apiserver.
+	minThrottleBackoff = time.Second
+	maxThrottleBackoff = time.Minute
+	// throttleJitter spreads the retries of yurthubs that are throttled at the same
+	// time(like after a fleet-wide reconnect), so they don't retry all at once.
+	throttleJitter = 0.2
+)
+
+type backoff struct {
+	until    time.Time
+	throttle int
+}
+
+// Throttler backs off the requests of components after they are throttled by kube-apiserver
+// (by max-requests-inflight or priority and fairness), so a recovering control plane is not
+// hammered by retries. the backoff of a component honors Retry-After of the response, and it's
+// doubled for consecutive throttles until a request of the component succeeds.
+type Throttler struct {
+	sync.Mutex
+	components map[string]*backoff
+	now        func() time.Time
+}
+
+// NewThrottler creates a Throttler
+func NewThrottler() *Throttler {
+	return &Throttler{
+		components: make(map[string]*backoff),
+		now:        time.Now,
+	}
+}
+
+// Throttle backs off the requests of component for retryAfter at least, and returns the backoff
+func (t *Throttler) Throttle(component string, retryAfter time.Duration) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	b, ok := t.components[component]
+	if !ok {
+		b = &backoff{}
+		t.components[component] = b
+	}
+
+	d := minThrottleBackoff << uint(b.throttle)
+	if retryAfter > d {
+		d = retryAfter
+	}
+	if d > maxThrottleBackoff {
+		d = maxThrottleBackoff
+	} else {
+		b.throttle++
+	}
+	d = wait.Jitter(d, throttleJitter)
+	if until := t.now().Add(d); until.After(b.until) {
+		b.until = until
+	}
+	return d
+}
+
+// Reset stops backing off the requests of component
+func (t *Throttler) Reset(component string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.components, component)
+}
+
+// ThrottledFor returns the remaining backoff of component, 0 if it's not throttled
+func (t *Throttler) ThrottledFor(component string) time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	b, ok := t.components[component]
+	if !ok {
+		return 0
+	}
+	if d := b.until.Sub(t.now()); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// parseRetryAfter parses Retry-After header in seconds or http date, 0 is returned if it's not set or invalid
+func parseRetryAfter(h http.Header, now time.Time) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
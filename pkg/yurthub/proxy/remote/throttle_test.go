@@ -0,0 +1,104 @@
+package remote
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+)
+
+func TestThrottler(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	throttler := NewThrottler()
+	throttler.now = func() time.Time { return now }
+
+	inRange := func(d, expected time.Duration) bool {
+		return d >= expected && d <= time.Duration(float64(expected)*(1+throttleJitter))
+	}
+
+	// the backoff is doubled for consecutive throttles
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if d := throttler.Throttle("kubelet", 0); !inRange(d, expected) {
+			t.Errorf("expect backoff %v, but got %v", expected, d)
+		}
+	}
+	// Retry-After is honored
+	if d := throttler.Throttle("kubelet", 30*time.Second); !inRange(d, 30*time.Second) {
+		t.Errorf("expect backoff 30s, but got %v", d)
+	}
+	// the backoff is capped
+	if d := throttler.Throttle("kubelet", time.Hour); !inRange(d, maxThrottleBackoff) {
+		t.Errorf("expect backoff %v, but got %v", maxThrottleBackoff, d)
+	}
+
+	if d := throttler.ThrottledFor("kubelet"); d < maxThrottleBackoff {
+		t.Errorf("expect kubelet is throttled for %v at least, but got %v", maxThrottleBackoff, d)
+	}
+	if d := throttler.ThrottledFor("kube-proxy"); d != 0 {
+		t.Errorf("expect kube-proxy is not throttled, but got %v", d)
+	}
+
+	now = now.Add(2 * maxThrottleBackoff)
+	if d := throttler.ThrottledFor("kubelet"); d != 0 {
+		t.Errorf("expect backoff of kubelet is ended, but got %v", d)
+	}
+
+	throttler.Reset("kubelet")
+	if d := throttler.Throttle("kubelet", 0); !inRange(d, time.Second) {
+		t.Errorf("expect backoff is reset, but got %v", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		retryAfter string
+		expected   time.Duration
+	}{
+		"not set": {},
+		"seconds": {
+			retryAfter: "5",
+			expected:   5 * time.Second,
+		},
+		"http date": {
+			retryAfter: now.Add(10 * time.Second).Format(http.TimeFormat),
+			expected:   10 * time.Second,
+		},
+		"invalid": {
+			retryAfter: "soon",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			h := http.Header{}
+			if tt.retryAfter != "" {
+				h.Set("Retry-After", tt.retryAfter)
+			}
+			if d := parseRetryAfter(h, now); d != tt.expected {
+				t.Errorf("expect %v, but got %v", tt.expected, d)
+			}
+		})
+	}
+}
+
+func TestThrottleByResponse(t *testing.T) {
+	u, _ := url.Parse("https://127.0.0.1:6443")
+	rp := &RemoteProxy{remoteServer: u, throttler: NewThrottler()}
+
+	req, _ := http.NewRequest("GET", "/api/v1/nodes/node1", nil)
+	req = req.WithContext(util.WithClientComponent(req.Context(), "kubelet"))
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3"}}, Request: req}
+	rp.modifyResponse(resp)
+	if d := rp.throttler.ThrottledFor("kubelet"); d < 2*time.Second {
+		t.Errorf("expect kubelet is throttled for 3s, but got %v", d)
+	}
+
+	resp = &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{}, Request: req}
+	rp.modifyResponse(resp)
+	if d := rp.throttler.ThrottledFor("kubelet"); d == 0 {
+		t.Errorf("expect kubelet is still throttled after a failed request")
+	}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"sync"
+)
+
+// RequestLimiter limits the number of parallel requests, and the limit
+// can be changed at runtime(like by hub configuration from cloud).
+type RequestLimiter struct {
+	sync.Mutex
+	limit    int
+	inFlight int
+}
+
+// NewRequestLimiter creates a limiter that allows limit requests in flight
+func NewRequestLimiter(limit int) *RequestLimiter {
+	return &RequestLimiter{
+		limit: limit,
+	}
+}
+
+// SetLimit updates the limit of requests in flight, the requests that
+// are already in flight are not affected.
+func (l *RequestLimiter) SetLimit(limit int) {
+	l.Lock()
+	defer l.Unlock()
+	l.limit = limit
+}
+
+// Limit returns the limit of requests in flight
+func (l *RequestLimiter) Limit() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of requests in flight
+func (l *RequestLimiter) InFlight() int {
+	l.Lock()
+	defer l.Unlock()
+	return l.inFlight
+}
+
+// acquire reserves a slot for request, false is returned when the limit is reached.
+func (l *RequestLimiter) acquire() bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.inFlight >= l.limit {
+		return false
+	}
+	l.inFlight++
+	return true
+}
+
+// release frees the slot reserved by acquire
+func (l *RequestLimiter) release() {
+	l.Lock()
+	defer l.Unlock()
+	l.inFlight--
+}
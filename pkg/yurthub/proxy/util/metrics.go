@@ -0,0 +1,53 @@
+package util
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	proxySubsystem = "yurthub_proxy"
+	// requestKindLongRunning and requestKindShort are the kinds of requests in metrics,
+	// long-running requests(like watch) are accounted separately from the others.
+	requestKindLongRunning = "long-running"
+	requestKindShort       = "short"
+)
+
+var (
+	inFlightRequests = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: proxySubsystem,
+			Name:      "inflight_requests",
+			Help:      "Gauge measuring the number of requests in flight, by kind of request(long-running or short).",
+		},
+		[]string{"request_kind"},
+	)
+	rejectedRequests = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: proxySubsystem,
+			Name:      "rejected_requests_total",
+			Help:      "Counter of the short requests that are rejected because max-requests-in-flight is reached.",
+		},
+	)
+	requestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: proxySubsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Histogram of the duration of short requests, long-running requests are not observed.",
+			Buckets:   []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		},
+		[]string{"verb"},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics of proxied requests.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(inFlightRequests)
+		prometheus.MustRegister(rejectedRequests)
+		prometheus.MustRegister(requestDuration)
+	})
+}
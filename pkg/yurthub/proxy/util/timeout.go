@@ -0,0 +1,79 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// RequestTimeouts are the timeouts of requests by verb, resource and subresource, so
+// small writes(like the status patches of nodes) fail fast while large lists have time
+// to complete over high-latency links.
+type RequestTimeouts struct {
+	defaultTimeout time.Duration
+	rules          map[string]time.Duration
+}
+
+// ParseRequestTimeouts parses the timeouts in the format of "verb[/resource[/subresource]]=seconds",
+// verb can be "*" for all verbs, and 0 seconds means no timeout. requests that match no timeout
+// use defaultTimeout.
+func ParseRequestTimeouts(timeouts []string, defaultTimeout time.Duration) (*RequestTimeouts, error) {
+	t := &RequestTimeouts{
+		defaultTimeout: defaultTimeout,
+		rules:          make(map[string]time.Duration),
+	}
+	for _, entry := range timeouts {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("request timeout(%s) is invalid, the format is verb[/resource[/subresource]]=seconds", entry)
+		}
+
+		match := strings.ToLower(strings.TrimSpace(parts[0]))
+		fields := strings.Split(match, "/")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("request timeout(%s) is invalid, the format is verb[/resource[/subresource]]=seconds", entry)
+		}
+		for _, f := range fields {
+			if f == "" {
+				return nil, fmt.Errorf("request timeout(%s) is invalid, verb, resource and subresource can not be empty", entry)
+			}
+		}
+
+		seconds, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("seconds of request timeout(%s) is not a non-negative integer", entry)
+		}
+		t.rules[match] = time.Duration(seconds) * time.Second
+	}
+	return t, nil
+}
+
+// TimeoutFor returns the timeout for the request, the most specific match wins:
+// verb/resource/subresource, */resource/subresource, verb/resource, */resource, verb.
+func (t *RequestTimeouts) TimeoutFor(info *apirequest.RequestInfo) time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	verb := strings.ToLower(info.Verb)
+	var matches []string
+	if info.IsResourceRequest {
+		if info.Subresource != "" {
+			matches = append(matches,
+				verb+"/"+info.Resource+"/"+info.Subresource,
+				"*/"+info.Resource+"/"+info.Subresource)
+		}
+		matches = append(matches, verb+"/"+info.Resource, "*/"+info.Resource)
+	}
+	matches = append(matches, verb)
+
+	for _, match := range matches {
+		if timeout, ok := t.rules[match]; ok {
+			return timeout
+		}
+	}
+	return t.defaultTimeout
+}
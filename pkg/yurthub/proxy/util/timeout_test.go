@@ -0,0 +1,88 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestParseRequestTimeouts(t *testing.T) {
+	testcases := map[string]struct {
+		timeouts []string
+		valid    bool
+	}{
+		"valid": {
+			timeouts: []string{"patch/nodes/status=10", "*/pods=30", "list=0"},
+			valid:    true,
+		},
+		"no seconds": {
+			timeouts: []string{"list"},
+		},
+		"negative seconds": {
+			timeouts: []string{"list=-1"},
+		},
+		"empty resource": {
+			timeouts: []string{"get//status=10"},
+		},
+		"too many fields": {
+			timeouts: []string{"get/pods/status/foo=10"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			_, err := ParseRequestTimeouts(tt.timeouts, 0)
+			if tt.valid && err != nil {
+				t.Errorf("expect valid, but got %v", err)
+			} else if !tt.valid && err == nil {
+				t.Errorf("expect invalid")
+			}
+		})
+	}
+}
+
+func TestTimeoutFor(t *testing.T) {
+	timeouts, err := ParseRequestTimeouts([]string{"patch/nodes/status=10", "*/nodes/status=20", "get/pods=30", "*/pods=40", "list=0"}, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to parse request timeouts, %v", err)
+	}
+
+	testcases := map[string]struct {
+		info    apirequest.RequestInfo
+		timeout time.Duration
+	}{
+		"verb, resource and subresource": {
+			info:    apirequest.RequestInfo{IsResourceRequest: true, Verb: "patch", Resource: "nodes", Subresource: "status"},
+			timeout: 10 * time.Second,
+		},
+		"resource and subresource": {
+			info:    apirequest.RequestInfo{IsResourceRequest: true, Verb: "update", Resource: "nodes", Subresource: "status"},
+			timeout: 20 * time.Second,
+		},
+		"verb and resource": {
+			info:    apirequest.RequestInfo{IsResourceRequest: true, Verb: "get", Resource: "pods"},
+			timeout: 30 * time.Second,
+		},
+		"resource": {
+			info:    apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "pods"},
+			timeout: 40 * time.Second,
+		},
+		"verb": {
+			info:    apirequest.RequestInfo{IsResourceRequest: true, Verb: "list", Resource: "configmaps"},
+			timeout: 0,
+		},
+		"default": {
+			info:    apirequest.RequestInfo{Verb: "get", Path: "/version"},
+			timeout: time.Minute,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if timeout := timeouts.TimeoutFor(&tt.info); timeout != tt.timeout {
+				t.Errorf("expect timeout %v, but got %v", tt.timeout, timeout)
+			}
+		})
+	}
+}
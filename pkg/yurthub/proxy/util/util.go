@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/util"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apirequest "k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/klog"
 )
@@ -27,7 +28,7 @@ func WithRequestContentType(handler http.Handler) http.Handler {
 
 				if len(contentType) == 0 {
 					klog.Errorf("no accept content type for request: %s", util.ReqString(req))
-					http.Error(w, "no accept content type is set.", http.StatusBadRequest)
+					util.Err(apierrors.NewBadRequest("no accept content type is set"), w, req)
 					return
 				}
 
@@ -61,6 +62,26 @@ func WithCacheHeaderCheck(handler http.Handler) http.Handler {
 	})
 }
 
+// WithPartialListCheck add partial-list field in request context for list requests.
+// the list with a label or field selector, or the page after a continue token, is a
+// part of the collection, so the cache of the collection is not replaced by it.
+func WithPartialListCheck(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		if info, ok := apirequest.RequestInfoFrom(ctx); ok {
+			if info.IsResourceRequest && info.Verb == "list" {
+				query := req.URL.Query()
+				partial := query.Get("labelSelector") != "" || query.Get("fieldSelector") != "" ||
+					query.Get("continue") != ""
+				ctx = util.WithPartialList(ctx, partial)
+				req = req.WithContext(ctx)
+			}
+		}
+
+		handler.ServeHTTP(w, req)
+	})
+}
+
 // withRequestClientComponent add component field in request context.
 // component is extracted from User-Agent Header, and only the content
 // before the "/" when User-Agent include "/".
@@ -139,27 +160,60 @@ func (wrw *wrapperResponseWriter) Flush() {
 	}
 }
 
-func WithRequestTrace(handler http.Handler, limit int) http.Handler {
-	var reqChan chan bool
-	if limit > 0 {
-		reqChan = make(chan bool, limit)
-	}
-
+// WithRequestTrace traces the request and rejects requests that exceed the limit of limiter.
+// long-running requests(like watch and exec) are not limited, as kube-apiserver does, they are
+// accounted separately in metrics.
+func WithRequestTrace(handler http.Handler, limiter *RequestLimiter, longRunning apirequest.LongRunningRequestCheck) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		wrapperRW := newWrapperResponseWriter(req.Context(), w)
 		start := time.Now()
 
-		select {
-		case reqChan <- true:
+		if isLongRunning(req, longRunning) {
+			inFlightRequests.WithLabelValues(requestKindLongRunning).Inc()
 			defer func() {
-				<-reqChan
-				klog.Infof("%s with status code %d, spent %v, left %d requests in flight", util.ReqString(req), wrapperRW.statusCode, time.Now().Sub(start), len(reqChan))
+				inFlightRequests.WithLabelValues(requestKindLongRunning).Dec()
+				klog.Infof("%s with status code %d, spent %v", util.ReqString(req), wrapperRW.statusCode, time.Now().Sub(start))
 			}()
 			handler.ServeHTTP(wrapperRW, req)
-		default:
-			// Return a 429 status indicating "Too Many Requests"
-			w.Header().Set("Retry-After", "1")
-			http.Error(w, "Too many requests, please try again later.", http.StatusTooManyRequests)
+		} else if limiter.acquire() {
+			inFlightRequests.WithLabelValues(requestKindShort).Inc()
+			defer func() {
+				limiter.release()
+				inFlightRequests.WithLabelValues(requestKindShort).Dec()
+				requestDuration.WithLabelValues(strings.ToLower(req.Method)).Observe(time.Since(start).Seconds())
+				klog.Infof("%s with status code %d, spent %v, left %d requests in flight", util.ReqString(req), wrapperRW.statusCode, time.Now().Sub(start), limiter.InFlight())
+			}()
+			handler.ServeHTTP(wrapperRW, req)
+		} else {
+			rejectedRequests.Inc()
+			// Return a 429 status indicating "Too Many Requests", with Retry-After
+			util.Err(util.NewStatusError(http.StatusTooManyRequests, util.CauseTypeThrottled,
+				"too many requests in flight of yurthub, please try again later", util.DefaultRetryAfterSeconds), w, req)
 		}
 	})
 }
+
+// WithRequestTimeout times out the requests that are not long-running after the timeout of
+// their verb and resource in timeouts, the request to remote servers is canceled when it's
+// timed out. 0 means no timeout.
+func WithRequestTimeout(handler http.Handler, longRunning apirequest.LongRunningRequestCheck, timeouts *RequestTimeouts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if info, ok := apirequest.RequestInfoFrom(req.Context()); ok && !isLongRunning(req, longRunning) {
+			if timeout := timeouts.TimeoutFor(info); timeout > 0 {
+				ctx, cancel := context.WithTimeout(req.Context(), timeout)
+				defer cancel()
+				req = req.WithContext(ctx)
+			}
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// isLongRunning returns true if req is a long-running request
+func isLongRunning(req *http.Request, longRunning apirequest.LongRunningRequestCheck) bool {
+	if longRunning == nil {
+		return false
+	}
+	info, ok := apirequest.RequestInfoFrom(req.Context())
+	return ok && longRunning(req, info)
+}
@@ -11,6 +11,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apiserver/pkg/endpoints/filters"
 	"k8s.io/apiserver/pkg/endpoints/request"
+	genericfilters "k8s.io/apiserver/pkg/server/filters"
 )
 
 func newTestRequestInfoResolver() *request.RequestInfoFactory {
@@ -138,6 +139,57 @@ func TestWithRequestClientComponent(t *testing.T) {
 	}
 }
 
+func TestWithPartialListCheck(t *testing.T) {
+	testcases := map[string]struct {
+		Path    string
+		Set     bool
+		Partial bool
+	}{
+		"full list": {
+			Path: "/api/v1/namespaces/default/pods",
+			Set:  true,
+		},
+		"list with label selector": {
+			Path:    "/api/v1/pods?labelSelector=app%3Dnginx",
+			Set:     true,
+			Partial: true,
+		},
+		"list with field selector": {
+			Path:    "/api/v1/pods?fieldSelector=spec.nodeName%3Dmynode",
+			Set:     true,
+			Partial: true,
+		},
+		"page of list": {
+			Path:    "/api/v1/pods?limit=500&continue=abc",
+			Set:     true,
+			Partial: true,
+		},
+		"get request": {
+			Path: "/api/v1/nodes/mynode",
+		},
+	}
+
+	resolver := newTestRequestInfoResolver()
+
+	for k, tc := range testcases {
+		req, _ := http.NewRequest("GET", tc.Path, nil)
+		req.RemoteAddr = "127.0.0.1"
+
+		var partial, set bool
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			partial, set = util.PartialListFrom(req.Context())
+		})
+
+		handler = WithPartialListCheck(handler)
+		handler = filters.WithRequestInfo(handler, resolver)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if set != tc.Set || partial != tc.Partial {
+			t.Errorf("%s: expect partial list %v(set %v), but got %v(set %v)", k, tc.Partial, tc.Set, partial, set)
+		}
+	}
+}
+
 func TestWithRequestTrace(t *testing.T) {
 	testcases := map[int]struct {
 		Verb            string
@@ -171,7 +223,7 @@ func TestWithRequestTrace(t *testing.T) {
 			w.WriteHeader(http.StatusOK)
 		})
 
-		handler = WithRequestTrace(handler, 10)
+		handler = WithRequestTrace(handler, NewRequestLimiter(10), nil)
 		handler = filters.WithRequestInfo(handler, resolver)
 
 		respCodes := make([]int, k)
@@ -200,3 +252,92 @@ func TestWithRequestTrace(t *testing.T) {
 		}
 	}
 }
+
+func TestWithRequestTraceForLongRunning(t *testing.T) {
+	longRunning := genericfilters.BasicLongRunningRequestCheck(sets.NewString("watch"), sets.NewString("exec"))
+	limiter := NewRequestLimiter(0)
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler = WithRequestTrace(handler, limiter, longRunning)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+
+	testcases := map[string]struct {
+		path string
+		code int
+	}{
+		"watch": {
+			path: "/api/v1/pods?watch=true",
+			code: http.StatusOK,
+		},
+		"exec": {
+			path: "/api/v1/namespaces/default/pods/pod1/exec",
+			code: http.StatusOK,
+		},
+		"get": {
+			path: "/api/v1/namespaces/default/pods/pod1",
+			code: http.StatusTooManyRequests,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			if resp.Code != tt.code {
+				t.Errorf("expect status code %d, but got %d", tt.code, resp.Code)
+			}
+		})
+	}
+}
+
+func TestWithRequestTimeout(t *testing.T) {
+	longRunning := genericfilters.BasicLongRunningRequestCheck(sets.NewString("watch"), sets.NewString())
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.Context().Deadline(); ok {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	timeouts, _ := ParseRequestTimeouts([]string{"patch/nodes/status=10", "list=0"}, time.Minute)
+	handler = WithRequestTimeout(handler, longRunning, timeouts)
+	handler = filters.WithRequestInfo(handler, newTestRequestInfoResolver())
+
+	testcases := map[string]struct {
+		method   string
+		path     string
+		deadline bool
+	}{
+		"watch": {
+			method: "GET",
+			path:   "/api/v1/pods?watch=true",
+		},
+		"list without timeout": {
+			method: "GET",
+			path:   "/api/v1/pods",
+		},
+		"get": {
+			method:   "GET",
+			path:     "/api/v1/namespaces/default/pods/pod1",
+			deadline: true,
+		},
+		"patch node status": {
+			method:   "PATCH",
+			path:     "/api/v1/nodes/node1/status",
+			deadline: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, nil)
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			if deadline := resp.Code == http.StatusGatewayTimeout; deadline != tt.deadline {
+				t.Errorf("expect deadline %v, but got %v", tt.deadline, deadline)
+			}
+		})
+	}
+}
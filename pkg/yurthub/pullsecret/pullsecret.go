@@ -0,0 +1,245 @@
+package pullsecret
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// component is the component that image pull secrets are cached for, kubelet reads the
+	// secrets when it pulls images for pods.
+	component = "kubelet"
+	// checkPeriod is the period that the connection to remote servers is checked
+	checkPeriod = 30 * time.Second
+	// storageTimeout bounds the time of reading and writing a secret
+	storageTimeout = 10 * time.Second
+	// maintenanceRefreshPeriod is the refresh period while the node pool is in maintenance,
+	// so the secrets are as fresh as possible when the planned outage begins.
+	maintenanceRefreshPeriod = time.Minute
+)
+
+// Prefetcher caches the image pull secrets(in type of dockerconfigjson or dockercfg) referenced
+// by the pods on the node ahead of kubelet, and refreshes them every refresh period and every
+// time yurthub is reconnected to remote servers. kubelet only gets the secrets when it pulls
+// images, so without prefetching, the cached secrets may be too old to pull images when pods
+// are restarted during disconnection, especially for the short-lived registry tokens.
+// the secrets are refreshed aggressively while the node pool is in maintenance.
+type Prefetcher struct {
+	sync.Mutex
+	nodeName         string
+	storageWrapper   cachemanager.StorageWrapper
+	remoteServers    []*url.URL
+	healthChecker    healthchecker.HealthChecker
+	transportManager transport.Interface
+	refreshPeriod    time.Duration
+	newClient        func() (clientset.Interface, error)
+	connected        bool
+	lastRefresh      time.Time
+	maintenance      *hubconfig.Maintenance
+	pendingRefresh   bool
+	stopCh           <-chan struct{}
+}
+
+// NewPrefetcher creates a Prefetcher for the image pull secrets of pods on the node
+func NewPrefetcher(cfg *config.YurtHubConfiguration,
+	storageWrapper cachemanager.StorageWrapper,
+	transportManager transport.Interface,
+	healthChecker healthchecker.HealthChecker,
+	stopCh <-chan struct{}) *Prefetcher {
+	p := &Prefetcher{
+		nodeName:         cfg.NodeName,
+		storageWrapper:   storageWrapper,
+		remoteServers:    cfg.RemoteServers,
+		healthChecker:    healthChecker,
+		transportManager: transportManager,
+		refreshPeriod:    time.Duration(cfg.PullSecretRefreshFrequency) * time.Minute,
+		stopCh:           stopCh,
+	}
+	p.newClient = p.kubeClient
+	return p
+}
+
+// Run refreshes the image pull secrets in cache until stopCh is closed
+func (p *Prefetcher) Run() {
+	go wait.Until(p.check, checkPeriod, p.stopCh)
+}
+
+// SetMaintenance sets the maintenance of node pool, the secrets are refreshed right away
+// when the maintenance begins, and then every maintenanceRefreshPeriod until it ends.
+func (p *Prefetcher) SetMaintenance(maintenance *hubconfig.Maintenance) {
+	p.Lock()
+	defer p.Unlock()
+	now := time.Now()
+	if maintenance.Active(now) && !p.maintenance.Active(now) {
+		p.pendingRefresh = true
+	}
+	p.maintenance = maintenance
+}
+
+// refreshDue returns whether the secrets should be refreshed since lastRefresh
+func (p *Prefetcher) refreshDue() bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.pendingRefresh {
+		p.pendingRefresh = false
+		return true
+	}
+
+	period := p.refreshPeriod
+	if p.maintenance.Active(time.Now()) && maintenanceRefreshPeriod < period {
+		period = maintenanceRefreshPeriod
+	}
+	return time.Since(p.lastRefresh) >= period
+}
+
+func (p *Prefetcher) check() {
+	healthy := false
+	for _, server := range p.remoteServers {
+		if p.healthChecker.IsHealthy(server) {
+			healthy = true
+			break
+		}
+	}
+	if !healthy {
+		p.connected = false
+		return
+	} else if p.connected && !p.refreshDue() {
+		return
+	}
+
+	kubeClient, err := p.newClient()
+	if err != nil {
+		klog.Errorf("could not new kube client, %v", err)
+		return
+	}
+	if err := p.refresh(kubeClient); err != nil {
+		// retried in the next check
+		klog.Errorf("failed to refresh image pull secrets, %v", err)
+		return
+	}
+	p.connected = true
+	p.lastRefresh = time.Now()
+}
+
+// refresh gets the image pull secrets of pods on the node, and caches the ones that are changed
+func (p *Prefetcher) refresh(kubeClient clientset.Interface) error {
+	listOpts := metav1.ListOptions{FieldSelector: fields.OneTermEqualSelector("spec.nodeName", p.nodeName).String()}
+	podList, err := kubeClient.CoreV1().Pods(v1.NamespaceAll).List(listOpts)
+	if err != nil {
+		return fmt.Errorf("could not list pods for node(%s), %v", p.nodeName, err)
+	}
+
+	cached := 0
+	for _, secret := range imagePullSecrets(podList.Items) {
+		key, _ := util.KeyFunc(component, "secrets", secret.Namespace, secret.Name)
+		obj, err := kubeClient.CoreV1().Secrets(secret.Namespace).Get(secret.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			p.deleteSecret(key)
+			continue
+		} else if err != nil {
+			klog.Errorf("could not get image pull secret %s, %v", key, err)
+			continue
+		}
+		if obj.Type != v1.SecretTypeDockerConfigJson && obj.Type != v1.SecretTypeDockercfg {
+			klog.V(4).Infof("skip secret %s in type %s, it's not an image pull secret", key, obj.Type)
+			continue
+		}
+
+		updated, err := p.cacheSecret(key, obj)
+		if err != nil {
+			klog.Errorf("failed to cache image pull secret %s, %v", key, err)
+			continue
+		} else if updated {
+			cached++
+		}
+	}
+	klog.V(2).Infof("image pull secrets of node %s are refreshed, %d secrets are updated in cache", p.nodeName, cached)
+	return nil
+}
+
+// cacheSecret caches the secret if its resource version is newer than the cached one
+func (p *Prefetcher) cacheSecret(key string, secret *v1.Secret) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	if oldObj, err := p.storageWrapper.Get(ctx, key); err == nil {
+		oldRv, _ := meta.NewAccessor().ResourceVersion(oldObj)
+		oldRvInt, _ := strconv.Atoi(oldRv)
+		newRvInt, _ := strconv.Atoi(secret.ResourceVersion)
+		if newRvInt <= oldRvInt {
+			return false, nil
+		}
+	}
+
+	secret.APIVersion, secret.Kind = "v1", "Secret"
+	if err := p.storageWrapper.Update(ctx, key, secret); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (p *Prefetcher) deleteSecret(key string) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := p.storageWrapper.Delete(ctx, key); err != nil {
+		klog.Errorf("failed to delete image pull secret %s that is not found, %v", key, err)
+	}
+}
+
+func (p *Prefetcher) kubeClient() (clientset.Interface, error) {
+	// rest config is not ready until the certificate of yurthub is prepared
+	cfg := p.transportManager.GetRestClientConfig()
+	if cfg == nil {
+		return nil, fmt.Errorf("rest config is not ready")
+	}
+	return clientset.NewForConfig(cfg)
+}
+
+// imagePullSecrets returns the image pull secrets referenced by pods, the image pull secrets of
+// service accounts are added to pods by admission, so only the pods are checked.
+func imagePullSecrets(pods []v1.Pod) []metav1.ObjectMeta {
+	seen := make(map[string]bool)
+	secrets := make([]metav1.ObjectMeta, 0)
+	for i := range pods {
+		for _, ref := range pods[i].Spec.ImagePullSecrets {
+			if ref.Name == "" {
+				continue
+			}
+			secret := metav1.ObjectMeta{Namespace: pods[i].Namespace, Name: ref.Name}
+			id := secret.Namespace + "/" + secret.Name
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		if secrets[i].Namespace != secrets[j].Namespace {
+			return secrets[i].Namespace < secrets[j].Namespace
+		}
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets
+}
@@ -0,0 +1,197 @@
+package pullsecret
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/hubconfig"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	clientfake "k8s.io/client-go/kubernetes/fake"
+)
+
+type healthChecker struct {
+	healthy bool
+}
+
+func (hc *healthChecker) IsHealthy(server *url.URL) bool {
+	return hc.healthy
+}
+
+func newPod(ns, name string, secrets ...string) *v1.Pod {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+		Spec:       v1.PodSpec{NodeName: "node1"},
+	}
+	for _, secret := range secrets {
+		pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, v1.LocalObjectReference{Name: secret})
+	}
+	return pod
+}
+
+func newSecret(ns, name string, secretType v1.SecretType, rv, token string) *v1.Secret {
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name, ResourceVersion: rv},
+		Type:       secretType,
+		Data:       map[string][]byte{v1.DockerConfigJsonKey: []byte(token)},
+	}
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	pods := []v1.Pod{
+		*newPod("default", "pod1", "registry", "mirror"),
+		*newPod("default", "pod2", "registry", ""),
+		*newPod("kube-system", "pod3", "registry"),
+	}
+
+	secrets := imagePullSecrets(pods)
+	expected := []string{"default/mirror", "default/registry", "kube-system/registry"}
+	if len(secrets) != len(expected) {
+		t.Fatalf("expect secrets %v, but got %v", expected, secrets)
+	}
+	for i := range secrets {
+		if id := secrets[i].Namespace + "/" + secrets[i].Name; id != expected[i] {
+			t.Errorf("expect secret %s, but got %s", expected[i], id)
+		}
+	}
+}
+
+func TestPrefetcher(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec())
+	ctx := context.Background()
+
+	kubeClient := clientfake.NewSimpleClientset(
+		newPod("default", "pod1", "registry", "opaque", "missing"),
+		newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "10", "token1"),
+		newSecret("default", "opaque", v1.SecretTypeOpaque, "10", "password"),
+	)
+	// the secret is deleted from the cluster after it's cached
+	if err := sw.Create(ctx, "kubelet/secrets/default/missing", newSecret("default", "missing", v1.SecretTypeDockerConfigJson, "1", "token")); err != nil {
+		t.Fatalf("failed to create secret, %v", err)
+	}
+
+	hc := &healthChecker{}
+	p := &Prefetcher{
+		nodeName:       "node1",
+		storageWrapper: sw,
+		remoteServers:  []*url.URL{{Scheme: "https", Host: "127.0.0.1:6443"}},
+		healthChecker:  hc,
+		refreshPeriod:  time.Hour,
+		newClient: func() (clientset.Interface, error) {
+			return kubeClient, nil
+		},
+		stopCh: make(chan struct{}),
+	}
+
+	// secrets are not refreshed until remote servers are healthy
+	p.check()
+	if _, err := sw.Get(ctx, "kubelet/secrets/default/registry"); err != storage.ErrNotFound {
+		t.Fatalf("expect secret is not cached, but got %v", err)
+	}
+
+	hc.healthy = true
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token1")
+	if _, err := sw.Get(ctx, "kubelet/secrets/default/opaque"); err != storage.ErrNotFound {
+		t.Errorf("expect opaque secret is not cached, but got %v", err)
+	}
+	if _, err := sw.Get(ctx, "kubelet/secrets/default/missing"); err != storage.ErrNotFound {
+		t.Errorf("expect secret that is not found is deleted, but got %v", err)
+	}
+
+	// the registry token is rotated, and it's not refreshed until the refresh period
+	if _, err := kubeClient.CoreV1().Secrets("default").Update(newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "11", "token2")); err != nil {
+		t.Fatalf("failed to update secret, %v", err)
+	}
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token1")
+
+	// secrets are refreshed after yurthub is reconnected
+	hc.healthy = false
+	p.check()
+	hc.healthy = true
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token2")
+
+	// secrets in older resource version don't overwrite the cache
+	if _, err := kubeClient.CoreV1().Secrets("default").Update(newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "9", "token0")); err != nil {
+		t.Fatalf("failed to update secret, %v", err)
+	}
+	p.lastRefresh = time.Time{}
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token2")
+}
+
+func TestPrefetcherInMaintenance(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec())
+	kubeClient := clientfake.NewSimpleClientset(
+		newPod("default", "pod1", "registry"),
+		newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "10", "token1"),
+	)
+
+	p := &Prefetcher{
+		nodeName:       "node1",
+		storageWrapper: sw,
+		remoteServers:  []*url.URL{{Scheme: "https", Host: "127.0.0.1:6443"}},
+		healthChecker:  &healthChecker{healthy: true},
+		refreshPeriod:  time.Hour,
+		newClient: func() (clientset.Interface, error) {
+			return kubeClient, nil
+		},
+		stopCh: make(chan struct{}),
+	}
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token1")
+
+	// secrets are refreshed right away when the maintenance begins
+	if _, err := kubeClient.CoreV1().Secrets("default").Update(newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "11", "token2")); err != nil {
+		t.Fatalf("failed to update secret, %v", err)
+	}
+	p.SetMaintenance(&hubconfig.Maintenance{Reason: "wan upgrade"})
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token2")
+
+	// and then every maintenance refresh period
+	if _, err := kubeClient.CoreV1().Secrets("default").Update(newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "12", "token3")); err != nil {
+		t.Fatalf("failed to update secret, %v", err)
+	}
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token2")
+	p.lastRefresh = time.Now().Add(-maintenanceRefreshPeriod)
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token3")
+
+	// the refresh period is restored after the maintenance is removed
+	if _, err := kubeClient.CoreV1().Secrets("default").Update(newSecret("default", "registry", v1.SecretTypeDockerConfigJson, "13", "token4")); err != nil {
+		t.Fatalf("failed to update secret, %v", err)
+	}
+	p.SetMaintenance(nil)
+	p.lastRefresh = time.Now().Add(-maintenanceRefreshPeriod)
+	p.check()
+	assertToken(t, sw, "kubelet/secrets/default/registry", "token3")
+}
+
+func assertToken(t *testing.T, sw cachemanager.StorageWrapper, key, token string) {
+	t.Helper()
+	obj, err := sw.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("expect secret %s is cached, but got %v", key, err)
+	}
+	secret, ok := obj.(*v1.Secret)
+	if !ok {
+		t.Fatalf("expect *v1.Secret, but got %T", obj)
+	}
+	if got := string(secret.Data[v1.DockerConfigJsonKey]); got != token {
+		t.Errorf("expect token %s of secret %s, but got %s", token, key, got)
+	}
+}
@@ -0,0 +1,136 @@
+package readiness
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+const (
+	// cacheStaleThreshold is the age of the newest cache of kubelet that the cache is regarded
+	// as stale, kubelet updates the status of node periodically when cloud is healthy.
+	cacheStaleThreshold = time.Hour
+	// certExpiryMargin is the margin before the certificate expires that is needed to survive
+	// an outage of cloud, the certificate can not be renewed during the outage.
+	certExpiryMargin = 7 * 24 * time.Hour
+	// diskMinFreeBytes and diskMinFreePercent are the free space that the cache can not
+	// be updated without, and diskWarnFreePercent is the free space that is running low.
+	diskMinFreeBytes    = 100 * 1024 * 1024
+	diskMinFreePercent  = 5
+	diskWarnFreePercent = 15
+	// clockMaxSkew is the skew that certificates may be rejected(like not valid yet), and
+	// clockWarnSkew is the skew that is worth a warning.
+	clockMaxSkew  = 5 * time.Minute
+	clockWarnSkew = 30 * time.Second
+)
+
+// cacheCheck checks that the node and pods are cached for kubelet and the cache is fresh
+func cacheCheck(store storage.Store, nodeName string) func(ctx context.Context, now time.Time) CheckResult {
+	return func(ctx context.Context, now time.Time) CheckResult {
+		infos, err := storage.StatKeys(ctx, store, "kubelet")
+		if err != nil {
+			return CheckResult{Status: StatusFail, Message: fmt.Sprintf("failed to list cache of kubelet, %v", err)}
+		}
+
+		nodeKey := path.Join("kubelet", "nodes", nodeName)
+		nodeCached, pods := false, 0
+		var newest time.Time
+		for _, info := range infos {
+			if info.Key == nodeKey {
+				nodeCached = true
+			} else if strings.HasPrefix(info.Key, "kubelet/pods/") {
+				pods++
+			}
+			if info.ModTime.After(newest) {
+				newest = info.ModTime
+			}
+		}
+
+		if !nodeCached {
+			return CheckResult{Status: StatusFail, Message: fmt.Sprintf("node %s is not cached for kubelet", nodeName)}
+		} else if pods == 0 {
+			return CheckResult{Status: StatusWarn, Message: "no pods are cached for kubelet"}
+		} else if !newest.IsZero() && now.Sub(newest) > cacheStaleThreshold {
+			return CheckResult{Status: StatusWarn, Message: fmt.Sprintf("cache of kubelet is not refreshed since %s", newest.Format(time.RFC3339))}
+		}
+
+		return CheckResult{Status: StatusPass, Message: fmt.Sprintf("%d keys(%d pods) are cached for kubelet", len(infos), pods)}
+	}
+}
+
+// certificateCheck checks that the certificate of yurthub doesn't expire in certExpiryMargin
+func certificateCheck(current func() *tls.Certificate) func(ctx context.Context, now time.Time) CheckResult {
+	return func(ctx context.Context, now time.Time) CheckResult {
+		cert := current()
+		if cert == nil || len(cert.Certificate) == 0 {
+			return CheckResult{Status: StatusFail, Message: "certificate is not prepared"}
+		}
+
+		leaf := cert.Leaf
+		if leaf == nil {
+			var err error
+			if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+				return CheckResult{Status: StatusFail, Message: fmt.Sprintf("failed to parse certificate, %v", err)}
+			}
+		}
+
+		expiry := leaf.NotAfter.Format(time.RFC3339)
+		if !now.Before(leaf.NotAfter) {
+			return CheckResult{Status: StatusFail, Message: fmt.Sprintf("certificate expired at %s", expiry)}
+		} else if leaf.NotAfter.Sub(now) < certExpiryMargin {
+			return CheckResult{Status: StatusWarn, Message: fmt.Sprintf("certificate expires at %s, within %v", expiry, certExpiryMargin)}
+		}
+
+		return CheckResult{Status: StatusPass, Message: fmt.Sprintf("certificate expires at %s", expiry)}
+	}
+}
+
+// diskCheck checks that there is headroom on disk for cache
+func diskCheck(dir string, usage func(dir string) (total, free uint64, err error)) func(ctx context.Context, now time.Time) CheckResult {
+	return func(ctx context.Context, now time.Time) CheckResult {
+		total, free, err := usage(dir)
+		if err != nil {
+			return CheckResult{Status: StatusWarn, Message: fmt.Sprintf("failed to get disk usage of %s, %v", dir, err)}
+		} else if total == 0 {
+			return CheckResult{Status: StatusWarn, Message: fmt.Sprintf("size of disk for %s is unknown", dir)}
+		}
+
+		percent := free * 100 / total
+		message := fmt.Sprintf("%d MiB(%d%%) is free on disk of %s", free/1024/1024, percent, dir)
+		if free < diskMinFreeBytes || percent < diskMinFreePercent {
+			return CheckResult{Status: StatusFail, Message: message}
+		} else if percent < diskWarnFreePercent {
+			return CheckResult{Status: StatusWarn, Message: message}
+		}
+
+		return CheckResult{Status: StatusPass, Message: message}
+	}
+}
+
+// clockCheck checks that the clock is not skewed from cloud
+func clockCheck(skew func(ctx context.Context) (time.Duration, error)) func(ctx context.Context, now time.Time) CheckResult {
+	return func(ctx context.Context, now time.Time) CheckResult {
+		d, err := skew(ctx)
+		if err != nil {
+			return CheckResult{Status: StatusWarn, Message: fmt.Sprintf("clock skew is unknown, %v", err)}
+		}
+
+		if d < 0 {
+			d = -d
+		}
+		message := fmt.Sprintf("clock skew from cloud is %v", d.Round(time.Second))
+		if d > clockMaxSkew {
+			return CheckResult{Status: StatusFail, Message: message}
+		} else if d > clockWarnSkew {
+			return CheckResult{Status: StatusWarn, Message: message}
+		}
+
+		return CheckResult{Status: StatusPass, Message: message}
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux
+// +build linux
+
+package readiness
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// diskUsage returns the total and available bytes of the filesystem of dir
+func diskUsage(dir string) (total, free uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, 0, err
+	}
+
+	return st.Blocks * uint64(st.Bsize), st.Bavail * uint64(st.Bsize), nil
+}
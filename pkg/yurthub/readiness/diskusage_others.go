@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package readiness
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func diskUsage(dir string) (total, free uint64, err error) {
+	return 0, 0, fmt.Errorf("disk usage is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,37 @@
+package readiness
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const readinessSubsystem = "yurthub_autonomy_readiness"
+
+var (
+	score = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: readinessSubsystem,
+			Name:      "score",
+			Help:      "Gauge measuring the score(0-100) of autonomy readiness of node.",
+		},
+	)
+	checkStatus = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: readinessSubsystem,
+			Name:      "check",
+			Help:      "Gauge measuring the result of autonomy readiness checks, 1 for pass, 0.5 for warn and 0 for fail.",
+		},
+		[]string{"check"},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics of autonomy readiness.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(score)
+		prometheus.MustRegister(checkStatus)
+	})
+}
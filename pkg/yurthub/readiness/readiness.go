@@ -0,0 +1,294 @@
+package readiness
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// ReadinessAnnotation is the annotation of node that yurthub reports the summary
+	// of autonomy readiness in, the value is the json of Summary.
+	ReadinessAnnotation = "openyurt.io/autonomy-readiness"
+	// evaluatePeriod is the period that autonomy readiness is evaluated and reported
+	evaluatePeriod = time.Minute
+	// evaluateTimeout bounds the time of an evaluation
+	evaluateTimeout = 10 * time.Second
+)
+
+// Status is the result of a readiness check
+type Status string
+
+const (
+	// StatusPass means the check is passed
+	StatusPass Status = "Pass"
+	// StatusWarn means the node may not survive a long outage of cloud
+	StatusWarn Status = "Warn"
+	// StatusFail means the node would not survive an outage of cloud
+	StatusFail Status = "Fail"
+)
+
+// CheckResult is the result of a readiness check with the reason
+type CheckResult struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the result of an evaluation of autonomy readiness. Score is the weighted
+// percentage of passed checks(a warned check counts half), and the node is ready for
+// autonomy only if none of the checks failed.
+type Report struct {
+	Ready       bool          `json:"ready"`
+	Score       int           `json:"score"`
+	EvaluatedAt time.Time     `json:"evaluatedAt"`
+	Checks      []CheckResult `json:"checks"`
+}
+
+// Summary is the part of Report that is reported in annotation of node, messages and time
+// are left out, so the annotation is only updated when the readiness is changed.
+type Summary struct {
+	Ready    bool     `json:"ready"`
+	Score    int      `json:"score"`
+	Warnings []string `json:"warnings,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// check is a readiness check with its weight in score
+type check struct {
+	name   string
+	weight int
+	run    func(ctx context.Context, now time.Time) CheckResult
+}
+
+// Evaluator evaluates whether the node is ready for autonomy, by checking that the cache
+// is complete, the certificate doesn't expire soon, there is headroom on disk for cache,
+// and the clock is not skewed from cloud(certificates can not be verified with a skewed clock).
+type Evaluator struct {
+	sync.Mutex
+	nodeName         string
+	checks           []check
+	last             *Report
+	lastSummary      string
+	kubeClient       clientset.Interface
+	transportManager transport.Interface
+	stopCh           <-chan struct{}
+}
+
+// NewEvaluator creates an Evaluator for the node of yurthub
+func NewEvaluator(cfg *config.YurtHubConfiguration,
+	store storage.Store,
+	certManager interfaces.YurtCertificateManager,
+	transportManager transport.Interface,
+	healthChecker healthchecker.HealthChecker,
+	cacheDir string,
+	stopCh <-chan struct{}) *Evaluator {
+	Register()
+
+	clock := &clockChecker{
+		servers:       cfg.RemoteServers,
+		healthChecker: healthChecker,
+		client:        transportManager.HealthzHttpClient,
+	}
+	return &Evaluator{
+		nodeName: cfg.NodeName,
+		checks: []check{
+			{name: "cache", weight: 40, run: cacheCheck(store, cfg.NodeName)},
+			{name: "certificate", weight: 25, run: certificateCheck(func() *tls.Certificate {
+				return certManager.Current()
+			})},
+			{name: "disk", weight: 20, run: diskCheck(cacheDir, diskUsage)},
+			{name: "clock", weight: 15, run: clockCheck(clock.skew)},
+		},
+		transportManager: transportManager,
+		stopCh:           stopCh,
+	}
+}
+
+// Evaluate runs all checks and returns the report
+func (e *Evaluator) Evaluate(ctx context.Context) *Report {
+	now := time.Now()
+	report := &Report{
+		EvaluatedAt: now,
+		Checks:      make([]CheckResult, 0, len(e.checks)),
+	}
+
+	var total, passed float64
+	ready := true
+	for _, c := range e.checks {
+		result := c.run(ctx, now)
+		result.Name = c.name
+		report.Checks = append(report.Checks, result)
+
+		total += float64(c.weight)
+		switch result.Status {
+		case StatusPass:
+			passed += float64(c.weight)
+		case StatusWarn:
+			passed += float64(c.weight) / 2
+		default:
+			ready = false
+		}
+		checkStatus.WithLabelValues(c.name).Set(statusValue(result.Status))
+	}
+
+	if total > 0 {
+		report.Score = int(math.Round(passed * 100 / total))
+	}
+	report.Ready = ready
+	score.Set(float64(report.Score))
+
+	e.Lock()
+	e.last = report
+	e.Unlock()
+	return report
+}
+
+// Last returns the report of last evaluation, nil is returned if it's not evaluated yet
+func (e *Evaluator) Last() *Report {
+	e.Lock()
+	defer e.Unlock()
+	return e.last
+}
+
+// Run evaluates the autonomy readiness periodically, and reports the summary in annotation of node
+func (e *Evaluator) Run() {
+	go wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), evaluateTimeout)
+		defer cancel()
+
+		report := e.Evaluate(ctx)
+		if !report.Ready {
+			klog.Warningf("node %s is not ready for autonomy, score %d, %v", e.nodeName, report.Score, report.Checks)
+		}
+		e.reportSummary(report)
+	}, evaluatePeriod, e.stopCh)
+}
+
+// Summarize returns the summary of report
+func (r *Report) Summarize() *Summary {
+	s := &Summary{
+		Ready: r.Ready,
+		Score: r.Score,
+	}
+	for _, c := range r.Checks {
+		switch c.Status {
+		case StatusWarn:
+			s.Warnings = append(s.Warnings, c.Name)
+		case StatusFail:
+			s.Failures = append(s.Failures, c.Name)
+		}
+	}
+	sort.Strings(s.Warnings)
+	sort.Strings(s.Failures)
+	return s
+}
+
+// reportSummary records the summary of report in annotation of node if the summary is changed
+func (e *Evaluator) reportSummary(report *Report) {
+	b, err := json.Marshal(report.Summarize())
+	if err != nil {
+		klog.Errorf("failed to encode summary of autonomy readiness, %v", err)
+		return
+	}
+	summary := string(b)
+	if summary == e.lastSummary {
+		return
+	}
+
+	if e.kubeClient == nil {
+		// rest config is not ready until the certificate of yurthub is prepared
+		cfg := e.transportManager.GetRestClientConfig()
+		if cfg == nil {
+			return
+		}
+		client, err := clientset.NewForConfig(cfg)
+		if err != nil {
+			klog.Errorf("could not new kube client, %v", err)
+			return
+		}
+		e.kubeClient = client
+	}
+
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, ReadinessAnnotation, summary)
+	if _, err := e.kubeClient.CoreV1().Nodes().Patch(e.nodeName, types.StrategicMergePatchType, []byte(patch)); err != nil {
+		klog.Errorf("failed to report autonomy readiness for node %s, %v", e.nodeName, err)
+		return
+	}
+	e.lastSummary = summary
+}
+
+// clockChecker estimates the clock skew by the Date header of healthz responses of remote servers
+type clockChecker struct {
+	servers       []*url.URL
+	healthChecker healthchecker.HealthChecker
+	client        func() *http.Client
+}
+
+func (c *clockChecker) skew(ctx context.Context) (time.Duration, error) {
+	var lastErr error
+	for _, server := range c.servers {
+		if !c.healthChecker.IsHealthy(server) {
+			continue
+		}
+
+		u := *server
+		u.Path = "/healthz"
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		start := time.Now()
+		resp, err := c.client().Do(req.WithContext(ctx))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		rtt := time.Since(start)
+
+		serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			lastErr = fmt.Errorf("no valid Date header in response of %s, %v", u.String(), err)
+			continue
+		}
+		// the Date header is truncated to seconds, so the skew is accurate to about a second
+		return start.Add(rtt / 2).Sub(serverTime), nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no healthy remote server")
+	}
+	return 0, lastErr
+}
+
+func statusValue(status Status) float64 {
+	switch status {
+	case StatusPass:
+		return 1
+	case StatusWarn:
+		return 0.5
+	default:
+		return 0
+	}
+}
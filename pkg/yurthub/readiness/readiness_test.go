@@ -0,0 +1,251 @@
+package readiness
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+type statStore struct {
+	storage.Store
+	infos []storage.KeyInfo
+}
+
+func (s *statStore) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	return s.infos, nil
+}
+
+func TestCacheCheck(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		infos  []storage.KeyInfo
+		status Status
+	}{
+		"node is not cached": {
+			infos:  []storage.KeyInfo{{Key: "kubelet/pods/default/pod1", ModTime: now}},
+			status: StatusFail,
+		},
+		"no pods": {
+			infos:  []storage.KeyInfo{{Key: "kubelet/nodes/node1", ModTime: now}},
+			status: StatusWarn,
+		},
+		"stale cache": {
+			infos: []storage.KeyInfo{
+				{Key: "kubelet/nodes/node1", ModTime: now.Add(-2 * time.Hour)},
+				{Key: "kubelet/pods/default/pod1", ModTime: now.Add(-3 * time.Hour)},
+			},
+			status: StatusWarn,
+		},
+		"complete cache": {
+			infos: []storage.KeyInfo{
+				{Key: "kubelet/nodes/node1", ModTime: now.Add(-time.Minute)},
+				{Key: "kubelet/pods/default/pod1", ModTime: now.Add(-3 * time.Hour)},
+			},
+			status: StatusPass,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			result := cacheCheck(&statStore{infos: tt.infos}, "node1")(context.Background(), now)
+			if result.Status != tt.status {
+				t.Errorf("expect status %s, but got %s(%s)", tt.status, result.Status, result.Message)
+			}
+		})
+	}
+
+	// modification time is not recorded by fake storage
+	store, _ := fake.NewFakeStorage()
+	store.Create(context.Background(), "kubelet/nodes/node1", []byte("node1"))
+	store.Create(context.Background(), "kubelet/pods/default/pod1", []byte("pod1"))
+	if result := cacheCheck(store, "node1")(context.Background(), now); result.Status != StatusPass {
+		t.Errorf("expect status %s, but got %s(%s)", StatusPass, result.Status, result.Message)
+	}
+}
+
+func TestCertificateCheck(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	testcases := map[string]struct {
+		cert   *tls.Certificate
+		status Status
+	}{
+		"no certificate": {
+			status: StatusFail,
+		},
+		"expired": {
+			cert:   &tls.Certificate{Certificate: [][]byte{{}}, Leaf: &x509.Certificate{NotAfter: now.Add(-time.Hour)}},
+			status: StatusFail,
+		},
+		"expires soon": {
+			cert:   &tls.Certificate{Certificate: [][]byte{{}}, Leaf: &x509.Certificate{NotAfter: now.Add(24 * time.Hour)}},
+			status: StatusWarn,
+		},
+		"valid": {
+			cert:   &tls.Certificate{Certificate: [][]byte{{}}, Leaf: &x509.Certificate{NotAfter: now.Add(365 * 24 * time.Hour)}},
+			status: StatusPass,
+		},
+		"invalid certificate": {
+			cert:   &tls.Certificate{Certificate: [][]byte{[]byte("invalid")}},
+			status: StatusFail,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			result := certificateCheck(func() *tls.Certificate { return tt.cert })(context.Background(), now)
+			if result.Status != tt.status {
+				t.Errorf("expect status %s, but got %s(%s)", tt.status, result.Status, result.Message)
+			}
+		})
+	}
+}
+
+func TestDiskCheck(t *testing.T) {
+	const gib = 1024 * 1024 * 1024
+	testcases := map[string]struct {
+		total  uint64
+		free   uint64
+		err    error
+		status Status
+	}{
+		"unknown usage": {
+			err:    errors.New("not supported"),
+			status: StatusWarn,
+		},
+		"less than min percent": {
+			total:  100 * gib,
+			free:   4 * gib,
+			status: StatusFail,
+		},
+		"less than min bytes": {
+			total:  1 * gib,
+			free:   50 * 1024 * 1024,
+			status: StatusFail,
+		},
+		"running low": {
+			total:  100 * gib,
+			free:   10 * gib,
+			status: StatusWarn,
+		},
+		"enough space": {
+			total:  100 * gib,
+			free:   50 * gib,
+			status: StatusPass,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			usage := func(dir string) (uint64, uint64, error) { return tt.total, tt.free, tt.err }
+			result := diskCheck("/cache", usage)(context.Background(), time.Now())
+			if result.Status != tt.status {
+				t.Errorf("expect status %s, but got %s(%s)", tt.status, result.Status, result.Message)
+			}
+		})
+	}
+}
+
+func TestClockCheck(t *testing.T) {
+	testcases := map[string]struct {
+		skew   time.Duration
+		err    error
+		status Status
+	}{
+		"unknown skew": {
+			err:    errors.New("no healthy remote server"),
+			status: StatusWarn,
+		},
+		"skewed behind": {
+			skew:   -10 * time.Minute,
+			status: StatusFail,
+		},
+		"skewed ahead": {
+			skew:   time.Minute,
+			status: StatusWarn,
+		},
+		"in sync": {
+			skew:   time.Second,
+			status: StatusPass,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			skew := func(ctx context.Context) (time.Duration, error) { return tt.skew, tt.err }
+			result := clockCheck(skew)(context.Background(), time.Now())
+			if result.Status != tt.status {
+				t.Errorf("expect status %s, but got %s(%s)", tt.status, result.Status, result.Message)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	result := func(status Status) func(ctx context.Context, now time.Time) CheckResult {
+		return func(ctx context.Context, now time.Time) CheckResult {
+			return CheckResult{Status: status}
+		}
+	}
+
+	testcases := map[string]struct {
+		checks  []check
+		ready   bool
+		score   int
+		summary Summary
+	}{
+		"all passed": {
+			checks: []check{
+				{name: "a", weight: 60, run: result(StatusPass)},
+				{name: "b", weight: 40, run: result(StatusPass)},
+			},
+			ready:   true,
+			score:   100,
+			summary: Summary{Ready: true, Score: 100},
+		},
+		"warned": {
+			checks: []check{
+				{name: "a", weight: 60, run: result(StatusPass)},
+				{name: "b", weight: 40, run: result(StatusWarn)},
+			},
+			ready:   true,
+			score:   80,
+			summary: Summary{Ready: true, Score: 80, Warnings: []string{"b"}},
+		},
+		"failed": {
+			checks: []check{
+				{name: "a", weight: 60, run: result(StatusFail)},
+				{name: "b", weight: 20, run: result(StatusWarn)},
+				{name: "c", weight: 20, run: result(StatusPass)},
+			},
+			ready:   false,
+			score:   30,
+			summary: Summary{Ready: false, Score: 30, Warnings: []string{"b"}, Failures: []string{"a"}},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			e := &Evaluator{checks: tt.checks}
+			report := e.Evaluate(context.Background())
+			if report.Ready != tt.ready || report.Score != tt.score {
+				t.Errorf("expect ready %v with score %d, but got ready %v with score %d", tt.ready, tt.score, report.Ready, report.Score)
+			}
+			if len(report.Checks) != len(tt.checks) || report.Checks[0].Name != "a" {
+				t.Errorf("expect results of %d checks, but got %v", len(tt.checks), report.Checks)
+			}
+			if e.Last() != report {
+				t.Errorf("expect last report is recorded")
+			}
+			if summary := report.Summarize(); !reflect.DeepEqual(*summary, tt.summary) {
+				t.Errorf("expect summary %#v, but got %#v", tt.summary, *summary)
+			}
+		})
+	}
+}
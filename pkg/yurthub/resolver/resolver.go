@@ -0,0 +1,235 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"k8s.io/klog"
+)
+
+const (
+	// cacheKey is the key in storage that the resolved addresses are cached
+	cacheKey = "_internal/resolver/hosts.json"
+	// storageTimeout bounds the time of reading and writing the cache
+	storageTimeout = 10 * time.Second
+)
+
+// Resolver resolves the host names of remote servers. static hosts(from flag or hosts file)
+// take precedence over dns, and the addresses resolved by dns are cached in storage, so
+// yurthub can connect remote servers by the cached addresses when dns of the site is
+// unavailable(like after the node is rebooted).
+type Resolver struct {
+	sync.RWMutex
+	static map[string][]string
+	cache  map[string][]string
+	store  storage.Store
+	lookup func(ctx context.Context, host string) ([]string, error)
+	dialer *net.Dialer
+}
+
+// NewResolver creates a Resolver with the static hosts in staticHosts(like "host=ip")
+// and hostsFile(in the format of /etc/hosts), the dns cache is disabled if store is nil.
+func NewResolver(staticHosts []string, hostsFile string, store storage.Store) (*Resolver, error) {
+	static, err := ParseStaticHosts(staticHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostsFile != "" {
+		fileHosts, err := parseHostsFile(hostsFile)
+		if err != nil {
+			return nil, err
+		}
+		// hosts from flag override the hosts from file
+		for host, addrs := range fileHosts {
+			if _, ok := static[host]; !ok {
+				static[host] = addrs
+			}
+		}
+	}
+
+	r := &Resolver{
+		static: static,
+		cache:  make(map[string][]string),
+		store:  store,
+		lookup: net.DefaultResolver.LookupHost,
+		dialer: &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	r.loadCache()
+
+	return r, nil
+}
+
+// ParseStaticHosts parses the static hosts in the format of "host=ip", the
+// same host can be specified multiple times for multiple addresses.
+func ParseStaticHosts(staticHosts []string) (map[string][]string, error) {
+	hosts := make(map[string][]string)
+	for _, entry := range staticHosts {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("static host(%s) is invalid, the format is host=ip", entry)
+		}
+
+		host, addr := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("address(%s) of static host %s is not an ip", addr, host)
+		}
+		hosts[host] = append(hosts[host], addr)
+	}
+
+	return hosts, nil
+}
+
+// parseHostsFile parses the file in the format of /etc/hosts, like "ip host1 host2 # comment"
+func parseHostsFile(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open hosts file %s, %v", path, err)
+	}
+	defer f.Close()
+
+	hosts := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		if net.ParseIP(fields[0]) == nil {
+			klog.Warningf("skip line of hosts file %s, %s is not an ip", path, fields[0])
+			continue
+		}
+		for _, host := range fields[1:] {
+			host = strings.ToLower(host)
+			hosts[host] = append(hosts[host], fields[0])
+		}
+	}
+
+	return hosts, scanner.Err()
+}
+
+// LookupHost returns the addresses of host, static hosts are returned first, then
+// the addresses resolved by dns, and the cached addresses if dns is failed.
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	host = strings.ToLower(host)
+	if addrs, ok := r.static[host]; ok {
+		return addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err == nil && len(addrs) != 0 {
+		r.updateCache(host, addrs)
+		return addrs, nil
+	}
+
+	r.RLock()
+	cached, ok := r.cache[host]
+	r.RUnlock()
+	if ok && len(cached) != 0 {
+		klog.Warningf("failed to resolve %s, %v, use cached addresses %v", host, err, cached)
+		return cached, nil
+	}
+
+	if err == nil {
+		err = fmt.Errorf("no address is resolved for %s", host)
+	}
+	return nil, err
+}
+
+// DialContext connects the address by the addresses resolved for its host in order,
+// until a connection is established.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+func (r *Resolver) updateCache(host string, addrs []string) {
+	sorted := append([]string(nil), addrs...)
+	sort.Strings(sorted)
+
+	r.Lock()
+	defer r.Unlock()
+	if reflect.DeepEqual(r.cache[host], sorted) {
+		return
+	}
+	r.cache[host] = sorted
+
+	if r.store == nil {
+		return
+	}
+
+	b, err := json.Marshal(r.cache)
+	if err != nil {
+		klog.Errorf("failed to encode resolved addresses, %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	if err := r.store.Update(ctx, cacheKey, b); err != nil {
+		klog.Errorf("failed to cache resolved addresses of %s, %v", host, err)
+	}
+}
+
+func (r *Resolver) loadCache() {
+	if r.store == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	b, err := r.store.Get(ctx, cacheKey)
+	if err != nil {
+		if err != storage.ErrNotFound {
+			klog.Errorf("failed to load cached addresses, %v", err)
+		}
+		return
+	}
+
+	cache := make(map[string][]string)
+	if err := json.Unmarshal(b, &cache); err != nil {
+		klog.Errorf("failed to decode cached addresses, %v", err)
+		return
+	}
+	r.cache = cache
+	klog.Infof("load cached addresses of %d hosts", len(cache))
+}
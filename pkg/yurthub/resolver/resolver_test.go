@@ -0,0 +1,152 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+func TestParseStaticHosts(t *testing.T) {
+	testcases := map[string]struct {
+		hosts    []string
+		expected map[string][]string
+		err      bool
+	}{
+		"no hosts": {
+			expected: map[string][]string{},
+		},
+		"multiple addresses": {
+			hosts: []string{"APISERVER.local=10.0.0.1", "apiserver.local=10.0.0.2", "lb=fd00::1"},
+			expected: map[string][]string{
+				"apiserver.local": {"10.0.0.1", "10.0.0.2"},
+				"lb":              {"fd00::1"},
+			},
+		},
+		"no address": {
+			hosts: []string{"apiserver.local"},
+			err:   true,
+		},
+		"address is not ip": {
+			hosts: []string{"apiserver.local=lb.local"},
+			err:   true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			hosts, err := ParseStaticHosts(tt.hosts)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(hosts, tt.expected) {
+				t.Errorf("expect hosts %v, but got %v", tt.expected, hosts)
+			}
+		})
+	}
+}
+
+func TestHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolver")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostsFile := filepath.Join(dir, "hosts")
+	content := "# comment\n10.0.0.1 apiserver.local lb.local # inline comment\nbad-ip other.local\n\n10.0.0.3 kube.local\n"
+	if err := ioutil.WriteFile(hostsFile, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write hosts file, %v", err)
+	}
+
+	r, err := NewResolver([]string{"kube.local=10.0.0.2"}, hostsFile, nil)
+	if err != nil {
+		t.Fatalf("failed to new resolver, %v", err)
+	}
+
+	expected := map[string][]string{
+		"apiserver.local": {"10.0.0.1"},
+		"lb.local":        {"10.0.0.1"},
+		"kube.local":      {"10.0.0.2"},
+	}
+	if !reflect.DeepEqual(r.static, expected) {
+		t.Errorf("expect static hosts %v, but got %v", expected, r.static)
+	}
+
+	if _, err := NewResolver(nil, filepath.Join(dir, "not-exist"), nil); err == nil {
+		t.Errorf("expect error for hosts file that doesn't exist, but got nil")
+	}
+}
+
+func TestLookupHost(t *testing.T) {
+	store, err := fake.NewFakeStorage()
+	if err != nil {
+		t.Fatalf("failed to new fake storage, %v", err)
+	}
+
+	r, err := NewResolver([]string{"static.local=10.0.0.9"}, "", store)
+	if err != nil {
+		t.Fatalf("failed to new resolver, %v", err)
+	}
+
+	var dnsAddrs []string
+	var dnsErr error
+	lookups := 0
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return dnsAddrs, dnsErr
+	}
+
+	// ip is returned without lookup
+	if addrs, err := r.LookupHost(context.Background(), "10.0.0.1"); err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) {
+		t.Errorf("expect addresses [10.0.0.1], but got %v, %v", addrs, err)
+	}
+
+	// static hosts take precedence over dns
+	dnsAddrs = []string{"10.0.0.8"}
+	if addrs, err := r.LookupHost(context.Background(), "Static.Local"); err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.9"}) {
+		t.Errorf("expect addresses [10.0.0.9], but got %v, %v", addrs, err)
+	}
+	if lookups != 0 {
+		t.Errorf("expect no dns lookup, but got %d", lookups)
+	}
+
+	// host resolved by dns is cached
+	dnsAddrs = []string{"10.0.0.3", "10.0.0.2"}
+	if addrs, err := r.LookupHost(context.Background(), "apiserver.local"); err != nil || !reflect.DeepEqual(addrs, dnsAddrs) {
+		t.Errorf("expect addresses %v, but got %v, %v", dnsAddrs, addrs, err)
+	}
+
+	// cached addresses are used when dns is failed
+	dnsAddrs, dnsErr = nil, errors.New("dns is unavailable")
+	expected := []string{"10.0.0.2", "10.0.0.3"}
+	if addrs, err := r.LookupHost(context.Background(), "apiserver.local"); err != nil || !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expect cached addresses %v, but got %v, %v", expected, addrs, err)
+	}
+
+	// error is returned for host that is not cached
+	if _, err := r.LookupHost(context.Background(), "other.local"); err != dnsErr {
+		t.Errorf("expect error %v, but got %v", dnsErr, err)
+	}
+
+	// cache is loaded from storage by a new resolver(like after reboot)
+	r2, err := NewResolver(nil, "", store)
+	if err != nil {
+		t.Fatalf("failed to new resolver, %v", err)
+	}
+	r2.lookup = r.lookup
+	if addrs, err := r2.LookupHost(context.Background(), "apiserver.local"); err != nil || !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("expect cached addresses %v after restart, but got %v, %v", expected, addrs, err)
+	}
+}
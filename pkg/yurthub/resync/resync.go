@@ -0,0 +1,271 @@
+package resync
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/healthchecker"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog"
+)
+
+const (
+	// versionKey is the key in storage that the version of kube-apiserver that the cache
+	// is synced with is recorded, so an upgrade is detected across restarts of yurthub.
+	versionKey = "_internal/resync/apiserver.version"
+	// checkPeriod is the period that the connection to remote servers is checked
+	checkPeriod = 30 * time.Second
+	// storageTimeout bounds the time of reading and writing a key
+	storageTimeout = 10 * time.Second
+	// resyncQPS and resyncBurst limit the rate of refreshing cached objects, so a resync
+	// doesn't starve the requests of clients for storage.
+	resyncQPS   = 10
+	resyncBurst = 20
+)
+
+// Resyncer detects the minor version change of kube-apiserver after yurthub is reconnected
+// to remote servers, and refreshes the cached objects in the api versions that are not served
+// any more, so the objects in deprecated api versions are not served to the restarted clients.
+// the objects are converted to the preferred version of their groups, or deleted if they can't
+// be converted, and then they are cached again when clients list them.
+type Resyncer struct {
+	store            storage.Store
+	storageWrapper   cachemanager.StorageWrapper
+	remoteServers    []*url.URL
+	healthChecker    healthchecker.HealthChecker
+	transportManager transport.Interface
+	limiter          flowcontrol.RateLimiter
+	newDiscovery     func() (discovery.DiscoveryInterface, error)
+	connected        bool
+	stopCh           <-chan struct{}
+}
+
+// NewResyncer creates a Resyncer for the cache in store
+func NewResyncer(cfg *config.YurtHubConfiguration,
+	store storage.Store,
+	storageWrapper cachemanager.StorageWrapper,
+	transportManager transport.Interface,
+	healthChecker healthchecker.HealthChecker,
+	stopCh <-chan struct{}) *Resyncer {
+	r := &Resyncer{
+		store:            store,
+		storageWrapper:   storageWrapper,
+		remoteServers:    cfg.RemoteServers,
+		healthChecker:    healthChecker,
+		transportManager: transportManager,
+		limiter:          flowcontrol.NewTokenBucketRateLimiter(resyncQPS, resyncBurst),
+		stopCh:           stopCh,
+	}
+	r.newDiscovery = r.discoveryClient
+	return r
+}
+
+// Run checks the version of kube-apiserver every time yurthub is reconnected to remote servers
+func (r *Resyncer) Run() {
+	go wait.Until(r.check, checkPeriod, r.stopCh)
+}
+
+func (r *Resyncer) check() {
+	healthy := false
+	for _, server := range r.remoteServers {
+		if r.healthChecker.IsHealthy(server) {
+			healthy = true
+			break
+		}
+	}
+	if !healthy {
+		r.connected = false
+		return
+	} else if r.connected {
+		return
+	}
+
+	dc, err := r.newDiscovery()
+	if err != nil {
+		klog.Errorf("could not new discovery client, %v", err)
+		return
+	}
+	if err := r.sync(dc); err != nil {
+		// retried in the next check
+		klog.Errorf("failed to resync cache with kube-apiserver, %v", err)
+		return
+	}
+	r.connected = true
+}
+
+// sync compares the version of kube-apiserver with the recorded one, and resyncs the
+// cache if the minor version is changed. the version is recorded only when the cache
+// is resynced, so an interrupted resync is retried.
+func (r *Resyncer) sync(dc discovery.DiscoveryInterface) error {
+	info, err := dc.ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get version of kube-apiserver, %v", err)
+	}
+	current := minorVersion(info)
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	last, err := r.store.Get(ctx, versionKey)
+	cancel()
+	if err != nil && err != storage.ErrNotFound {
+		return fmt.Errorf("failed to get recorded version of kube-apiserver, %v", err)
+	}
+
+	if len(last) != 0 && string(last) != current {
+		klog.Infof("kube-apiserver is upgraded from %s to %s, resync cache", string(last), current)
+		if err := r.resync(dc); err != nil {
+			return err
+		}
+	}
+
+	if string(last) == current {
+		return nil
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	return r.store.Update(ctx, versionKey, []byte(current))
+}
+
+// resync refreshes the cached objects in the api versions that are not served by kube-apiserver
+func (r *Resyncer) resync(dc discovery.DiscoveryInterface) error {
+	groups, resources, err := dc.ServerGroupsAndResources()
+	if err != nil {
+		// objects of the groups that failed in discovery would be deleted by mistake
+		return fmt.Errorf("failed to discover api resources, %v", err)
+	}
+	served, preferred := servedVersions(groups, resources)
+
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	keys, err := r.store.ListKeys(ctx, "")
+	cancel()
+	if err != nil {
+		return fmt.Errorf("failed to list keys of cache, %v", err)
+	}
+
+	converted, deleted := 0, 0
+	for _, key := range keys {
+		comp, resource, _, _ := util.SplitKey(key)
+		if len(resource) == 0 || strings.HasPrefix(comp, "_") || strings.HasPrefix(resource, "_") {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+		obj, err := r.storageWrapper.Get(ctx, key)
+		cancel()
+		if err != nil {
+			continue
+		}
+		gvk := obj.GetObjectKind().GroupVersionKind()
+		gr := schema.GroupResource{Group: gvk.Group, Resource: resource}
+		if len(gvk.Version) == 0 || served[gr].Has(gvk.Version) {
+			continue
+		}
+
+		select {
+		case <-r.stopCh:
+			return fmt.Errorf("resync is stopped")
+		default:
+		}
+		r.limiter.Accept()
+
+		ctx, cancel = context.WithTimeout(context.Background(), storageTimeout)
+		if version, ok := preferred[gr]; ok {
+			out, err := serializer.ConvertToVersion(obj, schema.GroupVersion{Group: gvk.Group, Version: version})
+			if err == nil {
+				if err = r.storageWrapper.Update(ctx, key, out); err == nil {
+					converted++
+					cancel()
+					continue
+				}
+			}
+			klog.Warningf("failed to convert %s from %v to version %s, delete it, %v", key, gvk, version, err)
+		}
+
+		if err := r.storageWrapper.Delete(ctx, key); err != nil {
+			klog.Errorf("failed to delete %s in %v, %v", key, gvk, err)
+		} else {
+			deleted++
+		}
+		cancel()
+	}
+
+	klog.Infof("cache is resynced with kube-apiserver, %d objects converted, %d objects deleted", converted, deleted)
+	return nil
+}
+
+func (r *Resyncer) discoveryClient() (discovery.DiscoveryInterface, error) {
+	// rest config is not ready until the certificate of yurthub is prepared
+	cfg := r.transportManager.GetRestClientConfig()
+	if cfg == nil {
+		return nil, fmt.Errorf("rest config is not ready")
+	}
+	return discovery.NewDiscoveryClientForConfig(cfg)
+}
+
+// servedVersions returns the served versions of resources, and the version of resources
+// that they are converted to, which is the preferred version of group if it serves the
+// resource, or the first version that serves the resource.
+func servedVersions(groups []*metav1.APIGroup, resources []*metav1.APIResourceList) (map[schema.GroupResource]sets.String, map[schema.GroupResource]string) {
+	served := make(map[schema.GroupResource]sets.String)
+	for _, list := range resources {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			// subresources(like pods/status) are cached as their resources
+			if strings.Contains(resource.Name, "/") {
+				continue
+			}
+			gr := schema.GroupResource{Group: gv.Group, Resource: resource.Name}
+			if served[gr] == nil {
+				served[gr] = sets.NewString()
+			}
+			served[gr].Insert(gv.Version)
+		}
+	}
+
+	preferred := make(map[schema.GroupResource]string)
+	for _, group := range groups {
+		for gr, versions := range served {
+			if gr.Group != group.Name {
+				continue
+			}
+			if versions.Has(group.PreferredVersion.Version) {
+				preferred[gr] = group.PreferredVersion.Version
+				continue
+			}
+			for _, v := range group.Versions {
+				if versions.Has(v.Version) {
+					preferred[gr] = v.Version
+					break
+				}
+			}
+		}
+	}
+	return served, preferred
+}
+
+// minorVersion returns the version of kube-apiserver in the format of "major.minor",
+// the suffix of provider(like "+" of "18+") is trimmed.
+func minorVersion(info *version.Info) string {
+	minor := strings.TrimRightFunc(info.Minor, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	return fmt.Sprintf("%s.%s", info.Major, minor)
+}
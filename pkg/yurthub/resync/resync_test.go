@@ -0,0 +1,162 @@
+package resync
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	v1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/discovery"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+type healthChecker struct {
+	healthy bool
+}
+
+func (hc *healthChecker) IsHealthy(server *url.URL) bool {
+	return hc.healthy
+}
+
+func TestMinorVersion(t *testing.T) {
+	testcases := map[string]struct {
+		info    version.Info
+		version string
+	}{
+		"plain": {
+			info:    version.Info{Major: "1", Minor: "16"},
+			version: "1.16",
+		},
+		"with provider suffix": {
+			info:    version.Info{Major: "1", Minor: "18+"},
+			version: "1.18",
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if v := minorVersion(&tt.info); v != tt.version {
+				t.Errorf("expect version %s, but got %s", tt.version, v)
+			}
+		})
+	}
+}
+
+func TestResync(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec())
+	ctx := context.Background()
+	objects := map[string]runtime.Object{
+		"kubelet/pods/default/pod1": &v1.Pod{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "default"},
+		},
+		"kubelet/leases/kube-node-lease/node1": &coordinationv1beta1.Lease{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "coordination.k8s.io/v1beta1", Kind: "Lease"},
+			ObjectMeta: metav1.ObjectMeta{Name: "node1", Namespace: "kube-node-lease"},
+		},
+		"kube-proxy/deployments/default/deploy1": &extensionsv1beta1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "extensions/v1beta1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{Name: "deploy1", Namespace: "default"},
+		},
+	}
+	for key, obj := range objects {
+		if err := sw.Create(ctx, key, obj); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+
+	dc := &fakediscovery.FakeDiscovery{
+		Fake:               &clienttesting.Fake{},
+		FakedServerVersion: &version.Info{Major: "1", Minor: "15"},
+	}
+	dc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods"}, {Name: "pods/status"}}},
+		{GroupVersion: "coordination.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "leases"}}},
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "deployments"}}},
+	}
+	hc := &healthChecker{}
+	r := &Resyncer{
+		store:          store,
+		storageWrapper: sw,
+		remoteServers:  []*url.URL{{Scheme: "https", Host: "127.0.0.1:6443"}},
+		healthChecker:  hc,
+		limiter:        flowcontrol.NewFakeAlwaysRateLimiter(),
+		newDiscovery: func() (discovery.DiscoveryInterface, error) {
+			return dc, nil
+		},
+		stopCh: make(chan struct{}),
+	}
+
+	// version is not checked until remote servers are healthy
+	r.check()
+	if _, err := store.Get(ctx, versionKey); err != storage.ErrNotFound {
+		t.Fatalf("expect version is not recorded, but got %v", err)
+	}
+
+	// version is recorded for the first time without resync
+	hc.healthy = true
+	r.check()
+	if b, _ := store.Get(ctx, versionKey); string(b) != "1.15" || !r.connected {
+		t.Fatalf("expect version 1.15 is recorded, but got %q", string(b))
+	}
+
+	// kube-apiserver is upgraded during the disconnection
+	dc.FakedServerVersion = &version.Info{Major: "1", Minor: "16+"}
+	dc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "v1", APIResources: []metav1.APIResource{{Name: "pods"}}},
+		{GroupVersion: "coordination.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "leases"}}},
+		{GroupVersion: "coordination.k8s.io/v1beta1", APIResources: []metav1.APIResource{{Name: "leases"}}},
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments"}}},
+	}
+	r.check()
+	if b, _ := store.Get(ctx, versionKey); string(b) != "1.15" {
+		t.Fatalf("expect version is not checked while connected, but got %q", string(b))
+	}
+	hc.healthy = false
+	r.check()
+	hc.healthy = true
+	r.check()
+
+	if b, _ := store.Get(ctx, versionKey); string(b) != "1.16" {
+		t.Errorf("expect version 1.16 is recorded, but got %q", string(b))
+	}
+	if _, err := sw.Get(ctx, "kubelet/pods/default/pod1"); err != nil {
+		t.Errorf("expect pod is kept, but got %v", err)
+	}
+	if obj, err := sw.Get(ctx, "kubelet/leases/kube-node-lease/node1"); err != nil {
+		t.Errorf("expect lease is kept, but got %v", err)
+	} else if apiVersion := obj.GetObjectKind().GroupVersionKind().Version; apiVersion != "v1beta1" {
+		t.Errorf("expect lease in served version is kept as is, but got %s", apiVersion)
+	}
+	// deployments are not served in extensions, and they are not converted to another group
+	if _, err := sw.Get(ctx, "kube-proxy/deployments/default/deploy1"); err != storage.ErrNotFound {
+		t.Errorf("expect deployment is deleted, but got %v", err)
+	}
+
+	// coordination.k8s.io/v1beta1 is removed in the next upgrade
+	dc.FakedServerVersion = &version.Info{Major: "1", Minor: "17"}
+	dc.Resources = dc.Resources[:2]
+	if err := r.sync(dc); err != nil {
+		t.Fatalf("failed to sync, %v", err)
+	}
+	obj, err := sw.Get(ctx, "kubelet/leases/kube-node-lease/node1")
+	if err != nil {
+		t.Fatalf("expect lease is converted, but got %v", err)
+	}
+	if gvk := obj.GetObjectKind().GroupVersionKind(); gvk.GroupVersion().String() != "coordination.k8s.io/v1" || gvk.Kind != "Lease" {
+		t.Errorf("expect lease is converted to coordination.k8s.io/v1, but got %v", gvk)
+	}
+}
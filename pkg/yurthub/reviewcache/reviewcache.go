@@ -0,0 +1,212 @@
+package reviewcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authenticationv1beta1 "k8s.io/api/authentication/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	authorizationv1beta1 "k8s.io/api/authorization/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/apimachinery/pkg/util/clock"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/klog"
+)
+
+const (
+	// deniedTTL bounds the time that a denied review is fresh, so the granted permissions
+	// take effect soon, it's the same as the unauthorized ttl of kubelet webhook authorizer.
+	deniedTTL = 30 * time.Second
+	// maxReviewSize bounds the size of review requests and responses that are cached,
+	// reviews are small, larger ones are passed through.
+	maxReviewSize = 64 * 1024
+	// cacheHeader tells the client whether the response is a fresh hit, a stale hit or a miss
+	cacheHeader = "X-Yurthub-Cache"
+)
+
+// cachedHeaders are the response headers that are cached with the body
+var cachedHeaders = []string{"Content-Type", "Content-Encoding"}
+
+// reviews are the resources of reviews that are cached, their results are decided by the spec
+// of reviews only, so the responses can be reused for the same requests.
+var reviews = map[string]string{
+	"authentication.k8s.io": "tokenreviews",
+	"authorization.k8s.io":  "subjectaccessreviews",
+}
+
+// cachedReview is the response of a review
+type cachedReview struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+	reviewedAt time.Time
+	ttl        time.Duration
+}
+
+// Cache caches the results of TokenReviews and SubjectAccessReviews that node agents(like kubelet
+// webhook authenticator and authorizer) create through yurthub. a cached result is served without
+// a round-trip to kube-apiserver while it's fresh(within ttl, denied results within 30 seconds at
+// most), and it's served as stale up to maxStale when kube-apiserver is unavailable, so the auth of
+// local requests keeps working during disconnection. results are cached in memory only and bounded
+// by size, the least recently used ones are evicted.
+type Cache struct {
+	ttl      time.Duration
+	maxStale time.Duration
+	reviews  *cache.LRUExpireCache
+	clock    clock.Clock
+}
+
+// NewCache creates a Cache for size reviews
+func NewCache(size int, ttl, maxStale time.Duration) *Cache {
+	return NewCacheWithClock(size, ttl, maxStale, clock.RealClock{})
+}
+
+// NewCacheWithClock creates a Cache that expires reviews by clk
+func NewCacheWithClock(size int, ttl, maxStale time.Duration, clk clock.Clock) *Cache {
+	if maxStale < ttl {
+		maxStale = ttl
+	}
+	return &Cache{
+		ttl:      ttl,
+		maxStale: maxStale,
+		reviews:  cache.NewLRUExpireCacheWithClock(size, clk),
+		clock:    clk,
+	}
+}
+
+// WithReviewCache serves the creates of reviews from cache, other requests are passed to handler.
+// a nil Cache caches nothing.
+func (c *Cache) WithReviewCache(handler http.Handler) http.Handler {
+	if c == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		info, ok := apirequest.RequestInfoFrom(req.Context())
+		if !ok || !info.IsResourceRequest || info.Verb != "create" || info.Subresource != "" || reviews[info.APIGroup] != info.Resource {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, maxReviewSize+1))
+		if err != nil {
+			req.Body.Close()
+			util.Err(apierrors.NewBadRequest(fmt.Sprintf("failed to read review, %v", err)), w, req)
+			return
+		}
+		if len(body) > maxReviewSize {
+			req.Body = &util.ReadCloser{Reader: io.MultiReader(bytes.NewReader(body), req.Body), Closer: req.Body}
+			handler.ServeHTTP(w, req)
+			return
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		key := cacheKey(req, body)
+		var cached *cachedReview
+		if v, ok := c.reviews.Get(key); ok {
+			cached = v.(*cachedReview)
+		}
+		if cached != nil && c.clock.Since(cached.reviewedAt) < cached.ttl {
+			writeReview(w, cached, "hit")
+			return
+		}
+
+		rw := util.NewResponseRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.StatusCode >= http.StatusInternalServerError && cached != nil {
+			klog.Warningf("serve stale result of %s reviewed at %s, %s is responded", util.ReqString(req), cached.reviewedAt.Format(time.RFC3339), http.StatusText(rw.StatusCode))
+			writeReview(w, cached, "stale")
+			return
+		}
+
+		review := &cachedReview{
+			statusCode: rw.StatusCode,
+			header:     http.Header{},
+			body:       rw.Body.Bytes(),
+			reviewedAt: c.clock.Now(),
+		}
+		for _, h := range cachedHeaders {
+			if v := rw.Header().Get(h); v != "" {
+				review.header.Set(h, v)
+			}
+		}
+		if review.ttl = c.ttlOf(review); review.ttl > 0 {
+			c.reviews.Add(key, review, c.maxStale)
+		}
+		for h, values := range rw.Header() {
+			for _, v := range values {
+				w.Header().Add(h, v)
+			}
+		}
+		w.Header().Set(cacheHeader, "miss")
+		w.WriteHeader(review.statusCode)
+		w.Write(review.body)
+	})
+}
+
+// ttlOf returns the time that review is fresh, 0 means review is not cached
+func (c *Cache) ttlOf(review *cachedReview) time.Duration {
+	if review.statusCode != http.StatusCreated && review.statusCode != http.StatusOK {
+		return 0
+	}
+	if review.header.Get("Content-Encoding") != "" || len(review.body) > maxReviewSize {
+		return 0
+	}
+
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(review.body, nil, nil)
+	if err != nil {
+		klog.Errorf("failed to decode review, %v", err)
+		return 0
+	}
+	allowed := false
+	switch r := obj.(type) {
+	case *authenticationv1.TokenReview:
+		allowed = r.Status.Authenticated
+	case *authenticationv1beta1.TokenReview:
+		allowed = r.Status.Authenticated
+	case *authorizationv1.SubjectAccessReview:
+		allowed = r.Status.Allowed
+	case *authorizationv1beta1.SubjectAccessReview:
+		allowed = r.Status.Allowed
+	default:
+		return 0
+	}
+	if !allowed && deniedTTL < c.ttl {
+		return deniedTTL
+	}
+	return c.ttl
+}
+
+// cacheKey is the key of review for the request, the credentials and the content types of request
+// are included, so reviews are not shared between agents with different credentials.
+func cacheKey(req *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, s := range []string{req.URL.Path, req.Header.Get("Authorization"), req.Header.Get("Accept"), req.Header.Get("Content-Type")} {
+		h.Write([]byte(s))
+		h.Write([]byte("\n"))
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeReview(w http.ResponseWriter, review *cachedReview, cacheStatus string) {
+	for h, values := range review.header {
+		for _, v := range values {
+			w.Header().Add(h, v)
+		}
+	}
+	w.Header().Set(cacheHeader, cacheStatus)
+	w.WriteHeader(review.statusCode)
+	w.Write(review.body)
+}
@@ -0,0 +1,160 @@
+package reviewcache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/endpoints/filters"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// fakeAPIServer responds the reviews, tokens in allowed are authenticated and users in allowed
+// are authorized, and it responds 503 when it's offline.
+type fakeAPIServer struct {
+	allowed  sets.String
+	offline  bool
+	requests int
+}
+
+func (s *fakeAPIServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.requests++
+	if s.offline {
+		util.Err(util.NewRemoteUnavailable("kube-apiserver is unreachable"), w, req)
+		return
+	}
+
+	var resp interface{}
+	if strings.Contains(req.URL.Path, "tokenreviews") {
+		review := &authenticationv1.TokenReview{}
+		json.NewDecoder(req.Body).Decode(review)
+		review.TypeMeta = metav1.TypeMeta{APIVersion: "authentication.k8s.io/v1", Kind: "TokenReview"}
+		review.Status.Authenticated = s.allowed.Has(review.Spec.Token)
+		resp = review
+	} else {
+		review := &authorizationv1.SubjectAccessReview{}
+		json.NewDecoder(req.Body).Decode(review)
+		review.TypeMeta = metav1.TypeMeta{APIVersion: "authorization.k8s.io/v1", Kind: "SubjectAccessReview"}
+		review.Status.Allowed = s.allowed.Has(review.Spec.User)
+		resp = review
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func newHandler(c *Cache, server http.Handler) http.Handler {
+	resolver := &apirequest.RequestInfoFactory{
+		APIPrefixes:          sets.NewString("api", "apis"),
+		GrouplessAPIPrefixes: sets.NewString("api"),
+	}
+	return filters.WithRequestInfo(c.WithReviewCache(server), resolver)
+}
+
+func review(handler http.Handler, path string, obj interface{}) *httptest.ResponseRecorder {
+	b, _ := json.Marshal(obj)
+	req := httptest.NewRequest("POST", path, strings.NewReader(string(b)))
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func tokenReview(token string) *authenticationv1.TokenReview {
+	return &authenticationv1.TokenReview{Spec: authenticationv1.TokenReviewSpec{Token: token}}
+}
+
+func accessReview(user string) *authorizationv1.SubjectAccessReview {
+	return &authorizationv1.SubjectAccessReview{Spec: authorizationv1.SubjectAccessReviewSpec{
+		User:               user,
+		ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: "get", Resource: "nodes", Subresource: "metrics"},
+	}}
+}
+
+const (
+	tokenReviewPath  = "/apis/authentication.k8s.io/v1/tokenreviews"
+	accessReviewPath = "/apis/authorization.k8s.io/v1/subjectaccessreviews"
+)
+
+func TestReviewCache(t *testing.T) {
+	clk := clock.NewFakeClock(time.Now())
+	server := &fakeAPIServer{allowed: sets.NewString("token1", "user1")}
+	handler := newHandler(NewCacheWithClock(10, 2*time.Minute, 30*time.Minute, clk), server)
+
+	testcases := []struct {
+		desc     string
+		step     time.Duration
+		offline  bool
+		path     string
+		obj      interface{}
+		code     int
+		cache    string
+		requests int
+	}{
+		{desc: "token is reviewed by kube-apiserver", path: tokenReviewPath, obj: tokenReview("token1"), code: http.StatusCreated, cache: "miss", requests: 1},
+		{desc: "token is reviewed from cache", step: time.Minute, path: tokenReviewPath, obj: tokenReview("token1"), code: http.StatusCreated, cache: "hit", requests: 1},
+		{desc: "another token is reviewed by kube-apiserver", path: tokenReviewPath, obj: tokenReview("token2"), code: http.StatusCreated, cache: "miss", requests: 2},
+		{desc: "access is reviewed by kube-apiserver", path: accessReviewPath, obj: accessReview("user1"), code: http.StatusCreated, cache: "miss", requests: 3},
+		{desc: "denied access is reviewed by kube-apiserver", path: accessReviewPath, obj: accessReview("user2"), code: http.StatusCreated, cache: "miss", requests: 4},
+		{desc: "denied access is reviewed from cache", step: 10 * time.Second, path: accessReviewPath, obj: accessReview("user2"), code: http.StatusCreated, cache: "hit", requests: 4},
+		{desc: "denied access is expired", step: 30 * time.Second, path: accessReviewPath, obj: accessReview("user2"), code: http.StatusCreated, cache: "miss", requests: 5},
+		{desc: "token is expired", step: 30 * time.Second, path: tokenReviewPath, obj: tokenReview("token1"), code: http.StatusCreated, cache: "miss", requests: 6},
+		{desc: "stale token is reviewed from cache when offline", step: 10 * time.Minute, offline: true, path: tokenReviewPath, obj: tokenReview("token1"), code: http.StatusCreated, cache: "stale", requests: 7},
+		{desc: "token not in cache is failed when offline", offline: true, path: tokenReviewPath, obj: tokenReview("token3"), code: http.StatusServiceUnavailable, cache: "miss", requests: 8},
+		{desc: "stale token is dropped after max stale", step: 30 * time.Minute, offline: true, path: tokenReviewPath, obj: tokenReview("token1"), code: http.StatusServiceUnavailable, cache: "miss", requests: 9},
+	}
+
+	for _, tt := range testcases {
+		clk.Step(tt.step)
+		server.offline = tt.offline
+		w := review(handler, tt.path, tt.obj)
+		if w.Code != tt.code {
+			t.Errorf("%s: expect status code %d, but got %d", tt.desc, tt.code, w.Code)
+		}
+		if got := w.Header().Get(cacheHeader); got != tt.cache {
+			t.Errorf("%s: expect cache %s, but got %s", tt.desc, tt.cache, got)
+		}
+		if server.requests != tt.requests {
+			t.Errorf("%s: expect %d requests to kube-apiserver, but got %d", tt.desc, tt.requests, server.requests)
+		}
+	}
+}
+
+func TestReviewCacheIsBounded(t *testing.T) {
+	server := &fakeAPIServer{allowed: sets.NewString("token1", "token2", "token3")}
+	handler := newHandler(NewCache(2, time.Minute, time.Minute), server)
+
+	for _, token := range []string{"token1", "token2", "token3", "token1"} {
+		review(handler, tokenReviewPath, tokenReview(token))
+	}
+	// token1 is evicted by token3
+	if server.requests != 4 {
+		t.Errorf("expect 4 requests to kube-apiserver, but got %d", server.requests)
+	}
+}
+
+func TestOtherRequestsAreNotCached(t *testing.T) {
+	server := &fakeAPIServer{allowed: sets.NewString("user1")}
+	handler := newHandler(NewCache(10, time.Minute, time.Minute), server)
+
+	for i := 0; i < 2; i++ {
+		w := review(handler, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", accessReview("user1"))
+		if w.Header().Get(cacheHeader) != "" {
+			t.Errorf("expect self subject access review is not cached, but got %s", w.Header().Get(cacheHeader))
+		}
+	}
+	if server.requests != 2 {
+		t.Errorf("expect 2 requests to kube-apiserver, but got %d", server.requests)
+	}
+}
@@ -0,0 +1,112 @@
+package selfreport
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// VersionAnnotation is the annotation on node that records the version of yurthub
+	VersionAnnotation = "openyurt.io/yurthub-version"
+	// FlagsAnnotation is the annotation on node that records the flags set explicitly
+	// on command line of yurthub, the value is a json object of flag names and values.
+	FlagsAnnotation = "openyurt.io/yurthub-flags"
+	// reportPeriod is the period to retry the report until it succeeds
+	reportPeriod = 30 * time.Second
+)
+
+// Version is the version of yurthub, it is set by -ldflags when yurthub is built, like
+// -X github.com/alibaba/openyurt/pkg/yurthub/selfreport.Version=v0.2.0
+var Version = "unknown"
+
+// Reporter reports the version and flags of yurthub to cloud by annotations of node,
+// so yurthubconfig controller can detect the drift between the static pod manifest
+// on the node and the desired configuration of the node pool.
+type Reporter struct {
+	nodeName         string
+	flags            map[string]string
+	transportManager transport.Interface
+	stopCh           <-chan struct{}
+}
+
+// NewReporter creates a reporter for the node of yurthub
+func NewReporter(cfg *config.YurtHubConfiguration, transportManager transport.Interface, stopCh <-chan struct{}) *Reporter {
+	return &Reporter{
+		nodeName:         cfg.NodeName,
+		flags:            cfg.Flags,
+		transportManager: transportManager,
+		stopCh:           stopCh,
+	}
+}
+
+// Run reports the version and flags in background, the report is retried until it
+// succeeds, because the rest config is not ready until the certificate of yurthub is
+// prepared, and the node may be offline when yurthub is started.
+func (r *Reporter) Run() {
+	go wait.PollImmediateUntil(reportPeriod, func() (bool, error) {
+		cfg := r.transportManager.GetRestClientConfig()
+		if cfg == nil {
+			klog.V(4).Infof("rest config is not ready, wait for reporting yurthub version of node %s", r.nodeName)
+			return false, nil
+		}
+
+		client, err := clientset.NewForConfig(cfg)
+		if err != nil {
+			klog.Errorf("could not new kube client, %v", err)
+			return false, nil
+		}
+
+		if err := r.report(client); err != nil {
+			klog.Errorf("failed to report yurthub version of node %s, %v", r.nodeName, err)
+			return false, nil
+		}
+		klog.Infof("yurthub version %s and flags are reported for node %s", Version, r.nodeName)
+		return true, nil
+	}, r.stopCh)
+}
+
+func (r *Reporter) report(client clientset.Interface) error {
+	patch, err := reportPatch(Version, r.flags)
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Nodes().Patch(r.nodeName, types.StrategicMergePatchType, patch)
+	return err
+}
+
+// reportPatch returns the patch of node annotations for the version and flags
+func reportPatch(version string, flags map[string]string) ([]byte, error) {
+	if flags == nil {
+		flags = map[string]string{}
+	}
+	b, err := json.Marshal(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				VersionAnnotation: version,
+				FlagsAnnotation:   string(b),
+			},
+		},
+	})
+}
+
+// ParseFlags parses the flags in the value of FlagsAnnotation
+func ParseFlags(value string) (map[string]string, error) {
+	flags := make(map[string]string)
+	if err := json.Unmarshal([]byte(value), &flags); err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
@@ -0,0 +1,81 @@
+package selfreport
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReport(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "node1",
+		Annotations: map[string]string{"foo": "bar", VersionAnnotation: "v0.1.0"},
+	}})
+	flags := map[string]string{"node-pool": "hangzhou", "max-requests-in-flight": "100"}
+	r := &Reporter{nodeName: "node1", flags: flags}
+
+	if err := r.report(client); err != nil {
+		t.Fatalf("failed to report, %v", err)
+	}
+
+	node, err := client.CoreV1().Nodes().Get("node1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get node, %v", err)
+	}
+	if node.Annotations["foo"] != "bar" {
+		t.Errorf("expect other annotations are kept, but got %v", node.Annotations)
+	}
+	if node.Annotations[VersionAnnotation] != Version {
+		t.Errorf("expect version %s, but got %s", Version, node.Annotations[VersionAnnotation])
+	}
+
+	reported, err := ParseFlags(node.Annotations[FlagsAnnotation])
+	if err != nil {
+		t.Fatalf("failed to parse flags, %v", err)
+	}
+	if !reflect.DeepEqual(reported, flags) {
+		t.Errorf("expect flags %v, but got %v", flags, reported)
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	testcases := map[string]struct {
+		value     string
+		expectErr bool
+		expected  map[string]string
+	}{
+		"no flags": {
+			value:    "{}",
+			expected: map[string]string{},
+		},
+		"flags": {
+			value:    `{"node-pool":"hangzhou"}`,
+			expected: map[string]string{"node-pool": "hangzhou"},
+		},
+		"invalid flags": {
+			value:     "node-pool=hangzhou",
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			flags, err := ParseFlags(tt.value)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse flags, %v", err)
+			}
+			if !reflect.DeepEqual(flags, tt.expected) {
+				t.Errorf("expect flags %v, but got %v", tt.expected, flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog"
+)
+
+const (
+	// proxyHeaderTimeout bounds the time of reading the proxy protocol header
+	proxyHeaderTimeout = 5 * time.Second
+	// proxyV1MaxLength is the max length of proxy protocol v1 header(including CRLF)
+	proxyV1MaxLength = 107
+)
+
+var (
+	proxyV1Prefix    = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ResolveBindAddresses returns the listen addresses for bindAddrs, an entry of
+// bindAddrs is an ip or the name of a network interface(like "lo", "cni0"), all
+// ips of the interface are used for the name of an interface.
+func ResolveBindAddresses(bindAddrs []string, port int) ([]string, error) {
+	addrs := make([]string, 0, len(bindAddrs))
+	seen := make(map[string]bool)
+	add := func(ip net.IP) {
+		addr := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+
+	for _, bindAddr := range bindAddrs {
+		if ip := net.ParseIP(bindAddr); ip != nil {
+			add(ip)
+			continue
+		}
+
+		iface, err := net.InterfaceByName(bindAddr)
+		if err != nil {
+			return nil, fmt.Errorf("bind address %s is neither an ip nor an interface, %v", bindAddr, err)
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get addresses of interface %s, %v", bindAddr, err)
+		}
+
+		n := len(addrs)
+		for _, ifaceAddr := range ifaceAddrs {
+			ipNet, ok := ifaceAddr.(*net.IPNet)
+			// link-local ipv6 addresses need a zone to listen, skip them
+			if !ok || ipNet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			add(ipNet.IP)
+		}
+		if len(addrs) == n {
+			return nil, fmt.Errorf("no address is found on interface %s", bindAddr)
+		}
+	}
+
+	return addrs, nil
+}
+
+// proxyProtocolListener accepts connections with or without a proxy protocol(v1 or v2)
+// header, the source address in header is used as the remote address of connection.
+type proxyProtocolListener struct {
+	net.Listener
+}
+
+// NewProxyProtocolListener wraps l to accept the proxy protocol header from a load balancer
+func NewProxyProtocolListener(l net.Listener) net.Listener {
+	return &proxyProtocolListener{Listener: l}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// proxyProtocolConn reads the proxy protocol header on the first Read or RemoteAddr,
+// so a slow client doesn't block the Accept loop.
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	c.remoteAddr, c.err = parseProxyHeader(c.reader)
+	if c.err != nil {
+		klog.Errorf("failed to read proxy protocol header from %s, %v", c.Conn.RemoteAddr(), c.err)
+		c.Conn.Close()
+	}
+}
+
+// parseProxyHeader reads the proxy protocol header from r, nil address is returned
+// if there is no header or the header doesn't carry the source address.
+func parseProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case proxyV1Prefix[0]:
+		if b, err := r.Peek(len(proxyV1Prefix)); err == nil && bytes.Equal(b, proxyV1Prefix) {
+			return parseProxyHeaderV1(r)
+		}
+	case proxyV2Signature[0]:
+		if b, err := r.Peek(len(proxyV2Signature)); err == nil && bytes.Equal(b, proxyV2Signature) {
+			return parseProxyHeaderV2(r)
+		}
+	}
+
+	return nil, nil
+}
+
+// parseProxyHeaderV1 parses header like "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func parseProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyV1MaxLength {
+			return nil, fmt.Errorf("proxy protocol v1 header exceeds %d bytes", proxyV1MaxLength)
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol v1 header %q", strings.TrimSpace(string(line)))
+	}
+
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("invalid source address in proxy protocol v1 header %q", strings.TrimSpace(string(line)))
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// parseProxyHeaderV2 parses the binary header of proxy protocol v2
+func parseProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	verCmd, family := header[12], header[13]
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported proxy protocol version %d", verCmd>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL command is used by health checks of load balancer, keep the real address
+	if verCmd&0x0f == 0 {
+		return nil, nil
+	} else if verCmd&0x0f != 1 {
+		return nil, fmt.Errorf("unsupported proxy protocol v2 command %d", verCmd&0x0f)
+	}
+
+	var ipLen int
+	switch family {
+	case 0x11: // TCP over IPv4
+		ipLen = net.IPv4len
+	case 0x21: // TCP over IPv6
+		ipLen = net.IPv6len
+	default:
+		return nil, nil
+	}
+
+	if len(payload) < 2*ipLen+4 {
+		return nil, fmt.Errorf("proxy protocol v2 address block of %d bytes is too short", len(payload))
+	}
+
+	return &net.TCPAddr{
+		IP:   net.IP(payload[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(payload[2*ipLen:])),
+	}, nil
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func TestParseProxyHeader(t *testing.T) {
+	v2 := func(verCmd, family byte, addrs []byte) []byte {
+		b := append([]byte{}, proxyV2Signature...)
+		b = append(b, verCmd, family, byte(len(addrs)>>8), byte(len(addrs)))
+		return append(b, addrs...)
+	}
+	v4Addrs := []byte{192, 168, 0, 1, 192, 168, 0, 11, 0xdc, 0x04, 0x01, 0xbb}
+	v6Addrs := append(append(net.ParseIP("fd00::1").To16(), net.ParseIP("fd00::2").To16()...), 0xdc, 0x04, 0x01, 0xbb)
+
+	testcases := map[string]struct {
+		data       []byte
+		remoteAddr string
+		err        bool
+	}{
+		"no header": {
+			data: []byte("GET /api HTTP/1.1\r\n\r\n"),
+		},
+		"no header starts with P": {
+			data: []byte("POST /api HTTP/1.1\r\n\r\n"),
+		},
+		"v1 tcp4": {
+			data:       []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nGET /api HTTP/1.1\r\n\r\n"),
+			remoteAddr: "192.168.0.1:56324",
+		},
+		"v1 tcp6": {
+			data:       []byte("PROXY TCP6 fd00::1 fd00::2 56324 443\r\nGET /api HTTP/1.1\r\n\r\n"),
+			remoteAddr: "[fd00::1]:56324",
+		},
+		"v1 unknown": {
+			data: []byte("PROXY UNKNOWN\r\nGET /api HTTP/1.1\r\n\r\n"),
+		},
+		"v1 invalid": {
+			data: []byte("PROXY TCP4 192.168.0.1\r\nGET /api HTTP/1.1\r\n\r\n"),
+			err:  true,
+		},
+		"v1 too long": {
+			data: append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), 200)...),
+			err:  true,
+		},
+		"v2 tcp4": {
+			data:       append(v2(0x21, 0x11, v4Addrs), []byte("GET /api HTTP/1.1\r\n\r\n")...),
+			remoteAddr: "192.168.0.1:56324",
+		},
+		"v2 tcp6": {
+			data:       append(v2(0x21, 0x21, v6Addrs), []byte("GET /api HTTP/1.1\r\n\r\n")...),
+			remoteAddr: "[fd00::1]:56324",
+		},
+		"v2 local": {
+			data: append(v2(0x20, 0x00, nil), []byte("GET /api HTTP/1.1\r\n\r\n")...),
+		},
+		"v2 unsupported version": {
+			data: append(v2(0x11, 0x11, v4Addrs), []byte("GET /api HTTP/1.1\r\n\r\n")...),
+			err:  true,
+		},
+		"v2 short address": {
+			data: append(v2(0x21, 0x11, v4Addrs[:6]), []byte("GET /api HTTP/1.1\r\n\r\n")...),
+			err:  true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			r := bufio.NewReader(bytes.NewReader(tt.data))
+			addr, err := parseProxyHeader(r)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expect error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if tt.remoteAddr == "" && addr != nil {
+				t.Errorf("expect no address, but got %s", addr)
+			} else if tt.remoteAddr != "" && (addr == nil || addr.String() != tt.remoteAddr) {
+				t.Errorf("expect address %s, but got %v", tt.remoteAddr, addr)
+			}
+
+			rest, _ := ioutil.ReadAll(r)
+			if !bytes.HasPrefix(rest, []byte("GET /api")) && !bytes.HasPrefix(rest, []byte("POST /api")) {
+				t.Errorf("expect request after header, but got %q", rest)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err)
+	}
+	pl := NewProxyProtocolListener(l)
+	defer pl.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP4 10.0.0.1 10.0.0.2 1234 443\r\nhello"))
+	}()
+
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept, %v", err)
+	}
+	defer conn.Close()
+
+	if addr := conn.RemoteAddr().String(); addr != "10.0.0.1:1234" {
+		t.Errorf("expect remote address 10.0.0.1:1234, but got %s", addr)
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != nil || string(b) != "hello" {
+		t.Errorf("expect data hello, but got %q, %v", b, err)
+	}
+}
+
+func TestResolveBindAddresses(t *testing.T) {
+	addrs, err := ResolveBindAddresses([]string{"127.0.0.1", "::1", "127.0.0.1"}, 10261)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(addrs) != 2 || addrs[0] != "127.0.0.1:10261" || addrs[1] != "[::1]:10261" {
+		t.Errorf("expect addresses [127.0.0.1:10261 [::1]:10261], but got %v", addrs)
+	}
+
+	if _, err := ResolveBindAddresses([]string{"not-exist-iface"}, 10261); err == nil {
+		t.Errorf("expect error for interface that doesn't exist, but got nil")
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Fatalf("failed to list interfaces, %v", err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback == 0 {
+			continue
+		}
+		addrs, err := ResolveBindAddresses([]string{iface.Name}, 10261)
+		if err != nil {
+			t.Fatalf("failed to resolve interface %s, %v", iface.Name, err)
+		}
+		found := false
+		for _, addr := range addrs {
+			found = found || addr == "127.0.0.1:10261"
+		}
+		if !found {
+			t.Errorf("expect 127.0.0.1:10261 in addresses of %s, but got %v", iface.Name, addrs)
+		}
+		break
+	}
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestReusePortControl(t *testing.T) {
+	lc := net.ListenConfig{Control: reusePortControl}
+	old, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen, %v", err)
+	}
+	defer old.Close()
+
+	// a new listener on the same address succeeds while the old one is serving
+	l, err := lc.Listen(context.Background(), "tcp", old.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to listen on %s with SO_REUSEPORT, %v", old.Addr(), err)
+	}
+	defer l.Close()
+
+	if _, err := net.Listen("tcp", old.Addr().String()); err == nil {
+		t.Errorf("expect error for listening on %s without SO_REUSEPORT, but got nil", old.Addr())
+	}
+}
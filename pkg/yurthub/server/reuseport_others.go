@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+func reusePortControl(network, address string, c syscall.RawConn) error {
+	return fmt.Errorf("SO_REUSEPORT is not supported on %s", runtime.GOOS)
+}
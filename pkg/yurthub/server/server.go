@@ -1,16 +1,35 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
 	"github.com/alibaba/openyurt/pkg/yurthub/certificate/interfaces"
+	"github.com/alibaba/openyurt/pkg/yurthub/credentialprovider"
+	"github.com/alibaba/openyurt/pkg/yurthub/endpointcache"
+	"github.com/alibaba/openyurt/pkg/yurthub/metricsbuffer"
 	"github.com/alibaba/openyurt/pkg/yurthub/profile"
+	"github.com/alibaba/openyurt/pkg/yurthub/readiness"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/coreos/go-systemd/daemon"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
 )
 
+// shutdownTimeout bounds the time of draining in-flight requests(like watches) when
+// yurthub is stopped, the remaining connections are closed after the timeout.
+const shutdownTimeout = 10 * time.Second
+
 type Server interface {
+	// Run serves requests until stopCh is closed
 	Run()
 }
 
@@ -19,37 +38,180 @@ type yurtHubServer struct {
 	certificateMgr interfaces.YurtCertificateManager
 	proxyHandler   http.Handler
 	cfg            *config.YurtHubConfiguration
+	storage        storage.Store
+	storageWrapper cachemanager.StorageWrapper
+	watermarks     *cachemanager.Watermarks
+	evaluator      *readiness.Evaluator
+	wiper          http.Handler
+	endpointCache  http.Handler
+	// credentialProxy serves the forward proxy requests of image credential providers if it's enabled
+	credentialProxy http.Handler
+	// metricsBuffer serves the metrics buffered while disconnected if it's enabled
+	metricsBuffer http.Handler
+	stopCh        <-chan struct{}
+}
+
+// Options are the components that yurthub server serves the requests by, the optional
+// handlers are nil if their features are disabled.
+type Options struct {
+	CertificateMgr interfaces.YurtCertificateManager
+	// ProxyHandler proxies the requests of kubernetes api to cloud or local cache
+	ProxyHandler   http.Handler
+	Storage        storage.Store
+	StorageWrapper cachemanager.StorageWrapper
+	Watermarks     *cachemanager.Watermarks
+	Evaluator      *readiness.Evaluator
+	// Wiper serves the remote wipe of cache and credentials
+	Wiper http.Handler
+	// EndpointCache serves the cached endpoints outside of kubernetes
+	EndpointCache http.Handler
+	// CredentialProxy serves the forward proxy requests of image credential providers
+	CredentialProxy http.Handler
+	// MetricsBuffer serves the metrics buffered while disconnected
+	MetricsBuffer http.Handler
 }
 
-func NewYurtHubServer(cfg *config.YurtHubConfiguration,
-	certificateMgr interfaces.YurtCertificateManager,
-	proxyHandler http.Handler) Server {
+// NewYurtHubServer creates the server of yurthub that serves requests by the components
+// in opts until stopCh is closed
+func NewYurtHubServer(cfg *config.YurtHubConfiguration, opts Options, stopCh <-chan struct{}) Server {
 	return &yurtHubServer{
-		mux:            mux.NewRouter(),
-		certificateMgr: certificateMgr,
-		proxyHandler:   proxyHandler,
-		cfg:            cfg,
+		mux:             mux.NewRouter(),
+		certificateMgr:  opts.CertificateMgr,
+		proxyHandler:    opts.ProxyHandler,
+		cfg:             cfg,
+		storage:         opts.Storage,
+		storageWrapper:  opts.StorageWrapper,
+		watermarks:      opts.Watermarks,
+		evaluator:       opts.Evaluator,
+		wiper:           opts.Wiper,
+		endpointCache:   opts.EndpointCache,
+		credentialProxy: opts.CredentialProxy,
+		metricsBuffer:   opts.MetricsBuffer,
+		stopCh:          stopCh,
 	}
 }
 
 func (s *yurtHubServer) Run() {
 	s.registerHandler()
 
+	bindAddrs := s.cfg.BindAddresses
+	if len(bindAddrs) == 0 {
+		bindAddrs = []string{s.cfg.YurtHubHost}
+	}
+	addrs, err := ResolveBindAddresses(bindAddrs, s.cfg.YurtHubPort)
+	if err != nil {
+		panic(err)
+	}
+
+	// listen on all addresses before serving, so yurthub fails fast
+	// if any of the addresses is not available.
+	lc := net.ListenConfig{}
+	if s.cfg.EnableReusePort {
+		lc.Control = reusePortControl
+	}
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := lc.Listen(context.Background(), "tcp", addr)
+		if err != nil {
+			panic(err)
+		}
+		if s.cfg.EnableProxyProtocol {
+			l = NewProxyProtocolListener(l)
+		}
+		listeners = append(listeners, l)
+	}
+
 	server := &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", s.cfg.YurtHubHost, s.cfg.YurtHubPort),
-		Handler: s.mux,
+		Handler: s.handler(),
 	}
 
-	err := server.ListenAndServe()
-	if err != nil {
+	errCh := make(chan error, len(listeners))
+	for i := range listeners {
+		klog.Infof("yurthub server listens on %s, proxy protocol: %v, reuse port: %v", addrs[i], s.cfg.EnableProxyProtocol, s.cfg.EnableReusePort)
+		go func(l net.Listener) {
+			errCh <- server.Serve(l)
+		}(listeners[i])
+	}
+	// tell systemd that yurthub is ready when it's run as a service of Type=notify,
+	// so kubelet ordered after yurthub is not started before the addresses are listened.
+	notifySystemd(daemon.SdNotifyReady)
+	go runSystemdWatchdog(addrs[0], s.stopCh)
+
+	select {
+	case err := <-errCh:
 		panic(err)
+	case <-s.stopCh:
 	}
+	notifySystemd(daemon.SdNotifyStopping)
+
+	// keep serving for a while, so a new yurthub(like during the upgrade of static pod)
+	// can listen on the same addresses before the listeners of this one are closed.
+	if s.cfg.ShutdownDelaySeconds > 0 {
+		klog.Infof("yurthub server is stopping, keep serving for %d seconds", s.cfg.ShutdownDelaySeconds)
+		time.Sleep(time.Duration(s.cfg.ShutdownDelaySeconds) * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		klog.Warningf("failed to drain requests of yurthub server, %v", err)
+		server.Close()
+	}
+	klog.Infof("yurthub server is stopped")
+}
+
+// handler returns the handler of requests, the forward proxy requests(like CONNECT) are served by the
+// proxy of credential providers before routing, as they are not for the paths of yurthub.
+func (s *yurtHubServer) handler() http.Handler {
+	if s.credentialProxy == nil {
+		return s.mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if credentialprovider.IsProxyRequest(r) {
+			s.credentialProxy.ServeHTTP(w, r)
+			return
+		}
+		s.mux.ServeHTTP(w, r)
+	})
 }
 
 func (s *yurtHubServer) registerHandler() {
 	// register handler for health check
 	s.mux.HandleFunc("/v1/healthz", s.healthz).Methods("GET")
 
+	// register handler for the usage report of cache
+	s.mux.HandleFunc("/v1/cache/report", s.cacheReport).Methods("GET")
+
+	// register handler for the index of cached objects
+	s.mux.HandleFunc("/v1/cache/objects", s.cacheObjects).Methods("GET")
+
+	// register handler for the contents of cached objects
+	s.mux.HandleFunc("/v1/cache/objects/{key:.+}", s.cachedObject).Methods("GET")
+
+	// register handler for the watermarks of resource versions in cache
+	s.mux.HandleFunc("/v1/cache/watermarks", s.cacheWatermarks).Methods("GET")
+
+	// register handler for the preflight check of autonomy readiness
+	s.mux.HandleFunc("/v1/autonomy/readiness", s.autonomyReadiness).Methods("GET")
+
+	// register handler for the remote wipe of cache and credentials if it's enabled
+	if s.wiper != nil {
+		s.mux.Handle("/v1/admin/wipe", s.wiper).Methods("POST")
+	}
+
+	// register handler for the cached endpoints outside of kubernetes if any
+	if s.endpointCache != nil {
+		s.mux.PathPrefix(endpointcache.PathPrefix).Handler(s.endpointCache).Methods("GET")
+	}
+
+	// register handler for metrics
+	s.mux.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// register handler for the metrics buffered while disconnected if it's enabled
+	if s.metricsBuffer != nil {
+		s.mux.Handle(metricsbuffer.BufferedPath, s.metricsBuffer).Methods("GET")
+	}
+
 	// register handler for profile
 	profile.Install(s.mux)
 
@@ -62,3 +224,120 @@ func (s *yurtHubServer) healthz(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, "OK")
 }
+
+func (s *yurtHubServer) cacheReport(w http.ResponseWriter, r *http.Request) {
+	report, err := cachemanager.NewCacheReport(r.Context(), s.storage)
+	if err != nil {
+		klog.Errorf("failed to generate cache report, %v", err)
+		http.Error(w, fmt.Sprintf("failed to generate cache report, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode cache report, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// cacheObjects lists the cached objects of the component in query(all components if not set)
+// with their resource versions, so the cache can be compared with the cluster.
+func (s *yurtHubServer) cacheObjects(w http.ResponseWriter, r *http.Request) {
+	index, err := cachemanager.NewCacheIndex(r.Context(), s.storageWrapper, r.URL.Query().Get("component"))
+	if err != nil {
+		klog.Errorf("failed to generate cache index, %v", err)
+		http.Error(w, fmt.Sprintf("failed to generate cache index, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	b, err := json.Marshal(index)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode cache index, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// cachedObject renders the cached object in key of path(like kubelet/pods/default/nginx), or
+// the v1.List of cached objects if key is a collection(like kubelet/pods), in the format of
+// output in query(json or yaml, json by default), as kubectl renders the objects.
+func (s *yurtHubServer) cachedObject(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+	output := r.URL.Query().Get("output")
+	if output != "" && output != "json" && output != "yaml" {
+		http.Error(w, fmt.Sprintf("output %s is not supported, valid outputs are: json, yaml", output), http.StatusBadRequest)
+		return
+	}
+
+	obj, err := cachemanager.ExportCachedObjects(r.Context(), s.storageWrapper, key)
+	switch err {
+	case nil:
+	case storage.ErrNotFound:
+		http.Error(w, fmt.Sprintf("%s is not cached", key), http.StatusNotFound)
+		return
+	case storage.ErrInvalidKey:
+		http.Error(w, fmt.Sprintf("%s is not a key of cached objects", key), http.StatusBadRequest)
+		return
+	default:
+		klog.Errorf("failed to get cached object %s, %v", key, err)
+		http.Error(w, fmt.Sprintf("failed to get cached object %s, %v", key, err), http.StatusInternalServerError)
+		return
+	}
+
+	var b []byte
+	if output == "yaml" {
+		b, err = yaml.Marshal(obj)
+		w.Header().Set("Content-Type", "application/yaml")
+	} else {
+		b, err = json.MarshalIndent(obj, "", "    ")
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		http.Error(w, fmt.Sprintf("failed to encode cached object %s, %v", key, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// cacheWatermarks reports the highest resource versions observed for components, and how far
+// they are behind cloud.
+func (s *yurtHubServer) cacheWatermarks(w http.ResponseWriter, r *http.Request) {
+	b, err := json.MarshalIndent(s.watermarks.Report(), "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode cache watermarks, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// autonomyReadiness evaluates the autonomy readiness of node, http.StatusServiceUnavailable
+// is returned if the node is not ready for autonomy, so it can be used as a preflight check.
+func (s *yurtHubServer) autonomyReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.evaluator.Evaluate(r.Context())
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode autonomy readiness, %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/cachemanager"
+	"github.com/alibaba/openyurt/pkg/yurthub/kubernetes/serializer"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+	"github.com/gorilla/mux"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCachedObject(t *testing.T) {
+	store, _ := fake.NewFakeStorage()
+	sw := cachemanager.NewStorageWrapper(store, serializer.NewStorageCodec())
+	sw.Create(context.Background(), "kubelet/pods/default/pod1", &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod1"},
+	})
+	store.Create(context.Background(), "kubelet/pods/default/pod2", []byte("corrupted"))
+
+	s := &yurtHubServer{storageWrapper: sw}
+	router := mux.NewRouter()
+	router.HandleFunc("/v1/cache/objects/{key:.+}", s.cachedObject).Methods("GET")
+
+	testcases := map[string]struct {
+		path     string
+		code     int
+		contains string
+	}{
+		"json": {
+			path:     "/v1/cache/objects/kubelet/pods/default/pod1",
+			code:     http.StatusOK,
+			contains: `"kind": "Pod"`,
+		},
+		"yaml": {
+			path:     "/v1/cache/objects/kubelet/pods/default/pod1?output=yaml",
+			code:     http.StatusOK,
+			contains: "kind: Pod",
+		},
+		"collection": {
+			path:     "/v1/cache/objects/kubelet/pods?output=yaml",
+			code:     http.StatusOK,
+			contains: "kind: List",
+		},
+		"unsupported output": {
+			path: "/v1/cache/objects/kubelet/pods/default/pod1?output=wide",
+			code: http.StatusBadRequest,
+		},
+		"not found": {
+			path: "/v1/cache/objects/kubelet/pods/default/pod3",
+			code: http.StatusNotFound,
+		},
+		"internal key": {
+			path: "/v1/cache/objects/_internal/cache-manager/cache-agent.conf",
+			code: http.StatusBadRequest,
+		},
+		"corrupted": {
+			path: "/v1/cache/objects/kubelet/pods/default/pod2",
+			code: http.StatusInternalServerError,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.code {
+				t.Errorf("expect status %d, but got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("expect %q in response, but got %s", tt.contains, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyRequestsHandler(t *testing.T) {
+	proxied := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("credential proxy"))
+	})
+	s := &yurtHubServer{mux: mux.NewRouter(), credentialProxy: proxied}
+	s.mux.HandleFunc("/v1/healthz", s.healthz).Methods("GET")
+
+	testcases := map[string]struct {
+		method   string
+		target   string
+		expected string
+	}{
+		"connect":      {method: "CONNECT", target: "api.ecr.us-east-1.amazonaws.com:443", expected: "credential proxy"},
+		"absolute url": {method: "GET", target: "http://169.254.169.254/latest/meta-data", expected: "credential proxy"},
+		"yurthub":      {method: "GET", target: "/v1/healthz", expected: "OK"},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+			if w.Body.String() != tt.expected {
+				t.Errorf("expect %s, but got %s", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-systemd/daemon"
+	"k8s.io/klog"
+)
+
+// notifySystemd tells the state of yurthub to systemd if it's started by a service of
+// Type=notify, it's a no-op when NOTIFY_SOCKET is not set(like in a static pod).
+func notifySystemd(state string) {
+	sent, err := daemon.SdNotify(false, state)
+	if err != nil {
+		klog.Warningf("failed to notify %s to systemd, %v", state, err)
+	} else if sent {
+		klog.V(2).Infof("notified %s to systemd", state)
+	}
+}
+
+// runSystemdWatchdog pings the watchdog of systemd at half of WatchdogSec of the service
+// as long as healthz of yurthub on addr is ok, so a hung yurthub is restarted by systemd.
+// it returns immediately if the watchdog is not enabled for yurthub.
+func runSystemdWatchdog(addr string, stopCh <-chan struct{}) {
+	interval, err := daemon.SdWatchdogEnabled(false)
+	if err != nil {
+		klog.Warningf("failed to check the watchdog of systemd, %v", err)
+		return
+	} else if interval == 0 {
+		return
+	}
+	interval = interval / 2
+	klog.Infof("yurthub pings the watchdog of systemd every %v", interval)
+
+	client := &http.Client{Timeout: interval}
+	url := fmt.Sprintf("http://%s/v1/healthz", addr)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := probeHealthz(client, url); err != nil {
+			klog.Warningf("skip the ping of systemd watchdog, %v", err)
+		} else {
+			notifySystemd(daemon.SdNotifyWatchdog)
+		}
+
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func probeHealthz(client *http.Client, url string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("healthz of yurthub returns %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-systemd/daemon"
+)
+
+func newNotifySocket(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("failed to listen notify socket, %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	os.Setenv("NOTIFY_SOCKET", path)
+	t.Cleanup(func() { os.Unsetenv("NOTIFY_SOCKET") })
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn, timeout time.Duration) string {
+	buf := make([]byte, 128)
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	n, err := conn.Read(buf)
+	if err != nil {
+		return ""
+	}
+	return string(buf[:n])
+}
+
+func TestNotifySystemd(t *testing.T) {
+	// it's a no-op without NOTIFY_SOCKET
+	notifySystemd(daemon.SdNotifyReady)
+
+	conn := newNotifySocket(t)
+	notifySystemd(daemon.SdNotifyReady)
+	if state := readNotify(t, conn, time.Second); state != daemon.SdNotifyReady {
+		t.Errorf("expect %s is notified, but got %q", daemon.SdNotifyReady, state)
+	}
+}
+
+func TestRunSystemdWatchdog(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	addr := strings.TrimPrefix(server.URL, "http://")
+	conn := newNotifySocket(t)
+
+	// it returns immediately if the watchdog is not enabled
+	stopCh := make(chan struct{})
+	runSystemdWatchdog(addr, stopCh)
+
+	os.Setenv("WATCHDOG_USEC", "200000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+	done := make(chan struct{})
+	go func() {
+		runSystemdWatchdog(addr, stopCh)
+		close(done)
+	}()
+	if state := readNotify(t, conn, time.Second); state != daemon.SdNotifyWatchdog {
+		t.Errorf("expect %s is notified, but got %q", daemon.SdNotifyWatchdog, state)
+	}
+	close(stopCh)
+	<-done
+
+	// the watchdog is not pinged when yurthub is not healthy
+	healthy = false
+	stopCh = make(chan struct{})
+	go runSystemdWatchdog(addr, stopCh)
+	defer close(stopCh)
+	if state := readNotify(t, conn, 300*time.Millisecond); state != "" {
+		t.Errorf("expect nothing is notified when yurthub is not healthy, but got %q", state)
+	}
+}
@@ -0,0 +1,591 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"go.etcd.io/bbolt"
+	"k8s.io/klog"
+)
+
+const (
+	// bucketDepth is the number of leading components of keys that are buckets, so the
+	// objects of a resource(like "kubelet/pods") are in one bucket, and the rest of key
+	// (like "default/foo") is the key in the bucket.
+	bucketDepth = 2
+	// modTimeLen is the length of the header of modification time before the contents
+	modTimeLen = 8
+	// openTimeout bounds the time to wait for the lock of db, which is held by another
+	// yurthub that uses the same cache.
+	openTimeout = 5 * time.Second
+)
+
+// boltStorage stores cache in a bbolt db, so the cache is one file rather than a file per
+// object, which saves the inodes of small edge filesystems. the writes are durable when
+// they are finished, and a write is either applied completely or not at all.
+type boltStorage struct {
+	db *bbolt.DB
+}
+
+// entry is a key found by list with its contents and modification time
+type entry struct {
+	key      string
+	contents []byte
+	modTime  time.Time
+}
+
+// DBPath returns the path of db for the cache under baseDir, it's next to baseDir rather
+// than in it, so it's never taken as a key when baseDir is used by disk storage again.
+func DBPath(baseDir string) string {
+	return filepath.Clean(baseDir) + ".db"
+}
+
+// Options are the options of bolt storage
+type Options struct {
+	// ReadOnly opens the db read-only, the db must exist, as it's neither created nor
+	// migrated from disk storage, so the cache on disk is left as it is.
+	ReadOnly bool
+}
+
+// NewBoltStorage creates the storage that stores cache in the db next to baseDir, the keys
+// of disk storage under baseDir are migrated to the db when it's created.
+func NewBoltStorage(baseDir string) (storage.Store, error) {
+	return NewBoltStorageWithOptions(baseDir, Options{})
+}
+
+// NewBoltStorageWithOptions creates the storage that stores cache in the db next to baseDir with options
+func NewBoltStorageWithOptions(baseDir string, opts Options) (storage.Store, error) {
+	if len(baseDir) == 0 {
+		return nil, fmt.Errorf("base dir of bolt storage is not set")
+	}
+
+	path := DBPath(baseDir)
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	created := os.IsNotExist(err)
+	if opts.ReadOnly {
+		if created {
+			return nil, fmt.Errorf("cache db %s doesn't exist, it's not created in read-only mode", path)
+		}
+		db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout, ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s, %v", path, err)
+		}
+		return &boltStorage{db: db}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s, %v", path, err)
+	}
+
+	bs := &boltStorage{db: db}
+	if created {
+		if err := migrateFromDisk(bs, baseDir); err != nil {
+			// the db is created again by the next start, and the migration is retried
+			db.Close()
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	return bs, nil
+}
+
+// splitKey returns the components of key, ErrInvalidKey is returned for key that is
+// absolute, contains ".." or ".", or has empty components(except a trailing slash),
+// the same as disk storage, so a key is valid for both of them.
+func splitKey(key string) ([]string, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	elems := strings.Split(key, "/")
+	for i, elem := range elems {
+		switch elem {
+		case "..", ".":
+			klog.Errorf("key %s is rejected, %s is not allowed in key", key, elem)
+			return nil, storage.ErrInvalidKey
+		case "":
+			// a trailing slash is allowed for collections
+			if i == 0 || i != len(elems)-1 {
+				klog.Errorf("key %s is rejected, empty component is not allowed in key", key)
+				return nil, storage.ErrInvalidKey
+			}
+		}
+	}
+
+	return strings.Split(strings.TrimSuffix(key, "/"), "/"), nil
+}
+
+// depthOf returns the number of components of key that are buckets
+func depthOf(comps []string) int {
+	if len(comps)-1 < bucketDepth {
+		return len(comps) - 1
+	}
+	return bucketDepth
+}
+
+func encode(contents []byte, modTime time.Time) []byte {
+	b := make([]byte, modTimeLen+len(contents))
+	binary.BigEndian.PutUint64(b, uint64(modTime.UnixNano()))
+	copy(b[modTimeLen:], contents)
+	return b
+}
+
+// decode returns a copy of the contents and modification time in v, as v is only valid in the transaction
+func decode(v []byte) ([]byte, time.Time, error) {
+	if len(v) < modTimeLen {
+		return nil, time.Time{}, storage.ErrCorrupted
+	}
+	contents := append([]byte(nil), v[modTimeLen:]...)
+	return contents, time.Unix(0, int64(binary.BigEndian.Uint64(v))), nil
+}
+
+// bucketOf returns the bucket that the key of comps is in and the key in the bucket,
+// the bucket is nil if it doesn't exist.
+func bucketOf(tx *bbolt.Tx, comps []string) (*bbolt.Bucket, string) {
+	depth := depthOf(comps)
+	if depth <= 0 {
+		return nil, ""
+	}
+
+	b := tx.Bucket([]byte(comps[0]))
+	for i := 1; i < depth && b != nil; i++ {
+		b = b.Bucket([]byte(comps[i]))
+	}
+	return b, strings.Join(comps[depth:], "/")
+}
+
+// hasPrefix returns true if there are keys with prefix in b
+func hasPrefix(b *bbolt.Bucket, prefix string) bool {
+	k, _ := b.Cursor().Seek([]byte(prefix))
+	return k != nil && bytes.HasPrefix(k, []byte(prefix))
+}
+
+// isCollection returns true if leaf in b is a bucket or there are keys under it
+func isCollection(b *bbolt.Bucket, leaf string) bool {
+	return b.Bucket([]byte(leaf)) != nil || hasPrefix(b, leaf+"/")
+}
+
+// put writes the contents of key, the buckets of key are created if they don't exist
+func put(tx *bbolt.Tx, comps []string, contents []byte, modTime time.Time) error {
+	depth := depthOf(comps)
+	if depth <= 0 {
+		klog.Errorf("key %s is rejected, key must be under a component", strings.Join(comps, "/"))
+		return storage.ErrInvalidKey
+	}
+
+	b, err := tx.CreateBucketIfNotExists([]byte(comps[0]))
+	if err != nil {
+		return err
+	}
+	for i := 1; i < depth; i++ {
+		b, err = b.CreateBucketIfNotExists([]byte(comps[i]))
+		if err == bbolt.ErrIncompatibleValue {
+			return fmt.Errorf("%s is not a collection", strings.Join(comps[:i+1], "/"))
+		} else if err != nil {
+			return err
+		}
+	}
+
+	leaf := strings.Join(comps[depth:], "/")
+	if isCollection(b, leaf) {
+		return storage.ErrKeyIsDir
+	}
+	// the parents of key in the bucket must not be keys, like the dirs of a file in disk storage
+	for i := strings.LastIndex(leaf, "/"); i > 0; i = strings.LastIndex(leaf[:i], "/") {
+		if b.Get([]byte(leaf[:i])) != nil {
+			return fmt.Errorf("%s is not a collection", strings.Join(append(comps[:depth:depth], leaf[:i]), "/"))
+		}
+	}
+
+	return b.Put([]byte(leaf), encode(contents, modTime))
+}
+
+// walk calls fn for the keys under the key of comps, or the key itself if it's not a collection
+func walk(tx *bbolt.Tx, comps []string, fn func(key string, v []byte) error) error {
+	if len(comps) == 0 {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			return walkBucket(b, string(name)+"/", fn)
+		})
+	}
+
+	b := tx.Bucket([]byte(comps[0]))
+	if b == nil {
+		return nil
+	}
+	i := 1
+	for ; i < len(comps) && i < bucketDepth; i++ {
+		nb := b.Bucket([]byte(comps[i]))
+		if nb == nil {
+			break
+		}
+		b = nb
+	}
+	prefix := strings.Join(comps[:i], "/") + "/"
+	if i == len(comps) {
+		return walkBucket(b, prefix, fn)
+	}
+
+	leaf := strings.Join(comps[i:], "/")
+	if v := b.Get([]byte(leaf)); v != nil {
+		return fn(strings.Join(comps, "/"), v)
+	}
+	c := b.Cursor()
+	for k, v := c.Seek([]byte(leaf + "/")); k != nil && bytes.HasPrefix(k, []byte(leaf+"/")); k, v = c.Next() {
+		if v != nil {
+			if err := fn(prefix+string(k), v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walkBucket calls fn for the keys in b and its nested buckets
+func walkBucket(b *bbolt.Bucket, prefix string, fn func(key string, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v == nil {
+			if err := walkBucket(b.Bucket(k), prefix+string(k)+"/", fn); err != nil {
+				return err
+			}
+		} else if err := fn(prefix+string(k), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteUnder deletes the keys under the key of comps, an error is returned if it's not a collection
+func deleteUnder(tx *bbolt.Tx, comps []string) error {
+	key := strings.Join(comps, "/")
+	if len(comps) == 1 {
+		if err := tx.DeleteBucket([]byte(comps[0])); err != nil && err != bbolt.ErrBucketNotFound {
+			return err
+		}
+		return nil
+	}
+
+	b, leaf := bucketOf(tx, comps)
+	if b == nil {
+		return nil
+	} else if b.Get([]byte(leaf)) != nil {
+		return fmt.Errorf("%s is not a collection", key)
+	} else if b.Bucket([]byte(leaf)) != nil {
+		return b.DeleteBucket([]byte(leaf))
+	}
+
+	// keys are collected before deleting, as the cursor is moved by deleting
+	keys := make([][]byte, 0)
+	c := b.Cursor()
+	for k, _ := c.Seek([]byte(leaf + "/")); k != nil && bytes.HasPrefix(k, []byte(leaf+"/")); k, _ = c.Next() {
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	for _, k := range keys {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bs *boltStorage) Create(ctx context.Context, key string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	comps, err := splitKey(key)
+	if err != nil {
+		return err
+	} else if len(comps) == 0 || len(contents) == 0 {
+		return nil
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, comps, contents, time.Now())
+	})
+}
+
+// Update replaces the contents of key, it's the same as create
+func (bs *boltStorage) Update(ctx context.Context, key string, contents []byte) error {
+	return bs.Create(ctx, key, contents)
+}
+
+func (bs *boltStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	comps, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		b, leaf := bucketOf(tx, comps)
+		if b == nil || b.Get([]byte(leaf)) == nil {
+			return nil
+		}
+		return b.Delete([]byte(leaf))
+	})
+}
+
+func (bs *boltStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+	comps, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		return deleteUnder(tx, comps)
+	})
+}
+
+func (bs *boltStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	comps, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	} else if len(comps) == 0 {
+		return nil, storage.ErrKeyIsDir
+	}
+
+	var contents []byte
+	err = bs.db.View(func(tx *bbolt.Tx) error {
+		if len(comps) == 1 {
+			if tx.Bucket([]byte(comps[0])) != nil {
+				return storage.ErrKeyIsDir
+			}
+			return storage.ErrNotFound
+		}
+
+		b, leaf := bucketOf(tx, comps)
+		if b == nil {
+			return storage.ErrNotFound
+		}
+		v := b.Get([]byte(leaf))
+		if v == nil {
+			if isCollection(b, leaf) {
+				return storage.ErrKeyIsDir
+			}
+			return storage.ErrNotFound
+		}
+
+		var err error
+		contents, _, err = decode(v)
+		if err != nil {
+			klog.Errorf("contents of %s are corrupted", key)
+		}
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// listEntries returns the keys under key in the specified order
+func (bs *boltStorage) listEntries(key string, order storage.ListOrder) ([]entry, error) {
+	comps, err := splitKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]entry, 0)
+	err = bs.db.View(func(tx *bbolt.Tx) error {
+		return walk(tx, comps, func(k string, v []byte) error {
+			contents, modTime, err := decode(v)
+			if err != nil {
+				klog.Warningf("contents of %s are corrupted, skip it", k)
+				return nil
+			}
+			entries = append(entries, entry{key: k, contents: contents, modTime: modTime})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// keys are sorted explicitly, because the order of walk is not the lexical order of
+	// whole keys(like "a/b/c" in bucket "b" is walked after "a/b-c" in bucket "a").
+	switch order {
+	case storage.OrderByKey:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	case storage.OrderByModTime:
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].modTime.Equal(entries[j].modTime) {
+				return entries[i].key < entries[j].key
+			}
+			return entries[i].modTime.Before(entries[j].modTime)
+		})
+	default:
+		return nil, fmt.Errorf("list order %s is not supported", order)
+	}
+	return entries, nil
+}
+
+func (bs *boltStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return bs.ListKeysInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListKeysInOrder returns the keys under key in the specified order
+func (bs *boltStorage) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return []string{}, err
+	}
+
+	entries, err := bs.listEntries(key, order)
+	if err != nil {
+		return []string{}, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for i := range entries {
+		keys = append(keys, entries[i].key)
+	}
+	return keys, nil
+}
+
+func (bs *boltStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	return bs.ListInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListInOrder returns the contents under key in the specified order of their keys
+func (bs *boltStorage) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if key == "" {
+		return nil, fmt.Errorf("key for list is empty")
+	}
+
+	entries, err := bs.listEntries(key, order)
+	if err != nil {
+		return nil, err
+	}
+
+	bb := make([][]byte, 0, len(entries))
+	for i := range entries {
+		bb = append(bb, entries[i].contents)
+	}
+	return bb, nil
+}
+
+// StatKeys returns the metadata of keys under key in ascending lexical order of keys
+func (bs *boltStorage) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := bs.listEntries(key, storage.OrderByKey)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]storage.KeyInfo, 0, len(entries))
+	for i := range entries {
+		infos = append(infos, storage.KeyInfo{
+			Key:     entries[i].key,
+			Size:    int64(len(entries[i].contents)),
+			ModTime: entries[i].modTime,
+		})
+	}
+	return infos, nil
+}
+
+// Touch sets the modification time of key to now
+func (bs *boltStorage) Touch(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	comps, err := splitKey(key)
+	if err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		b, leaf := bucketOf(tx, comps)
+		if b == nil {
+			return storage.ErrNotFound
+		}
+		v := b.Get([]byte(leaf))
+		if v == nil {
+			if isCollection(b, leaf) {
+				return storage.ErrKeyIsDir
+			}
+			return storage.ErrNotFound
+		}
+
+		contents, _, err := decode(v)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(leaf), encode(contents, time.Now()))
+	})
+}
+
+// Replace replaces the keys under rootKey with contents in one transaction, so readers
+// get either the keys before or after the replace.
+func (bs *boltStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+	rootComps, err := splitKey(rootKey)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string][]string, len(contents))
+	for key := range contents {
+		comps, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+		keys[key] = comps
+	}
+
+	return bs.db.Update(func(tx *bbolt.Tx) error {
+		if err := deleteUnder(tx, rootComps); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for key, comps := range keys {
+			if err := put(tx, comps, contents[key], now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,74 @@
+package bolt
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"go.etcd.io/bbolt"
+	"k8s.io/klog"
+)
+
+// migrateFromDisk moves the keys of disk storage under baseDir to bs in one transaction,
+// and removes them from disk after they are committed, so the inodes are freed. the contents
+// are copied as they are stored by disk storage(like encrypted), because they are read and
+// written through the same wrappers of storage. keys that can not be read(like corrupted
+// ones) are skipped, they are fetched from cloud again.
+func migrateFromDisk(bs *boltStorage, baseDir string) error {
+	if infos, err := ioutil.ReadDir(baseDir); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	} else if len(infos) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	ds, err := disk.NewDiskStorage(baseDir)
+	if err != nil {
+		return err
+	}
+	infos, err := storage.StatKeys(ctx, ds, "")
+	if err != nil {
+		return err
+	}
+
+	components := make(map[string]bool)
+	migrated := 0
+	err = bs.db.Update(func(tx *bbolt.Tx) error {
+		for _, info := range infos {
+			components[strings.SplitN(info.Key, "/", 2)[0]] = true
+			comps, err := splitKey(info.Key)
+			if err != nil || len(comps) < 2 {
+				klog.Warningf("key %s of disk storage is not migrated, it's invalid", info.Key)
+				continue
+			}
+
+			contents, err := ds.Get(ctx, info.Key)
+			if err != nil {
+				klog.Warningf("key %s of disk storage is not migrated, %v", info.Key, err)
+				continue
+			}
+			if err := put(tx, comps, contents, info.ModTime); err != nil {
+				return err
+			}
+			migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	klog.Infof("%d keys of disk storage in %s are migrated to %s", migrated, baseDir, DBPath(baseDir))
+
+	for component := range components {
+		if err := ds.DeleteCollection(ctx, component, true); err != nil {
+			klog.Warningf("failed to remove %s of disk storage after migration, %v", component, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package disk
+
+import (
+	"context"
+	"sort"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+)
+
+// batchWorkers bounds the files that are read or written concurrently by a batch, so the
+// batch of hundreds of keys(like the pods of node) doesn't use up the io of node.
+const batchWorkers = 8
+
+// GetMulti reads the files of keys concurrently, the keys that don't exist or can't be
+// read are not in the result.
+func (ds *diskStorage) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	var result map[string][]byte
+	err := runWithContext(ctx, func() error {
+		paths := make([]string, len(keys))
+		for i := range keys {
+			absKey, err := ds.filePath(keys[i])
+			if err != nil {
+				return err
+			}
+			paths[i] = absKey
+		}
+
+		bb, errs, err := ds.getAll(ctx, paths)
+		if err != nil {
+			return err
+		}
+		contents := make(map[string][]byte, len(keys))
+		for i := range keys {
+			if errs[i] == nil {
+				contents[keys[i]] = bb[i]
+			} else if errs[i] != storage.ErrNotFound {
+				klog.Warningf("failed to get bytes for %s in batch, %v", keys[i], errs[i])
+			}
+		}
+		result = contents
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetMulti writes the files of keys concurrently, the error of the first key in lexical
+// order that fails is returned.
+func (ds *diskStorage) SetMulti(ctx context.Context, contents map[string][]byte) error {
+	return runWithContext(ctx, func() error {
+		keys := make([]string, 0, len(contents))
+		for key := range contents {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		errs := make([]error, len(keys))
+		workqueue.ParallelizeUntil(ctx, batchWorkers, len(keys), func(i int) {
+			errs[i] = ds.create(ctx, keys[i], contents[keys[i]])
+		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i := range errs {
+			if errs[i] != nil {
+				return errs[i]
+			}
+		}
+		return nil
+	})
+}
+
+// getAll reads the files of paths concurrently, the contents and errors are in the order
+// of paths. ctx.Err() is returned if ctx is done before all files are read.
+func (ds *diskStorage) getAll(ctx context.Context, paths []string) ([][]byte, []error, error) {
+	bb := make([][]byte, len(paths))
+	errs := make([]error, len(paths))
+	workqueue.ParallelizeUntil(ctx, batchWorkers, len(paths), func(i int) {
+		bb[i], errs[i] = ds.get(ctx, paths[i])
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return bb, errs, nil
+}
@@ -0,0 +1,65 @@
+package disk
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+const (
+	// checksumHeaderSize is the size of header before contents in the file of key,
+	// it's made of checksumMagic and the crc32 checksum of contents.
+	checksumHeaderSize = 8
+)
+
+var (
+	// checksumMagic marks the header of checksum for contents that are not compressed,
+	// the file of key without a known magic(see compression.go) is corrupted
+	checksumMagic = []byte("YHC1")
+	// crcTable is the table of crc32 checksum, castagnoli is accelerated by hardware
+	// on most platforms, and detects the corruptions of flash storage better than ieee.
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// checksumHeader returns the header of checksum for contents that are not compressed
+func checksumHeader(contents []byte) []byte {
+	return checksumHeaderWithMagic(checksumMagic, contents)
+}
+
+// checksumHeaderWithMagic returns the header of checksum with magic for contents
+func checksumHeaderWithMagic(magic, contents []byte) []byte {
+	header := make([]byte, checksumHeaderSize)
+	copy(header, magic)
+	binary.BigEndian.PutUint32(header[len(checksumMagic):], crc32.Checksum(contents, crcTable))
+	return header
+}
+
+// verifyChecksum verifies b read from the file of key against its header of checksum,
+// and returns the contents without header, decompressed as the magic of header tells.
+// storage.ErrCorrupted is returned when the header is missing, the checksum mismatches
+// (like flash storage returns damaged data) or the contents can't be decompressed.
+func verifyChecksum(b []byte) ([]byte, error) {
+	if len(b) < checksumHeaderSize {
+		return nil, storage.ErrCorrupted
+	}
+
+	contents := b[checksumHeaderSize:]
+	if binary.BigEndian.Uint32(b[len(checksumMagic):checksumHeaderSize]) != crc32.Checksum(contents, crcTable) {
+		return nil, storage.ErrCorrupted
+	}
+
+	contents, err := decompress(b[:len(checksumMagic)], contents)
+	if err != nil {
+		return nil, storage.ErrCorrupted
+	}
+	return contents, nil
+}
+
+// contentSize returns the size of contents(compressed if they are) in the file of key with size
+func contentSize(size int64) int64 {
+	if size < checksumHeaderSize {
+		return 0
+	}
+	return size - checksumHeaderSize
+}
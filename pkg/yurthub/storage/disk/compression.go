@@ -0,0 +1,89 @@
+package disk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+)
+
+const (
+	// CompressionNone stores contents as they are
+	CompressionNone = "none"
+	// CompressionGzip compresses contents by gzip, it saves the most disk space
+	CompressionGzip = "gzip"
+	// CompressionSnappy compresses contents by snappy, it's faster than gzip and
+	// costs less cpu, but saves less disk space
+	CompressionSnappy = "snappy"
+)
+
+var (
+	gzipMagic   = []byte("YHG1")
+	snappyMagic = []byte("YHS1")
+)
+
+// IsSupportedCompression checks compression is supported by disk storage or not
+func IsSupportedCompression(compression string) bool {
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionSnappy:
+		return true
+	default:
+		return false
+	}
+}
+
+// encodeFile returns the bytes in file of key for contents, contents are compressed
+// by compression, and the magic of checksum header tells how they are compressed, so
+// the files written with any compression can be read. contents are stored as they are
+// if compression doesn't make them smaller(like small objects).
+func encodeFile(compression string, contents []byte) ([]byte, error) {
+	magic, compressed := checksumMagic, contents
+	switch compression {
+	case CompressionGzip:
+		var buf bytes.Buffer
+		// cpu of edge nodes is limited, the best speed compresses json objects
+		// well enough with much less cpu than the best compression
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(contents); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		magic, compressed = gzipMagic, buf.Bytes()
+	case CompressionSnappy:
+		magic, compressed = snappyMagic, snappy.Encode(nil, contents)
+	case CompressionNone, "":
+	default:
+		return nil, fmt.Errorf("compression %s is not supported", compression)
+	}
+
+	if len(compressed) >= len(contents) {
+		magic, compressed = checksumMagic, contents
+	}
+	return append(checksumHeaderWithMagic(magic, compressed), compressed...), nil
+}
+
+// decompress decompresses the contents of file with magic of checksum header
+func decompress(magic, b []byte) ([]byte, error) {
+	switch {
+	case bytes.Equal(magic, checksumMagic):
+		return b, nil
+	case bytes.Equal(magic, gzipMagic):
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return ioutil.ReadAll(r)
+	case bytes.Equal(magic, snappyMagic):
+		return snappy.Decode(nil, b)
+	default:
+		return nil, fmt.Errorf("magic %q is not recognized", magic)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package disk
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchangeDirs exchanges the dirs of a and b atomically by renameat2(RENAME_EXCHANGE),
+// it falls back to renames if the filesystem doesn't support the exchange.
+func exchangeDirs(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	if err == unix.EINVAL || err == unix.ENOSYS || err == unix.EOPNOTSUPP {
+		return renameDirs(a, b)
+	} else if err != nil {
+		return &os.LinkError{Op: "renameat2", Old: a, New: b, Err: err}
+	}
+	return nil
+}
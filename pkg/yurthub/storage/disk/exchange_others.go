@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package disk
+
+// exchangeDirs exchanges the dirs of a and b by renames, the exchange is not atomic
+// on the platforms other than linux.
+func exchangeDirs(a, b string) error {
+	return renameDirs(a, b)
+}
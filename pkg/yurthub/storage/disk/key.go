@@ -0,0 +1,89 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"k8s.io/klog"
+)
+
+// keyPath returns the path of key in cache. keys are made of component name
+// and resource names that come from requests, so a crafted key must not make
+// yurthub read or write files outside of cache, ErrInvalidKey is returned for
+// key that is absolute, contains ".." or ".", has empty components(except a
+// trailing slash), has components named like shard dirs, or refers to a path
+// outside of cache through symlinks.
+// "." and empty components are rejected because they are cleaned by
+// filepath.Join, then the key would refer to the path of another key.
+func (ds *diskStorage) keyPath(key string) (string, error) {
+	if filepath.IsAbs(key) {
+		klog.Errorf("key %s is rejected, absolute key is not allowed", key)
+		return "", storage.ErrInvalidKey
+	}
+
+	elems := strings.Split(filepath.ToSlash(key), "/")
+	for i, elem := range elems {
+		switch elem {
+		case "..", ".":
+			klog.Errorf("key %s is rejected, %s is not allowed in key", key, elem)
+			return "", storage.ErrInvalidKey
+		case "":
+			// empty key is the root of cache, and a trailing slash is allowed for collections
+			if i != len(elems)-1 {
+				klog.Errorf("key %s is rejected, empty component is not allowed in key", key)
+				return "", storage.ErrInvalidKey
+			}
+		default:
+			// shard dirs are removed when the paths of files are mapped back to keys
+			if isShardDir(elem) {
+				klog.Errorf("key %s is rejected, %s is reserved for shard dirs", key, elem)
+				return "", storage.ErrInvalidKey
+			}
+		}
+	}
+
+	path := filepath.Join(ds.baseDir, key)
+	if err := ds.verifyPath(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// verifyPath checks the path is still in cache after symlinks are resolved,
+// the path may not exist, so the deepest existing ancestor of path is checked.
+func (ds *diskStorage) verifyPath(path string) error {
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+
+	root, err := filepath.EvalSymlinks(ds.baseDir)
+	if err != nil {
+		return err
+	}
+
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		klog.Errorf("path %s is rejected, it is resolved to %s outside of cache", path, resolved)
+		return storage.ErrInvalidKey
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+// keyLocks are the locks of keys, the operations on a key wait until the key is unlocked,
+// so concurrent writers of the same key(like a watch event and a relist) are serialized
+// instead of failing with ErrStorageAccessConflict.
+type keyLocks struct {
+	sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock of a key, it's held by the one that has sent to ch
+type keyLock struct {
+	ch chan struct{}
+	// refs is the number of the holder and the waiters, the lock is dropped when it's 0
+	refs int
+	// owner is the token of the transaction that holds the lock by LockKey, it's nil
+	// when the lock is held by a single operation.
+	owner *lockToken
+}
+
+// lockToken identifies a transaction of LockKey, it's carried by the context of the transaction.
+// it's not zero-sized, as pointers to zero-sized values may be equal.
+type lockToken struct {
+	_ byte
+}
+
+// heldKey is the key of the context value for the token of a locked key
+type heldKey struct {
+	locks *keyLocks
+	key   string
+}
+
+func newKeyLocks() *keyLocks {
+	return &keyLocks{locks: make(map[string]*keyLock)}
+}
+
+// lockName normalizes key, so "kubelet/pods/a" and "/kubelet/pods/a/" are the same lock
+func lockName(key string) string {
+	return strings.Trim(filepath.Clean("/"+key), "/")
+}
+
+// lock waits until key is locked or ctx is done, and returns the func to unlock it.
+// if key is locked by the transaction of ctx, it returns at once and unlock is a no-op.
+func (kl *keyLocks) lock(ctx context.Context, key string) (func(), error) {
+	return kl.acquire(ctx, key, true)
+}
+
+// tryLock locks key if it's not locked, ErrStorageAccessConflict is returned otherwise
+func (kl *keyLocks) tryLock(ctx context.Context, key string) (func(), error) {
+	return kl.acquire(ctx, key, false)
+}
+
+func (kl *keyLocks) acquire(ctx context.Context, key string, wait bool) (func(), error) {
+	name := lockName(key)
+	kl.Lock()
+	l := kl.locks[name]
+	if l != nil && l.owner != nil && ctx.Value(heldKey{locks: kl, key: name}) == l.owner {
+		kl.Unlock()
+		return func() {}, nil
+	}
+	if l == nil {
+		l = &keyLock{ch: make(chan struct{}, 1)}
+		kl.locks[name] = l
+	}
+	l.refs++
+	kl.Unlock()
+
+	if wait {
+		select {
+		case l.ch <- struct{}{}:
+			return func() { kl.release(name, l) }, nil
+		case <-ctx.Done():
+		}
+	} else {
+		select {
+		case l.ch <- struct{}{}:
+			return func() { kl.release(name, l) }, nil
+		default:
+		}
+	}
+
+	kl.Lock()
+	l.refs--
+	if l.refs == 0 {
+		delete(kl.locks, name)
+	}
+	kl.Unlock()
+	if !wait {
+		return nil, storage.ErrStorageAccessConflict
+	}
+	return nil, ctx.Err()
+}
+
+func (kl *keyLocks) release(name string, l *keyLock) {
+	kl.Lock()
+	l.owner = nil
+	l.refs--
+	if l.refs == 0 {
+		delete(kl.locks, name)
+	}
+	kl.Unlock()
+	<-l.ch
+}
+
+// lockTx locks key for a transaction, the returned context carries the lock
+func (kl *keyLocks) lockTx(ctx context.Context, key string) (context.Context, error) {
+	name := lockName(key)
+	hk := heldKey{locks: kl, key: name}
+	kl.Lock()
+	if l := kl.locks[name]; l != nil && l.owner != nil && ctx.Value(hk) == l.owner {
+		kl.Unlock()
+		return nil, fmt.Errorf("key %s is already locked by the transaction", key)
+	}
+	kl.Unlock()
+
+	if _, err := kl.lock(ctx, key); err != nil {
+		return nil, err
+	}
+	token := &lockToken{}
+	kl.Lock()
+	kl.locks[name].owner = token
+	kl.Unlock()
+	return context.WithValue(ctx, hk, token), nil
+}
+
+// unlockTx unlocks key that is locked by lockTx with ctx, it's a no-op if the key is
+// not locked by the transaction of ctx.
+func (kl *keyLocks) unlockTx(ctx context.Context, key string) bool {
+	name := lockName(key)
+	kl.Lock()
+	l := kl.locks[name]
+	if l == nil || l.owner == nil || ctx.Value(heldKey{locks: kl, key: name}) != l.owner {
+		kl.Unlock()
+		return false
+	}
+	kl.Unlock()
+	kl.release(name, l)
+	return true
+}
@@ -0,0 +1,148 @@
+package disk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	// CurrentLayoutVersion is the version of cache layout on disk that
+	// is used by this release of yurthub, it should be increased when the
+	// layout of cache is changed, and a migration from the previous version
+	// should be registered in layoutMigrations.
+	CurrentLayoutVersion = 2
+
+	// layoutVersionKey is the key that records the layout version of cache,
+	// cache without layout version is regarded as version 0.
+	layoutVersionKey = "_internal/storage/layout.version"
+)
+
+// layoutMigration migrates cache under baseDir from version n to version n+1
+type layoutMigration func(baseDir string) error
+
+// layoutMigrations[n] migrates the cache layout from version n to n+1
+var layoutMigrations = []layoutMigration{
+	// version 0 to 1: layout of cache is not changed, only layout version is recorded
+	func(baseDir string) error { return nil },
+	// version 1 to 2: the header of checksum is added before the contents of keys
+	addChecksumHeaders,
+}
+
+// LayoutVersion returns the layout version of cache under baseDir
+func LayoutVersion(baseDir string) (int, error) {
+	b, err := ioutil.ReadFile(filepath.Join(baseDir, layoutVersionKey))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("layout version(%s) is invalid, %v", string(b), err)
+	}
+
+	return version, nil
+}
+
+// MigrateLayout upgrades the cache layout under baseDir to CurrentLayoutVersion step by step,
+// the layout version is recorded after each step, so an interrupted migration can be continued.
+func MigrateLayout(baseDir string) error {
+	version, err := LayoutVersion(baseDir)
+	if err != nil {
+		return err
+	}
+
+	if version > CurrentLayoutVersion {
+		return fmt.Errorf("cache layout version %d is newer than version %d supported by yurthub, cache can not be downgraded", version, CurrentLayoutVersion)
+	}
+
+	for ; version < CurrentLayoutVersion; version++ {
+		klog.Infof("migrate cache layout from version %d to %d", version, version+1)
+		if err := layoutMigrations[version](baseDir); err != nil {
+			return fmt.Errorf("failed to migrate cache layout from version %d to %d, %v", version, version+1, err)
+		}
+
+		if err := writeLayoutVersion(baseDir, version+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeLayoutVersion(baseDir string, version int) error {
+	return writeLayoutFile(baseDir, layoutVersionKey, []byte(strconv.Itoa(version)))
+}
+
+// writeLayoutFile writes the file of key that records the layout of cache through a tmp file
+func writeLayoutFile(baseDir, key string, contents []byte) error {
+	path := filepath.Join(baseDir, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	dir, file := filepath.Split(path)
+	tmp, err := ioutil.TempFile(dir, tmpPrefix+file+".")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// addChecksumHeaders adds the header of checksum to the files of keys under baseDir,
+// the files that have the header already(like by an interrupted migration) are skipped.
+func addChecksumHeaders(baseDir string) error {
+	return filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), tmpPrefix) ||
+			path == filepath.Join(baseDir, layoutVersionKey) {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, err := verifyChecksum(b); err == nil {
+			return nil
+		}
+
+		tmpPath := filepath.Join(filepath.Dir(path), tmpPrefix+info.Name())
+		if err := ioutil.WriteFile(tmpPath, append(checksumHeader(b), b...), info.Mode().Perm()); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		return os.Rename(tmpPath, path)
+	})
+}
@@ -0,0 +1,130 @@
+package disk
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+const (
+	// quarantineKey is the dir that the corrupted files of keys are moved into by the
+	// startup scan, the files keep their keys under it, like
+	// _internal/quarantine/kubelet/pods/default/pod1. files under it are not keys.
+	quarantineKey = "_internal/quarantine"
+
+	quarantineReasonCorrupted  = "corrupted"
+	quarantineReasonUnreadable = "unreadable"
+)
+
+var (
+	quarantinedKeys = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "yurthub_disk_cache",
+			Name:      "quarantined_keys_total",
+			Help:      "Counter of keys that are quarantined by the startup scan of disk cache by reason(corrupted or unreadable).",
+		},
+		[]string{"reason"},
+	)
+	quarantineScanDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_disk_cache",
+			Name:      "quarantine_scan_duration_seconds",
+			Help:      "Gauge measuring the duration of the last startup scan of disk cache.",
+		},
+	)
+)
+
+var registerMetrics sync.Once
+
+// Register the metrics of disk storage.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(quarantinedKeys)
+		prometheus.MustRegister(quarantineScanDuration)
+		prometheus.MustRegister(requestedBytes)
+		prometheus.MustRegister(writtenBytes)
+	})
+}
+
+// quarantine reads the files of all keys once when yurthub starts, and moves the files that
+// can't be read or whose checksum is not verified(like zero-byte or truncated files left by a
+// dirty shutdown) into quarantineKey, so they are neither served nor counted in the quota, and
+// are kept for inspection. the keys that are quarantined are returned.
+func (ds *diskStorage) quarantine() []string {
+	start := time.Now()
+	quarantineDir := filepath.Join(ds.baseDir, quarantineKey)
+	versionPath := filepath.Join(ds.baseDir, layoutVersionKey)
+	shardingPath := filepath.Join(ds.baseDir, shardingKey)
+	scanned := 0
+	quarantined := make([]string, 0)
+	err := filepath.Walk(ds.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the dir that can't be read is skipped, the other keys are still scanned
+			klog.Warningf("failed to scan %s, %v", path, err)
+			if info != nil && info.IsDir() && path != ds.baseDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() && (path == quarantineDir || strings.HasPrefix(info.Name(), tmpPrefix)) {
+			return filepath.SkipDir
+		} else if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), tmpPrefix) || path == versionPath || path == shardingPath {
+			return nil
+		}
+
+		scanned++
+		rel := strings.TrimPrefix(path, ds.baseDir)
+		key := unshardKey(rel)
+		reason := ""
+		if b, err := ioutil.ReadFile(path); err != nil {
+			klog.Errorf("failed to read %s when scanning cache, %v", key, err)
+			reason = quarantineReasonUnreadable
+		} else if _, err := verifyChecksum(b); err != nil {
+			klog.Errorf("checksum of %s(%d bytes) is not verified when scanning cache, it's corrupted", key, len(b))
+			reason = quarantineReasonCorrupted
+		} else {
+			return nil
+		}
+
+		if err := ds.moveToQuarantine(rel); err != nil {
+			klog.Errorf("failed to quarantine %s, %v", key, err)
+			return nil
+		}
+		quarantinedKeys.WithLabelValues(reason).Inc()
+		quarantined = append(quarantined, key)
+		return nil
+	})
+	if err != nil {
+		klog.Errorf("failed to scan cache in %s, %v", ds.baseDir, err)
+	}
+
+	quarantineScanDuration.Set(time.Since(start).Seconds())
+	if len(quarantined) != 0 {
+		klog.Warningf("%d of %d keys in cache are quarantined into %s, %v", len(quarantined), scanned, quarantineDir, quarantined)
+	} else {
+		klog.Infof("%d keys in cache are scanned in %v, no key is corrupted", scanned, time.Since(start))
+	}
+	return quarantined
+}
+
+// moveToQuarantine moves the file of rel path into quarantineKey, the file that is quarantined
+// for the same path before is replaced.
+func (ds *diskStorage) moveToQuarantine(rel string) error {
+	target := filepath.Join(ds.baseDir, quarantineKey, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if info, err := os.Lstat(target); err == nil && info.IsDir() {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+	return os.Rename(filepath.Join(ds.baseDir, rel), target)
+}
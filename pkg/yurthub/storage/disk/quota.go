@@ -0,0 +1,261 @@
+package disk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"k8s.io/klog"
+)
+
+// quotaLowWatermark is the percent of quota that usage is evicted down to once the quota
+// is exceeded, so evictions are done in batches instead of on every write.
+const quotaLowWatermark = 90
+
+// quotaEntry is the size on disk and modification time of a key
+type quotaEntry struct {
+	size    int64
+	modTime time.Time
+}
+
+// quota bounds the bytes on disk and the number of keys of disk storage. when a write
+// exceeds the quota, the least recently updated keys are evicted, except the keys under
+// protected prefixes and the keys that hold no objects(like internal keys of yurthub and
+// raw responses of aggregated apis, their component or resource starts with "_").
+// usage is tracked in memory after the cache is walked once when yurthub starts, so
+// writes don't walk the cache. concurrent writes may exceed the quota briefly.
+type quota struct {
+	maxBytes          int64
+	maxObjects        int
+	protectedPrefixes []string
+
+	sync.Mutex
+	usedBytes int64
+	entries   map[string]quotaEntry
+}
+
+// newQuota creates the quota of disk storage under baseDir, nil is returned if neither
+// maxBytes nor maxObjects is set.
+func newQuota(baseDir string, maxBytes int64, maxObjects int, protectedPrefixes []string) (*quota, error) {
+	if maxBytes <= 0 && maxObjects <= 0 {
+		return nil, nil
+	}
+
+	q := &quota{
+		maxBytes:   maxBytes,
+		maxObjects: maxObjects,
+		entries:    make(map[string]quotaEntry),
+	}
+	for _, prefix := range protectedPrefixes {
+		if prefix = strings.Trim(prefix, "/"); len(prefix) != 0 {
+			q.protectedPrefixes = append(q.protectedPrefixes, prefix)
+		}
+	}
+
+	err := filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() && (strings.HasPrefix(info.Name(), tmpPrefix) || path == filepath.Join(baseDir, quarantineKey)) {
+			return filepath.SkipDir
+		} else if info.Mode().IsRegular() {
+			if _, file := filepath.Split(path); !strings.HasPrefix(file, tmpPrefix) {
+				q.entries[strings.TrimPrefix(path, baseDir)] = quotaEntry{size: info.Size(), modTime: info.ModTime()}
+				q.usedBytes += info.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	klog.Infof("disk cache uses %d bytes of %d keys, quota is %d bytes and %d keys", q.usedBytes, len(q.entries), maxBytes, maxObjects)
+	return q, nil
+}
+
+// within checks the bytes and objects are within limits of bytes and objects
+func within(bytes int64, objects int, maxBytes int64, maxObjects int) bool {
+	return (maxBytes <= 0 || bytes <= maxBytes) && (maxObjects <= 0 || objects <= maxObjects)
+}
+
+// evictable checks key can be evicted or not, key is the rel path of file in cache,
+// which is in shard dirs if sharding is enabled.
+func (q *quota) evictable(key string) bool {
+	parts := strings.SplitN(key, "/", 3)
+	if len(parts) < 3 || strings.HasPrefix(parts[0], "_") || strings.HasPrefix(parts[1], "_") {
+		return false
+	}
+
+	// protected prefixes are keys, which may name an object without the shard dirs
+	key = unshardKey(key)
+	for _, prefix := range q.protectedPrefixes {
+		if key == prefix || strings.HasPrefix(key, prefix+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// victims returns the keys that are evicted before key of size is written, the least
+// recently updated keys are evicted until usage is below the low watermark of quota.
+// storage.ErrExceedQuota is returned if key can't be written even if all the evictable
+// keys are evicted, and nothing is evicted.
+func (q *quota) victims(key string, size int64) ([]string, error) {
+	return q.victimsOf(key, map[string]int64{key: size})
+}
+
+// victimsOf returns the keys that are evicted before the keys under root are replaced by
+// the keys of sizes, the keys under root are not evicted, they are replaced anyway.
+func (q *quota) victimsOf(root string, sizes map[string]int64) ([]string, error) {
+	q.Lock()
+	defer q.Unlock()
+
+	bytes, objects := q.usedBytes, len(q.entries)
+	for k, e := range q.entries {
+		if isUnder(k, root) {
+			bytes, objects = bytes-e.size, objects-1
+		}
+	}
+	var size int64
+	for _, s := range sizes {
+		size += s
+	}
+	bytes, objects = bytes+size, objects+len(sizes)
+	if within(bytes, objects, q.maxBytes, q.maxObjects) {
+		return nil, nil
+	}
+
+	candidates := make([]string, 0, len(q.entries))
+	for k := range q.entries {
+		if !isUnder(k, root) && q.evictable(k) {
+			candidates = append(candidates, k)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		ei, ej := q.entries[candidates[i]], q.entries[candidates[j]]
+		if ei.modTime.Equal(ej.modTime) {
+			return candidates[i] < candidates[j]
+		}
+		return ei.modTime.Before(ej.modTime)
+	})
+
+	lowBytes, lowObjects := q.maxBytes*quotaLowWatermark/100, q.maxObjects*quotaLowWatermark/100
+	var victims []string
+	for _, k := range candidates {
+		if within(bytes, objects, lowBytes, lowObjects) {
+			break
+		}
+		victims = append(victims, k)
+		bytes, objects = bytes-q.entries[k].size, objects-1
+	}
+
+	if !within(bytes, objects, q.maxBytes, q.maxObjects) {
+		klog.Errorf("%s of %d bytes can not be written, disk cache quota is exceeded by the keys that can not be evicted", root, size)
+		return nil, storage.ErrExceedQuota
+	}
+	return victims, nil
+}
+
+// set records the size on disk and modification time of key after it's written
+func (q *quota) set(key string, size int64, modTime time.Time) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.usedBytes += size - q.entries[key].size
+	q.entries[key] = quotaEntry{size: size, modTime: modTime}
+}
+
+// usage returns the bytes of all keys in cache
+func (q *quota) usage() int64 {
+	q.Lock()
+	defer q.Unlock()
+	return q.usedBytes
+}
+
+// remove forgets key after it's deleted
+func (q *quota) remove(key string) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.usedBytes -= q.entries[key].size
+	delete(q.entries, key)
+}
+
+// replace records the keys under root are replaced by entries
+func (q *quota) replace(root string, entries map[string]quotaEntry) {
+	q.Lock()
+	defer q.Unlock()
+
+	for k, e := range q.entries {
+		if isUnder(k, root) {
+			q.usedBytes -= e.size
+			delete(q.entries, k)
+		}
+	}
+	for k, e := range entries {
+		q.usedBytes += e.size
+		q.entries[k] = e
+	}
+}
+
+// isUnder checks key is root or under root
+func isUnder(key, root string) bool {
+	return key == root || strings.HasPrefix(key, root+"/")
+}
+
+// makeRoom evicts keys for key of size if the quota of disk storage is exceeded by it.
+// the keys that are under accessing are not evicted, so the quota may not be met, it's
+// met after the following writes.
+func (ds *diskStorage) makeRoom(key string, size int64) error {
+	return ds.makeRoomFor(key, map[string]int64{key: size})
+}
+
+// makeRoomFor evicts keys for replacing the keys under root by the keys of sizes
+func (ds *diskStorage) makeRoomFor(root string, sizes map[string]int64) error {
+	if ds.quota == nil {
+		return nil
+	}
+
+	victims, err := ds.quota.victimsOf(root, sizes)
+	if err != nil {
+		return err
+	}
+
+	evicted := 0
+	for _, victim := range victims {
+		key := unshardKey(victim)
+		if err := ds.delete(context.Background(), key, false); err != nil {
+			klog.V(4).Infof("%s is not evicted from disk cache, %v", victim, err)
+			continue
+		}
+		ds.evicted(key)
+		evicted++
+	}
+	if evicted != 0 {
+		klog.Infof("%d keys are evicted from disk cache for quota", evicted)
+	}
+	return nil
+}
+
+// OnEvict adds f that is called with the key after it's evicted for quota
+func (ds *diskStorage) OnEvict(f func(key string)) {
+	ds.evictLock.Lock()
+	defer ds.evictLock.Unlock()
+	ds.evictHandlers = append(ds.evictHandlers, f)
+}
+
+func (ds *diskStorage) evicted(key string) {
+	ds.evictLock.Lock()
+	handlers := ds.evictHandlers
+	ds.evictLock.Unlock()
+	for _, f := range handlers {
+		f(key)
+	}
+}
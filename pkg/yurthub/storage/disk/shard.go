@@ -0,0 +1,167 @@
+package disk
+
+import (
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+const (
+	// shardingKey records that the files of objects in cache are in shard dirs,
+	// cache without it is in the flat layout.
+	shardingKey = "_internal/storage/layout.sharded"
+	// shardingScheme is the hash of names and the number of shard dirs of each level,
+	// it's written to shardingKey for inspection.
+	shardingScheme = "fnv32a-16x16"
+	// shardDirPrefix is the prefix of the names of shard dirs, like "_a", keys can't
+	// have the components that are named like shard dirs.
+	shardDirPrefix = "_"
+	hexDigits      = "0123456789abcdef"
+)
+
+// isShardDir returns true if name is the name of a shard dir, like "_a"
+func isShardDir(name string) bool {
+	return len(name) == 2 && strings.HasPrefix(name, shardDirPrefix) && strings.IndexByte(hexDigits, name[1]) >= 0
+}
+
+// shardable returns true if the file of key is put in shard dirs when sharding is enabled.
+// like the evictable keys of quota, only the keys of objects(component/resource/.../name)
+// are sharded, the internal keys(like "_internal/..." and "component/_xxx/...") are not.
+func shardable(key string) bool {
+	parts := strings.SplitN(filepath.ToSlash(key), "/", 3)
+	return len(parts) == 3 && !strings.HasPrefix(parts[0], "_") && !strings.HasPrefix(parts[1], "_") &&
+		!strings.HasSuffix(parts[2], "/")
+}
+
+// shardPath returns the path of file in two levels of shard dirs under its dir, the shard
+// dirs are picked by the hash of the name of file, so a collection of 5k objects is spread
+// into 256 dirs of about 20 objects, and readdir of each dir stays fast on flash media.
+func shardPath(path string) string {
+	dir, name := filepath.Split(path)
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+	return filepath.Join(dir, shardDirPrefix+string(hexDigits[sum>>4&0xf]), shardDirPrefix+string(hexDigits[sum&0xf]), name)
+}
+
+// unshardKey returns the key of rel path of a file or dir in cache, the shard dirs in it are removed
+func unshardKey(rel string) string {
+	elems := strings.Split(filepath.ToSlash(rel), "/")
+	key := elems[:0]
+	for _, elem := range elems {
+		if !isShardDir(elem) {
+			key = append(key, elem)
+		}
+	}
+	return strings.Join(key, "/")
+}
+
+// filePath returns the path of the file of key, it's in shard dirs if sharding is enabled
+// and key is shardable. the path of collection is returned as it is for key that refers to
+// a collection, so the callers return ErrKeyIsDir as before.
+func (ds *diskStorage) filePath(key string) (string, error) {
+	path, err := ds.keyPath(key)
+	if err != nil || !ds.sharded || !shardable(key) {
+		return path, err
+	}
+
+	if info, err := os.Lstat(path); err == nil && info.IsDir() {
+		return path, nil
+	}
+	sharded := shardPath(path)
+	if err := ds.verifyPath(sharded); err != nil {
+		return "", err
+	}
+	return sharded, nil
+}
+
+// ShardedLayout returns true if the files of objects in cache under baseDir are in shard dirs
+func ShardedLayout(baseDir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(baseDir, shardingKey)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// migrateSharding moves the files of objects in cache under baseDir into shard dirs if sharded
+// is true, or back into the dirs of their collections otherwise. it's done once when sharding of
+// cache is changed, and the layout is recorded after all files are moved, so an interrupted
+// migration is continued when yurthub restarts.
+func migrateSharding(baseDir string, sharded bool) error {
+	current, err := ShardedLayout(baseDir)
+	if err != nil || current == sharded {
+		return err
+	}
+
+	quarantineDir := filepath.Join(baseDir, quarantineKey)
+	shardDirs := make([]string, 0)
+	moved := 0
+	err = filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if path == quarantineDir || (path != baseDir && strings.HasPrefix(info.Name(), tmpPrefix)) {
+				return filepath.SkipDir
+			} else if isShardDir(info.Name()) {
+				// the files in shard dirs are moved already by an interrupted migration
+				if sharded {
+					return filepath.SkipDir
+				}
+				shardDirs = append(shardDirs, path)
+			}
+			return nil
+		} else if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			return nil
+		}
+
+		key := unshardKey(strings.TrimPrefix(path, baseDir))
+		if !shardable(key) {
+			return nil
+		}
+		target := filepath.Join(baseDir, key)
+		if sharded {
+			target = shardPath(target)
+		} else if info, err := os.Lstat(target); err == nil && info.IsDir() {
+			klog.Warningf("%s is kept in shard dir, key %s is a collection in the flat layout", path, key)
+			return nil
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(path, target); err != nil {
+			return err
+		}
+		moved++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove the empty shard dirs from the deepest
+	for i := len(shardDirs) - 1; i >= 0; i-- {
+		if err := os.Remove(shardDirs[i]); err != nil && !os.IsNotExist(err) {
+			klog.V(4).Infof("shard dir %s is not removed, %v", shardDirs[i], err)
+		}
+	}
+
+	if sharded {
+		err = writeLayoutFile(baseDir, shardingKey, []byte(shardingScheme))
+	} else {
+		err = os.Remove(filepath.Join(baseDir, shardingKey))
+	}
+	if err != nil {
+		return err
+	}
+	klog.Infof("%d files in cache are moved for the sharded layout(%v)", moved, sharded)
+	return nil
+}
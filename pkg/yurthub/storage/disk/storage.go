@@ -1,65 +1,200 @@
 package disk
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 	"k8s.io/klog"
 )
 
 const (
-	cacheBaseDir = "/etc/kubernetes/cache/"
-	tmpPrefix    = "tmp_"
+	// DefaultBaseDir is the directory on disk where cache is stored by default
+	DefaultBaseDir = "/etc/kubernetes/cache/"
+	tmpPrefix      = "tmp_"
 )
 
 type diskStorage struct {
-	baseDir          string
-	keyPendingStatus map[string]struct{}
+	// baseDir is the directory where cache is stored, it ends with a separator
+	baseDir string
+	// locks serialize the operations on the same key
+	locks *keyLocks
+	// fsync flushes the contents of files and their dirs to disk before they are
+	// taken as written, so the writes survive power loss.
+	fsync bool
+	// compression is how contents are compressed when they are written
+	compression string
+	// quota bounds the size of cache, it's nil if the size of cache is not bounded
+	quota *quota
+	// meter counts the bytes written
+	meter *writeMeter
+	// sharded puts the files of objects into two levels of shard dirs under their
+	// collections, so a collection of thousands of objects is not one flat dir.
+	sharded bool
+	// evictHandlers are called with the keys evicted for quota
+	evictHandlers []func(key string)
+	evictLock     sync.Mutex
 	sync.RWMutex
 }
 
-func NewDiskStorage() (storage.Store, error) {
-	if _, err := os.Stat(cacheBaseDir); os.IsNotExist(err) {
-		if err = os.MkdirAll(cacheBaseDir, 0755); err != nil {
+// Options are the options of disk storage
+type Options struct {
+	// Fsync flushes writes to disk before they are completed
+	Fsync bool
+	// Compression is the compression of contents written to disk, like CompressionGzip,
+	// contents are not compressed if it's empty.
+	Compression string
+	// MaxBytes is the maximum bytes of cache on disk, 0 means no limit
+	MaxBytes int64
+	// MaxObjects is the maximum number of keys in cache, 0 means no limit
+	MaxObjects int
+	// ProtectedPrefixes are the prefixes of keys(like "kubelet/pods") that are never
+	// evicted when MaxBytes or MaxObjects is exceeded.
+	ProtectedPrefixes []string
+	// ReadOnly skips the writes when the storage is created(like recovering, migrating and
+	// quarantining), so the cache on disk is left as it is. the storage should be wrapped
+	// by a read-only store to reject the writes after it's created.
+	ReadOnly bool
+	// Sharding puts the files of objects into hashed shard dirs under their collections, the
+	// existing cache is migrated once when the storage is created after Sharding is changed.
+	Sharding bool
+}
+
+// NewDiskStorage creates the disk storage that stores cache under baseDir
+func NewDiskStorage(baseDir string) (storage.Store, error) {
+	return NewDiskStorageWithOptions(baseDir, Options{})
+}
+
+// NewDiskStorageWithOptions creates the disk storage that stores cache under baseDir with options
+func NewDiskStorageWithOptions(baseDir string, opts Options) (storage.Store, error) {
+	if len(baseDir) == 0 {
+		return nil, fmt.Errorf("base dir of disk storage is not set")
+	}
+	if len(opts.Compression) == 0 {
+		opts.Compression = CompressionNone
+	} else if !IsSupportedCompression(opts.Compression) {
+		return nil, fmt.Errorf("compression %s of disk storage is not supported", opts.Compression)
+	}
+	baseDir, err := filepath.Abs(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(baseDir, string(filepath.Separator)) {
+		baseDir += string(filepath.Separator)
+	}
+
+	if _, err := os.Stat(baseDir); os.IsNotExist(err) {
+		if err = os.MkdirAll(baseDir, 0755); err != nil {
 			return nil, err
 		}
 	}
 
 	ds := &diskStorage{
-		keyPendingStatus: make(map[string]struct{}, 0),
-		baseDir:          cacheBaseDir,
+		baseDir:     baseDir,
+		locks:       newKeyLocks(),
+		fsync:       opts.Fsync,
+		meter:       &writeMeter{},
+		compression: opts.Compression,
 	}
 
-	err := ds.Recover("")
-	if err != nil {
+	if opts.ReadOnly {
+		if version, err := LayoutVersion(ds.baseDir); err != nil {
+			return nil, err
+		} else if version > CurrentLayoutVersion {
+			return nil, fmt.Errorf("cache layout version %d is newer than version %d supported by yurthub", version, CurrentLayoutVersion)
+		} else if version < CurrentLayoutVersion {
+			klog.Warningf("cache layout version %d is not migrated to %d in read-only mode, the keys without checksum are taken as corrupted", version, CurrentLayoutVersion)
+		}
+		// the cache is read in the layout it's in
+		if ds.sharded, err = ShardedLayout(ds.baseDir); err != nil {
+			return nil, err
+		} else if ds.sharded != opts.Sharding {
+			klog.Warningf("sharded layout(%v) of cache is not migrated to %v in read-only mode", ds.sharded, opts.Sharding)
+		}
+		ds.quota, err = newQuota(ds.baseDir, opts.MaxBytes, opts.MaxObjects, opts.ProtectedPrefixes)
+		if err != nil {
+			return nil, fmt.Errorf("could not load usage of disk cache, %v", err)
+		}
+		return ds, nil
+	}
+
+	if err := ds.Recover(""); err != nil {
 		klog.Errorf("could not recover local storage, %v, and skip the error", err)
 	}
+
+	if err := MigrateLayout(ds.baseDir); err != nil {
+		klog.Errorf("could not migrate cache layout, %v", err)
+		return nil, err
+	}
+	if err := migrateSharding(ds.baseDir, opts.Sharding); err != nil {
+		klog.Errorf("could not migrate cache to sharded layout(%v), %v", opts.Sharding, err)
+		return nil, err
+	}
+	ds.sharded = opts.Sharding
+
+	// the corrupted keys are quarantined before the usage of cache is loaded
+	Register()
+	ds.quarantine()
+
+	ds.quota, err = newQuota(ds.baseDir, opts.MaxBytes, opts.MaxObjects, opts.ProtectedPrefixes)
+	if err != nil {
+		return nil, fmt.Errorf("could not load usage of disk cache, %v", err)
+	}
 	return ds, nil
 }
 
-func (ds *diskStorage) Create(key string, contents []byte) error {
+func (ds *diskStorage) Create(ctx context.Context, key string, contents []byte) error {
+	return runWithContext(ctx, func() error {
+		return ds.create(ctx, key, contents)
+	})
+}
+
+func (ds *diskStorage) create(ctx context.Context, key string, contents []byte) error {
 	if key == "" || len(contents) == 0 {
 		return nil
 	}
 
-	if !ds.lockKey(key) {
-		return storage.ErrStorageAccessConflict
+	unlock, err := ds.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return ds.write(key, contents)
+}
+
+// write writes contents of key to a temp file in the dir of key and renames it to key, so
+// the file of key is always either the old or the new complete contents, even if yurthub
+// crashes or the node loses power while writing. contents are compressed and written after
+// the header of their checksum, so the damaged contents are detected when they are read.
+func (ds *diskStorage) write(key string, contents []byte) error {
+	absKey, err := ds.filePath(key)
+	if err != nil {
+		return err
+	}
+
+	data, err := encodeFile(ds.compression, contents)
+	if err != nil {
+		return err
 	}
-	defer ds.unLockKey(key)
 
-	absKey := filepath.Join(cacheBaseDir, key)
-	if info, err := os.Stat(absKey); err != nil {
+	// symlink is not followed, so the file it refers to is not overwritten
+	if info, err := os.Lstat(absKey); err != nil {
 		if os.IsNotExist(err) {
 			dir, _ := filepath.Split(absKey)
 			if _, err := os.Stat(dir); err != nil {
 				if os.IsNotExist(err) {
 					if err = os.MkdirAll(dir, 0755); err != nil {
-						return err
+						return diskError(err)
 					}
 				} else {
 					return err
@@ -71,43 +206,115 @@ func (ds *diskStorage) Create(key string, contents []byte) error {
 			return err
 		}
 	} else if info.Mode().IsRegular() {
-		file, err := os.OpenFile(absKey, os.O_RDWR, 0666)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+		// the file is replaced by rename
+	} else if info.IsDir() {
+		return storage.ErrKeyIsDir
+	} else {
+		klog.Errorf("%s is exist, but not recognized, %v", key, info.Mode())
+		return fmt.Errorf("unexpected file type %v of %s", info.Mode(), key)
+	}
 
-		if err := file.Truncate(0); err != nil {
-			return err
-		}
+	// verify again before writing, in case the path is changed(like by a symlink)
+	// after the key is checked.
+	if err := ds.verifyPath(absKey); err != nil {
+		return err
+	}
 
-		if _, err := file.Seek(0, 0); err != nil {
-			return err
+	// keys are evicted before writing if the quota of cache is exceeded
+	relKey := strings.TrimPrefix(absKey, ds.baseDir)
+	if err := ds.makeRoom(relKey, int64(len(data))); err != nil {
+		return err
+	}
+
+	dir, file := filepath.Split(absKey)
+	tmp, err := ioutil.TempFile(dir, tmpPrefix+file+".")
+	if err != nil {
+		return diskError(err)
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return diskError(err)
+	}
+	if ds.fsync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return diskError(err)
 		}
-	} else {
-		klog.Errorf("%s is exist, but not recognized, %v", key, info.Mode())
-		return nil
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return diskError(err)
 	}
 
-	if err := ioutil.WriteFile(absKey, contents, 0600); err != nil {
+	// rename replaces the file of key(or a symlink, not the file it refers to) atomically
+	if err := os.Rename(tmpPath, absKey); err != nil {
+		os.Remove(tmpPath)
 		return err
 	}
+	ds.meter.add(len(contents), len(data))
+	if ds.quota != nil {
+		ds.quota.set(relKey, int64(len(data)), time.Now())
+	}
 
+	if ds.fsync {
+		// the rename is durable after the dir is flushed
+		if err := syncDir(dir); err != nil {
+			return diskError(err)
+		}
+	}
 	return nil
 }
 
-func (ds *diskStorage) Delete(key string) error {
+// renameDirs exchanges the dirs of a and b by renaming b aside, there is a short time
+// that b doesn't exist, so readers of b get nothing instead of a mix of a and b.
+func renameDirs(a, b string) error {
+	aside := a + ".old"
+	if err := os.Rename(b, aside); err != nil {
+		return err
+	}
+	if err := os.Rename(a, b); err != nil {
+		os.Rename(aside, b)
+		return err
+	}
+	return os.Rename(aside, a)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+func (ds *diskStorage) Delete(ctx context.Context, key string) error {
+	return runWithContext(ctx, func() error {
+		return ds.deleteKey(ctx, key)
+	})
+}
+
+// deleteKey deletes the key and its tmp key left by the previous versions
+func (ds *diskStorage) deleteKey(ctx context.Context, key string) error {
 	if key == "" {
 		return nil
 	}
 
+	// ErrInvalidKey is returned as is, rather than aggregated with the error of tmp key
+	if _, err := ds.keyPath(key); err != nil {
+		return err
+	}
+
 	errs := make([]error, 0)
-	if err := ds.delete(key); err != nil {
+	if err := ds.delete(ctx, key, true); err != nil {
 		errs = append(errs, err)
 	}
 
 	tmpKey := getTmpKey(key)
-	if err := ds.delete(tmpKey); err != nil {
+	if err := ds.delete(ctx, tmpKey, true); err != nil {
 		errs = append(errs, err)
 	}
 
@@ -117,18 +324,29 @@ func (ds *diskStorage) Delete(key string) error {
 	return nil
 }
 
-func (ds *diskStorage) delete(key string) error {
+// delete deletes the file of key, it waits until key is unlocked if wait is true,
+// otherwise ErrStorageAccessConflict is returned if key is locked.
+func (ds *diskStorage) delete(ctx context.Context, key string, wait bool) error {
 	if key == "" {
 		return nil
 	}
 
-	if !ds.lockKey(key) {
-		return storage.ErrStorageAccessConflict
+	lock := ds.locks.tryLock
+	if wait {
+		lock = ds.locks.lock
+	}
+	unlock, err := lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	absKey, err := ds.filePath(key)
+	if err != nil {
+		return err
 	}
-	defer ds.unLockKey(key)
 
-	absKey := filepath.Join(cacheBaseDir, key)
-	info, err := os.Stat(absKey)
+	info, err := os.Lstat(absKey)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -137,189 +355,606 @@ func (ds *diskStorage) delete(key string) error {
 	}
 
 	if info.Mode().IsRegular() {
-		return os.Remove(absKey)
+		if err := os.Remove(absKey); err != nil {
+			return err
+		}
+		if ds.quota != nil {
+			ds.quota.remove(strings.TrimPrefix(absKey, ds.baseDir))
+		}
+	}
+
+	return nil
+}
+
+// DeleteCollection deletes all keys under key, and removes the empty directories.
+// keys that are under accessing are skipped, and ErrStorageAccessConflict is returned.
+func (ds *diskStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	return runWithContext(ctx, func() error {
+		return ds.deleteCollection(ctx, key, force)
+	})
+}
+
+func (ds *diskStorage) deleteCollection(ctx context.Context, key string, force bool) error {
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+
+	absPath, err := ds.keyPath(key)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(absPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a collection", key)
+	}
+
+	dirs := make([]string, 0)
+	conflict := false
+	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else if info.Mode().IsRegular() {
+			if err := ds.delete(ctx, unshardKey(strings.TrimPrefix(path, ds.baseDir)), false); err == storage.ErrStorageAccessConflict {
+				conflict = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove directories from the deepest, a directory that is not empty
+	// (like a key is created during deleting) is kept.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Remove(dirs[i]); err != nil && !os.IsNotExist(err) {
+			klog.V(4).Infof("directory %s is not removed, %v", dirs[i], err)
+		}
 	}
 
+	if conflict {
+		return storage.ErrStorageAccessConflict
+	}
 	return nil
 }
 
-func (ds *diskStorage) Get(key string) ([]byte, error) {
-	return ds.get(filepath.Join(cacheBaseDir, key))
+// Replace replaces the keys under rootKey with contents atomically. contents are written
+// to a staging dir next to the dir of rootKey, and then the two dirs are exchanged, so the
+// keys under rootKey are always either the old or the new ones. the writes of single keys
+// under rootKey during the replace are lost, the result of a list is newer than them.
+func (ds *diskStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	return runWithContext(ctx, func() error {
+		return ds.replace(ctx, rootKey, contents)
+	})
 }
 
-func (ds *diskStorage) get(path string) ([]byte, error) {
+func (ds *diskStorage) replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+
+	absRoot, err := ds.keyPath(rootKey)
+	if err != nil {
+		return err
+	}
+	relRoot := strings.TrimPrefix(absRoot, ds.baseDir)
+
+	unlock, err := ds.locks.tryLock(ctx, relRoot)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	exists := false
+	if info, err := os.Lstat(absRoot); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a collection", rootKey)
+		}
+		exists = true
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	files := make(map[string][]byte, len(contents))
+	sizes := make(map[string]int64, len(contents))
+	requested := make(map[string]int, len(contents))
+	for key, b := range contents {
+		absKey, err := ds.filePath(key)
+		if err != nil {
+			return err
+		}
+		data, err := encodeFile(ds.compression, b)
+		if err != nil {
+			return err
+		}
+		relKey := strings.TrimPrefix(absKey, ds.baseDir)
+		files[relKey] = data
+		sizes[relKey] = int64(len(data))
+		requested[relKey] = len(b)
+	}
+
+	// keys out of rootKey are evicted before writing if the quota of cache is exceeded
+	if err := ds.makeRoomFor(relRoot, sizes); err != nil {
+		return err
+	}
+
+	parent, base := filepath.Split(absRoot)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return diskError(err)
+	}
+	staging, err := ioutil.TempDir(parent, tmpPrefix+base+".")
+	if err != nil {
+		return diskError(err)
+	}
+	// after the exchange, the staging dir holds the old keys
+	defer os.RemoveAll(staging)
+	if err := os.Chmod(staging, 0755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entries := make(map[string]quotaEntry, len(files))
+	for relKey, data := range files {
+		path := filepath.Join(staging, strings.TrimPrefix(relKey, relRoot+"/"))
+		if err := ds.writeFile(path, data); err != nil {
+			return err
+		}
+		ds.meter.add(requested[relKey], len(data))
+		entries[relKey] = quotaEntry{size: int64(len(data)), modTime: now}
+	}
+
+	// verify again before exchanging, in case the path is changed(like by a symlink)
+	// after the key is checked.
+	if err := ds.verifyPath(absRoot); err != nil {
+		return err
+	}
+	if exists {
+		err = exchangeDirs(staging, absRoot)
+	} else {
+		err = os.Rename(staging, absRoot)
+	}
+	if err != nil {
+		return diskError(err)
+	}
+	if ds.quota != nil {
+		ds.quota.replace(relRoot, entries)
+	}
+
+	if ds.fsync {
+		// the exchange is durable after the parent dir is flushed
+		if err := syncDir(parent); err != nil {
+			return diskError(err)
+		}
+	}
+	return nil
+}
+
+// writeFile writes data to the new file of path, and creates the dirs of path
+func (ds *diskStorage) writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return diskError(err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return diskError(err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return diskError(err)
+	}
+	if ds.fsync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return diskError(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return diskError(err)
+	}
+
+	if ds.fsync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+func (ds *diskStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	var b []byte
+	err := runWithContext(ctx, func() error {
+		absKey, err := ds.filePath(key)
+		if err != nil {
+			return err
+		}
+
+		b, err = ds.get(ctx, absKey)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (ds *diskStorage) get(ctx context.Context, path string) ([]byte, error) {
 	if path == "" {
 		return nil, nil
 	}
 
-	key := strings.TrimPrefix(path, cacheBaseDir)
-	if !ds.lockKey(key) {
-		return nil, storage.ErrStorageAccessConflict
+	key := unshardKey(strings.TrimPrefix(path, ds.baseDir))
+	unlock, err := ds.locks.lock(ctx, key)
+	if err != nil {
+		return nil, err
 	}
-	defer ds.unLockKey(key)
+	defer unlock()
 
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return []byte{}, nil
+			return nil, storage.ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get bytes for %s, %v", key, err)
+	} else if info.IsDir() {
+		return nil, storage.ErrKeyIsDir
 	} else if info.Mode().IsRegular() {
 		b, err := ioutil.ReadFile(path)
 		if err != nil {
 			return nil, err
 		}
 
-		return b, nil
+		contents, err := verifyChecksum(b)
+		if err != nil {
+			klog.Errorf("checksum of %s is not verified, it's corrupted", key)
+			return nil, err
+		}
+		return contents, nil
 	}
 
 	return nil, fmt.Errorf("%s is exist, but not recognized, %v", key, info.Mode())
 }
 
-func (ds *diskStorage) ListKeys(key string) ([]string, error) {
-	keys := make([]string, 0)
-	absPath := filepath.Join(cacheBaseDir, key)
-	if info, err := os.Stat(absPath); err != nil {
-		if os.IsNotExist(err) {
-			return keys, nil
-		}
-		return keys, err
-	} else if info.IsDir() {
-		err := filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+func (ds *diskStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return ds.ListKeysInOrder(ctx, key, storage.OrderByKey)
+}
 
-			if info.Mode().IsRegular() {
-				_, file := filepath.Split(path)
-				if !strings.HasPrefix(file, tmpPrefix) {
-					keys = append(keys, strings.TrimPrefix(path, cacheBaseDir))
-				}
-			}
+// ListKeysInOrder returns the keys under key in the specified order
+func (ds *diskStorage) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	var keys []string
+	err := runWithContext(ctx, func() error {
+		var err error
+		keys, err = ds.listKeysInOrder(key, order)
+		return err
+	})
 
-			return nil
-		})
+	if err != nil {
+		return []string{}, err
+	}
+	return keys, nil
+}
 
-		return keys, err
-	} else if info.Mode().IsRegular() {
-		keys = append(keys, key)
-		return keys, nil
+func (ds *diskStorage) listKeysInOrder(key string, order storage.ListOrder) ([]string, error) {
+	entries, err := ds.listEntries(key)
+	if err != nil {
+		return []string{}, err
 	}
 
-	return keys, fmt.Errorf("failed to list keys because %s not recognized", key)
+	if err := sortEntries(entries, order); err != nil {
+		return []string{}, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for i := range entries {
+		keys = append(keys, entries[i].key)
+	}
+
+	return keys, nil
 }
 
-func (ds *diskStorage) List(key string) ([][]byte, error) {
-	if key == "" {
-		return nil, fmt.Errorf("key for list is empty")
+// Touch sets the modification time of the file of key to now without rewriting it
+func (ds *diskStorage) Touch(ctx context.Context, key string) error {
+	return runWithContext(ctx, func() error {
+		return ds.touch(ctx, key)
+	})
+}
+
+func (ds *diskStorage) touch(ctx context.Context, key string) error {
+	unlock, err := ds.locks.lock(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	absKey, err := ds.filePath(key)
+	if err != nil {
+		return err
 	}
 
-	bb := make([][]byte, 0)
-	absKey := filepath.Join(cacheBaseDir, key)
-	info, err := os.Stat(absKey)
+	info, err := os.Lstat(absKey)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return bb, nil
-		}
-		klog.Errorf("filed to list bytes for (%s), %v", key, err)
-		return nil, err
-	} else if info.Mode().IsRegular() {
-		b, err := ds.get(absKey)
-		if err != nil {
-			return nil, err
+			return storage.ErrNotFound
 		}
+		return err
+	} else if info.IsDir() {
+		return storage.ErrKeyIsDir
+	} else if !info.Mode().IsRegular() {
+		return storage.ErrNotFound
+	}
 
-		bb = append(bb, b)
-		return bb, nil
-	} else if info.Mode().IsDir() {
-		err := filepath.Walk(absKey, func(path string, info os.FileInfo, err error) error {
+	now := time.Now()
+	if err := os.Chtimes(absKey, now, now); err != nil {
+		return diskError(err)
+	}
+	if ds.quota != nil {
+		ds.quota.set(strings.TrimPrefix(absKey, ds.baseDir), info.Size(), now)
+	}
+	return nil
+}
+
+// Usage returns the bytes on disk of all keys in cache(including their checksums, so it's
+// a little larger than the sum of sizes of StatKeys), it's tracked by the quota if the size
+// of cache is bounded, otherwise the cache is walked.
+func (ds *diskStorage) Usage(ctx context.Context) (int64, error) {
+	if ds.quota != nil {
+		return ds.quota.usage(), nil
+	}
+
+	var total int64
+	err := runWithContext(ctx, func() error {
+		return filepath.Walk(ds.baseDir, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
 
-			if info.Mode().IsRegular() {
-				b, err := ds.get(path)
-				if err != nil {
-					klog.Warningf("failed to get bytes for %s when listing bytes, %v", path, err)
-					return nil
-				}
-
-				bb = append(bb, b)
+			if info.IsDir() && (strings.HasPrefix(info.Name(), tmpPrefix) || path == filepath.Join(ds.baseDir, quarantineKey)) {
+				return filepath.SkipDir
+			} else if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), tmpPrefix) {
+				total += info.Size()
 			}
-
 			return nil
 		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
 
+// StatKeys returns the metadata of keys under key in ascending lexical order of keys
+func (ds *diskStorage) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	var infos []storage.KeyInfo
+	err := runWithContext(ctx, func() error {
+		entries, err := ds.listEntries(key)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		return bb, nil
+		if err := sortEntries(entries, storage.OrderByKey); err != nil {
+			return err
+		}
+
+		infos = make([]storage.KeyInfo, 0, len(entries))
+		for i := range entries {
+			infos = append(infos, storage.KeyInfo{
+				Key:     entries[i].key,
+				Size:    entries[i].size,
+				ModTime: entries[i].modTime,
+			})
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
 	}
+	return infos, nil
+}
 
-	return nil, fmt.Errorf("%s is exist, but not recognized, %v", key, info.Mode())
+func (ds *diskStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	return ds.ListInOrder(ctx, key, storage.OrderByKey)
 }
 
-func (ds *diskStorage) Update(key string, contents []byte) error {
-	if key == "" || len(contents) == 0 {
-		return nil
+// ListInOrder returns the contents under key in the specified order of their keys
+func (ds *diskStorage) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	var bb [][]byte
+	err := runWithContext(ctx, func() error {
+		var err error
+		bb, err = ds.listInOrder(ctx, key, order)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
 	}
+	return bb, nil
+}
 
-	if !ds.lockKey(key) {
-		return storage.ErrStorageAccessConflict
+func (ds *diskStorage) listInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if key == "" {
+		return nil, fmt.Errorf("key for list is empty")
 	}
-	defer ds.unLockKey(key)
 
-	dir, file := filepath.Split(key)
-	tmpKey := filepath.Join(dir, fmt.Sprintf("%s%s", tmpPrefix, file))
+	entries, err := ds.listEntries(key)
+	if err != nil {
+		klog.Errorf("filed to list bytes for (%s), %v", key, err)
+		return nil, err
+	}
+
+	if err := sortEntries(entries, order); err != nil {
+		return nil, err
+	}
 
-	err := ds.Create(tmpKey, contents)
+	// files are read concurrently, so a list of hundreds of objects is not bounded by
+	// the latency of reading files one by one.
+	paths := make([]string, len(entries))
+	for i := range entries {
+		paths[i] = entries[i].path
+	}
+	contents, errs, err := ds.getAll(ctx, paths)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	bb := make([][]byte, 0, len(entries))
+	for i := range entries {
+		if errs[i] != nil {
+			if len(entries) == 1 && entries[i].key == key {
+				// list the specified file
+				return nil, errs[i]
+			}
+			klog.Warningf("failed to get bytes for %s when listing bytes, %v", entries[i].key, errs[i])
+			continue
+		}
+
+		bb = append(bb, contents[i])
 	}
 
-	tmpPath := filepath.Join(cacheBaseDir, tmpKey)
-	absKey := filepath.Join(cacheBaseDir, key)
-	info, err := os.Stat(absKey)
+	return bb, nil
+}
+
+// listEntry is a key found by list with the path of its file, size and modification time
+type listEntry struct {
+	key     string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// listEntries returns the keys under key, tmp files are skipped.
+// if key is a regular file, key itself is returned.
+func (ds *diskStorage) listEntries(key string) ([]listEntry, error) {
+	entries := make([]listEntry, 0)
+	absPath, err := ds.keyPath(key)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			os.Remove(tmpPath)
-			return err
+		return entries, err
+	}
+
+	info, err := os.Stat(absPath)
+	if os.IsNotExist(err) && ds.sharded && shardable(key) {
+		// key may refer to the file of an object in shard dirs
+		absPath = shardPath(absPath)
+		info, err = os.Stat(absPath)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
 		}
+		return entries, err
 	} else if info.Mode().IsRegular() {
-		if err := os.Remove(absKey); err != nil {
-			os.Remove(tmpPath)
+		entries = append(entries, listEntry{key: key, path: absPath, size: contentSize(info.Size()), modTime: info.ModTime()})
+		return entries, nil
+	} else if !info.IsDir() {
+		return entries, fmt.Errorf("failed to list keys because %s not recognized", key)
+	}
+
+	err = filepath.Walk(absPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
 			return err
 		}
+
+		// staging dirs of replace and the quarantined files are not keys
+		if info.IsDir() && path != absPath && strings.HasPrefix(info.Name(), tmpPrefix) {
+			return filepath.SkipDir
+		} else if info.IsDir() && path == filepath.Join(ds.baseDir, quarantineKey) {
+			return filepath.SkipDir
+		} else if info.Mode().IsRegular() {
+			_, file := filepath.Split(path)
+			if !strings.HasPrefix(file, tmpPrefix) {
+				entries = append(entries, listEntry{
+					key:     unshardKey(strings.TrimPrefix(path, ds.baseDir)),
+					path:    path,
+					size:    contentSize(info.Size()),
+					modTime: info.ModTime(),
+				})
+			}
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// sortEntries sorts entries in the specified order, entries are sorted by key
+// explicitly, because the order of filepath.Walk is not the lexical order of
+// whole keys(like "a/b" is walked before "a-b").
+func sortEntries(entries []listEntry, order storage.ListOrder) error {
+	switch order {
+	case storage.OrderByKey:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	case storage.OrderByModTime:
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].modTime.Equal(entries[j].modTime) {
+				return entries[i].key < entries[j].key
+			}
+			return entries[i].modTime.Before(entries[j].modTime)
+		})
+	default:
+		return fmt.Errorf("list order %s is not supported", order)
 	}
 
-	return os.Rename(tmpPath, absKey)
+	return nil
+}
+
+func (ds *diskStorage) Update(ctx context.Context, key string, contents []byte) error {
+	return runWithContext(ctx, func() error {
+		return ds.update(ctx, key, contents)
+	})
+}
+
+// update replaces the contents of key, it's the same as create, because create replaces
+// the file of key atomically.
+func (ds *diskStorage) update(ctx context.Context, key string, contents []byte) error {
+	return ds.create(ctx, key, contents)
 }
 
+// Recover removes the temp files and staging dirs of replace that are left under key when
+// yurthub crashes or the node loses power while writing. the temp files may be incomplete,
+// and the files of keys are always complete, so the temp files are never taken as the
+// contents of keys.
 func (ds *diskStorage) Recover(key string) error {
-	dir := filepath.Join(cacheBaseDir, key)
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+	dir, err := ds.keyPath(key)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.Mode().IsRegular() {
+		if info.IsDir() && path != dir && strings.HasPrefix(info.Name(), tmpPrefix) {
+			if err := os.RemoveAll(path); err != nil {
+				klog.V(2).Infof("failed to remove staging dir %s, %v", path, err)
+			} else {
+				klog.V(2).Infof("staging dir %s is removed", path)
+			}
+			return filepath.SkipDir
+		} else if info.Mode().IsRegular() {
 			_, file := filepath.Split(path)
 			if strings.HasPrefix(file, tmpPrefix) {
-				tmpKey := strings.TrimPrefix(path, cacheBaseDir)
-				key := getKey(tmpKey)
-				keyPath := filepath.Join(cacheBaseDir, key)
-				if !ds.lockKey(key) {
-					return nil
-				}
-				defer ds.unLockKey(key)
-
-				iErr := os.Rename(path, keyPath)
-				if iErr != nil {
-					klog.V(2).Infof("failed to recover bytes %s, %v", tmpKey, err)
+				if err := os.Remove(path); err != nil {
+					klog.V(2).Infof("failed to remove temp file %s, %v", path, err)
 					return nil
 				}
-				klog.V(2).Infof("bytes %s recovered successfully", key)
+				klog.V(2).Infof("temp file %s is removed", path)
 			}
 		}
 
@@ -329,49 +964,58 @@ func (ds *diskStorage) Recover(key string) error {
 	return err
 }
 
-func (ds *diskStorage) lockKey(key string) bool {
-	if ds.isKeyPending(key) {
-		return false
-	} else {
-		if !ds.setKeyPending(key) {
-			return false
-		}
-	}
-	return true
+// LockKey locks key for a transaction of multiple operations, the operations of others
+// on key wait until it's unlocked by UnlockKey, or fail with ErrStorageAccessConflict
+// if they don't wait(like DeleteCollection).
+func (ds *diskStorage) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return ds.locks.lockTx(ctx, key)
 }
 
-func (ds *diskStorage) unLockKey(key string) {
-	ds.Lock()
-	defer ds.Unlock()
-	delete(ds.keyPendingStatus, key)
+// UnlockKey unlocks key that is locked by LockKey with ctx
+func (ds *diskStorage) UnlockKey(ctx context.Context, key string) {
+	if !ds.locks.unlockTx(ctx, key) {
+		klog.Warningf("key %s is not locked by the transaction, skip unlocking it", key)
+	}
 }
 
-func (ds *diskStorage) isKeyPending(key string) bool {
-	ds.RLock()
-	defer ds.RUnlock()
-	if _, ok := ds.keyPendingStatus[key]; ok {
-		return true
-	}
-	return false
+// getTmpKey returns the temp key that the previous versions wrote key through
+func getTmpKey(key string) string {
+	dir, file := filepath.Split(key)
+	return filepath.Join(dir, fmt.Sprintf("%s%s", tmpPrefix, file))
 }
 
-func (ds *diskStorage) setKeyPending(key string) bool {
-	ds.Lock()
-	defer ds.Unlock()
-	if _, ok := ds.keyPendingStatus[key]; ok {
-		return false
+// diskError converts the error of writing disk into the error of storage,
+// so callers can tell a full disk from other failures.
+func diskError(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		return storage.ErrExceedQuota
 	}
 
-	ds.keyPendingStatus[key] = struct{}{}
-	return true
+	return err
 }
 
-func getTmpKey(key string) string {
-	dir, file := filepath.Split(key)
-	return filepath.Join(dir, fmt.Sprintf("%s%s", tmpPrefix, file))
-}
+// runWithContext runs fn and returns when fn is finished or ctx is done, so slow disk
+// (like NFS-backed cache or dying SD card) doesn't block the caller beyond its deadline.
+// disk operations can not be interrupted, so fn goes on in background after ctx is done,
+// and the key stays locked until fn is finished. the results set by fn can only be read
+// when nil is returned, as fn may still be running otherwise.
+func runWithContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	} else if ctx.Done() == nil {
+		// ctx can never be canceled
+		return fn()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- fn()
+	}()
 
-func getKey(tmpKey string) string {
-	dir, file := filepath.Split(tmpKey)
-	return filepath.Join(dir, strings.TrimPrefix(file, tmpPrefix))
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
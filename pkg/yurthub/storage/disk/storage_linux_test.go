@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package disk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWriteUnexpectedFileType(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(baseDir, "kubelet/pods/default"), 0755); err != nil {
+		t.Fatalf("unable to create dir, %v", err)
+	}
+	fifo := filepath.Join(baseDir, "kubelet/pods/default/fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatalf("unable to create fifo, %v", err)
+	}
+
+	if err := s.Update(context.Background(), "kubelet/pods/default/fifo", []byte("foo")); err == nil {
+		t.Errorf("Got no error, but expect error for writing a fifo")
+	}
+	if info, err := os.Lstat(fifo); err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("expect fifo is kept, but got %v, %v", info, err)
+	}
+}
@@ -2,11 +2,17 @@ package disk
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
 )
 
 var (
@@ -14,84 +20,93 @@ var (
 	tempKey = "kubelet/default/pods/test-pod"
 )
 
+// readFile returns the contents in file of key after its checksum is verified
+func readFile(path string) ([]byte, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return verifyChecksum(b)
+}
+
+// writeFile writes the file of key with the header of checksum
+func writeFile(path string, contents []byte) error {
+	return ioutil.WriteFile(path, append(checksumHeader(contents), contents...), 0600)
+}
+
 func TestCreate(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	b, err := ioutil.ReadFile(createdFile)
+	b, err := readFile(createdFile)
 	if err != nil {
 		t.Errorf("Got error %v, unable read regular file %q", err, createdFile)
 	} else if !bytes.Equal(b, []byte("test-pod")) {
 		t.Errorf("Wanted string: test-pod but got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestCreateFileExist(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod1"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod1"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod2"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod2"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s witch contents test-pod2", err, tempKey)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	b, err := ioutil.ReadFile(createdFile)
+	b, err := readFile(createdFile)
 	if err != nil {
 		t.Errorf("Got error %v, unable read regular file %q", err, createdFile)
 	} else if !bytes.Equal(b, []byte("test-pod2")) {
 		t.Errorf("Wanted string: test-pod2 but got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestCreateDirExist(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	dir, _ := filepath.Split(createdFile)
 	if err = os.MkdirAll(dir, 0755); err != nil {
 		t.Errorf("Got error %v, unable make dir %s", err, dir)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
@@ -102,37 +117,34 @@ func TestCreateDirExist(t *testing.T) {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	b, err := ioutil.ReadFile(createdFile)
+	b, err := readFile(createdFile)
 	if err != nil {
 		t.Errorf("Got error %v, unable read regular file %q", err, createdFile)
 	} else if !bytes.Equal(b, []byte("test-pod")) {
 		t.Errorf("Wanted string: test-pod but got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestDelete(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	err = s.Delete(tempKey)
+	err = s.Delete(context.Background(), tempKey)
 	if err != nil {
 		t.Errorf("Got error %v, unable delete key %q", err, tempKey)
 	}
@@ -140,121 +152,186 @@ func TestDelete(t *testing.T) {
 	if _, err := os.Stat(createdFile); err == nil || !os.IsNotExist(err) {
 		t.Errorf("want %q is deleted, but it still exist", createdFile)
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestDeleteFileNotExist(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
-	err = s.Delete(tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
+	err = s.Delete(context.Background(), tempKey)
 	if err != nil {
 		t.Errorf("Got error %v, delete not exist file(%q) returned error", err, createdFile)
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestDeleteDir(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	err = s.Delete(tempDir)
+	err = s.Delete(context.Background(), tempDir)
 	if err != nil {
 		t.Errorf("Got error %v, unable delete dir key %q", err, tempDir)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
+}
+
+func TestDeleteCollection(t *testing.T) {
+	testcases := map[string]struct {
+		key         string
+		force       bool
+		expectErr   bool
+		deletedKeys []string
+	}{
+		"delete collection of resource": {
+			key:         "kubelet/pods",
+			deletedKeys: []string{"kubelet/pods/default/foo", "kubelet/pods/kube-system/bar"},
+		},
+		"delete collection of component without force": {
+			key:       "kubelet",
+			expectErr: true,
+		},
+		"delete collection of component with force": {
+			key:         "kubelet",
+			force:       true,
+			deletedKeys: []string{"kubelet/pods/default/foo", "kubelet/pods/kube-system/bar", "kubelet/nodes/foo"},
+		},
+		"delete internal collection without force": {
+			key:       "_internal/cache-manager",
+			expectErr: true,
+		},
+		"delete root of storage": {
+			key:       "/",
+			force:     true,
+			expectErr: true,
+		},
+	}
+
+	keys := []string{"kubelet/pods/default/foo", "kubelet/pods/kube-system/bar", "kubelet/nodes/foo", "_internal/cache-manager/foo"}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorage(baseDir)
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+
+			for _, key := range keys {
+				if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+					t.Fatalf("Got error %v, wanted successful create %s", err, key)
+				}
+			}
+
+			err = s.DeleteCollection(context.Background(), tt.key, tt.force)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expect error for deleting collection %s, but got nil", tt.key)
+				}
+			} else if err != nil {
+				t.Errorf("Got error %v, unable to delete collection %s", err, tt.key)
+			}
 
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
+			deleted := make(map[string]bool)
+			for _, key := range tt.deletedKeys {
+				deleted[key] = true
+			}
+
+			for _, key := range keys {
+				_, err := os.Stat(filepath.Join(baseDir, key))
+				if deleted[key] && !os.IsNotExist(err) {
+					t.Errorf("expect key %s is deleted, but got %v", key, err)
+				} else if !deleted[key] && err != nil {
+					t.Errorf("expect key %s is kept, but got %v", key, err)
+				}
+			}
+
+			if !tt.expectErr {
+				if _, err := os.Stat(filepath.Join(baseDir, tt.key)); !os.IsNotExist(err) {
+					t.Errorf("expect dir of collection %s is removed, but got %v", tt.key, err)
+				}
+			}
+		})
 	}
 }
 
 func TestGet(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	b, err := s.Get(tempKey)
+	b, err := s.Get(context.Background(), tempKey)
 	if err != nil {
 		t.Errorf("Got error %v, get key %q", err, tempKey)
 	} else if !bytes.Equal(b, []byte("test-pod")) {
 		t.Errorf("Wanted string: test-pod but got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestGetFileNotExist(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	b, err := s.Get(tempKey)
-	if err != nil {
-		t.Errorf("Got error %v, get key %q", err, tempKey)
+	b, err := s.Get(context.Background(), tempKey)
+	if err != storage.ErrNotFound {
+		t.Errorf("Got error %v, wanted %v for get key %q", err, storage.ErrNotFound, tempKey)
 	} else if len(b) != 0 {
 		t.Errorf("Wanted empty string got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestGetNotRegularFile(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	_, err = s.Get(tempDir)
-	if err == nil {
-		t.Errorf("Got not error for dir key %q", tempDir)
+	_, err = s.Get(context.Background(), tempDir)
+	if err != storage.ErrKeyIsDir {
+		t.Errorf("Got error %v, wanted %v for dir key %q", err, storage.ErrKeyIsDir, tempDir)
 	}
 
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
+	err = s.Update(context.Background(), tempDir, []byte("test-pod"))
+	if err != storage.ErrKeyIsDir {
+		t.Errorf("Got error %v, wanted %v for update dir key %q", err, storage.ErrKeyIsDir, tempDir)
 	}
 }
 
 func TestListKeys(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
@@ -262,13 +339,13 @@ func TestListKeys(t *testing.T) {
 	tempKeys := make([]string, 5)
 	for i := 0; i < 5; i++ {
 		tempKeys[i] = fmt.Sprintf("%s-%d", tempKey, i)
-		err = s.Create(tempKeys[i], []byte("test-pod"))
+		err = s.Create(context.Background(), tempKeys[i], []byte("test-pod"))
 		if err != nil {
 			t.Errorf("Got error %v, wanted successful create %s", err, tempKeys[i])
 		}
 	}
 
-	keys, err := s.ListKeys(tempDir)
+	keys, err := s.ListKeys(context.Background(), tempDir)
 	if err != nil {
 		t.Errorf("Got error %v, unable list keys for %s", err, tempDir)
 	}
@@ -289,19 +366,112 @@ func TestListKeys(t *testing.T) {
 			t.Errorf("key %s is not found by list keys", key)
 		}
 	}
+}
+
+func TestStatKeys(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	contents := map[string]string{
+		tempKey + "-1": "test-pod",
+		tempKey + "-2": "test-pod-2",
+	}
+	for key, content := range contents {
+		if err := s.Create(context.Background(), key, []byte(content)); err != nil {
+			t.Fatalf("Got error %v, wanted successful create %s", err, key)
+		}
+	}
+
+	infos, err := s.(storage.StatStore).StatKeys(context.Background(), tempDir)
+	if err != nil {
+		t.Fatalf("Got error %v, unable stat keys for %s", err, tempDir)
+	}
+
+	if len(infos) != len(contents) {
+		t.Fatalf("expect %d keys, but got %d keys", len(contents), len(infos))
+	}
+	for i, info := range infos {
+		if i > 0 && infos[i-1].Key >= info.Key {
+			t.Errorf("keys are not in lexical order, %s is before %s", infos[i-1].Key, info.Key)
+		}
+		if info.Size != int64(len(contents[info.Key])) {
+			t.Errorf("expect size %d for %s, but got %d", len(contents[info.Key]), info.Key, info.Size)
+		}
+		if info.ModTime.IsZero() {
+			t.Errorf("expect modification time for %s, but got zero", info.Key)
+		}
+	}
+}
+
+func TestListKeysInOrder(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	ds := s.(*diskStorage)
+
+	// keys are created in the order of modification time, and "a/b" is walked before "a-b"
+	keys := []string{"kubelet/pods/ns/a-b", "kubelet/pods/ns/a/b", "kubelet/pods/ns/c", "kubelet/pods/ns/b"}
+	now := time.Now()
+	for i, key := range keys {
+		if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+			t.Fatalf("Got error %v, wanted successful create %s", err, key)
+		}
+		modTime := now.Add(time.Duration(i) * time.Second)
+		if err := os.Chtimes(filepath.Join(baseDir, key), modTime, modTime); err != nil {
+			t.Fatalf("Got error %v, unable to change time of %s", err, key)
+		}
+	}
+
+	testcases := map[string]struct {
+		order    storage.ListOrder
+		expected []string
+	}{
+		"order by key": {
+			order:    storage.OrderByKey,
+			expected: []string{"kubelet/pods/ns/a-b", "kubelet/pods/ns/a/b", "kubelet/pods/ns/b", "kubelet/pods/ns/c"},
+		},
+		"order by modification time": {
+			order:    storage.OrderByModTime,
+			expected: keys,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			listedKeys, err := ds.ListKeysInOrder(context.Background(), "kubelet/pods", tt.order)
+			if err != nil {
+				t.Errorf("Got error %v, unable list keys", err)
+			}
+			if !reflect.DeepEqual(listedKeys, tt.expected) {
+				t.Errorf("expect keys %v, but got %v", tt.expected, listedKeys)
+			}
 
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
+			contents, err := ds.ListInOrder(context.Background(), "kubelet/pods", tt.order)
+			if err != nil {
+				t.Errorf("Got error %v, unable list", err)
+			}
+			for i := range contents {
+				if string(contents[i]) != tt.expected[i] {
+					t.Errorf("expect content %s at %d, but got %s", tt.expected[i], i, string(contents[i]))
+				}
+			}
+		})
 	}
 }
 
 func TestListKeysForEmptyDir(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	keys, err := s.ListKeys(tempDir)
+	keys, err := s.ListKeys(context.Background(), tempDir)
 	if err != nil {
 		t.Errorf("Got error %v, unable list keys for empty dir %s", err, tempDir)
 	}
@@ -309,24 +479,21 @@ func TestListKeysForEmptyDir(t *testing.T) {
 	if len(keys) != 0 {
 		t.Errorf("expect 0 key, but got %d keys", len(keys))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestListKeysForRegularFile(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	keys, err := s.ListKeys(tempKey)
+	keys, err := s.ListKeys(context.Background(), tempKey)
 	if err != nil {
 		t.Errorf("Got error %v, unable list keys for empty dir %s", err, tempDir)
 	}
@@ -338,14 +505,11 @@ func TestListKeysForRegularFile(t *testing.T) {
 	if keys[0] != tempKey {
 		t.Errorf("listKeys: expect %s key, but got %s key", tempKey, keys[0])
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestList(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
@@ -353,13 +517,13 @@ func TestList(t *testing.T) {
 	tempContents := make([]string, 5)
 	for i := 0; i < 5; i++ {
 		tempContents[i] = fmt.Sprintf("test-pod-%d", i)
-		err = s.Create(fmt.Sprintf("%s-%d", tempKey, i), []byte(tempContents[i]))
+		err = s.Create(context.Background(), fmt.Sprintf("%s-%d", tempKey, i), []byte(tempContents[i]))
 		if err != nil {
 			t.Errorf("Got error %v, wanted successful create %s", err, fmt.Sprintf("%s-%d", tempKey, i))
 		}
 	}
 
-	contents, err := s.List(tempDir)
+	contents, err := s.List(context.Background(), tempDir)
 	if err != nil {
 		t.Errorf("Got error %v, unable list for %s", err, tempDir)
 	}
@@ -380,19 +544,16 @@ func TestList(t *testing.T) {
 			t.Errorf("content %s is not found by list", content)
 		}
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestListEmptyDir(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	contents, err := s.List(tempDir)
+	contents, err := s.List(context.Background(), tempDir)
 	if err != nil {
 		t.Errorf("Got error %v, unable list for %s", err, tempDir)
 	}
@@ -400,24 +561,21 @@ func TestListEmptyDir(t *testing.T) {
 	if len(contents) != 0 {
 		t.Errorf("expect no contents, but got %d number of contents", len(contents))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestListSpecifiedFile(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	contents, err := s.List(tempKey)
+	contents, err := s.List(context.Background(), tempKey)
 	if err != nil {
 		t.Errorf("Got error %v, unable list for %s", err, tempKey)
 	}
@@ -429,78 +587,1399 @@ func TestListSpecifiedFile(t *testing.T) {
 	if string(contents[0]) != "test-pod" {
 		t.Errorf("expect content: test-pod, but got content: %s", contents[0])
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestUpdate(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	err = s.Update(tempKey, []byte("test-pod1"))
+	err = s.Update(context.Background(), tempKey, []byte("test-pod1"))
 	if err != nil {
 		t.Errorf("Got error %v, unable update key %s", err, tempKey)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	b, err := ioutil.ReadFile(createdFile)
+	b, err := readFile(createdFile)
 	if err != nil {
 		t.Errorf("Got error %v, unable read regular file %q", err, createdFile)
 	} else if !bytes.Equal(b, []byte("test-pod1")) {
 		t.Errorf("Wanted string: test-pod1 but got %s", string(b))
 	}
-
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
-	}
 }
 
 func TestUpdateEmptyString(t *testing.T) {
-	s, err := NewDiskStorage()
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
 	if err != nil {
 		t.Fatalf("unable to new disk storage, %v", err)
 	}
 
-	err = s.Create(tempKey, []byte("test-pod"))
+	err = s.Create(context.Background(), tempKey, []byte("test-pod"))
 	if err != nil {
 		t.Errorf("Got error %v, wanted successful create %s", err, tempKey)
 	}
 
-	err = s.Update(tempKey, []byte(""))
+	err = s.Update(context.Background(), tempKey, []byte(""))
 	if err != nil {
 		t.Errorf("Got error %v, unable update key %s", err, tempKey)
 	}
 
-	createdFile := filepath.Join(cacheBaseDir, tempKey)
+	createdFile := filepath.Join(baseDir, tempKey)
 	if fi, err := os.Stat(createdFile); err != nil {
 		t.Errorf("Got error %v, wanted file %q to be there", err, createdFile)
 	} else if !fi.Mode().IsRegular() {
 		t.Errorf("Got %q not a regular file", createdFile)
 	}
 
-	b, err := ioutil.ReadFile(createdFile)
+	b, err := readFile(createdFile)
 	if err != nil {
 		t.Errorf("Got error %v, unable read regular file %q", err, createdFile)
 	} else if len(b) == 0 {
 		t.Errorf("Wanted string: empty string but got %s", string(b))
 	}
+}
+
+func TestMigrateLayout(t *testing.T) {
+	baseDir := t.TempDir()
+	_, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	version, err := LayoutVersion(baseDir)
+	if err != nil {
+		t.Errorf("Got error %v, unable to get layout version", err)
+	} else if version != CurrentLayoutVersion {
+		t.Errorf("Got layout version %d, but expect %d", version, CurrentLayoutVersion)
+	}
+
+	if err := writeLayoutVersion(baseDir, CurrentLayoutVersion+1); err != nil {
+		t.Errorf("Got error %v, unable to write layout version", err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Dir(filepath.Join(baseDir, layoutVersionKey)))
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), tmpPrefix) {
+			t.Errorf("Got tmp file %s, but expect it's renamed to the layout file", f.Name())
+		}
+	}
+
+	if _, err := NewDiskStorage(baseDir); err == nil {
+		t.Errorf("Got no error, but expect error for newer layout version")
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	outsideDir, err := ioutil.TempDir("", "yurthub-outside")
+	if err != nil {
+		t.Fatalf("unable to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(outsideDir)
+
+	outsideFile := filepath.Join(outsideDir, "secret")
+	if err := ioutil.WriteFile(outsideFile, []byte("secret"), 0600); err != nil {
+		t.Fatalf("unable to write file %s, %v", outsideFile, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(baseDir, "kubelet/pods"), 0755); err != nil {
+		t.Fatalf("unable to create dir, %v", err)
+	}
+	if err := os.Symlink(outsideDir, filepath.Join(baseDir, "kubelet/pods/linkdir")); err != nil {
+		t.Fatalf("unable to create symlink, %v", err)
+	}
+	if err := os.Symlink(outsideFile, filepath.Join(baseDir, "kubelet/pods/linkfile")); err != nil {
+		t.Fatalf("unable to create symlink, %v", err)
+	}
+
+	keys := []string{
+		"../outside",
+		"kubelet/../../outside",
+		"/etc/outside",
+		"kubelet/pods/linkdir/secret",
+		"kubelet/pods/linkdir/foo",
+		"kubelet/pods/linkfile",
+		"kubelet//pods/default/foo",
+		"kubelet/./pods/default/foo",
+		"./kubelet/pods/default/foo",
+		"kubelet/pods/default/.",
+		"kubelet/pods/_a/foo",
+	}
+
+	for _, key := range keys {
+		if err := s.Create(context.Background(), key, []byte("foo")); err != storage.ErrInvalidKey {
+			t.Errorf("expect create %s is rejected, but got %v", key, err)
+		}
+
+		if err := s.Update(context.Background(), key, []byte("foo")); err != storage.ErrInvalidKey {
+			t.Errorf("expect update %s is rejected, but got %v", key, err)
+		}
+
+		if _, err := s.Get(context.Background(), key); err != storage.ErrInvalidKey {
+			t.Errorf("expect get %s is rejected, but got %v", key, err)
+		}
+
+		if _, err := s.ListKeys(context.Background(), key); err != storage.ErrInvalidKey {
+			t.Errorf("expect list keys %s is rejected, but got %v", key, err)
+		}
+
+		if err := s.DeleteCollection(context.Background(), key, true); err != storage.ErrInvalidKey {
+			t.Errorf("expect delete collection %s is rejected, but got %v", key, err)
+		}
+
+		if err := s.Delete(context.Background(), key); err != storage.ErrInvalidKey {
+			t.Errorf("expect delete %s is rejected, but got %v", key, err)
+		}
+
+		if err := s.(storage.TouchStore).Touch(context.Background(), key); err != storage.ErrInvalidKey {
+			t.Errorf("expect touch %s is rejected, but got %v", key, err)
+		}
+	}
+
+	// the root of cache and collections with a trailing slash are still valid
+	for _, key := range []string{"", "kubelet/pods/"} {
+		if _, err := s.ListKeys(context.Background(), key); err != nil && err != storage.ErrNotFound {
+			t.Errorf("expect list keys %q is allowed, but got %v", key, err)
+		}
+	}
+
+	// the symlink is not followed when it's deleted as a collection
+	if err := s.DeleteCollection(context.Background(), "kubelet/pods/linkdir", true); err == nil {
+		t.Errorf("expect delete collection of symlink is rejected, but got nil")
+	}
+
+	if b, err := ioutil.ReadFile(outsideFile); err != nil || string(b) != "secret" {
+		t.Errorf("expect file outside of cache is not changed, but got %s, %v", string(b), err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outsideDir, "foo")); !os.IsNotExist(err) {
+		t.Errorf("expect no file is created outside of cache, but got %v", err)
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	tempKey := "kubelet/pods/default/foo"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := s.Create(ctx, tempKey, []byte("test-pod")); err != context.Canceled {
+		t.Errorf("expect create with canceled context returns %v, but got %v", context.Canceled, err)
+	}
+
+	if _, err := s.Get(ctx, tempKey); err != context.Canceled {
+		t.Errorf("expect get with canceled context returns %v, but got %v", context.Canceled, err)
+	}
+
+	if _, err := s.ListKeys(ctx, "kubelet/pods"); err != context.Canceled {
+		t.Errorf("expect list keys with canceled context returns %v, but got %v", context.Canceled, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(baseDir, tempKey)); !os.IsNotExist(err) {
+		t.Errorf("expect no file is created with canceled context, but got %v", err)
+	}
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), time.Second)
+	defer timeoutCancel()
+	if err := s.Create(timeoutCtx, tempKey, []byte("test-pod")); err != nil {
+		t.Errorf("expect create with timeout context succeeds, but got %v", err)
+	}
+}
+
+func TestUpdateLeavesNoTempFile(t *testing.T) {
+	for _, fsync := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fsync=%v", fsync), func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorageWithOptions(baseDir, Options{Fsync: fsync})
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+
+			for _, contents := range []string{"test-pod", "test-pod1"} {
+				if err := s.Update(context.Background(), tempKey, []byte(contents)); err != nil {
+					t.Fatalf("Got error %v, unable update key %s", err, tempKey)
+				}
+				b, err := s.Get(context.Background(), tempKey)
+				if err != nil || string(b) != contents {
+					t.Errorf("expect %s, but got %s, %v", contents, string(b), err)
+				}
+			}
+
+			files, err := ioutil.ReadDir(filepath.Join(baseDir, tempDir))
+			if err != nil {
+				t.Fatalf("failed to read dir, %v", err)
+			}
+			if len(files) != 1 || files[0].Name() != "test-pod" {
+				t.Errorf("expect only file test-pod, but got %v", files)
+			}
+		})
+	}
+}
+
+func TestRecoverRemovesTempFiles(t *testing.T) {
+	baseDir := t.TempDir()
+	if _, err := NewDiskStorage(baseDir); err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	// a write is interrupted after the temp file is partially written
+	dir := filepath.Join(baseDir, tempDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create dir, %v", err)
+	}
+	writeFile(filepath.Join(dir, "test-pod"), []byte("test-pod"))
+	ioutil.WriteFile(filepath.Join(dir, tmpPrefix+"test-pod.123"), []byte("test-p"), 0600)
+	ioutil.WriteFile(filepath.Join(dir, tmpPrefix+"new-pod.456"), []byte("new-p"), 0600)
+
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	b, err := s.Get(context.Background(), tempKey)
+	if err != nil || string(b) != "test-pod" {
+		t.Errorf("expect the old complete contents test-pod, but got %s, %v", string(b), err)
+	}
+	keys, err := s.ListKeys(context.Background(), tempDir)
+	if err != nil || !reflect.DeepEqual(keys, []string{tempKey}) {
+		t.Errorf("expect keys %v, but got %v, %v", []string{tempKey}, keys, err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("expect temp files are removed, but got %d files", len(files))
+	}
+}
+
+func TestNewDiskStorageInBaseDirs(t *testing.T) {
+	// trailing separator of base dir is optional
+	baseDir1 := t.TempDir()
+	baseDir2 := t.TempDir() + string(filepath.Separator)
+	s1, err := NewDiskStorage(baseDir1)
+	if err != nil {
+		t.Fatalf("unable to new disk storage in %s, %v", baseDir1, err)
+	}
+	s2, err := NewDiskStorage(baseDir2)
+	if err != nil {
+		t.Fatalf("unable to new disk storage in %s, %v", baseDir2, err)
+	}
+
+	if err := s1.Create(context.Background(), tempKey, []byte("test-pod")); err != nil {
+		t.Fatalf("Got error %v, unable create key %s", err, tempKey)
+	}
+
+	keys, err := s1.ListKeys(context.Background(), tempDir)
+	if err != nil || !reflect.DeepEqual(keys, []string{tempKey}) {
+		t.Errorf("expect keys %v, but got %v, %v", []string{tempKey}, keys, err)
+	}
+
+	if _, err := s2.Get(context.Background(), tempKey); err != storage.ErrNotFound {
+		t.Errorf("expect key %s is not found in another base dir, but got %v", tempKey, err)
+	}
+
+	if _, err := NewDiskStorage(""); err == nil {
+		t.Errorf("expect error for empty base dir, but got nil")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	testcases := map[string]struct {
+		damage func(b []byte) []byte
+	}{
+		"bit flipped": {
+			damage: func(b []byte) []byte {
+				b[len(b)-1] ^= 0x01
+				return b
+			},
+		},
+		"truncated": {
+			damage: func(b []byte) []byte {
+				return b[:len(b)-3]
+			},
+		},
+		"zeroed": {
+			damage: func(b []byte) []byte {
+				return make([]byte, len(b))
+			},
+		},
+		"no header": {
+			damage: func(b []byte) []byte {
+				return b[checksumHeaderSize:]
+			},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorage(baseDir)
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+
+			goodKey := tempDir + "/good-pod"
+			for _, key := range []string{tempKey, goodKey} {
+				if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+					t.Fatalf("Got error %v, unable create key %s", err, key)
+				}
+			}
+
+			path := filepath.Join(baseDir, tempKey)
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("unable to read file %s, %v", path, err)
+			}
+			if err := ioutil.WriteFile(path, tt.damage(b), 0600); err != nil {
+				t.Fatalf("unable to write file %s, %v", path, err)
+			}
+
+			if _, err := s.Get(context.Background(), tempKey); err != storage.ErrCorrupted {
+				t.Errorf("expect get returns %v, but got %v", storage.ErrCorrupted, err)
+			}
+
+			if _, err := s.List(context.Background(), tempKey); err != storage.ErrCorrupted {
+				t.Errorf("expect list of the key returns %v, but got %v", storage.ErrCorrupted, err)
+			}
+
+			// corrupted contents are skipped when listing a collection
+			bb, err := s.List(context.Background(), tempDir)
+			if err != nil || len(bb) != 1 || string(bb[0]) != goodKey {
+				t.Errorf("expect list returns %s only, but got %d contents, %v", goodKey, len(bb), err)
+			}
+
+			// corrupted contents are replaced by update
+			if err := s.Update(context.Background(), tempKey, []byte("test-pod")); err != nil {
+				t.Fatalf("Got error %v, unable update key %s", err, tempKey)
+			}
+			if b, err := s.Get(context.Background(), tempKey); err != nil || string(b) != "test-pod" {
+				t.Errorf("expect test-pod, but got %s, %v", string(b), err)
+			}
+		})
+	}
+}
+
+func TestMigrateLayoutAddsChecksum(t *testing.T) {
+	baseDir := t.TempDir()
+	if err := writeLayoutVersion(baseDir, 1); err != nil {
+		t.Fatalf("unable to write layout version, %v", err)
+	}
+
+	// files written by layout version 1 have no header of checksum
+	dir := filepath.Join(baseDir, tempDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create dir, %v", err)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "test-pod"), []byte("test-pod"), 0600)
+	writeFile(filepath.Join(dir, "migrated-pod"), []byte("migrated-pod"))
+
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	for _, name := range []string{"test-pod", "migrated-pod"} {
+		if b, err := s.Get(context.Background(), tempDir+"/"+name); err != nil || string(b) != name {
+			t.Errorf("expect %s, but got %s, %v", name, string(b), err)
+		}
+	}
+
+	if version, err := LayoutVersion(baseDir); err != nil || version != CurrentLayoutVersion {
+		t.Errorf("expect layout version %d, but got %d, %v", CurrentLayoutVersion, version, err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	large := bytes.Repeat([]byte(`{"kind":"Pod","apiVersion":"v1","metadata":{"name":"test-pod"}}`), 100)
+	testcases := map[string]struct {
+		compression string
+		contents    []byte
+		magic       []byte
+	}{
+		"none": {
+			compression: CompressionNone,
+			contents:    large,
+			magic:       checksumMagic,
+		},
+		"gzip": {
+			compression: CompressionGzip,
+			contents:    large,
+			magic:       gzipMagic,
+		},
+		"snappy": {
+			compression: CompressionSnappy,
+			contents:    large,
+			magic:       snappyMagic,
+		},
+		"small contents are not compressed": {
+			compression: CompressionGzip,
+			contents:    []byte("test-pod"),
+			magic:       checksumMagic,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorageWithOptions(baseDir, Options{Compression: tt.compression})
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+
+			if err := s.Update(context.Background(), tempKey, tt.contents); err != nil {
+				t.Fatalf("Got error %v, unable update key %s", err, tempKey)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(baseDir, tempKey))
+			if err != nil {
+				t.Fatalf("unable to read file of %s, %v", tempKey, err)
+			}
+			if !bytes.Equal(b[:len(tt.magic)], tt.magic) {
+				t.Errorf("expect magic %s, but got %s", string(tt.magic), string(b[:len(tt.magic)]))
+			}
+			if !bytes.Equal(tt.magic, checksumMagic) && len(b) >= len(tt.contents) {
+				t.Errorf("expect contents are compressed, but got %d bytes for %d bytes", len(b), len(tt.contents))
+			}
+
+			// contents can be read after the compression is changed
+			for _, compression := range []string{CompressionNone, CompressionGzip, CompressionSnappy} {
+				s, err := NewDiskStorageWithOptions(baseDir, Options{Compression: compression})
+				if err != nil {
+					t.Fatalf("unable to new disk storage, %v", err)
+				}
+
+				got, err := s.Get(context.Background(), tempKey)
+				if err != nil || !bytes.Equal(got, tt.contents) {
+					t.Errorf("expect get %d bytes with %s compression, but got %d bytes, %v", len(tt.contents), compression, len(got), err)
+				}
+
+				bb, err := s.List(context.Background(), tempDir)
+				if err != nil || len(bb) != 1 || !bytes.Equal(bb[0], tt.contents) {
+					t.Errorf("expect list %d bytes with %s compression, but got %v", len(tt.contents), compression, err)
+				}
+			}
+		})
+	}
+}
+
+func TestCompressionCorrupted(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorageWithOptions(baseDir, Options{Compression: CompressionGzip})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	// the checksum is verified, but contents can't be decompressed
+	contents := []byte("not gzip")
+	path := filepath.Join(baseDir, tempKey)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("unable to make dir, %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(checksumHeaderWithMagic(gzipMagic, contents), contents...), 0600); err != nil {
+		t.Fatalf("unable to write file %s, %v", path, err)
+	}
+
+	if _, err := s.Get(context.Background(), tempKey); err != storage.ErrCorrupted {
+		t.Errorf("expect get returns %v, but got %v", storage.ErrCorrupted, err)
+	}
+}
+
+func TestUnsupportedCompression(t *testing.T) {
+	if _, err := NewDiskStorageWithOptions(t.TempDir(), Options{Compression: "lz4"}); err == nil {
+		t.Errorf("expect unsupported compression is refused, but got nil")
+	}
+}
+
+func TestQuota(t *testing.T) {
+	// every key is 100 bytes on disk with the header of checksum, and the key of
+	// layout version is in cache besides the keys written by tests.
+	contents := bytes.Repeat([]byte("x"), 100-checksumHeaderSize)
+	testcases := map[string]struct {
+		opts      Options
+		writes    []string
+		expectErr error
+		kept      []string
+		evicted   []string
+	}{
+		"least recently updated keys are evicted": {
+			opts:    Options{MaxObjects: 4},
+			writes:  []string{"kubelet/configmaps/default/a", "kubelet/configmaps/default/b", "kubelet/configmaps/default/c", "kubelet/configmaps/default/a", "kubelet/configmaps/default/d"},
+			kept:    []string{"kubelet/configmaps/default/a", "kubelet/configmaps/default/d"},
+			evicted: []string{"kubelet/configmaps/default/b", "kubelet/configmaps/default/c"},
+		},
+		"protected keys are not evicted": {
+			opts:    Options{MaxObjects: 3, ProtectedPrefixes: []string{"kubelet/pods"}},
+			writes:  []string{"kubelet/pods/default/p1", "kubelet/configmaps/default/a", "kubelet/configmaps/default/b"},
+			kept:    []string{"kubelet/pods/default/p1", "kubelet/configmaps/default/b"},
+			evicted: []string{"kubelet/configmaps/default/a"},
+		},
+		"quota is exceeded by protected keys": {
+			opts:      Options{MaxObjects: 2, ProtectedPrefixes: []string{"/kubelet/pods/"}},
+			writes:    []string{"kubelet/pods/default/p1", "kubelet/pods/default/p2"},
+			expectErr: storage.ErrExceedQuota,
+			kept:      []string{"kubelet/pods/default/p1"},
+			evicted:   []string{"kubelet/pods/default/p2"},
+		},
+		"protected objects are not evicted with sharding": {
+			// the key of sharded layout is in cache too
+			opts:    Options{MaxObjects: 4, Sharding: true, ProtectedPrefixes: []string{"kubelet/pods/default/p1"}},
+			writes:  []string{"kubelet/pods/default/p1", "kubelet/configmaps/default/a", "kubelet/configmaps/default/b"},
+			kept:    []string{"kubelet/pods/default/p1", "kubelet/configmaps/default/b"},
+			evicted: []string{"kubelet/configmaps/default/a"},
+		},
+		"keys are evicted for bytes and internal keys are not evicted": {
+			opts:    Options{MaxBytes: 260},
+			writes:  []string{"_internal/test/state", "kubelet/configmaps/default/a", "kubelet/configmaps/default/b"},
+			kept:    []string{"_internal/test/state", "kubelet/configmaps/default/b"},
+			evicted: []string{"kubelet/configmaps/default/a"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorageWithOptions(baseDir, tt.opts)
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+
+			for i, key := range tt.writes {
+				err := s.Update(context.Background(), key, contents)
+				if i == len(tt.writes)-1 {
+					if err != tt.expectErr {
+						t.Errorf("expect error %v for %s, but got %v", tt.expectErr, key, err)
+					}
+				} else if err != nil {
+					t.Fatalf("Got error %v, unable update key %s", err, key)
+				}
+				// keys are updated at different times
+				time.Sleep(10 * time.Millisecond)
+			}
 
-	if err = os.RemoveAll(cacheBaseDir); err != nil {
-		t.Errorf("Got error %v, unable remove path %s", err, cacheBaseDir)
+			for _, key := range tt.kept {
+				if _, err := s.Get(context.Background(), key); err != nil {
+					t.Errorf("expect %s is kept, but got %v", key, err)
+				}
+			}
+			for _, key := range tt.evicted {
+				if _, err := s.Get(context.Background(), key); err != storage.ErrNotFound {
+					t.Errorf("expect %s is evicted, but got %v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestQuotaUsageIsLoaded(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	for _, key := range []string{"kubelet/configmaps/default/a", "kubelet/configmaps/default/b"} {
+		if err := s.Update(context.Background(), key, []byte("test")); err != nil {
+			t.Fatalf("Got error %v, unable update key %s", err, key)
+		}
+	}
+	if err := s.Delete(context.Background(), "kubelet/configmaps/default/a"); err != nil {
+		t.Fatalf("Got error %v, unable delete key", err)
+	}
+	used := s.(*diskStorage).quota
+
+	s, err = NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	loaded := s.(*diskStorage).quota
+	if loaded.usedBytes != used.usedBytes || !reflect.DeepEqual(keysOf(loaded.entries), keysOf(used.entries)) {
+		t.Errorf("expect usage %d bytes of %v is loaded, but got %d bytes of %v", used.usedBytes, keysOf(used.entries), loaded.usedBytes, keysOf(loaded.entries))
+	}
+
+	if s, _ := NewDiskStorage(baseDir); s.(*diskStorage).quota != nil {
+		t.Errorf("expect no quota without limits")
+	}
+}
+
+func keysOf(entries map[string]quotaEntry) map[string]int64 {
+	keys := make(map[string]int64, len(entries))
+	for k, e := range entries {
+		keys[k] = e.size
+	}
+	return keys
+}
+
+func TestTouch(t *testing.T) {
+	baseDir := t.TempDir()
+	store, _ := NewDiskStorage(baseDir)
+	ctx := context.Background()
+	key := "kubelet/configmaps/default/cm1"
+	if err := store.Create(ctx, key, []byte("object")); err != nil {
+		t.Fatalf("failed to create %s, %v", key, err)
+	}
+	past := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(baseDir, key), past, past)
+
+	if err := storage.Touch(ctx, store, key); err != nil {
+		t.Fatalf("failed to touch %s, %v", key, err)
+	}
+	infos, _ := storage.StatKeys(ctx, store, key)
+	if len(infos) != 1 || !infos[0].ModTime.After(past) {
+		t.Errorf("expect key is refreshed by touch, but got %v", infos)
+	}
+	if err := storage.Touch(ctx, store, "kubelet/configmaps/default/cm2"); err != storage.ErrNotFound {
+		t.Errorf("expect ErrNotFound for touching a missing key, but got %v", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	testcases := map[string]struct {
+		rootKey   string
+		contents  map[string][]byte
+		expectErr error
+		expected  []string
+	}{
+		"replace collection of resource": {
+			rootKey: "kubelet/pods",
+			contents: map[string][]byte{
+				"kubelet/pods/default/foo": []byte("foo-v2"),
+				"kubelet/pods/default/baz": []byte("baz"),
+			},
+			expected: []string{"kubelet/nodes/foo", "kubelet/pods/default/baz", "kubelet/pods/default/foo"},
+		},
+		"replace collection that doesn't exist": {
+			rootKey: "kubelet/configmaps",
+			contents: map[string][]byte{
+				"kubelet/configmaps/default/cm": []byte("cm"),
+			},
+			expected: []string{"kubelet/configmaps/default/cm", "kubelet/nodes/foo", "kubelet/pods/default/foo", "kubelet/pods/kube-system/bar"},
+		},
+		"replace with nothing": {
+			rootKey:  "kubelet/pods",
+			expected: []string{"kubelet/nodes/foo"},
+		},
+		"key out of collection": {
+			rootKey: "kubelet/pods",
+			contents: map[string][]byte{
+				"kubelet/nodes/bar": []byte("bar"),
+			},
+			expectErr: storage.ErrInvalidKey,
+		},
+		"key out of storage": {
+			rootKey: "kubelet/pods",
+			contents: map[string][]byte{
+				"kubelet/pods/../../../outside": []byte("outside"),
+			},
+			expectErr: storage.ErrInvalidKey,
+		},
+		"collection of component": {
+			rootKey:   "kubelet",
+			expectErr: storage.ErrProtectedKey,
+		},
+	}
+
+	keys := []string{"kubelet/pods/default/foo", "kubelet/pods/kube-system/bar", "kubelet/nodes/foo"}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			baseDir := t.TempDir()
+			s, err := NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+			if err != nil {
+				t.Fatalf("unable to new disk storage, %v", err)
+			}
+			for _, key := range keys {
+				if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+					t.Fatalf("Got error %v, wanted successful create %s", err, key)
+				}
+			}
+
+			err = s.Replace(context.Background(), tt.rootKey, tt.contents)
+			if tt.expectErr != nil {
+				if err != tt.expectErr {
+					t.Errorf("expect error %v, but got %v", tt.expectErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("Got error %v, unable to replace %s", err, tt.rootKey)
+			}
+
+			got, err := s.ListKeys(context.Background(), "kubelet")
+			if err != nil || !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expect keys %v, but got %v, %v", tt.expected, got, err)
+			}
+			for key, contents := range tt.contents {
+				if b, err := s.Get(context.Background(), key); err != nil || !bytes.Equal(b, contents) {
+					t.Errorf("expect contents %s of %s, but got %s, %v", contents, key, b, err)
+				}
+			}
+
+			// no staging dir is left, and the usage of quota is the same as loaded from disk
+			files, _ := ioutil.ReadDir(filepath.Join(baseDir, "kubelet"))
+			for _, f := range files {
+				if strings.HasPrefix(f.Name(), tmpPrefix) {
+					t.Errorf("expect staging dir is removed, but got %s", f.Name())
+				}
+			}
+			used := s.(*diskStorage).quota
+			loaded, _ := NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+			if q := loaded.(*diskStorage).quota; q.usedBytes != used.usedBytes || !reflect.DeepEqual(keysOf(q.entries), keysOf(used.entries)) {
+				t.Errorf("expect usage %d bytes of %v, but got %d bytes of %v", q.usedBytes, keysOf(q.entries), used.usedBytes, keysOf(used.entries))
+			}
+		})
+	}
+}
+
+func TestReplaceQuota(t *testing.T) {
+	baseDir := t.TempDir()
+	// the version of layout takes one key of quota
+	s, err := NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 4, ProtectedPrefixes: []string{"kubelet/pods"}})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	for _, key := range []string{"kubelet/pods/default/foo", "kubelet/configmaps/default/cm"} {
+		if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+			t.Fatalf("Got error %v, wanted successful create %s", err, key)
+		}
+	}
+
+	// the replaced keys are not counted, and keys out of the collection are evicted
+	err = s.Replace(context.Background(), "kubelet/pods", map[string][]byte{
+		"kubelet/pods/default/a": []byte("a"),
+		"kubelet/pods/default/b": []byte("b"),
+		"kubelet/pods/default/c": []byte("c"),
+	})
+	if err != nil {
+		t.Fatalf("Got error %v, unable to replace", err)
+	}
+	if _, err := s.Get(context.Background(), "kubelet/configmaps/default/cm"); err != storage.ErrNotFound {
+		t.Errorf("expect configmap is evicted, but got %v", err)
+	}
+
+	err = s.Replace(context.Background(), "kubelet/pods", map[string][]byte{
+		"kubelet/pods/default/a": []byte("a"),
+		"kubelet/pods/default/b": []byte("b"),
+		"kubelet/pods/default/c": []byte("c"),
+		"kubelet/pods/default/d": []byte("d"),
+	})
+	if err != storage.ErrExceedQuota {
+		t.Errorf("expect quota is exceeded, but got %v", err)
+	}
+	keys, _ := s.ListKeys(context.Background(), "kubelet/pods")
+	if len(keys) != 3 {
+		t.Errorf("expect keys are not replaced, but got %v", keys)
+	}
+}
+
+func TestRecoverRemovesStagingDirs(t *testing.T) {
+	baseDir := t.TempDir()
+	if _, err := NewDiskStorage(baseDir); err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	// a replace is interrupted after the staging dir is partially written
+	for _, dir := range []string{"kubelet/pods/default", "kubelet/" + tmpPrefix + "pods.123/default"} {
+		if err := os.MkdirAll(filepath.Join(baseDir, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir, %v", err)
+		}
+	}
+	writeFile(filepath.Join(baseDir, "kubelet/pods/default/foo"), []byte("foo"))
+	writeFile(filepath.Join(baseDir, "kubelet", tmpPrefix+"pods.123/default/bar"), []byte("bar"))
+
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	keys, err := s.ListKeys(context.Background(), "kubelet")
+	if err != nil || !reflect.DeepEqual(keys, []string{"kubelet/pods/default/foo"}) {
+		t.Errorf("expect keys %v, but got %v, %v", []string{"kubelet/pods/default/foo"}, keys, err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "kubelet", tmpPrefix+"pods.123")); !os.IsNotExist(err) {
+		t.Errorf("expect staging dir is removed, but got %v", err)
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	s, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+
+	// writers of the same key wait for each other instead of failing with conflicts
+	errCh := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		go func(i int) {
+			errCh <- s.Update(context.Background(), tempKey, []byte(fmt.Sprintf("test-pod-%d", i)))
+		}(i)
+	}
+	for i := 0; i < 50; i++ {
+		if err := <-errCh; err != nil {
+			t.Errorf("expect concurrent writes to succeed, but got %v", err)
+		}
+	}
+	if n := len(s.(*diskStorage).locks.locks); n != 0 {
+		t.Errorf("expect all locks to be dropped, but got %d", n)
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	s, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	if err := s.Create(context.Background(), tempKey, []byte("v1")); err != nil {
+		t.Fatalf("unable to create %s, %v", tempKey, err)
+	}
+
+	ctx, err := storage.LockKey(context.Background(), s, tempKey)
+	if err != nil {
+		t.Fatalf("unable to lock %s, %v", tempKey, err)
+	}
+	if _, err := storage.LockKey(ctx, s, tempKey); err == nil {
+		t.Errorf("expect nested lock of %s to fail", tempKey)
+	}
+
+	// operations of others wait until the transaction is finished
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Update(context.Background(), tempKey, []byte("v3"))
+	}()
+
+	// operations in the transaction don't wait
+	if b, err := s.Get(ctx, tempKey); err != nil || string(b) != "v1" {
+		t.Errorf("expect v1 in the transaction, but got %s, %v", b, err)
+	}
+	if err := s.Update(ctx, tempKey, []byte("v2")); err != nil {
+		t.Errorf("unable to update %s in the transaction, %v", tempKey, err)
+	}
+	if err := storage.Touch(ctx, s, tempKey); err != nil {
+		t.Errorf("unable to touch %s in the transaction, %v", tempKey, err)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Get(timeoutCtx, tempKey); err != context.DeadlineExceeded {
+		t.Errorf("expect get out of the transaction to wait until deadline, but got %v", err)
+	}
+	if err := s.DeleteCollection(context.Background(), tempDir, false); err != storage.ErrStorageAccessConflict {
+		t.Errorf("expect locked key to be skipped by delete collection, but got %v", err)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("expect update to wait for the transaction, but it's finished, %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// unlocking with a context out of the transaction is a no-op
+	storage.UnlockKey(context.Background(), s, tempKey)
+	select {
+	case <-done:
+		t.Fatalf("expect update to wait for the transaction")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	storage.UnlockKey(ctx, s, tempKey)
+	if err := <-done; err != nil {
+		t.Fatalf("unable to update %s after the transaction, %v", tempKey, err)
+	}
+	if b, err := s.Get(context.Background(), tempKey); err != nil || string(b) != "v3" {
+		t.Errorf("expect v3 after the transaction, but got %s, %v", b, err)
+	}
+
+	// the context of a finished transaction doesn't skip the lock of the next one
+	ctx2, err := storage.LockKey(context.Background(), s, tempKey)
+	if err != nil {
+		t.Fatalf("unable to lock %s again, %v", tempKey, err)
+	}
+	defer storage.UnlockKey(ctx2, s, tempKey)
+	timeoutCtx2, cancel2 := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel2()
+	if err := s.Update(timeoutCtx2, tempKey, []byte("v4")); err != context.DeadlineExceeded {
+		t.Errorf("expect update with the context of finished transaction to wait, but got %v", err)
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	goodKey := tempDir + "/good-pod"
+	emptyKey := tempDir + "/empty-pod"
+	for _, key := range []string{tempKey, goodKey, emptyKey} {
+		if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+			t.Fatalf("Got error %v, unable create key %s", err, key)
+		}
+	}
+
+	// a dirty shutdown leaves a truncated file and a zero-byte file
+	path := filepath.Join(baseDir, tempKey)
+	b, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, b[:len(b)-3], 0600)
+	ioutil.WriteFile(filepath.Join(baseDir, emptyKey), []byte{}, 0600)
+
+	s, err = NewDiskStorageWithOptions(baseDir, Options{MaxObjects: 10})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	for _, key := range []string{tempKey, emptyKey} {
+		if _, err := s.Get(context.Background(), key); err != storage.ErrNotFound {
+			t.Errorf("expect %s is quarantined, but got %v", key, err)
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, quarantineKey, key)); err != nil {
+			t.Errorf("expect %s is kept in quarantine, %v", key, err)
+		}
+	}
+	if b, err := s.Get(context.Background(), goodKey); err != nil || string(b) != goodKey {
+		t.Errorf("expect %s, but got %s, %v", goodKey, string(b), err)
+	}
+
+	// the quarantined files are neither keys nor counted in the quota
+	keys, err := s.ListKeys(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unable to list keys, %v", err)
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, quarantineKey) {
+			t.Errorf("expect quarantined files are not keys, but got %s", key)
+		}
+	}
+	if entries := keysOf(s.(*diskStorage).quota.entries); len(entries) != 2 {
+		t.Errorf("expect 2 keys in quota, but got %v", entries)
+	}
+
+	// the quarantined files are not scanned again
+	if quarantined := s.(*diskStorage).quarantine(); len(quarantined) != 0 {
+		t.Errorf("expect no key is quarantined again, but got %v", quarantined)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	keys := []string{"kubelet/pods/default/pod1", "kubelet/pods/default/pod2", "kube-proxy/services/default/svc1"}
+	usages := make(map[string]int64)
+	for k, opts := range map[string]Options{"usage tracked by quota": {MaxObjects: 10}, "usage walked": {}} {
+		s, err := NewDiskStorageWithOptions(t.TempDir(), opts)
+		if err != nil {
+			t.Fatalf("unable to new disk storage, %v", err)
+		}
+		for _, key := range keys {
+			if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+				t.Fatalf("Got error %v, unable create key %s", err, key)
+			}
+		}
+		if err := s.Delete(context.Background(), keys[1]); err != nil {
+			t.Fatalf("Got error %v, unable delete key %s", err, keys[1])
+		}
+
+		if usages[k], err = storage.Usage(context.Background(), s); err != nil {
+			t.Fatalf("failed to get usage when %s, %v", k, err)
+		}
+		infos, _ := s.(storage.StatStore).StatKeys(context.Background(), "")
+		var contents int64
+		for _, info := range infos {
+			contents += info.Size
+		}
+		if usages[k] <= contents {
+			t.Errorf("expect usage on disk is larger than %d bytes of contents when %s, but got %d", contents, k, usages[k])
+		}
+	}
+
+	if usages["usage tracked by quota"] != usages["usage walked"] {
+		t.Errorf("expect the same usage, but got %v", usages)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	contents := []byte(strings.Repeat("kubelet/pods/default/pod1", 100))
+	for _, compression := range []string{CompressionNone, CompressionGzip} {
+		baseDir := t.TempDir()
+		s, err := NewDiskStorageWithOptions(baseDir, Options{Compression: compression})
+		if err != nil {
+			t.Fatalf("unable to new disk storage, %v", err)
+		}
+		if err := s.Create(context.Background(), "kubelet/pods/default/pod1", contents); err != nil {
+			t.Fatalf("Got error %v, unable create key", err)
+		}
+		if err := s.Replace(context.Background(), "kubelet/nodes", map[string][]byte{"kubelet/nodes/node1": contents}); err != nil {
+			t.Fatalf("Got error %v, unable replace keys", err)
+		}
+
+		stats, ok := storage.GetWriteStats(s)
+		if !ok {
+			t.Fatalf("expect write stats of disk storage")
+		}
+		if stats.Requested != int64(2*len(contents)) {
+			t.Errorf("expect %d bytes requested, but got %d", 2*len(contents), stats.Requested)
+		}
+		// the layout version is written when the storage is created, it's not counted
+		usage, _ := storage.Usage(context.Background(), s)
+		info, _ := os.Stat(filepath.Join(baseDir, layoutVersionKey))
+		if expected := usage - info.Size(); stats.Written != expected {
+			t.Errorf("expect %d bytes written, but got %d", expected, stats.Written)
+		}
+		if compression == CompressionNone && stats.Written <= stats.Requested {
+			t.Errorf("expect checksums are written, but got %d bytes written for %d bytes", stats.Written, stats.Requested)
+		} else if compression == CompressionGzip && stats.Written >= stats.Requested {
+			t.Errorf("expect compressed contents are written, but got %d bytes written for %d bytes", stats.Written, stats.Requested)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	s, err := NewDiskStorageWithOptions(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	ctx := context.Background()
+
+	contents := make(map[string][]byte)
+	keys := make([]string, 0)
+	for i := 0; i < 3*batchWorkers; i++ {
+		key := fmt.Sprintf("kubelet/pods/default/pod%02d", i)
+		contents[key] = []byte(key)
+		keys = append(keys, key)
+	}
+	if err := storage.SetMulti(ctx, s, contents); err != nil {
+		t.Fatalf("failed to set multi, %v", err)
+	}
+	bb, err := s.List(ctx, "kubelet/pods")
+	if err != nil {
+		t.Fatalf("failed to list, %v", err)
+	}
+	for i := range keys {
+		if i >= len(bb) || string(bb[i]) != keys[i] {
+			t.Fatalf("expect %d keys are listed in order, but got %q", len(keys), bb)
+		}
+	}
+
+	// the corrupted and missing keys are skipped
+	ioutil.WriteFile(filepath.Join(s.(*diskStorage).baseDir, keys[0]), []byte{}, 0600)
+	result, err := storage.GetMulti(ctx, s, append(keys, "kubelet/pods/default/missing"))
+	if err != nil {
+		t.Fatalf("failed to get multi, %v", err)
+	}
+	if len(result) != len(keys)-1 {
+		t.Errorf("expect %d keys, but got %d", len(keys)-1, len(result))
+	}
+	if _, ok := result[keys[0]]; ok {
+		t.Errorf("expect corrupted %s is skipped", keys[0])
+	}
+
+	if _, err := storage.GetMulti(ctx, s, []string{"../invalid"}); err != storage.ErrInvalidKey {
+		t.Errorf("expect %v for invalid key, but got %v", storage.ErrInvalidKey, err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := storage.SetMulti(cancelled, s, contents); err != context.Canceled {
+		t.Errorf("expect %v, but got %v", context.Canceled, err)
+	}
+}
+
+// waitEvent waits until want is received from events, the events before want are skipped
+// as they may be merged or repeated, but they must be under prefix.
+func waitEvent(t *testing.T, events <-chan storage.Event, prefix string, want storage.Event) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("expect event %v, but events are closed", want)
+			}
+			if event.Key != prefix && !strings.HasPrefix(event.Key, prefix+"/") {
+				t.Errorf("expect events under %s, but got %v", prefix, event)
+			}
+			if event == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("expect event %v, but timed out", want)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, err := NewDiskStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, key := range []string{"kubelet/pods/default/p0", "kubelet/configmaps/default/c0"} {
+		if err := s.Create(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+	if _, err := storage.Watch(ctx, s, "../pods"); err != storage.ErrInvalidKey {
+		t.Errorf("expect %v for invalid prefix, but got %v", storage.ErrInvalidKey, err)
+	}
+	events, err := storage.Watch(ctx, s, "kubelet/pods")
+	if err != nil {
+		t.Fatalf("failed to watch, %v", err)
+	}
+
+	steps := []struct {
+		name string
+		op   func() error
+		want storage.Event
+	}{
+		{
+			name: "update",
+			op:   func() error { return s.Update(ctx, "kubelet/pods/default/p0", []byte("p0-v2")) },
+			want: storage.Event{Type: storage.EventWrite, Key: "kubelet/pods/default/p0"},
+		},
+		{
+			name: "create",
+			op:   func() error { return s.Create(ctx, "kubelet/pods/default/p1", []byte("p1")) },
+			want: storage.Event{Type: storage.EventWrite, Key: "kubelet/pods/default/p1"},
+		},
+		{
+			name: "delete",
+			op:   func() error { return s.Delete(ctx, "kubelet/pods/default/p0") },
+			want: storage.Event{Type: storage.EventDelete, Key: "kubelet/pods/default/p0"},
+		},
+		{
+			name: "key out of prefix is not notified",
+			op: func() error {
+				if err := s.Update(ctx, "kubelet/configmaps/default/c0", []byte("c0-v2")); err != nil {
+					return err
+				}
+				return s.Create(ctx, "kubelet/pods/default/p2", []byte("p2"))
+			},
+			want: storage.Event{Type: storage.EventWrite, Key: "kubelet/pods/default/p2"},
+		},
+		{
+			name: "replace",
+			op: func() error {
+				return s.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/pods/default/p3": []byte("p3")})
+			},
+			want: storage.Event{Type: storage.EventResync, Key: "kubelet/pods"},
+		},
+		{
+			name: "write after replace",
+			op:   func() error { return s.Update(ctx, "kubelet/pods/default/p3", []byte("p3-v2")) },
+			want: storage.Event{Type: storage.EventWrite, Key: "kubelet/pods/default/p3"},
+		},
+		{
+			name: "new dir",
+			op:   func() error { return s.Create(ctx, "kubelet/pods/kube-system/p4", []byte("p4")) },
+			want: storage.Event{Type: storage.EventResync, Key: "kubelet/pods/kube-system"},
+		},
+		{
+			name: "write in new dir",
+			op:   func() error { return s.Update(ctx, "kubelet/pods/kube-system/p4", []byte("p4-v2")) },
+			want: storage.Event{Type: storage.EventWrite, Key: "kubelet/pods/kube-system/p4"},
+		},
+	}
+	for _, step := range steps {
+		if err := step.op(); err != nil {
+			t.Fatalf("%s: failed to operate, %v", step.name, err)
+		}
+		waitEvent(t, events, "kubelet/pods", step.want)
+	}
+
+	// the dirs of prefix are watched after they are created
+	leases, err := storage.Watch(ctx, s, "kubelet/leases")
+	if err != nil {
+		t.Fatalf("failed to watch, %v", err)
+	}
+	if err := s.Create(ctx, "kubelet/leases/default/l0", []byte("l0")); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+	waitEvent(t, leases, "kubelet/leases", storage.Event{Type: storage.EventResync, Key: "kubelet/leases"})
+
+	cancel()
+	timeout := time.After(10 * time.Second)
+	for closed := false; !closed; {
+		select {
+		case _, ok := <-events:
+			closed = !ok
+		case <-timeout:
+			t.Fatalf("expect events are closed when ctx is done")
+		}
+	}
+}
+
+func TestSharding(t *testing.T) {
+	baseDir := t.TempDir()
+	ctx := context.Background()
+	s, err := NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	keys := []string{"kubelet/nodes/node1", "kubelet/pods/default/pod1", "kubelet/pods/kube-system/pod2"}
+	for _, key := range append(keys, "_internal/state/foo") {
+		if err := s.Create(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+
+	// the existing cache is migrated into shard dirs
+	s, err = NewDiskStorageWithOptions(baseDir, Options{Sharding: true, MaxObjects: 10})
+	if err != nil {
+		t.Fatalf("unable to new disk storage with sharding, %v", err)
+	}
+	if sharded, err := ShardedLayout(baseDir); err != nil || !sharded {
+		t.Errorf("expect sharded layout is recorded, but got %v, %v", sharded, err)
+	}
+	for _, key := range keys {
+		if _, err := os.Stat(filepath.Join(baseDir, key)); !os.IsNotExist(err) {
+			t.Errorf("expect %s is moved into shard dirs, but got %v", key, err)
+		}
+		if _, err := readFile(shardPath(filepath.Join(baseDir, key))); err != nil {
+			t.Errorf("expect %s in shard dirs, but got %v", key, err)
+		}
+		if b, err := s.Get(ctx, key); err != nil || string(b) != key {
+			t.Errorf("expect contents of %s, but got %q, %v", key, b, err)
+		}
+	}
+	if _, err := readFile(filepath.Join(baseDir, "_internal/state/foo")); err != nil {
+		t.Errorf("expect internal keys are not sharded, but got %v", err)
+	}
+
+	// keys are mapped back from the paths in shard dirs
+	if got, err := s.ListKeys(ctx, "kubelet"); err != nil || !reflect.DeepEqual(got, keys) {
+		t.Errorf("expect keys %v, but got %v, %v", keys, got, err)
+	}
+	if got, err := s.ListKeys(ctx, keys[0]); err != nil || !reflect.DeepEqual(got, keys[:1]) {
+		t.Errorf("expect key %s is listed, but got %v, %v", keys[0], got, err)
+	}
+	if bb, err := s.List(ctx, "kubelet/pods"); err != nil || len(bb) != 2 || string(bb[0]) != keys[1] {
+		t.Errorf("expect contents of pods, but got %q, %v", bb, err)
+	}
+	if infos, err := s.(*diskStorage).StatKeys(ctx, "kubelet/pods/default"); err != nil || len(infos) != 1 || infos[0].Key != keys[1] {
+		t.Errorf("expect stats of %s, but got %v, %v", keys[1], infos, err)
+	}
+	if _, err := s.Get(ctx, "kubelet/pods/default"); err != storage.ErrKeyIsDir {
+		t.Errorf("expect %v for collection, but got %v", storage.ErrKeyIsDir, err)
+	}
+	if err := s.(*diskStorage).Touch(ctx, keys[0]); err != nil {
+		t.Errorf("failed to touch %s, %v", keys[0], err)
+	}
+
+	// the writes of keys go to shard dirs
+	if err := s.Update(ctx, "kubelet/pods/default/pod3", []byte("pod3")); err != nil {
+		t.Fatalf("failed to update pod3, %v", err)
+	}
+	if _, err := readFile(shardPath(filepath.Join(baseDir, "kubelet/pods/default/pod3"))); err != nil {
+		t.Errorf("expect pod3 in shard dirs, but got %v", err)
+	}
+	if err := s.Replace(ctx, "kubelet/pods/default", map[string][]byte{"kubelet/pods/default/pod4": []byte("pod4")}); err != nil {
+		t.Fatalf("failed to replace pods, %v", err)
+	}
+	if got, err := s.ListKeys(ctx, "kubelet/pods/default"); err != nil || !reflect.DeepEqual(got, []string{"kubelet/pods/default/pod4"}) {
+		t.Errorf("expect replaced pods, but got %v, %v", got, err)
+	}
+	if err := s.Delete(ctx, keys[0]); err != nil {
+		t.Errorf("failed to delete %s, %v", keys[0], err)
+	}
+	if _, err := s.Get(ctx, keys[0]); err != storage.ErrNotFound {
+		t.Errorf("expect %s is deleted, but got %v", keys[0], err)
+	}
+	if err := s.DeleteCollection(ctx, "kubelet/pods/kube-system", true); err != nil {
+		t.Errorf("failed to delete collection, %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "kubelet/pods/kube-system")); !os.IsNotExist(err) {
+		t.Errorf("expect shard dirs of collection are removed, but got %v", err)
+	}
+
+	// the sharded layout is read as it is in read-only mode
+	ro, err := NewDiskStorageWithOptions(baseDir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("unable to new read-only disk storage, %v", err)
+	}
+	if b, err := ro.Get(ctx, "kubelet/pods/default/pod4"); err != nil || string(b) != "pod4" {
+		t.Errorf("expect pod4 in read-only mode, but got %q, %v", b, err)
+	}
+
+	// the cache is migrated back when sharding is disabled
+	s, err = NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	if sharded, err := ShardedLayout(baseDir); err != nil || sharded {
+		t.Errorf("expect flat layout, but got %v, %v", sharded, err)
+	}
+	if _, err := readFile(filepath.Join(baseDir, "kubelet/pods/default/pod4")); err != nil {
+		t.Errorf("expect pod4 is moved back, but got %v", err)
+	}
+	if got, err := ioutil.ReadDir(filepath.Join(baseDir, "kubelet/pods/default")); err != nil || len(got) != 1 {
+		t.Errorf("expect shard dirs are removed, but got %v, %v", got, err)
+	}
+	if got, err := s.ListKeys(ctx, "kubelet"); err != nil || !reflect.DeepEqual(got, []string{"kubelet/pods/default/pod4"}) {
+		t.Errorf("expect pod4 is listed, but got %v, %v", got, err)
+	}
+}
+
+func TestShardPath(t *testing.T) {
+	path := shardPath("/cache/kubelet/configmaps/default/cm1")
+	dir, name := filepath.Split(path)
+	elems := strings.Split(strings.TrimSuffix(dir, "/"), "/")
+	if name != "cm1" || len(elems) != 7 || !isShardDir(elems[5]) || !isShardDir(elems[6]) {
+		t.Errorf("expect cm1 in two levels of shard dirs, but got %s", path)
+	}
+	if path != shardPath("/cache/kubelet/configmaps/default/cm1") {
+		t.Errorf("expect the shard dirs of a key are stable")
+	}
+	if key := unshardKey("kubelet/configmaps/default/_a/_0/cm1"); key != "kubelet/configmaps/default/cm1" {
+		t.Errorf("expect shard dirs are removed from key, but got %s", key)
+	}
+
+	// the names are spread over the shard dirs
+	dirs := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		dirs[filepath.Dir(shardPath(fmt.Sprintf("/cache/kubelet/configmaps/default/cm%d", i)))] = true
+	}
+	if len(dirs) != 256 {
+		t.Errorf("expect 5000 names are spread over 256 shard dirs, but got %d", len(dirs))
+	}
+}
+
+func TestWatchSharding(t *testing.T) {
+	s, err := NewDiskStorageWithOptions(t.TempDir(), Options{Sharding: true})
+	if err != nil {
+		t.Fatalf("unable to new disk storage, %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the write of key that creates its shard dirs is notified as a resync of its collection
+	key := "kubelet/pods/default/pod1"
+	if err := s.Create(ctx, key, []byte("pod1")); err != nil {
+		t.Fatalf("failed to create %s, %v", key, err)
+	}
+	events, err := storage.Watch(ctx, s, "kubelet/pods")
+	if err != nil {
+		t.Fatalf("failed to watch, %v", err)
+	}
+
+	// the keys of events are mapped back from the paths in shard dirs
+	if err := s.Update(ctx, key, []byte("pod1-v2")); err != nil {
+		t.Fatalf("failed to update %s, %v", key, err)
+	}
+	waitEvent(t, events, "kubelet/pods", storage.Event{Type: storage.EventWrite, Key: key})
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("failed to delete %s, %v", key, err)
 	}
+	waitEvent(t, events, "kubelet/pods", storage.Event{Type: storage.EventDelete, Key: key})
 }
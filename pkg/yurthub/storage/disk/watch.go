@@ -0,0 +1,191 @@
+package disk
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"github.com/fsnotify/fsnotify"
+	"k8s.io/klog"
+)
+
+// watchBufferSize is the number of events buffered for a slow consumer, the events of
+// fsnotify are dropped when its queue overflows, then a resync of prefix is sent.
+const watchBufferSize = 100
+
+// Watch returns the changes of keys under prefix by fsnotify. the dirs of prefix and its
+// ancestors are watched, so the keys written before the dirs of prefix are created are
+// notified too. the channel is closed when ctx is done.
+func (ds *diskStorage) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	if _, err := ds.keyPath(prefix); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	w := &diskWatcher{
+		baseDir:       ds.baseDir,
+		quarantineDir: filepath.Join(ds.baseDir, quarantineKey),
+		prefix:        strings.TrimSuffix(filepath.ToSlash(prefix), "/"),
+		watcher:       watcher,
+		dirs:          make(map[string]bool),
+		events:        make(chan storage.Event, watchBufferSize),
+	}
+	if err := w.addDirs(filepath.Clean(ds.baseDir)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go w.run(ctx)
+	return w.events, nil
+}
+
+// diskWatcher translates the events of files in cache into the events of keys under prefix.
+// as writes go through tmp files and collections are replaced by exchanging dirs, the events
+// of fsnotify are taken as hints, the state of the file is checked to decide the event of key.
+type diskWatcher struct {
+	baseDir       string
+	quarantineDir string
+	prefix        string
+	watcher       *fsnotify.Watcher
+	// dirs are the paths of dirs that are watched
+	dirs   map[string]bool
+	events chan storage.Event
+}
+
+func (w *diskWatcher) run(ctx context.Context) {
+	defer close(w.events)
+	defer w.watcher.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			w.handle(ctx, event)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			klog.Warningf("failed to watch disk cache under %q, %v", w.prefix, err)
+			w.send(ctx, storage.Event{Type: storage.EventResync, Key: w.prefix})
+		}
+	}
+}
+
+func (w *diskWatcher) handle(ctx context.Context, event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod || strings.HasPrefix(filepath.Base(event.Name), tmpPrefix) {
+		return
+	} else if event.Name == w.quarantineDir || strings.HasPrefix(event.Name, w.quarantineDir+string(filepath.Separator)) {
+		return
+	}
+
+	key := w.key(event.Name)
+	info, err := os.Lstat(event.Name)
+	switch {
+	case err == nil && info.IsDir():
+		// a dir is created or replaced, the keys in it may be written before it's watched
+		if !w.inScope(key) {
+			return
+		}
+		if err := w.addDirs(event.Name); err != nil {
+			klog.Warningf("failed to watch dir %s of disk cache, %v", event.Name, err)
+		}
+		w.send(ctx, w.resync(key))
+	case err == nil && info.Mode().IsRegular():
+		if event.Op&(fsnotify.Create|fsnotify.Write) != 0 && w.underPrefix(key) {
+			w.send(ctx, storage.Event{Type: storage.EventWrite, Key: key})
+		}
+	case os.IsNotExist(err):
+		if w.dirs[event.Name] {
+			w.removeDirs(event.Name)
+			w.send(ctx, w.resync(key))
+		} else if w.underPrefix(key) {
+			w.send(ctx, storage.Event{Type: storage.EventDelete, Key: key})
+		}
+	case err != nil:
+		klog.Warningf("failed to stat %s for watch of disk cache, %v", event.Name, err)
+	}
+}
+
+// addDirs watches root and the dirs under it that are in scope of prefix
+func (w *diskWatcher) addDirs(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the dir may be removed while it's walked, its removal is notified by its parent
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		} else if !info.IsDir() {
+			return nil
+		} else if path == w.quarantineDir || (path != root && strings.HasPrefix(info.Name(), tmpPrefix)) {
+			return filepath.SkipDir
+		} else if !w.inScope(w.key(path)) {
+			return filepath.SkipDir
+		}
+
+		if err := w.watcher.Add(path); err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		w.dirs[path] = true
+		return nil
+	})
+}
+
+// removeDirs stops watching root and the dirs under it
+func (w *diskWatcher) removeDirs(root string) {
+	for dir := range w.dirs {
+		if dir == root || strings.HasPrefix(dir, root+string(filepath.Separator)) {
+			// the watch is removed by the kernel already if the dir is deleted
+			w.watcher.Remove(dir)
+			delete(w.dirs, dir)
+		}
+	}
+}
+
+func (w *diskWatcher) send(ctx context.Context, event storage.Event) {
+	select {
+	case w.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// key returns the key of path in cache, the key of base dir is empty, and the key
+// of a shard dir is the key of its collection.
+func (w *diskWatcher) key(path string) string {
+	key := strings.TrimPrefix(path+string(filepath.Separator), w.baseDir)
+	return unshardKey(strings.TrimSuffix(filepath.ToSlash(key), "/"))
+}
+
+// underPrefix returns true if key is prefix or under prefix
+func (w *diskWatcher) underPrefix(key string) bool {
+	return w.prefix == "" || key == w.prefix || strings.HasPrefix(key, w.prefix+"/")
+}
+
+// inScope returns true if the dir of key is watched, that is, it's under prefix or
+// it's an ancestor of prefix.
+func (w *diskWatcher) inScope(key string) bool {
+	return key == "" || w.underPrefix(key) || strings.HasPrefix(w.prefix, key+"/")
+}
+
+// resync returns the resync event of the dir of key, the dir that is an ancestor of
+// prefix is resynced as prefix.
+func (w *diskWatcher) resync(key string) storage.Event {
+	if !w.underPrefix(key) {
+		key = w.prefix
+	}
+	return storage.Event{Type: storage.EventResync, Key: key}
+}
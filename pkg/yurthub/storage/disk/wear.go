@@ -0,0 +1,51 @@
+package disk
+
+import (
+	"sync/atomic"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	requestedBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "yurthub_disk_cache",
+			Name:      "requested_bytes_total",
+			Help:      "Counter of bytes of contents that are requested to be written into disk cache.",
+		},
+	)
+	writtenBytes = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "yurthub_disk_cache",
+			Name:      "written_bytes_total",
+			Help:      "Counter of bytes that are written into files of disk cache, including checksums and compression.",
+		},
+	)
+)
+
+// writeMeter counts the bytes written by disk storage, so the wear of flash(like eMMC on
+// fanless edge boxes) can be predicted. it's allocated separately, so its counters are
+// 64-bit aligned for atomic operations on 32-bit platforms.
+type writeMeter struct {
+	requested int64
+	written   int64
+}
+
+// add counts the bytes of contents requested and the bytes written into files for them
+func (m *writeMeter) add(requested, written int) {
+	atomic.AddInt64(&m.requested, int64(requested))
+	atomic.AddInt64(&m.written, int64(written))
+	requestedBytes.Add(float64(requested))
+	writtenBytes.Add(float64(written))
+}
+
+// WriteStats returns the bytes written since the disk storage is created, the writes when
+// the storage is created(like migrating the layout) are not counted.
+func (ds *diskStorage) WriteStats() (storage.WriteStats, bool) {
+	return storage.WriteStats{
+		Requested: atomic.LoadInt64(&ds.meter.requested),
+		Written:   atomic.LoadInt64(&ds.meter.written),
+	}, true
+}
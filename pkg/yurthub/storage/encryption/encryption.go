@@ -0,0 +1,400 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apiserver/pkg/storage/value"
+	aestransformer "k8s.io/apiserver/pkg/storage/value/encrypt/aes"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/envelope"
+	"k8s.io/klog"
+)
+
+const (
+	// aesGCMPrefix and kmsPrefix are the prefixes of encrypted contents, they are
+	// the same as the ones of aesgcm and kms providers of kube-apiserver.
+	aesGCMPrefix = "k8s:enc:aesgcm:v1:yurthub:"
+	kmsPrefix    = "k8s:enc:kms:v1:yurthub:"
+
+	// kmsTimeout is the timeout of calls to kms plugin
+	kmsTimeout = 3 * time.Second
+	// kmsCacheSize is the number of data encryption keys decrypted by kms plugin
+	// that are cached, so reads don't call the kms plugin for every object.
+	kmsCacheSize = 1000
+	// migrateTimeout is the timeout of encrypting the contents cached in plaintext
+	migrateTimeout = 5 * time.Minute
+)
+
+// Options are the options of encryption at rest
+type Options struct {
+	// KeyFile is the file of base64 encoded AES key(16, 24 or 32 bytes)
+	KeyFile string
+	// KMSEndpoint is the unix socket of kms plugin(like unix:///var/run/kms.sock),
+	// contents are encrypted by the data encryption keys that are encrypted by kms.
+	KMSEndpoint string
+	// Resources are the resources(like secrets) that are encrypted
+	Resources []string
+}
+
+// Enabled checks encryption at rest is enabled or not
+func (o Options) Enabled() bool {
+	return len(o.KeyFile) != 0 || len(o.KMSEndpoint) != 0
+}
+
+// store encrypts the contents of the configured resources by AES-GCM before they are
+// written to the backend storage, and decrypts them when they are read. the key of
+// contents is the authenticated data of encryption, so the encrypted contents can't be
+// moved to another key. contents of other resources are passed through as they are.
+type store struct {
+	backend     storage.Store
+	transformer value.Transformer
+	resources   map[string]bool
+}
+
+// NewStore creates a storage that encrypts the contents of resources in opts, the contents
+// of resources that are cached in plaintext before are encrypted when the store is created.
+func NewStore(backend storage.Store, opts Options) (storage.Store, error) {
+	transformer, err := newTransformer(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(backend, transformer, opts.Resources)
+}
+
+func newStore(backend storage.Store, transformer value.Transformer, resources []string) (storage.Store, error) {
+	s := &store{
+		backend:     backend,
+		transformer: transformer,
+		resources:   make(map[string]bool),
+	}
+	for _, resource := range resources {
+		s.resources[resource] = true
+	}
+
+	if storage.IsReadOnly(backend) {
+		klog.Warningf("storage is read-only, objects cached in plaintext are not encrypted")
+		return s, nil
+	}
+	if err := s.encryptPlaintext(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newTransformer creates the transformer of AES-GCM with the key in key file, or the
+// envelope transformer with kms plugin.
+func newTransformer(opts Options) (value.Transformer, error) {
+	switch {
+	case len(opts.KeyFile) != 0 && len(opts.KMSEndpoint) != 0:
+		return nil, fmt.Errorf("only one of encryption key file and kms endpoint can be set")
+	case len(opts.KeyFile) != 0:
+		key, err := loadKey(opts.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create aes cipher with key in %s, %v", opts.KeyFile, err)
+		}
+		return value.NewPrefixTransformers(fmt.Errorf("no aesgcm prefix is found"),
+			value.PrefixTransformer{Prefix: []byte(aesGCMPrefix), Transformer: aestransformer.NewGCMTransformer(block)}), nil
+	case len(opts.KMSEndpoint) != 0:
+		service, err := envelope.NewGRPCService(opts.KMSEndpoint, kmsTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect kms plugin %s, %v", opts.KMSEndpoint, err)
+		}
+		transformer, err := envelope.NewEnvelopeTransformer(service, kmsCacheSize, aestransformer.NewGCMTransformer)
+		if err != nil {
+			return nil, err
+		}
+		return value.NewPrefixTransformers(fmt.Errorf("no kms prefix is found"),
+			value.PrefixTransformer{Prefix: []byte(kmsPrefix), Transformer: transformer}), nil
+	default:
+		return nil, fmt.Errorf("neither encryption key file nor kms endpoint is set")
+	}
+}
+
+// loadKey loads the base64 encoded AES key from file
+func loadKey(file string) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file %s, %v", file, err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key in %s, %v", file, err)
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("encryption key in %s is %d bytes, it must be 16, 24 or 32 bytes", file, len(key))
+	}
+}
+
+// encrypted checks the contents of key are encrypted or not
+func (s *store) encrypted(key string) bool {
+	_, resource, _, _ := util.SplitKey(strings.Trim(key, "/"))
+	return s.resources[resource]
+}
+
+func isEncrypted(contents []byte) bool {
+	return bytes.HasPrefix(contents, []byte(aesGCMPrefix)) || bytes.HasPrefix(contents, []byte(kmsPrefix))
+}
+
+func (s *store) encrypt(key string, contents []byte) ([]byte, error) {
+	if !s.encrypted(key) {
+		return contents, nil
+	}
+
+	b, err := s.transformer.TransformToStorage(contents, value.DefaultContext(strings.Trim(key, "/")))
+	if err != nil {
+		klog.Errorf("failed to encrypt contents of %s, %v", key, err)
+		return nil, err
+	}
+	return b, nil
+}
+
+// decrypt decrypts the contents of key, storage.ErrCorrupted is returned if the contents
+// can't be decrypted, like they are damaged or encrypted by another key. contents without
+// the prefix of encryption are cached in plaintext(like before the encryption is enabled,
+// or they are left as they are in a read-only storage), they are returned as they are, and
+// encrypted when they are written next time.
+func (s *store) decrypt(key string, b []byte) ([]byte, error) {
+	if !s.encrypted(key) || !isEncrypted(b) {
+		return b, nil
+	}
+
+	contents, _, err := s.transformer.TransformFromStorage(b, value.DefaultContext(strings.Trim(key, "/")))
+	if err != nil {
+		klog.Errorf("failed to decrypt contents of %s, %v", key, err)
+		return nil, storage.ErrCorrupted
+	}
+	return contents, nil
+}
+
+// encryptPlaintext encrypts the contents of resources that are cached in plaintext,
+// like before the encryption is enabled or a new resource is added.
+func (s *store) encryptPlaintext() error {
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	keys, err := s.backend.ListKeys(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list keys for encryption, %v", err)
+	}
+
+	count := 0
+	for _, key := range keys {
+		if !s.encrypted(key) {
+			continue
+		}
+
+		b, err := s.backend.Get(ctx, key)
+		if err != nil {
+			klog.Warningf("failed to get %s for encryption, %v", key, err)
+			continue
+		} else if isEncrypted(b) {
+			continue
+		}
+
+		if err := s.Update(ctx, key, b); err != nil {
+			return fmt.Errorf("failed to encrypt %s, %v", key, err)
+		}
+		count++
+	}
+
+	if count != 0 {
+		klog.Infof("%d objects cached in plaintext are encrypted", count)
+	}
+	return nil
+}
+
+func (s *store) Create(ctx context.Context, key string, contents []byte) error {
+	b, err := s.encrypt(key, contents)
+	if err != nil {
+		return err
+	}
+	return s.backend.Create(ctx, key, b)
+}
+
+func (s *store) Update(ctx context.Context, key string, contents []byte) error {
+	b, err := s.encrypt(key, contents)
+	if err != nil {
+		return err
+	}
+	return s.backend.Update(ctx, key, b)
+}
+
+func (s *store) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *store) DeleteCollection(ctx context.Context, key string, force bool) error {
+	return s.backend.DeleteCollection(ctx, key, force)
+}
+
+// Replace encrypts the contents of encrypted resources and replaces the keys under rootKey in backend
+func (s *store) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	encrypted := make(map[string][]byte, len(contents))
+	for key, b := range contents {
+		eb, err := s.encrypt(key, b)
+		if err != nil {
+			return err
+		}
+		encrypted[key] = eb
+	}
+	return s.backend.Replace(ctx, rootKey, encrypted)
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return s.decrypt(key, b)
+}
+
+func (s *store) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return s.backend.ListKeys(ctx, key)
+}
+
+// ListKeysInOrder returns the keys under key in the specified order of backend
+func (s *store) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	return storage.ListKeysInOrder(ctx, s.backend, key, order)
+}
+
+// ReadOnly returns true if backend is read-only
+func (s *store) ReadOnly() bool {
+	return storage.IsReadOnly(s.backend)
+}
+
+// OnEvict adds f that is called with the keys evicted by backend
+func (s *store) OnEvict(f func(key string)) {
+	storage.OnEvict(s.backend, f)
+}
+
+// Touch marks key as refreshed in backend
+func (s *store) Touch(ctx context.Context, key string) error {
+	return storage.Touch(ctx, s.backend, key)
+}
+
+// LockKey locks key of backend for a transaction
+func (s *store) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return storage.LockKey(ctx, s.backend, key)
+}
+
+// UnlockKey unlocks key of backend that is locked by LockKey
+func (s *store) UnlockKey(ctx context.Context, key string) {
+	storage.UnlockKey(ctx, s.backend, key)
+}
+
+// Usage returns the total bytes of all keys in backend, the sizes of encrypted
+// contents are the sizes after encryption.
+func (s *store) Usage(ctx context.Context) (int64, error) {
+	return storage.Usage(ctx, s.backend)
+}
+
+// WriteStats returns the bytes written by backend
+func (s *store) WriteStats() (storage.WriteStats, bool) {
+	return storage.GetWriteStats(s.backend)
+}
+
+// Watch returns the changes of keys under prefix of backend
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	return storage.Watch(ctx, s.backend, prefix)
+}
+
+// StatKeys returns the metadata of keys under key from backend, the sizes of
+// encrypted contents are the sizes after encryption.
+func (s *store) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	return storage.StatKeys(ctx, s.backend, key)
+}
+
+func (s *store) List(ctx context.Context, key string) ([][]byte, error) {
+	return s.ListInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListInOrder returns the contents under key in the specified order of their keys. the
+// contents of resources that are not encrypted are listed from backend directly, others
+// are read in a batch and decrypted one by one, because the key of contents is needed to
+// decrypt them.
+// like the disk storage, the contents that can't be read are skipped unless key itself is listed.
+func (s *store) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if _, resource, _, _ := util.SplitKey(strings.Trim(key, "/")); len(resource) != 0 && !s.resources[resource] {
+		if ordered, ok := s.backend.(storage.OrderedStore); ok {
+			return ordered.ListInOrder(ctx, key, order)
+		} else if order == storage.OrderByKey {
+			return s.backend.List(ctx, key)
+		}
+	}
+
+	keys, err := s.ListKeysInOrder(ctx, key, order)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 1 && keys[0] == strings.Trim(key, "/") {
+		b, err := s.Get(ctx, keys[0])
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{b}, nil
+	}
+
+	contents, err := s.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	bb := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		if b, ok := contents[k]; ok {
+			bb = append(bb, b)
+		}
+	}
+	return bb, nil
+}
+
+// GetMulti reads the contents of keys from backend in a batch and decrypts them, the
+// contents that can't be decrypted are skipped.
+func (s *store) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	contents, err := storage.GetMulti(ctx, s.backend, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]byte, len(contents))
+	for key, b := range contents {
+		plaintext, err := s.decrypt(key, b)
+		if err != nil {
+			klog.Warningf("failed to get bytes for %s in batch, %v", key, err)
+			continue
+		}
+		result[key] = plaintext
+	}
+	return result, nil
+}
+
+// SetMulti encrypts the contents of encrypted resources and writes them to backend in a batch
+func (s *store) SetMulti(ctx context.Context, contents map[string][]byte) error {
+	encrypted := make(map[string][]byte, len(contents))
+	for key, b := range contents {
+		eb, err := s.encrypt(key, b)
+		if err != nil {
+			return err
+		}
+		encrypted[key] = eb
+	}
+	return storage.SetMulti(ctx, s.backend, encrypted)
+}
@@ -0,0 +1,256 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/readonly"
+
+	"k8s.io/apiserver/pkg/storage/value"
+	aestransformer "k8s.io/apiserver/pkg/storage/value/encrypt/aes"
+	"k8s.io/apiserver/pkg/storage/value/encrypt/envelope"
+)
+
+const (
+	secretKey = "kubelet/secrets/default/token"
+	podKey    = "kubelet/pods/default/nginx"
+)
+
+// fakeKMS encrypts data keys by xor, it's only for tests
+type fakeKMS struct{}
+
+func (fakeKMS) Encrypt(data []byte) ([]byte, error) { return xor(data), nil }
+func (fakeKMS) Decrypt(data []byte) ([]byte, error) { return xor(data), nil }
+
+func xor(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = data[i] ^ 0x5a
+	}
+	return out
+}
+
+func writeKeyFile(t *testing.T, key []byte) string {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := ioutil.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write key file, %v", err)
+	}
+	return path
+}
+
+func newKeyFileTransformer(t *testing.T) value.Transformer {
+	transformer, err := newTransformer(Options{KeyFile: writeKeyFile(t, bytes.Repeat([]byte("k"), 32))})
+	if err != nil {
+		t.Fatalf("failed to create transformer, %v", err)
+	}
+	return transformer
+}
+
+func newKMSTransformer(t *testing.T) value.Transformer {
+	transformer, err := envelope.NewEnvelopeTransformer(fakeKMS{}, kmsCacheSize, aestransformer.NewGCMTransformer)
+	if err != nil {
+		t.Fatalf("failed to create envelope transformer, %v", err)
+	}
+	return value.NewPrefixTransformers(nil, value.PrefixTransformer{Prefix: []byte(kmsPrefix), Transformer: transformer})
+}
+
+func TestEncryption(t *testing.T) {
+	testcases := map[string]struct {
+		transformer func(t *testing.T) value.Transformer
+		prefix      string
+	}{
+		"key file": {
+			transformer: newKeyFileTransformer,
+			prefix:      aesGCMPrefix,
+		},
+		"kms": {
+			transformer: newKMSTransformer,
+			prefix:      kmsPrefix,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			backend, _ := fake.NewFakeStorage()
+			s, err := newStore(backend, tt.transformer(t), []string{"secrets"})
+			if err != nil {
+				t.Fatalf("failed to create store, %v", err)
+			}
+
+			secret, pod := []byte(`{"kind":"Secret","data":{"token":"c2VjcmV0"}}`), []byte(`{"kind":"Pod"}`)
+			if err := s.Create(context.Background(), secretKey, secret); err != nil {
+				t.Fatalf("failed to create %s, %v", secretKey, err)
+			}
+			if err := s.Update(context.Background(), podKey, pod); err != nil {
+				t.Fatalf("failed to update %s, %v", podKey, err)
+			}
+
+			b, _ := backend.Get(context.Background(), secretKey)
+			if !bytes.HasPrefix(b, []byte(tt.prefix)) || bytes.Contains(b, []byte("c2VjcmV0")) {
+				t.Errorf("expect secret is encrypted with prefix %s, but got %s", tt.prefix, string(b))
+			}
+			if b, _ := backend.Get(context.Background(), podKey); !bytes.Equal(b, pod) {
+				t.Errorf("expect pod is not encrypted, but got %s", string(b))
+			}
+
+			for key, expect := range map[string][]byte{secretKey: secret, podKey: pod} {
+				if b, err := s.Get(context.Background(), key); err != nil || !bytes.Equal(b, expect) {
+					t.Errorf("expect get %s for %s, but got %s, %v", string(expect), key, string(b), err)
+				}
+			}
+
+			for key, expect := range map[string]int{"kubelet/secrets": 1, "kubelet/pods": 1, "kubelet": 2} {
+				bb, err := s.List(context.Background(), key)
+				if err != nil || len(bb) != expect {
+					t.Errorf("expect list %d objects for %s, but got %d, %v", expect, key, len(bb), err)
+				}
+				for i := range bb {
+					if !bytes.Equal(bb[i], secret) && !bytes.Equal(bb[i], pod) {
+						t.Errorf("expect list decrypted objects for %s, but got %s", key, string(bb[i]))
+					}
+				}
+			}
+
+			// encrypted contents can't be moved to another key
+			movedKey := "kubelet/secrets/default/moved"
+			backend.Update(context.Background(), movedKey, b)
+			if _, err := s.Get(context.Background(), movedKey); err != storage.ErrCorrupted {
+				t.Errorf("expect get moved secret returns %v, but got %v", storage.ErrCorrupted, err)
+			}
+		})
+	}
+}
+
+func TestReplace(t *testing.T) {
+	backend, _ := fake.NewFakeStorage()
+	s, err := newStore(backend, newKeyFileTransformer(t), []string{"secrets"})
+	if err != nil {
+		t.Fatalf("failed to create store, %v", err)
+	}
+
+	secret := []byte(`{"kind":"Secret","data":{"token":"c2VjcmV0"}}`)
+	if err := s.Replace(context.Background(), "kubelet/secrets", map[string][]byte{secretKey: secret}); err != nil {
+		t.Fatalf("failed to replace, %v", err)
+	}
+	if b, _ := backend.Get(context.Background(), secretKey); !bytes.HasPrefix(b, []byte(aesGCMPrefix)) {
+		t.Errorf("expect replaced secret is encrypted, but got %s", string(b))
+	}
+	if b, err := s.Get(context.Background(), secretKey); err != nil || !bytes.Equal(b, secret) {
+		t.Errorf("expect get %s, but got %s, %v", string(secret), string(b), err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	backend, _ := fake.NewFakeStorage()
+	s, err := newStore(backend, newKeyFileTransformer(t), []string{"secrets"})
+	if err != nil {
+		t.Fatalf("failed to create store, %v", err)
+	}
+
+	secret := []byte(`{"kind":"Secret","data":{"token":"c2VjcmV0"}}`)
+	pod := []byte(`{"kind":"Pod"}`)
+	contents := map[string][]byte{secretKey: secret, podKey: pod}
+	if err := storage.SetMulti(context.Background(), s, contents); err != nil {
+		t.Fatalf("failed to set multi, %v", err)
+	}
+	if b, _ := backend.Get(context.Background(), secretKey); !bytes.HasPrefix(b, []byte(aesGCMPrefix)) {
+		t.Errorf("expect secret is encrypted, but got %s", string(b))
+	}
+	if b, _ := backend.Get(context.Background(), podKey); !bytes.Equal(b, pod) {
+		t.Errorf("expect pod is not encrypted, but got %s", string(b))
+	}
+
+	result, err := storage.GetMulti(context.Background(), s, []string{secretKey, podKey})
+	if err != nil {
+		t.Fatalf("failed to get multi, %v", err)
+	}
+	if len(result) != 2 || !bytes.Equal(result[secretKey], secret) || !bytes.Equal(result[podKey], pod) {
+		t.Errorf("expect %q, but got %q", contents, result)
+	}
+}
+
+func TestEncryptPlaintext(t *testing.T) {
+	backend, _ := fake.NewFakeStorage()
+	secret := []byte(`{"kind":"Secret"}`)
+	backend.Create(context.Background(), secretKey, secret)
+	backend.Create(context.Background(), podKey, []byte(`{"kind":"Pod"}`))
+
+	s, err := newStore(backend, newKeyFileTransformer(t), []string{"secrets"})
+	if err != nil {
+		t.Fatalf("failed to create store, %v", err)
+	}
+
+	if b, _ := backend.Get(context.Background(), secretKey); !bytes.HasPrefix(b, []byte(aesGCMPrefix)) {
+		t.Errorf("expect secret cached in plaintext is encrypted, but got %s", string(b))
+	}
+	if b, _ := backend.Get(context.Background(), podKey); bytes.HasPrefix(b, []byte(aesGCMPrefix)) {
+		t.Errorf("expect pod is not encrypted, but got %s", string(b))
+	}
+	if b, err := s.Get(context.Background(), secretKey); err != nil || !bytes.Equal(b, secret) {
+		t.Errorf("expect get %s, but got %s, %v", string(secret), string(b), err)
+	}
+}
+
+func TestReadOnlyPlaintext(t *testing.T) {
+	backend, _ := fake.NewFakeStorage()
+	secret := []byte(`{"kind":"Secret"}`)
+	backend.Create(context.Background(), secretKey, secret)
+
+	s, err := newStore(readonly.NewStore(backend), newKeyFileTransformer(t), []string{"secrets"})
+	if err != nil {
+		t.Fatalf("failed to create store, %v", err)
+	}
+
+	// the secret cached in plaintext is left as it is and served in read-only mode
+	if b, _ := backend.Get(context.Background(), secretKey); !bytes.Equal(b, secret) {
+		t.Errorf("expect secret is left in plaintext, but got %s", string(b))
+	}
+	if b, err := s.Get(context.Background(), secretKey); err != nil || !bytes.Equal(b, secret) {
+		t.Errorf("expect get %s, but got %s, %v", string(secret), string(b), err)
+	}
+	if bb, err := s.List(context.Background(), "kubelet/secrets"); err != nil || len(bb) != 1 || !bytes.Equal(bb[0], secret) {
+		t.Errorf("expect list %s, but got %q, %v", string(secret), bb, err)
+	}
+	if result, err := storage.GetMulti(context.Background(), s, []string{secretKey}); err != nil || !bytes.Equal(result[secretKey], secret) {
+		t.Errorf("expect get multi %s, but got %q, %v", string(secret), result, err)
+	}
+}
+
+func TestNewTransformer(t *testing.T) {
+	testcases := map[string]struct {
+		key       []byte
+		kms       string
+		expectErr bool
+	}{
+		"aes-128 key": {
+			key: bytes.Repeat([]byte("k"), 16),
+		},
+		"aes-256 key": {
+			key: bytes.Repeat([]byte("k"), 32),
+		},
+		"short key": {
+			key:       bytes.Repeat([]byte("k"), 8),
+			expectErr: true,
+		},
+		"both key file and kms": {
+			key:       bytes.Repeat([]byte("k"), 32),
+			kms:       "unix:///var/run/kms-plugin.sock",
+			expectErr: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			_, err := newTransformer(Options{KeyFile: writeKeyFile(t, tt.key), KMSEndpoint: tt.kms})
+			if tt.expectErr != (err != nil) {
+				t.Errorf("expect error %v, but got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/util"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/klog"
+)
+
+const (
+	// checkPeriod is the period that the connection to remote servers is checked
+	checkPeriod = 30 * time.Second
+	// collectPeriod is the period that the cache is walked for expired keys
+	collectPeriod = 10 * time.Minute
+	// storageTimeout bounds the time of listing and deleting keys
+	storageTimeout = time.Minute
+)
+
+// HealthChecker tells whether a remote server is healthy, it's implemented by
+// healthchecker.HealthChecker, which can't be imported by the options of yurthub.
+type HealthChecker interface {
+	IsHealthy(server *url.URL) bool
+}
+
+// DeleteFunc deletes key from cache
+type DeleteFunc func(ctx context.Context, key string) error
+
+// Options is the ttl of cached objects
+type Options struct {
+	// TTL is the time that a cached object is kept without being refreshed, 0 disables the expiry.
+	TTL time.Duration
+	// Overrides is the ttl of resources that is different from TTL, 0 means never expire.
+	Overrides map[string]time.Duration
+}
+
+// Enabled returns whether any cached objects expire
+func (o Options) Enabled() bool {
+	if o.TTL > 0 {
+		return true
+	}
+	for _, ttl := range o.Overrides {
+		if ttl > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ttlFor returns the ttl of the objects of resource, 0 means never expire
+func (o Options) ttlFor(resource string) time.Duration {
+	if ttl, ok := o.Overrides[resource]; ok {
+		return ttl
+	}
+	return o.TTL
+}
+
+// ParseOverrides parses the ttl of resources in the format of "resource=minutes"
+func ParseOverrides(entries []string) (map[string]time.Duration, error) {
+	overrides := make(map[string]time.Duration, len(entries))
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid cache ttl %q, the format is resource=minutes", entry)
+		}
+		minutes, err := strconv.Atoi(parts[1])
+		if err != nil || minutes < 0 {
+			return nil, fmt.Errorf("invalid minutes of cache ttl %q", entry)
+		}
+		if _, ok := overrides[parts[0]]; ok {
+			return nil, fmt.Errorf("cache ttl of %s is duplicated", parts[0])
+		}
+		overrides[parts[0]] = time.Duration(minutes) * time.Minute
+	}
+	return overrides, nil
+}
+
+// Collector deletes the cached objects that are not refreshed within their ttl, like the
+// objects deleted in cloud while yurthub missed the delete events. objects are refreshed
+// only when yurthub is connected to remote servers, so the time is counted from when yurthub
+// is connected last time, and the cache is never expired while the node is offline.
+type Collector struct {
+	store          storage.Store
+	deleteFn       DeleteFunc
+	opts           Options
+	remoteServers  []*url.URL
+	healthChecker  HealthChecker
+	clock          clock.Clock
+	connectedSince time.Time
+	lastCollect    time.Time
+	stopCh         <-chan struct{}
+}
+
+// NewCollector creates a Collector for the cache in store, the expired keys are deleted by
+// deleteFn, so the objects cached in memory in front of store are deleted as well.
+func NewCollector(store storage.Store,
+	deleteFn DeleteFunc,
+	opts Options,
+	remoteServers []*url.URL,
+	healthChecker HealthChecker,
+	stopCh <-chan struct{}) *Collector {
+	return NewCollectorWith(store, deleteFn, opts, remoteServers, healthChecker, clock.RealClock{}, stopCh)
+}
+
+// NewCollectorWith creates a Collector that counts time by clk, it's used in tests.
+func NewCollectorWith(store storage.Store,
+	deleteFn DeleteFunc,
+	opts Options,
+	remoteServers []*url.URL,
+	healthChecker HealthChecker,
+	clk clock.Clock,
+	stopCh <-chan struct{}) *Collector {
+	return &Collector{
+		store:         store,
+		deleteFn:      deleteFn,
+		opts:          opts,
+		remoteServers: remoteServers,
+		healthChecker: healthChecker,
+		clock:         clk,
+		stopCh:        stopCh,
+	}
+}
+
+// Run checks the connection and collects the expired keys in background
+func (c *Collector) Run() {
+	go func() {
+		for {
+			c.check()
+
+			timer := c.clock.NewTimer(checkPeriod)
+			select {
+			case <-c.stopCh:
+				timer.Stop()
+				return
+			case <-timer.C():
+			}
+		}
+	}()
+}
+
+// check records when yurthub is connected, and collects the expired keys every collectPeriod
+// while it's connected.
+func (c *Collector) check() {
+	if !c.connected() {
+		if !c.connectedSince.IsZero() {
+			klog.V(2).Infof("remote servers are unhealthy, stop expiring cache")
+		}
+		c.connectedSince = time.Time{}
+		return
+	}
+
+	now := c.clock.Now()
+	if c.connectedSince.IsZero() {
+		c.connectedSince = now
+	}
+	if now.Sub(c.lastCollect) >= collectPeriod {
+		c.lastCollect = now
+		c.collect(now)
+	}
+}
+
+func (c *Collector) connected() bool {
+	for _, server := range c.remoteServers {
+		if c.healthChecker.IsHealthy(server) {
+			return true
+		}
+	}
+	return false
+}
+
+// collect deletes the keys that are not refreshed within their ttl before now
+func (c *Collector) collect(now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+	infos, err := storage.StatKeys(ctx, c.store, "")
+	if err != nil {
+		klog.Errorf("failed to list cache for expiry, %v", err)
+		return
+	}
+
+	expired := 0
+	for _, info := range infos {
+		if !c.expired(info, now) {
+			continue
+		}
+		if err := c.deleteFn(ctx, info.Key); err != nil {
+			klog.Errorf("failed to delete expired cache %s, %v", info.Key, err)
+			continue
+		}
+		klog.V(2).Infof("expired cache %s is deleted, it's not refreshed since %s", info.Key, info.ModTime.Format(time.RFC3339))
+		expired++
+	}
+
+	if expired != 0 {
+		klog.Infof("%d expired objects are deleted from cache", expired)
+	}
+}
+
+// expired returns whether the object of key is not refreshed within its ttl
+func (c *Collector) expired(info storage.KeyInfo, now time.Time) bool {
+	comp, resource, _, _ := util.SplitKey(info.Key)
+	// internal keys of yurthub and cached responses of aggregated apis are not objects
+	if comp == "" || resource == "" || strings.HasPrefix(comp, "_") || strings.HasPrefix(resource, "_") {
+		return false
+	}
+
+	ttl := c.opts.ttlFor(resource)
+	if ttl <= 0 || info.ModTime.IsZero() {
+		return false
+	}
+
+	refreshed := info.ModTime
+	if refreshed.Before(c.connectedSince) {
+		refreshed = c.connectedSince
+	}
+	return now.Sub(refreshed) > ttl
+}
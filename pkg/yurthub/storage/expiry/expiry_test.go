@@ -0,0 +1,148 @@
+package expiry
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	yurttesting "github.com/alibaba/openyurt/pkg/yurthub/testing"
+)
+
+type healthChecker struct {
+	healthy bool
+}
+
+func (hc *healthChecker) IsHealthy(server *url.URL) bool {
+	return hc.healthy
+}
+
+func TestParseOverrides(t *testing.T) {
+	testcases := map[string]struct {
+		entries   []string
+		overrides map[string]time.Duration
+		valid     bool
+	}{
+		"overrides": {
+			entries:   []string{"events=60", "pods=0"},
+			overrides: map[string]time.Duration{"events": time.Hour, "pods": 0},
+			valid:     true,
+		},
+		"no minutes": {
+			entries: []string{"events"},
+		},
+		"negative": {
+			entries: []string{"events=-1"},
+		},
+		"duplicated": {
+			entries: []string{"events=60", "events=30"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			overrides, err := ParseOverrides(tt.entries)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expect invalid")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expect valid, but got %v", err)
+			}
+			for resource, ttl := range tt.overrides {
+				if overrides[resource] != ttl {
+					t.Errorf("expect ttl %v of %s, but got %v", ttl, resource, overrides[resource])
+				}
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := disk.NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to new disk storage, %v", err)
+	}
+
+	start := time.Now().Add(-48 * time.Hour)
+	clk := yurttesting.NewFakeClock(start)
+	ctx := context.Background()
+	keys := []string{
+		"kubelet/pods/default/pod1",
+		"kubelet/configmaps/default/cm1",
+		"kubelet/configmaps/default/cm2",
+		"kube-proxy/services/default/svc1",
+		"_internal/resync/apiserver.version",
+	}
+	for _, key := range keys {
+		if err := store.Create(ctx, key, []byte("object")); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+		// all objects are cached before yurthub is started
+		past := start.Add(-24 * time.Hour)
+		os.Chtimes(filepath.Join(baseDir, key), past, past)
+	}
+
+	hc := &healthChecker{healthy: false}
+	server, _ := url.Parse("https://10.0.0.1:6443")
+	opts := Options{
+		TTL:       time.Hour,
+		Overrides: map[string]time.Duration{"pods": 0, "services": 2 * time.Hour},
+	}
+	c := NewCollectorWith(store, store.Delete, opts, []*url.URL{server}, hc, clk, nil)
+	exists := func(key string) bool {
+		_, err := store.Get(ctx, key)
+		return err != storage.ErrNotFound
+	}
+
+	// cache is never expired while offline
+	c.check()
+	clk.Step(2 * time.Hour)
+	c.check()
+	if !exists("kubelet/configmaps/default/cm1") {
+		t.Fatalf("expect cache is not expired while offline")
+	}
+
+	// the time is counted from when yurthub is connected
+	hc.healthy = true
+	c.check()
+	if !exists("kubelet/configmaps/default/cm1") {
+		t.Fatalf("expect cache is not expired right after connected")
+	}
+
+	// cm2 is refreshed without changes
+	clk.Step(90 * time.Minute)
+	now := clk.Now()
+	os.Chtimes(filepath.Join(baseDir, "kubelet/configmaps/default/cm2"), now, now)
+	c.check()
+
+	expected := map[string]bool{
+		"kubelet/pods/default/pod1":          true,
+		"kubelet/configmaps/default/cm1":     false,
+		"kubelet/configmaps/default/cm2":     true,
+		"kube-proxy/services/default/svc1":   true,
+		"_internal/resync/apiserver.version": true,
+	}
+	for key, exist := range expected {
+		if exists(key) != exist {
+			t.Errorf("expect %s exists %v", key, exist)
+		}
+	}
+
+	// services expire by their own ttl
+	clk.Step(time.Hour)
+	c.check()
+	if exists("kube-proxy/services/default/svc1") {
+		t.Errorf("expect services expire after their ttl")
+	}
+	if !exists("kubelet/pods/default/pod1") {
+		t.Errorf("expect pods never expire")
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+)
+
+// maxImportSize bounds the size of a key in the tarball that is imported
+const maxImportSize = 64 * 1024 * 1024
+
+// Export writes the keys under each of keys in s to w as a gzipped tarball, a file per key that
+// is named by the key, so the cache of a node can seed the cache of another. all keys except the
+// internal ones of yurthub are exported if keys is empty. the number of exported keys is returned.
+func Export(ctx context.Context, s Store, w io.Writer, keys ...string) (int, error) {
+	if len(keys) == 0 {
+		keys = []string{""}
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	exported := 0
+	for _, key := range keys {
+		infos, err := StatKeys(ctx, s, key)
+		if err != nil {
+			return exported, err
+		}
+		for _, info := range infos {
+			if strings.HasPrefix(info.Key, internalKeyPrefix+"/") && !strings.HasPrefix(key, internalKeyPrefix) {
+				continue
+			}
+			contents, err := s.Get(ctx, info.Key)
+			if err == ErrNotFound {
+				continue
+			} else if err != nil {
+				return exported, fmt.Errorf("failed to get %s, %v", info.Key, err)
+			}
+
+			modTime := info.ModTime
+			if modTime.IsZero() {
+				modTime = time.Now()
+			}
+			hdr := &tar.Header{
+				Name:     info.Key,
+				Mode:     0600,
+				Size:     int64(len(contents)),
+				ModTime:  modTime,
+				Typeflag: tar.TypeReg,
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return exported, err
+			}
+			if _, err := tw.Write(contents); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return exported, err
+	}
+	return exported, gw.Close()
+}
+
+// Import writes the keys in the gzipped tarball of r that is written by Export to s. the keys
+// that are already in s are kept unless overwrite is true, as they are newer than the tarball
+// in general, and the internal keys of yurthub are skipped. the number of imported keys is
+// returned, the keys imported before an error are kept.
+func Import(ctx context.Context, s Store, r io.Reader, overwrite bool) (int, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read gzip, %v", err)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	imported := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return imported, fmt.Errorf("failed to read tarball, %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		key := strings.Trim(path.Clean("/"+hdr.Name), "/")
+		if key != strings.TrimPrefix(hdr.Name, "./") || !strings.Contains(key, "/") {
+			return imported, fmt.Errorf("key %s in tarball is invalid", hdr.Name)
+		}
+		if strings.HasPrefix(key, internalKeyPrefix+"/") {
+			klog.Warningf("internal key %s is not imported", key)
+			continue
+		}
+		if hdr.Size > maxImportSize {
+			return imported, fmt.Errorf("key %s in tarball exceeds %d bytes", key, maxImportSize)
+		}
+
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return imported, fmt.Errorf("failed to read %s in tarball, %v", key, err)
+		}
+		if !overwrite {
+			if _, err := s.Get(ctx, key); err == nil {
+				continue
+			} else if err != ErrNotFound {
+				return imported, fmt.Errorf("failed to get %s, %v", key, err)
+			}
+		}
+		if err := s.Update(ctx, key, contents); err != nil {
+			return imported, fmt.Errorf("failed to import %s, %v", key, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
@@ -0,0 +1,114 @@
+package storage_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+)
+
+func TestExportAndImport(t *testing.T) {
+	ctx := context.Background()
+	src, _ := memory.NewMemoryStorage(0)
+	for key, contents := range map[string]string{
+		"kubelet/pods/default/pod1":                "pod1",
+		"kubelet/pods/default/pod2":                "pod2",
+		"kubelet/nodes/node1":                      "node1",
+		"kube-proxy/services/default/kubernetes":   "kubernetes",
+		"_internal/cache-manager/cache-agent.conf": "kubelet",
+	} {
+		src.Create(ctx, key, []byte(contents))
+	}
+
+	testcases := map[string]struct {
+		keys      []string
+		overwrite bool
+		existing  map[string]string
+		exported  int
+		imported  int
+		expected  map[string]string
+	}{
+		"all keys": {
+			exported: 4,
+			imported: 4,
+			expected: map[string]string{"kubelet/pods/default/pod1": "pod1", "kubelet/nodes/node1": "node1", "kube-proxy/services/default/kubernetes": "kubernetes"},
+		},
+		"keys of kubelet": {
+			keys:     []string{"kubelet"},
+			exported: 3,
+			imported: 3,
+			expected: map[string]string{"kubelet/pods/default/pod2": "pod2", "kube-proxy/services/default/kubernetes": ""},
+		},
+		"existing keys are kept": {
+			keys:     []string{"kubelet/pods"},
+			existing: map[string]string{"kubelet/pods/default/pod1": "newer pod1"},
+			exported: 2,
+			imported: 1,
+			expected: map[string]string{"kubelet/pods/default/pod1": "newer pod1", "kubelet/pods/default/pod2": "pod2"},
+		},
+		"existing keys are overwritten": {
+			keys:      []string{"kubelet/pods"},
+			overwrite: true,
+			existing:  map[string]string{"kubelet/pods/default/pod1": "newer pod1"},
+			exported:  2,
+			imported:  2,
+			expected:  map[string]string{"kubelet/pods/default/pod1": "pod1"},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			var buf bytes.Buffer
+			exported, err := storage.Export(ctx, src, &buf, tt.keys...)
+			if err != nil || exported != tt.exported {
+				t.Fatalf("expect %d keys exported, but got %d, %v", tt.exported, exported, err)
+			}
+
+			dst, _ := memory.NewMemoryStorage(0)
+			for key, contents := range tt.existing {
+				dst.Create(ctx, key, []byte(contents))
+			}
+			imported, err := storage.Import(ctx, dst, &buf, tt.overwrite)
+			if err != nil || imported != tt.imported {
+				t.Fatalf("expect %d keys imported, but got %d, %v", tt.imported, imported, err)
+			}
+			for key, contents := range tt.expected {
+				b, _ := dst.Get(ctx, key)
+				if string(b) != contents {
+					t.Errorf("expect %s of %s, but got %s", contents, key, string(b))
+				}
+			}
+			if _, err := dst.Get(ctx, "_internal/cache-manager/cache-agent.conf"); err != storage.ErrNotFound {
+				t.Errorf("expect internal keys are not imported, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestImportInvalidTarball(t *testing.T) {
+	tarball := func(name string, contents string) *bytes.Buffer {
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gw)
+		tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(contents))
+		tw.Close()
+		gw.Close()
+		return &buf
+	}
+
+	dst, _ := memory.NewMemoryStorage(0)
+	for _, r := range []*bytes.Buffer{
+		bytes.NewBufferString("not a tarball"),
+		tarball("../kubelet/pods/default/pod1", "pod1"),
+		tarball("kubelet", "pod1"),
+	} {
+		if _, err := storage.Import(context.Background(), dst, r, false); err == nil {
+			t.Errorf("expect error for invalid tarball")
+		}
+	}
+}
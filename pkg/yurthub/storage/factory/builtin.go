@@ -0,0 +1,155 @@
+package factory
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+)
+
+// the backend options of built-in storages
+const (
+	// OptionFsync flushes writes of disk storage to disk, "true" or "false"
+	OptionFsync = "fsync"
+	// OptionCompression is the compression of disk storage, like "gzip"
+	OptionCompression = "compression"
+	// OptionMaxBytes is the maximum bytes of disk or memory storage, 0 means no limit
+	OptionMaxBytes = "max-bytes"
+	// OptionMaxObjects is the maximum number of keys of disk storage, 0 means no limit
+	OptionMaxObjects = "max-objects"
+	// OptionProtectedPrefixes are the prefixes of keys that are never evicted from disk
+	// storage, separated by ";" as "," separates the backend options in flags.
+	OptionProtectedPrefixes = "protected-prefixes"
+	// OptionReadOnly skips the writes when disk storage is created, "true" or "false"
+	OptionReadOnly = "read-only"
+	// OptionSharding puts the files of objects of disk storage into shard dirs, "true" or "false"
+	OptionSharding = "sharding"
+	// OptionSocket is the unix socket of storage plugin, like /var/run/yurthub/storage.sock
+	OptionSocket = "socket"
+)
+
+// knownOptions are the backend options that each built-in storage accepts
+var knownOptions = map[string][]string{
+	StorageDisk:   {OptionFsync, OptionCompression, OptionMaxBytes, OptionMaxObjects, OptionProtectedPrefixes, OptionReadOnly, OptionSharding},
+	StorageMemory: {OptionMaxBytes},
+	StorageBolt:   {},
+	StorageSQLite: {},
+	StoragePlugin: {OptionSocket},
+}
+
+// builtinOptions converts the typed options of built-in storageType into backend options,
+// the options of disk storage are omitted if they are zero.
+func builtinOptions(storageType string, opts Options) map[string]string {
+	options := make(map[string]string)
+	switch storageType {
+	case StorageDisk:
+		if opts.Disk.Fsync {
+			options[OptionFsync] = "true"
+		}
+		if opts.Disk.Compression != "" {
+			options[OptionCompression] = opts.Disk.Compression
+		}
+		if opts.Disk.MaxBytes != 0 {
+			options[OptionMaxBytes] = strconv.FormatInt(opts.Disk.MaxBytes, 10)
+		}
+		if opts.Disk.MaxObjects != 0 {
+			options[OptionMaxObjects] = strconv.Itoa(opts.Disk.MaxObjects)
+		}
+		if len(opts.Disk.ProtectedPrefixes) != 0 {
+			options[OptionProtectedPrefixes] = strings.Join(opts.Disk.ProtectedPrefixes, ";")
+		}
+		if opts.Disk.ReadOnly {
+			options[OptionReadOnly] = "true"
+		}
+		if opts.Disk.Sharding {
+			options[OptionSharding] = "true"
+		}
+	case StorageMemory:
+		if opts.MemoryStorageBytes != 0 {
+			options[OptionMaxBytes] = strconv.FormatInt(opts.MemoryStorageBytes, 10)
+		}
+	}
+	return options
+}
+
+// checkOptions returns error if options has keys that built-in storageType doesn't accept
+func checkOptions(storageType string, options map[string]string) error {
+	var unknown []string
+	for k := range options {
+		found := false
+		for _, known := range knownOptions[storageType] {
+			if k == known {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) != 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("options %v are not supported by %s storage", unknown, storageType)
+	}
+	return nil
+}
+
+func newDiskStorage(opts storage.BackendOptions) (storage.Store, error) {
+	if err := checkOptions(StorageDisk, opts.Options); err != nil {
+		return nil, err
+	}
+
+	var err error
+	diskOpts := disk.Options{Compression: opts.Options[OptionCompression]}
+	if v, ok := opts.Options[OptionFsync]; ok {
+		if diskOpts.Fsync, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("option %s(%s) of disk storage is invalid, %v", OptionFsync, v, err)
+		}
+	}
+	if v, ok := opts.Options[OptionReadOnly]; ok {
+		if diskOpts.ReadOnly, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("option %s(%s) of disk storage is invalid, %v", OptionReadOnly, v, err)
+		}
+	}
+	if v, ok := opts.Options[OptionSharding]; ok {
+		if diskOpts.Sharding, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("option %s(%s) of disk storage is invalid, %v", OptionSharding, v, err)
+		}
+	}
+	if v, ok := opts.Options[OptionMaxBytes]; ok {
+		if diskOpts.MaxBytes, err = strconv.ParseInt(v, 10, 64); err != nil || diskOpts.MaxBytes < 0 {
+			return nil, fmt.Errorf("option %s(%s) of disk storage is invalid", OptionMaxBytes, v)
+		}
+	}
+	if v, ok := opts.Options[OptionMaxObjects]; ok {
+		if diskOpts.MaxObjects, err = strconv.Atoi(v); err != nil || diskOpts.MaxObjects < 0 {
+			return nil, fmt.Errorf("option %s(%s) of disk storage is invalid", OptionMaxObjects, v)
+		}
+	}
+	for _, prefix := range strings.Split(opts.Options[OptionProtectedPrefixes], ";") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			diskOpts.ProtectedPrefixes = append(diskOpts.ProtectedPrefixes, prefix)
+		}
+	}
+	diskOpts.ReadOnly = diskOpts.ReadOnly || opts.ReadOnly
+	return disk.NewDiskStorageWithOptions(opts.BaseDir, diskOpts)
+}
+
+func newMemoryStorage(opts storage.BackendOptions) (storage.Store, error) {
+	if err := checkOptions(StorageMemory, opts.Options); err != nil {
+		return nil, err
+	}
+
+	var maxBytes int64
+	if v, ok := opts.Options[OptionMaxBytes]; ok {
+		var err error
+		if maxBytes, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("option %s(%s) of memory storage is invalid", OptionMaxBytes, v)
+		}
+	}
+	return memory.NewMemoryStorage(maxBytes)
+}
@@ -1,11 +1,146 @@
 package factory
 
 import (
+	"fmt"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/plugin"
 	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/bolt"
 	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/encryption"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/lru"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/readonly"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/sqlite"
+	"k8s.io/klog"
+)
+
+const (
+	// StorageDisk stores cache in files on disk
+	StorageDisk = "disk"
+	// StorageMemory stores cache in memory, for diskless edge devices, cache is lost when yurthub restarts
+	StorageMemory = "memory"
+	// StorageBolt stores cache in a bbolt db on disk, for the filesystems that are short of inodes
+	StorageBolt = "bolt"
+	// StorageSQLite stores cache in a sqlite db on disk, with the metadata of objects in columns
+	StorageSQLite = "sqlite"
+	// StoragePlugin stores cache in a storage plugin of yurthub on the unix socket in option "socket"
+	StoragePlugin = "plugin"
 )
 
-func CreateStorage() (storage.Store, error) {
+// backends are the registered storage backends, the built-in ones are registered in init
+var backends = storage.NewRegistry()
+
+func init() {
+	RegisterBackend(StorageDisk, newDiskStorage)
+	RegisterBackend(StorageMemory, newMemoryStorage)
+	RegisterBackend(StorageBolt, func(opts storage.BackendOptions) (storage.Store, error) {
+		if err := checkOptions(StorageBolt, opts.Options); err != nil {
+			return nil, err
+		}
+		return bolt.NewBoltStorageWithOptions(opts.BaseDir, bolt.Options{ReadOnly: opts.ReadOnly})
+	})
+	RegisterBackend(StorageSQLite, func(opts storage.BackendOptions) (storage.Store, error) {
+		if err := checkOptions(StorageSQLite, opts.Options); err != nil {
+			return nil, err
+		}
+		return sqlite.NewSQLiteStorageWithOptions(opts.BaseDir, sqlite.Options{ReadOnly: opts.ReadOnly})
+	})
+	RegisterBackend(StoragePlugin, func(opts storage.BackendOptions) (storage.Store, error) {
+		if err := checkOptions(StoragePlugin, opts.Options); err != nil {
+			return nil, err
+		}
+		socket := opts.Options[OptionSocket]
+		if socket == "" {
+			return nil, fmt.Errorf("option %s of plugin storage is not set", OptionSocket)
+		}
+		return plugin.NewStore(socket)
+	})
+}
+
+// RegisterBackend registers the factory of storage backend name, so it can be selected by
+// --cache-storage=name. it's called in init or main of a downstream yurthub before the
+// command is executed, so a custom Store can be plugged in without forking yurthub.
+func RegisterBackend(name string, f storage.Factory) {
+	backends.Register(name, f)
+}
+
+// IsSupportedStorage checks the type of cache storage is supported or not
+func IsSupportedStorage(storageType string) bool {
+	return backends.IsRegistered(storageType)
+}
+
+// SupportedStorages returns the sorted names of supported cache storages
+func SupportedStorages() []string {
+	return backends.Names()
+}
+
+// Options are the options of cache storage
+type Options struct {
+	// Type is the type of storage, like StorageDisk or StorageMemory
+	Type string
+	// BaseDir is the dir of cache on disk, the db of bolt or sqlite storage is next to it
+	BaseDir string
+	// Disk is the options of disk storage, they don't apply to bolt or sqlite storage
+	Disk disk.Options
+	// Encryption is the options of encrypting contents on disk
+	Encryption encryption.Options
+	// MemoryCacheBytes is the size of contents of hot keys cached in memory in front of disk,
+	// 0 disables the memory cache.
+	MemoryCacheBytes int64
+	// MemoryStorageBytes is the maximum size of contents in memory storage, 0 means no limit
+	MemoryStorageBytes int64
+	// ReadOnly serves the contents in storage as they are and rejects all writes with
+	// storage.ErrStorageReadOnly, the writes when storage is created are skipped as well.
+	ReadOnly bool
+	// BackendOptions are the backend-specific options passed to the factory of storage, they
+	// override the options above for the built-in storages(like "max-bytes" of disk storage).
+	BackendOptions map[string]string
+}
+
+// CreateStorage creates the storage of cache by the registered factory of its type. except for
+// memory storage, the contents of resources in encryption options are encrypted if encryption
+// is enabled, and contents of hot keys are cached in memory. memory storage is neither encrypted
+// nor cached, as its contents are never written to disk.
+func CreateStorage(opts Options) (storage.Store, error) {
+	storageType := opts.Type
+	if storageType == "" {
+		storageType = StorageDisk
+	}
+	if !IsSupportedStorage(storageType) {
+		return nil, fmt.Errorf("cache storage %s is not supported", storageType)
+	}
+
+	backendOpts := storage.BackendOptions{
+		BaseDir:  opts.BaseDir,
+		Options:  builtinOptions(storageType, opts),
+		ReadOnly: opts.ReadOnly,
+	}
+	for k, v := range opts.BackendOptions {
+		backendOpts.Options[k] = v
+	}
+	store, err := backends.New(storageType, backendOpts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.ReadOnly {
+		store = readonly.NewStore(store)
+	}
+	if storageType == StorageMemory {
+		if opts.Encryption.Enabled() {
+			klog.Infof("contents in memory storage are not encrypted")
+		}
+		return store, nil
+	}
+
+	if opts.Encryption.Enabled() {
+		store, err = encryption.NewStore(store, opts.Encryption)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	return disk.NewDiskStorage()
+	if opts.MemoryCacheBytes > 0 {
+		store = lru.NewStore(store, opts.MemoryCacheBytes)
+	}
+	return store, nil
 }
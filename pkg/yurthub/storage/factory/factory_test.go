@@ -0,0 +1,163 @@
+package factory
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+func TestRegisterBackend(t *testing.T) {
+	var passed storage.BackendOptions
+	RegisterBackend("test-backend", func(opts storage.BackendOptions) (storage.Store, error) {
+		passed = opts
+		return fake.NewFakeStorage()
+	})
+
+	if !IsSupportedStorage("test-backend") {
+		t.Fatalf("expect test-backend is supported")
+	}
+	_, err := CreateStorage(Options{
+		Type:           "test-backend",
+		BaseDir:        "/tmp/cache",
+		Disk:           disk.Options{MaxObjects: 10},
+		BackendOptions: map[string]string{"endpoint": "127.0.0.1:2379"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create storage, %v", err)
+	}
+	if passed.BaseDir != "/tmp/cache" || len(passed.Options) != 1 || passed.Options["endpoint"] != "127.0.0.1:2379" {
+		t.Errorf("expect only backend options are passed to custom storage, but got %#v", passed)
+	}
+
+	if _, err := CreateStorage(Options{Type: "not-registered"}); err == nil {
+		t.Errorf("expect error for the storage that is not registered")
+	}
+}
+
+func TestCreateBuiltinStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yurthub-factory")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testcases := map[string]struct {
+		opts    Options
+		invalid bool
+	}{
+		"default disk": {
+			opts: Options{BaseDir: filepath.Join(dir, "default")},
+		},
+		"disk with typed options": {
+			opts: Options{Type: StorageDisk, BaseDir: filepath.Join(dir, "typed"), Disk: disk.Options{Fsync: true, MaxBytes: 1024, ProtectedPrefixes: []string{"kubelet/pods", "kubelet/nodes"}}},
+		},
+		"disk with backend options": {
+			opts: Options{Type: StorageDisk, BaseDir: filepath.Join(dir, "backend"), BackendOptions: map[string]string{OptionMaxObjects: "100", OptionProtectedPrefixes: "kubelet/pods;kubelet/nodes"}},
+		},
+		"disk with sharding": {
+			opts: Options{Type: StorageDisk, BaseDir: filepath.Join(dir, "sharding"), BackendOptions: map[string]string{OptionSharding: "true"}},
+		},
+		"disk with invalid option": {
+			opts:    Options{Type: StorageDisk, BaseDir: filepath.Join(dir, "invalid"), BackendOptions: map[string]string{OptionFsync: "maybe"}},
+			invalid: true,
+		},
+		"disk with unknown option": {
+			opts:    Options{Type: StorageDisk, BaseDir: filepath.Join(dir, "unknown"), BackendOptions: map[string]string{"endpoint": "127.0.0.1:2379"}},
+			invalid: true,
+		},
+		"memory": {
+			opts: Options{Type: StorageMemory, BackendOptions: map[string]string{OptionMaxBytes: "10"}},
+		},
+		"memory with disk option": {
+			opts:    Options{Type: StorageMemory, BackendOptions: map[string]string{OptionCompression: "gzip"}},
+			invalid: true,
+		},
+		"bolt with option": {
+			opts:    Options{Type: StorageBolt, BaseDir: filepath.Join(dir, "bolt"), BackendOptions: map[string]string{OptionMaxBytes: "10"}},
+			invalid: true,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			store, err := CreateStorage(tt.opts)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("expect error for invalid options")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create storage, %v", err)
+			}
+			if err := store.Create(context.Background(), "kubelet/pods/default/pod1", []byte("pod1")); err != nil {
+				t.Errorf("failed to create key, %v", err)
+			}
+		})
+	}
+
+	// max-bytes in backend options limits memory storage
+	store, _ := CreateStorage(Options{Type: StorageMemory, MemoryStorageBytes: 1024, BackendOptions: map[string]string{OptionMaxBytes: "2"}})
+	if err := store.Create(context.Background(), "kubelet/pods/default/pod1", []byte("pod1")); err != storage.ErrExceedQuota {
+		t.Errorf("expect %v, but got %v", storage.ErrExceedQuota, err)
+	}
+}
+
+func TestCreateReadOnlyStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yurthub-factory")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := "kubelet/pods/default/pod1"
+	store, err := CreateStorage(Options{BaseDir: dir})
+	if err != nil {
+		t.Fatalf("failed to create storage, %v", err)
+	}
+	if err := store.Create(context.Background(), key, []byte("pod1")); err != nil {
+		t.Fatalf("failed to create key, %v", err)
+	}
+	// the temp file left by an interrupted write is not removed in read-only mode
+	tmpFile := filepath.Join(dir, "kubelet/pods/default/tmp_pod2.123")
+	ioutil.WriteFile(tmpFile, []byte("pod"), 0600)
+
+	store, err = CreateStorage(Options{BaseDir: dir, ReadOnly: true, MemoryCacheBytes: 1024})
+	if err != nil {
+		t.Fatalf("failed to create read-only storage, %v", err)
+	}
+	if !storage.IsReadOnly(store) {
+		t.Errorf("expect storage is read-only")
+	}
+	if _, err := os.Stat(tmpFile); err != nil {
+		t.Errorf("expect temp file is left as it is, %v", err)
+	}
+	if b, err := store.Get(context.Background(), key); err != nil || string(b) != "pod1" {
+		t.Errorf("expect pod1, but got %s, %v", string(b), err)
+	}
+	if err := store.Update(context.Background(), key, []byte("pod1-updated")); err != storage.ErrStorageReadOnly {
+		t.Errorf("expect %v, but got %v", storage.ErrStorageReadOnly, err)
+	}
+	if b, err := store.Get(context.Background(), key); err != nil || string(b) != "pod1" {
+		t.Errorf("expect pod1 is not changed, but got %s, %v", string(b), err)
+	}
+
+	// the db of bolt or sqlite storage is neither created nor migrated from disk in read-only mode
+	for _, storageType := range []string{StorageBolt, StorageSQLite} {
+		if _, err := CreateStorage(Options{Type: storageType, BaseDir: dir, ReadOnly: true}); err == nil {
+			t.Errorf("expect error for %s storage without db in read-only mode", storageType)
+		}
+		if _, err := os.Stat(filepath.Join(dir, key)); err != nil {
+			t.Errorf("expect %s on disk is untouched by %s storage, %v", key, storageType, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Clean(dir) + ".db"); !os.IsNotExist(err) {
+		t.Errorf("expect bolt db is not created in read-only mode, but got %v", err)
+	}
+}
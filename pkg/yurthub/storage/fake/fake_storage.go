@@ -1,6 +1,12 @@
 package fake
 
-import "github.com/alibaba/openyurt/pkg/yurthub/storage"
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
 
 type fakeStorage struct {
 	data map[string]string
@@ -12,41 +18,76 @@ func NewFakeStorage() (storage.Store, error) {
 	}, nil
 }
 
-func (fs *fakeStorage) Create(key string, contents []byte) error {
+func (fs *fakeStorage) Create(ctx context.Context, key string, contents []byte) error {
 	fs.data[key] = string(contents)
 	return nil
 }
 
-func (fs *fakeStorage) Delete(key string) error {
+func (fs *fakeStorage) Delete(ctx context.Context, key string) error {
 	delete(fs.data, key)
 	return nil
 }
 
-func (fs *fakeStorage) Get(key string) ([]byte, error) {
+func (fs *fakeStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+
+	keys, _ := fs.ListKeys(ctx, key)
+	for _, k := range keys {
+		delete(fs.data, k)
+	}
+	return nil
+}
+
+func (fs *fakeStorage) Get(ctx context.Context, key string) ([]byte, error) {
 	s, ok := fs.data[key]
 	if ok {
 		return []byte(s), nil
 	}
-	return []byte{}, nil
+
+	if keys, _ := fs.ListKeys(ctx, key); len(keys) != 0 {
+		return nil, storage.ErrKeyIsDir
+	}
+	return nil, storage.ErrNotFound
 }
 
-func (fs *fakeStorage) ListKeys(key string) ([]string, error) {
+func (fs *fakeStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
 	keys := make([]string, 0)
 	for k := range fs.data {
-		keys = append(keys, k)
+		if key == "" || k == key || strings.HasPrefix(k, strings.TrimSuffix(key, "/")+"/") {
+			keys = append(keys, k)
+		}
 	}
+	sort.Strings(keys)
 	return keys, nil
 }
 
-func (fs *fakeStorage) List(key string) ([][]byte, error) {
-	bb := make([][]byte, 0)
-	for _, v := range fs.data {
-		bb = append(bb, []byte(v))
+func (fs *fakeStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	keys, _ := fs.ListKeys(ctx, key)
+	bb := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		bb = append(bb, []byte(fs.data[k]))
 	}
 	return bb, nil
 }
 
-func (fs *fakeStorage) Update(key string, contents []byte) error {
+func (fs *fakeStorage) Update(ctx context.Context, key string, contents []byte) error {
 	fs.data[key] = string(contents)
 	return nil
 }
+
+func (fs *fakeStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+
+	keys, _ := fs.ListKeys(ctx, rootKey)
+	for _, k := range keys {
+		delete(fs.data, k)
+	}
+	for k, b := range contents {
+		fs.data[k] = string(b)
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package lru
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+// entry is the contents of key in memory
+type entry struct {
+	key      string
+	contents []byte
+}
+
+// store caches the contents of keys in memory in front of the backend storage, so the
+// repeated reads of hot keys(like node, leases and the frequently listed resources) are
+// served without reading the disk, which cuts read latency and the wear of flash storage.
+// writes go through to the backend and remove the keys from memory after they are written,
+// and the keys are cached again when they are read, so the contents in memory are always
+// the ones in backend, whatever order the concurrent writes of a key are finished in. the
+// keys evicted by backend(like for its quota) are removed from memory as well. the contents
+// are bounded by size in bytes, the least recently used ones are evicted.
+type store struct {
+	sync.Mutex
+	backend  storage.Store
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	lru      *list.List
+	// generation is increased by every write, the contents read from backend are not
+	// cached if there is a write during the read, because they may be stale.
+	generation uint64
+}
+
+// NewStore creates a storage that caches up to maxBytes contents of backend in memory
+func NewStore(backend storage.Store, maxBytes int64) storage.Store {
+	s := &store{
+		backend:  backend,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+	storage.OnEvict(backend, func(key string) {
+		s.invalidate(key)
+	})
+	return s
+}
+
+func (s *store) Create(ctx context.Context, key string, contents []byte) error {
+	defer s.invalidate(key)
+	return s.backend.Create(ctx, key, contents)
+}
+
+func (s *store) Update(ctx context.Context, key string, contents []byte) error {
+	defer s.invalidate(key)
+	return s.backend.Update(ctx, key, contents)
+}
+
+func (s *store) Delete(ctx context.Context, key string) error {
+	defer s.invalidate(key)
+	return s.backend.Delete(ctx, key)
+}
+
+func (s *store) DeleteCollection(ctx context.Context, key string, force bool) error {
+	defer s.invalidateCollection(key)
+	return s.backend.DeleteCollection(ctx, key, force)
+}
+
+// invalidateCollection removes the contents of keys under key from memory after they are written
+func (s *store) invalidateCollection(key string) {
+	s.Lock()
+	defer s.Unlock()
+	s.generation++
+	prefix := strings.Trim(key, "/") + "/"
+	for k, elem := range s.entries {
+		if strings.HasPrefix(k, prefix) {
+			s.remove(elem)
+		}
+	}
+}
+
+// Replace replaces the keys under rootKey in backend, the contents of the keys under
+// rootKey are removed from memory, they are read from backend again.
+func (s *store) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	defer s.invalidateCollection(rootKey)
+	return s.backend.Replace(ctx, rootKey, contents)
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	s.Lock()
+	if elem, ok := s.entries[key]; ok {
+		s.lru.MoveToFront(elem)
+		b := copyBytes(elem.Value.(*entry).contents)
+		s.Unlock()
+		return b, nil
+	}
+	gen := s.generation
+	s.Unlock()
+
+	b, err := s.backend.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	s.add(key, b, gen)
+	return b, nil
+}
+
+func (s *store) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return s.backend.ListKeys(ctx, key)
+}
+
+// ListKeysInOrder returns the keys under key in the specified order of backend
+func (s *store) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	return storage.ListKeysInOrder(ctx, s.backend, key, order)
+}
+
+// ReadOnly returns true if backend is read-only
+func (s *store) ReadOnly() bool {
+	return storage.IsReadOnly(s.backend)
+}
+
+// OnEvict adds f that is called with the keys evicted by backend
+func (s *store) OnEvict(f func(key string)) {
+	storage.OnEvict(s.backend, f)
+}
+
+// Touch marks key as refreshed in backend
+func (s *store) Touch(ctx context.Context, key string) error {
+	return storage.Touch(ctx, s.backend, key)
+}
+
+// LockKey locks key of backend for a transaction
+func (s *store) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return storage.LockKey(ctx, s.backend, key)
+}
+
+// UnlockKey unlocks key of backend that is locked by LockKey
+func (s *store) UnlockKey(ctx context.Context, key string) {
+	storage.UnlockKey(ctx, s.backend, key)
+}
+
+// Usage returns the total bytes of all keys in backend
+func (s *store) Usage(ctx context.Context) (int64, error) {
+	return storage.Usage(ctx, s.backend)
+}
+
+// WriteStats returns the bytes written by backend
+func (s *store) WriteStats() (storage.WriteStats, bool) {
+	return storage.GetWriteStats(s.backend)
+}
+
+// Watch returns the changes of keys under prefix of backend, the keys that are cached in
+// memory are not invalidated by the events, as all writes of cache go through the store,
+// and the keys evicted by backend are removed by OnEvict of backend.
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	return storage.Watch(ctx, s.backend, prefix)
+}
+
+// StatKeys returns the metadata of keys under key from backend
+func (s *store) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	return storage.StatKeys(ctx, s.backend, key)
+}
+
+func (s *store) List(ctx context.Context, key string) ([][]byte, error) {
+	return s.ListInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListInOrder returns the contents under key in the specified order of their keys, the
+// keys are listed from backend, and their contents are read through memory, the keys that
+// are not in memory are read from backend in a batch. like the disk storage, the contents
+// that can't be read are skipped unless key itself is listed.
+func (s *store) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	keys, err := s.ListKeysInOrder(ctx, key, order)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 1 && keys[0] == strings.Trim(key, "/") {
+		b, err := s.Get(ctx, keys[0])
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{b}, nil
+	}
+
+	contents, err := s.GetMulti(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	bb := make([][]byte, 0, len(keys))
+	for _, k := range keys {
+		if b, ok := contents[k]; ok {
+			bb = append(bb, b)
+		}
+	}
+	return bb, nil
+}
+
+// GetMulti returns the contents of keys in memory, and reads the others from backend in
+// a batch, the contents read from backend are kept in memory.
+func (s *store) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(keys))
+	missing := make([]string, 0, len(keys))
+	s.Lock()
+	for _, key := range keys {
+		if elem, ok := s.entries[key]; ok {
+			s.lru.MoveToFront(elem)
+			result[key] = copyBytes(elem.Value.(*entry).contents)
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	gen := s.generation
+	s.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	contents, err := storage.GetMulti(ctx, s.backend, missing)
+	if err != nil {
+		return nil, err
+	}
+	for key, b := range contents {
+		s.add(key, b, gen)
+		result[key] = b
+	}
+	return result, nil
+}
+
+// SetMulti writes contents of keys to backend in a batch, and removes them from memory
+// after they are written.
+func (s *store) SetMulti(ctx context.Context, contents map[string][]byte) error {
+	defer func() {
+		s.Lock()
+		defer s.Unlock()
+		s.generation++
+		for key := range contents {
+			if elem, ok := s.entries[key]; ok {
+				s.remove(elem)
+			}
+		}
+	}()
+	return storage.SetMulti(ctx, s.backend, contents)
+}
+
+// invalidate removes key from memory after it's written, and increases the generation, so
+// the contents of key that are read from backend during the write are not cached.
+func (s *store) invalidate(key string) {
+	s.Lock()
+	defer s.Unlock()
+	s.generation++
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+}
+
+// add caches contents of key that are read in generation gen, contents
+// larger than the half of maxBytes are not cached, so they don't evict all hot keys.
+func (s *store) add(key string, contents []byte, gen uint64) {
+	size := int64(len(contents))
+	if size == 0 || size > s.maxBytes/2 {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.generation != gen {
+		return
+	}
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	s.entries[key] = s.lru.PushFront(&entry{key: key, contents: copyBytes(contents)})
+	s.bytes += size
+	for s.bytes > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *store) remove(elem *list.Element) {
+	e := elem.Value.(*entry)
+	s.lru.Remove(elem)
+	delete(s.entries, e.key)
+	s.bytes -= int64(len(e.contents))
+}
+
+// copyBytes copies b, so the contents in memory are not changed by callers
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
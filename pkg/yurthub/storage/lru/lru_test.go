@@ -0,0 +1,303 @@
+package lru
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/fake"
+)
+
+// countingStore counts the gets of backend, and calls beforeGet before the get
+type countingStore struct {
+	storage.Store
+	gets      int
+	beforeGet func()
+}
+
+func (s *countingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.gets++
+	if s.beforeGet != nil {
+		s.beforeGet()
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func newTestStore(maxBytes int64) (*store, *countingStore) {
+	backend, _ := fake.NewFakeStorage()
+	counting := &countingStore{Store: backend}
+	return NewStore(counting, maxBytes).(*store), counting
+}
+
+func TestGet(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestStore(100)
+	backend.Store.Create(ctx, "kubelet/nodes/foo", []byte("foo"))
+
+	for i := 0; i < 3; i++ {
+		b, err := s.Get(ctx, "kubelet/nodes/foo")
+		if err != nil || string(b) != "foo" {
+			t.Fatalf("expect foo, but got %s, %v", string(b), err)
+		}
+		// contents in memory are not changed by callers
+		b[0] = 'x'
+	}
+	if backend.gets != 1 {
+		t.Errorf("expect 1 get of backend, but got %d", backend.gets)
+	}
+
+	if _, err := s.Get(ctx, "kubelet/nodes/bar"); err != storage.ErrNotFound {
+		t.Errorf("expect %v, but got %v", storage.ErrNotFound, err)
+	}
+}
+
+func TestWriteInvalidates(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestStore(100)
+
+	if err := s.Create(ctx, "kubelet/leases/foo", []byte("v1")); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+	if err := s.Update(ctx, "kubelet/leases/foo", []byte("v2")); err != nil {
+		t.Fatalf("failed to update, %v", err)
+	}
+	if b, err := s.Get(ctx, "kubelet/leases/foo"); err != nil || string(b) != "v2" {
+		t.Errorf("expect v2, but got %s, %v", string(b), err)
+	}
+	if b, _ := backend.Store.Get(ctx, "kubelet/leases/foo"); string(b) != "v2" {
+		t.Errorf("expect v2 in backend, but got %s", string(b))
+	}
+	// the written contents are read from backend once, and then from memory
+	s.Get(ctx, "kubelet/leases/foo")
+	if backend.gets != 1 {
+		t.Errorf("expect 1 get of backend, but got %d", backend.gets)
+	}
+
+	if err := s.Delete(ctx, "kubelet/leases/foo"); err != nil {
+		t.Fatalf("failed to delete, %v", err)
+	}
+	if _, err := s.Get(ctx, "kubelet/leases/foo"); err != storage.ErrNotFound {
+		t.Errorf("expect %v after delete, but got %v", storage.ErrNotFound, err)
+	}
+}
+
+func TestDeleteCollection(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(100)
+	for _, key := range []string{"kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/pods-x/foo"} {
+		s.Create(ctx, key, []byte(key))
+	}
+
+	if err := s.DeleteCollection(ctx, "kubelet/pods", false); err != nil {
+		t.Fatalf("failed to delete collection, %v", err)
+	}
+	for key, expectErr := range map[string]error{
+		"kubelet/pods/default/foo": storage.ErrNotFound,
+		"kubelet/pods/default/bar": storage.ErrNotFound,
+		"kubelet/pods-x/foo":       nil,
+	} {
+		if _, err := s.Get(ctx, key); err != expectErr {
+			t.Errorf("expect %v for %s, but got %v", expectErr, key, err)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestStore(100)
+	for _, key := range []string{"kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/pods-x/foo"} {
+		s.Create(ctx, key, []byte(key))
+	}
+
+	if err := s.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/pods/default/foo": []byte("foo-v2")}); err != nil {
+		t.Fatalf("failed to replace, %v", err)
+	}
+	if b, err := s.Get(ctx, "kubelet/pods/default/foo"); err != nil || string(b) != "foo-v2" {
+		t.Errorf("expect foo-v2, but got %s, %v", string(b), err)
+	}
+	for key, expectErr := range map[string]error{
+		"kubelet/pods/default/bar": storage.ErrNotFound,
+		"kubelet/pods-x/foo":       nil,
+	} {
+		if _, err := s.Get(ctx, key); err != expectErr {
+			t.Errorf("expect %v for %s, but got %v", expectErr, key, err)
+		}
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestStore(10)
+	backend.Store.Create(ctx, "kubelet/pods/default/a", []byte("aaaa"))
+	backend.Store.Create(ctx, "kubelet/pods/default/b", []byte("bbbb"))
+	backend.Store.Create(ctx, "kubelet/pods/default/c", []byte("cccc"))
+	backend.Store.Create(ctx, "kubelet/pods/default/large", []byte("llllllll"))
+	s.Get(ctx, "kubelet/pods/default/a")
+	s.Get(ctx, "kubelet/pods/default/b")
+	// a is used recently, so b is evicted by c
+	s.Get(ctx, "kubelet/pods/default/a")
+	s.Get(ctx, "kubelet/pods/default/c")
+	// contents larger than half of max bytes are not cached
+	s.Get(ctx, "kubelet/pods/default/large")
+
+	if s.bytes != 8 {
+		t.Errorf("expect 8 bytes in memory, but got %d", s.bytes)
+	}
+	for key, cached := range map[string]bool{
+		"kubelet/pods/default/a":     true,
+		"kubelet/pods/default/b":     false,
+		"kubelet/pods/default/c":     true,
+		"kubelet/pods/default/large": false,
+	} {
+		if _, ok := s.entries[key]; ok != cached {
+			t.Errorf("expect %s is cached %v, but got %v", key, cached, ok)
+		}
+	}
+
+	bb, err := s.List(ctx, "kubelet/pods/default")
+	if err != nil {
+		t.Fatalf("failed to list, %v", err)
+	}
+	expected := [][]byte{[]byte("aaaa"), []byte("bbbb"), []byte("cccc"), []byte("llllllll")}
+	if !reflect.DeepEqual(bb, expected) {
+		t.Errorf("expect %q, but got %q", expected, bb)
+	}
+	// a and c are read from memory, then b and large are read from backend in a batch
+	if backend.gets != 6 {
+		t.Errorf("expect 6 gets of backend, but got %d", backend.gets)
+	}
+}
+
+func TestStaleReadIsNotCached(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestStore(100)
+	backend.Store.Create(ctx, "kubelet/nodes/foo", []byte("v1"))
+
+	// the contents are updated while they are read from backend
+	backend.beforeGet = func() {
+		backend.beforeGet = nil
+		backend.Store.Update(ctx, "kubelet/nodes/foo", []byte("v2"))
+		s.invalidate("kubelet/nodes/foo")
+	}
+	s.Get(ctx, "kubelet/nodes/foo")
+
+	if b, err := s.Get(ctx, "kubelet/nodes/foo"); err != nil || string(b) != "v2" {
+		t.Errorf("expect v2, but got %s, %v", string(b), err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	s, backend := newTestStore(100)
+	backend.Store.Create(ctx, "kubelet/pods/default/a", []byte("aaaa"))
+	s.Get(ctx, "kubelet/pods/default/a")
+
+	contents := map[string][]byte{
+		"kubelet/pods/default/b": []byte("bbbb"),
+		"kubelet/pods/default/c": []byte("cccc"),
+	}
+	if err := s.SetMulti(ctx, contents); err != nil {
+		t.Fatalf("failed to set multi, %v", err)
+	}
+	for key, b := range contents {
+		if stored, err := backend.Store.Get(ctx, key); err != nil || !reflect.DeepEqual(stored, b) {
+			t.Errorf("expect %s is written to backend, but got %q, %v", key, stored, err)
+		}
+	}
+
+	result, err := s.GetMulti(ctx, []string{"kubelet/pods/default/a", "kubelet/pods/default/b", "kubelet/pods/default/c", "kubelet/pods/default/missing"})
+	if err != nil {
+		t.Fatalf("failed to get multi, %v", err)
+	}
+	expected := map[string][]byte{
+		"kubelet/pods/default/a": []byte("aaaa"),
+		"kubelet/pods/default/b": []byte("bbbb"),
+		"kubelet/pods/default/c": []byte("cccc"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expect %q, but got %q", expected, result)
+	}
+	// a is read from memory, the keys written in a batch are read from backend
+	if backend.gets != 4 {
+		t.Errorf("expect 4 gets of backend, but got %d", backend.gets)
+	}
+}
+
+// orderedStore finishes the update of contents "first" after the update of contents "second"
+type orderedStore struct {
+	storage.Store
+	updating      chan struct{}
+	secondWritten chan struct{}
+}
+
+func (s *orderedStore) Update(ctx context.Context, key string, contents []byte) error {
+	switch string(contents) {
+	case "first":
+		close(s.updating)
+		<-s.secondWritten
+		return s.Store.Update(ctx, key, contents)
+	case "second":
+		defer close(s.secondWritten)
+	}
+	return s.Store.Update(ctx, key, contents)
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	fakeStore, _ := fake.NewFakeStorage()
+	backend := &orderedStore{Store: fakeStore, updating: make(chan struct{}), secondWritten: make(chan struct{})}
+	s := NewStore(backend, 100)
+	key := "kubelet/leases/foo"
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		s.Update(ctx, key, []byte("first"))
+	}()
+	go func() {
+		defer wg.Done()
+		<-backend.updating
+		s.Update(ctx, key, []byte("second"))
+	}()
+	wg.Wait()
+
+	// the first write is the last one written to backend, so it's served from memory
+	stored, _ := fakeStore.Get(ctx, key)
+	if b, err := s.Get(ctx, key); err != nil || string(b) != string(stored) || string(b) != "first" {
+		t.Errorf("expect %s in backend is served, but got %s, %v", string(stored), string(b), err)
+	}
+}
+
+func TestBackendEviction(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "yurthub-lru")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// the layout version of cache is one of the 2 objects on disk
+	backend, err := disk.NewDiskStorageWithOptions(dir, disk.Options{MaxObjects: 2})
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	s := NewStore(backend, 100)
+
+	s.Create(ctx, "kubelet/pods/default/a", []byte("aaaa"))
+	if b, err := s.Get(ctx, "kubelet/pods/default/a"); err != nil || string(b) != "aaaa" {
+		t.Fatalf("expect aaaa, but got %s, %v", string(b), err)
+	}
+	// a is evicted by disk storage for b, and it's not served from memory any more
+	s.Create(ctx, "kubelet/pods/default/b", []byte("bbbb"))
+	if _, err := backend.Get(ctx, "kubelet/pods/default/a"); err != storage.ErrNotFound {
+		t.Fatalf("expect a is evicted from disk, but got %v", err)
+	}
+	if _, err := s.Get(ctx, "kubelet/pods/default/a"); err != storage.ErrNotFound {
+		t.Errorf("expect %v for evicted key, but got %v", storage.ErrNotFound, err)
+	}
+}
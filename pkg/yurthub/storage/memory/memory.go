@@ -0,0 +1,405 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"k8s.io/klog"
+)
+
+// entry is the contents of key with its modification time
+type entry struct {
+	contents []byte
+	modTime  time.Time
+}
+
+// memoryStorage stores cache in memory, for diskless edge devices(like the nodes that boot
+// from network or have read-only root fs) and for unit tests. cache is lost when yurthub
+// restarts, so pods can not be restarted from cache when the node is offline after that.
+// keys are organized like the files of disk storage, a key(like "kubelet/pods") that other
+// keys are under is a collection of keys.
+type memoryStorage struct {
+	sync.RWMutex
+	data map[string]*entry
+	// maxBytes is the maximum bytes of contents, 0 means no limit
+	maxBytes int64
+	bytes    int64
+}
+
+// NewMemoryStorage creates the storage that stores up to maxBytes contents in memory,
+// 0 means no limit. ErrExceedQuota is returned when writing beyond maxBytes, contents
+// are never evicted, because there is nowhere else to read them.
+func NewMemoryStorage(maxBytes int64) (storage.Store, error) {
+	if maxBytes < 0 {
+		return nil, fmt.Errorf("max bytes(%d) of memory storage can not be negative", maxBytes)
+	}
+
+	return &memoryStorage{
+		data:     make(map[string]*entry),
+		maxBytes: maxBytes,
+	}, nil
+}
+
+// normalizeKey returns key without trailing slash, ErrInvalidKey is returned for key
+// that is absolute, contains ".." or ".", or has empty components(except a trailing
+// slash), the same as disk storage, so a key is valid for both of them.
+func normalizeKey(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	elems := strings.Split(filepath.ToSlash(key), "/")
+	for i, elem := range elems {
+		switch elem {
+		case "..", ".":
+			klog.Errorf("key %s is rejected, %s is not allowed in key", key, elem)
+			return "", storage.ErrInvalidKey
+		case "":
+			// a trailing slash is allowed for collections
+			if i == 0 || i != len(elems)-1 {
+				klog.Errorf("key %s is rejected, empty component is not allowed in key", key)
+				return "", storage.ErrInvalidKey
+			}
+		}
+	}
+
+	return strings.TrimSuffix(key, "/"), nil
+}
+
+// isUnder returns true if key is root or under root, every key is under the empty root
+func isUnder(key, root string) bool {
+	return root == "" || key == root || strings.HasPrefix(key, root+"/")
+}
+
+// isCollection returns true if there are keys under key
+func (ms *memoryStorage) isCollection(key string) bool {
+	prefix := key + "/"
+	for k := range ms.data {
+		if key == "" || strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWrite checks key can be written with size bytes, the parents of key must not be
+// keys, like the dirs of a file in disk storage.
+func (ms *memoryStorage) checkWrite(key string, size int64) error {
+	if ms.isCollection(key) {
+		return storage.ErrKeyIsDir
+	}
+	for parent := filepath.Dir(key); parent != "."; parent = filepath.Dir(parent) {
+		if _, ok := ms.data[parent]; ok {
+			return fmt.Errorf("%s is not a collection", parent)
+		}
+	}
+
+	if ms.maxBytes > 0 {
+		bytes := ms.bytes + size
+		if e, ok := ms.data[key]; ok {
+			bytes -= int64(len(e.contents))
+		}
+		if bytes > ms.maxBytes {
+			return storage.ErrExceedQuota
+		}
+	}
+	return nil
+}
+
+// set writes a copy of contents to key, so the contents are not changed by the caller later
+func (ms *memoryStorage) set(key string, contents []byte, modTime time.Time) {
+	if e, ok := ms.data[key]; ok {
+		ms.bytes -= int64(len(e.contents))
+	}
+	ms.data[key] = &entry{contents: append([]byte(nil), contents...), modTime: modTime}
+	ms.bytes += int64(len(contents))
+}
+
+func (ms *memoryStorage) remove(key string) {
+	if e, ok := ms.data[key]; ok {
+		ms.bytes -= int64(len(e.contents))
+		delete(ms.data, key)
+	}
+}
+
+func (ms *memoryStorage) Create(ctx context.Context, key string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	} else if key == "" || len(contents) == 0 {
+		return nil
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+	if err := ms.checkWrite(key, int64(len(contents))); err != nil {
+		return err
+	}
+	ms.set(key, contents, time.Now())
+	return nil
+}
+
+// Update replaces the contents of key, it's the same as create
+func (ms *memoryStorage) Update(ctx context.Context, key string, contents []byte) error {
+	return ms.Create(ctx, key, contents)
+}
+
+func (ms *memoryStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+	ms.remove(key)
+	return nil
+}
+
+func (ms *memoryStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+	if _, ok := ms.data[key]; ok {
+		return fmt.Errorf("%s is not a collection", key)
+	}
+	for k := range ms.data {
+		if isUnder(k, key) {
+			ms.remove(k)
+		}
+	}
+	return nil
+}
+
+func (ms *memoryStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+	if e, ok := ms.data[key]; ok {
+		return append([]byte(nil), e.contents...), nil
+	} else if ms.isCollection(key) {
+		return nil, storage.ErrKeyIsDir
+	}
+	return nil, storage.ErrNotFound
+}
+
+// listEntry is a key found by list with its entry
+type listEntry struct {
+	key string
+	*entry
+}
+
+// listEntries returns the keys under key in the specified order, key itself is returned
+// if it's not a collection.
+func (ms *memoryStorage) listEntries(key string, order storage.ListOrder) ([]listEntry, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]listEntry, 0)
+	for k, e := range ms.data {
+		if isUnder(k, key) {
+			entries = append(entries, listEntry{key: k, entry: e})
+		}
+	}
+
+	switch order {
+	case storage.OrderByKey:
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	case storage.OrderByModTime:
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].modTime.Equal(entries[j].modTime) {
+				return entries[i].key < entries[j].key
+			}
+			return entries[i].modTime.Before(entries[j].modTime)
+		})
+	default:
+		return nil, fmt.Errorf("list order %s is not supported", order)
+	}
+	return entries, nil
+}
+
+func (ms *memoryStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return ms.ListKeysInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListKeysInOrder returns the keys under key in the specified order
+func (ms *memoryStorage) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return []string{}, err
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+	entries, err := ms.listEntries(key, order)
+	if err != nil {
+		return []string{}, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for i := range entries {
+		keys = append(keys, entries[i].key)
+	}
+	return keys, nil
+}
+
+func (ms *memoryStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	return ms.ListInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListInOrder returns the contents under key in the specified order of their keys
+func (ms *memoryStorage) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if key == "" {
+		return nil, fmt.Errorf("key for list is empty")
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+	entries, err := ms.listEntries(key, order)
+	if err != nil {
+		return nil, err
+	}
+
+	bb := make([][]byte, 0, len(entries))
+	for i := range entries {
+		bb = append(bb, append([]byte(nil), entries[i].contents...))
+	}
+	return bb, nil
+}
+
+// StatKeys returns the metadata of keys under key in ascending lexical order of keys
+func (ms *memoryStorage) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ms.RLock()
+	defer ms.RUnlock()
+	entries, err := ms.listEntries(key, storage.OrderByKey)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]storage.KeyInfo, 0, len(entries))
+	for i := range entries {
+		infos = append(infos, storage.KeyInfo{
+			Key:     entries[i].key,
+			Size:    int64(len(entries[i].contents)),
+			ModTime: entries[i].modTime,
+		})
+	}
+	return infos, nil
+}
+
+// Touch sets the modification time of key to now without rewriting it
+func (ms *memoryStorage) Touch(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+	if e, ok := ms.data[key]; ok {
+		e.modTime = time.Now()
+		return nil
+	} else if ms.isCollection(key) {
+		return storage.ErrKeyIsDir
+	}
+	return storage.ErrNotFound
+}
+
+// Replace replaces the keys under rootKey with contents atomically, readers never get
+// a mix of the old and new keys, because they are replaced under the lock of storage.
+func (ms *memoryStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+	rootKey, err := normalizeKey(rootKey)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string][]byte, len(contents))
+	var size int64
+	for key, b := range contents {
+		k, err := normalizeKey(key)
+		if err != nil {
+			return err
+		}
+		keys[k] = b
+		size += int64(len(b))
+	}
+
+	ms.Lock()
+	defer ms.Unlock()
+	if _, ok := ms.data[rootKey]; ok {
+		return fmt.Errorf("%s is not a collection", rootKey)
+	}
+
+	var oldSize int64
+	for k, e := range ms.data {
+		if isUnder(k, rootKey) {
+			oldSize += int64(len(e.contents))
+		}
+	}
+	if ms.maxBytes > 0 && ms.bytes-oldSize+size > ms.maxBytes {
+		return storage.ErrExceedQuota
+	}
+
+	for k := range ms.data {
+		if isUnder(k, rootKey) {
+			ms.remove(k)
+		}
+	}
+	now := time.Now()
+	for k, b := range keys {
+		ms.set(k, b, now)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package memory
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+func newTestStorage(t *testing.T, maxBytes int64, keys ...string) storage.Store {
+	s, err := NewMemoryStorage(maxBytes)
+	if err != nil {
+		t.Fatalf("failed to create memory storage, %v", err)
+	}
+	for _, key := range keys {
+		if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+	return s
+}
+
+func TestCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo")
+
+	contents := []byte("bar")
+	if err := s.Create(ctx, "kubelet/pods/default/bar", contents); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+	// contents in storage are not changed by callers
+	contents[0] = 'x'
+	b, err := s.Get(ctx, "kubelet/pods/default/bar")
+	if err != nil || string(b) != "bar" {
+		t.Fatalf("expect bar, but got %s, %v", string(b), err)
+	}
+	b[0] = 'x'
+	if b, _ := s.Get(ctx, "kubelet/pods/default/bar"); string(b) != "bar" {
+		t.Errorf("expect bar, but got %s", string(b))
+	}
+
+	testcases := map[string]struct {
+		key string
+		err error
+	}{
+		"not found":              {key: "kubelet/pods/default/baz", err: storage.ErrNotFound},
+		"collection":             {key: "kubelet/pods", err: storage.ErrKeyIsDir},
+		"collection with slash":  {key: "kubelet/pods/", err: storage.ErrKeyIsDir},
+		"parent of a collection": {key: "kubelet", err: storage.ErrKeyIsDir},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			if _, err := s.Get(ctx, tt.key); err != tt.err {
+				t.Errorf("expect %v, but got %v", tt.err, err)
+			}
+		})
+	}
+
+	if err := s.Create(ctx, "kubelet/pods", []byte("pods")); err != storage.ErrKeyIsDir {
+		t.Errorf("expect %v when create a collection, but got %v", storage.ErrKeyIsDir, err)
+	}
+	if err := s.Create(ctx, "kubelet/pods/default/foo/bar", []byte("bar")); err == nil {
+		t.Errorf("expect error when create a key under a key, but got nil")
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0)
+
+	for _, key := range []string{"/kubelet/pods/default/foo", "kubelet/../../etc/passwd",
+		"kubelet//pods/default/foo", "kubelet/./pods/default/foo", "kubelet/pods/default/."} {
+		if err := s.Create(ctx, key, []byte("foo")); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when create %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+		if _, err := s.Get(ctx, key); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when get %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+		if err := s.Delete(ctx, key); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when delete %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo", "kubelet/pods/default/bar")
+
+	if err := s.Delete(ctx, "kubelet/pods/default/foo"); err != nil {
+		t.Fatalf("failed to delete, %v", err)
+	}
+	if err := s.Delete(ctx, "kubelet/pods/default/foo"); err != nil {
+		t.Errorf("expect nil when delete a key that doesn't exist, but got %v", err)
+	}
+	// a collection is not deleted
+	if err := s.Delete(ctx, "kubelet/pods"); err != nil {
+		t.Errorf("expect nil when delete a collection, but got %v", err)
+	}
+
+	keys, _ := s.ListKeys(ctx, "kubelet")
+	if !reflect.DeepEqual(keys, []string{"kubelet/pods/default/bar"}) {
+		t.Errorf("expect keys [kubelet/pods/default/bar], but got %v", keys)
+	}
+}
+
+func TestDeleteCollection(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo", "kubelet/pods/kube-system/bar",
+		"kubelet/pods-extra/foo", "kubelet/nodes/foo")
+
+	if err := s.DeleteCollection(ctx, "kubelet", false); err != storage.ErrProtectedKey {
+		t.Errorf("expect %v, but got %v", storage.ErrProtectedKey, err)
+	}
+	if err := s.DeleteCollection(ctx, "kubelet/nodes/foo", false); err == nil {
+		t.Errorf("expect error when delete collection of a key, but got nil")
+	}
+	if err := s.DeleteCollection(ctx, "kubelet/pods", false); err != nil {
+		t.Fatalf("failed to delete collection, %v", err)
+	}
+
+	keys, _ := s.ListKeys(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"kubelet/nodes/foo", "kubelet/pods-extra/foo"}) {
+		t.Errorf("expect keys [kubelet/nodes/foo kubelet/pods-extra/foo], but got %v", keys)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/pods-extra/foo")
+
+	testcases := map[string]struct {
+		key      string
+		keys     []string
+		contents []string
+	}{
+		"collection": {
+			key:      "kubelet/pods",
+			keys:     []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo"},
+			contents: []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo"},
+		},
+		"specified key": {
+			key:      "kubelet/pods/default/foo",
+			keys:     []string{"kubelet/pods/default/foo"},
+			contents: []string{"kubelet/pods/default/foo"},
+		},
+		"not found": {
+			key:      "kubelet/nodes",
+			keys:     []string{},
+			contents: []string{},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			keys, err := s.ListKeys(ctx, tt.key)
+			if err != nil || !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("expect keys %v, but got %v, %v", tt.keys, keys, err)
+			}
+
+			bb, err := s.List(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("failed to list, %v", err)
+			}
+			contents := make([]string, 0, len(bb))
+			for _, b := range bb {
+				contents = append(contents, string(b))
+			}
+			if !reflect.DeepEqual(contents, tt.contents) {
+				t.Errorf("expect contents %v, but got %v", tt.contents, contents)
+			}
+		})
+	}
+
+	if _, err := s.List(ctx, ""); err == nil {
+		t.Errorf("expect error when list with empty key, but got nil")
+	}
+}
+
+func TestListKeysInOrderAndTouch(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo")
+	time.Sleep(10 * time.Millisecond)
+	s.Create(ctx, "kubelet/pods/default/bar", []byte("bar"))
+
+	keys, err := storage.ListKeysInOrder(ctx, s, "kubelet/pods", storage.OrderByModTime)
+	if err != nil || !reflect.DeepEqual(keys, []string{"kubelet/pods/default/foo", "kubelet/pods/default/bar"}) {
+		t.Errorf("expect keys [kubelet/pods/default/foo kubelet/pods/default/bar], but got %v, %v", keys, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := storage.Touch(ctx, s, "kubelet/pods/default/foo"); err != nil {
+		t.Fatalf("failed to touch, %v", err)
+	}
+	keys, _ = storage.ListKeysInOrder(ctx, s, "kubelet/pods", storage.OrderByModTime)
+	if !reflect.DeepEqual(keys, []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo"}) {
+		t.Errorf("expect keys [kubelet/pods/default/bar kubelet/pods/default/foo], but got %v", keys)
+	}
+	if err := storage.Touch(ctx, s, "kubelet/pods/default/baz"); err != storage.ErrNotFound {
+		t.Errorf("expect %v, but got %v", storage.ErrNotFound, err)
+	}
+
+	infos, err := storage.StatKeys(ctx, s, "kubelet/pods")
+	if err != nil || len(infos) != 2 || infos[0].Key != "kubelet/pods/default/bar" || infos[0].Size != 3 {
+		t.Errorf("expect stat of 2 keys, but got %+v, %v", infos, err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 0, "kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/nodes/foo")
+
+	err := s.Replace(ctx, "kubelet/pods", map[string][]byte{
+		"kubelet/pods/default/foo":     []byte("foo2"),
+		"kubelet/pods/kube-system/baz": []byte("baz"),
+	})
+	if err != nil {
+		t.Fatalf("failed to replace, %v", err)
+	}
+
+	keys, _ := s.ListKeys(ctx, "kubelet")
+	expected := []string{"kubelet/nodes/foo", "kubelet/pods/default/foo", "kubelet/pods/kube-system/baz"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expect keys %v, but got %v", expected, keys)
+	}
+	if b, _ := s.Get(ctx, "kubelet/pods/default/foo"); string(b) != "foo2" {
+		t.Errorf("expect foo2, but got %s", string(b))
+	}
+
+	err = s.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/nodes/bar": []byte("bar")})
+	if err != storage.ErrInvalidKey {
+		t.Errorf("expect %v when replace with key out of root, but got %v", storage.ErrInvalidKey, err)
+	}
+	if err := s.Replace(ctx, "kubelet", nil); err != storage.ErrProtectedKey {
+		t.Errorf("expect %v when replace root of component, but got %v", storage.ErrProtectedKey, err)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, 10)
+
+	if err := s.Create(ctx, "kubelet/pods/foo", []byte("12345678")); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+	if err := s.Create(ctx, "kubelet/pods/bar", []byte("123")); err != storage.ErrExceedQuota {
+		t.Errorf("expect %v, but got %v", storage.ErrExceedQuota, err)
+	}
+	// the size of the old contents is released by update
+	if err := s.Update(ctx, "kubelet/pods/foo", []byte("1234567890")); err != nil {
+		t.Errorf("failed to update, %v", err)
+	}
+	if err := s.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/pods/bar": []byte("123")}); err != nil {
+		t.Errorf("failed to replace, %v", err)
+	}
+	if err := s.Delete(ctx, "kubelet/pods/bar"); err != nil {
+		t.Fatalf("failed to delete, %v", err)
+	}
+	if err := s.Create(ctx, "kubelet/pods/foo", []byte("1234567890")); err != nil {
+		t.Errorf("expect the size of deleted key is released, but got %v", err)
+	}
+
+	if _, err := NewMemoryStorage(-1); err == nil {
+		t.Errorf("expect error for negative max bytes, but got nil")
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s := newTestStorage(t, 0)
+
+	if err := s.Create(ctx, "kubelet/pods/foo", []byte("foo")); err != context.Canceled {
+		t.Errorf("expect %v, but got %v", context.Canceled, err)
+	}
+	if _, err := s.Get(ctx, "kubelet/pods/foo"); err != context.Canceled {
+		t.Errorf("expect %v, but got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,121 @@
+package readonly
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+)
+
+// store serves the contents of backend as they are and rejects all writes with
+// storage.ErrStorageReadOnly, for debugging the cache and for the nodes with failing
+// disks, so yurthub never changes the cache on disk.
+type store struct {
+	backend storage.Store
+}
+
+// NewStore creates a read-only storage of backend
+func NewStore(backend storage.Store) storage.Store {
+	return &store{backend: backend}
+}
+
+// ReadOnly always returns true
+func (s *store) ReadOnly() bool {
+	return true
+}
+
+func (s *store) Create(ctx context.Context, key string, contents []byte) error {
+	return storage.ErrStorageReadOnly
+}
+
+func (s *store) Update(ctx context.Context, key string, contents []byte) error {
+	return storage.ErrStorageReadOnly
+}
+
+func (s *store) Delete(ctx context.Context, key string) error {
+	return storage.ErrStorageReadOnly
+}
+
+func (s *store) DeleteCollection(ctx context.Context, key string, force bool) error {
+	return storage.ErrStorageReadOnly
+}
+
+func (s *store) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	return storage.ErrStorageReadOnly
+}
+
+// OnEvict adds f that is called with the keys evicted by backend
+func (s *store) OnEvict(f func(key string)) {
+	storage.OnEvict(s.backend, f)
+}
+
+// Touch is rejected as well, as it changes the modification time of key
+func (s *store) Touch(ctx context.Context, key string) error {
+	return storage.ErrStorageReadOnly
+}
+
+func (s *store) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.backend.Get(ctx, key)
+}
+
+func (s *store) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return s.backend.ListKeys(ctx, key)
+}
+
+func (s *store) List(ctx context.Context, key string) ([][]byte, error) {
+	return s.backend.List(ctx, key)
+}
+
+// ListKeysInOrder returns the keys under key of backend in the specified order
+func (s *store) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	return storage.ListKeysInOrder(ctx, s.backend, key, order)
+}
+
+// ListInOrder returns the contents under key of backend in the specified order of their keys
+func (s *store) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if ordered, ok := s.backend.(storage.OrderedStore); ok {
+		return ordered.ListInOrder(ctx, key, order)
+	} else if order == storage.OrderByKey {
+		return s.backend.List(ctx, key)
+	}
+	return nil, fmt.Errorf("list order %s is not supported by storage", order)
+}
+
+// Usage returns the total bytes of all keys in backend
+func (s *store) Usage(ctx context.Context) (int64, error) {
+	return storage.Usage(ctx, s.backend)
+}
+
+// WriteStats returns the bytes written by backend
+func (s *store) WriteStats() (storage.WriteStats, bool) {
+	return storage.GetWriteStats(s.backend)
+}
+
+// Watch returns the changes of keys under prefix of backend
+func (s *store) Watch(ctx context.Context, prefix string) (<-chan storage.Event, error) {
+	return storage.Watch(ctx, s.backend, prefix)
+}
+
+// GetMulti returns the contents of keys of backend
+func (s *store) GetMulti(ctx context.Context, keys []string) (map[string][]byte, error) {
+	return storage.GetMulti(ctx, s.backend, keys)
+}
+
+func (s *store) SetMulti(ctx context.Context, contents map[string][]byte) error {
+	return storage.ErrStorageReadOnly
+}
+
+// StatKeys returns the metadata of keys under key of backend
+func (s *store) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	return storage.StatKeys(ctx, s.backend, key)
+}
+
+// LockKey locks key of backend for a transaction, the writes in it still fail
+func (s *store) LockKey(ctx context.Context, key string) (context.Context, error) {
+	return storage.LockKey(ctx, s.backend, key)
+}
+
+// UnlockKey unlocks key of backend that is locked by LockKey
+func (s *store) UnlockKey(ctx context.Context, key string) {
+	storage.UnlockKey(ctx, s.backend, key)
+}
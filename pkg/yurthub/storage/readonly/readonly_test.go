@@ -0,0 +1,56 @@
+package readonly
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+)
+
+func TestReadOnly(t *testing.T) {
+	ctx := context.Background()
+	backend, err := memory.NewMemoryStorage(0)
+	if err != nil {
+		t.Fatalf("failed to create memory storage, %v", err)
+	}
+	key := "kubelet/pods/default/pod1"
+	if err := backend.Create(ctx, key, []byte("pod1")); err != nil {
+		t.Fatalf("failed to create %s, %v", key, err)
+	}
+	s := NewStore(backend)
+	if !storage.IsReadOnly(s) || storage.IsReadOnly(backend) {
+		t.Fatalf("expect only the read-only store is read-only")
+	}
+
+	writes := map[string]func() error{
+		"create":            func() error { return s.Create(ctx, "kubelet/pods/default/pod2", []byte("pod2")) },
+		"update":            func() error { return s.Update(ctx, key, []byte("pod1-updated")) },
+		"delete":            func() error { return s.Delete(ctx, key) },
+		"delete collection": func() error { return s.DeleteCollection(ctx, "kubelet/pods", true) },
+		"replace":           func() error { return s.Replace(ctx, "kubelet/pods", map[string][]byte{}) },
+		"touch":             func() error { return storage.Touch(ctx, s, key) },
+		"set multi":         func() error { return storage.SetMulti(ctx, s, map[string][]byte{key: []byte("pod1-updated")}) },
+	}
+	for k, write := range writes {
+		t.Run(k, func(t *testing.T) {
+			if err := write(); err != storage.ErrStorageReadOnly {
+				t.Errorf("expect %v, but got %v", storage.ErrStorageReadOnly, err)
+			}
+		})
+	}
+
+	if b, err := s.Get(ctx, key); err != nil || string(b) != "pod1" {
+		t.Errorf("expect pod1 is served as it is, but got %s, %v", string(b), err)
+	}
+	if keys, err := s.ListKeys(ctx, "kubelet/pods"); err != nil || !reflect.DeepEqual(keys, []string{key}) {
+		t.Errorf("expect keys %v, but got %v, %v", []string{key}, keys, err)
+	}
+	if bb, err := s.List(ctx, "kubelet/pods"); err != nil || len(bb) != 1 || string(bb[0]) != "pod1" {
+		t.Errorf("expect pod1 is listed, but got %d contents, %v", len(bb), err)
+	}
+	if infos, err := storage.StatKeys(ctx, s, "kubelet/pods"); err != nil || len(infos) != 1 || infos[0].Key != key {
+		t.Errorf("expect metadata of %s, but got %v, %v", key, infos, err)
+	}
+}
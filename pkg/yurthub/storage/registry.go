@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/klog"
+)
+
+// BackendOptions are the options passed to the factory of a storage backend
+type BackendOptions struct {
+	// BaseDir is the dir of cache on disk(--disk-cache-path), backends that don't store
+	// cache on disk can ignore it.
+	BaseDir string
+	// Options are the backend-specific options(--cache-storage-options), like the
+	// endpoint of a remote store. a backend should reject the keys it doesn't know.
+	Options map[string]string
+	// ReadOnly is true if the cache is read-only(--cache-read-only), the backend must not write
+	// when it's created(like creating or migrating the cache), the writes after that are
+	// rejected by the read-only store in front of it.
+	ReadOnly bool
+}
+
+// Factory creates a storage backend with options
+type Factory func(opts BackendOptions) (Store, error)
+
+// Registry holds the factories of storage backends by name
+type Registry struct {
+	sync.Mutex
+	registry map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds the factory of storage backend name, a name can only be registered once
+func (r *Registry) Register(name string, f Factory) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.registry == nil {
+		r.registry = map[string]Factory{}
+	}
+
+	_, found := r.registry[name]
+	if found {
+		klog.Fatalf("storage backend %s was registered twice", name)
+	}
+
+	klog.V(2).Infof("Registered storage backend %s", name)
+	r.registry[name] = f
+}
+
+// IsRegistered checks storage backend name is registered or not
+func (r *Registry) IsRegistered(name string) bool {
+	r.Lock()
+	defer r.Unlock()
+	_, found := r.registry[name]
+	return found
+}
+
+// Names returns the sorted names of registered storage backends
+func (r *Registry) Names() []string {
+	r.Lock()
+	defer r.Unlock()
+	names := make([]string, 0, len(r.registry))
+	for name := range r.registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the storage backend name with opts
+func (r *Registry) New(name string, opts BackendOptions) (Store, error) {
+	r.Lock()
+	f, found := r.registry[name]
+	r.Unlock()
+	if !found {
+		return nil, fmt.Errorf("storage backend %s is not registered", name)
+	}
+
+	return f(opts)
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type fakeStore struct {
+	Store
+	opts BackendOptions
+}
+
+func (fs *fakeStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return []byte(fs.opts.Options[key]), nil
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Register("fake", func(opts BackendOptions) (Store, error) {
+		return &fakeStore{opts: opts}, nil
+	})
+	r.Register("another", func(opts BackendOptions) (Store, error) {
+		return &fakeStore{opts: opts}, nil
+	})
+
+	if !r.IsRegistered("fake") || r.IsRegistered("disk") {
+		t.Errorf("expect only fake and another are registered")
+	}
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"another", "fake"}) {
+		t.Errorf("expect sorted names, but got %v", names)
+	}
+
+	store, err := r.New("fake", BackendOptions{BaseDir: "/tmp/cache", Options: map[string]string{"endpoint": "127.0.0.1:2379"}})
+	if err != nil {
+		t.Fatalf("failed to create fake storage, %v", err)
+	}
+	if b, _ := store.Get(context.Background(), "endpoint"); string(b) != "127.0.0.1:2379" {
+		t.Errorf("expect options are passed to factory, but got %s", string(b))
+	}
+
+	if _, err := r.New("disk", BackendOptions{}); err == nil {
+		t.Errorf("expect error for the storage backend that is not registered")
+	}
+}
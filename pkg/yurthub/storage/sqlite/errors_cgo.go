@@ -0,0 +1,19 @@
+//go:build cgo
+// +build cgo
+
+package sqlite
+
+import (
+	"github.com/mattn/go-sqlite3"
+)
+
+// isFull returns true if err is returned because the disk or the db is full
+func isFull(err error) bool {
+	e, ok := err.(sqlite3.Error)
+	return ok && e.Code == sqlite3.ErrFull
+}
+
+// CheckCgo returns nil, the sqlite3 driver works in the yurthub built with cgo
+func CheckCgo() error {
+	return nil
+}
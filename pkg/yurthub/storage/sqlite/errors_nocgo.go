@@ -0,0 +1,20 @@
+//go:build !cgo
+// +build !cgo
+
+package sqlite
+
+import (
+	"fmt"
+)
+
+// isFull always returns false, the sqlite3 driver doesn't work without cgo, the db
+// can not be opened.
+func isFull(err error) bool {
+	return false
+}
+
+// CheckCgo returns an error, the sqlite3 driver is a stub in the yurthub built without
+// cgo(like the cross-compiled binaries), so sqlite storage is rejected before it's used.
+func CheckCgo() error {
+	return fmt.Errorf("sqlite storage requires yurthub built with cgo(CGO_ENABLED=1), use disk or bolt storage instead")
+}
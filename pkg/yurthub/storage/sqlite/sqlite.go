@@ -0,0 +1,552 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	// register the sqlite3 driver of database/sql
+	_ "github.com/mattn/go-sqlite3"
+	"k8s.io/klog"
+)
+
+const (
+	// busyTimeout bounds the time to wait for the lock of db, which is held by another
+	// yurthub that uses the same cache, or by a tool that queries the db.
+	busyTimeout = 5 * time.Second
+
+	// schema of the db, the metadata of keys are columns besides the contents, so the keys
+	// can be listed and queried(like by sqlite3 cli) without reading the contents. keys
+	// under a collection are a range of the primary key, which is listed by the index.
+	schema = `
+CREATE TABLE IF NOT EXISTS objects (
+	key TEXT NOT NULL PRIMARY KEY,
+	component TEXT NOT NULL,
+	resource TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	resource_version TEXT NOT NULL,
+	mod_time INTEGER NOT NULL,
+	contents BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS objects_by_resource ON objects (component, resource, namespace);
+`
+)
+
+// sqliteStorage stores cache in a sqlite db, one row for a key. the writes are durable
+// when they are finished, and a write is either applied completely or not at all.
+type sqliteStorage struct {
+	db *sql.DB
+}
+
+// entry is a key found by list with its metadata
+type entry struct {
+	key      string
+	size     int64
+	contents []byte
+	modTime  time.Time
+}
+
+// objectMeta is the metadata of object in contents, it's only read from contents encoded
+// in json, the columns are empty for other contents(like protobuf or encrypted ones).
+type objectMeta struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+}
+
+// execer is implemented by both sql.DB and sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// DBPath returns the path of db for the cache under baseDir, it's next to baseDir rather
+// than in it, so it's never taken as a key when baseDir is used by disk storage again.
+func DBPath(baseDir string) string {
+	return filepath.Clean(baseDir) + ".sqlite"
+}
+
+// Options are the options of sqlite storage
+type Options struct {
+	// ReadOnly opens the db read-only, the db must exist, as it's neither created nor
+	// migrated from disk storage, so the cache on disk is left as it is.
+	ReadOnly bool
+}
+
+// NewSQLiteStorage creates the storage that stores cache in the db next to baseDir, the keys
+// of disk storage under baseDir are migrated to the db when it's created. yurthub must be built
+// with cgo for the sqlite3 driver.
+func NewSQLiteStorage(baseDir string) (storage.Store, error) {
+	return NewSQLiteStorageWithOptions(baseDir, Options{})
+}
+
+// NewSQLiteStorageWithOptions creates the storage that stores cache in the db next to baseDir with options
+func NewSQLiteStorageWithOptions(baseDir string, opts Options) (storage.Store, error) {
+	if err := CheckCgo(); err != nil {
+		return nil, err
+	}
+	if len(baseDir) == 0 {
+		return nil, fmt.Errorf("base dir of sqlite storage is not set")
+	}
+
+	path := DBPath(baseDir)
+	_, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	created := os.IsNotExist(err)
+	if opts.ReadOnly {
+		if created {
+			return nil, fmt.Errorf("cache db %s doesn't exist, it's not created in read-only mode", path)
+		}
+		// the journal mode and schema are left as they are, as setting them writes the db
+		dsn := fmt.Sprintf("file:%s?mode=ro&_busy_timeout=%d", path, busyTimeout.Milliseconds())
+		db, err := sql.Open("sqlite3", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s, %v", path, err)
+		}
+		db.SetMaxOpenConns(1)
+		if err := db.Ping(); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to open %s, %v", path, err)
+		}
+		return &sqliteStorage{db: db}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	dsn := fmt.Sprintf("file:%s?_busy_timeout=%d&_journal_mode=WAL&_synchronous=FULL&_txlock=immediate",
+		path, busyTimeout.Milliseconds())
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s, %v", path, err)
+	}
+	// a connection is shared by all operations, so writes never wait for each other's locks
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		if created {
+			os.Remove(path)
+		}
+		return nil, fmt.Errorf("failed to init %s, %v", path, err)
+	}
+	os.Chmod(path, 0600)
+
+	ss := &sqliteStorage{db: db}
+	if created {
+		if err := migrateFromDisk(ss, baseDir); err != nil {
+			// the db is created again by the next start, and the migration is retried
+			db.Close()
+			os.Remove(path)
+			return nil, err
+		}
+	}
+	return ss, nil
+}
+
+// normalizeKey returns key without trailing slash, ErrInvalidKey is returned for key
+// that is absolute, contains ".." or ".", or has empty components(except a trailing
+// slash), the same as disk storage, so a key is valid for both of them.
+func normalizeKey(key string) (string, error) {
+	if key == "" {
+		return "", nil
+	}
+
+	elems := strings.Split(key, "/")
+	for i, elem := range elems {
+		switch elem {
+		case "..", ".":
+			klog.Errorf("key %s is rejected, %s is not allowed in key", key, elem)
+			return "", storage.ErrInvalidKey
+		case "":
+			// a trailing slash is allowed for collections
+			if i == 0 || i != len(elems)-1 {
+				klog.Errorf("key %s is rejected, empty component is not allowed in key", key)
+				return "", storage.ErrInvalidKey
+			}
+		}
+	}
+
+	return strings.TrimSuffix(key, "/"), nil
+}
+
+// under returns the condition and its args that select the keys under key, the keys under
+// "a/b" are in ["a/b/", "a/b0"), as '0' is next to '/'.
+func under(key string) (string, []interface{}) {
+	if key == "" {
+		return "1", nil
+	}
+	return "(key = ? OR (key >= ? AND key < ?))", []interface{}{key, key + "/", key + "0"}
+}
+
+// isCollection returns true if there are keys under key
+func isCollection(ctx context.Context, e execer, key string) (bool, error) {
+	var n int
+	err := e.QueryRowContext(ctx, "SELECT COUNT(*) FROM (SELECT 1 FROM objects WHERE key >= ? AND key < ? LIMIT 1)",
+		key+"/", key+"0").Scan(&n)
+	return n != 0, err
+}
+
+// exists returns true if key is in the db
+func exists(ctx context.Context, e execer, key string) (bool, error) {
+	var n int
+	err := e.QueryRowContext(ctx, "SELECT COUNT(*) FROM objects WHERE key = ?", key).Scan(&n)
+	return n != 0, err
+}
+
+// notFound returns ErrKeyIsDir if key is a collection, or ErrNotFound
+func notFound(ctx context.Context, e execer, key string) error {
+	if key == "" {
+		return storage.ErrKeyIsDir
+	}
+	if collection, err := isCollection(ctx, e, key); err != nil {
+		return err
+	} else if collection {
+		return storage.ErrKeyIsDir
+	}
+	return storage.ErrNotFound
+}
+
+// metaOf returns the columns of metadata of key and its contents
+func metaOf(key string, contents []byte) (component, resource, namespace, name, kind, rv string) {
+	comps := strings.Split(key, "/")
+	component, resource = comps[0], comps[1]
+	switch len(comps) {
+	case 3:
+		name = comps[2]
+	case 4:
+		namespace, name = comps[2], comps[3]
+	}
+
+	if len(contents) != 0 && contents[0] == '{' {
+		var meta objectMeta
+		if err := json.Unmarshal(contents, &meta); err == nil {
+			kind, rv = meta.Kind, meta.Metadata.ResourceVersion
+		}
+	}
+	return
+}
+
+// put writes the contents of key, the parents of key must not be keys, like the dirs of a
+// file in disk storage.
+func put(ctx context.Context, e execer, key string, contents []byte, modTime time.Time) error {
+	if !strings.Contains(key, "/") {
+		klog.Errorf("key %s is rejected, key must be under a component", key)
+		return storage.ErrInvalidKey
+	}
+
+	if collection, err := isCollection(ctx, e, key); err != nil {
+		return err
+	} else if collection {
+		return storage.ErrKeyIsDir
+	}
+	for i := strings.LastIndex(key, "/"); i > 0; i = strings.LastIndex(key[:i], "/") {
+		if found, err := exists(ctx, e, key[:i]); err != nil {
+			return err
+		} else if found {
+			return fmt.Errorf("%s is not a collection", key[:i])
+		}
+	}
+
+	component, resource, namespace, name, kind, rv := metaOf(key, contents)
+	_, err := e.ExecContext(ctx, "INSERT OR REPLACE INTO objects "+
+		"(key, component, resource, namespace, name, kind, resource_version, mod_time, contents) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		key, component, resource, namespace, name, kind, rv, modTime.UnixNano(), contents)
+	return convertErr(err)
+}
+
+// convertErr converts the error of db to the error of storage
+func convertErr(err error) error {
+	if isFull(err) {
+		return storage.ErrExceedQuota
+	}
+	return err
+}
+
+// inTx runs fn in a transaction, the transaction is committed if fn returns nil
+func (ss *sqliteStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := ss.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return convertErr(tx.Commit())
+}
+
+func (ss *sqliteStorage) Create(ctx context.Context, key string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	} else if key == "" || len(contents) == 0 {
+		return nil
+	}
+
+	return ss.inTx(ctx, func(tx *sql.Tx) error {
+		return put(ctx, tx, key, contents, time.Now())
+	})
+}
+
+// Update replaces the contents of key, it's the same as create
+func (ss *sqliteStorage) Update(ctx context.Context, key string, contents []byte) error {
+	return ss.Create(ctx, key, contents)
+}
+
+func (ss *sqliteStorage) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = ss.db.ExecContext(ctx, "DELETE FROM objects WHERE key = ?", key)
+	return err
+}
+
+func (ss *sqliteStorage) DeleteCollection(ctx context.Context, key string, force bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateDeleteCollection(key, force); err != nil {
+		return err
+	}
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	return ss.inTx(ctx, func(tx *sql.Tx) error {
+		return deleteUnder(ctx, tx, key)
+	})
+}
+
+// deleteUnder deletes the keys under key, an error is returned if it's not a collection
+func deleteUnder(ctx context.Context, tx *sql.Tx, key string) error {
+	if found, err := exists(ctx, tx, key); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("%s is not a collection", key)
+	}
+
+	cond, args := under(key)
+	_, err := tx.ExecContext(ctx, "DELETE FROM objects WHERE "+cond, args...)
+	return err
+}
+
+func (ss *sqliteStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents []byte
+	err = ss.db.QueryRowContext(ctx, "SELECT contents FROM objects WHERE key = ?", key).Scan(&contents)
+	if err == sql.ErrNoRows {
+		return nil, notFound(ctx, ss.db, key)
+	} else if err != nil {
+		return nil, err
+	}
+	return contents, nil
+}
+
+// listEntries returns the keys under key in the specified order, the contents are
+// read only if withContents is true.
+func (ss *sqliteStorage) listEntries(ctx context.Context, key string, order storage.ListOrder, withContents bool) ([]entry, error) {
+	key, err := normalizeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderBy string
+	switch order {
+	case storage.OrderByKey:
+		orderBy = "key"
+	case storage.OrderByModTime:
+		orderBy = "mod_time, key"
+	default:
+		return nil, fmt.Errorf("list order %s is not supported", order)
+	}
+	columns := "key, mod_time, length(contents)"
+	if withContents {
+		columns += ", contents"
+	}
+
+	cond, args := under(key)
+	rows, err := ss.db.QueryContext(ctx, "SELECT "+columns+" FROM objects WHERE "+cond+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]entry, 0)
+	for rows.Next() {
+		var e entry
+		var modTime int64
+		dest := []interface{}{&e.key, &modTime, &e.size}
+		if withContents {
+			dest = append(dest, &e.contents)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		e.modTime = time.Unix(0, modTime)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (ss *sqliteStorage) ListKeys(ctx context.Context, key string) ([]string, error) {
+	return ss.ListKeysInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListKeysInOrder returns the keys under key in the specified order
+func (ss *sqliteStorage) ListKeysInOrder(ctx context.Context, key string, order storage.ListOrder) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return []string{}, err
+	}
+
+	entries, err := ss.listEntries(ctx, key, order, false)
+	if err != nil {
+		return []string{}, err
+	}
+
+	keys := make([]string, 0, len(entries))
+	for i := range entries {
+		keys = append(keys, entries[i].key)
+	}
+	return keys, nil
+}
+
+func (ss *sqliteStorage) List(ctx context.Context, key string) ([][]byte, error) {
+	return ss.ListInOrder(ctx, key, storage.OrderByKey)
+}
+
+// ListInOrder returns the contents under key in the specified order of their keys
+func (ss *sqliteStorage) ListInOrder(ctx context.Context, key string, order storage.ListOrder) ([][]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	} else if key == "" {
+		return nil, fmt.Errorf("key for list is empty")
+	}
+
+	entries, err := ss.listEntries(ctx, key, order, true)
+	if err != nil {
+		return nil, err
+	}
+
+	bb := make([][]byte, 0, len(entries))
+	for i := range entries {
+		bb = append(bb, entries[i].contents)
+	}
+	return bb, nil
+}
+
+// StatKeys returns the metadata of keys under key in ascending lexical order of keys,
+// the contents are not read.
+func (ss *sqliteStorage) StatKeys(ctx context.Context, key string) ([]storage.KeyInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := ss.listEntries(ctx, key, storage.OrderByKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]storage.KeyInfo, 0, len(entries))
+	for i := range entries {
+		infos = append(infos, storage.KeyInfo{
+			Key:     entries[i].key,
+			Size:    entries[i].size,
+			ModTime: entries[i].modTime,
+		})
+	}
+	return infos, nil
+}
+
+// Touch sets the modification time of key to now
+func (ss *sqliteStorage) Touch(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	key, err := normalizeKey(key)
+	if err != nil {
+		return err
+	}
+
+	return ss.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE objects SET mod_time = ? WHERE key = ?", time.Now().UnixNano(), key)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return notFound(ctx, tx, key)
+		}
+		return nil
+	})
+}
+
+// Replace replaces the keys under rootKey with contents in one transaction, so readers
+// get either the keys before or after the replace.
+func (ss *sqliteStorage) Replace(ctx context.Context, rootKey string, contents map[string][]byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateReplace(rootKey, contents); err != nil {
+		return err
+	}
+	rootKey, err := normalizeKey(rootKey)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string][]byte, len(contents))
+	for key, b := range contents {
+		k, err := normalizeKey(key)
+		if err != nil {
+			return err
+		}
+		keys[k] = b
+	}
+
+	return ss.inTx(ctx, func(tx *sql.Tx) error {
+		if err := deleteUnder(ctx, tx, rootKey); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for k, b := range keys {
+			if err := put(ctx, tx, k, b, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
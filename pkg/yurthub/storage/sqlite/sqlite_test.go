@@ -0,0 +1,449 @@
+package sqlite
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/disk"
+)
+
+// newTestStorage creates a sqlite storage with keys, the returned func closes it and removes its files
+func newTestStorage(t *testing.T, keys ...string) (storage.Store, func()) {
+	skipWithoutCgo(t)
+	dir, err := ioutil.TempDir("", "yurthub-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	baseDir := filepath.Join(dir, "cache")
+	s, err := NewSQLiteStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create sqlite storage, %v", err)
+	}
+	cleanup := func() {
+		s.(*sqliteStorage).db.Close()
+		os.RemoveAll(dir)
+	}
+
+	for _, key := range keys {
+		if err := s.Create(context.Background(), key, []byte(key)); err != nil {
+			cleanup()
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+	return s, cleanup
+}
+
+// skipWithoutCgo skips the test if the sqlite3 driver doesn't work without cgo
+func skipWithoutCgo(t *testing.T) {
+	if err := CheckCgo(); err != nil {
+		t.Skip(err)
+	}
+}
+
+func TestCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t, "kubelet/pods/default/foo", "kubelet/nodes/foo", "_internal/resolver/hosts.json")
+	defer cleanup()
+
+	testcases := map[string]struct {
+		key      string
+		contents string
+		err      error
+	}{
+		"key of namespaced object": {key: "kubelet/pods/default/foo", contents: "kubelet/pods/default/foo"},
+		"key of cluster object":    {key: "kubelet/nodes/foo", contents: "kubelet/nodes/foo"},
+		"internal key":             {key: "_internal/resolver/hosts.json", contents: "_internal/resolver/hosts.json"},
+		"not found":                {key: "kubelet/pods/default/bar", err: storage.ErrNotFound},
+		"component not found":      {key: "kube-proxy/services/default/foo", err: storage.ErrNotFound},
+		"namespace":                {key: "kubelet/pods/default", err: storage.ErrKeyIsDir},
+		"resource":                 {key: "kubelet/pods/", err: storage.ErrKeyIsDir},
+		"component":                {key: "kubelet", err: storage.ErrKeyIsDir},
+		"root":                     {key: "", err: storage.ErrKeyIsDir},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			b, err := s.Get(ctx, tt.key)
+			if err != tt.err {
+				t.Fatalf("expect %v, but got %v", tt.err, err)
+			}
+			if string(b) != tt.contents {
+				t.Errorf("expect contents %s, but got %s", tt.contents, string(b))
+			}
+		})
+	}
+
+	if err := s.Create(ctx, "kubelet/pods/default", []byte("pods")); err != storage.ErrKeyIsDir {
+		t.Errorf("expect %v when create a collection, but got %v", storage.ErrKeyIsDir, err)
+	}
+	if err := s.Create(ctx, "kubelet/pods", []byte("pods")); err != storage.ErrKeyIsDir {
+		t.Errorf("expect %v when create a resource, but got %v", storage.ErrKeyIsDir, err)
+	}
+	if err := s.Create(ctx, "kubelet/pods/default/foo/bar", []byte("bar")); err == nil {
+		t.Errorf("expect error when create a key under a key, but got nil")
+	}
+	if err := s.Create(ctx, "kubelet", []byte("kubelet")); err != storage.ErrInvalidKey {
+		t.Errorf("expect %v when create a key out of component, but got %v", storage.ErrInvalidKey, err)
+	}
+
+	if err := s.Update(ctx, "kubelet/pods/default/foo", []byte("foo2")); err != nil {
+		t.Fatalf("failed to update, %v", err)
+	}
+	if b, _ := s.Get(ctx, "kubelet/pods/default/foo"); string(b) != "foo2" {
+		t.Errorf("expect foo2, but got %s", string(b))
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	for _, key := range []string{"/kubelet/pods/default/foo", "kubelet/../../etc/passwd",
+		"kubelet//pods/default/foo", "kubelet/./pods/default/foo", "kubelet/pods/default/."} {
+		if err := s.Create(ctx, key, []byte("foo")); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when create %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+		if _, err := s.Get(ctx, key); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when get %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+		if err := s.Delete(ctx, key); err != storage.ErrInvalidKey {
+			t.Errorf("expect %v when delete %s, but got %v", storage.ErrInvalidKey, key, err)
+		}
+	}
+}
+
+func TestListKeys(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t, "kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/pods/kube-system/foo",
+		"kubelet/pods-extra/foo", "kubelet/nodes/foo", "kube-proxy/services/default/foo")
+	defer cleanup()
+
+	testcases := map[string]struct {
+		key  string
+		keys []string
+	}{
+		"root": {
+			key: "",
+			keys: []string{"kube-proxy/services/default/foo", "kubelet/nodes/foo", "kubelet/pods-extra/foo",
+				"kubelet/pods/default/bar", "kubelet/pods/default/foo", "kubelet/pods/kube-system/foo"},
+		},
+		"component": {
+			key: "kubelet",
+			keys: []string{"kubelet/nodes/foo", "kubelet/pods-extra/foo", "kubelet/pods/default/bar",
+				"kubelet/pods/default/foo", "kubelet/pods/kube-system/foo"},
+		},
+		"resource": {
+			key:  "kubelet/pods",
+			keys: []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo", "kubelet/pods/kube-system/foo"},
+		},
+		"namespace": {
+			key:  "kubelet/pods/default/",
+			keys: []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo"},
+		},
+		"specified key": {
+			key:  "kubelet/nodes/foo",
+			keys: []string{"kubelet/nodes/foo"},
+		},
+		"not found": {
+			key:  "kubelet/services",
+			keys: []string{},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			keys, err := s.ListKeys(ctx, tt.key)
+			if err != nil || !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("expect keys %v, but got %v, %v", tt.keys, keys, err)
+			}
+
+			if tt.key == "" {
+				return
+			}
+			bb, err := s.List(ctx, tt.key)
+			if err != nil {
+				t.Fatalf("failed to list, %v", err)
+			}
+			contents := make([]string, 0, len(bb))
+			for _, b := range bb {
+				contents = append(contents, string(b))
+			}
+			if !reflect.DeepEqual(contents, tt.keys) {
+				t.Errorf("expect contents %v, but got %v", tt.keys, contents)
+			}
+		})
+	}
+}
+
+func TestListKeysInOrderAndTouch(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t, "kubelet/pods/default/foo")
+	defer cleanup()
+	time.Sleep(10 * time.Millisecond)
+	s.Create(ctx, "kubelet/pods/default/bar", []byte("bar"))
+
+	keys, err := storage.ListKeysInOrder(ctx, s, "kubelet/pods", storage.OrderByModTime)
+	if err != nil || !reflect.DeepEqual(keys, []string{"kubelet/pods/default/foo", "kubelet/pods/default/bar"}) {
+		t.Errorf("expect keys [kubelet/pods/default/foo kubelet/pods/default/bar], but got %v, %v", keys, err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := storage.Touch(ctx, s, "kubelet/pods/default/foo"); err != nil {
+		t.Fatalf("failed to touch, %v", err)
+	}
+	keys, _ = storage.ListKeysInOrder(ctx, s, "kubelet/pods", storage.OrderByModTime)
+	if !reflect.DeepEqual(keys, []string{"kubelet/pods/default/bar", "kubelet/pods/default/foo"}) {
+		t.Errorf("expect keys [kubelet/pods/default/bar kubelet/pods/default/foo], but got %v", keys)
+	}
+	if b, _ := s.Get(ctx, "kubelet/pods/default/foo"); string(b) != "kubelet/pods/default/foo" {
+		t.Errorf("expect contents are not changed by touch, but got %s", string(b))
+	}
+	if err := storage.Touch(ctx, s, "kubelet/pods/default/baz"); err != storage.ErrNotFound {
+		t.Errorf("expect %v, but got %v", storage.ErrNotFound, err)
+	}
+
+	infos, err := storage.StatKeys(ctx, s, "kubelet/pods")
+	if err != nil || len(infos) != 2 || infos[0].Key != "kubelet/pods/default/bar" || infos[0].Size != 3 {
+		t.Errorf("expect stat of 2 keys, but got %+v, %v", infos, err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t, "kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/pods/kube-system/foo",
+		"kubelet/nodes/foo")
+	defer cleanup()
+
+	if err := s.Delete(ctx, "kubelet/pods/default/foo"); err != nil {
+		t.Fatalf("failed to delete, %v", err)
+	}
+	for _, key := range []string{"kubelet/pods/default/foo", "kubelet/pods/default", "kubelet/pods", "kube-proxy/services/foo"} {
+		if err := s.Delete(ctx, key); err != nil {
+			t.Errorf("expect nil when delete %s, but got %v", key, err)
+		}
+	}
+
+	if err := s.DeleteCollection(ctx, "kubelet", false); err != storage.ErrProtectedKey {
+		t.Errorf("expect %v, but got %v", storage.ErrProtectedKey, err)
+	}
+	if err := s.DeleteCollection(ctx, "kubelet/nodes/foo", false); err == nil {
+		t.Errorf("expect error when delete collection of a key, but got nil")
+	}
+	if err := s.DeleteCollection(ctx, "kubelet/pods/default", false); err != nil {
+		t.Fatalf("failed to delete collection, %v", err)
+	}
+	keys, _ := s.ListKeys(ctx, "")
+	if !reflect.DeepEqual(keys, []string{"kubelet/nodes/foo", "kubelet/pods/kube-system/foo"}) {
+		t.Errorf("expect keys [kubelet/nodes/foo kubelet/pods/kube-system/foo], but got %v", keys)
+	}
+
+	if err := s.DeleteCollection(ctx, "kubelet/pods", false); err != nil {
+		t.Fatalf("failed to delete collection, %v", err)
+	}
+	if err := s.DeleteCollection(ctx, "kubelet", true); err != nil {
+		t.Fatalf("failed to delete collection, %v", err)
+	}
+	if keys, _ := s.ListKeys(ctx, ""); len(keys) != 0 {
+		t.Errorf("expect no keys, but got %v", keys)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t, "kubelet/pods/default/foo", "kubelet/pods/default/bar", "kubelet/nodes/foo")
+	defer cleanup()
+
+	err := s.Replace(ctx, "kubelet/pods", map[string][]byte{
+		"kubelet/pods/default/foo":     []byte("foo2"),
+		"kubelet/pods/kube-system/baz": []byte("baz"),
+	})
+	if err != nil {
+		t.Fatalf("failed to replace, %v", err)
+	}
+
+	keys, _ := s.ListKeys(ctx, "kubelet")
+	expected := []string{"kubelet/nodes/foo", "kubelet/pods/default/foo", "kubelet/pods/kube-system/baz"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expect keys %v, but got %v", expected, keys)
+	}
+	if b, _ := s.Get(ctx, "kubelet/pods/default/foo"); string(b) != "foo2" {
+		t.Errorf("expect foo2, but got %s", string(b))
+	}
+
+	err = s.Replace(ctx, "kubelet/pods", map[string][]byte{"kubelet/nodes/bar": []byte("bar")})
+	if err != storage.ErrInvalidKey {
+		t.Errorf("expect %v when replace with key out of root, but got %v", storage.ErrInvalidKey, err)
+	}
+	// the replace is rolled back when it fails
+	err = s.Replace(ctx, "kubelet/pods", map[string][]byte{
+		"kubelet/pods/default/foo":     []byte("foo3"),
+		"kubelet/pods/default/foo/bar": []byte("bar"),
+	})
+	if err == nil {
+		t.Errorf("expect error when replace with a key under a key, but got nil")
+	}
+	if keys, _ := s.ListKeys(ctx, "kubelet"); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expect keys %v, but got %v", expected, keys)
+	}
+}
+
+func TestMigrateFromDisk(t *testing.T) {
+	skipWithoutCgo(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "yurthub-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	baseDir := filepath.Join(dir, "cache")
+
+	ds, err := disk.NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	keys := []string{"kubelet/pods/default/foo", "kubelet/nodes/foo", "_internal/resolver/hosts.json"}
+	for _, key := range keys {
+		if err := ds.Create(ctx, key, []byte(key)); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+	infos, _ := storage.StatKeys(ctx, ds, "kubelet/pods/default/foo")
+
+	s, err := NewSQLiteStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create sqlite storage, %v", err)
+	}
+	for _, key := range keys {
+		if b, err := s.Get(ctx, key); err != nil || string(b) != key {
+			t.Errorf("expect %s is migrated, but got %s, %v", key, string(b), err)
+		}
+	}
+	if migrated, _ := storage.StatKeys(ctx, s, "kubelet/pods/default/foo"); len(migrated) != 1 ||
+		!migrated[0].ModTime.Equal(infos[0].ModTime) {
+		t.Errorf("expect modification time %v is migrated, but got %+v", infos[0].ModTime, migrated)
+	}
+	if keys, _ := ds.ListKeys(ctx, ""); len(keys) != 0 {
+		t.Errorf("expect keys on disk are removed after migration, but got %v", keys)
+	}
+
+	// keys written to disk again(like by a rollback to disk storage) are not migrated to an existing db
+	ds.Create(ctx, "kubelet/nodes/bar", []byte("bar"))
+	s.(*sqliteStorage).db.Close()
+	s, err = NewSQLiteStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to open sqlite storage, %v", err)
+	}
+	defer s.(*sqliteStorage).db.Close()
+	if _, err := s.Get(ctx, "kubelet/nodes/bar"); err != storage.ErrNotFound {
+		t.Errorf("expect %v, but got %v", storage.ErrNotFound, err)
+	}
+	if b, err := s.Get(ctx, "kubelet/nodes/foo"); err != nil || string(b) != "kubelet/nodes/foo" {
+		t.Errorf("expect kubelet/nodes/foo is kept in db, but got %s, %v", string(b), err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	s, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	pod := `{"kind":"Pod","apiVersion":"v1","metadata":{"name":"foo","namespace":"default","resourceVersion":"10"}}`
+	if err := s.Create(ctx, "kubelet/pods/default/foo", []byte(pod)); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+	if err := s.Create(ctx, "kubelet/nodes/foo", []byte("\x6b\x38\x73\x00")); err != nil {
+		t.Fatalf("failed to create, %v", err)
+	}
+
+	testcases := map[string]struct {
+		key      string
+		expected []string
+	}{
+		"namespaced object in json": {
+			key:      "kubelet/pods/default/foo",
+			expected: []string{"kubelet", "pods", "default", "foo", "Pod", "10"},
+		},
+		"cluster object in protobuf": {
+			key:      "kubelet/nodes/foo",
+			expected: []string{"kubelet", "nodes", "", "foo", "", ""},
+		},
+	}
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			columns := make([]string, 6)
+			err := s.(*sqliteStorage).db.QueryRow("SELECT component, resource, namespace, name, kind, resource_version "+
+				"FROM objects WHERE key = ?", tt.key).Scan(&columns[0], &columns[1], &columns[2], &columns[3], &columns[4], &columns[5])
+			if err != nil || !reflect.DeepEqual(columns, tt.expected) {
+				t.Errorf("expect metadata %v, but got %v, %v", tt.expected, columns, err)
+			}
+		})
+	}
+}
+
+func TestCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s, cleanup := newTestStorage(t)
+	defer cleanup()
+
+	if err := s.Create(ctx, "kubelet/pods/foo", []byte("foo")); err != context.Canceled {
+		t.Errorf("expect %v, but got %v", context.Canceled, err)
+	}
+	if _, err := s.Get(ctx, "kubelet/pods/foo"); err != context.Canceled {
+		t.Errorf("expect %v, but got %v", context.Canceled, err)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	skipWithoutCgo(t)
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "yurthub-sqlite")
+	if err != nil {
+		t.Fatalf("failed to create temp dir, %v", err)
+	}
+	defer os.RemoveAll(dir)
+	baseDir := filepath.Join(dir, "cache")
+
+	ds, err := disk.NewDiskStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create disk storage, %v", err)
+	}
+	if err := ds.Create(ctx, "kubelet/pods/default/foo", []byte("foo")); err != nil {
+		t.Fatalf("failed to create key, %v", err)
+	}
+
+	// the db is neither created nor migrated from disk storage in read-only mode
+	if _, err := NewSQLiteStorageWithOptions(baseDir, Options{ReadOnly: true}); err == nil {
+		t.Errorf("expect error when db doesn't exist in read-only mode")
+	}
+	if _, err := os.Stat(DBPath(baseDir)); !os.IsNotExist(err) {
+		t.Errorf("expect db is not created in read-only mode, but got %v", err)
+	}
+	if b, err := ds.Get(ctx, "kubelet/pods/default/foo"); err != nil || string(b) != "foo" {
+		t.Errorf("expect key on disk is untouched, but got %s, %v", string(b), err)
+	}
+
+	s, err := NewSQLiteStorage(baseDir)
+	if err != nil {
+		t.Fatalf("failed to create sqlite storage, %v", err)
+	}
+	s.(*sqliteStorage).db.Close()
+
+	ro, err := NewSQLiteStorageWithOptions(baseDir, Options{ReadOnly: true})
+	if err != nil {
+		t.Fatalf("failed to open sqlite storage in read-only mode, %v", err)
+	}
+	defer ro.(*sqliteStorage).db.Close()
+	if b, err := ro.Get(ctx, "kubelet/pods/default/foo"); err != nil || string(b) != "foo" {
+		t.Errorf("expect foo in read-only mode, but got %s, %v", string(b), err)
+	}
+	if err := ro.Update(ctx, "kubelet/pods/default/foo", []byte("bar")); err == nil {
+		t.Errorf("expect writes to read-only db fail")
+	}
+}
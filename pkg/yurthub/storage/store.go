@@ -1,16 +1,407 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
+// internalKeyPrefix is the prefix of keys that are used by yurthub itself
+const internalKeyPrefix = "_internal"
+
 var ErrStorageAccessConflict = errors.New("specified key is under accessing")
 
+// ErrInvalidKey is returned when key is absolute, contains "..", or refers
+// to a path outside of storage(like by symlinks).
+var ErrInvalidKey = errors.New("specified key is invalid")
+
+// ErrProtectedKey is returned when delete a protected collection without force
+var ErrProtectedKey = errors.New("specified key is protected")
+
+// ErrNotFound is returned when get a key that is not in storage
+var ErrNotFound = errors.New("specified key is not found")
+
+// ErrKeyIsDir is returned when get or write a key that is a collection of keys(like "kubelet/pods")
+var ErrKeyIsDir = errors.New("specified key is a collection of keys")
+
+// ErrCorrupted is returned when the content of key is damaged(like truncated by
+// power loss) and can not be decoded.
+var ErrCorrupted = errors.New("content of specified key is corrupted")
+
+// ErrExceedQuota is returned when there is no space(like disk is full) for writing the content
+var ErrExceedQuota = errors.New("storage quota is exceeded")
+
+// ErrStorageReadOnly is returned when write a storage that is read-only(like --cache-read-only)
+var ErrStorageReadOnly = errors.New("storage is read-only")
+
+// ErrWatchNotSupported is returned when watch a storage that can't notify the changes of keys
+var ErrWatchNotSupported = errors.New("watch is not supported by storage")
+
+// ListOrder is the order of keys or contents returned by list
+type ListOrder int
+
+const (
+	// OrderByKey sorts keys in ascending lexical order
+	OrderByKey ListOrder = iota
+	// OrderByModTime sorts keys from the oldest modification to the newest,
+	// keys modified at the same time are sorted in ascending lexical order.
+	OrderByModTime
+)
+
+func (o ListOrder) String() string {
+	switch o {
+	case OrderByKey:
+		return "key"
+	case OrderByModTime:
+		return "mtime"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(o))
+	}
+}
+
+// Store is the interface of storage for cache, ctx of the methods bounds the
+// time of operation, ctx.Err() is returned when ctx is done before the operation
+// is finished, so slow storage doesn't block the caller beyond its deadline.
+// all implementations return the errors defined in this package for the same
+// failures, so callers can tell a missing key from a broken storage.
 type Store interface {
-	Create(key string, contents []byte) error
-	Delete(key string) error
-	Get(key string) ([]byte, error)
-	ListKeys(key string) ([]string, error)
-	List(key string) ([][]byte, error)
-	Update(key string, contents []byte) error
+	// Create writes contents of key, ErrKeyIsDir is returned if key is a collection
+	// of keys, and ErrExceedQuota is returned if there is no space for contents.
+	Create(ctx context.Context, key string, contents []byte) error
+	// Delete deletes the content of key, nil is returned if key doesn't exist.
+	// a key for a collection(like "kubelet/pods") is not deleted, use
+	// DeleteCollection for it instead.
+	Delete(ctx context.Context, key string) error
+	// DeleteCollection deletes all keys under key recursively. the root of storage
+	// can not be deleted, and the root of a component(like "kubelet") or internal
+	// keys are protected and ErrProtectedKey is returned unless force is true.
+	DeleteCollection(ctx context.Context, key string, force bool) error
+	// Get returns the content of key, ErrNotFound is returned if key doesn't exist,
+	// and ErrKeyIsDir is returned if key is a collection of keys.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// ListKeys returns the keys under key in ascending lexical order,
+	// so the keys can be iterated deterministically. an empty list
+	// is returned if key doesn't exist.
+	ListKeys(ctx context.Context, key string) ([]string, error)
+	// List returns the contents under key in ascending lexical order of their keys
+	List(ctx context.Context, key string) ([][]byte, error)
+	// Update replaces contents of key, it returns the same errors as Create.
+	Update(ctx context.Context, key string, contents []byte) error
+	// Replace replaces all keys under rootKey with contents atomically, like the result
+	// of a list, readers get either the keys before or after the replace, never a mix of
+	// them. the keys in contents must be under rootKey, and the keys under rootKey that are
+	// not in contents are deleted. the root of a component or internal keys can not be replaced.
+	Replace(ctx context.Context, rootKey string, contents map[string][]byte) error
+}
+
+// OrderedStore is implemented by Store that can list in the order other than OrderByKey
+type OrderedStore interface {
+	ListKeysInOrder(ctx context.Context, key string, order ListOrder) ([]string, error)
+	ListInOrder(ctx context.Context, key string, order ListOrder) ([][]byte, error)
+}
+
+// KeyInfo is the metadata of a key in storage
+type KeyInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// StatStore is implemented by Store that can return the metadata of keys without reading contents
+type StatStore interface {
+	// StatKeys returns the metadata of keys under key in ascending lexical order of keys
+	StatKeys(ctx context.Context, key string) ([]KeyInfo, error)
+}
+
+// TouchStore is implemented by Store that can mark keys as refreshed without rewriting contents
+type TouchStore interface {
+	// Touch sets the ModTime of key to now, ErrNotFound is returned if key doesn't exist.
+	Touch(ctx context.Context, key string) error
+}
+
+// KeyLockStore is implemented by Store that can lock a key for a transaction of multiple
+// operations on it(like get and then update), the operations of others on the key wait
+// until the transaction is finished.
+type KeyLockStore interface {
+	// LockKey waits until key is locked or ctx is done. the returned context carries the
+	// lock, the operations on key in the transaction must be called with it.
+	LockKey(ctx context.Context, key string) (context.Context, error)
+	// UnlockKey unlocks key that is locked by LockKey, ctx is the context returned by LockKey.
+	UnlockKey(ctx context.Context, key string)
+}
+
+// BatchStore is implemented by Store that reads and writes multiple keys faster than one
+// by one, like by reading and writing files concurrently.
+type BatchStore interface {
+	// GetMulti returns the contents of keys. like List, the keys that don't exist or can't
+	// be read(like corrupted) are not in the result, an error is returned only if the batch
+	// can't be done, like for an invalid key or ctx is done.
+	GetMulti(ctx context.Context, keys []string) (map[string][]byte, error)
+	// SetMulti writes contents of keys like Update, it's not atomic, the keys written before
+	// an error are kept, use Replace for an atomic write of a collection.
+	SetMulti(ctx context.Context, contents map[string][]byte) error
+}
+
+// ReadOnlyStore is implemented by Store that can be read-only, and the Store that wraps
+// another Store, so the callers can skip the writes that would fail with ErrStorageReadOnly.
+type ReadOnlyStore interface {
+	// ReadOnly returns true if all writes of the store fail with ErrStorageReadOnly
+	ReadOnly() bool
+}
+
+// IsReadOnly returns true if s is read-only, the store that doesn't implement ReadOnlyStore
+// is writable.
+func IsReadOnly(s Store) bool {
+	if ro, ok := s.(ReadOnlyStore); ok {
+		return ro.ReadOnly()
+	}
+	return false
+}
+
+// UsageStore is implemented by Store that tracks the total bytes of its keys, so the
+// usage can be got without walking all keys.
+type UsageStore interface {
+	// Usage returns the total bytes of all keys in the store
+	Usage(ctx context.Context) (int64, error)
+}
+
+// Usage returns the total bytes of all keys in s, the sizes of keys are summed up for
+// the store that doesn't implement UsageStore.
+func Usage(ctx context.Context, s Store) (int64, error) {
+	if u, ok := s.(UsageStore); ok {
+		return u.Usage(ctx)
+	}
+
+	infos, err := StatKeys(ctx, s, "")
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for i := range infos {
+		total += infos[i].Size
+	}
+	return total, nil
+}
+
+// WriteStats is the bytes written by a Store since it's created
+type WriteStats struct {
+	// Requested is the bytes of contents that are requested to be written
+	Requested int64
+	// Written is the bytes that are written to the device, like the contents with their
+	// checksums or compressed, so Written/Requested is the write amplification.
+	Written int64
+}
+
+// WriteStatsStore is implemented by Store that counts the bytes it writes, like the stores
+// on flash(eMMC) whose wear is bounded by the bytes written.
+type WriteStatsStore interface {
+	// WriteStats returns the bytes written since the store is created, false is returned
+	// if the bytes are not counted, like by the store that wraps a store that doesn't count.
+	WriteStats() (WriteStats, bool)
+}
+
+// GetWriteStats returns the bytes written by s, false is returned if they are not counted
+func GetWriteStats(s Store) (WriteStats, bool) {
+	if w, ok := s.(WriteStatsStore); ok {
+		return w.WriteStats()
+	}
+	return WriteStats{}, false
+}
+
+// EvictStore is implemented by Store that deletes keys by itself(like for its quota), and the
+// Store that wraps another Store, so the caches in front of it can drop the evicted keys.
+type EvictStore interface {
+	// OnEvict adds f that is called with the key after it's evicted
+	OnEvict(f func(key string))
+}
+
+// OnEvict adds f that is called with the keys evicted by s, nothing is done for the store
+// that doesn't implement EvictStore, as its keys are only deleted by its callers.
+func OnEvict(s Store, f func(key string)) {
+	if e, ok := s.(EvictStore); ok {
+		e.OnEvict(f)
+	}
+}
+
+// EventType is the type of change of keys in a Store
+type EventType string
+
+const (
+	// EventWrite is sent when a key is created or updated
+	EventWrite EventType = "write"
+	// EventDelete is sent when a key is deleted
+	EventDelete EventType = "delete"
+	// EventResync is sent when the keys under the key of event may be changed without their
+	// own events(like replaced as a whole, or events are dropped), so they should be listed again.
+	EventResync EventType = "resync"
+)
+
+// Event is a change of keys in a Store
+type Event struct {
+	Type EventType
+	Key  string
+}
+
+// WatchStore is implemented by Store that can notify the changes of its keys, so the
+// components that react to the changes of cache don't need to poll ListKeys.
+type WatchStore interface {
+	// Watch returns a channel of the changes of keys under prefix, the channel is closed
+	// when ctx is done. the events are hints of changes, they may be merged or repeated,
+	// so the current contents of keys should be read when an event is received.
+	Watch(ctx context.Context, prefix string) (<-chan Event, error)
+}
+
+// Watch returns the changes of keys under prefix of s, ErrWatchNotSupported is returned
+// for the store that doesn't implement WatchStore.
+func Watch(ctx context.Context, s Store, prefix string) (<-chan Event, error) {
+	if w, ok := s.(WatchStore); ok {
+		return w.Watch(ctx, prefix)
+	}
+	return nil, ErrWatchNotSupported
+}
+
+// LockKey locks key of s for a transaction, ctx is returned as is for the store that
+// doesn't implement KeyLockStore.
+func LockKey(ctx context.Context, s Store, key string) (context.Context, error) {
+	if l, ok := s.(KeyLockStore); ok {
+		return l.LockKey(ctx, key)
+	}
+	return ctx, nil
+}
+
+// UnlockKey unlocks key of s that is locked by LockKey
+func UnlockKey(ctx context.Context, s Store, key string) {
+	if l, ok := s.(KeyLockStore); ok {
+		l.UnlockKey(ctx, key)
+	}
+}
+
+// Touch marks key as refreshed, like when its object is received again from cloud without
+// changes, so the key is not taken as stale. it's a no-op for the store that doesn't
+// implement TouchStore.
+func Touch(ctx context.Context, s Store, key string) error {
+	if t, ok := s.(TouchStore); ok {
+		return t.Touch(ctx, key)
+	}
+	return nil
+}
+
+// GetMulti returns the contents of keys that exist and can be read, keys are read one by
+// one for the store that doesn't implement BatchStore.
+func GetMulti(ctx context.Context, s Store, keys []string) (map[string][]byte, error) {
+	if b, ok := s.(BatchStore); ok {
+		return b.GetMulti(ctx, keys)
+	}
+
+	result := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		contents, err := s.Get(ctx, key)
+		if err == nil {
+			result[key] = contents
+		} else if err == ctx.Err() || err == ErrInvalidKey {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// SetMulti writes contents of keys, keys are updated one by one for the store that doesn't
+// implement BatchStore.
+func SetMulti(ctx context.Context, s Store, contents map[string][]byte) error {
+	if b, ok := s.(BatchStore); ok {
+		return b.SetMulti(ctx, contents)
+	}
+
+	for _, key := range sortedKeys(contents) {
+		if err := s.Update(ctx, key, contents[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of contents in ascending lexical order, so they are
+// written deterministically.
+func sortedKeys(contents map[string][]byte) []string {
+	keys := make([]string, 0, len(contents))
+	for key := range contents {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StatKeys returns the metadata of keys under key, for the store that doesn't implement
+// StatStore, contents are read to get the size, and ModTime is left zero.
+func StatKeys(ctx context.Context, s Store, key string) ([]KeyInfo, error) {
+	if stat, ok := s.(StatStore); ok {
+		return stat.StatKeys(ctx, key)
+	}
+
+	keys, err := s.ListKeys(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]KeyInfo, 0, len(keys))
+	for _, k := range keys {
+		b, err := s.Get(ctx, k)
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+		infos = append(infos, KeyInfo{Key: k, Size: int64(len(b))})
+	}
+
+	return infos, nil
+}
+
+// ListKeysInOrder returns keys under key in the specified order, an error is returned
+// if the order is not supported by the store.
+func ListKeysInOrder(ctx context.Context, s Store, key string, order ListOrder) ([]string, error) {
+	if ordered, ok := s.(OrderedStore); ok {
+		return ordered.ListKeysInOrder(ctx, key, order)
+	} else if order == OrderByKey {
+		return s.ListKeys(ctx, key)
+	}
+
+	return nil, fmt.Errorf("list order %s is not supported by storage", order)
+}
+
+// ValidateDeleteCollection checks the collection of key can be deleted or not
+func ValidateDeleteCollection(key string, force bool) error {
+	key = strings.Trim(filepath.Clean("/"+key), "/")
+	if key == "" {
+		return fmt.Errorf("root of storage can not be deleted")
+	} else if force {
+		return nil
+	}
+
+	if !strings.Contains(key, "/") || strings.HasPrefix(key, internalKeyPrefix+"/") {
+		return ErrProtectedKey
+	}
+
+	return nil
+}
+
+// ValidateReplace checks the keys under rootKey can be replaced by contents or not,
+// ErrInvalidKey is returned if a key in contents is not under rootKey.
+func ValidateReplace(rootKey string, contents map[string][]byte) error {
+	if err := ValidateDeleteCollection(rootKey, false); err != nil {
+		return err
+	}
+
+	prefix := strings.Trim(filepath.Clean("/"+rootKey), "/") + "/"
+	for key := range contents {
+		if !strings.HasPrefix(strings.Trim(filepath.Clean("/"+key), "/"), prefix) {
+			return ErrInvalidKey
+		}
+	}
+	return nil
 }
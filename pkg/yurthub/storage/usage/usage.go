@@ -0,0 +1,246 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alibaba/openyurt/cmd/yurthub/app/config"
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+const (
+	// HighWaterMarkReason is the reason of the event of node when the usage of cache
+	// crosses the high water mark
+	HighWaterMarkReason = "YurtHubCacheHighWaterMark"
+	// samplePeriod is the period that the usage of cache is sampled
+	samplePeriod = 5 * time.Minute
+	// storageTimeout bounds the time of walking the cache
+	storageTimeout = time.Minute
+)
+
+var (
+	cacheUsageBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "usage_bytes",
+			Help:      "Gauge of the total bytes of cache in storage.",
+		},
+	)
+	prefixUsageBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "prefix_usage_bytes",
+			Help:      "Gauge of the bytes of cache in storage by prefix(component/resource).",
+		},
+		[]string{"prefix"},
+	)
+	highWaterMarkExceeded = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "high_water_mark_exceeded",
+			Help:      "Gauge that is 1 when the usage of cache is above the high water mark, otherwise 0.",
+		},
+	)
+)
+
+var registerMetrics sync.Once
+
+func newHighWaterMark(limit int64) *mark {
+	return &mark{name: "usage of cache", limit: limit, gauge: highWaterMarkExceeded}
+}
+
+// Register the metrics of cache usage and write volume.
+func Register() {
+	registerMetrics.Do(func() {
+		prometheus.MustRegister(cacheUsageBytes)
+		prometheus.MustRegister(prefixUsageBytes)
+		prometheus.MustRegister(highWaterMarkExceeded)
+		prometheus.MustRegister(writtenBytesPerHour)
+		prometheus.MustRegister(estimatedDailyWrittenBytes)
+		prometheus.MustRegister(writeAmplification)
+		prometheus.MustRegister(dailyWriteWarningExceeded)
+	})
+}
+
+// mark is a threshold of a sampled value, it's crossed when the value goes from below it
+// to at or above it, and it's crossed again only after the value drops below it.
+type mark struct {
+	name     string
+	limit    int64
+	exceeded bool
+	gauge    prometheus.Gauge
+}
+
+// cross updates whether value is above the mark, true is returned only when value crosses
+// the mark from below. the mark is disabled if its limit is not set.
+func (m *mark) cross(value int64) bool {
+	if m.limit <= 0 {
+		return false
+	}
+
+	if value < m.limit {
+		if m.exceeded {
+			klog.Infof("%s(%d bytes) drops below %d bytes", m.name, value, m.limit)
+		}
+		m.exceeded = false
+		m.gauge.Set(0)
+		return false
+	}
+
+	crossed := !m.exceeded
+	m.exceeded = true
+	m.gauge.Set(1)
+	return crossed
+}
+
+// Sampler samples the usage and the write volume of cache in background and exposes them
+// by metrics, when they cross their marks, a warning is logged and an event of the node is
+// recorded once, until they drop below the marks again.
+type Sampler struct {
+	store            storage.Store
+	highWaterMark    *mark
+	nodeName         string
+	transportManager transport.Interface
+	writes           *writeWindow
+	dailyWriteMark   *mark
+	stopCh           <-chan struct{}
+}
+
+// NewSampler creates a Sampler for the cache in store
+func NewSampler(cfg *config.YurtHubConfiguration, store storage.Store, transportManager transport.Interface, stopCh <-chan struct{}) *Sampler {
+	Register()
+	return &Sampler{
+		store:            store,
+		highWaterMark:    newHighWaterMark(int64(cfg.CacheHighWaterMarkMB) * 1024 * 1024),
+		nodeName:         cfg.NodeName,
+		transportManager: transportManager,
+		writes:           &writeWindow{},
+		dailyWriteMark:   newDailyWriteMark(int64(cfg.CacheDailyWriteWarningMB) * 1024 * 1024),
+		stopCh:           stopCh,
+	}
+}
+
+// Run samples the usage of cache in background
+func (s *Sampler) Run() {
+	go wait.Until(s.sample, samplePeriod, s.stopCh)
+}
+
+func (s *Sampler) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), storageTimeout)
+	defer cancel()
+
+	s.sampleWrites(time.Now())
+
+	total, prefixes, err := s.usage(ctx)
+	if err != nil {
+		klog.Errorf("failed to sample usage of cache, %v", err)
+		return
+	}
+
+	cacheUsageBytes.Set(float64(total))
+	prefixUsageBytes.Reset()
+	for prefix, size := range prefixes {
+		prefixUsageBytes.WithLabelValues(prefix).Set(float64(size))
+	}
+
+	if s.highWaterMark.cross(total) {
+		message := fmt.Sprintf("yurthub cache uses %d bytes, above the high water mark of %d bytes, the largest prefixes are %v", total, s.highWaterMark.limit, largest(prefixes, 3))
+		klog.Warning(message)
+		s.recordEvent(HighWaterMarkReason, message)
+	}
+}
+
+// usage returns the total bytes of cache and the bytes of each prefix(component/resource)
+func (s *Sampler) usage(ctx context.Context) (int64, map[string]int64, error) {
+	total, err := storage.Usage(ctx, s.store)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	infos, err := storage.StatKeys(ctx, s.store, "")
+	if err != nil {
+		return 0, nil, err
+	}
+	prefixes := make(map[string]int64)
+	for _, info := range infos {
+		parts := strings.SplitN(strings.Trim(info.Key, "/"), "/", 3)
+		prefix := parts[0]
+		if len(parts) > 1 {
+			prefix = parts[0] + "/" + parts[1]
+		}
+		prefixes[prefix] += info.Size
+	}
+	return total, prefixes, nil
+}
+
+// recordEvent records a warning event of the node, it's skipped if the rest config is
+// not ready yet.
+func (s *Sampler) recordEvent(reason, message string) {
+	cfg := s.transportManager.GetRestClientConfig()
+	if cfg == nil {
+		klog.Warningf("rest config is not ready, skip recording event %s for node %s", reason, s.nodeName)
+		return
+	}
+	client, err := clientset.NewForConfig(cfg)
+	if err != nil {
+		klog.Errorf("could not new kube client, %v", err)
+		return
+	}
+	if err := s.record(client, reason, message); err != nil {
+		klog.Errorf("failed to record event %s for node %s, %v", reason, s.nodeName, err)
+	}
+}
+
+func (s *Sampler) record(client clientset.Interface, reason, message string) error {
+	now := metav1.Now()
+	_, err := client.CoreV1().Events(metav1.NamespaceDefault).Create(&v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: s.nodeName + ".",
+			Namespace:    metav1.NamespaceDefault,
+		},
+		InvolvedObject: v1.ObjectReference{Kind: "Node", Name: s.nodeName},
+		Reason:         reason,
+		Message:        message,
+		Source:         v1.EventSource{Component: "yurthub", Host: s.nodeName},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           v1.EventTypeWarning,
+	})
+	return err
+}
+
+// largest returns at most n prefixes that use the most bytes, in descending order of bytes
+func largest(prefixes map[string]int64, n int) []string {
+	names := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		names = append(names, prefix)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if prefixes[names[i]] != prefixes[names[j]] {
+			return prefixes[names[i]] > prefixes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	if len(names) > n {
+		names = names[:n]
+	}
+	result := make([]string, 0, len(names))
+	for _, prefix := range names {
+		result = append(result, fmt.Sprintf("%s(%d bytes)", prefix, prefixes[prefix]))
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage/memory"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestUsage(t *testing.T) {
+	store, _ := memory.NewMemoryStorage(0)
+	contents := map[string]string{
+		"kubelet/pods/default/pod1":        "1234567890",
+		"kubelet/pods/default/pod2":        "12345",
+		"kubelet/nodes/node1":              "123",
+		"kube-proxy/services/default/svc1": "12",
+		"_internal/cache-agents":           "1",
+	}
+	for key, value := range contents {
+		if err := store.Create(context.Background(), key, []byte(value)); err != nil {
+			t.Fatalf("failed to create %s, %v", key, err)
+		}
+	}
+
+	s := &Sampler{store: store}
+	total, prefixes, err := s.usage(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get usage, %v", err)
+	}
+	if total != 21 {
+		t.Errorf("expect total 21 bytes, but got %d", total)
+	}
+	expected := map[string]int64{
+		"kubelet/pods":           15,
+		"kubelet/nodes":          3,
+		"kube-proxy/services":    2,
+		"_internal/cache-agents": 1,
+	}
+	if !reflect.DeepEqual(prefixes, expected) {
+		t.Errorf("expect prefixes %v, but got %v", expected, prefixes)
+	}
+
+	top := largest(prefixes, 2)
+	if !reflect.DeepEqual(top, []string{"kubelet/pods(15 bytes)", "kubelet/nodes(3 bytes)"}) {
+		t.Errorf("unexpected largest prefixes %v", top)
+	}
+}
+
+func TestCross(t *testing.T) {
+	testcases := map[string]struct {
+		limit   int64
+		values  []int64
+		crossed []bool
+	}{
+		"disabled": {
+			values:  []int64{0, 100, 1000},
+			crossed: []bool{false, false, false},
+		},
+		"crossed once until dropping below": {
+			limit:   100,
+			values:  []int64{50, 100, 200, 99, 150},
+			crossed: []bool{false, true, false, false, true},
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			m := newHighWaterMark(tt.limit)
+			for i, value := range tt.values {
+				if crossed := m.cross(value); crossed != tt.crossed[i] {
+					t.Errorf("expect crossed %v for %d, but got %v", tt.crossed[i], value, crossed)
+				}
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	s := &Sampler{nodeName: "node1"}
+	if err := s.record(client, HighWaterMarkReason, "yurthub cache uses 200 bytes, the largest prefixes are [kubelet/pods(200 bytes)]"); err != nil {
+		t.Fatalf("failed to record event, %v", err)
+	}
+
+	events, err := client.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list events, %v", err)
+	}
+	if len(events.Items) != 1 {
+		t.Fatalf("expect 1 event, but got %d", len(events.Items))
+	}
+	event := events.Items[0]
+	if event.Reason != HighWaterMarkReason || event.InvolvedObject.Name != "node1" || !strings.Contains(event.Message, "kubelet/pods") {
+		t.Errorf("unexpected event %v", event)
+	}
+}
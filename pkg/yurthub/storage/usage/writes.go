@@ -0,0 +1,127 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/klog"
+)
+
+const (
+	// WriteVolumeReason is the reason of the event of node when the estimated daily write
+	// volume of cache crosses the warning threshold
+	WriteVolumeReason = "YurtHubCacheWriteVolume"
+	// writeWindowPeriod is the time that the write volume is averaged over
+	writeWindowPeriod = time.Hour
+)
+
+var (
+	writtenBytesPerHour = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "written_bytes_per_hour",
+			Help:      "Gauge of bytes written by storage per hour, averaged over the last hour.",
+		},
+	)
+	estimatedDailyWrittenBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "estimated_daily_written_bytes",
+			Help:      "Gauge of bytes that storage is estimated to write per day at the rate of the last hour.",
+		},
+	)
+	writeAmplification = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "write_amplification",
+			Help:      "Gauge of bytes written to the device per byte of contents written in the last hour.",
+		},
+	)
+	dailyWriteWarningExceeded = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "yurthub_cache",
+			Name:      "daily_write_warning_exceeded",
+			Help:      "Gauge that is 1 when the estimated daily write volume is above the warning threshold, otherwise 0.",
+		},
+	)
+)
+
+func newDailyWriteMark(limit int64) *mark {
+	return &mark{name: "estimated daily write volume of cache", limit: limit, gauge: dailyWriteWarningExceeded}
+}
+
+type writeSample struct {
+	at    time.Time
+	stats storage.WriteStats
+}
+
+// writeWindow keeps the write stats sampled in the last hour, and the one sampled right
+// before the hour, so the rate is averaged over at least an hour once it's sampled long enough.
+type writeWindow struct {
+	samples []writeSample
+}
+
+// writeRate is the write volume averaged over a period
+type writeRate struct {
+	period        time.Duration
+	perHour       int64
+	daily         int64
+	amplification float64
+}
+
+// add records the stats sampled at now, and returns the rate over the window. false is
+// returned if there is no earlier sample to compare with.
+func (w *writeWindow) add(now time.Time, stats storage.WriteStats) (writeRate, bool) {
+	w.samples = append(w.samples, writeSample{at: now, stats: stats})
+	for len(w.samples) > 2 && !w.samples[1].at.After(now.Add(-writeWindowPeriod)) {
+		w.samples = w.samples[1:]
+	}
+
+	oldest := w.samples[0]
+	period := now.Sub(oldest.at)
+	if period <= 0 {
+		return writeRate{}, false
+	}
+
+	written := stats.Written - oldest.stats.Written
+	requested := stats.Requested - oldest.stats.Requested
+	rate := writeRate{
+		period:  period,
+		perHour: int64(float64(written) * float64(time.Hour) / float64(period)),
+	}
+	rate.daily = rate.perHour * 24
+	if requested > 0 {
+		rate.amplification = float64(written) / float64(requested)
+	}
+	return rate, true
+}
+
+// sampleWrites samples the bytes written by storage and exposes the write volume, the
+// warning is checked only after the volume is averaged over a whole window, so the burst
+// of writes when yurthub starts(like caching all pods of node) is not taken as the rate.
+func (s *Sampler) sampleWrites(now time.Time) {
+	stats, ok := storage.GetWriteStats(s.store)
+	if !ok {
+		return
+	}
+
+	rate, ok := s.writes.add(now, stats)
+	if !ok {
+		return
+	}
+	writtenBytesPerHour.Set(float64(rate.perHour))
+	estimatedDailyWrittenBytes.Set(float64(rate.daily))
+	writeAmplification.Set(rate.amplification)
+
+	if rate.period < writeWindowPeriod {
+		return
+	}
+	if s.dailyWriteMark.cross(rate.daily) {
+		message := fmt.Sprintf("yurthub cache writes %d bytes per hour(write amplification %.2f), %d bytes per day is estimated, above the warning threshold of %d bytes", rate.perHour, rate.amplification, rate.daily, s.dailyWriteMark.limit)
+		klog.Warning(message)
+		s.recordEvent(WriteVolumeReason, message)
+	}
+}
@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alibaba/openyurt/pkg/yurthub/storage"
+	"github.com/alibaba/openyurt/pkg/yurthub/transport"
+
+	"k8s.io/client-go/rest"
+)
+
+func TestWriteWindow(t *testing.T) {
+	start := time.Now()
+	w := &writeWindow{}
+	if _, ok := w.add(start, storage.WriteStats{}); ok {
+		t.Errorf("expect no rate for the first sample")
+	}
+
+	// 1MB of contents are written as 2MB every 5 minutes
+	var rate writeRate
+	for i := 1; i <= 24; i++ {
+		var ok bool
+		stats := storage.WriteStats{Requested: int64(i) << 20, Written: int64(i) << 21}
+		if rate, ok = w.add(start.Add(time.Duration(i)*5*time.Minute), stats); !ok {
+			t.Fatalf("expect rate for sample %d", i)
+		}
+		if i == 6 && (rate.period != 30*time.Minute || rate.perHour != 24<<20) {
+			t.Errorf("expect 24MB per hour over 30m, but got %d over %v", rate.perHour, rate.period)
+		}
+	}
+
+	if rate.period != time.Hour {
+		t.Errorf("expect rate over an hour, but got %v", rate.period)
+	}
+	if len(w.samples) != 13 {
+		t.Errorf("expect samples of an hour are kept, but got %d", len(w.samples))
+	}
+	if rate.perHour != 24<<20 || rate.daily != 24*24<<20 {
+		t.Errorf("expect 24MB per hour and 576MB per day, but got %d and %d", rate.perHour, rate.daily)
+	}
+	if rate.amplification != 2 {
+		t.Errorf("expect write amplification 2, but got %v", rate.amplification)
+	}
+}
+
+// fakeWriteStore counts the bytes written without storing anything
+type fakeWriteStore struct {
+	storage.Store
+	stats storage.WriteStats
+}
+
+func (f *fakeWriteStore) WriteStats() (storage.WriteStats, bool) {
+	return f.stats, true
+}
+
+// fakeTransport has no rest config, like before the certificate of yurthub is ready
+type fakeTransport struct {
+	transport.Interface
+}
+
+func (f *fakeTransport) GetRestClientConfig() *rest.Config {
+	return nil
+}
+
+func TestSampleWrites(t *testing.T) {
+	store := &fakeWriteStore{}
+	s := &Sampler{
+		store:            store,
+		transportManager: &fakeTransport{},
+		writes:           &writeWindow{},
+		dailyWriteMark:   newDailyWriteMark(100 << 20),
+	}
+
+	// the burst when yurthub starts is not taken as the daily volume
+	start := time.Now()
+	s.sampleWrites(start)
+	store.stats = storage.WriteStats{Requested: 10 << 20, Written: 10 << 20}
+	s.sampleWrites(start.Add(5 * time.Minute))
+	if s.dailyWriteMark.exceeded {
+		t.Errorf("expect warning is not checked within the first hour")
+	}
+
+	store.stats = storage.WriteStats{Requested: 11 << 20, Written: 11 << 20}
+	s.sampleWrites(start.Add(time.Hour))
+	if !s.dailyWriteMark.exceeded {
+		t.Errorf("expect 264MB per day is above the warning threshold")
+	}
+}
@@ -0,0 +1,16 @@
+package testing
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// FakeClock is a clock whose time is stepped by tests, tickers and timers created by
+// the components fire when the time is stepped over them.
+type FakeClock = clock.FakeClock
+
+// NewFakeClock creates a FakeClock at t
+func NewFakeClock(t time.Time) *FakeClock {
+	return clock.NewFakeClock(t)
+}
@@ -0,0 +1,4 @@
+// Package testing provides the simulated time and network for unit testing the components of
+// yurthub(like health checker, gc and certificate managers) deterministically, the components
+// accept them by their With constructors, or by their clock and client fields in tests.
+package testing
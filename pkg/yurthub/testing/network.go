@@ -0,0 +1,93 @@
+package testing
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+)
+
+// FakeNetwork simulates the connectivity between yurthub and remote servers, the servers
+// are reachable until they are disconnected. it implements the Prober of health checker,
+// and provides the clients of kube-apiserver that fail while the server is disconnected.
+type FakeNetwork struct {
+	sync.Mutex
+	disconnected sets.String
+	partitioned  bool
+	probes       map[string]int
+}
+
+// NewFakeNetwork creates a FakeNetwork with all servers reachable
+func NewFakeNetwork() *FakeNetwork {
+	return &FakeNetwork{
+		disconnected: sets.NewString(),
+		probes:       make(map[string]int),
+	}
+}
+
+// Disconnect makes the hosts(host:port) unreachable, or all servers if no host is specified
+func (n *FakeNetwork) Disconnect(hosts ...string) {
+	n.Lock()
+	defer n.Unlock()
+	if len(hosts) == 0 {
+		n.partitioned = true
+		return
+	}
+	n.disconnected.Insert(hosts...)
+}
+
+// Connect makes the hosts(host:port) reachable again, or all servers if no host is specified
+func (n *FakeNetwork) Connect(hosts ...string) {
+	n.Lock()
+	defer n.Unlock()
+	if len(hosts) == 0 {
+		n.partitioned = false
+		n.disconnected = sets.NewString()
+		return
+	}
+	n.disconnected.Delete(hosts...)
+}
+
+// Reachable returns true if host(host:port) is reachable
+func (n *FakeNetwork) Reachable(host string) bool {
+	n.Lock()
+	defer n.Unlock()
+	return !n.partitioned && !n.disconnected.Has(host)
+}
+
+// Probe probes the healthz address, it fails if the host of address is unreachable
+func (n *FakeNetwork) Probe(healthzAddr string) error {
+	u, err := url.Parse(healthzAddr)
+	if err != nil {
+		return err
+	}
+	n.Lock()
+	n.probes[u.Host]++
+	n.Unlock()
+
+	if !n.Reachable(u.Host) {
+		return fmt.Errorf("dial tcp %s: connect: network is unreachable", u.Host)
+	}
+	return nil
+}
+
+// Probes returns the times that host(host:port) is probed, tests wait for the probes to
+// know that a heartbeat is handled.
+func (n *FakeNetwork) Probes(host string) int {
+	n.Lock()
+	defer n.Unlock()
+	return n.probes[host]
+}
+
+// ClientFunc returns a func that returns client as the client of kube-apiserver on host(host:port),
+// it fails while host is unreachable.
+func (n *FakeNetwork) ClientFunc(host string, client kubernetes.Interface) func() (kubernetes.Interface, error) {
+	return func() (kubernetes.Interface, error) {
+		if !n.Reachable(host) {
+			return nil, fmt.Errorf("dial tcp %s: connect: network is unreachable", host)
+		}
+		return client, nil
+	}
+}
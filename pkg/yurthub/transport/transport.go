@@ -26,7 +26,7 @@ type Interface interface {
 	HealthzHttpClient() *http.Client
 	// concurrent use by multiple goroutines
 	// CurrentTransport get transport that used by load balancer
-	CurrentTransport() *http.Transport
+	CurrentTransport() http.RoundTripper
 	// GetRestClientConfig get rest config that used by gc
 	GetRestClientConfig() *rest.Config
 	// UpdateTransport update secure transport manager with certificate manager
@@ -38,15 +38,17 @@ type Interface interface {
 type transportManager struct {
 	dialer            *util.Dialer
 	healthzHttpClient *http.Client
-	currentTransport  *http.Transport
+	currentTransport  http.RoundTripper
 	certManager       interfaces.YurtCertificateManager
 	closeAll          func()
 	close             func(string)
 	stopCh            <-chan struct{}
 }
 
-func NewTransportManager(heartbeatTimeoutSeconds int, stopCh <-chan struct{}) (Interface, error) {
-	d := util.NewDialer("transport manager")
+// NewTransportManager creates a transport manager, host names of remote servers are
+// resolved by dial if it's not nil.
+func NewTransportManager(heartbeatTimeoutSeconds int, dial util.DialFunc, stopCh <-chan struct{}) (Interface, error) {
+	d := util.NewDialer("transport manager", dial)
 	t := utilnet.SetTransportDefaults(&http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		TLSHandshakeTimeout: 10 * time.Second,
@@ -86,13 +88,18 @@ func (tm *transportManager) UpdateTransport(certMgr interfaces.YurtCertificateMa
 		return err
 	}
 
-	tm.currentTransport = utilnet.SetTransportDefaults(&http.Transport{
+	var rt http.RoundTripper = utilnet.SetTransportDefaults(&http.Transport{
 		Proxy:               http.ProxyFromEnvironment,
 		TLSHandshakeTimeout: 10 * time.Second,
 		TLSClientConfig:     cfg,
 		MaxIdleConnsPerHost: 25,
 		DialContext:         tm.dialer.DialContext,
 	})
+	if source, ok := certMgr.(interfaces.TokenSource); ok {
+		klog.V(2).Infof("use bearer tokens of cert manager to access remote server")
+		rt = NewBearerTokenRoundTripper(source, rt)
+	}
+	tm.currentTransport = rt
 	tm.certManager = certMgr
 
 	tm.start()
@@ -107,7 +114,7 @@ func (tm *transportManager) GetRestClientConfig() *rest.Config {
 	return tm.certManager.GetRestConfig()
 }
 
-func (tm *transportManager) CurrentTransport() *http.Transport {
+func (tm *transportManager) CurrentTransport() http.RoundTripper {
 	return tm.currentTransport
 }
 
@@ -144,6 +151,38 @@ func (tm *transportManager) start() {
 	}, 10*time.Second, tm.stopCh)
 }
 
+// bearerTokenRoundTripper sets the token of TokenSource on requests, the token of clients
+// is replaced, as the client certificate of yurthub takes precedence over the tokens of
+// clients in cert modes.
+type bearerTokenRoundTripper struct {
+	source interfaces.TokenSource
+	rt     http.RoundTripper
+}
+
+// NewBearerTokenRoundTripper returns a round tripper that authenticates requests by the tokens of source
+func NewBearerTokenRoundTripper(source interfaces.TokenSource, rt http.RoundTripper) http.RoundTripper {
+	return &bearerTokenRoundTripper{source: source, rt: rt}
+}
+
+func (b *bearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := b.source.Token()
+	if len(token) == 0 {
+		return b.rt.RoundTrip(req)
+	}
+
+	req = utilnet.CloneRequest(req)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := b.rt.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		b.source.InvalidateToken(token)
+	}
+	return resp, err
+}
+
+func (b *bearerTokenRoundTripper) WrappedRoundTripper() http.RoundTripper {
+	return b.rt
+}
+
 func tlsConfig(certMgr interfaces.YurtCertificateManager, caFile string) (*tls.Config, error) {
 	root, err := rootCertPool(caFile)
 	if err != nil {
@@ -25,10 +25,14 @@ type Dialer struct {
 //
 // If dial is not nil, it will be used to create new underlying connections.
 // Otherwise net.DialContext is used.
-func NewDialer(name string) *Dialer {
+func NewDialer(name string, dial DialFunc) *Dialer {
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+
 	return &Dialer{
 		name:      name,
-		dial:      (&net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		dial:      dial,
 		addrConns: make(map[string]map[net.Conn]struct{}),
 	}
 }
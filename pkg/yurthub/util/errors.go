@@ -0,0 +1,79 @@
+package util
+
+import (
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CauseTypeRemoteUnavailable means yurthub can't reach kube-apiserver for the request
+	CauseTypeRemoteUnavailable metav1.CauseType = "YurtHubRemoteUnavailable"
+	// CauseTypeNotCached means the request can't be served from the cache of yurthub
+	// while kube-apiserver is unreachable
+	CauseTypeNotCached metav1.CauseType = "YurtHubNotCached"
+	// CauseTypeThrottled means the request is throttled by yurthub
+	CauseTypeThrottled metav1.CauseType = "YurtHubThrottled"
+	// CauseTypeRejected means the request is rejected by a plugin of yurthub
+	CauseTypeRejected metav1.CauseType = "YurtHubRejected"
+
+	// DefaultRetryAfterSeconds is the Retry-After of the errors that are expected to be transient,
+	// client-go only retries 429 and 5xx responses with Retry-After.
+	DefaultRetryAfterSeconds = 1
+)
+
+// NewStatusError returns the status error of code with the reason of code, and the cause of
+// yurthub, so clients can tell the failures of yurthub from the failures of kube-apiserver.
+// retryAfterSeconds is set as Retry-After of the response if it's positive.
+func NewStatusError(code int, cause metav1.CauseType, message string, retryAfterSeconds int) *apierrors.StatusError {
+	status := metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    int32(code),
+		Reason:  reasonForCode(code),
+		Message: message,
+		Details: &metav1.StatusDetails{
+			Causes: []metav1.StatusCause{{Type: cause, Message: message}},
+		},
+	}
+	if retryAfterSeconds > 0 {
+		status.Details.RetryAfterSeconds = int32(retryAfterSeconds)
+	}
+	return &apierrors.StatusError{ErrStatus: status}
+}
+
+// NewRemoteUnavailable returns the error when kube-apiserver can't be reached for a request
+func NewRemoteUnavailable(message string) *apierrors.StatusError {
+	return NewStatusError(http.StatusServiceUnavailable, CauseTypeRemoteUnavailable, message, DefaultRetryAfterSeconds)
+}
+
+// NewNotCached returns the error when a request can't be served from cache while kube-apiserver is unreachable
+func NewNotCached(message string) *apierrors.StatusError {
+	return NewStatusError(http.StatusServiceUnavailable, CauseTypeNotCached, message, DefaultRetryAfterSeconds)
+}
+
+func reasonForCode(code int) metav1.StatusReason {
+	switch code {
+	case http.StatusBadRequest:
+		return metav1.StatusReasonBadRequest
+	case http.StatusUnauthorized:
+		return metav1.StatusReasonUnauthorized
+	case http.StatusForbidden:
+		return metav1.StatusReasonForbidden
+	case http.StatusNotFound:
+		return metav1.StatusReasonNotFound
+	case http.StatusMethodNotAllowed:
+		return metav1.StatusReasonMethodNotAllowed
+	case http.StatusConflict:
+		return metav1.StatusReasonConflict
+	case http.StatusTooManyRequests:
+		return metav1.StatusReasonTooManyRequests
+	case http.StatusInternalServerError:
+		return metav1.StatusReasonInternalError
+	case http.StatusServiceUnavailable:
+		return metav1.StatusReasonServiceUnavailable
+	case http.StatusGatewayTimeout:
+		return metav1.StatusReasonTimeout
+	}
+	return metav1.StatusReasonUnknown
+}
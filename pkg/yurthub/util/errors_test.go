@@ -0,0 +1,82 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apirequest "k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestErrWithStatusError(t *testing.T) {
+	testcases := map[string]struct {
+		err        error
+		code       int
+		reason     metav1.StatusReason
+		cause      metav1.CauseType
+		retryAfter string
+	}{
+		"remote unavailable": {
+			err:        NewRemoteUnavailable("kube-apiserver is unreachable"),
+			code:       http.StatusServiceUnavailable,
+			reason:     metav1.StatusReasonServiceUnavailable,
+			cause:      CauseTypeRemoteUnavailable,
+			retryAfter: "1",
+		},
+		"not cached": {
+			err:        NewNotCached("pods is not cached"),
+			code:       http.StatusServiceUnavailable,
+			reason:     metav1.StatusReasonServiceUnavailable,
+			cause:      CauseTypeNotCached,
+			retryAfter: "1",
+		},
+		"throttled": {
+			err:        NewStatusError(http.StatusTooManyRequests, CauseTypeThrottled, "too many requests", 3),
+			code:       http.StatusTooManyRequests,
+			reason:     metav1.StatusReasonTooManyRequests,
+			cause:      CauseTypeThrottled,
+			retryAfter: "3",
+		},
+		"rejected": {
+			err:    NewStatusError(http.StatusForbidden, CauseTypeRejected, "denied by policy", 0),
+			code:   http.StatusForbidden,
+			reason: metav1.StatusReasonForbidden,
+			cause:  CauseTypeRejected,
+		},
+	}
+
+	for k, tt := range testcases {
+		t.Run(k, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/namespaces/default/pods", nil)
+			req.Header.Set("Accept", "application/json")
+			req = req.WithContext(apirequest.WithRequestInfo(req.Context(), &apirequest.RequestInfo{
+				IsResourceRequest: true,
+				APIVersion:        "v1",
+				Resource:          "pods",
+				Verb:              "list",
+			}))
+			w := httptest.NewRecorder()
+			Err(tt.err, w, req)
+
+			if w.Code != tt.code {
+				t.Errorf("expect status code %d, but got %d", tt.code, w.Code)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("expect Retry-After %q, but got %q", tt.retryAfter, got)
+			}
+
+			status := &metav1.Status{}
+			if err := json.Unmarshal(w.Body.Bytes(), status); err != nil {
+				t.Fatalf("failed to decode status %s, %v", w.Body.String(), err)
+			}
+			if status.Kind != "Status" || status.Reason != tt.reason || int(status.Code) != tt.code {
+				t.Errorf("expect status with reason %s and code %d, but got %#v", tt.reason, tt.code, status)
+			}
+			if status.Details == nil || len(status.Details.Causes) != 1 || status.Details.Causes[0].Type != tt.cause {
+				t.Errorf("expect cause %s, but got %#v", tt.cause, status.Details)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -25,6 +26,8 @@ const (
 	ProxyRespContentType
 	ProxyClientComponent
 	ProxyReqCanCache
+	ProxyPartialList
+	ProxyReqQuery
 )
 
 // WithValue returns a copy of parent in which the value associated with key is val.
@@ -76,6 +79,28 @@ func ReqCanCacheFrom(ctx context.Context) (bool, bool) {
 	return info, ok
 }
 
+// WithPartialList returns a copy of parent in which the partial list value is set
+func WithPartialList(parent context.Context, partial bool) context.Context {
+	return WithValue(parent, ProxyPartialList, partial)
+}
+
+// PartialListFrom returns the value of the partial list key on the ctx
+func PartialListFrom(ctx context.Context) (bool, bool) {
+	info, ok := ctx.Value(ProxyPartialList).(bool)
+	return info, ok
+}
+
+// WithReqQuery returns a copy of parent in which the raw query of request is set
+func WithReqQuery(parent context.Context, rawQuery string) context.Context {
+	return WithValue(parent, ProxyReqQuery, rawQuery)
+}
+
+// ReqQueryFrom returns the value of the raw query key on the ctx
+func ReqQueryFrom(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(ProxyReqQuery).(string)
+	return info, ok
+}
+
 func ReqString(req *http.Request) string {
 	ctx := req.Context()
 	comp, _ := ClientComponentFrom(ctx)
@@ -219,9 +244,39 @@ func IsSupportedLBMode(lbMode string) bool {
 
 func IsSupportedCertMode(certMode string) bool {
 	switch certMode {
-	case "kubelet":
+	case "kubelet", "exec":
 		return true
 	}
 
 	return false
 }
+
+// ReadCloser reads the consumed and the remaining body of request, and closes the original body
+type ReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ResponseRecorder records the response of handler, so it's cached or shared with other
+// requests before it's responded
+type ResponseRecorder struct {
+	header     http.Header
+	StatusCode int
+	Body       bytes.Buffer
+}
+
+func NewResponseRecorder() *ResponseRecorder {
+	return &ResponseRecorder{header: http.Header{}, StatusCode: http.StatusOK}
+}
+
+func (r *ResponseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *ResponseRecorder) WriteHeader(code int) {
+	r.StatusCode = code
+}
+
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	return r.Body.Write(b)
+}